@@ -3,10 +3,15 @@ package main
 import (
 	"context"
 	"net"
+	"time"
 	"ucode/ucode_go_object_builder_service/config"
 	"ucode/ucode_go_object_builder_service/grpc"
 	"ucode/ucode_go_object_builder_service/grpc/client"
+	"ucode/ucode_go_object_builder_service/pkg/cache"
+	"ucode/ucode_go_object_builder_service/pkg/formula"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
 	"ucode/ucode_go_object_builder_service/pkg/logger"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
 	"ucode/ucode_go_object_builder_service/storage/postgres"
 
 	"ucode/ucode_go_object_builder_service/pkg/cron"
@@ -55,6 +60,23 @@ func main() {
 
 	log.Info("Service env", logger.Any("cfg", cfg.SafeLogFields()))
 
+	psqlpool.SetDefaultBudgets(map[string]time.Duration{
+		psqlpool.BudgetList:        cfg.QueryTimeoutList,
+		psqlpool.BudgetExport:      cfg.QueryTimeoutExport,
+		psqlpool.BudgetUserSQL:     cfg.QueryTimeoutUserSQL,
+		psqlpool.BudgetAggregation: cfg.QueryTimeoutAggregation,
+	})
+
+	// The LRU is per replica: writes on one replica do not invalidate the
+	// entries of another, which live until RESULT_CACHE_TTL.
+	cache.SetDefault(cache.New(cache.NewLRU(cfg.ResultCacheSize), cfg.ResultCacheTTL))
+
+	formula.SetLimits(formula.Limits{Timeout: cfg.FormulaTimeout, MaxMemory: cfg.FormulaMaxMemory})
+
+	if err := helper.SetMasterKeys(cfg.EncryptionMasterKey, cfg.EncryptionPreviousMasterKeys); err != nil {
+		log.Panic("helper.SetMasterKeys", logger.Error(err))
+	}
+
 	tracer, closer, err := jaegerCfg.NewTracer(jaeger_config.Logger(jaeger.StdLogger))
 	if err != nil {
 		log.Error("ERROR: cannot init Jaeger", logger.Error(err))
@@ -77,7 +99,7 @@ func main() {
 
 	// ------------ cron -------------
 	{
-		cronJ := cron.New(log, pgStore, svcs)
+		cronJ := cron.New(cfg, log, pgStore, svcs)
 		err = cronJ.RunJobs(ctx)
 		if err != nil {
 			log.Panic("cronJ.RunJobs", logger.Error(err))
@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/cast"
@@ -51,34 +53,78 @@ type Config struct {
 	MinioSSL         bool
 
 	PostgresMaxConnections int32
+
+	QueryTimeoutList        time.Duration
+	QueryTimeoutExport      time.Duration
+	QueryTimeoutUserSQL     time.Duration
+	QueryTimeoutAggregation time.Duration
+
+	ResultCacheSize int
+	ResultCacheTTL  time.Duration
+
+	FormulaTimeout   time.Duration
+	FormulaMaxMemory int
+
+	FormulaQueueInterval  time.Duration
+	FormulaQueueBatchSize int
+
+	// EncryptionMasterKey wraps the data keys of encrypted fields. Keys
+	// wrapped by one of EncryptionPreviousMasterKeys are rewrapped by the
+	// re-encrypt job.
+	EncryptionMasterKey          string
+	EncryptionPreviousMasterKeys []string
+	EncryptionKeyRotation        time.Duration
+	EncryptionBatchSize          int
+
+	// AuditSigningKey is the base64 seed of the Ed25519 key signing audit
+	// checkpoints. Once it is set, version history partitions are only
+	// dropped after a checkpoint covering them was exported to AuditBucket.
+	AuditSigningKey string
+	AuditBucket     string
 }
 
 func (c Config) SafeLogFields() map[string]any {
 	return map[string]any{
-		"ServiceName":            c.ServiceName,
-		"ServiceHost":            c.ServiceHost,
-		"ServicePort":            c.ServicePort,
-		"Environment":            c.Environment,
-		"Version":                c.Version,
-		"JaegerHostPort":         c.JaegerHostPort,
-		"PostgresHost":           c.PostgresHost,
-		"PostgresPort":           c.PostgresPort,
-		"PostgresUser":           c.PostgresUser,
-		"PostgresPassword":       redact(c.PostgresPassword),
-		"PostgresDatabase":       c.PostgresDatabase,
-		"AuthServiceHost":        c.AuthServiceHost,
-		"AuthGRPCPort":           c.AuthGRPCPort,
-		"CompanyServiceHost":     c.CompanyServiceHost,
-		"CompanyServicePort":     c.CompanyServicePort,
-		"TranscoderServiceHost":  c.TranscoderServiceHost,
-		"TranscoderServicePort":  c.TranscoderServicePort,
-		"NodeType":               c.NodeType,
-		"K8sNamespace":           c.K8sNamespace,
-		"MinioHost":              c.MinioHost,
-		"MinioAccessKeyID":       redact(c.MinioAccessKeyID),
-		"MinioSecretKey":         redact(c.MinioSecretKey),
-		"MinioSSL":               c.MinioSSL,
-		"PostgresMaxConnections": c.PostgresMaxConnections,
+		"ServiceName":                  c.ServiceName,
+		"ServiceHost":                  c.ServiceHost,
+		"ServicePort":                  c.ServicePort,
+		"Environment":                  c.Environment,
+		"Version":                      c.Version,
+		"JaegerHostPort":               c.JaegerHostPort,
+		"PostgresHost":                 c.PostgresHost,
+		"PostgresPort":                 c.PostgresPort,
+		"PostgresUser":                 c.PostgresUser,
+		"PostgresPassword":             redact(c.PostgresPassword),
+		"PostgresDatabase":             c.PostgresDatabase,
+		"AuthServiceHost":              c.AuthServiceHost,
+		"AuthGRPCPort":                 c.AuthGRPCPort,
+		"CompanyServiceHost":           c.CompanyServiceHost,
+		"CompanyServicePort":           c.CompanyServicePort,
+		"TranscoderServiceHost":        c.TranscoderServiceHost,
+		"TranscoderServicePort":        c.TranscoderServicePort,
+		"NodeType":                     c.NodeType,
+		"K8sNamespace":                 c.K8sNamespace,
+		"MinioHost":                    c.MinioHost,
+		"MinioAccessKeyID":             redact(c.MinioAccessKeyID),
+		"MinioSecretKey":               redact(c.MinioSecretKey),
+		"MinioSSL":                     c.MinioSSL,
+		"PostgresMaxConnections":       c.PostgresMaxConnections,
+		"QueryTimeoutList":             c.QueryTimeoutList.String(),
+		"QueryTimeoutExport":           c.QueryTimeoutExport.String(),
+		"QueryTimeoutUserSQL":          c.QueryTimeoutUserSQL.String(),
+		"QueryTimeoutAggregation":      c.QueryTimeoutAggregation.String(),
+		"ResultCacheSize":              c.ResultCacheSize,
+		"ResultCacheTTL":               c.ResultCacheTTL.String(),
+		"FormulaTimeout":               c.FormulaTimeout.String(),
+		"FormulaMaxMemory":             c.FormulaMaxMemory,
+		"FormulaQueueInterval":         c.FormulaQueueInterval.String(),
+		"FormulaQueueBatchSize":        c.FormulaQueueBatchSize,
+		"EncryptionMasterKey":          redact(c.EncryptionMasterKey),
+		"EncryptionPreviousMasterKeys": redact(strings.Join(c.EncryptionPreviousMasterKeys, "")),
+		"EncryptionKeyRotation":        c.EncryptionKeyRotation.String(),
+		"EncryptionBatchSize":          c.EncryptionBatchSize,
+		"AuditSigningKey":              redact(c.AuditSigningKey),
+		"AuditBucket":                  c.AuditBucket,
 	}
 }
 
@@ -134,6 +180,28 @@ func Load() Config {
 
 	config.PostgresMaxConnections = cast.ToInt32(getOrReturnDefaultValue("POSTGRES_MAX_CONNECTIONS", 500))
 
+	config.QueryTimeoutList = cast.ToDuration(getOrReturnDefaultValue("QUERY_TIMEOUT_LIST", "10s"))
+	config.QueryTimeoutExport = cast.ToDuration(getOrReturnDefaultValue("QUERY_TIMEOUT_EXPORT", "5m"))
+	config.QueryTimeoutUserSQL = cast.ToDuration(getOrReturnDefaultValue("QUERY_TIMEOUT_USER_SQL", "30s"))
+	config.QueryTimeoutAggregation = cast.ToDuration(getOrReturnDefaultValue("QUERY_TIMEOUT_AGGREGATION", "30s"))
+
+	config.ResultCacheSize = cast.ToInt(getOrReturnDefaultValue("RESULT_CACHE_SIZE", 10000))
+	config.ResultCacheTTL = cast.ToDuration(getOrReturnDefaultValue("RESULT_CACHE_TTL", "1m"))
+
+	config.FormulaTimeout = cast.ToDuration(getOrReturnDefaultValue("FORMULA_TIMEOUT", "100ms"))
+	config.FormulaMaxMemory = cast.ToInt(getOrReturnDefaultValue("FORMULA_MAX_MEMORY", 1<<20))
+
+	config.FormulaQueueInterval = cast.ToDuration(getOrReturnDefaultValue("FORMULA_QUEUE_INTERVAL", "2s"))
+	config.FormulaQueueBatchSize = cast.ToInt(getOrReturnDefaultValue("FORMULA_QUEUE_BATCH_SIZE", 1000))
+
+	config.EncryptionMasterKey = cast.ToString(getOrReturnDefaultValue("ENCRYPTION_MASTER_KEY", ""))
+	config.EncryptionPreviousMasterKeys = cast.ToStringSlice(getOrReturnDefaultValue("ENCRYPTION_PREVIOUS_MASTER_KEYS", ""))
+	config.EncryptionKeyRotation = cast.ToDuration(getOrReturnDefaultValue("ENCRYPTION_KEY_ROTATION", "2160h"))
+	config.EncryptionBatchSize = cast.ToInt(getOrReturnDefaultValue("ENCRYPTION_BATCH_SIZE", 500))
+
+	config.AuditSigningKey = cast.ToString(getOrReturnDefaultValue("AUDIT_SIGNING_KEY", ""))
+	config.AuditBucket = cast.ToString(getOrReturnDefaultValue("AUDIT_BUCKET", "audit"))
+
 	return config
 }
 
@@ -28,9 +28,16 @@ const (
 	ONE2ONE      string = "One2One"
 	ONE2MANY     string = "One2Many"
 
+	// Relation ON DELETE policies
+	ON_DELETE_CASCADE   string = "CASCADE"
+	ON_DELETE_RESTRICT  string = "RESTRICT"
+	ON_DELETE_SET_NULL  string = "SET NULL"
+	ON_DELETE_NO_ACTION string = "NO ACTION"
+
 	// Filed Types
-	INCREMENT_ID string = "INCREMENT_ID"
-	PERSON       string = "PERSON"
+	INCREMENT_ID   string = "INCREMENT_ID"
+	PERSON         string = "PERSON"
+	DYNAMIC_LOOKUP string = "DYNAMIC_LOOKUP"
 
 	// Table Slugs
 	CLIENT_TYPE       string = "client_type"
@@ -45,9 +52,10 @@ const (
 	LAST_ACTIVITY  string = "last_activity"
 
 	// Tree
-	GUID string = "guid"
-	PATH string = "path"
-	ID   string = "_id"
+	GUID      string = "guid"
+	PATH      string = "path"
+	ID        string = "_id"
+	TREE_PATH string = "tree_path"
 
 	GRPC_MAX_CALL_SEND_MSG_SIZE = 100 * 1024 * 1024
 	GRPC_MAX_CALL_RECV_MSG_SIZE = 100 * 1024 * 1024
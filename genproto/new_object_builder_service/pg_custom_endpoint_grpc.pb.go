@@ -28,6 +28,10 @@ type CustomEndpointServiceClient interface {
 	GetById(ctx context.Context, in *CustomEndpointId, opts ...grpc.CallOption) (*CustomEndpoint, error)
 	Delete(ctx context.Context, in *CustomEndpointId, opts ...grpc.CallOption) (*CustomEndpoint, error)
 	Run(ctx context.Context, in *RunCustomEndpointRequest, opts ...grpc.CallOption) (*RunCustomEndpointResponse, error)
+	GetRevisionList(ctx context.Context, in *GetCustomEndpointRevisionListRequest, opts ...grpc.CallOption) (*CustomEndpointRevisionList, error)
+	GetRevision(ctx context.Context, in *CustomEndpointRevisionId, opts ...grpc.CallOption) (*CustomEndpointRevision, error)
+	PublishRevision(ctx context.Context, in *CustomEndpointRevisionId, opts ...grpc.CallOption) (*CustomEndpoint, error)
+	DiffRevisions(ctx context.Context, in *DiffCustomEndpointRevisionsRequest, opts ...grpc.CallOption) (*CustomEndpointRevisionDiff, error)
 }
 
 type customEndpointServiceClient struct {
@@ -92,6 +96,42 @@ func (c *customEndpointServiceClient) Run(ctx context.Context, in *RunCustomEndp
 	return out, nil
 }
 
+func (c *customEndpointServiceClient) GetRevisionList(ctx context.Context, in *GetCustomEndpointRevisionListRequest, opts ...grpc.CallOption) (*CustomEndpointRevisionList, error) {
+	out := new(CustomEndpointRevisionList)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.CustomEndpointService/GetRevisionList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *customEndpointServiceClient) GetRevision(ctx context.Context, in *CustomEndpointRevisionId, opts ...grpc.CallOption) (*CustomEndpointRevision, error) {
+	out := new(CustomEndpointRevision)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.CustomEndpointService/GetRevision", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *customEndpointServiceClient) PublishRevision(ctx context.Context, in *CustomEndpointRevisionId, opts ...grpc.CallOption) (*CustomEndpoint, error) {
+	out := new(CustomEndpoint)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.CustomEndpointService/PublishRevision", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *customEndpointServiceClient) DiffRevisions(ctx context.Context, in *DiffCustomEndpointRevisionsRequest, opts ...grpc.CallOption) (*CustomEndpointRevisionDiff, error) {
+	out := new(CustomEndpointRevisionDiff)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.CustomEndpointService/DiffRevisions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CustomEndpointServiceServer is the server API for CustomEndpointService service.
 // All implementations must embed UnimplementedCustomEndpointServiceServer
 // for forward compatibility
@@ -102,6 +142,10 @@ type CustomEndpointServiceServer interface {
 	GetById(context.Context, *CustomEndpointId) (*CustomEndpoint, error)
 	Delete(context.Context, *CustomEndpointId) (*CustomEndpoint, error)
 	Run(context.Context, *RunCustomEndpointRequest) (*RunCustomEndpointResponse, error)
+	GetRevisionList(context.Context, *GetCustomEndpointRevisionListRequest) (*CustomEndpointRevisionList, error)
+	GetRevision(context.Context, *CustomEndpointRevisionId) (*CustomEndpointRevision, error)
+	PublishRevision(context.Context, *CustomEndpointRevisionId) (*CustomEndpoint, error)
+	DiffRevisions(context.Context, *DiffCustomEndpointRevisionsRequest) (*CustomEndpointRevisionDiff, error)
 	mustEmbedUnimplementedCustomEndpointServiceServer()
 }
 
@@ -127,6 +171,18 @@ func (UnimplementedCustomEndpointServiceServer) Delete(context.Context, *CustomE
 func (UnimplementedCustomEndpointServiceServer) Run(context.Context, *RunCustomEndpointRequest) (*RunCustomEndpointResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Run not implemented")
 }
+func (UnimplementedCustomEndpointServiceServer) GetRevisionList(context.Context, *GetCustomEndpointRevisionListRequest) (*CustomEndpointRevisionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRevisionList not implemented")
+}
+func (UnimplementedCustomEndpointServiceServer) GetRevision(context.Context, *CustomEndpointRevisionId) (*CustomEndpointRevision, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRevision not implemented")
+}
+func (UnimplementedCustomEndpointServiceServer) PublishRevision(context.Context, *CustomEndpointRevisionId) (*CustomEndpoint, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishRevision not implemented")
+}
+func (UnimplementedCustomEndpointServiceServer) DiffRevisions(context.Context, *DiffCustomEndpointRevisionsRequest) (*CustomEndpointRevisionDiff, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiffRevisions not implemented")
+}
 func (UnimplementedCustomEndpointServiceServer) mustEmbedUnimplementedCustomEndpointServiceServer() {}
 
 // UnsafeCustomEndpointServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -248,6 +304,78 @@ func _CustomEndpointService_Run_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CustomEndpointService_GetRevisionList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCustomEndpointRevisionListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CustomEndpointServiceServer).GetRevisionList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.CustomEndpointService/GetRevisionList",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CustomEndpointServiceServer).GetRevisionList(ctx, req.(*GetCustomEndpointRevisionListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CustomEndpointService_GetRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CustomEndpointRevisionId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CustomEndpointServiceServer).GetRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.CustomEndpointService/GetRevision",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CustomEndpointServiceServer).GetRevision(ctx, req.(*CustomEndpointRevisionId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CustomEndpointService_PublishRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CustomEndpointRevisionId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CustomEndpointServiceServer).PublishRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.CustomEndpointService/PublishRevision",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CustomEndpointServiceServer).PublishRevision(ctx, req.(*CustomEndpointRevisionId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CustomEndpointService_DiffRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffCustomEndpointRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CustomEndpointServiceServer).DiffRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.CustomEndpointService/DiffRevisions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CustomEndpointServiceServer).DiffRevisions(ctx, req.(*DiffCustomEndpointRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CustomEndpointService_ServiceDesc is the grpc.ServiceDesc for CustomEndpointService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -279,6 +407,22 @@ var CustomEndpointService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Run",
 			Handler:    _CustomEndpointService_Run_Handler,
 		},
+		{
+			MethodName: "GetRevisionList",
+			Handler:    _CustomEndpointService_GetRevisionList_Handler,
+		},
+		{
+			MethodName: "GetRevision",
+			Handler:    _CustomEndpointService_GetRevision_Handler,
+		},
+		{
+			MethodName: "PublishRevision",
+			Handler:    _CustomEndpointService_PublishRevision_Handler,
+		},
+		{
+			MethodName: "DiffRevisions",
+			Handler:    _CustomEndpointService_DiffRevisions_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pg_custom_endpoint.proto",
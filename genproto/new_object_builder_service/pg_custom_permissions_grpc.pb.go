@@ -30,6 +30,9 @@ type CustomPermissionsServiceClient interface {
 	GetCustomPermissionAccesses(ctx context.Context, in *GetCustomPermissionAccessesRequest, opts ...grpc.CallOption) (*GetCustomPermissionAccessesResponse, error)
 	GetAllCustomPermissionAccesses(ctx context.Context, in *GetAllCustomPermissionAccessesRequest, opts ...grpc.CallOption) (*GetCustomPermissionAccessesResponse, error)
 	UpdateCustomPermissionAccess(ctx context.Context, in *UpdateCustomPermissionAccessRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetCustomPermissionMatrix(ctx context.Context, in *GetCustomPermissionMatrixRequest, opts ...grpc.CallOption) (*CustomPermissionMatrix, error)
+	UpdateCustomPermissionMatrix(ctx context.Context, in *UpdateCustomPermissionMatrixRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	HasCustomPermission(ctx context.Context, in *HasCustomPermissionRequest, opts ...grpc.CallOption) (*HasCustomPermissionResponse, error)
 }
 
 type customPermissionsServiceClient struct {
@@ -103,6 +106,33 @@ func (c *customPermissionsServiceClient) UpdateCustomPermissionAccess(ctx contex
 	return out, nil
 }
 
+func (c *customPermissionsServiceClient) GetCustomPermissionMatrix(ctx context.Context, in *GetCustomPermissionMatrixRequest, opts ...grpc.CallOption) (*CustomPermissionMatrix, error) {
+	out := new(CustomPermissionMatrix)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.CustomPermissionsService/GetCustomPermissionMatrix", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *customPermissionsServiceClient) UpdateCustomPermissionMatrix(ctx context.Context, in *UpdateCustomPermissionMatrixRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.CustomPermissionsService/UpdateCustomPermissionMatrix", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *customPermissionsServiceClient) HasCustomPermission(ctx context.Context, in *HasCustomPermissionRequest, opts ...grpc.CallOption) (*HasCustomPermissionResponse, error) {
+	out := new(HasCustomPermissionResponse)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.CustomPermissionsService/HasCustomPermission", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CustomPermissionsServiceServer is the server API for CustomPermissionsService service.
 // All implementations must embed UnimplementedCustomPermissionsServiceServer
 // for forward compatibility
@@ -114,6 +144,9 @@ type CustomPermissionsServiceServer interface {
 	GetCustomPermissionAccesses(context.Context, *GetCustomPermissionAccessesRequest) (*GetCustomPermissionAccessesResponse, error)
 	GetAllCustomPermissionAccesses(context.Context, *GetAllCustomPermissionAccessesRequest) (*GetCustomPermissionAccessesResponse, error)
 	UpdateCustomPermissionAccess(context.Context, *UpdateCustomPermissionAccessRequest) (*emptypb.Empty, error)
+	GetCustomPermissionMatrix(context.Context, *GetCustomPermissionMatrixRequest) (*CustomPermissionMatrix, error)
+	UpdateCustomPermissionMatrix(context.Context, *UpdateCustomPermissionMatrixRequest) (*emptypb.Empty, error)
+	HasCustomPermission(context.Context, *HasCustomPermissionRequest) (*HasCustomPermissionResponse, error)
 	mustEmbedUnimplementedCustomPermissionsServiceServer()
 }
 
@@ -142,6 +175,15 @@ func (UnimplementedCustomPermissionsServiceServer) GetAllCustomPermissionAccesse
 func (UnimplementedCustomPermissionsServiceServer) UpdateCustomPermissionAccess(context.Context, *UpdateCustomPermissionAccessRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateCustomPermissionAccess not implemented")
 }
+func (UnimplementedCustomPermissionsServiceServer) GetCustomPermissionMatrix(context.Context, *GetCustomPermissionMatrixRequest) (*CustomPermissionMatrix, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCustomPermissionMatrix not implemented")
+}
+func (UnimplementedCustomPermissionsServiceServer) UpdateCustomPermissionMatrix(context.Context, *UpdateCustomPermissionMatrixRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCustomPermissionMatrix not implemented")
+}
+func (UnimplementedCustomPermissionsServiceServer) HasCustomPermission(context.Context, *HasCustomPermissionRequest) (*HasCustomPermissionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HasCustomPermission not implemented")
+}
 func (UnimplementedCustomPermissionsServiceServer) mustEmbedUnimplementedCustomPermissionsServiceServer() {
 }
 
@@ -282,6 +324,60 @@ func _CustomPermissionsService_UpdateCustomPermissionAccess_Handler(srv interfac
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CustomPermissionsService_GetCustomPermissionMatrix_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCustomPermissionMatrixRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CustomPermissionsServiceServer).GetCustomPermissionMatrix(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.CustomPermissionsService/GetCustomPermissionMatrix",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CustomPermissionsServiceServer).GetCustomPermissionMatrix(ctx, req.(*GetCustomPermissionMatrixRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CustomPermissionsService_UpdateCustomPermissionMatrix_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCustomPermissionMatrixRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CustomPermissionsServiceServer).UpdateCustomPermissionMatrix(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.CustomPermissionsService/UpdateCustomPermissionMatrix",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CustomPermissionsServiceServer).UpdateCustomPermissionMatrix(ctx, req.(*UpdateCustomPermissionMatrixRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CustomPermissionsService_HasCustomPermission_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HasCustomPermissionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CustomPermissionsServiceServer).HasCustomPermission(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.CustomPermissionsService/HasCustomPermission",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CustomPermissionsServiceServer).HasCustomPermission(ctx, req.(*HasCustomPermissionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CustomPermissionsService_ServiceDesc is the grpc.ServiceDesc for CustomPermissionsService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -317,6 +413,18 @@ var CustomPermissionsService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateCustomPermissionAccess",
 			Handler:    _CustomPermissionsService_UpdateCustomPermissionAccess_Handler,
 		},
+		{
+			MethodName: "GetCustomPermissionMatrix",
+			Handler:    _CustomPermissionsService_GetCustomPermissionMatrix_Handler,
+		},
+		{
+			MethodName: "UpdateCustomPermissionMatrix",
+			Handler:    _CustomPermissionsService_UpdateCustomPermissionMatrix_Handler,
+		},
+		{
+			MethodName: "HasCustomPermission",
+			Handler:    _CustomPermissionsService_HasCustomPermission_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pg_custom_permissions.proto",
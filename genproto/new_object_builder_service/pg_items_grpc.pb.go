@@ -37,6 +37,11 @@ type ItemsServiceClient interface {
 	DeleteBySearch(ctx context.Context, in *DeleteBySearchReq, opts ...grpc.CallOption) (*CommonMessage, error)
 	UpsertMany(ctx context.Context, in *CommonMessage, opts ...grpc.CallOption) (*CommonMessage, error)
 	UpdateByUserIdAuth(ctx context.Context, in *CommonMessage, opts ...grpc.CallOption) (*CommonMessage, error)
+	RecalculateAll(ctx context.Context, in *RecalculateAllRequest, opts ...grpc.CallOption) (*RecalculateAllResponse, error)
+	GetRecalculationStatus(ctx context.Context, in *GetRecalculationStatusRequest, opts ...grpc.CallOption) (*GetRecalculationStatusResponse, error)
+	GetAncestors(ctx context.Context, in *TreeRequest, opts ...grpc.CallOption) (*CommonMessage, error)
+	GetDescendants(ctx context.Context, in *TreeRequest, opts ...grpc.CallOption) (*CommonMessage, error)
+	MoveSubtree(ctx context.Context, in *MoveSubtreeRequest, opts ...grpc.CallOption) (*CommonMessage, error)
 }
 
 type itemsServiceClient struct {
@@ -182,6 +187,51 @@ func (c *itemsServiceClient) UpdateByUserIdAuth(ctx context.Context, in *CommonM
 	return out, nil
 }
 
+func (c *itemsServiceClient) RecalculateAll(ctx context.Context, in *RecalculateAllRequest, opts ...grpc.CallOption) (*RecalculateAllResponse, error) {
+	out := new(RecalculateAllResponse)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.ItemsService/RecalculateAll", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *itemsServiceClient) GetRecalculationStatus(ctx context.Context, in *GetRecalculationStatusRequest, opts ...grpc.CallOption) (*GetRecalculationStatusResponse, error) {
+	out := new(GetRecalculationStatusResponse)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.ItemsService/GetRecalculationStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *itemsServiceClient) GetAncestors(ctx context.Context, in *TreeRequest, opts ...grpc.CallOption) (*CommonMessage, error) {
+	out := new(CommonMessage)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.ItemsService/GetAncestors", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *itemsServiceClient) GetDescendants(ctx context.Context, in *TreeRequest, opts ...grpc.CallOption) (*CommonMessage, error) {
+	out := new(CommonMessage)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.ItemsService/GetDescendants", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *itemsServiceClient) MoveSubtree(ctx context.Context, in *MoveSubtreeRequest, opts ...grpc.CallOption) (*CommonMessage, error) {
+	out := new(CommonMessage)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.ItemsService/MoveSubtree", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ItemsServiceServer is the server API for ItemsService service.
 // All implementations must embed UnimplementedItemsServiceServer
 // for forward compatibility
@@ -201,6 +251,11 @@ type ItemsServiceServer interface {
 	DeleteBySearch(context.Context, *DeleteBySearchReq) (*CommonMessage, error)
 	UpsertMany(context.Context, *CommonMessage) (*CommonMessage, error)
 	UpdateByUserIdAuth(context.Context, *CommonMessage) (*CommonMessage, error)
+	RecalculateAll(context.Context, *RecalculateAllRequest) (*RecalculateAllResponse, error)
+	GetRecalculationStatus(context.Context, *GetRecalculationStatusRequest) (*GetRecalculationStatusResponse, error)
+	GetAncestors(context.Context, *TreeRequest) (*CommonMessage, error)
+	GetDescendants(context.Context, *TreeRequest) (*CommonMessage, error)
+	MoveSubtree(context.Context, *MoveSubtreeRequest) (*CommonMessage, error)
 	mustEmbedUnimplementedItemsServiceServer()
 }
 
@@ -253,6 +308,21 @@ func (UnimplementedItemsServiceServer) UpsertMany(context.Context, *CommonMessag
 func (UnimplementedItemsServiceServer) UpdateByUserIdAuth(context.Context, *CommonMessage) (*CommonMessage, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateByUserIdAuth not implemented")
 }
+func (UnimplementedItemsServiceServer) RecalculateAll(context.Context, *RecalculateAllRequest) (*RecalculateAllResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecalculateAll not implemented")
+}
+func (UnimplementedItemsServiceServer) GetRecalculationStatus(context.Context, *GetRecalculationStatusRequest) (*GetRecalculationStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecalculationStatus not implemented")
+}
+func (UnimplementedItemsServiceServer) GetAncestors(context.Context, *TreeRequest) (*CommonMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAncestors not implemented")
+}
+func (UnimplementedItemsServiceServer) GetDescendants(context.Context, *TreeRequest) (*CommonMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDescendants not implemented")
+}
+func (UnimplementedItemsServiceServer) MoveSubtree(context.Context, *MoveSubtreeRequest) (*CommonMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MoveSubtree not implemented")
+}
 func (UnimplementedItemsServiceServer) mustEmbedUnimplementedItemsServiceServer() {}
 
 // UnsafeItemsServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -536,6 +606,96 @@ func _ItemsService_UpdateByUserIdAuth_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ItemsService_RecalculateAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecalculateAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ItemsServiceServer).RecalculateAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.ItemsService/RecalculateAll",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ItemsServiceServer).RecalculateAll(ctx, req.(*RecalculateAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ItemsService_GetRecalculationStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecalculationStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ItemsServiceServer).GetRecalculationStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.ItemsService/GetRecalculationStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ItemsServiceServer).GetRecalculationStatus(ctx, req.(*GetRecalculationStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ItemsService_GetAncestors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TreeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ItemsServiceServer).GetAncestors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.ItemsService/GetAncestors",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ItemsServiceServer).GetAncestors(ctx, req.(*TreeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ItemsService_GetDescendants_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TreeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ItemsServiceServer).GetDescendants(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.ItemsService/GetDescendants",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ItemsServiceServer).GetDescendants(ctx, req.(*TreeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ItemsService_MoveSubtree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MoveSubtreeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ItemsServiceServer).MoveSubtree(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.ItemsService/MoveSubtree",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ItemsServiceServer).MoveSubtree(ctx, req.(*MoveSubtreeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ItemsService_ServiceDesc is the grpc.ServiceDesc for ItemsService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -603,6 +763,26 @@ var ItemsService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateByUserIdAuth",
 			Handler:    _ItemsService_UpdateByUserIdAuth_Handler,
 		},
+		{
+			MethodName: "RecalculateAll",
+			Handler:    _ItemsService_RecalculateAll_Handler,
+		},
+		{
+			MethodName: "GetRecalculationStatus",
+			Handler:    _ItemsService_GetRecalculationStatus_Handler,
+		},
+		{
+			MethodName: "GetAncestors",
+			Handler:    _ItemsService_GetAncestors_Handler,
+		},
+		{
+			MethodName: "GetDescendants",
+			Handler:    _ItemsService_GetDescendants_Handler,
+		},
+		{
+			MethodName: "MoveSubtree",
+			Handler:    _ItemsService_MoveSubtree_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pg_items.proto",
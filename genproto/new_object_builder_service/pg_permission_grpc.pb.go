@@ -37,6 +37,12 @@ type PermissionServiceClient interface {
 	GetPermissionsByTableSlug(ctx context.Context, in *GetPermissionsByTableSlugRequest, opts ...grpc.CallOption) (*GetPermissionsByTableSlugResponse, error)
 	GetGlobalPermissionByRoleId(ctx context.Context, in *GetGlobalPermissionsByRoleIdRequest, opts ...grpc.CallOption) (*GlobalPermission, error)
 	GetTablePermission(ctx context.Context, in *GetTablePermissionRequest, opts ...grpc.CallOption) (*GetTablePermissionResponse, error)
+	SetRowLevelSecurity(ctx context.Context, in *SetRowLevelSecurityRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ExplainAccess(ctx context.Context, in *ExplainAccessRequest, opts ...grpc.CallOption) (*ExplainAccessResponse, error)
+	SetRoleParent(ctx context.Context, in *SetRoleParentRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	CreatePermissionTemplate(ctx context.Context, in *CreatePermissionTemplateRequest, opts ...grpc.CallOption) (*PermissionTemplate, error)
+	GetPermissionTemplates(ctx context.Context, in *GetPermissionTemplatesRequest, opts ...grpc.CallOption) (*GetPermissionTemplatesResponse, error)
+	DeletePermissionTemplate(ctx context.Context, in *DeletePermissionTemplateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type permissionServiceClient struct {
@@ -173,6 +179,60 @@ func (c *permissionServiceClient) GetTablePermission(ctx context.Context, in *Ge
 	return out, nil
 }
 
+func (c *permissionServiceClient) SetRowLevelSecurity(ctx context.Context, in *SetRowLevelSecurityRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.PermissionService/SetRowLevelSecurity", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionServiceClient) ExplainAccess(ctx context.Context, in *ExplainAccessRequest, opts ...grpc.CallOption) (*ExplainAccessResponse, error) {
+	out := new(ExplainAccessResponse)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.PermissionService/ExplainAccess", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionServiceClient) SetRoleParent(ctx context.Context, in *SetRoleParentRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.PermissionService/SetRoleParent", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionServiceClient) CreatePermissionTemplate(ctx context.Context, in *CreatePermissionTemplateRequest, opts ...grpc.CallOption) (*PermissionTemplate, error) {
+	out := new(PermissionTemplate)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.PermissionService/CreatePermissionTemplate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionServiceClient) GetPermissionTemplates(ctx context.Context, in *GetPermissionTemplatesRequest, opts ...grpc.CallOption) (*GetPermissionTemplatesResponse, error) {
+	out := new(GetPermissionTemplatesResponse)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.PermissionService/GetPermissionTemplates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *permissionServiceClient) DeletePermissionTemplate(ctx context.Context, in *DeletePermissionTemplateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.PermissionService/DeletePermissionTemplate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // PermissionServiceServer is the server API for PermissionService service.
 // All implementations must embed UnimplementedPermissionServiceServer
 // for forward compatibility
@@ -191,6 +251,12 @@ type PermissionServiceServer interface {
 	GetPermissionsByTableSlug(context.Context, *GetPermissionsByTableSlugRequest) (*GetPermissionsByTableSlugResponse, error)
 	GetGlobalPermissionByRoleId(context.Context, *GetGlobalPermissionsByRoleIdRequest) (*GlobalPermission, error)
 	GetTablePermission(context.Context, *GetTablePermissionRequest) (*GetTablePermissionResponse, error)
+	SetRowLevelSecurity(context.Context, *SetRowLevelSecurityRequest) (*emptypb.Empty, error)
+	ExplainAccess(context.Context, *ExplainAccessRequest) (*ExplainAccessResponse, error)
+	SetRoleParent(context.Context, *SetRoleParentRequest) (*emptypb.Empty, error)
+	CreatePermissionTemplate(context.Context, *CreatePermissionTemplateRequest) (*PermissionTemplate, error)
+	GetPermissionTemplates(context.Context, *GetPermissionTemplatesRequest) (*GetPermissionTemplatesResponse, error)
+	DeletePermissionTemplate(context.Context, *DeletePermissionTemplateRequest) (*emptypb.Empty, error)
 	mustEmbedUnimplementedPermissionServiceServer()
 }
 
@@ -240,6 +306,24 @@ func (UnimplementedPermissionServiceServer) GetGlobalPermissionByRoleId(context.
 func (UnimplementedPermissionServiceServer) GetTablePermission(context.Context, *GetTablePermissionRequest) (*GetTablePermissionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetTablePermission not implemented")
 }
+func (UnimplementedPermissionServiceServer) SetRowLevelSecurity(context.Context, *SetRowLevelSecurityRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRowLevelSecurity not implemented")
+}
+func (UnimplementedPermissionServiceServer) ExplainAccess(context.Context, *ExplainAccessRequest) (*ExplainAccessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExplainAccess not implemented")
+}
+func (UnimplementedPermissionServiceServer) SetRoleParent(context.Context, *SetRoleParentRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRoleParent not implemented")
+}
+func (UnimplementedPermissionServiceServer) CreatePermissionTemplate(context.Context, *CreatePermissionTemplateRequest) (*PermissionTemplate, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreatePermissionTemplate not implemented")
+}
+func (UnimplementedPermissionServiceServer) GetPermissionTemplates(context.Context, *GetPermissionTemplatesRequest) (*GetPermissionTemplatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPermissionTemplates not implemented")
+}
+func (UnimplementedPermissionServiceServer) DeletePermissionTemplate(context.Context, *DeletePermissionTemplateRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePermissionTemplate not implemented")
+}
 func (UnimplementedPermissionServiceServer) mustEmbedUnimplementedPermissionServiceServer() {}
 
 // UnsafePermissionServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -505,6 +589,114 @@ func _PermissionService_GetTablePermission_Handler(srv interface{}, ctx context.
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PermissionService_SetRowLevelSecurity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRowLevelSecurityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionServiceServer).SetRowLevelSecurity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.PermissionService/SetRowLevelSecurity",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionServiceServer).SetRowLevelSecurity(ctx, req.(*SetRowLevelSecurityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PermissionService_ExplainAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainAccessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionServiceServer).ExplainAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.PermissionService/ExplainAccess",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionServiceServer).ExplainAccess(ctx, req.(*ExplainAccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PermissionService_SetRoleParent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRoleParentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionServiceServer).SetRoleParent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.PermissionService/SetRoleParent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionServiceServer).SetRoleParent(ctx, req.(*SetRoleParentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PermissionService_CreatePermissionTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePermissionTemplateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionServiceServer).CreatePermissionTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.PermissionService/CreatePermissionTemplate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionServiceServer).CreatePermissionTemplate(ctx, req.(*CreatePermissionTemplateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PermissionService_GetPermissionTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPermissionTemplatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionServiceServer).GetPermissionTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.PermissionService/GetPermissionTemplates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionServiceServer).GetPermissionTemplates(ctx, req.(*GetPermissionTemplatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PermissionService_DeletePermissionTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePermissionTemplateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PermissionServiceServer).DeletePermissionTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.PermissionService/DeletePermissionTemplate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PermissionServiceServer).DeletePermissionTemplate(ctx, req.(*DeletePermissionTemplateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // PermissionService_ServiceDesc is the grpc.ServiceDesc for PermissionService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -568,6 +760,30 @@ var PermissionService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetTablePermission",
 			Handler:    _PermissionService_GetTablePermission_Handler,
 		},
+		{
+			MethodName: "SetRowLevelSecurity",
+			Handler:    _PermissionService_SetRowLevelSecurity_Handler,
+		},
+		{
+			MethodName: "ExplainAccess",
+			Handler:    _PermissionService_ExplainAccess_Handler,
+		},
+		{
+			MethodName: "SetRoleParent",
+			Handler:    _PermissionService_SetRoleParent_Handler,
+		},
+		{
+			MethodName: "CreatePermissionTemplate",
+			Handler:    _PermissionService_CreatePermissionTemplate_Handler,
+		},
+		{
+			MethodName: "GetPermissionTemplates",
+			Handler:    _PermissionService_GetPermissionTemplates_Handler,
+		},
+		{
+			MethodName: "DeletePermissionTemplate",
+			Handler:    _PermissionService_DeletePermissionTemplate_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pg_permission.proto",
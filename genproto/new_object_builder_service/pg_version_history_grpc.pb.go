@@ -30,6 +30,8 @@ type VersionHistoryServiceClient interface {
 	CreateFunctionLog(ctx context.Context, in *FunctionLogReq, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	GetFunctionLogs(ctx context.Context, in *GetFunctionLogsReq, opts ...grpc.CallOption) (*GetFunctionLogsResp, error)
 	GetPerformanceMetrics(ctx context.Context, in *GetPerformanceMetricsRequest, opts ...grpc.CallOption) (*GetPerformanceMetricsResponse, error)
+	VerifyAuditChain(ctx context.Context, in *VerifyAuditChainRequest, opts ...grpc.CallOption) (*VerifyAuditChainResponse, error)
+	CreateAuditCheckpoint(ctx context.Context, in *CreateAuditCheckpointRequest, opts ...grpc.CallOption) (*AuditCheckpoint, error)
 }
 
 type versionHistoryServiceClient struct {
@@ -103,6 +105,24 @@ func (c *versionHistoryServiceClient) GetPerformanceMetrics(ctx context.Context,
 	return out, nil
 }
 
+func (c *versionHistoryServiceClient) VerifyAuditChain(ctx context.Context, in *VerifyAuditChainRequest, opts ...grpc.CallOption) (*VerifyAuditChainResponse, error) {
+	out := new(VerifyAuditChainResponse)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.VersionHistoryService/VerifyAuditChain", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *versionHistoryServiceClient) CreateAuditCheckpoint(ctx context.Context, in *CreateAuditCheckpointRequest, opts ...grpc.CallOption) (*AuditCheckpoint, error) {
+	out := new(AuditCheckpoint)
+	err := c.cc.Invoke(ctx, "/new_object_builder_service.VersionHistoryService/CreateAuditCheckpoint", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // VersionHistoryServiceServer is the server API for VersionHistoryService service.
 // All implementations must embed UnimplementedVersionHistoryServiceServer
 // for forward compatibility
@@ -114,6 +134,8 @@ type VersionHistoryServiceServer interface {
 	CreateFunctionLog(context.Context, *FunctionLogReq) (*emptypb.Empty, error)
 	GetFunctionLogs(context.Context, *GetFunctionLogsReq) (*GetFunctionLogsResp, error)
 	GetPerformanceMetrics(context.Context, *GetPerformanceMetricsRequest) (*GetPerformanceMetricsResponse, error)
+	VerifyAuditChain(context.Context, *VerifyAuditChainRequest) (*VerifyAuditChainResponse, error)
+	CreateAuditCheckpoint(context.Context, *CreateAuditCheckpointRequest) (*AuditCheckpoint, error)
 	mustEmbedUnimplementedVersionHistoryServiceServer()
 }
 
@@ -142,6 +164,12 @@ func (UnimplementedVersionHistoryServiceServer) GetFunctionLogs(context.Context,
 func (UnimplementedVersionHistoryServiceServer) GetPerformanceMetrics(context.Context, *GetPerformanceMetricsRequest) (*GetPerformanceMetricsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetPerformanceMetrics not implemented")
 }
+func (UnimplementedVersionHistoryServiceServer) VerifyAuditChain(context.Context, *VerifyAuditChainRequest) (*VerifyAuditChainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyAuditChain not implemented")
+}
+func (UnimplementedVersionHistoryServiceServer) CreateAuditCheckpoint(context.Context, *CreateAuditCheckpointRequest) (*AuditCheckpoint, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAuditCheckpoint not implemented")
+}
 func (UnimplementedVersionHistoryServiceServer) mustEmbedUnimplementedVersionHistoryServiceServer() {}
 
 // UnsafeVersionHistoryServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -281,6 +309,42 @@ func _VersionHistoryService_GetPerformanceMetrics_Handler(srv interface{}, ctx c
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VersionHistoryService_VerifyAuditChain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyAuditChainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VersionHistoryServiceServer).VerifyAuditChain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.VersionHistoryService/VerifyAuditChain",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VersionHistoryServiceServer).VerifyAuditChain(ctx, req.(*VerifyAuditChainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VersionHistoryService_CreateAuditCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAuditCheckpointRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VersionHistoryServiceServer).CreateAuditCheckpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/new_object_builder_service.VersionHistoryService/CreateAuditCheckpoint",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VersionHistoryServiceServer).CreateAuditCheckpoint(ctx, req.(*CreateAuditCheckpointRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // VersionHistoryService_ServiceDesc is the grpc.ServiceDesc for VersionHistoryService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -316,6 +380,14 @@ var VersionHistoryService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetPerformanceMetrics",
 			Handler:    _VersionHistoryService_GetPerformanceMetrics_Handler,
 		},
+		{
+			MethodName: "VerifyAuditChain",
+			Handler:    _VersionHistoryService_VerifyAuditChain_Handler,
+		},
+		{
+			MethodName: "CreateAuditCheckpoint",
+			Handler:    _VersionHistoryService_CreateAuditCheckpoint_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pg_version_history.proto",
@@ -6,6 +6,7 @@ import (
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
 	"ucode/ucode_go_object_builder_service/grpc/client"
 	"ucode/ucode_go_object_builder_service/grpc/service"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
 	"ucode/ucode_go_object_builder_service/pkg/logger"
 	"ucode/ucode_go_object_builder_service/storage"
 
@@ -17,7 +18,10 @@ import (
 
 func SetUpServer(cfg config.Config, log logger.LoggerI, svcs client.ServiceManagerI, strg storage.StorageI) (grpcServer *grpc.Server) { // ,
 	grpcServer = grpc.NewServer(
-		grpc.UnaryInterceptor(otgrpc.OpenTracingServerInterceptor(opentracing.GlobalTracer())),
+		grpc.ChainUnaryInterceptor(
+			otgrpc.OpenTracingServerInterceptor(opentracing.GlobalTracer()),
+			authz.UnaryServerInterceptor(),
+		),
 		grpc.StreamInterceptor(otgrpc.OpenTracingStreamServerInterceptor(opentracing.GlobalTracer())),
 		grpc.MaxRecvMsgSize(config.GRPC_MAX_CALL_RECV_MSG_SIZE),
 		grpc.MaxSendMsgSize(config.GRPC_MAX_CALL_SEND_MSG_SIZE),
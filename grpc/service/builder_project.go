@@ -68,6 +68,7 @@ func (b *builderProjectService) Register(ctx context.Context, req *nb.RegisterPr
 	}
 
 	config.MaxConns = b.cfg.PostgresMaxConnections
+	psqlpool.BypassRowSecurity(config)
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
@@ -158,6 +159,7 @@ func (b *builderProjectService) Reconnect(ctx context.Context, req *nb.RegisterP
 	}
 
 	config.MaxConns = b.cfg.PostgresMaxConnections
+	psqlpool.BypassRowSecurity(config)
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
@@ -110,3 +110,59 @@ func (s *customEndpointService) Run(ctx context.Context, req *nb.RunCustomEndpoi
 	}
 	return resp, nil
 }
+
+func (s *customEndpointService) GetRevisionList(ctx context.Context, req *nb.GetCustomEndpointRevisionListRequest) (*nb.CustomEndpointRevisionList, error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_custom_endpoint.GetRevisionList", req)
+	defer dbSpan.Finish()
+
+	s.log.Info("--- CustomEndpoint.GetRevisionList --->", logger.Any("request", compactRequest(req)))
+
+	resp, err := s.strg.CustomEndpoint().GetRevisionList(ctx, req)
+	if err != nil {
+		s.log.Error("--- CustomEndpoint.GetRevisionList --->", logger.Error(err))
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *customEndpointService) GetRevision(ctx context.Context, req *nb.CustomEndpointRevisionId) (*nb.CustomEndpointRevision, error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_custom_endpoint.GetRevision", req)
+	defer dbSpan.Finish()
+
+	s.log.Info("--- CustomEndpoint.GetRevision --->", logger.Any("request", compactRequest(req)))
+
+	resp, err := s.strg.CustomEndpoint().GetRevision(ctx, req)
+	if err != nil {
+		s.log.Error("--- CustomEndpoint.GetRevision --->", logger.Error(err))
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *customEndpointService) PublishRevision(ctx context.Context, req *nb.CustomEndpointRevisionId) (*nb.CustomEndpoint, error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_custom_endpoint.PublishRevision", req)
+	defer dbSpan.Finish()
+
+	s.log.Info("--- CustomEndpoint.PublishRevision --->", logger.Any("request", compactRequest(req)))
+
+	resp, err := s.strg.CustomEndpoint().PublishRevision(ctx, req)
+	if err != nil {
+		s.log.Error("--- CustomEndpoint.PublishRevision --->", logger.Error(err))
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *customEndpointService) DiffRevisions(ctx context.Context, req *nb.DiffCustomEndpointRevisionsRequest) (*nb.CustomEndpointRevisionDiff, error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_custom_endpoint.DiffRevisions", req)
+	defer dbSpan.Finish()
+
+	s.log.Info("--- CustomEndpoint.DiffRevisions --->", logger.Any("request", compactRequest(req)))
+
+	resp, err := s.strg.CustomEndpoint().DiffRevisions(ctx, req)
+	if err != nil {
+		s.log.Error("--- CustomEndpoint.DiffRevisions --->", logger.Error(err))
+		return nil, err
+	}
+	return resp, nil
+}
@@ -83,3 +83,33 @@ func (s *CustomPermissionsService) UpdateCustomPermissionAccess(ctx context.Cont
 
 	return &emptypb.Empty{}, nil
 }
+
+// ==================== Matrix ====================
+
+func (s *CustomPermissionsService) GetCustomPermissionMatrix(ctx context.Context, req *nb.GetCustomPermissionMatrixRequest) (*nb.CustomPermissionMatrix, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CustomPermissionsService.GetCustomPermissionMatrix")
+	defer span.Finish()
+
+	return s.storage.CustomPermissions().GetMatrix(ctx, req)
+}
+
+func (s *CustomPermissionsService) UpdateCustomPermissionMatrix(ctx context.Context, req *nb.UpdateCustomPermissionMatrixRequest) (*emptypb.Empty, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CustomPermissionsService.UpdateCustomPermissionMatrix")
+	defer span.Finish()
+
+	err := s.storage.CustomPermissions().UpdateMatrix(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ==================== Check ====================
+
+func (s *CustomPermissionsService) HasCustomPermission(ctx context.Context, req *nb.HasCustomPermissionRequest) (*nb.HasCustomPermissionResponse, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CustomPermissionsService.HasCustomPermission")
+	defer span.Finish()
+
+	return s.storage.CustomPermissions().HasCustomPermission(ctx, req)
+}
@@ -148,3 +148,78 @@ func (i *itemsService) UpdateByUserIdAuth(ctx context.Context, req *nb.CommonMes
 
 	return resp, nil
 }
+
+func (i *itemsService) RecalculateAll(ctx context.Context, req *nb.RecalculateAllRequest) (resp *nb.RecalculateAllResponse, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_items.RecalculateAll", req)
+	defer dbSpan.Finish()
+
+	i.log.Info("---RecalculateAll--->>>", logger.Any("request", req))
+
+	resp, err = i.strg.Items().RecalculateAll(ctx, req)
+	if err != nil {
+		i.log.Error("---RecalculateAll--->>>", logger.Error(err))
+		return &nb.RecalculateAllResponse{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return resp, nil
+}
+
+func (i *itemsService) GetRecalculationStatus(ctx context.Context, req *nb.GetRecalculationStatusRequest) (resp *nb.GetRecalculationStatusResponse, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_items.GetRecalculationStatus", req)
+	defer dbSpan.Finish()
+
+	i.log.Info("---GetRecalculationStatus--->>>", logger.Any("request", req))
+
+	resp, err = i.strg.Items().GetRecalculationStatus(ctx, req)
+	if err != nil {
+		i.log.Error("---GetRecalculationStatus--->>>", logger.Error(err))
+		return &nb.GetRecalculationStatusResponse{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return resp, nil
+}
+
+func (i *itemsService) GetAncestors(ctx context.Context, req *nb.TreeRequest) (resp *nb.CommonMessage, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_items.GetAncestors", req)
+	defer dbSpan.Finish()
+
+	i.log.Info("---GetAncestors--->>>", logger.Any("request", req))
+
+	resp, err = i.strg.Items().GetAncestors(ctx, req)
+	if err != nil {
+		i.log.Error("---GetAncestors--->>>", logger.Error(err))
+		return &nb.CommonMessage{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return resp, nil
+}
+
+func (i *itemsService) GetDescendants(ctx context.Context, req *nb.TreeRequest) (resp *nb.CommonMessage, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_items.GetDescendants", req)
+	defer dbSpan.Finish()
+
+	i.log.Info("---GetDescendants--->>>", logger.Any("request", req))
+
+	resp, err = i.strg.Items().GetDescendants(ctx, req)
+	if err != nil {
+		i.log.Error("---GetDescendants--->>>", logger.Error(err))
+		return &nb.CommonMessage{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return resp, nil
+}
+
+func (i *itemsService) MoveSubtree(ctx context.Context, req *nb.MoveSubtreeRequest) (resp *nb.CommonMessage, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_items.MoveSubtree", req)
+	defer dbSpan.Finish()
+
+	i.log.Info("---MoveSubtree--->>>", logger.Any("request", req))
+
+	resp, err = i.strg.Items().MoveSubtree(ctx, req)
+	if err != nil {
+		i.log.Error("---MoveSubtree--->>>", logger.Error(err))
+		return &nb.CommonMessage{}, status.Error(codes.Internal, err.Error())
+	}
+
+	return resp, nil
+}
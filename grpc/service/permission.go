@@ -144,3 +144,93 @@ func (p *permissionService) GetTablePermission(ctx context.Context, req *nb.GetT
 
 	return resp, nil
 }
+
+func (p *permissionService) SetRowLevelSecurity(ctx context.Context, req *nb.SetRowLevelSecurityRequest) (resp *emptypb.Empty, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_permission.SetRowLevelSecurity", req)
+	defer dbSpan.Finish()
+
+	p.log.Info("---SetRowLevelSecurity--->", logger.Any("request", compactRequest(req)))
+
+	err = p.strg.Permission().SetRowLevelSecurity(ctx, req)
+	if err != nil {
+		p.log.Error("---SetRowLevelSecurity--->", logger.Error(err))
+		return resp, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (p *permissionService) ExplainAccess(ctx context.Context, req *nb.ExplainAccessRequest) (resp *nb.ExplainAccessResponse, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_permission.ExplainAccess", req)
+	defer dbSpan.Finish()
+
+	p.log.Info("---ExplainAccess--->", logger.Any("request", compactRequest(req)))
+
+	resp, err = p.strg.Permission().ExplainAccess(ctx, req)
+	if err != nil {
+		p.log.Error("---ExplainAccess--->", logger.Error(err))
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+func (p *permissionService) SetRoleParent(ctx context.Context, req *nb.SetRoleParentRequest) (resp *emptypb.Empty, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_permission.SetRoleParent", req)
+	defer dbSpan.Finish()
+
+	p.log.Info("---SetRoleParent--->", logger.Any("request", compactRequest(req)))
+
+	err = p.strg.Permission().SetRoleParent(ctx, req)
+	if err != nil {
+		p.log.Error("---SetRoleParent--->", logger.Error(err))
+		return resp, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (p *permissionService) CreatePermissionTemplate(ctx context.Context, req *nb.CreatePermissionTemplateRequest) (resp *nb.PermissionTemplate, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_permission.CreatePermissionTemplate", req)
+	defer dbSpan.Finish()
+
+	p.log.Info("---CreatePermissionTemplate--->", logger.Any("request", compactRequest(req)))
+
+	resp, err = p.strg.Permission().CreatePermissionTemplate(ctx, req)
+	if err != nil {
+		p.log.Error("---CreatePermissionTemplate--->", logger.Error(err))
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+func (p *permissionService) GetPermissionTemplates(ctx context.Context, req *nb.GetPermissionTemplatesRequest) (resp *nb.GetPermissionTemplatesResponse, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_permission.GetPermissionTemplates", req)
+	defer dbSpan.Finish()
+
+	p.log.Info("---GetPermissionTemplates--->", logger.Any("request", compactRequest(req)))
+
+	resp, err = p.strg.Permission().GetPermissionTemplates(ctx, req)
+	if err != nil {
+		p.log.Error("---GetPermissionTemplates--->", logger.Error(err))
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+func (p *permissionService) DeletePermissionTemplate(ctx context.Context, req *nb.DeletePermissionTemplateRequest) (resp *emptypb.Empty, err error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_permission.DeletePermissionTemplate", req)
+	defer dbSpan.Finish()
+
+	p.log.Info("---DeletePermissionTemplate--->", logger.Any("request", compactRequest(req)))
+
+	err = p.strg.Permission().DeletePermissionTemplate(ctx, req)
+	if err != nil {
+		p.log.Error("---DeletePermissionTemplate--->", logger.Error(err))
+		return resp, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
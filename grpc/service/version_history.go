@@ -125,3 +125,29 @@ func (v *versionHistoryService) GetPerformanceMetrics(ctx context.Context, req *
 
 	return resp, nil
 }
+
+func (v *versionHistoryService) VerifyAuditChain(ctx context.Context, req *nb.VerifyAuditChainRequest) (*nb.VerifyAuditChainResponse, error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_version_history.VerifyAuditChain", req)
+	defer dbSpan.Finish()
+
+	resp, err := v.strg.VersionHistory().VerifyAuditChain(ctx, req)
+	if err != nil {
+		v.log.Error("---VerifyAuditChain--->>>", logger.Error(err))
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (v *versionHistoryService) CreateAuditCheckpoint(ctx context.Context, req *nb.CreateAuditCheckpointRequest) (*nb.AuditCheckpoint, error) {
+	dbSpan, ctx := span.StartSpanFromContext(ctx, "grpc_version_history.CreateAuditCheckpoint", req)
+	defer dbSpan.Finish()
+
+	resp, err := v.strg.VersionHistory().CreateAuditCheckpoint(ctx, req)
+	if err != nil {
+		v.log.Error("---CreateAuditCheckpoint--->>>", logger.Error(err))
+		return nil, err
+	}
+
+	return resp, nil
+}
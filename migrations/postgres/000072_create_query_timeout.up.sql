@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS query_timeout (
+    rpc_type   VARCHAR(50) PRIMARY KEY,
+    timeout_ms INTEGER     NOT NULL CHECK (timeout_ms > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS sql_audit_log;
+
+ALTER TABLE custom_endpoint DROP COLUMN IF EXISTS policy;
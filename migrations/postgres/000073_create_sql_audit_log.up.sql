@@ -0,0 +1,20 @@
+ALTER TABLE custom_endpoint ADD COLUMN IF NOT EXISTS policy JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS sql_audit_log (
+    id            UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    source        VARCHAR(50) NOT NULL,
+    endpoint_id   UUID,
+    sql_query     TEXT        NOT NULL,
+    operations    TEXT[]      NOT NULL DEFAULT '{}',
+    tables        TEXT[]      NOT NULL DEFAULT '{}',
+    read_only     BOOLEAN     NOT NULL DEFAULT false,
+    allowed       BOOLEAN     NOT NULL,
+    reason        TEXT,
+    error         TEXT,
+    rows_affected BIGINT      NOT NULL DEFAULT 0,
+    duration_ms   BIGINT      NOT NULL DEFAULT 0,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sql_audit_log_created_at ON sql_audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_sql_audit_log_endpoint_id ON sql_audit_log(endpoint_id);
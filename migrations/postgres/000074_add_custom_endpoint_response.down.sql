@@ -0,0 +1 @@
+ALTER TABLE custom_endpoint DROP COLUMN IF EXISTS response;
@@ -0,0 +1 @@
+ALTER TABLE custom_endpoint ADD COLUMN IF NOT EXISTS response JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS custom_endpoint_revision;
+
+ALTER TABLE custom_endpoint DROP COLUMN IF EXISTS revision;
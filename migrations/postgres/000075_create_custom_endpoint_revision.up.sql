@@ -0,0 +1,30 @@
+ALTER TABLE custom_endpoint ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS custom_endpoint_revision (
+    id             UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    endpoint_id    UUID        NOT NULL REFERENCES custom_endpoint(id) ON DELETE CASCADE,
+    revision       INT         NOT NULL,
+    status         VARCHAR(20) NOT NULL DEFAULT 'draft',
+    is_current     BOOLEAN     NOT NULL DEFAULT false,
+    sql_query      TEXT        NOT NULL,
+    method         TEXT        NOT NULL DEFAULT 'POST',
+    in_transaction BOOLEAN     NOT NULL DEFAULT false,
+    parameters     JSONB       NOT NULL DEFAULT '[]',
+    policy         JSONB       NOT NULL DEFAULT '{}',
+    response       JSONB       NOT NULL DEFAULT '{}',
+    commit_message TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    published_at   TIMESTAMPTZ,
+    UNIQUE (endpoint_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS idx_custom_endpoint_revision_endpoint_id ON custom_endpoint_revision(endpoint_id);
+
+INSERT INTO custom_endpoint_revision (
+    endpoint_id, revision, status, is_current, sql_query, method, in_transaction,
+    parameters, policy, response, commit_message, created_at, published_at
+)
+SELECT id, 1, 'published', true, sql_query, method, in_transaction,
+       parameters, policy, response, 'Initial revision', updated_at, updated_at
+FROM custom_endpoint
+ON CONFLICT (endpoint_id, revision) DO NOTHING;
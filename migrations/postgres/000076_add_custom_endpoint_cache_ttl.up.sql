@@ -0,0 +1 @@
+ALTER TABLE custom_endpoint ADD COLUMN IF NOT EXISTS cache_ttl_seconds INT NOT NULL DEFAULT 0;
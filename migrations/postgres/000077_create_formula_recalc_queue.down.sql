@@ -0,0 +1 @@
+DROP TABLE IF EXISTS formula_recalc_queue;
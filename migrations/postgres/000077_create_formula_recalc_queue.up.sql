@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS formula_recalc_queue (
+    table_slug VARCHAR(255) NOT NULL,
+    record_id UUID NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (table_slug, record_id)
+);
+
+CREATE INDEX IF NOT EXISTS formula_recalc_queue_created_at_idx ON formula_recalc_queue (created_at);
@@ -0,0 +1 @@
+ALTER TABLE "relation" DROP COLUMN IF EXISTS "attributes";
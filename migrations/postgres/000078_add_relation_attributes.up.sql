@@ -0,0 +1 @@
+ALTER TABLE "relation" ADD COLUMN IF NOT EXISTS "attributes" JSONB DEFAULT '{}';
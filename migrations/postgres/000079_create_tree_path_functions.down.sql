@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS move_tree_subpaths() CASCADE;
+DROP FUNCTION IF EXISTS set_tree_path() CASCADE;
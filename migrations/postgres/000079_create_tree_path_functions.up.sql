@@ -0,0 +1,35 @@
+-- Maintain "tree_path" ('/<root guid>/.../<guid>/') of tables whose recursive
+-- relation has a materialized path. TG_ARGV[0] is the parent column.
+CREATE OR REPLACE FUNCTION set_tree_path()
+RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+DECLARE
+  parent_id TEXT := to_jsonb(NEW) ->> TG_ARGV[0];
+  parent_path TEXT;
+BEGIN
+  IF parent_id IS NOT NULL THEN
+    EXECUTE format('SELECT tree_path FROM %I.%I WHERE guid = $1::UUID', TG_TABLE_SCHEMA, TG_TABLE_NAME)
+      INTO parent_path
+      USING parent_id;
+
+    IF parent_path LIKE '%/' || NEW.guid::TEXT || '/%' THEN
+      RAISE EXCEPTION 'cannot move % under its descendant %', NEW.guid, parent_id;
+    END IF;
+  END IF;
+
+  NEW.tree_path := COALESCE(parent_path, '/') || NEW.guid::TEXT || '/';
+  RETURN NEW;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION move_tree_subpaths()
+RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+  EXECUTE format('UPDATE %I.%I SET tree_path = $1 || substr(tree_path, length($2) + 1) WHERE tree_path LIKE $2 || ''%%'' AND guid <> $3', TG_TABLE_SCHEMA, TG_TABLE_NAME)
+    USING NEW.tree_path, OLD.tree_path, NEW.guid;
+  RETURN NULL;
+END
+$$;
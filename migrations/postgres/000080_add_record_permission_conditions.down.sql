@@ -0,0 +1 @@
+ALTER TABLE "record_permission" DROP COLUMN IF EXISTS "conditions";
@@ -0,0 +1 @@
+ALTER TABLE "record_permission" ADD COLUMN IF NOT EXISTS "conditions" JSONB DEFAULT '{}';
@@ -0,0 +1,13 @@
+DO $$
+DECLARE
+    p RECORD;
+BEGIN
+    FOR p IN SELECT policyname, tablename FROM pg_policies
+        WHERE schemaname = current_schema() AND policyname LIKE 'ucode\_rls\_%'
+    LOOP
+        EXECUTE format('DROP POLICY IF EXISTS %I ON %I', p.policyname, p.tablename);
+        EXECUTE format('ALTER TABLE %I NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY', p.tablename);
+    END LOOP;
+END $$;
+
+ALTER TABLE "table" DROP COLUMN IF EXISTS "row_level_security";
@@ -0,0 +1 @@
+ALTER TABLE "table" ADD COLUMN IF NOT EXISTS "row_level_security" BOOLEAN DEFAULT false;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "role_parent_id_idx";
+
+ALTER TABLE "role" DROP COLUMN IF EXISTS "is_template";
+ALTER TABLE "role" DROP COLUMN IF EXISTS "parent_id";
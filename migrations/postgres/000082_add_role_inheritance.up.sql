@@ -0,0 +1,4 @@
+ALTER TABLE "role" ADD COLUMN IF NOT EXISTS "parent_id" UUID REFERENCES "role"("guid") ON DELETE RESTRICT;
+ALTER TABLE "role" ADD COLUMN IF NOT EXISTS "is_template" BOOLEAN DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS "role_parent_id_idx" ON "role"("parent_id");
@@ -0,0 +1,12 @@
+UPDATE "custom_permission_access" SET
+    "read" = COALESCE("read", 'No'),
+    "write" = COALESCE("write", 'No'),
+    "update" = COALESCE("update", 'No'),
+    "delete" = COALESCE("delete", 'No')
+WHERE "read" IS NULL OR "write" IS NULL OR "update" IS NULL OR "delete" IS NULL;
+
+ALTER TABLE IF EXISTS "custom_permission_access"
+    ALTER COLUMN "read" SET NOT NULL,
+    ALTER COLUMN "write" SET NOT NULL,
+    ALTER COLUMN "update" SET NOT NULL,
+    ALTER COLUMN "delete" SET NOT NULL;
@@ -0,0 +1,17 @@
+ALTER TABLE IF EXISTS "custom_permission_access"
+    ALTER COLUMN "read" DROP NOT NULL,
+    ALTER COLUMN "write" DROP NOT NULL,
+    ALTER COLUMN "update" DROP NOT NULL,
+    ALTER COLUMN "delete" DROP NOT NULL;
+
+-- Access rows of child permissions that were never edited hold the 'No'
+-- they were created with; they inherit from the parent instead.
+UPDATE "custom_permission_access" cpa SET
+    "read" = NULLIF(cpa."read", 'No'),
+    "write" = NULLIF(cpa."write", 'No'),
+    "update" = NULLIF(cpa."update", 'No'),
+    "delete" = NULLIF(cpa."delete", 'No')
+FROM "custom_permission" cp
+WHERE cp."id" = cpa."custom_permission_id"
+    AND cp."parent_id" IS NOT NULL
+    AND cpa."updated_at" IS NOT DISTINCT FROM cpa."created_at";
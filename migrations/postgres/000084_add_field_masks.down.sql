@@ -0,0 +1,3 @@
+ALTER TABLE "agent_permissions" DROP COLUMN IF EXISTS "masked_fields";
+
+ALTER TABLE "field_permission" DROP COLUMN IF EXISTS "mask";
@@ -0,0 +1,4 @@
+ALTER TABLE "field_permission" ADD COLUMN IF NOT EXISTS "mask" VARCHAR(16)
+    CHECK ("mask" IN ('full', 'last4', 'hash', 'email_domain'));
+
+ALTER TABLE "agent_permissions" ADD COLUMN IF NOT EXISTS "masked_fields" JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "encrypted_field_index";
+
+DROP TABLE IF EXISTS "data_key";
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS "data_key" (
+    "version" SERIAL PRIMARY KEY,
+    "purpose" VARCHAR(8) NOT NULL DEFAULT 'data' CHECK ("purpose" IN ('data', 'index')),
+    "wrapped_key" BYTEA NOT NULL,
+    "master_key_id" VARCHAR(16) NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "data_key_index_purpose_unq" ON "data_key" ("purpose") WHERE "purpose" = 'index';
+
+CREATE TABLE IF NOT EXISTS "encrypted_field_index" (
+    "table_slug" VARCHAR(255) NOT NULL,
+    "field_slug" VARCHAR(255) NOT NULL,
+    "row_guid" UUID NOT NULL,
+    "hash" VARCHAR(64) NOT NULL,
+    PRIMARY KEY ("table_slug", "field_slug", "row_guid")
+);
+
+CREATE INDEX IF NOT EXISTS "encrypted_field_index_hash_idx" ON "encrypted_field_index" ("table_slug", "field_slug", "hash");
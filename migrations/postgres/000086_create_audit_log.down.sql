@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "audit_checkpoint";
+
+DROP TABLE IF EXISTS "audit_log";
+
+DROP FUNCTION IF EXISTS audit_append_only();
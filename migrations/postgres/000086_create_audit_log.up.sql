@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    "seq" BIGINT PRIMARY KEY,
+    "version_history_id" UUID NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL,
+    "action_source" VARCHAR(255) NOT NULL,
+    "action_type" VARCHAR(255) NOT NULL,
+    "table_slug" VARCHAR(255) NOT NULL,
+    "user_info" VARCHAR(255) NOT NULL,
+    "content_hash" VARCHAR(64) NOT NULL,
+    "prev_hash" VARCHAR(64) NOT NULL,
+    "hash" VARCHAR(64) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "audit_log_version_history_id_idx" ON "audit_log" ("version_history_id");
+
+CREATE TABLE IF NOT EXISTS "audit_checkpoint" (
+    "seq" BIGINT PRIMARY KEY,
+    "from_seq" BIGINT NOT NULL,
+    "hash" VARCHAR(64) NOT NULL,
+    "key_id" VARCHAR(16) NOT NULL,
+    "signature" TEXT NOT NULL,
+    "object_key" TEXT NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION audit_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "audit_log_append_only" ON "audit_log";
+CREATE TRIGGER "audit_log_append_only"
+    BEFORE UPDATE OR DELETE ON "audit_log"
+    FOR EACH ROW EXECUTE FUNCTION audit_append_only();
+
+DROP TRIGGER IF EXISTS "audit_log_no_truncate" ON "audit_log";
+CREATE TRIGGER "audit_log_no_truncate"
+    BEFORE TRUNCATE ON "audit_log"
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_append_only();
+
+DROP TRIGGER IF EXISTS "audit_checkpoint_append_only" ON "audit_checkpoint";
+CREATE TRIGGER "audit_checkpoint_append_only"
+    BEFORE UPDATE OR DELETE ON "audit_checkpoint"
+    FOR EACH ROW EXECUTE FUNCTION audit_append_only();
+
+DROP TRIGGER IF EXISTS "audit_checkpoint_no_truncate" ON "audit_checkpoint";
+CREATE TRIGGER "audit_checkpoint_no_truncate"
+    BEFORE TRUNCATE ON "audit_checkpoint"
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_append_only();
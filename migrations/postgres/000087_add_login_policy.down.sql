@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "password_history";
+
+DROP TABLE IF EXISTS "login_attempt";
+
+DELETE FROM field WHERE "id" = '9b1f4a52-2c3e-4d8a-9f61-7e0c5d2b8a14';
+
+ALTER TABLE "client_type" DROP COLUMN IF EXISTS "login_policy";
@@ -0,0 +1,42 @@
+ALTER TABLE "client_type" ADD COLUMN IF NOT EXISTS "login_policy" VARCHAR DEFAULT '';
+
+INSERT INTO field(
+    "id",
+    "table_id",
+    "slug",
+    "label",
+    "default",
+    "type",
+    "is_visible",
+    "is_system",
+    "is_search") VALUES
+(
+'9b1f4a52-2c3e-4d8a-9f61-7e0c5d2b8a14',
+'ed3bf0d9-40a3-4b79-beb4-52506aa0b5ea',
+'login_policy',
+'Login Policy',
+'',
+'JSON',
+false,
+true,
+false
+) ON CONFLICT ("id") DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS "login_attempt" (
+    "client_type_id" UUID NOT NULL,
+    "kind" VARCHAR(4) NOT NULL CHECK ("kind" IN ('user', 'ip')),
+    "subject" VARCHAR(255) NOT NULL,
+    "failures" INTEGER NOT NULL DEFAULT 0,
+    "last_failure_at" TIMESTAMPTZ,
+    "locked_until" TIMESTAMPTZ,
+    PRIMARY KEY ("client_type_id", "kind", "subject")
+);
+
+CREATE TABLE IF NOT EXISTS "password_history" (
+    "table_slug" VARCHAR(255) NOT NULL,
+    "row_guid" UUID NOT NULL,
+    "hash" VARCHAR(255) NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "password_history_row_idx" ON "password_history" ("table_slug", "row_guid", "created_at" DESC);
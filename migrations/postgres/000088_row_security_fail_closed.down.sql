@@ -0,0 +1,22 @@
+DO $$
+DECLARE
+    p RECORD;
+    expression TEXT;
+BEGIN
+    FOR p IN
+        SELECT schemaname, tablename, policyname, cmd, COALESCE(qual, with_check) AS expression
+        FROM pg_policies
+        WHERE policyname LIKE 'ucode\_rls\_%'
+    LOOP
+        expression := format(
+            '(NULLIF(current_setting(%L, true), '''') IS NULL OR (%s))',
+            'ucode.role_id', p.expression
+        );
+
+        IF p.cmd = 'INSERT' THEN
+            EXECUTE format('ALTER POLICY %I ON %I.%I WITH CHECK %s', p.policyname, p.schemaname, p.tablename, expression);
+        ELSE
+            EXECUTE format('ALTER POLICY %I ON %I.%I USING %s', p.policyname, p.schemaname, p.tablename, expression);
+        END IF;
+    END LOOP;
+END $$;
@@ -0,0 +1,25 @@
+-- Row security policies used to let every connection without ucode.role_id
+-- through. Only the connections of the service, which set ucode.bypass, may
+-- skip them now; the others need a role.
+DO $$
+DECLARE
+    p RECORD;
+    expression TEXT;
+BEGIN
+    FOR p IN
+        SELECT schemaname, tablename, policyname, cmd, COALESCE(qual, with_check) AS expression
+        FROM pg_policies
+        WHERE policyname LIKE 'ucode\_rls\_%'
+    LOOP
+        expression := format(
+            '(COALESCE(current_setting(%L, true), '''') = ''on'' OR (NULLIF(current_setting(%L, true), '''') IS NOT NULL AND (%s)))',
+            'ucode.bypass', 'ucode.role_id', p.expression
+        );
+
+        IF p.cmd = 'INSERT' THEN
+            EXECUTE format('ALTER POLICY %I ON %I.%I WITH CHECK %s', p.policyname, p.schemaname, p.tablename, expression);
+        ELSE
+            EXECUTE format('ALTER POLICY %I ON %I.%I USING %s', p.policyname, p.schemaname, p.tablename, expression);
+        END IF;
+    END LOOP;
+END $$;
@@ -0,0 +1 @@
+ALTER TABLE "field_permission" DROP COLUMN IF EXISTS "decrypt_permission";
@@ -0,0 +1 @@
+ALTER TABLE "field_permission" ADD COLUMN IF NOT EXISTS "decrypt_permission" BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,15 @@
+DO $$
+DECLARE
+    f RECORD;
+BEGIN
+    FOR f IN
+        SELECT t.slug AS table_slug, fl.slug AS field_slug
+        FROM "field" fl
+        JOIN "table" t ON t.id = fl.table_id
+        JOIN information_schema.columns c
+            ON c.table_schema = current_schema() AND c.table_name = t.slug AND c.column_name = fl.slug
+        WHERE fl.type = 'FORMULA' AND c.data_type = 'numeric'
+    LOOP
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE FLOAT USING %I::FLOAT', f.table_slug, f.field_slug, f.field_slug);
+    END LOOP;
+END $$;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_agents_role_id;
+
+ALTER TABLE agents DROP COLUMN IF EXISTS role_id;
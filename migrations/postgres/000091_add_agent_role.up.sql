@@ -0,0 +1,3 @@
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS role_id UUID;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agents_role_id ON agents (role_id) WHERE role_id IS NOT NULL;
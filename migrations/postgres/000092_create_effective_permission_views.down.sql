@@ -0,0 +1,11 @@
+DROP VIEW IF EXISTS effective_automatic_filter;
+DROP VIEW IF EXISTS effective_custom_permission_access;
+DROP VIEW IF EXISTS effective_global_permission;
+DROP VIEW IF EXISTS effective_action_permission;
+DROP VIEW IF EXISTS effective_menu_permission;
+DROP VIEW IF EXISTS effective_view_relation_permission;
+DROP VIEW IF EXISTS effective_view_permission;
+DROP VIEW IF EXISTS effective_field_permission;
+DROP VIEW IF EXISTS effective_record_permission;
+
+DROP FUNCTION IF EXISTS role_chain(UUID);
@@ -0,0 +1,66 @@
+-- A role has the permission rows of its own and, for what it has no row
+-- for, the rows of its nearest parent that has one. role_chain returns a
+-- role and its parents by depth, and the effective_<table> views resolve
+-- the rows a role reads once, for every query: effective_role_id is the
+-- role reading them, role_id the role a row comes from.
+--
+-- The views select p.*, so they are recreated whenever a permission table
+-- gains a column.
+CREATE OR REPLACE FUNCTION role_chain(role_guid UUID)
+RETURNS TABLE (role_id UUID, depth INT)
+LANGUAGE sql STABLE AS $$
+    WITH RECURSIVE chain AS (
+        SELECT r.guid, r.parent_id, 0 AS depth FROM "role" r WHERE r.guid = role_guid
+        UNION ALL
+        SELECT r.guid, r.parent_id, c.depth + 1
+        FROM "role" r
+        JOIN chain c ON r.guid = c.parent_id
+        WHERE c.depth < 16
+    )
+    SELECT chain.guid, chain.depth FROM chain
+$$;
+
+DO $$
+DECLARE
+    t RECORD;
+BEGIN
+    FOR t IN
+        SELECT * FROM (VALUES
+            ('record_permission', ARRAY['table_slug']),
+            ('field_permission', ARRAY['field_id']),
+            ('view_permission', ARRAY['view_id']),
+            ('view_relation_permission', ARRAY['table_slug', 'relation_id']),
+            ('menu_permission', ARRAY['menu_id']),
+            ('action_permission', ARRAY['table_slug', 'custom_event_id']),
+            ('global_permission', ARRAY[]::TEXT[]),
+            ('custom_permission_access', ARRAY['custom_permission_id', 'client_type_id'])
+        ) AS v (name, keys)
+    LOOP
+        EXECUTE format(
+            'CREATE OR REPLACE VIEW %I AS
+            SELECT DISTINCT ON (%s) r.guid AS effective_role_id, p.*
+            FROM "role" r
+            CROSS JOIN LATERAL role_chain(r.guid) c
+            JOIN %I p ON p.role_id = c.role_id
+            ORDER BY %s, c.depth',
+            'effective_' || t.name,
+            array_to_string(ARRAY['r.guid'] || (SELECT array_agg('p.' || quote_ident(k)) FROM unnest(t.keys) k), ', '),
+            t.name,
+            array_to_string(ARRAY['r.guid'] || (SELECT array_agg('p.' || quote_ident(k)) FROM unnest(t.keys) k), ', ')
+        );
+    END LOOP;
+END $$;
+
+-- Automatic filters are inherited together: a role has all the filters of
+-- a table and method of the nearest role that has any.
+CREATE OR REPLACE VIEW effective_automatic_filter AS
+SELECT o.effective_role_id, f.*
+FROM (
+    SELECT DISTINCT ON (r.guid, a.table_slug, a.method) r.guid AS effective_role_id, a.table_slug, a.method, a.role_id
+    FROM "role" r
+    CROSS JOIN LATERAL role_chain(r.guid) c
+    JOIN automatic_filter a ON a.role_id = c.role_id AND a.deleted_at IS NULL
+    ORDER BY r.guid, a.table_slug, a.method, c.depth
+) o
+JOIN automatic_filter f
+    ON f.role_id = o.role_id AND f.table_slug = o.table_slug AND f.method IS NOT DISTINCT FROM o.method AND f.deleted_at IS NULL;
@@ -11,6 +11,8 @@ type CreateBody struct {
 	TableId    string
 	Fields     []Field
 	TableSlugs []string
+	// ValidatePassword replaces the default strong password check.
+	ValidatePassword func(password string) error
 }
 
 type FormulaFilter struct {
@@ -18,6 +20,7 @@ type FormulaFilter struct {
 }
 
 type FilterItem struct {
-	Key   string `json:"key"`
-	Value any    `json:"value"`
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    any    `json:"value"`
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	pa "ucode/ucode_go_object_builder_service/genproto/auth_service"
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
 
@@ -22,6 +24,7 @@ type ClientType struct {
 	TableSlug         string   `json:"table_slug"`
 	DefaultPage       string   `json:"default_page"`
 	SessionLimit      int32    `json:"session_limit"`
+	LoginPolicy       string   `json:"login_policy"`
 }
 
 type Connection struct {
@@ -161,6 +164,9 @@ type GetItemsBody struct {
 	Params       map[string]any
 	FieldsMap    map[string]Field
 	SearchFields []string
+	// RowFilter is an extra condition on the rows, prefixed with AND, on
+	// columns qualified with the quoted table slug.
+	RowFilter string
 }
 
 type RelationBody struct {
@@ -232,3 +238,17 @@ type GetAutomaticFilterRequest struct {
 	UserIdFromToken string
 	TableSlug       string
 }
+
+type SQLAuditEntry struct {
+	Source       string
+	EndpointId   string
+	Query        string
+	Operations   []string
+	Tables       []string
+	ReadOnly     bool
+	Allowed      bool
+	Reason       string
+	Error        string
+	RowsAffected int64
+	Duration     time.Duration
+}
@@ -94,6 +94,7 @@ type RelationHelper struct {
 	RelationType string
 	FieldFrom    string
 	FieldTo      string
+	OnDelete     string
 	Attributes   *structpb.Struct
 }
 
@@ -0,0 +1,194 @@
+// Package audit hash-chains the audit log of a project, so that edited,
+// removed or reordered entries are detected, and signs checkpoints of the
+// chain that can be kept outside the database.
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of problems a verification reports.
+const (
+	// ProblemGap is a missing entry.
+	ProblemGap = "gap"
+	// ProblemChain is an entry that does not link to the one before it.
+	ProblemChain = "chain"
+	// ProblemHash is an entry whose content no longer matches its hash.
+	ProblemHash = "hash"
+	// ProblemContent is a version history row edited after it was logged.
+	ProblemContent = "content"
+	// ProblemMissing is a version history row removed before a checkpoint
+	// covered it.
+	ProblemMissing = "missing"
+	// ProblemCheckpoint is a checkpoint that does not match the chain.
+	ProblemCheckpoint = "checkpoint"
+)
+
+// Entry is an entry of the audit log. Its hash covers its fields and the
+// hash of the entry before it.
+type Entry struct {
+	Seq              int64     `json:"seq"`
+	VersionHistoryId string    `json:"version_history_id"`
+	CreatedAt        time.Time `json:"created_at"`
+	ActionSource     string    `json:"action_source"`
+	ActionType       string    `json:"action_type"`
+	TableSlug        string    `json:"table_slug"`
+	UserInfo         string    `json:"user_info"`
+	ContentHash      string    `json:"content_hash"`
+	PrevHash         string    `json:"prev_hash"`
+	Hash             string    `json:"hash"`
+}
+
+// ComputeHash returns the hash the entry should have. Times are hashed at
+// the microsecond precision Postgres keeps.
+func (e Entry) ComputeHash() string {
+	fields := []string{
+		strconv.FormatInt(e.Seq, 10),
+		e.VersionHistoryId,
+		e.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		e.ActionSource,
+		e.ActionType,
+		e.TableSlug,
+		e.UserInfo,
+		e.ContentHash,
+		e.PrevHash,
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Next returns e as the entry following last, nil for the first entry of
+// a project, with its sequence number, link and hash set.
+func Next(last *Entry, e Entry) Entry {
+	e.Seq, e.PrevHash = 1, ""
+	if last != nil {
+		e.Seq, e.PrevHash = last.Seq+1, last.Hash
+	}
+	e.CreatedAt = e.CreatedAt.UTC().Truncate(time.Microsecond)
+	e.Hash = e.ComputeHash()
+	return e
+}
+
+// Problem is something wrong with the chain at an entry.
+type Problem struct {
+	Seq    int64
+	Kind   string
+	Detail string
+}
+
+// Chain verifies entries added in sequence order.
+type Chain struct {
+	last     *Entry
+	Checked  int64
+	Problems []Problem
+}
+
+// NewChain starts a verification after prev, the entry before the range
+// verified, nil when the range starts at the first entry.
+func NewChain(prev *Entry) *Chain {
+	return &Chain{last: prev}
+}
+
+// Add verifies the next entry.
+func (c *Chain) Add(e Entry) {
+	c.Checked++
+
+	if c.last != nil && e.Seq != c.last.Seq+1 {
+		c.Report(e.Seq, ProblemGap, fmt.Sprintf("entries %d to %d are missing", c.last.Seq+1, e.Seq-1))
+	}
+	if c.last == nil && e.Seq > 1 {
+		c.Report(e.Seq, ProblemGap, fmt.Sprintf("entries before %d are missing", e.Seq))
+	}
+
+	if c.last != nil && e.PrevHash != c.last.Hash {
+		c.Report(e.Seq, ProblemChain, "previous hash does not match the entry before it")
+	}
+	if c.last == nil && e.Seq == 1 && e.PrevHash != "" {
+		c.Report(e.Seq, ProblemChain, "first entry links to a previous one")
+	}
+
+	if e.ComputeHash() != e.Hash {
+		c.Report(e.Seq, ProblemHash, "entry does not match its hash")
+	}
+
+	c.last = &e
+}
+
+// Last returns the last entry added.
+func (c *Chain) Last() *Entry {
+	return c.last
+}
+
+// Report adds a problem found outside the chain itself.
+func (c *Chain) Report(seq int64, kind, detail string) {
+	c.Problems = append(c.Problems, Problem{Seq: seq, Kind: kind, Detail: detail})
+}
+
+// Checkpoint is a signed statement of the head of the chain of a project.
+// It carries the entries since the previous checkpoint, so the chain can
+// be verified from exported checkpoints once the database rotated them.
+type Checkpoint struct {
+	ProjectId string    `json:"project_id"`
+	FromSeq   int64     `json:"from_seq"`
+	Seq       int64     `json:"seq"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	Entries   []Entry   `json:"entries"`
+	KeyId     string    `json:"key_id"`
+	Signature string    `json:"signature"`
+}
+
+func (c *Checkpoint) signedBytes() []byte {
+	return []byte(strings.Join([]string{
+		c.ProjectId,
+		strconv.FormatInt(c.FromSeq, 10),
+		strconv.FormatInt(c.Seq, 10),
+		c.Hash,
+		c.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		c.KeyId,
+	}, "\x1f"))
+}
+
+// Sign signs the checkpoint with key.
+func (c *Checkpoint) Sign(key ed25519.PrivateKey) {
+	c.KeyId = KeyId(key.Public().(ed25519.PublicKey))
+	c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, c.signedBytes()))
+}
+
+// VerifySignature reports whether the checkpoint was signed by the key of
+// public.
+func (c *Checkpoint) VerifySignature(public ed25519.PublicKey) bool {
+	signature, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil {
+		return false
+	}
+	return c.KeyId == KeyId(public) && ed25519.Verify(public, c.signedBytes(), signature)
+}
+
+// KeyId names a signing key by its public key.
+func KeyId(public ed25519.PublicKey) string {
+	sum := sha256.Sum256(public)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParseSigningKey decodes the base64 seed of an Ed25519 signing key.
+func ParseSigningKey(s string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.Wrap(err, "error while decoding signing key")
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key is %d bytes, want %d", len(seed), ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -0,0 +1,119 @@
+package audit_test
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"ucode/ucode_go_object_builder_service/pkg/audit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEntries(n int) []audit.Entry {
+	var (
+		entries []audit.Entry
+		last    *audit.Entry
+	)
+	for i := 0; i < n; i++ {
+		e := audit.Next(last, audit.Entry{
+			VersionHistoryId: "vh",
+			CreatedAt:        time.Date(2026, 1, 1, 0, 0, i, 123456789, time.UTC),
+			ActionSource:     "ITEM",
+			ActionType:       "UPDATE",
+			TableSlug:        "account",
+			UserInfo:         "auditor",
+			ContentHash:      "content",
+		})
+		entries = append(entries, e)
+		last = &entries[len(entries)-1]
+	}
+	return entries
+}
+
+func verify(prev *audit.Entry, entries []audit.Entry) []audit.Problem {
+	chain := audit.NewChain(prev)
+	for _, e := range entries {
+		chain.Add(e)
+	}
+	return chain.Problems
+}
+
+func kinds(problems []audit.Problem) []string {
+	var kinds []string
+	for _, p := range problems {
+		kinds = append(kinds, p.Kind)
+	}
+	return kinds
+}
+
+func TestChain(t *testing.T) {
+	entries := testEntries(5)
+
+	assert.Equal(t, int64(1), entries[0].Seq)
+	assert.Equal(t, "", entries[0].PrevHash)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	assert.Empty(t, verify(nil, entries))
+	assert.Empty(t, verify(&entries[1], entries[2:]))
+
+	t.Run("edited", func(t *testing.T) {
+		edited := append([]audit.Entry(nil), entries...)
+		edited[2].UserInfo = "someone else"
+		assert.Equal(t, []string{audit.ProblemHash}, kinds(verify(nil, edited)))
+	})
+
+	t.Run("rehashed", func(t *testing.T) {
+		edited := append([]audit.Entry(nil), entries...)
+		edited[2].UserInfo = "someone else"
+		edited[2].Hash = edited[2].ComputeHash()
+		assert.Equal(t, []string{audit.ProblemChain}, kinds(verify(nil, edited)))
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		removed := append(append([]audit.Entry(nil), entries[:2]...), entries[3:]...)
+		assert.Equal(t, []string{audit.ProblemGap, audit.ProblemChain}, kinds(verify(nil, removed)))
+	})
+
+	t.Run("head removed", func(t *testing.T) {
+		assert.Equal(t, []string{audit.ProblemGap}, kinds(verify(nil, entries[1:])))
+	})
+}
+
+func TestEntryHashPrecision(t *testing.T) {
+	e := testEntries(1)[0]
+	e.CreatedAt = e.CreatedAt.Truncate(time.Microsecond).In(time.FixedZone("UTC+5", 5*3600))
+	assert.Equal(t, e.Hash, e.ComputeHash())
+}
+
+func TestCheckpointSignature(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = 1
+	key, err := audit.ParseSigningKey(base64.StdEncoding.EncodeToString(seed))
+	require.NoError(t, err)
+
+	entries := testEntries(3)
+	checkpoint := audit.Checkpoint{
+		ProjectId: "project",
+		FromSeq:   1,
+		Seq:       3,
+		Hash:      entries[2].Hash,
+		CreatedAt: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		Entries:   entries,
+	}
+	checkpoint.Sign(key)
+
+	public := key.Public().(ed25519.PublicKey)
+	assert.True(t, checkpoint.VerifySignature(public))
+
+	forged := checkpoint
+	forged.Seq = 2
+	assert.False(t, forged.VerifySignature(public))
+
+	other := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	assert.False(t, checkpoint.VerifySignature(other.Public().(ed25519.PublicKey)))
+
+	_, err = audit.ParseSigningKey(base64.StdEncoding.EncodeToString(seed[:16]))
+	assert.Error(t, err)
+}
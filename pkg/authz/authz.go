@@ -0,0 +1,167 @@
+// Package authz decides whether the caller of a request may write the items
+// of a table. A gRPC interceptor puts the caller, the subject, on the
+// context of every request; the storage layer loads the permissions of its
+// role from record_permission, field_permission and action_permission and
+// checks them before it writes. The Rules of a role restrict which rows it
+// may read and write.
+//
+// A request must name a role unless its client type is ADMIN. Work the
+// service does on its own, without a request, like its cron jobs, is not
+// restricted.
+package authz
+
+import (
+	"context"
+	"sort"
+
+	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Action string
+
+const (
+	Read   Action = "read"
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// Subject is the user a request runs for, as the gateway passes it in the
+// data of the request.
+type Subject struct {
+	RoleId       string
+	ClientTypeId string
+	UserId       string
+	// AgentId is set when an AI agent reads for the user.
+	AgentId string
+}
+
+// Restricted reports whether the permissions of the subject apply.
+func (s Subject) Restricted() bool {
+	return s.RoleId != ""
+}
+
+// SubjectFromData reads the subject from the role_id_from_token,
+// client_type_id_from_token, user_id_from_token and agent_id_from_token
+// fields of the data of a request.
+func SubjectFromData(data map[string]any) Subject {
+	return Subject{
+		RoleId:       cast.ToString(data["role_id_from_token"]),
+		ClientTypeId: cast.ToString(data["client_type_id_from_token"]),
+		UserId:       cast.ToString(data["user_id_from_token"]),
+		AgentId:      cast.ToString(data["agent_id_from_token"]),
+	}
+}
+
+type subjectKey struct{}
+
+// WithSubject returns a copy of ctx that carries s.
+func WithSubject(ctx context.Context, s Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, s)
+}
+
+// SubjectFromContext returns the subject on ctx. ok is false for work the
+// service does on its own, outside of a request.
+func SubjectFromContext(ctx context.Context) (Subject, bool) {
+	s, ok := ctx.Value(subjectKey{}).(Subject)
+	return s, ok
+}
+
+// FromRequest reports whether ctx belongs to a request, whose subject the
+// permissions apply to even when it names no role.
+func FromRequest(ctx context.Context) bool {
+	_, ok := SubjectFromContext(ctx)
+	return ok
+}
+
+// Resolve returns the subject a write runs for: the one on ctx, which the
+// writes nested in a request inherit, or else the one in data.
+func Resolve(ctx context.Context, data map[string]any) Subject {
+	s, ok := SubjectFromContext(ctx)
+	if ok && (s.Restricted() || s.AgentId != "") {
+		return s
+	}
+
+	d := SubjectFromData(data)
+	if d.ClientTypeId == "" {
+		d.ClientTypeId = s.ClientTypeId
+	}
+	return d
+}
+
+// ErrNoRole is returned for requests that name no role and whose client
+// type is not ADMIN.
+var ErrNoRole = status.Error(codes.PermissionDenied, "the request names no role")
+
+// Permissions are what the role of a subject may do with the items of a
+// table.
+type Permissions struct {
+	TableSlug string
+	// Admin is set for the ADMIN client type, which is not restricted.
+	Admin bool
+	// Record maps the actions to the "Yes" or "No" of record_permission.
+	// An action without a value is allowed, as the columns default to Yes.
+	Record map[Action]string
+	// ReadOnly are the fields the role has no edit permission on, or sees
+	// masked, so a masked value read back is not written over the real one.
+	ReadOnly map[string]bool
+	// Events maps the custom events of the table to whether the role may
+	// run them.
+	Events map[string]bool
+}
+
+// Check fails with PermissionDenied when the role may not do action.
+func (p *Permissions) Check(action Action) error {
+	if p == nil || p.Admin || p.Record[action] != "No" {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "no %s permission on %s", action, p.TableSlug)
+}
+
+// CheckEvent fails with PermissionDenied when the role may not run the
+// custom event. Events without an action permission are allowed.
+func (p *Permissions) CheckEvent(eventId string) error {
+	if p == nil || p.Admin || eventId == "" {
+		return nil
+	}
+	if allowed, ok := p.Events[eventId]; ok && !allowed {
+		return status.Errorf(codes.PermissionDenied, "no permission to run action %s on %s", eventId, p.TableSlug)
+	}
+	return nil
+}
+
+// Strip removes the fields the role may not edit from data and returns
+// their slugs, sorted.
+func (p *Permissions) Strip(data map[string]any) []string {
+	if p == nil || p.Admin {
+		return nil
+	}
+
+	var stripped []string
+	for slug := range p.ReadOnly {
+		if _, ok := data[slug]; ok {
+			delete(data, slug)
+			stripped = append(stripped, slug)
+		}
+	}
+	sort.Strings(stripped)
+
+	return stripped
+}
+
+// Editable returns the fields of slugs the role may edit.
+func (p *Permissions) Editable(slugs []string) []string {
+	if p == nil || p.Admin || len(p.ReadOnly) == 0 {
+		return slugs
+	}
+
+	editable := make([]string, 0, len(slugs))
+	for _, slug := range slugs {
+		if !p.ReadOnly[slug] {
+			editable = append(editable, slug)
+		}
+	}
+	return editable
+}
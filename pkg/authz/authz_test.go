@@ -0,0 +1,173 @@
+package authz_test
+
+import (
+	"context"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions *authz.Permissions
+		action      authz.Action
+		denied      bool
+	}{
+		{"unrestricted", nil, authz.Delete, false},
+		{"no record permission", &authz.Permissions{}, authz.Create, false},
+		{"allowed", &authz.Permissions{Record: map[authz.Action]string{authz.Update: "Yes"}}, authz.Update, false},
+		{"denied create", &authz.Permissions{Record: map[authz.Action]string{authz.Create: "No"}}, authz.Create, true},
+		{"denied delete", &authz.Permissions{Record: map[authz.Action]string{authz.Delete: "No"}}, authz.Delete, true},
+		{"other action denied", &authz.Permissions{Record: map[authz.Action]string{authz.Delete: "No"}}, authz.Update, false},
+		{"admin", &authz.Permissions{Admin: true, Record: map[authz.Action]string{authz.Delete: "No"}}, authz.Delete, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.permissions.Check(tt.action)
+			if tt.denied {
+				assert.Equal(t, codes.PermissionDenied, status.Code(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckEvent(t *testing.T) {
+	permissions := &authz.Permissions{Events: map[string]bool{"approve": true, "archive": false}}
+
+	tests := []struct {
+		event  string
+		denied bool
+	}{
+		{"", false},
+		{"approve", false},
+		{"archive", true},
+		{"unknown", false},
+	}
+
+	for _, tt := range tests {
+		err := permissions.CheckEvent(tt.event)
+		if tt.denied {
+			assert.Equal(t, codes.PermissionDenied, status.Code(err), tt.event)
+		} else {
+			assert.NoError(t, err, tt.event)
+		}
+	}
+}
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions *authz.Permissions
+		data        map[string]any
+		want        map[string]any
+		stripped    []string
+	}{
+		{
+			name:        "unrestricted",
+			permissions: nil,
+			data:        map[string]any{"salary": 10},
+			want:        map[string]any{"salary": 10},
+		},
+		{
+			name:        "read only fields",
+			permissions: &authz.Permissions{ReadOnly: map[string]bool{"salary": true, "status": true, "bonus": true}},
+			data:        map[string]any{"guid": "1", "salary": 10, "status": "done", "name": "x"},
+			want:        map[string]any{"guid": "1", "name": "x"},
+			stripped:    []string{"salary", "status"},
+		},
+		{
+			name:        "admin",
+			permissions: &authz.Permissions{Admin: true, ReadOnly: map[string]bool{"salary": true}},
+			data:        map[string]any{"salary": 10},
+			want:        map[string]any{"salary": 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.stripped, tt.permissions.Strip(tt.data))
+			assert.Equal(t, tt.want, tt.data)
+		})
+	}
+
+	permissions := &authz.Permissions{ReadOnly: map[string]bool{"salary": true}}
+	assert.Equal(t, []string{"name", "status"}, permissions.Editable([]string{"name", "salary", "status"}))
+}
+
+func TestResolve(t *testing.T) {
+	data := map[string]any{"role_id_from_token": "role-data", "client_type_id_from_token": "client-data"}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		data map[string]any
+		want authz.Subject
+	}{
+		{"data", context.Background(), data, authz.Subject{RoleId: "role-data", ClientTypeId: "client-data"}},
+		{"context first", authz.WithSubject(context.Background(), authz.Subject{RoleId: "role-ctx"}), data, authz.Subject{RoleId: "role-ctx"}},
+		{"nested write", authz.WithSubject(context.Background(), authz.Subject{RoleId: "role-ctx"}), map[string]any{}, authz.Subject{RoleId: "role-ctx"}},
+		{"service call", context.Background(), map[string]any{"from_auth_service": true}, authz.Subject{}},
+		{"admin without role", authz.WithSubject(context.Background(), authz.Subject{ClientTypeId: "client-ctx"}), map[string]any{}, authz.Subject{ClientTypeId: "client-ctx"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, authz.Resolve(tt.ctx, tt.data))
+		})
+	}
+}
+
+type request struct {
+	data *structpb.Struct
+}
+
+func (r request) GetData() *structpb.Struct {
+	return r.data
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	data, _ := structpb.NewStruct(map[string]any{"role_id_from_token": "role-data"})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  any
+		want authz.Subject
+		ok   bool
+	}{
+		{"from data", context.Background(), request{data: data}, authz.Subject{RoleId: "role-data"}, true},
+		{
+			name: "from metadata",
+			ctx:  metadata.NewIncomingContext(context.Background(), metadata.Pairs("role_id", "role-md", "client_type_id", "client-md")),
+			req:  request{},
+			want: authz.Subject{RoleId: "role-md", ClientTypeId: "client-md"},
+			ok:   true,
+		},
+		{"anonymous", context.Background(), request{}, authz.Subject{}, true},
+	}
+
+	interceptor := authz.UnaryServerInterceptor()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := interceptor(tt.ctx, tt.req, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+				subject, ok := authz.SubjectFromContext(ctx)
+				assert.Equal(t, tt.ok, ok)
+				assert.Equal(t, tt.ok, authz.FromRequest(ctx))
+				assert.Equal(t, tt.want, subject)
+				return nil, nil
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package authz
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type dataRequest interface {
+	GetData() *structpb.Struct
+}
+
+// UnaryServerInterceptor puts the subject of every request on its context:
+// the one in the data of the request or, for requests without one, the
+// role_id, client_type_id, user_id and agent_id metadata. The subject is
+// put there even when empty, so the storage layer denies the writes of
+// requests without a role instead of taking them for the service's own.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var s Subject
+		if r, ok := req.(dataRequest); ok && r.GetData() != nil {
+			s = SubjectFromData(r.GetData().AsMap())
+		}
+
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if s.RoleId == "" {
+				s.RoleId = first(md.Get("role_id"))
+			}
+			if s.ClientTypeId == "" {
+				s.ClientTypeId = first(md.Get("client_type_id"))
+			}
+			if s.UserId == "" {
+				s.UserId = first(md.Get("user_id"))
+			}
+			if s.AgentId == "" {
+				s.AgentId = first(md.Get("agent_id"))
+			}
+		}
+
+		return handler(WithSubject(ctx, s), req)
+	}
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,92 @@
+package authz
+
+import (
+	"strings"
+
+	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Mask is how a field a role may view is shown to it, set per role and
+// field in field_permission.mask and per agent and table in
+// agent_permissions.masked_fields.
+type Mask string
+
+const (
+	NoMask Mask = ""
+	// MaskFull replaces the value.
+	MaskFull Mask = "full"
+	// MaskLast4 keeps the last four characters, as for phones and cards.
+	MaskLast4 Mask = "last4"
+	// MaskHash replaces the value with its keyed hash, so equal values can
+	// still be told apart.
+	MaskHash Mask = "hash"
+	// MaskEmailDomain keeps the domain of an email.
+	MaskEmailDomain Mask = "email_domain"
+)
+
+const maskedValue = "****"
+
+// maskStrictness orders the masks by how much of the value they hide.
+var maskStrictness = map[Mask]int{
+	NoMask:          0,
+	MaskLast4:       1,
+	MaskEmailDomain: 1,
+	MaskHash:        2,
+	MaskFull:        3,
+}
+
+// Hasher hashes the values of MaskHash with a key of the tenant.
+type Hasher interface {
+	MaskHash(value string) string
+}
+
+// ParseMask validates a mask, failing with InvalidArgument on an unknown
+// one.
+func ParseMask(s string) (Mask, error) {
+	m := Mask(s)
+	if _, ok := maskStrictness[m]; !ok {
+		return NoMask, status.Errorf(codes.InvalidArgument, "unknown mask %q, want full, last4, hash or email_domain", s)
+	}
+	return m, nil
+}
+
+// Stricter returns the one of a and b that hides more.
+func Stricter(a, b Mask) Mask {
+	if maskStrictness[b] > maskStrictness[a] {
+		return b
+	}
+	return a
+}
+
+// Apply returns value as m shows it. Empty values are kept, so a masked
+// field still tells whether it is set; lists and objects are replaced.
+// Without a hasher MaskHash replaces the value as MaskFull does.
+func (m Mask) Apply(value any, h Hasher) any {
+	if m == NoMask || value == nil || value == "" {
+		return value
+	}
+
+	s, err := cast.ToStringE(value)
+	if err != nil {
+		return maskedValue
+	}
+
+	switch m {
+	case MaskLast4:
+		if r := []rune(s); len(r) > 4 {
+			return strings.Repeat("*", len(r)-4) + string(r[len(r)-4:])
+		}
+	case MaskHash:
+		if h != nil {
+			return h.MaskHash(s)
+		}
+	case MaskEmailDomain:
+		if i := strings.LastIndex(s, "@"); i >= 0 && i < len(s)-1 {
+			return maskedValue + s[i:]
+		}
+	}
+
+	return maskedValue
+}
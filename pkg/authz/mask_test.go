@@ -0,0 +1,65 @@
+package authz_test
+
+import (
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseMask(t *testing.T) {
+	for _, s := range []string{"", "full", "last4", "hash", "email_domain"} {
+		m, err := authz.ParseMask(s)
+		assert.NoError(t, err)
+		assert.Equal(t, authz.Mask(s), m)
+	}
+
+	_, err := authz.ParseMask("partial")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestMaskApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		mask  authz.Mask
+		value any
+		want  any
+	}{
+		{name: "no mask", mask: authz.NoMask, value: "+998901234567", want: "+998901234567"},
+		{name: "nil kept", mask: authz.MaskFull, value: nil, want: nil},
+		{name: "empty kept", mask: authz.MaskLast4, value: "", want: ""},
+		{name: "full", mask: authz.MaskFull, value: "secret", want: "****"},
+		{name: "full number", mask: authz.MaskFull, value: 42, want: "****"},
+		{name: "last4 phone", mask: authz.MaskLast4, value: "+998901234567", want: "*********4567"},
+		{name: "last4 number", mask: authz.MaskLast4, value: 4111111111111111, want: "************1111"},
+		{name: "last4 short", mask: authz.MaskLast4, value: "1234", want: "****"},
+		{name: "hash", mask: authz.MaskHash, value: "abc", want: "key:abc"},
+		{name: "email domain", mask: authz.MaskEmailDomain, value: "john.doe@example.com", want: "****@example.com"},
+		{name: "email domain without email", mask: authz.MaskEmailDomain, value: "john.doe", want: "****"},
+		{name: "list", mask: authz.MaskLast4, value: []any{"a", "b"}, want: "****"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.mask.Apply(tt.value, prefixHasher("key:")))
+		})
+	}
+
+	assert.Equal(t, "****", authz.MaskHash.Apply("abc", nil))
+}
+
+type prefixHasher string
+
+func (h prefixHasher) MaskHash(value string) string {
+	return string(h) + value
+}
+
+func TestStricter(t *testing.T) {
+	assert.Equal(t, authz.MaskFull, authz.Stricter(authz.MaskLast4, authz.MaskFull))
+	assert.Equal(t, authz.MaskHash, authz.Stricter(authz.MaskHash, authz.MaskEmailDomain))
+	assert.Equal(t, authz.MaskLast4, authz.Stricter(authz.NoMask, authz.MaskLast4))
+	assert.Equal(t, authz.MaskLast4, authz.Stricter(authz.MaskLast4, authz.MaskEmailDomain))
+}
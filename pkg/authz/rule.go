@@ -0,0 +1,327 @@
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Rule is a condition on the rows of a table. It is stored as JSON, either
+// a comparison of a field or a combination of rules:
+//
+//	{"field": "branch_id", "op": "eq", "value": "$user.branch_id"}
+//	{"field": "status", "op": "neq", "value": "closed"}
+//	{"all": [...]}, {"any": [...]}, {"not": {...}}
+//
+// The operators are eq, neq, gt, gte, lt, lte, in, nin, null and not_null.
+// A value that starts with $user. is a variable: $user.id, $user.role_id,
+// $user.client_type_id or a field of the row of the user in its login
+// table. A comparison with a variable the user has no value for matches
+// no row.
+type Rule struct {
+	All   []*Rule `json:"all,omitempty"`
+	Any   []*Rule `json:"any,omitempty"`
+	Not   *Rule   `json:"not,omitempty"`
+	Field string  `json:"field,omitempty"`
+	Op    string  `json:"op,omitempty"`
+	Value any     `json:"value,omitempty"`
+}
+
+// Expr is the value of a variable as an SQL expression the database
+// evaluates, for rules compiled into row security policies rather than
+// into the queries of a request.
+type Expr string
+
+// Conditions are the rules of a role on a table, by action. Rows an action
+// has no rule for are not restricted.
+type Conditions map[Action]*Rule
+
+const variablePrefix = "$user."
+
+var comparisons = map[string]string{
+	"eq":  "=",
+	"neq": "IS DISTINCT FROM",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// ParseConditions reads and validates conditions stored as JSON. Empty
+// input has no conditions.
+func ParseConditions(raw []byte) (Conditions, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var conditions Conditions
+	if err := json.Unmarshal(raw, &conditions); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid conditions: %v", err)
+	}
+
+	for action, rule := range conditions {
+		switch action {
+		case Read, Create, Update, Delete:
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "conditions: unknown action %q", action)
+		}
+		if rule == nil {
+			delete(conditions, action)
+			continue
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s condition: %v", action, err)
+		}
+	}
+
+	return conditions, nil
+}
+
+// Validate checks the structure of the rule and its operators.
+func (r *Rule) Validate() error {
+	var kinds int
+	for _, set := range []bool{len(r.All) > 0, len(r.Any) > 0, r.Not != nil, r.Field != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("a rule needs exactly one of all, any, not and field")
+	}
+
+	for _, rule := range append(append([]*Rule{}, r.All...), r.Any...) {
+		if rule == nil {
+			return fmt.Errorf("empty rule")
+		}
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.Not != nil {
+		return r.Not.Validate()
+	}
+	if r.Field == "" {
+		return nil
+	}
+
+	switch r.Op {
+	case "null", "not_null":
+	case "in", "nin":
+		if _, ok := r.Value.([]any); !ok {
+			return fmt.Errorf("%s: %s needs a list", r.Field, r.Op)
+		}
+	case "eq", "neq":
+	default:
+		if _, ok := comparisons[r.Op]; !ok {
+			return fmt.Errorf("%s: unknown operator %q", r.Field, r.Op)
+		}
+		if r.Value == nil {
+			return fmt.Errorf("%s: %s needs a value", r.Field, r.Op)
+		}
+	}
+
+	return nil
+}
+
+// Fields returns the fields the rule compares, sorted.
+func (r *Rule) Fields() []string {
+	set := make(map[string]bool)
+	r.walk(func(leaf *Rule) {
+		set[leaf.Field] = true
+	})
+	return sortedKeys(set)
+}
+
+// Variables returns the names of the $user variables the rule uses, sorted
+// and without the prefix.
+func (r *Rule) Variables() []string {
+	set := make(map[string]bool)
+	r.walk(func(leaf *Rule) {
+		values, ok := leaf.Value.([]any)
+		if !ok {
+			values = []any{leaf.Value}
+		}
+		for _, value := range values {
+			if name, ok := variable(value); ok {
+				set[name] = true
+			}
+		}
+	})
+	return sortedKeys(set)
+}
+
+func (r *Rule) walk(fn func(leaf *Rule)) {
+	if r == nil {
+		return
+	}
+	if r.Field != "" {
+		fn(r)
+	}
+	for _, rule := range r.All {
+		rule.walk(fn)
+	}
+	for _, rule := range r.Any {
+		rule.walk(fn)
+	}
+	r.Not.walk(fn)
+}
+
+func variable(value any) (string, bool) {
+	s, ok := value.(string)
+	if !ok || !strings.HasPrefix(s, variablePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(s, variablePrefix), true
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SQL compiles the rule into a condition on the row aliased alias, with the
+// values of the variables inlined as literals. The result is never NULL,
+// so it can be negated.
+func (r *Rule) SQL(alias string, vars map[string]any) (string, error) {
+	switch {
+	case len(r.All) > 0:
+		return joinRules(r.All, " AND ", alias, vars)
+	case len(r.Any) > 0:
+		return joinRules(r.Any, " OR ", alias, vars)
+	case r.Not != nil:
+		x, err := r.Not.SQL(alias, vars)
+		if err != nil {
+			return "", err
+		}
+		return "(NOT " + x + ")", nil
+	}
+
+	column := alias + "." + pq.QuoteIdentifier(r.Field)
+
+	switch r.Op {
+	case "null":
+		return "(" + column + " IS NULL)", nil
+	case "not_null":
+		return "(" + column + " IS NOT NULL)", nil
+	case "in", "nin":
+		var literals []string
+		for _, value := range r.Value.([]any) {
+			if name, ok := variable(value); ok && vars[name] == nil {
+				return "FALSE", nil
+			}
+			for _, v := range resolveList(value, vars) {
+				if expr, ok := v.(Expr); ok {
+					literals = append(literals, "("+string(expr)+")::TEXT")
+					continue
+				}
+				literals = append(literals, pq.QuoteLiteral(cast.ToString(v)))
+			}
+		}
+		if len(literals) == 0 {
+			return strings.ToUpper(strconv.FormatBool(r.Op == "nin")), nil
+		}
+		if r.Op == "nin" {
+			return fmt.Sprintf("(%s IS NULL OR %s::TEXT NOT IN (%s))", column, column, strings.Join(literals, ", ")), nil
+		}
+		return fmt.Sprintf("COALESCE(%s::TEXT IN (%s), FALSE)", column, strings.Join(literals, ", ")), nil
+	}
+
+	literal, ok, err := sqlValue(r.Value, vars)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "FALSE", nil
+	}
+	if r.Value == nil {
+		if r.Op == "neq" {
+			return "(" + column + " IS NOT NULL)", nil
+		}
+		return "(" + column + " IS NULL)", nil
+	}
+	if name, ok := variable(r.Value); ok && (r.Op == "eq" || r.Op == "neq") {
+		// Expressions have the type of their source, compare them as text
+		if _, ok := vars[name].(Expr); ok {
+			column, literal = column+"::TEXT", literal+"::TEXT"
+		}
+	}
+
+	return fmt.Sprintf("COALESCE(%s %s %s, FALSE)", column, comparisons[r.Op], literal), nil
+}
+
+func joinRules(rules []*Rule, separator, alias string, vars map[string]any) (string, error) {
+	parts := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		x, err := rule.SQL(alias, vars)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, x)
+	}
+	return "(" + strings.Join(parts, separator) + ")", nil
+}
+
+// resolveList resolves an element of an in list. A variable can hold a
+// list of values.
+func resolveList(value any, vars map[string]any) []any {
+	if name, ok := variable(value); ok {
+		value = vars[name]
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	case []string:
+		values := make([]any, len(v))
+		for i := range v {
+			values[i] = v[i]
+		}
+		return values
+	}
+	return []any{value}
+}
+
+// sqlValue renders a value of a rule as a literal. ok is false for a
+// variable without a value.
+func sqlValue(value any, vars map[string]any) (literal string, ok bool, err error) {
+	if name, isVariable := variable(value); isVariable {
+		value, ok = vars[name]
+		if !ok || value == nil {
+			return "", false, nil
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "NULL", true, nil
+	case Expr:
+		return "(" + string(v) + ")", true, nil
+	case string:
+		return pq.QuoteLiteral(v), true, nil
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v)), true, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true, nil
+	case int:
+		return strconv.Itoa(v), true, nil
+	case int64:
+		return strconv.FormatInt(v, 10), true, nil
+	case fmt.Stringer:
+		return pq.QuoteLiteral(v.String()), true, nil
+	}
+
+	return "", false, fmt.Errorf("unsupported value %v", value)
+}
@@ -0,0 +1,178 @@
+package authz_test
+
+import (
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseConditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		actions []authz.Action
+		invalid bool
+	}{
+		{name: "empty", raw: ""},
+		{name: "no rules", raw: `{}`},
+		{
+			name:    "read and delete",
+			raw:     `{"read": {"field": "branch_id", "op": "eq", "value": "$user.branch_id"}, "delete": {"field": "created_by", "op": "eq", "value": "$user.id"}}`,
+			actions: []authz.Action{authz.Delete, authz.Read},
+		},
+		{name: "null rule", raw: `{"update": null}`},
+		{name: "unknown action", raw: `{"archive": {"field": "status", "op": "eq", "value": "x"}}`, invalid: true},
+		{name: "unknown operator", raw: `{"read": {"field": "status", "op": "like", "value": "x"}}`, invalid: true},
+		{name: "in without list", raw: `{"read": {"field": "status", "op": "in", "value": "x"}}`, invalid: true},
+		{name: "gt without value", raw: `{"read": {"field": "amount", "op": "gt"}}`, invalid: true},
+		{name: "field and all", raw: `{"read": {"field": "status", "op": "null", "all": [{"field": "x", "op": "null"}]}}`, invalid: true},
+		{name: "empty nested rule", raw: `{"read": {"any": [null]}}`, invalid: true},
+		{name: "not json", raw: `[`, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions, err := authz.ParseConditions([]byte(tt.raw))
+			if tt.invalid {
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+				return
+			}
+			assert.NoError(t, err)
+
+			var actions []authz.Action
+			for action := range conditions {
+				actions = append(actions, action)
+			}
+			assert.ElementsMatch(t, tt.actions, actions)
+		})
+	}
+}
+
+func TestRuleFieldsAndVariables(t *testing.T) {
+	rule := &authz.Rule{
+		All: []*authz.Rule{
+			{Field: "branch_id", Op: "eq", Value: "$user.branch_id"},
+			{Not: &authz.Rule{Field: "status", Op: "in", Value: []any{"closed", "$user.role_id"}}},
+			{Any: []*authz.Rule{
+				{Field: "created_by", Op: "eq", Value: "$user.id"},
+				{Field: "branch_id", Op: "null"},
+			}},
+		},
+	}
+
+	assert.Equal(t, []string{"branch_id", "created_by", "status"}, rule.Fields())
+	assert.Equal(t, []string{"branch_id", "id", "role_id"}, rule.Variables())
+}
+
+func TestRuleSQL(t *testing.T) {
+	vars := map[string]any{
+		"id":        "u1",
+		"branch_id": "b'1",
+		"regions":   []any{"north", "south"},
+		"level":     float64(3),
+	}
+
+	tests := []struct {
+		name string
+		rule *authz.Rule
+		want string
+	}{
+		{
+			name: "eq variable",
+			rule: &authz.Rule{Field: "branch_id", Op: "eq", Value: "$user.branch_id"},
+			want: `COALESCE(a."branch_id" = 'b''1', FALSE)`,
+		},
+		{
+			name: "neq",
+			rule: &authz.Rule{Field: "status", Op: "neq", Value: "closed"},
+			want: `COALESCE(a."status" IS DISTINCT FROM 'closed', FALSE)`,
+		},
+		{
+			name: "gte number",
+			rule: &authz.Rule{Field: "level", Op: "gte", Value: "$user.level"},
+			want: `COALESCE(a."level" >= 3, FALSE)`,
+		},
+		{
+			name: "missing variable",
+			rule: &authz.Rule{Field: "manager_id", Op: "eq", Value: "$user.manager_id"},
+			want: `FALSE`,
+		},
+		{
+			name: "eq null",
+			rule: &authz.Rule{Field: "deleted_by", Op: "eq"},
+			want: `(a."deleted_by" IS NULL)`,
+		},
+		{
+			name: "in list variable",
+			rule: &authz.Rule{Field: "region", Op: "in", Value: []any{"$user.regions", "west"}},
+			want: `COALESCE(a."region"::TEXT IN ('north', 'south', 'west'), FALSE)`,
+		},
+		{
+			name: "nin",
+			rule: &authz.Rule{Field: "status", Op: "nin", Value: []any{"closed"}},
+			want: `(a."status" IS NULL OR a."status"::TEXT NOT IN ('closed'))`,
+		},
+		{
+			name: "empty in",
+			rule: &authz.Rule{Field: "status", Op: "in", Value: []any{}},
+			want: `FALSE`,
+		},
+		{
+			name: "combined",
+			rule: &authz.Rule{Any: []*authz.Rule{
+				{Field: "created_by", Op: "eq", Value: "$user.id"},
+				{Not: &authz.Rule{Field: "branch_id", Op: "not_null"}},
+			}},
+			want: `(COALESCE(a."created_by" = 'u1', FALSE) OR (NOT (a."branch_id" IS NOT NULL)))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule.SQL("a", vars)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRuleSQLExpressions(t *testing.T) {
+	vars := map[string]any{
+		"id":        authz.Expr("current_setting('ucode.user_id', true)"),
+		"branch_id": authz.Expr(`SELECT u.branch_id FROM "user" u`),
+	}
+
+	tests := []struct {
+		name string
+		rule *authz.Rule
+		want string
+	}{
+		{
+			name: "eq as text",
+			rule: &authz.Rule{Field: "created_by", Op: "eq", Value: "$user.id"},
+			want: `COALESCE("orders"."created_by"::TEXT = (current_setting('ucode.user_id', true))::TEXT, FALSE)`,
+		},
+		{
+			name: "in",
+			rule: &authz.Rule{Field: "branch_id", Op: "in", Value: []any{"$user.branch_id"}},
+			want: `COALESCE("orders"."branch_id"::TEXT IN ((SELECT u.branch_id FROM "user" u)::TEXT), FALSE)`,
+		},
+		{
+			name: "gt keeps the type",
+			rule: &authz.Rule{Field: "branch_id", Op: "gt", Value: "$user.branch_id"},
+			want: `COALESCE("orders"."branch_id" > (SELECT u.branch_id FROM "user" u), FALSE)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule.SQL(`"orders"`, vars)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
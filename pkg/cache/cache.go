@@ -0,0 +1,195 @@
+// Package cache keeps results of expensive read queries such as custom
+// endpoints and aggregations. Entries depend on the tables their query reads;
+// writing to any of those tables makes the entries unreachable.
+//
+// Invalidation works through per-table generation tokens kept in the same
+// store as the entries. A lookup key embeds the current token of every table
+// it depends on, so replacing a token is enough to invalidate every entry
+// built on it, and it works the same for in-process and external stores.
+// Stores that evict must not prefer tokens over entries; with LRU an
+// invalidated entry is never touched again, so it always goes first.
+//
+// The default store is an LRU inside the process, so invalidation only
+// reaches the replica that made the write. Other replicas keep serving their
+// entries until the TTL expires. Deployments with more than one replica
+// either plug a shared Store in with SetDefault, which carries the tokens to
+// every replica, or keep the TTL as short as the staleness they accept.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+var dollarTag = regexp.MustCompile(`\$[A-Za-z_]\w*\$`)
+
+// Store is a key/value backend for the cache. Implementations must be safe
+// for concurrent use. A zero ttl means the value does not expire.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
+
+const (
+	DefaultSize = 10000
+	DefaultTTL  = time.Minute
+)
+
+// Cache stores query results under keys scoped to a tenant.
+type Cache struct {
+	store Store
+	ttl   time.Duration
+	seq   atomic.Uint64
+}
+
+// New returns a cache on top of store. ttl is used when Set is called
+// without one.
+func New(store Store, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Cache{store: store, ttl: ttl}
+}
+
+var (
+	defaultMu    sync.RWMutex
+	defaultCache = New(NewLRU(DefaultSize), DefaultTTL)
+)
+
+// SetDefault replaces the service wide cache.
+func SetDefault(c *Cache) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	defaultCache = c
+}
+
+// Default returns the service wide cache.
+func Default() *Cache {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+
+	return defaultCache
+}
+
+// Entry identifies a cached result: the tenant it belongs to, the tables its
+// query reads and the values the result is computed from.
+type Entry struct {
+	Scope  string
+	Tables []string
+	Parts  []any
+}
+
+// Get returns the cached value of the entry.
+func (c *Cache) Get(ctx context.Context, e Entry) ([]byte, bool) {
+	key, ok := c.key(ctx, e)
+	if !ok {
+		return nil, false
+	}
+	return c.store.Get(ctx, key)
+}
+
+// Set caches the value of the entry. A zero ttl uses the cache default.
+func (c *Cache) Set(ctx context.Context, e Entry, value []byte, ttl time.Duration) {
+	key, ok := c.key(ctx, e)
+	if !ok {
+		return
+	}
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	c.store.Set(ctx, key, value, ttl)
+}
+
+// Invalidate drops every entry of scope that depends on one of tables.
+func (c *Cache) Invalidate(ctx context.Context, scope string, tables ...string) {
+	token := strconv.FormatInt(time.Now().UnixNano(), 36) + "." + strconv.FormatUint(c.seq.Add(1), 36)
+	for _, table := range tables {
+		if table = normalizeTable(table); table != "" {
+			c.store.Set(ctx, generationKey(scope, table), []byte(token), 0)
+		}
+	}
+}
+
+func (c *Cache) key(ctx context.Context, e Entry) (string, bool) {
+	tables := make([]string, 0, len(e.Tables))
+	for _, table := range e.Tables {
+		if table = normalizeTable(table); table != "" {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+
+	generations := make([]string, len(tables))
+	for i, table := range tables {
+		token, _ := c.store.Get(ctx, generationKey(e.Scope, table))
+		generations[i] = table + "@" + string(token)
+	}
+
+	parts, err := json.Marshal(e.Parts)
+	if err != nil {
+		return "", false
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(generations, ",") + "\x00" + string(parts)))
+	return "result:" + e.Scope + ":" + hex.EncodeToString(sum[:]), true
+}
+
+func generationKey(scope, table string) string {
+	return "generation:" + scope + ":" + table
+}
+
+func normalizeTable(table string) string {
+	table = strings.ToLower(strings.TrimSpace(table))
+	return strings.TrimPrefix(table, "public.")
+}
+
+// NormalizeQuery collapses whitespace outside quoted literals so formatting
+// changes do not create separate entries for the same query. Queries with
+// dollar-quoted strings are only trimmed.
+func NormalizeQuery(query string) string {
+	query = strings.TrimSpace(query)
+	if strings.Contains(query, "$$") || dollarTag.MatchString(query) {
+		return query
+	}
+
+	var (
+		sb    strings.Builder
+		quote rune
+		space bool
+	)
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case unicode.IsSpace(c):
+			space = true
+			continue
+		}
+		if space {
+			sb.WriteRune(' ')
+			space = false
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// Invalidate drops entries of the default cache.
+func Invalidate(ctx context.Context, scope string, tables ...string) {
+	Default().Invalidate(ctx, scope, tables...)
+}
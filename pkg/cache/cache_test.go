@@ -0,0 +1,66 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ucode/ucode_go_object_builder_service/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheInvalidation(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		c      = cache.New(cache.NewLRU(100), time.Minute)
+		orders = cache.Entry{Scope: "project", Tables: []string{"orders", "public.Customers"}, Parts: []any{"SELECT 1", 10}}
+		other  = cache.Entry{Scope: "project", Tables: []string{"products"}, Parts: []any{"SELECT 2"}}
+	)
+
+	c.Set(ctx, orders, []byte("orders"), 0)
+	c.Set(ctx, other, []byte("products"), 0)
+
+	value, ok := c.Get(ctx, orders)
+	assert.True(t, ok)
+	assert.Equal(t, "orders", string(value))
+
+	c.Invalidate(ctx, "another_project", "customers")
+	_, ok = c.Get(ctx, orders)
+	assert.True(t, ok)
+
+	c.Invalidate(ctx, "project", "customers")
+	_, ok = c.Get(ctx, orders)
+	assert.False(t, ok)
+
+	_, ok = c.Get(ctx, other)
+	assert.True(t, ok)
+}
+
+func TestLRU(t *testing.T) {
+	var (
+		ctx = context.Background()
+		lru = cache.NewLRU(2)
+	)
+
+	lru.Set(ctx, "a", []byte("1"), 0)
+	lru.Set(ctx, "b", []byte("2"), 0)
+	lru.Get(ctx, "a")
+	lru.Set(ctx, "c", []byte("3"), 0)
+
+	_, ok := lru.Get(ctx, "b")
+	assert.False(t, ok)
+	_, ok = lru.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, lru.Len())
+
+	lru.Set(ctx, "d", []byte("4"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	_, ok = lru.Get(ctx, "d")
+	assert.False(t, ok)
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	assert.Equal(t, "SELECT * FROM orders WHERE note = 'a  b'", cache.NormalizeQuery("  SELECT *\n\tFROM   orders WHERE note = 'a  b'  "))
+	assert.Equal(t, "SELECT $tag$a  b$tag$", cache.NormalizeQuery("SELECT $tag$a  b$tag$"))
+}
@@ -2,9 +2,12 @@ package cron
 
 import (
 	"context"
+	"fmt"
+	"ucode/ucode_go_object_builder_service/config"
 	"ucode/ucode_go_object_builder_service/genproto/company_service"
 	"ucode/ucode_go_object_builder_service/grpc/client"
 	"ucode/ucode_go_object_builder_service/pkg/logger"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
 	"ucode/ucode_go_object_builder_service/storage"
 
 	pb "ucode/ucode_go_object_builder_service/genproto/company_service"
@@ -13,6 +16,7 @@ import (
 )
 
 type TaskScheduler struct {
+	cfg     config.Config
 	cronJob *cron.Cron
 	logger  logger.LoggerI
 	storage storage.StorageI
@@ -23,12 +27,15 @@ type TaskSchedulerI interface {
 	RunJobs(context.Context) error
 	DeleteFunctionLogs(context.Context) error
 	RotateVersionHistoryPartitions(context.Context) error
+	ProcessFormulaQueue(context.Context) error
+	ReencryptFields(context.Context) error
 }
 
-func New(log logger.LoggerI, storage storage.StorageI, svcs client.ServiceManagerI) TaskSchedulerI {
+func New(cfg config.Config, log logger.LoggerI, storage storage.StorageI, svcs client.ServiceManagerI) TaskSchedulerI {
 	var cronJob = cron.New()
 	defer cronJob.Start()
 	return &TaskScheduler{
+		cfg:     cfg,
 		cronJob: cronJob,
 		logger:  log,
 		storage: storage,
@@ -55,6 +62,27 @@ func (t *TaskScheduler) RunJobs(ctx context.Context) error {
 		return err
 	}
 
+	// A slow run of the formula queue must not overlap the next tick.
+	formulaQueue := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(cron.FuncJob(func() {
+		if err := t.ProcessFormulaQueue(ctx); err != nil {
+			t.logger.Error("error in ProcessFormulaQueue", logger.Error(err))
+		}
+	}))
+
+	if _, err := t.cronJob.AddJob(fmt.Sprintf("@every %s", t.cfg.FormulaQueueInterval), formulaQueue); err != nil {
+		return err
+	}
+
+	reencrypt := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(cron.FuncJob(func() {
+		if err := t.ReencryptFields(ctx); err != nil {
+			t.logger.Error("error in ReencryptFields", logger.Error(err))
+		}
+	}))
+
+	if _, err := t.cronJob.AddJob("@every 5m", reencrypt); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -85,6 +113,8 @@ func (t *TaskScheduler) DeleteFunctionLogs(ctx context.Context) error {
 // RotateVersionHistoryPartitions walks every tenant Postgres pool and:
 //  - creates the partition for the next week (idempotent),
 //  - drops partitions whose date range is fully outside the retention window.
+// Partitions are only dropped after a checkpoint of the audit log covering
+// them was exported, which the rotation of each tenant does first.
 // One failing tenant must not stop the rotation for the rest.
 func (t *TaskScheduler) RotateVersionHistoryPartitions(ctx context.Context) error {
 	t.logger.Info("Running RotateVersionHistoryPartitions job ...")
@@ -109,3 +139,53 @@ func (t *TaskScheduler) RotateVersionHistoryPartitions(ctx context.Context) erro
 
 	return nil
 }
+
+// ProcessFormulaQueue drains the formula recalculation queue of every
+// connected project, batch by batch. Bulk writes fill the queue, so their
+// formula values lag until this job has run.
+func (t *TaskScheduler) ProcessFormulaQueue(ctx context.Context) error {
+	batchSize := t.cfg.FormulaQueueBatchSize
+
+	for _, projectId := range psqlpool.Projects() {
+		for ctx.Err() == nil {
+			processed, err := t.storage.Items().ProcessRecalculationQueue(ctx, projectId, batchSize)
+			if err != nil {
+				t.logger.Error("error in processing formula queue",
+					logger.String("project_id", projectId),
+					logger.Error(err),
+				)
+				break
+			}
+			if processed < batchSize {
+				break
+			}
+		}
+	}
+
+	return ctx.Err()
+}
+
+// ReencryptFields encrypts, re-encrypts after a key rotation or decrypts
+// the values of the encrypted fields of every connected project, batch by
+// batch, until they match the flags of their fields.
+func (t *TaskScheduler) ReencryptFields(ctx context.Context) error {
+	batchSize := t.cfg.EncryptionBatchSize
+
+	for _, projectId := range psqlpool.Projects() {
+		for ctx.Err() == nil {
+			processed, err := t.storage.Items().ReencryptFields(ctx, projectId, batchSize)
+			if err != nil {
+				t.logger.Error("error in re-encrypting fields",
+					logger.String("project_id", projectId),
+					logger.Error(err),
+				)
+				break
+			}
+			if processed < batchSize {
+				break
+			}
+		}
+	}
+
+	return ctx.Err()
+}
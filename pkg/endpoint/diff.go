@@ -0,0 +1,119 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+)
+
+// Revision statuses of a custom endpoint.
+const (
+	RevisionDraft     = "draft"
+	RevisionPublished = "published"
+)
+
+// Diff compares the executable content of two revisions. Metadata such as
+// the commit message and timestamps is ignored.
+func Diff(from, to *nb.CustomEndpointRevision) *nb.CustomEndpointRevisionDiff {
+	diff := &nb.CustomEndpointRevisionDiff{
+		FromRevision: from.GetRevision(),
+		ToRevision:   to.GetRevision(),
+	}
+
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"sql", from.GetSql(), to.GetSql()},
+		{"method", from.GetMethod(), to.GetMethod()},
+		{"in_transaction", strconv.FormatBool(from.GetInTransaction()), strconv.FormatBool(to.GetInTransaction())},
+		{"parameters", paramsJSON(from.GetParameters()), paramsJSON(to.GetParameters())},
+		{"policy", policyJSON(from.GetPolicy()), policyJSON(to.GetPolicy())},
+		{"response", responseJSON(from.GetResponse()), responseJSON(to.GetResponse())},
+	}
+
+	for _, f := range fields {
+		if f.from != f.to {
+			diff.Changes = append(diff.Changes, &nb.RevisionFieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+
+	if from.GetSql() != to.GetSql() {
+		diff.SqlDiff = LineDiff(from.GetSql(), to.GetSql())
+	}
+
+	return diff
+}
+
+// LineDiff renders a line based diff of two texts. Unchanged lines are
+// prefixed with two spaces, removed lines with "- " and added lines with "+ ".
+func LineDiff(from, to string) string {
+	a, b := strings.Split(from, "\n"), strings.Split(to, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var (
+		sb   strings.Builder
+		i, j int
+	)
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+
+	return sb.String()
+}
+
+func paramsJSON(params []*nb.Parameter) string {
+	if len(params) == 0 {
+		return "[]"
+	}
+	return toJSON(params)
+}
+
+func policyJSON(policy *nb.SqlPolicy) string {
+	if policy == nil {
+		policy = &nb.SqlPolicy{}
+	}
+	return toJSON(policy)
+}
+
+func responseJSON(response *nb.ResponseShape) string {
+	if response == nil {
+		response = &nb.ResponseShape{}
+	}
+	return toJSON(response)
+}
+
+func toJSON(v any) string {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(body)
+}
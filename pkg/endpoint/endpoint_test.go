@@ -0,0 +1,127 @@
+package endpoint_test
+
+import (
+	"testing"
+
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/endpoint"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBind(t *testing.T) {
+	params := []*nb.Parameter{
+		{Name: "id", Type: "int", Required: true},
+		{Name: "status", Type: "string", DefaultValue: "new", Enum: []string{"new", "done"}},
+		{Name: "code", Pattern: `^[A-Z]{3}$`},
+	}
+
+	query, args, err := endpoint.Bind(
+		"SELECT * FROM orders WHERE id = :id AND status = :status AND created_at::date = now()::date AND id <> :id",
+		params,
+		map[string]string{"id": "42"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM orders WHERE id = $1 AND status = $2 AND created_at::date = now()::date AND id <> $1", query)
+	assert.Equal(t, []any{int64(42), "new"}, args)
+
+	_, _, err = endpoint.Bind("SELECT :id", params, map[string]string{})
+	assert.ErrorContains(t, err, "is required")
+
+	_, _, err = endpoint.Bind("SELECT :id", params, map[string]string{"id": "abc"})
+	assert.ErrorContains(t, err, "expected int")
+
+	_, _, err = endpoint.Bind("SELECT :status", params, map[string]string{"status": "closed"})
+	assert.ErrorContains(t, err, "must be one of")
+
+	_, _, err = endpoint.Bind("SELECT :code", params, map[string]string{"code": "ab"})
+	assert.ErrorContains(t, err, "does not match pattern")
+
+	_, args, err = endpoint.Bind("SELECT $1, $2", params[:2], map[string]string{"id": "7"})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(7), "new"}, args)
+
+	query, args, err = endpoint.Bind("SELECT ':status' AS note, \"a:b\" FROM orders -- :code\nWHERE id = :id", params, map[string]string{"id": "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT ':status' AS note, \"a:b\" FROM orders -- :code\nWHERE id = $1", query)
+	assert.Equal(t, []any{int64(1)}, args)
+
+	_, _, err = endpoint.Bind("SELECT :id, :other", params, map[string]string{"id": "1"})
+	assert.ErrorContains(t, err, "is not declared")
+
+	query, args, err = endpoint.Bind("SELECT :other", nil, map[string]string{"other": "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT $1", query)
+	assert.Equal(t, []any{"x"}, args)
+}
+
+func TestValidatePlaceholders(t *testing.T) {
+	params := []*nb.Parameter{{Name: "id"}, {Name: "status"}}
+
+	tests := []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{name: "declared names", query: "SELECT * FROM orders WHERE id = :id AND status = :status"},
+		{name: "declared positions", query: "SELECT * FROM orders WHERE id = $1 AND status = $2"},
+		{name: "undeclared name", query: "SELECT * FROM orders WHERE id = :id AND owner = :owner", err: `"owner": is not declared`},
+		{name: "undeclared position", query: "SELECT * FROM orders WHERE id = $3", err: `"$3": is not declared`},
+		{name: "quoted text", query: "SELECT ':owner', $$ :owner $$ FROM orders /* :owner */ WHERE id = :id"},
+		{name: "mixed", query: "SELECT * FROM orders WHERE id = :id AND status = $2", err: "mixes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := endpoint.ValidatePlaceholders(tt.query, params)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestShape(t *testing.T) {
+	rows := func() []map[string]any {
+		return []map[string]any{
+			{"id": [16]byte{1}, "amount": "12.50", endpoint.TotalColumn: int64(3)},
+		}
+	}
+	columns := []*nb.ResponseColumn{
+		{Source: "amount", Name: "total", Type: "number"},
+		{Source: "id", Type: "uuid"},
+	}
+
+	data, err := endpoint.Shape(rows(), &nb.ResponseShape{Mode: "object", Columns: columns}, 0, 0)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"total": 12.50, "id": "01000000-0000-0000-0000-000000000000"}`, string(data))
+
+	data, err = endpoint.Shape(rows(), &nb.ResponseShape{Mode: "scalar", Columns: columns}, 0, 0)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `12.50`, string(data))
+
+	data, err = endpoint.Shape(rows(), &nb.ResponseShape{Mode: "paginated", Columns: columns}, 20, 0)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"items": [{"total": 12.50, "id": "01000000-0000-0000-0000-000000000000"}], "total": 3, "limit": 20, "offset": 0}`, string(data))
+
+	data, err = endpoint.Shape(nil, nil, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(data))
+}
+
+func TestDiff(t *testing.T) {
+	from := &nb.CustomEndpointRevision{Revision: 1, Sql: "SELECT id\nFROM orders", Method: "GET"}
+	to := &nb.CustomEndpointRevision{Revision: 2, Sql: "SELECT id, total\nFROM orders", Method: "GET", Policy: &nb.SqlPolicy{}}
+
+	diff := endpoint.Diff(from, to)
+	assert.Equal(t, int32(1), diff.GetFromRevision())
+	assert.Equal(t, int32(2), diff.GetToRevision())
+	if assert.Len(t, diff.GetChanges(), 1) {
+		assert.Equal(t, "sql", diff.GetChanges()[0].GetField())
+	}
+	assert.Equal(t, "- SELECT id\n+ SELECT id, total\n  FROM orders\n", diff.GetSqlDiff())
+
+	assert.Empty(t, endpoint.Diff(from, from).GetChanges())
+}
@@ -0,0 +1,293 @@
+// Package endpoint implements the contract of custom endpoints: typed
+// parameters declared on the endpoint are validated and bound before the SQL
+// runs, and the result rows are shaped into the response the caller expects.
+package endpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/sqlguard"
+
+	"github.com/google/uuid"
+)
+
+// Parameter and column types understood by the contract.
+const (
+	TypeString    = "string"
+	TypeInt       = "int"
+	TypeNumber    = "number"
+	TypeBool      = "bool"
+	TypeUUID      = "uuid"
+	TypeDate      = "date"
+	TypeTimestamp = "timestamp"
+	TypeJSON      = "json"
+)
+
+var typeAliases = map[string]string{
+	"":          TypeString,
+	"string":    TypeString,
+	"text":      TypeString,
+	"varchar":   TypeString,
+	"int":       TypeInt,
+	"integer":   TypeInt,
+	"bigint":    TypeInt,
+	"number":    TypeNumber,
+	"float":     TypeNumber,
+	"numeric":   TypeNumber,
+	"decimal":   TypeNumber,
+	"bool":      TypeBool,
+	"boolean":   TypeBool,
+	"uuid":      TypeUUID,
+	"date":      TypeDate,
+	"timestamp": TypeTimestamp,
+	"datetime":  TypeTimestamp,
+	"json":      TypeJSON,
+	"jsonb":     TypeJSON,
+}
+
+// ValidationError is returned when the caller's input does not satisfy the
+// declared parameters.
+type ValidationError struct {
+	Param  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("parameter %q: %s", e.Param, e.Reason)
+}
+
+// NormalizeType maps a declared type to one of the Type constants.
+func NormalizeType(t string) (string, error) {
+	normalized, ok := typeAliases[strings.ToLower(strings.TrimSpace(t))]
+	if !ok {
+		return "", fmt.Errorf("unsupported type %q", t)
+	}
+	return normalized, nil
+}
+
+// ValidateDeclarations checks the parameter list stored on an endpoint:
+// names are unique, types are known, patterns compile and defaults satisfy
+// their own rules.
+func ValidateDeclarations(params []*nb.Parameter) error {
+	seen := make(map[string]bool, len(params))
+	for _, p := range params {
+		if p.GetName() == "" {
+			return &ValidationError{Reason: "name is required"}
+		}
+		if seen[p.GetName()] {
+			return &ValidationError{Param: p.GetName(), Reason: "declared more than once"}
+		}
+		seen[p.GetName()] = true
+
+		if _, err := NormalizeType(p.GetType()); err != nil {
+			return &ValidationError{Param: p.GetName(), Reason: err.Error()}
+		}
+		if p.GetPattern() != "" {
+			if _, err := regexp.Compile(p.GetPattern()); err != nil {
+				return &ValidationError{Param: p.GetName(), Reason: "invalid pattern: " + err.Error()}
+			}
+		}
+		if p.GetDefaultValue() != "" {
+			if _, err := Coerce(p, p.GetDefaultValue()); err != nil {
+				return &ValidationError{Param: p.GetName(), Reason: "invalid default: " + err.Error()}
+			}
+		}
+	}
+	return nil
+}
+
+// Coerce validates a raw input value against a declared parameter and
+// converts it to the Go value bound to the query.
+func Coerce(p *nb.Parameter, raw string) (any, error) {
+	if len(p.GetEnum()) > 0 && !slices.Contains(p.GetEnum(), raw) {
+		return nil, &ValidationError{Param: p.GetName(), Reason: fmt.Sprintf("must be one of %s", strings.Join(p.GetEnum(), ", "))}
+	}
+
+	if p.GetPattern() != "" {
+		re, err := regexp.Compile(p.GetPattern())
+		if err != nil {
+			return nil, &ValidationError{Param: p.GetName(), Reason: "invalid pattern: " + err.Error()}
+		}
+		if !re.MatchString(raw) {
+			return nil, &ValidationError{Param: p.GetName(), Reason: fmt.Sprintf("does not match pattern %s", p.GetPattern())}
+		}
+	}
+
+	typ, err := NormalizeType(p.GetType())
+	if err != nil {
+		return nil, &ValidationError{Param: p.GetName(), Reason: err.Error()}
+	}
+
+	value, err := parseValue(typ, raw)
+	if err != nil {
+		return nil, &ValidationError{Param: p.GetName(), Reason: fmt.Sprintf("expected %s: %v", typ, err)}
+	}
+	return value, nil
+}
+
+func parseValue(typ, raw string) (any, error) {
+	switch typ {
+	case TypeInt:
+		return strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	case TypeNumber:
+		// Bound as text so numeric columns keep their exact value.
+		if _, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); err != nil {
+			return nil, err
+		}
+		return strings.TrimSpace(raw), nil
+	case TypeBool:
+		return strconv.ParseBool(strings.TrimSpace(raw))
+	case TypeUUID:
+		id, err := uuid.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, err
+		}
+		return id.String(), nil
+	case TypeDate:
+		return time.Parse(time.DateOnly, strings.TrimSpace(raw))
+	case TypeTimestamp:
+		return time.Parse(time.RFC3339, strings.TrimSpace(raw))
+	case TypeJSON:
+		if !json.Valid([]byte(raw)) {
+			return nil, fmt.Errorf("invalid json")
+		}
+		return raw, nil
+	default:
+		return raw, nil
+	}
+}
+
+// ValidatePlaceholders checks that every placeholder of query is declared:
+// :name placeholders by name and $N placeholders by position.
+func ValidatePlaceholders(query string, params []*nb.Parameter) error {
+	placeholders, err := sqlguard.Params(query)
+	if err != nil {
+		return err
+	}
+
+	declared := make(map[string]bool, len(params))
+	for _, p := range params {
+		declared[p.GetName()] = true
+	}
+
+	var named, positional bool
+	for _, ph := range placeholders {
+		if ph.Name != "" {
+			named = true
+			if !declared[ph.Name] {
+				return &ValidationError{Param: ph.Name, Reason: "is not declared"}
+			}
+			continue
+		}
+
+		positional = true
+		if ph.Position < 1 || ph.Position > len(params) {
+			return &ValidationError{Param: fmt.Sprintf("$%d", ph.Position), Reason: "is not declared"}
+		}
+	}
+
+	if named && positional {
+		return fmt.Errorf("query mixes :name and $N placeholders")
+	}
+	return nil
+}
+
+// Bind resolves the placeholders of query against the declared parameters and
+// the caller's input. Queries written with :name placeholders are rewritten
+// to $N; queries written with $N take their arguments in declaration order.
+// Quoted text and comments are left as they are. A placeholder that is not
+// declared is rejected, unless the endpoint declares no parameters at all:
+// such endpoints predate declarations and keep binding the caller's input as
+// untyped strings, or NULL when absent.
+func Bind(query string, params []*nb.Parameter, input map[string]string) (string, []any, error) {
+	placeholders, err := sqlguard.Params(query)
+	if err != nil {
+		return "", nil, err
+	}
+
+	declared := make(map[string]*nb.Parameter, len(params))
+	for _, p := range params {
+		declared[p.GetName()] = p
+	}
+
+	resolve := func(name string) (any, error) {
+		p, ok := declared[name]
+		raw, exists := input[name]
+		if !ok {
+			if len(params) > 0 {
+				return nil, &ValidationError{Param: name, Reason: "is not declared"}
+			}
+			if !exists {
+				return nil, nil
+			}
+			return raw, nil
+		}
+
+		if !exists || raw == "" {
+			switch {
+			case p.GetDefaultValue() != "":
+				raw = p.GetDefaultValue()
+			case p.GetRequired():
+				return nil, &ValidationError{Param: name, Reason: "is required"}
+			default:
+				return nil, nil
+			}
+		}
+		return Coerce(p, raw)
+	}
+
+	var (
+		args     []any
+		position = make(map[string]int)
+	)
+
+	if hasNamedParams(placeholders) {
+		var (
+			b    strings.Builder
+			last int
+		)
+		for _, ph := range placeholders {
+			if ph.Name == "" {
+				continue
+			}
+			if _, ok := position[ph.Name]; !ok {
+				value, err := resolve(ph.Name)
+				if err != nil {
+					return "", nil, err
+				}
+				args = append(args, value)
+				position[ph.Name] = len(args)
+			}
+			b.WriteString(query[last:ph.Start])
+			b.WriteString(fmt.Sprintf("$%d", position[ph.Name]))
+			last = ph.End
+		}
+		b.WriteString(query[last:])
+		return b.String(), args, nil
+	}
+
+	for _, p := range params {
+		value, err := resolve(p.GetName())
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, value)
+	}
+	return query, args, nil
+}
+
+func hasNamedParams(placeholders []sqlguard.Param) bool {
+	for _, ph := range placeholders {
+		if ph.Name != "" {
+			return true
+		}
+	}
+	return false
+}
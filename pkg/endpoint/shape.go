@@ -0,0 +1,241 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Response modes of a custom endpoint.
+const (
+	ModeArray     = "array"
+	ModeObject    = "object"
+	ModeScalar    = "scalar"
+	ModePaginated = "paginated"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 1000
+
+	// TotalColumn carries the total row count of a paginated query.
+	TotalColumn = "__total"
+)
+
+// Page is the envelope returned by paginated endpoints.
+type Page struct {
+	Items  []map[string]any `json:"items"`
+	Total  int64            `json:"total"`
+	Limit  uint32           `json:"limit"`
+	Offset uint32           `json:"offset"`
+}
+
+// NormalizeMode maps an empty mode to the default array mode.
+func NormalizeMode(mode string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(mode)); m {
+	case "":
+		return ModeArray, nil
+	case ModeArray, ModeObject, ModeScalar, ModePaginated:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unsupported response mode %q", mode)
+	}
+}
+
+// ValidateShape checks the response shape stored on an endpoint.
+func ValidateShape(shape *nb.ResponseShape) error {
+	if _, err := NormalizeMode(shape.GetMode()); err != nil {
+		return err
+	}
+	for _, col := range shape.GetColumns() {
+		if col.GetSource() == "" {
+			return fmt.Errorf("response column source is required")
+		}
+		if col.GetType() != "" {
+			if _, err := NormalizeType(col.GetType()); err != nil {
+				return fmt.Errorf("response column %q: %w", col.GetSource(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Paginate wraps a SELECT so it returns one page of rows together with the
+// total row count in TotalColumn. The limit and offset are appended to args.
+func Paginate(query string, args []any, limit, offset uint32) (string, []any, uint32) {
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	args = append(args, limit, offset)
+
+	return fmt.Sprintf(
+		"SELECT endpoint_page.*, count(*) OVER() AS %s FROM (%s) AS endpoint_page LIMIT $%d OFFSET $%d",
+		TotalColumn, query, len(args)-1, len(args),
+	), args, limit
+}
+
+// Shape renames and coerces the columns of rows and encodes them in the
+// response mode of the endpoint.
+func Shape(rows []map[string]any, shape *nb.ResponseShape, limit, offset uint32) ([]byte, error) {
+	mode, err := NormalizeMode(shape.GetMode())
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, row := range rows {
+		if v, ok := row[TotalColumn]; ok {
+			total, _ = v.(int64)
+			delete(row, TotalColumn)
+		}
+		if err := shapeRow(row, shape.GetColumns()); err != nil {
+			return nil, err
+		}
+	}
+
+	switch mode {
+	case ModeObject:
+		if len(rows) == 0 {
+			return json.Marshal(nil)
+		}
+		return json.Marshal(rows[0])
+	case ModeScalar:
+		if len(rows) == 0 {
+			return json.Marshal(nil)
+		}
+		return json.Marshal(scalarOf(rows[0], shape.GetColumns()))
+	case ModePaginated:
+		if rows == nil {
+			rows = []map[string]any{}
+		}
+		return json.Marshal(Page{Items: rows, Total: total, Limit: limit, Offset: offset})
+	default:
+		return json.Marshal(rows)
+	}
+}
+
+// scalarOf returns the first mapped column of a row, or its only column.
+func scalarOf(row map[string]any, columns []*nb.ResponseColumn) any {
+	if len(columns) > 0 {
+		return row[columnName(columns[0])]
+	}
+	for _, v := range row {
+		return v
+	}
+	return nil
+}
+
+func columnName(col *nb.ResponseColumn) string {
+	if col.GetName() != "" {
+		return col.GetName()
+	}
+	return col.GetSource()
+}
+
+func shapeRow(row map[string]any, columns []*nb.ResponseColumn) error {
+	for key, val := range row {
+		row[key] = normalizeValue(val)
+	}
+
+	for _, col := range columns {
+		val, ok := row[col.GetSource()]
+		if !ok {
+			continue
+		}
+
+		if col.GetType() != "" {
+			typ, err := NormalizeType(col.GetType())
+			if err != nil {
+				return err
+			}
+			if val, err = coerceColumn(typ, val); err != nil {
+				return fmt.Errorf("response column %q: %w", col.GetSource(), err)
+			}
+		}
+
+		delete(row, col.GetSource())
+		row[columnName(col)] = val
+	}
+	return nil
+}
+
+// normalizeValue converts driver types to values with a stable JSON form.
+func normalizeValue(val any) any {
+	switch v := val.(type) {
+	case [16]byte:
+		return uuid.UUID(v).String()
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case pgtype.Numeric:
+		if !v.Valid {
+			return nil
+		}
+		text, err := v.MarshalJSON()
+		if err != nil {
+			return nil
+		}
+		if s, err := strconv.Unquote(string(text)); err == nil {
+			return s
+		}
+		return json.Number(text)
+	default:
+		return val
+	}
+}
+
+func coerceColumn(typ string, val any) (any, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	text := fmt.Sprint(val)
+	switch typ {
+	case TypeString:
+		return text, nil
+	case TypeInt:
+		if f, ok := new(big.Float).SetString(text); ok {
+			i, _ := f.Int64()
+			return i, nil
+		}
+		return nil, fmt.Errorf("cannot convert %q to int", text)
+	case TypeNumber:
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return nil, fmt.Errorf("cannot convert %q to number", text)
+		}
+		return json.Number(text), nil
+	case TypeBool:
+		return strconv.ParseBool(text)
+	case TypeUUID:
+		id, err := uuid.Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		return id.String(), nil
+	case TypeDate, TypeTimestamp:
+		t, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, text); err != nil {
+				return nil, fmt.Errorf("cannot convert %q to %s", text, typ)
+			}
+		}
+		if typ == TypeDate {
+			return t.Format(time.DateOnly), nil
+		}
+		return t.Format(time.RFC3339), nil
+	default:
+		return val, nil
+	}
+}
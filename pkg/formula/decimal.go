@@ -0,0 +1,80 @@
+package formula
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Rounding modes for RoundDecimal.
+const (
+	RoundHalfUp   = "half_up"
+	RoundHalfEven = "half_even"
+	RoundUp       = "up"
+	RoundDown     = "down"
+	RoundCeiling  = "ceiling"
+	RoundFloor    = "floor"
+)
+
+// RoundDecimal rounds the decimal text value to places digits after the
+// point without going through floating point. Negative places round to
+// tens, hundreds and so on. HALF_UP rounds ties away from zero, like the
+// numeric ROUND of Postgres; an empty mode means HALF_UP.
+func RoundDecimal(value string, places int, mode string) (string, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return "", fmt.Errorf("formula: %q is not a decimal", value)
+	}
+
+	exp := places
+	if exp < 0 {
+		exp = -exp
+	}
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+
+	scaled := new(big.Rat).Set(r)
+	if places >= 0 {
+		scaled.Mul(scaled, scale)
+	} else {
+		scaled.Quo(scaled, scale)
+	}
+
+	quo, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if rem.Sign() != 0 {
+		away := false
+
+		switch strings.ToLower(mode) {
+		case RoundDown:
+		case RoundUp:
+			away = true
+		case RoundCeiling:
+			away = scaled.Sign() > 0
+		case RoundFloor:
+			away = scaled.Sign() < 0
+		case RoundHalfUp, RoundHalfEven, "":
+			half := new(big.Int).Abs(rem)
+			half.Lsh(half, 1)
+			switch half.Cmp(scaled.Denom()) {
+			case 1:
+				away = true
+			case 0:
+				away = strings.ToLower(mode) != RoundHalfEven || quo.Bit(0) == 1
+			}
+		default:
+			return "", fmt.Errorf("formula: unknown rounding mode %q", mode)
+		}
+
+		if away {
+			quo.Add(quo, big.NewInt(int64(scaled.Sign())))
+		}
+	}
+
+	result := new(big.Rat).SetInt(quo)
+	if places >= 0 {
+		result.Quo(result, scale)
+	} else {
+		result.Mul(result, scale)
+	}
+
+	return result.FloatString(max(places, 0)), nil
+}
@@ -0,0 +1,245 @@
+package formula
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+type node interface {
+	eval(s *state) (any, error)
+}
+
+type literal struct {
+	value any
+}
+
+type reference struct {
+	name string
+}
+
+type unary struct {
+	op string
+	x  node
+}
+
+type binary struct {
+	op   string
+	x, y node
+}
+
+type call struct {
+	name string
+	fn   *function
+	args []node
+}
+
+func (n *literal) eval(s *state) (any, error) {
+	return n.value, s.step()
+}
+
+func (n *reference) eval(s *state) (any, error) {
+	if err := s.step(); err != nil {
+		return nil, err
+	}
+
+	value, ok := s.vars[n.name]
+	if !ok {
+		return nil, &Error{Code: ErrName, Message: "unknown field " + n.name}
+	}
+
+	value = normalize(value)
+	return value, s.account(value)
+}
+
+func (n *unary) eval(s *state) (any, error) {
+	if err := s.step(); err != nil {
+		return nil, err
+	}
+
+	x, err := n.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	num, err := toNumber(x)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "-":
+		return -num, nil
+	case "%":
+		return num / 100, nil
+	}
+	return num, nil
+}
+
+func (n *binary) eval(s *state) (any, error) {
+	if err := s.step(); err != nil {
+		return nil, err
+	}
+
+	x, err := n.x.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	y, err := n.y.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "&":
+		left, right := toText(x), toText(y)
+		if err := s.reserve(len(left) + len(right)); err != nil {
+			return nil, err
+		}
+		result := left + right
+		return result, s.account(result)
+	case "=", "<>", "<", ">", "<=", ">=":
+		return comparison(n.op, compare(x, y)), nil
+	}
+
+	if result, ok, err := dateArithmetic(n.op, x, y); ok {
+		return result, err
+	}
+
+	a, err := toNumber(x)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toNumber(y)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return nil, &Error{Code: ErrDiv0}
+		}
+		return a / b, nil
+	case "^":
+		return number(math.Pow(a, b))
+	}
+
+	return nil, valueError("unknown operator %s", n.op)
+}
+
+// dateArithmetic handles date + days, date - days and date - date, the last
+// one giving the difference in days.
+func dateArithmetic(op string, x, y any) (any, bool, error) {
+	if op != "+" && op != "-" {
+		return nil, false, nil
+	}
+
+	tx, xIsTime := x.(time.Time)
+	ty, yIsTime := y.(time.Time)
+
+	switch {
+	case xIsTime && yIsTime && op == "-":
+		return tx.Sub(ty).Hours() / 24, true, nil
+	case xIsTime && !yIsTime:
+		days, err := toNumber(y)
+		if err != nil {
+			return nil, true, err
+		}
+		if op == "-" {
+			days = -days
+		}
+		return addDays(tx, days), true, nil
+	case yIsTime && !xIsTime && op == "+":
+		days, err := toNumber(x)
+		if err != nil {
+			return nil, true, err
+		}
+		return addDays(ty, days), true, nil
+	}
+
+	return nil, false, nil
+}
+
+func addDays(t time.Time, days float64) time.Time {
+	whole := math.Trunc(days)
+	return t.AddDate(0, 0, int(whole)).Add(time.Duration((days - whole) * float64(24*time.Hour)))
+}
+
+func comparison(op string, c int) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "<>":
+		return c != 0
+	case "<":
+		return c < 0
+	case ">":
+		return c > 0
+	case "<=":
+		return c <= 0
+	}
+	return c >= 0
+}
+
+// compare orders numbers numerically, dates chronologically and anything
+// else as case-insensitive text.
+func compare(x, y any) int {
+	if tx, ok := x.(time.Time); ok {
+		if ty, err := toTime(y); err == nil {
+			return tx.Compare(ty)
+		}
+	}
+	if ty, ok := y.(time.Time); ok {
+		if tx, err := toTime(x); err == nil {
+			return tx.Compare(ty)
+		}
+	}
+
+	if a, ok := numeric(x); ok {
+		if b, ok := numeric(y); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(strings.ToLower(toText(x)), strings.ToLower(toText(y)))
+}
+
+func (n *call) eval(s *state) (any, error) {
+	if err := s.step(); err != nil {
+		return nil, err
+	}
+
+	var (
+		result any
+		err    error
+	)
+
+	if n.fn.lazy != nil {
+		result, err = n.fn.lazy(s, n.args)
+	} else {
+		args := make([]any, len(n.args))
+		for i, arg := range n.args {
+			if args[i], err = arg.eval(s); err != nil {
+				return nil, err
+			}
+		}
+		result, err = n.fn.eager(s, args)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, s.account(result)
+}
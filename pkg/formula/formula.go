@@ -0,0 +1,247 @@
+// Package formula evaluates FORMULA_FRONTEND field formulas in process. The
+// language follows the spreadsheet syntax the formulas were written for:
+// arithmetic, comparisons, & concatenation, IF and friends, text and date
+// functions, and bare identifiers that refer to fields of the record.
+//
+// Formulas are compiled once and cached by their source. Evaluation runs in a
+// sandbox bounded by a deadline, a step budget and a memory budget for the
+// strings and lists it builds.
+package formula
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Spreadsheet error codes. A formula that fails at runtime evaluates to one
+// of them, the same way the previous JavaScript evaluator reported failures.
+const (
+	ErrSyntax = "#ERROR!"
+	ErrDiv0   = "#DIV/0!"
+	ErrName   = "#NAME?"
+	ErrNA     = "#N/A"
+	ErrNum    = "#NUM!"
+	ErrValue  = "#VALUE!"
+)
+
+var (
+	ErrTimeout     = errors.New("formula: evaluation timed out")
+	ErrStepLimit   = errors.New("formula: evaluation step limit exceeded")
+	ErrMemoryLimit = errors.New("formula: evaluation memory limit exceeded")
+)
+
+// Error is a formula error carrying its spreadsheet code.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + " " + e.Message
+}
+
+func syntaxError(pos int, format string, args ...any) *Error {
+	return &Error{Code: ErrSyntax, Message: fmt.Sprintf("at %d: ", pos) + fmt.Sprintf(format, args...)}
+}
+
+func valueError(format string, args ...any) *Error {
+	return &Error{Code: ErrValue, Message: fmt.Sprintf(format, args...)}
+}
+
+// Limits bound a single evaluation. MaxMemory counts the bytes of strings and
+// lists produced while evaluating.
+type Limits struct {
+	Timeout   time.Duration
+	MaxSteps  int
+	MaxMemory int
+}
+
+var (
+	DefaultLimits = Limits{
+		Timeout:   100 * time.Millisecond,
+		MaxSteps:  100000,
+		MaxMemory: 1 << 20,
+	}
+
+	limitsMu sync.RWMutex
+	limits   = DefaultLimits
+)
+
+// SetLimits replaces the limits used by Eval. Zero fields keep their default.
+func SetLimits(l Limits) {
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultLimits.Timeout
+	}
+	if l.MaxSteps <= 0 {
+		l.MaxSteps = DefaultLimits.MaxSteps
+	}
+	if l.MaxMemory <= 0 {
+		l.MaxMemory = DefaultLimits.MaxMemory
+	}
+
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+
+	limits = l
+}
+
+func currentLimits() Limits {
+	limitsMu.RLock()
+	defer limitsMu.RUnlock()
+
+	return limits
+}
+
+// Program is a compiled formula. It is safe for concurrent use.
+type Program struct {
+	source string
+	root   node
+	refs   []string
+}
+
+// Compile parses a formula. Syntax errors, unknown functions and wrong
+// argument counts are reported here rather than during evaluation.
+func Compile(src string) (*Program, error) {
+	root, refs, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	program := &Program{source: src, root: root, refs: make([]string, 0, len(refs))}
+	for ref := range refs {
+		program.refs = append(program.refs, ref)
+	}
+	sort.Strings(program.refs)
+
+	return program, nil
+}
+
+// maxPrograms caps the compiled program cache. Formulas belong to fields, so
+// the set is small; if it overflows the cache simply starts over.
+const maxPrograms = 4096
+
+type compiled struct {
+	program *Program
+	err     error
+}
+
+var (
+	programsMu sync.RWMutex
+	programs   = make(map[string]compiled)
+)
+
+// CompileCached is Compile with the result, including a failure, cached by
+// source, so bulk evaluation parses each field formula once.
+func CompileCached(src string) (*Program, error) {
+	programsMu.RLock()
+	c, ok := programs[src]
+	programsMu.RUnlock()
+	if ok {
+		return c.program, c.err
+	}
+
+	program, err := Compile(src)
+
+	programsMu.Lock()
+	defer programsMu.Unlock()
+
+	if len(programs) >= maxPrograms {
+		programs = make(map[string]compiled)
+	}
+	programs[src] = compiled{program: program, err: err}
+
+	return program, err
+}
+
+// Source returns the formula text.
+func (p *Program) Source() string {
+	return p.source
+}
+
+// References returns the identifiers the formula reads, sorted.
+func (p *Program) References() []string {
+	return p.refs
+}
+
+// Eval evaluates the formula against vars. Identifiers missing from vars
+// evaluate to #NAME?. Failures of the formula itself are returned as *Error;
+// exceeding a limit returns ErrTimeout, ErrStepLimit or ErrMemoryLimit.
+func (p *Program) Eval(ctx context.Context, vars map[string]any) (any, error) {
+	l := currentLimits()
+
+	ctx, cancel := context.WithTimeout(ctx, l.Timeout)
+	defer cancel()
+
+	s := &state{ctx: ctx, vars: vars, limits: l, now: time.Now().UTC()}
+	return p.root.eval(s)
+}
+
+// Evaluate compiles src through the cache, evaluates it and formats the
+// result. Formula errors are returned as their code, like a spreadsheet cell
+// shows them; only limit violations are returned as errors.
+func Evaluate(ctx context.Context, src string, vars map[string]any) (string, error) {
+	program, err := CompileCached(src)
+	if err == nil {
+		var value any
+		if value, err = program.Eval(ctx, vars); err == nil {
+			return Format(value), nil
+		}
+	}
+
+	var formulaErr *Error
+	if errors.As(err, &formulaErr) {
+		return formulaErr.Code, nil
+	}
+	return "", err
+}
+
+type state struct {
+	ctx    context.Context
+	vars   map[string]any
+	limits Limits
+	steps  int
+	memory int
+	now    time.Time
+}
+
+func (s *state) step() error {
+	s.steps++
+	if s.steps > s.limits.MaxSteps {
+		return ErrStepLimit
+	}
+	if s.steps%64 == 0 && s.ctx.Err() != nil {
+		return ErrTimeout
+	}
+	return nil
+}
+
+// reserve checks that n more bytes fit in the memory budget before a
+// function builds a value that large.
+func (s *state) reserve(n int) error {
+	if n < 0 || s.memory+n > s.limits.MaxMemory {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+func (s *state) account(value any) error {
+	switch v := value.(type) {
+	case string:
+		s.memory += len(v)
+	case []any:
+		s.memory += 16 * len(v)
+	default:
+		return nil
+	}
+	if s.memory > s.limits.MaxMemory {
+		return ErrMemoryLimit
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package formula_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/formula"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluate(t *testing.T) {
+	vars := map[string]any{
+		"price":      12.5,
+		"quantity":   int64(4),
+		"name":       "john smith",
+		"status":     "done",
+		"created_at": "2024-01-31T10:00:00Z",
+		"tags":       []any{1.0, "2", "x"},
+		"empty":      nil,
+	}
+
+	tests := []struct {
+		formula string
+		want    string
+	}{
+		{"price * quantity", "50"},
+		{"(1 + 2) * 3 - 4 / 2", "7"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"2 ^ 10", "1024"},
+		{"50%", "0.5"},
+		{"-price", "-12.5"},
+		{`IF(status = "DONE", "closed", "open")`, "closed"},
+		{`IF(quantity > 10, "bulk")`, "false"},
+		{`UPPER(LEFT(name, 4)) & "-" & LEN(name)`, "JOHN-10"},
+		{`PROPER(name)`, "John Smith"},
+		{`CONCATENATE("a", 1, TRUE)`, "a1true"},
+		{`SUBSTITUTE("a-b-c", "-", "+")`, "a+b+c"},
+		{`MID("formula", 2, 3)`, "orm"},
+		{`ROUND(price / 3, 2)`, "4.17"},
+		{`SUM(tags, 4)`, "7"},
+		{`AVERAGE(1, 2, 3, 4)`, "2.5"},
+		{`AND(price > 10, quantity = 4)`, "true"},
+		{`YEAR(created_at) & "/" & MONTH(created_at)`, "2024/1"},
+		{`EDATE(created_at, 1)`, "2024-02-29T00:00:00.000Z"},
+		{`DATE(2024, 3, 1) - DATE(2024, 2, 1)`, "29"},
+		{`DATEDIF("2020-05-10", "2024-05-09", "Y")`, "3"},
+		{`ISBLANK(empty)`, "true"},
+		{`1 / 0`, "#DIV/0!"},
+		{`IFERROR(1 / 0, "none")`, "none"},
+		{`VALUE("abc")`, "#VALUE!"},
+		{`unknown_field + 1`, "#NAME?"},
+		{`NOSUCH(1)`, "#NAME?"},
+		{`(1 + 2`, "#ERROR!"},
+	}
+
+	for _, tt := range tests {
+		got, err := formula.Evaluate(context.Background(), tt.formula, vars)
+		assert.NoError(t, err, tt.formula)
+		assert.Equal(t, tt.want, got, tt.formula)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	program, err := formula.Compile(`IF(price > 0, price * quantity, discount)`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"discount", "price", "quantity"}, program.References())
+
+	_, err = formula.Compile(`IF(price > 0)`)
+	assert.ErrorContains(t, err, "wrong number of arguments")
+
+	_, err = formula.Compile(`price * `)
+	assert.ErrorContains(t, err, "unexpected end of formula")
+
+	_, err = formula.Compile(strings.Repeat("(", 200) + "1" + strings.Repeat(")", 200))
+	assert.ErrorContains(t, err, "nested too deeply")
+
+	cached, err := formula.CompileCached("1 + 1")
+	assert.NoError(t, err)
+	again, _ := formula.CompileCached("1 + 1")
+	assert.Same(t, cached, again)
+}
+
+func TestSQL(t *testing.T) {
+	column := func(name string) (string, error) {
+		if name == "missing" {
+			return "", errors.New("unknown field missing")
+		}
+		return `a."` + name + `"`, nil
+	}
+
+	tests := []struct {
+		formula string
+		want    string
+	}{
+		{"price * quantity", `((a."price")::NUMERIC * (a."quantity")::NUMERIC)`},
+		{"total / count", `((a."total")::NUMERIC / NULLIF((a."count")::NUMERIC, 0))`},
+		{`first_name & " " & last_name`, `CONCAT((CONCAT((a."first_name")::TEXT, (' ')::TEXT))::TEXT, (a."last_name")::TEXT)`},
+		{`IF(status = "done", 1, 0)`, `CASE WHEN (a."status" = 'done') THEN 1 ELSE 0 END`},
+		{`ROUND(price, 2)`, `ROUND((a."price")::NUMERIC, (2)::INT)`},
+		{`YEAR(created_at)`, `EXTRACT(YEAR FROM (a."created_at")::TIMESTAMP)::INT`},
+		{`name = "it's"`, `(a."name" = 'it''s')`},
+	}
+
+	for _, tt := range tests {
+		program, err := formula.Compile(tt.formula)
+		assert.NoError(t, err, tt.formula)
+		got, err := program.SQL(column)
+		assert.NoError(t, err, tt.formula)
+		assert.Equal(t, tt.want, got, tt.formula)
+	}
+
+	program, _ := formula.Compile(`DATEDIF(created_at, NOW(), "Y")`)
+	_, err := program.SQL(column)
+	assert.ErrorContains(t, err, "DATEDIF cannot be computed in a query")
+
+	program, _ = formula.Compile(`missing + 1`)
+	_, err = program.SQL(column)
+	assert.ErrorContains(t, err, "unknown field missing")
+}
+
+func TestLimits(t *testing.T) {
+	defer formula.SetLimits(formula.DefaultLimits)
+	formula.SetLimits(formula.Limits{MaxMemory: 1024})
+
+	_, err := formula.Evaluate(context.Background(), `REPT("abc", 1000)`, nil)
+	assert.ErrorIs(t, err, formula.ErrMemoryLimit)
+
+	_, err = formula.Evaluate(context.Background(), `IFERROR(REPT("abc", 1000), "")`, nil)
+	assert.ErrorIs(t, err, formula.ErrMemoryLimit)
+
+	formula.SetLimits(formula.Limits{MaxSteps: 10})
+	_, err = formula.Evaluate(context.Background(), `1+1+1+1+1+1+1+1+1+1+1`, nil)
+	assert.ErrorIs(t, err, formula.ErrStepLimit)
+}
+
+func TestRoundDecimal(t *testing.T) {
+	tests := []struct {
+		value  string
+		places int
+		mode   string
+		want   string
+	}{
+		{"1234567890.125", 2, "", "1234567890.13"},
+		{"-2.5", 0, formula.RoundHalfUp, "-3"},
+		{"2.5", 0, formula.RoundHalfEven, "2"},
+		{"3.5", 0, formula.RoundHalfEven, "4"},
+		{"1.001", 2, formula.RoundUp, "1.01"},
+		{"-1.009", 2, formula.RoundDown, "-1.00"},
+		{"-1.001", 2, formula.RoundCeiling, "-1.00"},
+		{"-1.001", 2, formula.RoundFloor, "-1.01"},
+		{"1250", -2, "", "1300"},
+		{"0.1", 3, "", "0.100"},
+	}
+
+	for _, tt := range tests {
+		got, err := formula.RoundDecimal(tt.value, tt.places, tt.mode)
+		assert.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+
+	_, err := formula.RoundDecimal("abc", 2, "")
+	assert.Error(t, err)
+	_, err = formula.RoundDecimal("1.5", 0, "sideways")
+	assert.Error(t, err)
+}
+
+func TestGraph(t *testing.T) {
+	var (
+		amount   = formula.Node{Table: "order_item", Field: "amount"}
+		total    = formula.Node{Table: "order", Field: "total"}
+		withTax  = formula.Node{Table: "order", Field: "total_with_tax"}
+		lifetime = formula.Node{Table: "customer", Field: "lifetime_value"}
+	)
+
+	g := formula.NewGraph()
+	g.AddEdge(withTax, lifetime)
+	g.AddEdge(total, withTax)
+	g.AddEdge(amount, total)
+
+	order, err := g.Sort()
+	assert.NoError(t, err)
+	assert.Equal(t, []formula.Node{amount, total, withTax, lifetime}, order)
+	assert.Equal(t, []formula.Node{withTax}, g.Dependents(total))
+
+	g.AddEdge(lifetime, total)
+	_, err = g.Sort()
+	assert.EqualError(t, err, "formula dependency cycle: customer.lifetime_value -> order.total -> order.total_with_tax -> customer.lifetime_value")
+}
@@ -0,0 +1,726 @@
+package formula
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type function struct {
+	min, max int
+	eager    func(s *state, args []any) (any, error)
+	lazy     func(s *state, args []node) (any, error)
+}
+
+func eager(min, max int, fn func(s *state, args []any) (any, error)) *function {
+	return &function{min: min, max: max, eager: fn}
+}
+
+func lazy(min, max int, fn func(s *state, args []node) (any, error)) *function {
+	return &function{min: min, max: max, lazy: fn}
+}
+
+func math1(fn func(float64) float64) *function {
+	return eager(1, 1, func(_ *state, args []any) (any, error) {
+		x, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return number(fn(x))
+	})
+}
+
+func text1(fn func(string) string) *function {
+	return eager(1, 1, func(_ *state, args []any) (any, error) {
+		return fn(toText(args[0])), nil
+	})
+}
+
+func datePart(fn func(time.Time) int) *function {
+	return eager(1, 1, func(_ *state, args []any) (any, error) {
+		t, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return float64(fn(t)), nil
+	})
+}
+
+// functions are the builtins by upper-case name. max < 0 means variadic.
+var functions = map[string]*function{
+	// logical
+	"IF":      lazy(2, 3, fnIf),
+	"IFERROR": lazy(2, 2, fnIfError),
+	"ISERROR": lazy(1, 1, fnIsError),
+	"AND":     eager(1, -1, fnAnd),
+	"OR":      eager(1, -1, fnOr),
+	"XOR":     eager(1, -1, fnXor),
+	"NOT": eager(1, 1, func(_ *state, args []any) (any, error) {
+		b, err := toBool(args[0])
+		return !b, err
+	}),
+	"TRUE":  eager(0, 0, func(*state, []any) (any, error) { return true, nil }),
+	"FALSE": eager(0, 0, func(*state, []any) (any, error) { return false, nil }),
+	"ISBLANK": eager(1, 1, func(_ *state, args []any) (any, error) {
+		return args[0] == nil || args[0] == "", nil
+	}),
+	"ISNUMBER": eager(1, 1, func(_ *state, args []any) (any, error) {
+		_, ok := args[0].(float64)
+		return ok, nil
+	}),
+	"ISTEXT": eager(1, 1, func(_ *state, args []any) (any, error) {
+		_, ok := args[0].(string)
+		return ok, nil
+	}),
+
+	// math
+	"ABS":       math1(math.Abs),
+	"SQRT":      math1(math.Sqrt),
+	"EXP":       math1(math.Exp),
+	"LN":        math1(math.Log),
+	"LOG10":     math1(math.Log10),
+	"INT":       math1(math.Floor),
+	"SIGN":      math1(sign),
+	"PI":        eager(0, 0, func(*state, []any) (any, error) { return math.Pi, nil }),
+	"LOG":       eager(1, 2, fnLog),
+	"ROUND":     eager(1, 2, rounding(math.Round)),
+	"ROUNDUP":   eager(1, 2, rounding(roundUp)),
+	"ROUNDDOWN": eager(1, 2, rounding(math.Trunc)),
+	"TRUNC":     eager(1, 2, rounding(math.Trunc)),
+	"CEILING":   eager(1, 2, multiple(math.Ceil)),
+	"FLOOR":     eager(1, 2, multiple(math.Floor)),
+	"MOD":       eager(2, 2, fnMod),
+	"POWER": eager(2, 2, func(_ *state, args []any) (any, error) {
+		a, b, err := twoNumbers(args)
+		if err != nil {
+			return nil, err
+		}
+		return number(math.Pow(a, b))
+	}),
+	"SUM":     eager(0, -1, aggregate(func(n []float64) (any, error) { return sum(n), nil })),
+	"PRODUCT": eager(0, -1, aggregate(product)),
+	"AVERAGE": eager(1, -1, aggregate(average)),
+	"MIN":     eager(0, -1, aggregate(minimum)),
+	"MAX":     eager(0, -1, aggregate(maximum)),
+	"COUNT":   eager(0, -1, aggregate(func(n []float64) (any, error) { return float64(len(n)), nil })),
+	"COUNTA":  eager(0, -1, fnCountA),
+
+	// text
+	"CONCATENATE": eager(1, -1, fnConcat),
+	"CONCAT":      eager(1, -1, fnConcat),
+	"TEXTJOIN":    eager(3, -1, fnTextJoin),
+	"LEN": eager(1, 1, func(_ *state, args []any) (any, error) {
+		return float64(len([]rune(toText(args[0])))), nil
+	}),
+	"LOWER":      text1(strings.ToLower),
+	"UPPER":      text1(strings.ToUpper),
+	"PROPER":     text1(proper),
+	"TRIM":       text1(func(s string) string { return strings.Join(strings.Fields(s), " ") }),
+	"LEFT":       eager(1, 2, fnLeft),
+	"RIGHT":      eager(1, 2, fnRight),
+	"MID":        eager(3, 3, fnMid),
+	"FIND":       eager(2, 3, finder(false)),
+	"SEARCH":     eager(2, 3, finder(true)),
+	"SUBSTITUTE": eager(3, 4, fnSubstitute),
+	"REPLACE":    eager(4, 4, fnReplace),
+	"REPT":       eager(2, 2, fnRept),
+	"VALUE": eager(1, 1, func(_ *state, args []any) (any, error) {
+		return toNumber(args[0])
+	}),
+	"EXACT": eager(2, 2, func(_ *state, args []any) (any, error) {
+		return toText(args[0]) == toText(args[1]), nil
+	}),
+
+	// date
+	"DATE": eager(3, 3, fnDate),
+	"TODAY": eager(0, 0, func(s *state, _ []any) (any, error) {
+		return s.now.Truncate(24 * time.Hour), nil
+	}),
+	"NOW": eager(0, 0, func(s *state, _ []any) (any, error) {
+		return s.now, nil
+	}),
+	"YEAR":    datePart(time.Time.Year),
+	"MONTH":   datePart(func(t time.Time) int { return int(t.Month()) }),
+	"DAY":     datePart(time.Time.Day),
+	"HOUR":    datePart(time.Time.Hour),
+	"MINUTE":  datePart(time.Time.Minute),
+	"SECOND":  datePart(time.Time.Second),
+	"WEEKDAY": eager(1, 2, fnWeekday),
+	"DAYS":    eager(2, 2, fnDays),
+	"DATEDIF": eager(3, 3, fnDateDif),
+	"EDATE":   eager(2, 2, monthShift(false)),
+	"EOMONTH": eager(2, 2, monthShift(true)),
+}
+
+func fnIf(s *state, args []node) (any, error) {
+	cond, err := args[0].eval(s)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := toBool(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case ok:
+		return args[1].eval(s)
+	case len(args) == 3:
+		return args[2].eval(s)
+	}
+	return false, nil
+}
+
+// fnIfError only catches formula errors; running out of time or memory
+// still aborts the evaluation.
+func fnIfError(s *state, args []node) (any, error) {
+	value, err := args[0].eval(s)
+
+	var formulaErr *Error
+	if errors.As(err, &formulaErr) {
+		return args[1].eval(s)
+	}
+	return value, err
+}
+
+func fnIsError(s *state, args []node) (any, error) {
+	_, err := args[0].eval(s)
+
+	var formulaErr *Error
+	if errors.As(err, &formulaErr) {
+		return true, nil
+	}
+	return false, err
+}
+
+func bools(args []any) ([]bool, error) {
+	values := flatten(args)
+	result := make([]bool, len(values))
+	for i, v := range values {
+		b, err := toBool(v)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = b
+	}
+	return result, nil
+}
+
+func fnAnd(_ *state, args []any) (any, error) {
+	values, err := bools(args)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range values {
+		if !b {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func fnOr(_ *state, args []any) (any, error) {
+	values, err := bools(args)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range values {
+		if b {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func fnXor(_ *state, args []any) (any, error) {
+	values, err := bools(args)
+	if err != nil {
+		return nil, err
+	}
+	result := false
+	for _, b := range values {
+		result = result != b
+	}
+	return result, nil
+}
+
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+func twoNumbers(args []any) (float64, float64, error) {
+	a, err := toNumber(args[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := toNumber(args[1])
+	return a, b, err
+}
+
+func fnLog(_ *state, args []any) (any, error) {
+	x, err := toNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
+	base := 10.0
+	if len(args) == 2 {
+		if base, err = toNumber(args[1]); err != nil {
+			return nil, err
+		}
+	}
+	if x <= 0 || base <= 0 || base == 1 {
+		return nil, &Error{Code: ErrNum}
+	}
+	return math.Log(x) / math.Log(base), nil
+}
+
+func roundUp(x float64) float64 {
+	if x < 0 {
+		return math.Floor(x)
+	}
+	return math.Ceil(x)
+}
+
+// rounding builds ROUND-like functions taking an optional number of digits,
+// which may be negative to round to tens, hundreds and so on.
+func rounding(fn func(float64) float64) func(*state, []any) (any, error) {
+	return func(_ *state, args []any) (any, error) {
+		x, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		digits := 0
+		if len(args) == 2 {
+			if digits, err = toInt(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		scale := math.Pow(10, float64(digits))
+		return number(fn(x*scale) / scale)
+	}
+}
+
+func multiple(fn func(float64) float64) func(*state, []any) (any, error) {
+	return func(_ *state, args []any) (any, error) {
+		x, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		significance := 1.0
+		if len(args) == 2 {
+			if significance, err = toNumber(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		if significance == 0 {
+			return 0.0, nil
+		}
+		return number(fn(x/significance) * significance)
+	}
+}
+
+func fnMod(_ *state, args []any) (any, error) {
+	a, b, err := twoNumbers(args)
+	if err != nil {
+		return nil, err
+	}
+	if b == 0 {
+		return nil, &Error{Code: ErrDiv0}
+	}
+	return a - b*math.Floor(a/b), nil
+}
+
+// aggregate coerces direct arguments to numbers and skips non-numeric
+// values inside lists, like spreadsheet ranges.
+func aggregate(fn func([]float64) (any, error)) func(*state, []any) (any, error) {
+	return func(_ *state, args []any) (any, error) {
+		var numbers []float64
+		for _, arg := range args {
+			if list, ok := arg.([]any); ok {
+				for _, v := range flatten(list) {
+					if f, ok := numeric(v); ok && v != nil {
+						numbers = append(numbers, f)
+					}
+				}
+				continue
+			}
+			if arg == nil {
+				continue
+			}
+			f, err := toNumber(arg)
+			if err != nil {
+				return nil, err
+			}
+			numbers = append(numbers, f)
+		}
+		return fn(numbers)
+	}
+}
+
+func sum(numbers []float64) float64 {
+	var total float64
+	for _, n := range numbers {
+		total += n
+	}
+	return total
+}
+
+func product(numbers []float64) (any, error) {
+	if len(numbers) == 0 {
+		return 0.0, nil
+	}
+	result := 1.0
+	for _, n := range numbers {
+		result *= n
+	}
+	return number(result)
+}
+
+func average(numbers []float64) (any, error) {
+	if len(numbers) == 0 {
+		return nil, &Error{Code: ErrDiv0}
+	}
+	return sum(numbers) / float64(len(numbers)), nil
+}
+
+func minimum(numbers []float64) (any, error) {
+	if len(numbers) == 0 {
+		return 0.0, nil
+	}
+	result := numbers[0]
+	for _, n := range numbers[1:] {
+		result = math.Min(result, n)
+	}
+	return result, nil
+}
+
+func maximum(numbers []float64) (any, error) {
+	if len(numbers) == 0 {
+		return 0.0, nil
+	}
+	result := numbers[0]
+	for _, n := range numbers[1:] {
+		result = math.Max(result, n)
+	}
+	return result, nil
+}
+
+func fnCountA(_ *state, args []any) (any, error) {
+	count := 0
+	for _, v := range flatten(args) {
+		if v != nil && v != "" {
+			count++
+		}
+	}
+	return float64(count), nil
+}
+
+func fnConcat(s *state, args []any) (any, error) {
+	var sb strings.Builder
+	for _, v := range flatten(args) {
+		text := toText(v)
+		if err := s.reserve(sb.Len() + len(text)); err != nil {
+			return nil, err
+		}
+		sb.WriteString(text)
+	}
+	return sb.String(), nil
+}
+
+func fnTextJoin(s *state, args []any) (any, error) {
+	delimiter := toText(args[0])
+	ignoreEmpty, err := toBool(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []string
+	size := 0
+	for _, v := range flatten(args[2:]) {
+		text := toText(v)
+		if ignoreEmpty && text == "" {
+			continue
+		}
+		size += len(text) + len(delimiter)
+		if err := s.reserve(size); err != nil {
+			return nil, err
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, delimiter), nil
+}
+
+func proper(s string) string {
+	var (
+		sb    strings.Builder
+		start = true
+	)
+	for _, r := range s {
+		if start {
+			sb.WriteRune(unicode.ToUpper(r))
+		} else {
+			sb.WriteRune(unicode.ToLower(r))
+		}
+		start = !unicode.IsLetter(r)
+	}
+	return sb.String()
+}
+
+func count(args []any, i int) (int, error) {
+	if len(args) <= i {
+		return 1, nil
+	}
+	n, err := toInt(args[i])
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, valueError("count must not be negative")
+	}
+	return n, nil
+}
+
+func fnLeft(_ *state, args []any) (any, error) {
+	text := []rune(toText(args[0]))
+	n, err := count(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return string(text[:min(n, len(text))]), nil
+}
+
+func fnRight(_ *state, args []any) (any, error) {
+	text := []rune(toText(args[0]))
+	n, err := count(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return string(text[len(text)-min(n, len(text)):]), nil
+}
+
+func fnMid(_ *state, args []any) (any, error) {
+	text := []rune(toText(args[0]))
+	start, err := toInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	n, err := count(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if start < 1 {
+		return nil, valueError("start must be at least 1")
+	}
+	if start > len(text) {
+		return "", nil
+	}
+	return string(text[start-1 : min(start-1+n, len(text))]), nil
+}
+
+func finder(caseInsensitive bool) func(*state, []any) (any, error) {
+	return func(_ *state, args []any) (any, error) {
+		needle, haystack := []rune(toText(args[0])), []rune(toText(args[1]))
+		start := 1
+		if len(args) == 3 {
+			var err error
+			if start, err = toInt(args[2]); err != nil {
+				return nil, err
+			}
+		}
+		if start < 1 || start > len(haystack)+1 {
+			return nil, valueError("start is out of range")
+		}
+
+		n, h := string(needle), string(haystack[start-1:])
+		if caseInsensitive {
+			n, h = strings.ToLower(n), strings.ToLower(h)
+		}
+		i := strings.Index(h, n)
+		if i < 0 {
+			return nil, valueError("%q not found", string(needle))
+		}
+		return float64(start + len([]rune(h[:i]))), nil
+	}
+}
+
+func fnSubstitute(s *state, args []any) (any, error) {
+	text, old, replacement := toText(args[0]), toText(args[1]), toText(args[2])
+	if old == "" {
+		return text, nil
+	}
+
+	if len(args) == 4 {
+		instance, err := toInt(args[3])
+		if err != nil {
+			return nil, err
+		}
+		if instance < 1 {
+			return nil, valueError("instance must be at least 1")
+		}
+		offset := 0
+		for i := 1; ; i++ {
+			j := strings.Index(text[offset:], old)
+			if j < 0 {
+				return text, nil
+			}
+			if i == instance {
+				at := offset + j
+				return text[:at] + replacement + text[at+len(old):], nil
+			}
+			offset += j + len(old)
+		}
+	}
+
+	if err := s.reserve(len(text) + strings.Count(text, old)*(len(replacement)-len(old))); err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(text, old, replacement), nil
+}
+
+func fnReplace(_ *state, args []any) (any, error) {
+	text := []rune(toText(args[0]))
+	start, err := toInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	n, err := count(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if start < 1 {
+		return nil, valueError("start must be at least 1")
+	}
+
+	from := min(start-1, len(text))
+	to := min(from+n, len(text))
+	return string(text[:from]) + toText(args[3]) + string(text[to:]), nil
+}
+
+func fnRept(s *state, args []any) (any, error) {
+	text := toText(args[0])
+	n, err := count(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(text) > 0 && n > s.limits.MaxMemory/len(text) {
+		return nil, ErrMemoryLimit
+	}
+	if err := s.reserve(len(text) * n); err != nil {
+		return nil, err
+	}
+	return strings.Repeat(text, n), nil
+}
+
+func fnDate(_ *state, args []any) (any, error) {
+	var parts [3]int
+	for i := range parts {
+		n, err := toInt(args[i])
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = n
+	}
+	return time.Date(parts[0], time.Month(parts[1]), parts[2], 0, 0, 0, 0, time.UTC), nil
+}
+
+// fnWeekday numbers days from Sunday = 1 by default; type 2 starts the week
+// on Monday = 1 and type 3 on Monday = 0.
+func fnWeekday(_ *state, args []any) (any, error) {
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	kind := 1
+	if len(args) == 2 {
+		if kind, err = toInt(args[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	day := int(t.Weekday())
+	switch kind {
+	case 1:
+		return float64(day + 1), nil
+	case 2:
+		return float64((day+6)%7 + 1), nil
+	case 3:
+		return float64((day + 6) % 7), nil
+	}
+	return nil, &Error{Code: ErrNum}
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func twoDates(args []any) (time.Time, time.Time, error) {
+	a, err := toTime(args[0])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	b, err := toTime(args[1])
+	return dateOnly(a), dateOnly(b), err
+}
+
+func fnDays(_ *state, args []any) (any, error) {
+	end, start, err := twoDates(args)
+	if err != nil {
+		return nil, err
+	}
+	return math.Round(end.Sub(start).Hours() / 24), nil
+}
+
+func fnDateDif(_ *state, args []any) (any, error) {
+	start, end, err := twoDates(args)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, &Error{Code: ErrNum}
+	}
+
+	months := (end.Year()-start.Year())*12 + int(end.Month()-start.Month())
+	if end.Day() < start.Day() {
+		months--
+	}
+
+	switch strings.ToUpper(toText(args[2])) {
+	case "Y":
+		return float64(months / 12), nil
+	case "M":
+		return float64(months), nil
+	case "D":
+		return math.Round(end.Sub(start).Hours() / 24), nil
+	}
+	return nil, &Error{Code: ErrNum}
+}
+
+// monthShift builds EDATE and EOMONTH. Like spreadsheets, EDATE clamps the
+// day to the end of a shorter month instead of rolling over.
+func monthShift(endOfMonth bool) func(*state, []any) (any, error) {
+	return func(_ *state, args []any) (any, error) {
+		t, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		months, err := toInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+		last := first.AddDate(0, 1, -1)
+		if endOfMonth {
+			return last, nil
+		}
+		return first.AddDate(0, 0, min(t.Day(), last.Day())-1), nil
+	}
+}
@@ -0,0 +1,177 @@
+package formula
+
+import (
+	"sort"
+	"strings"
+)
+
+// Node is a field of a table in the dependency graph.
+type Node struct {
+	Table string
+	Field string
+}
+
+func (n Node) String() string {
+	return n.Table + "." + n.Field
+}
+
+// Graph records which fields are computed from which. An edge from a field
+// to a formula means the formula reads the field.
+type Graph struct {
+	nodes map[Node]struct{}
+	edges map[Node]map[Node]struct{}
+}
+
+func NewGraph() *Graph {
+	return &Graph{
+		nodes: make(map[Node]struct{}),
+		edges: make(map[Node]map[Node]struct{}),
+	}
+}
+
+func (g *Graph) AddNode(n Node) {
+	g.nodes[n] = struct{}{}
+}
+
+// AddEdge records that to is computed from from.
+func (g *Graph) AddEdge(from, to Node) {
+	g.AddNode(from)
+	g.AddNode(to)
+
+	if g.edges[from] == nil {
+		g.edges[from] = make(map[Node]struct{})
+	}
+	g.edges[from][to] = struct{}{}
+}
+
+// Dependents returns the nodes computed directly from n, sorted.
+func (g *Graph) Dependents(n Node) []Node {
+	return sortedNodes(g.edges[n])
+}
+
+// CycleError reports formulas that depend on themselves. Path starts and
+// ends with the same node.
+type CycleError struct {
+	Path []Node
+}
+
+func (e *CycleError) Error() string {
+	parts := make([]string, len(e.Path))
+	for i, n := range e.Path {
+		parts[i] = n.String()
+	}
+	return "formula dependency cycle: " + strings.Join(parts, " -> ")
+}
+
+// Sort returns every node after all the nodes it is computed from. Ties are
+// broken by name, so the order is stable. A cycle is returned as
+// *CycleError.
+func (g *Graph) Sort() ([]Node, error) {
+	indegree := make(map[Node]int, len(g.nodes))
+	for n := range g.nodes {
+		indegree[n] += 0
+		for to := range g.edges[n] {
+			indegree[to]++
+		}
+	}
+
+	var ready []Node
+	for n, d := range indegree {
+		if d == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	order := make([]Node, 0, len(g.nodes))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return less(ready[j], ready[i]) })
+		n := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		order = append(order, n)
+
+		for to := range g.edges[n] {
+			if indegree[to]--; indegree[to] == 0 {
+				ready = append(ready, to)
+			}
+		}
+	}
+
+	if len(order) < len(g.nodes) {
+		return nil, &CycleError{Path: g.cycle(indegree)}
+	}
+	return order, nil
+}
+
+// cycle finds a cycle among the nodes Sort could not place.
+func (g *Graph) cycle(indegree map[Node]int) []Node {
+	var remaining []Node
+	for n, d := range indegree {
+		if d > 0 {
+			remaining = append(remaining, n)
+		}
+	}
+	sort.Slice(remaining, func(i, j int) bool { return less(remaining[i], remaining[j]) })
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+
+	var (
+		state = make(map[Node]int)
+		stack []Node
+		found []Node
+		visit func(n Node) bool
+	)
+
+	visit = func(n Node) bool {
+		state[n] = visiting
+		stack = append(stack, n)
+
+		for _, to := range sortedNodes(g.edges[n]) {
+			if indegree[to] == 0 {
+				continue
+			}
+			switch state[to] {
+			case visiting:
+				for i := range stack {
+					if stack[i] == to {
+						found = append(append([]Node{}, stack[i:]...), to)
+						return true
+					}
+				}
+			case 0:
+				if visit(to) {
+					return true
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[n] = done
+		return false
+	}
+
+	for _, n := range remaining {
+		if state[n] == 0 && visit(n) {
+			return found
+		}
+	}
+	return remaining
+}
+
+func less(a, b Node) bool {
+	if a.Table != b.Table {
+		return a.Table < b.Table
+	}
+	return a.Field < b.Field
+}
+
+func sortedNodes(set map[Node]struct{}) []Node {
+	nodes := make([]Node, 0, len(set))
+	for n := range set {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return less(nodes[i], nodes[j]) })
+	return nodes
+}
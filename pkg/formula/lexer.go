@@ -0,0 +1,153 @@
+package formula
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOperator
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+var operators = []string{"<>", "<=", ">=", "+", "-", "*", "/", "^", "&", "%", "=", "<", ">"}
+
+func lex(src string) ([]token, error) {
+	var (
+		tokens []token
+		i      int
+	)
+
+	for i < len(src) {
+		c, size := utf8.DecodeRuneInString(src[i:])
+
+		switch {
+		case unicode.IsSpace(c):
+			i += size
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',' || c == ';':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			text, end, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: i})
+			i = end
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			end := lexNumber(src, i)
+			num, err := strconv.ParseFloat(src[i:end], 64)
+			if err != nil {
+				return nil, syntaxError(i, "invalid number %q", src[i:end])
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[i:end], num: num, pos: i})
+			i = end
+		case c == '_' || unicode.IsLetter(c):
+			end := i
+			for end < len(src) {
+				r, n := utf8.DecodeRuneInString(src[end:])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				end += n
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[i:end], pos: i})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, syntaxError(i, "unexpected character %q", c)
+			}
+			tokens = append(tokens, token{kind: tokOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString reads a quoted literal. A doubled quote stands for the quote
+// itself, as in spreadsheets; backslash escapes are accepted as well because
+// older formulas had field values substituted as JSON strings.
+func lexString(src string, start int) (string, int, error) {
+	var (
+		quote = src[start]
+		sb    strings.Builder
+	)
+
+	for i := start + 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote && i+1 < len(src) && src[i+1] == quote:
+			sb.WriteByte(quote)
+			i++
+		case c == quote:
+			return sb.String(), i + 1, nil
+		case c == '\\' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			default:
+				sb.WriteByte(src[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return "", 0, syntaxError(start, "unterminated string")
+}
+
+func lexNumber(src string, start int) int {
+	i := start
+	for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+		i++
+	}
+	if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+		j := i + 1
+		if j < len(src) && (src[j] == '+' || src[j] == '-') {
+			j++
+		}
+		if j < len(src) && src[j] >= '0' && src[j] <= '9' {
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			i = j
+		}
+	}
+	return i
+}
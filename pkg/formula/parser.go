@@ -0,0 +1,202 @@
+package formula
+
+import (
+	"strings"
+)
+
+// maxDepth bounds nesting so a hostile formula cannot exhaust the stack of
+// the parser or of the evaluator.
+const maxDepth = 128
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+	refs   map[string]struct{}
+}
+
+func parse(src string) (node, map[string]struct{}, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &parser{tokens: tokens, refs: map[string]struct{}{}}
+
+	root, err := p.expression()
+	if err != nil {
+		return nil, nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, nil, syntaxError(tok.pos, "unexpected %q", tok.text)
+	}
+
+	return root, p.refs, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) operator(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// expression parses comparisons, the lowest precedence level. Levels from
+// lowest to highest: comparison, &, + -, * /, ^, unary sign, postfix %.
+func (p *parser) expression() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, syntaxError(p.peek().pos, "formula is nested too deeply")
+	}
+
+	return p.binary(0)
+}
+
+var precedence = [][]string{
+	{"=", "<>", "<", ">", "<=", ">="},
+	{"&"},
+	{"+", "-"},
+	{"*", "/"},
+	{"^"},
+}
+
+func (p *parser) binary(level int) (node, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.operator(precedence[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, x: left, y: right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	if op, ok := p.operator("-", "+"); ok {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxDepth {
+			return nil, syntaxError(p.peek().pos, "formula is nested too deeply")
+		}
+
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: op, x: x}, nil
+	}
+
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.operator("%"); !ok {
+			return x, nil
+		}
+		x = &unary{op: "%", x: x}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokNumber:
+		return &literal{value: tok.num}, nil
+	case tokString:
+		return &literal{value: tok.text}, nil
+	case tokLParen:
+		x, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, syntaxError(closing.pos, "expected ')'")
+		}
+		return x, nil
+	case tokIdent:
+		if p.peek().kind == tokLParen {
+			p.next()
+			return p.call(tok)
+		}
+		switch strings.ToUpper(tok.text) {
+		case "TRUE":
+			return &literal{value: true}, nil
+		case "FALSE":
+			return &literal{value: false}, nil
+		}
+		p.refs[tok.text] = struct{}{}
+		return &reference{name: tok.text}, nil
+	case tokEOF:
+		return nil, syntaxError(tok.pos, "unexpected end of formula")
+	}
+
+	return nil, syntaxError(tok.pos, "unexpected %q", tok.text)
+}
+
+func (p *parser) call(name token) (node, error) {
+	fn, ok := functions[strings.ToUpper(name.text)]
+	if !ok {
+		return nil, &Error{Code: ErrName, Message: "unknown function " + name.text}
+	}
+
+	var args []node
+	if p.peek().kind == tokRParen {
+		p.next()
+	} else {
+		for {
+			arg, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			tok := p.next()
+			if tok.kind == tokRParen {
+				break
+			}
+			if tok.kind != tokComma {
+				return nil, syntaxError(tok.pos, "expected ',' or ')'")
+			}
+		}
+	}
+
+	if len(args) < fn.min || fn.max >= 0 && len(args) > fn.max {
+		return nil, &Error{Code: ErrNA, Message: "wrong number of arguments for " + strings.ToUpper(name.text)}
+	}
+
+	return &call{name: strings.ToUpper(name.text), fn: fn, args: args}, nil
+}
@@ -0,0 +1,184 @@
+package formula
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// sqlFunctions are the functions a formula can use when it is compiled to
+// SQL. Each one gets the already translated arguments.
+var sqlFunctions = map[string]func(args []string) string{
+	"IF": func(args []string) string {
+		if len(args) == 2 {
+			return fmt.Sprintf("CASE WHEN %s THEN %s END", args[0], args[1])
+		}
+		return fmt.Sprintf("CASE WHEN %s THEN %s ELSE %s END", args[0], args[1], args[2])
+	},
+	"AND":     func(args []string) string { return "(" + strings.Join(args, " AND ") + ")" },
+	"OR":      func(args []string) string { return "(" + strings.Join(args, " OR ") + ")" },
+	"NOT":     func(args []string) string { return "(NOT " + args[0] + ")" },
+	"ISBLANK": func(args []string) string { return fmt.Sprintf("(%s IS NULL OR (%s)::TEXT = '')", args[0], args[0]) },
+
+	"ABS":   sqlNumeric("ABS"),
+	"SQRT":  sqlNumeric("SQRT"),
+	"EXP":   sqlNumeric("EXP"),
+	"LN":    sqlNumeric("LN"),
+	"LOG10": sqlNumeric("LOG"),
+	"INT":   sqlNumeric("FLOOR"),
+	"SIGN":  sqlNumeric("SIGN"),
+	"ROUND": func(args []string) string {
+		if len(args) == 1 {
+			return fmt.Sprintf("ROUND(%s)", sqlCast(args[0]))
+		}
+		return fmt.Sprintf("ROUND(%s, (%s)::INT)", sqlCast(args[0]), args[1])
+	},
+	"CEILING": sqlNumeric("CEIL"),
+	"FLOOR":   sqlNumeric("FLOOR"),
+	"MOD":     func(args []string) string { return fmt.Sprintf("MOD(%s, %s)", sqlCast(args[0]), sqlCast(args[1])) },
+	"POWER":   func(args []string) string { return fmt.Sprintf("POWER(%s, %s)", sqlCast(args[0]), sqlCast(args[1])) },
+	"SUM": func(args []string) string {
+		for i, arg := range args {
+			args[i] = fmt.Sprintf("COALESCE(%s, 0)", sqlCast(arg))
+		}
+		return "(" + strings.Join(args, " + ") + ")"
+	},
+	"MIN": func(args []string) string { return "LEAST(" + strings.Join(sqlNumerics(args), ", ") + ")" },
+	"MAX": func(args []string) string { return "GREATEST(" + strings.Join(sqlNumerics(args), ", ") + ")" },
+
+	"CONCATENATE": sqlConcat,
+	"CONCAT":      sqlConcat,
+	"LEN":         func(args []string) string { return fmt.Sprintf("LENGTH((%s)::TEXT)", args[0]) },
+	"LOWER":       func(args []string) string { return fmt.Sprintf("LOWER((%s)::TEXT)", args[0]) },
+	"UPPER":       func(args []string) string { return fmt.Sprintf("UPPER((%s)::TEXT)", args[0]) },
+	"TRIM":        func(args []string) string { return fmt.Sprintf("BTRIM((%s)::TEXT)", args[0]) },
+	"LEFT":        sqlSide("LEFT"),
+	"RIGHT":       sqlSide("RIGHT"),
+	"MID": func(args []string) string {
+		return fmt.Sprintf("SUBSTRING((%s)::TEXT FROM (%s)::INT FOR (%s)::INT)", args[0], args[1], args[2])
+	},
+
+	"TODAY":  func([]string) string { return "CURRENT_DATE" },
+	"NOW":    func([]string) string { return "NOW()" },
+	"YEAR":   sqlDatePart("YEAR"),
+	"MONTH":  sqlDatePart("MONTH"),
+	"DAY":    sqlDatePart("DAY"),
+	"HOUR":   sqlDatePart("HOUR"),
+	"MINUTE": sqlDatePart("MINUTE"),
+	"SECOND": sqlDatePart("SECOND"),
+}
+
+func sqlCast(x string) string {
+	return "(" + x + ")::NUMERIC"
+}
+
+func sqlNumerics(args []string) []string {
+	for i, arg := range args {
+		args[i] = sqlCast(arg)
+	}
+	return args
+}
+
+func sqlNumeric(name string) func(args []string) string {
+	return func(args []string) string {
+		return fmt.Sprintf("%s(%s)", name, sqlCast(args[0]))
+	}
+}
+
+func sqlConcat(args []string) string {
+	for i, arg := range args {
+		args[i] = "(" + arg + ")::TEXT"
+	}
+	return "CONCAT(" + strings.Join(args, ", ") + ")"
+}
+
+func sqlSide(name string) func(args []string) string {
+	return func(args []string) string {
+		if len(args) == 1 {
+			return fmt.Sprintf("%s((%s)::TEXT, 1)", name, args[0])
+		}
+		return fmt.Sprintf("%s((%s)::TEXT, (%s)::INT)", name, args[0], args[1])
+	}
+}
+
+func sqlDatePart(part string) func(args []string) string {
+	return func(args []string) string {
+		return fmt.Sprintf("EXTRACT(%s FROM (%s)::TIMESTAMP)::INT", part, args[0])
+	}
+}
+
+// SQL translates the program into a PostgreSQL expression, so a database
+// can compute, sort and filter by it. column returns the SQL of a referenced
+// field. Only a subset of the functions has a SQL form; the others are an
+// error. Division by zero gives NULL instead of #DIV/0!.
+func (p *Program) SQL(column func(name string) (string, error)) (string, error) {
+	return toSQL(p.root, column)
+}
+
+func toSQL(n node, column func(name string) (string, error)) (string, error) {
+	switch n := n.(type) {
+	case *literal:
+		switch v := n.value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case string:
+			return pq.QuoteLiteral(v), nil
+		case bool:
+			return strings.ToUpper(strconv.FormatBool(v)), nil
+		}
+		return "", valueError("unsupported literal %v", n.value)
+	case *reference:
+		return column(n.name)
+	case *unary:
+		x, err := toSQL(n.x, column)
+		if err != nil {
+			return "", err
+		}
+		switch n.op {
+		case "-":
+			return "(-" + sqlCast(x) + ")", nil
+		case "%":
+			return "(" + sqlCast(x) + " / 100)", nil
+		}
+		return x, nil
+	case *binary:
+		x, err := toSQL(n.x, column)
+		if err != nil {
+			return "", err
+		}
+		y, err := toSQL(n.y, column)
+		if err != nil {
+			return "", err
+		}
+		switch n.op {
+		case "&":
+			return sqlConcat([]string{x, y}), nil
+		case "+", "-", "*":
+			return fmt.Sprintf("(%s %s %s)", sqlCast(x), n.op, sqlCast(y)), nil
+		case "/":
+			return fmt.Sprintf("(%s / NULLIF(%s, 0))", sqlCast(x), sqlCast(y)), nil
+		case "^":
+			return fmt.Sprintf("POWER(%s, %s)", sqlCast(x), sqlCast(y)), nil
+		}
+		return fmt.Sprintf("(%s %s %s)", x, n.op, y), nil
+	case *call:
+		name := strings.ToUpper(n.name)
+		fn, ok := sqlFunctions[name]
+		if !ok {
+			return "", &Error{Code: ErrName, Message: name + " cannot be computed in a query"}
+		}
+		args := make([]string, len(n.args))
+		for i, arg := range n.args {
+			x, err := toSQL(arg, column)
+			if err != nil {
+				return "", err
+			}
+			args[i] = x
+		}
+		return fn(args), nil
+	}
+
+	return "", valueError("unsupported expression")
+}
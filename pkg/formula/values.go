@@ -0,0 +1,207 @@
+package formula
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values are nil, float64, string, bool, time.Time or []any.
+
+const jsDateLayout = "2006-01-02T15:04:05.000Z"
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02.01.2006 15:04",
+	"02.01.2006",
+}
+
+func normalize(value any) any {
+	switch v := value.(type) {
+	case nil, float64, string, bool, time.Time:
+		return v
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []any:
+		list := make([]any, len(v))
+		for i := range v {
+			list[i] = normalize(v[i])
+		}
+		return list
+	case []string:
+		list := make([]any, len(v))
+		for i := range v {
+			list[i] = v[i]
+		}
+		return list
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+func number(f float64) (any, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, &Error{Code: ErrNum}
+	}
+	return f, nil
+}
+
+// numeric reports whether value reads as a number without being coerced
+// from arbitrary text.
+func numeric(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case nil:
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toNumber(value any) (float64, error) {
+	if s, ok := value.(string); ok && strings.TrimSpace(s) == "" {
+		return 0, nil
+	}
+	if f, ok := numeric(value); ok {
+		return f, nil
+	}
+	return 0, valueError("%q is not a number", toText(value))
+}
+
+func toInt(value any) (int, error) {
+	f, err := toNumber(value)
+	if err != nil {
+		return 0, err
+	}
+	if math.Abs(f) > math.MaxInt32 {
+		return 0, &Error{Code: ErrNum}
+	}
+	return int(f), nil
+}
+
+func toText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return formatNumber(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(jsDateLayout)
+	case []any:
+		parts := make([]string, len(v))
+		for i := range v {
+			parts[i] = toText(v[i])
+		}
+		return strings.Join(parts, ",")
+	}
+	return ""
+}
+
+func toBool(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case nil:
+		return false, nil
+	case float64:
+		return v != 0, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true":
+			return true, nil
+		case "false", "":
+			return false, nil
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f != 0, nil
+		}
+	}
+	return false, valueError("%q is not a boolean", toText(value))
+}
+
+func toTime(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.UTC(), nil
+			}
+		}
+	}
+	return time.Time{}, valueError("%q is not a date", toText(value))
+}
+
+// formatNumber prints f the way JavaScript does, so stored formula values
+// keep the format the previous evaluator produced.
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+		digits := strings.TrimLeft(exponent[1:], "0")
+		return mantissa + "e" + exponent[:1] + digits
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Format renders a formula result as the text stored in the field.
+func Format(value any) string {
+	return toText(value)
+}
+
+// flatten expands list arguments, so aggregate functions accept both
+// SUM(a, b) and SUM(multiselect_field).
+func flatten(args []any) []any {
+	var values []any
+	for _, arg := range args {
+		if list, ok := arg.([]any); ok {
+			values = append(values, flatten(list)...)
+			continue
+		}
+		values = append(values, arg)
+	}
+	return values
+}
@@ -12,6 +12,9 @@ import (
 	"github.com/spf13/cast"
 )
 
+// GetAutomaticFilter merges the read automatic filters of the role into
+// params. It is superseded by the read rules of record_permission.conditions
+// and only used for roles without one.
 func GetAutomaticFilter(ctx context.Context, req models.GetAutomaticFilterRequest) (map[string]any, error) {
 	var (
 		many2ManyRelation    bool
@@ -25,8 +28,8 @@ func GetAutomaticFilter(ctx context.Context, req models.GetAutomaticFilterReques
 		custom_field,
 		object_field,
 		not_use_in_tab
-	FROM automatic_filter
-	WHERE method = 'read' AND role_id = $1 AND table_slug = $2 AND deleted_at IS NULL`
+	FROM effective_automatic_filter
+	WHERE method = 'read' AND table_slug = $2 AND effective_role_id::TEXT = $1`
 
 	rows, err := req.Conn.Query(ctx, automaticFilterQuery, req.RoleIdFromToken, req.TableSlug)
 	if err != nil {
@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+
+	"ucode/ucode_go_object_builder_service/pkg/cache"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CacheScope is the scope of the cached results read from the database
+// conn talks to. Every entry and invalidation is scoped by database rather
+// than by the id a pool was requested with, so writes through any pool or
+// transaction of a database reach the entries read through the others.
+func CacheScope(conn RowQuerier) (string, bool) {
+	var config *pgconn.Config
+	switch c := conn.(type) {
+	case *psqlpool.Pool:
+		config = &c.Db.Config().ConnConfig.Config
+	case *pgxpool.Pool:
+		config = &c.Config().ConnConfig.Config
+	case pgx.Tx:
+		config = &c.Conn().Config().Config
+	default:
+		return "", false
+	}
+	return fmt.Sprintf("%s:%d/%s", config.Host, config.Port, config.Database), true
+}
+
+// InvalidateCache drops the cached results that read one of tables in the
+// database of conn.
+func InvalidateCache(ctx context.Context, conn RowQuerier, tables ...string) {
+	if scope, ok := CacheScope(conn); ok {
+		cache.Invalidate(ctx, scope, tables...)
+	}
+}
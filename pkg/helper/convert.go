@@ -34,11 +34,12 @@ var (
 		"NUMBER":                      "FLOAT",
 		"FLOAT":                       "FLOAT",
 		"FLOAT_NOLIMIT":               "FLOAT",
-		"FORMULA":                     "FLOAT",
+		"FORMULA":                     "NUMERIC",
 		"CHECKBOX":                    "BOOL",
 		"SWITCH":                      "BOOL",
 		"MULTISELECT":                 "TEXT[]",
 		"LOOKUPS":                     "UUID[]",
+		"DYNAMIC_LOOKUP":              "JSONB",
 		"DYNAMIC":                     "TEXT[]",
 		"LANGUAGE_TYPE":               "TEXT[]",
 		"MULTI_IMAGE":                 "TEXT[]",
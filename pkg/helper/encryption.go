@@ -0,0 +1,574 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"ucode/ucode_go_object_builder_service/pkg/cache"
+	"ucode/ucode_go_object_builder_service/pkg/security"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EncryptableFieldTypes are the field types that can be flagged encrypted:
+// their column is VARCHAR and they are compared by equality only.
+var EncryptableFieldTypes = map[string]bool{
+	"SINGLE_LINE":       true,
+	"MULTI_LINE":        true,
+	"EMAIL":             true,
+	"PHONE":             true,
+	"INTERNATION_PHONE": true,
+}
+
+// Querier is the part of a pool or a transaction the encryption helpers
+// need.
+type Querier interface {
+	RowQuerier
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+type masterKeys struct {
+	current []byte
+	byId    map[string][]byte
+}
+
+var (
+	masterKeysMu      sync.RWMutex
+	configuredMasters = masterKeys{byId: make(map[string][]byte)}
+)
+
+// SetMasterKeys sets the master keys data keys are wrapped with: current
+// wraps new keys and previous ones only unwrap the keys they wrapped until
+// those are rewrapped.
+func SetMasterKeys(current string, previous []string) error {
+	keys := masterKeys{byId: make(map[string][]byte)}
+
+	for _, encoded := range previous {
+		key, err := security.ParseKey(encoded)
+		if err != nil {
+			return errors.Wrap(err, "invalid ENCRYPTION_PREVIOUS_MASTER_KEYS")
+		}
+		keys.byId[security.KeyId(key)] = key
+	}
+
+	if current != "" {
+		key, err := security.ParseKey(current)
+		if err != nil {
+			return errors.Wrap(err, "invalid ENCRYPTION_MASTER_KEY")
+		}
+		keys.current = key
+		keys.byId[security.KeyId(key)] = key
+	}
+
+	masterKeysMu.Lock()
+	defer masterKeysMu.Unlock()
+
+	configuredMasters = keys
+	return nil
+}
+
+func loadMasterKeys() masterKeys {
+	masterKeysMu.RLock()
+	defer masterKeysMu.RUnlock()
+
+	return configuredMasters
+}
+
+// currentMasterKey returns the master key new data keys are wrapped with,
+// failing with FailedPrecondition when none is configured.
+func currentMasterKey() ([]byte, error) {
+	keys := loadMasterKeys()
+	if keys.current == nil {
+		return nil, status.Error(codes.FailedPrecondition, "field encryption needs ENCRYPTION_MASTER_KEY to be configured")
+	}
+	return keys.current, nil
+}
+
+// DataKey is a data key of a tenant as it is stored, wrapped by a master
+// key.
+type DataKey struct {
+	Version     int    `json:"version"`
+	Purpose     string `json:"purpose"`
+	WrappedKey  []byte `json:"wrapped_key"`
+	MasterKeyId string `json:"master_key_id"`
+}
+
+// Data keys encrypt values; the index key computes their blind index and
+// is never rotated, so indexes stay valid across rotations.
+const (
+	DataKeyPurpose  = "data"
+	IndexKeyPurpose = "index"
+)
+
+// LoadDataKeys returns the wrapped data keys of the database of q. Only
+// wrapped keys are cached, and not those read in a transaction, which may
+// have created them and roll back.
+func LoadDataKeys(ctx context.Context, q Querier) ([]DataKey, error) {
+	scope, cacheable := CacheScope(q)
+	entry := cache.Entry{Scope: scope, Tables: []string{"data_key"}, Parts: []any{"data_keys"}}
+	if cacheable {
+		if data, ok := cache.Default().Get(ctx, entry); ok {
+			var keys []DataKey
+			if err := json.Unmarshal(data, &keys); err == nil {
+				return keys, nil
+			}
+		}
+	}
+
+	rows, err := q.Query(ctx, `SELECT version, purpose, wrapped_key, master_key_id FROM data_key ORDER BY version`)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting data keys")
+	}
+	defer rows.Close()
+
+	var keys []DataKey
+	for rows.Next() {
+		var key DataKey
+		if err := rows.Scan(&key.Version, &key.Purpose, &key.WrappedKey, &key.MasterKeyId); err != nil {
+			return nil, errors.Wrap(err, "error while scanning data key")
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error while getting data keys")
+	}
+
+	if _, inTx := q.(pgx.Tx); cacheable && !inTx && len(keys) > 0 {
+		if data, err := json.Marshal(keys); err == nil {
+			cache.Default().Set(ctx, entry, data, 0)
+		}
+	}
+
+	return keys, nil
+}
+
+// InvalidateDataKeys drops the cached data keys of the database of q.
+func InvalidateDataKeys(ctx context.Context, q RowQuerier) {
+	InvalidateCache(ctx, q, "data_key")
+}
+
+// CreateDataKey adds a data key of purpose, wrapped by the current master
+// key. A tenant has a single index key, so an existing one is kept.
+func CreateDataKey(ctx context.Context, q Querier, purpose string) error {
+	master, err := currentMasterKey()
+	if err != nil {
+		return err
+	}
+
+	key, err := security.GenerateKey()
+	if err != nil {
+		return err
+	}
+	wrapped, err := security.Seal(master, key)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(ctx, `
+		INSERT INTO data_key (purpose, wrapped_key, master_key_id) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`,
+		purpose, wrapped, security.KeyId(master),
+	)
+	if err != nil {
+		return errors.Wrap(err, "error while creating data key")
+	}
+
+	InvalidateDataKeys(ctx, q)
+	return nil
+}
+
+// EnsureDataKeys creates the data key and the index key of the database
+// of q when it has none yet.
+func EnsureDataKeys(ctx context.Context, q Querier) error {
+	keys, err := LoadDataKeys(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	hasData, hasIndex := false, false
+	for _, key := range keys {
+		hasData = hasData || key.Purpose == DataKeyPurpose
+		hasIndex = hasIndex || key.Purpose == IndexKeyPurpose
+	}
+
+	if !hasIndex {
+		if err := CreateDataKey(ctx, q, IndexKeyPurpose); err != nil {
+			return err
+		}
+	}
+	if !hasData {
+		if err := CreateDataKey(ctx, q, DataKeyPurpose); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RewrapDataKeys wraps the data keys wrapped by a previous master key with
+// the current one, returning how many it rewrapped.
+func RewrapDataKeys(ctx context.Context, q Querier) (int, error) {
+	master, err := currentMasterKey()
+	if err != nil {
+		return 0, err
+	}
+	masters := loadMasterKeys()
+
+	keys, err := LoadDataKeys(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	var rewrapped int
+	for _, key := range keys {
+		if key.MasterKeyId == security.KeyId(master) {
+			continue
+		}
+
+		previous, ok := masters.byId[key.MasterKeyId]
+		if !ok {
+			return rewrapped, status.Errorf(codes.FailedPrecondition, "master key %s of data key %d is not configured", key.MasterKeyId, key.Version)
+		}
+		plain, err := security.Open(previous, key.WrappedKey)
+		if err != nil {
+			return rewrapped, errors.Wrapf(err, "error while unwrapping data key %d", key.Version)
+		}
+		wrapped, err := security.Seal(master, plain)
+		if err != nil {
+			return rewrapped, err
+		}
+
+		_, err = q.Exec(ctx, `UPDATE data_key SET wrapped_key = $2, master_key_id = $3 WHERE version = $1`,
+			key.Version, wrapped, security.KeyId(master))
+		if err != nil {
+			return rewrapped, errors.Wrap(err, "error while rewrapping data key")
+		}
+		rewrapped++
+	}
+
+	if rewrapped > 0 {
+		InvalidateDataKeys(ctx, q)
+	}
+	return rewrapped, nil
+}
+
+// LoadKeyring unwraps the data keys of the database of q. It is nil when
+// the database has no keys, so nothing in it is encrypted.
+func LoadKeyring(ctx context.Context, q Querier) (*security.Keyring, error) {
+	keys, err := LoadDataKeys(ctx, q)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+
+	masters := loadMasterKeys()
+
+	var (
+		active int
+		data   = make(map[int][]byte)
+		index  []byte
+	)
+	for _, key := range keys {
+		master, ok := masters.byId[key.MasterKeyId]
+		if !ok {
+			return nil, status.Errorf(codes.FailedPrecondition, "master key %s of data key %d is not configured", key.MasterKeyId, key.Version)
+		}
+		plain, err := security.Open(master, key.WrappedKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error while unwrapping data key %d", key.Version)
+		}
+
+		if key.Purpose == IndexKeyPurpose {
+			index = plain
+			continue
+		}
+		data[key.Version] = plain
+		active = max(active, key.Version)
+	}
+
+	return security.NewKeyring(active, data, index), nil
+}
+
+// FieldCipher encrypts and decrypts the encrypted fields of a table. A
+// field flagged with the "encrypted" attribute is encrypted on write; one
+// whose flag was turned off stays in the cipher, as false, until the
+// re-encrypt job has decrypted its values.
+type FieldCipher struct {
+	tableSlug string
+	fields    map[string]bool
+	keyring   *security.Keyring
+}
+
+// NewFieldCipher returns the cipher of the fields of tableSlug.
+func NewFieldCipher(tableSlug string, fields map[string]bool, keyring *security.Keyring) *FieldCipher {
+	return &FieldCipher{tableSlug: tableSlug, fields: fields, keyring: keyring}
+}
+
+// LoadEncryptedFields returns the fields of tableSlug with an "encrypted"
+// attribute, mapped to its value.
+func LoadEncryptedFields(ctx context.Context, q Querier, tableSlug string) (map[string]bool, error) {
+	rows, err := q.Query(ctx, `
+		SELECT f.slug, f.attributes->'encrypted' = 'true'::JSONB
+		FROM field f
+		JOIN "table" t ON t.id = f.table_id
+		WHERE t.slug = $1 AND f.attributes ? 'encrypted'`,
+		tableSlug,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting encrypted fields")
+	}
+	defer rows.Close()
+
+	fields := make(map[string]bool)
+	for rows.Next() {
+		var (
+			slug string
+			on   bool
+		)
+		if err := rows.Scan(&slug, &on); err != nil {
+			return nil, errors.Wrap(err, "error while scanning encrypted field")
+		}
+		fields[slug] = on
+	}
+
+	return fields, rows.Err()
+}
+
+// LoadFieldCipher returns the cipher of tableSlug, nil when no field of
+// it is encrypted.
+func LoadFieldCipher(ctx context.Context, q Querier, tableSlug string) (*FieldCipher, error) {
+	fields, err := LoadEncryptedFields(ctx, q, tableSlug)
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+
+	keyring, err := ensureKeyring(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFieldCipher(tableSlug, fields, keyring), nil
+}
+
+// ensureKeyring returns the keyring of the database of q, creating its
+// keys first when it has none.
+func ensureKeyring(ctx context.Context, q Querier) (*security.Keyring, error) {
+	keyring, err := LoadKeyring(ctx, q)
+	if err != nil || keyring != nil {
+		return keyring, err
+	}
+
+	// Keys are created with the first encrypted field, so a database
+	// without them holds no encrypted value yet.
+	if err := EnsureDataKeys(ctx, q); err != nil {
+		return nil, err
+	}
+	return LoadKeyring(ctx, q)
+}
+
+// Encrypted reports whether values of slug are encrypted on write.
+func (c *FieldCipher) Encrypted(slug string) bool {
+	return c != nil && c.fields[slug]
+}
+
+// Encrypt returns value as the field slug stores it. Empty values and
+// values already encrypted are kept.
+func (c *FieldCipher) Encrypt(slug string, value any) (any, error) {
+	if !c.Encrypted(slug) || value == nil {
+		return value, nil
+	}
+
+	s := cast.ToString(value)
+	if _, ok := security.KeyVersion(s); ok || s == "" {
+		return value, nil
+	}
+
+	encrypted, err := c.keyring.Encrypt(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while encrypting %s", slug)
+	}
+	return encrypted, nil
+}
+
+// Decrypt returns the stored value of the field slug in plaintext. Values
+// that cannot be decrypted are returned as they are.
+func (c *FieldCipher) Decrypt(slug string, value any) any {
+	if c == nil {
+		return value
+	}
+	if _, ok := c.fields[slug]; !ok {
+		return value
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	plain, err := c.keyring.Decrypt(s)
+	if err != nil {
+		return value
+	}
+	return plain
+}
+
+// DecryptItem decrypts the encrypted fields of item in place.
+func (c *FieldCipher) DecryptItem(item map[string]any) {
+	if c == nil {
+		return
+	}
+
+	for slug := range c.fields {
+		if value, ok := item[slug]; ok {
+			item[slug] = c.Decrypt(slug, value)
+		}
+	}
+}
+
+// BlindIndex returns the blind index of a plaintext value.
+func (c *FieldCipher) BlindIndex(value string) string {
+	return c.keyring.BlindIndex(value)
+}
+
+// Keyring returns the keys of the cipher.
+func (c *FieldCipher) Keyring() *security.Keyring {
+	return c.keyring
+}
+
+// IndexRow updates the blind index of the row guid from data, the
+// plaintext values written to it. Fields data does not set are left.
+func (c *FieldCipher) IndexRow(ctx context.Context, q Querier, guid string, data map[string]any) error {
+	if c == nil {
+		return nil
+	}
+
+	for slug, on := range c.fields {
+		value, ok := data[slug]
+		if !on || !ok {
+			continue
+		}
+
+		if err := c.IndexValue(ctx, q, slug, guid, cast.ToString(c.Decrypt(slug, value))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IndexValue sets the blind index of the field slug of the row guid to the
+// plaintext value, removing it when value is empty.
+func (c *FieldCipher) IndexValue(ctx context.Context, q Querier, slug, guid, value string) error {
+	if value == "" {
+		_, err := q.Exec(ctx, `
+			DELETE FROM encrypted_field_index
+			WHERE table_slug = $1 AND field_slug = $2 AND row_guid::TEXT = $3`,
+			c.tableSlug, slug, guid,
+		)
+		return errors.Wrap(err, "error while deleting blind index")
+	}
+
+	_, err := q.Exec(ctx, `
+		INSERT INTO encrypted_field_index (table_slug, field_slug, row_guid, hash) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (table_slug, field_slug, row_guid) DO UPDATE SET hash = EXCLUDED.hash`,
+		c.tableSlug, slug, guid, c.BlindIndex(value),
+	)
+	return errors.Wrap(err, "error while updating blind index")
+}
+
+// FilterParams turns the filters of params on encrypted fields into a
+// filter on guid, using the blind index. Encrypted fields are matched by
+// equality only: a value, a list of values or {"$in": [...]}.
+func (c *FieldCipher) FilterParams(ctx context.Context, q Querier, params map[string]any) error {
+	if c == nil {
+		return nil
+	}
+
+	for slug, on := range c.fields {
+		value, ok := params[slug]
+		if !on || !ok {
+			continue
+		}
+
+		values, err := equalityValues(slug, value)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			delete(params, slug)
+			continue
+		}
+
+		hashes := make([]string, 0, len(values))
+		for _, v := range values {
+			hashes = append(hashes, c.BlindIndex(v))
+		}
+
+		rows, err := q.Query(ctx, `
+			SELECT row_guid::TEXT FROM encrypted_field_index
+			WHERE table_slug = $1 AND field_slug = $2 AND hash = ANY($3)`,
+			c.tableSlug, slug, pq.Array(hashes),
+		)
+		if err != nil {
+			return errors.Wrap(err, "error while searching blind index")
+		}
+		guids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return errors.Wrap(err, "error while searching blind index")
+		}
+
+		if current, ok := params["guid"]; ok {
+			allowed := make(map[string]bool)
+			for _, guid := range cast.ToStringSlice(current) {
+				allowed[guid] = true
+			}
+			matched := guids[:0]
+			for _, guid := range guids {
+				if allowed[guid] {
+					matched = append(matched, guid)
+				}
+			}
+			guids = matched
+		}
+
+		filter := make([]any, 0, len(guids))
+		for _, guid := range guids {
+			filter = append(filter, guid)
+		}
+		params["guid"] = filter
+		delete(params, slug)
+	}
+
+	return nil
+}
+
+// equalityValues returns the non-empty values a filter on an encrypted
+// field matches.
+func equalityValues(slug string, value any) ([]string, error) {
+	var values []string
+	switch v := value.(type) {
+	case nil:
+	case []any, []string:
+		values = cast.ToStringSlice(v)
+	case map[string]any:
+		in, ok := v["$in"]
+		if !ok || len(v) != 1 {
+			return nil, status.Errorf(codes.InvalidArgument, "encrypted field %s can only be filtered by equality", slug)
+		}
+		values = cast.ToStringSlice(in)
+	default:
+		values = []string{cast.ToString(v)}
+	}
+
+	nonEmpty := values[:0]
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+	return nonEmpty, nil
+}
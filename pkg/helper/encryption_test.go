@@ -0,0 +1,41 @@
+package helper
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/security"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSetMasterKeys(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, SetMasterKeys("", nil)) })
+
+	key := func(b byte) ([]byte, string) {
+		raw := make([]byte, security.KeySize)
+		raw[0] = b
+		return raw, base64.StdEncoding.EncodeToString(raw)
+	}
+	current, encodedCurrent := key(1)
+	previous, encodedPrevious := key(2)
+
+	require.NoError(t, SetMasterKeys(encodedCurrent, []string{encodedPrevious}))
+	master, err := currentMasterKey()
+	require.NoError(t, err)
+	assert.Equal(t, current, master)
+	assert.Equal(t, previous, loadMasterKeys().byId[security.KeyId(previous)])
+
+	assert.Error(t, SetMasterKeys("short", nil))
+	assert.Error(t, SetMasterKeys(encodedCurrent, []string{"short"}))
+	master, err = currentMasterKey()
+	require.NoError(t, err)
+	assert.Equal(t, current, master, "invalid keys keep the configured ones")
+
+	require.NoError(t, SetMasterKeys("", nil))
+	_, err = currentMasterKey()
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
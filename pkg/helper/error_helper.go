@@ -1,6 +1,7 @@
 package helper
 
 import (
+	"context"
 	"fmt"
 	"ucode/ucode_go_object_builder_service/pkg/logger"
 
@@ -20,6 +21,11 @@ func HandleDatabaseError(err error, log logger.LoggerI, message string) error {
 		return status.Error(codes.NotFound, "not found")
 	}
 
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Error(message + ": " + err.Error())
+		return status.Error(codes.DeadlineExceeded, "query exceeded its time budget")
+	}
+
 	var pgErr *pgconn.PgError
 
 	if errors.As(err, &pgErr) {
@@ -56,6 +62,9 @@ func HandleDatabaseError(err error, log logger.LoggerI, message string) error {
 		case "40P01":
 			// Deadlock detected
 			return status.Error(codes.Aborted, fmt.Sprintf("deadlock detected: %v", pgErr.Message))
+		case "57014":
+			// Query canceled (statement_timeout or cancel request)
+			return status.Error(codes.DeadlineExceeded, fmt.Sprintf("query canceled: %v", pgErr.Message))
 
 		// --- Transaction Errors ---
 		case "25P01":
@@ -114,3 +123,18 @@ func HandleDatabaseError(err error, log logger.LoggerI, message string) error {
 
 	return status.Error(codes.Internal, fmt.Sprintf("unknown error: %v", err))
 }
+
+// IsQueryTimeout reports whether err comes from a query cancelled by its
+// context deadline or by statement_timeout.
+func IsQueryTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
@@ -0,0 +1,41 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/logger"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestQueryTimeouts(t *testing.T) {
+	log := logger.NewLogger("test", "error")
+
+	tests := []struct {
+		name    string
+		err     error
+		timeout bool
+		code    codes.Code
+	}{
+		{name: "deadline", err: fmt.Errorf("query: %w", context.DeadlineExceeded), timeout: true, code: codes.DeadlineExceeded},
+		{name: "statement timeout", err: &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}, timeout: true, code: codes.DeadlineExceeded},
+		{name: "cancelled by the client", err: context.Canceled, code: codes.Internal},
+		{name: "other database error", err: &pgconn.PgError{Code: "23505"}, code: codes.AlreadyExists},
+		{name: "other error", err: errors.New("boom"), code: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.timeout, IsQueryTimeout(tt.err))
+			assert.Equal(t, tt.code, status.Code(HandleDatabaseError(tt.err, log, "test")))
+		})
+	}
+
+	assert.False(t, IsQueryTimeout(nil))
+}
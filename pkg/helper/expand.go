@@ -0,0 +1,223 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RelationExpansion is a lookup field of listed items whose related row is
+// added to every item as <Field>_data.
+type RelationExpansion struct {
+	Field string
+	Table string
+	// Columns of the related row to return, all of them when empty.
+	Columns []string
+}
+
+// SelectRelationExpansions applies the caller's choice from the list
+// params: "relations" lists the lookup fields to expand, all of them when
+// missing, and "relation_fields" maps a lookup field to the columns of the
+// related row to return. Related rows outside the projection of "fields"
+// are not expanded, and "<lookup>_data.<column>" fields select columns too.
+func SelectRelationExpansions(params map[string]any, expansions []RelationExpansion) []RelationExpansion {
+	var (
+		requested, filtered = params["relations"]
+		wanted              = make(map[string]bool)
+		columns             = cast.ToStringMap(params["relation_fields"])
+		projection          = NewProjection(params)
+		selected            = make([]RelationExpansion, 0, len(expansions))
+	)
+
+	for _, field := range cast.ToStringSlice(requested) {
+		wanted[field] = true
+	}
+
+	for _, expansion := range expansions {
+		if (filtered && !wanted[expansion.Field]) || !projection.Keeps(expansion.Field+"_data") {
+			continue
+		}
+		if fields, ok := columns[expansion.Field]; ok {
+			expansion.Columns = cast.ToStringSlice(fields)
+		} else {
+			expansion.Columns = projection.Columns(expansion.Field)
+		}
+		selected = append(selected, expansion)
+	}
+
+	return selected
+}
+
+// ExpandRelations sets <field>_data on every item to the related row of
+// each expansion, or nil when there is none. The related rows of all
+// expansions are read in one query keyed by the guids collected from the
+// items, instead of a query per item. Related rows are shown with the
+// field access of the subject of ctx or params on their table.
+func ExpandRelations(ctx context.Context, conn *psqlpool.Pool, params map[string]any, items []map[string]any, expansions []RelationExpansion) error {
+	if len(items) == 0 || len(expansions) == 0 {
+		return nil
+	}
+
+	if err := checkExpansionColumns(ctx, conn, expansions); err != nil {
+		return err
+	}
+
+	var (
+		parts []string
+		args  []any
+	)
+
+	for n, expansion := range expansions {
+		ids := make(map[string]bool)
+		for _, item := range items {
+			id := cast.ToString(item[expansion.Field])
+			if _, err := uuid.Parse(id); err == nil {
+				ids[id] = true
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		list := make([]string, 0, len(ids))
+		for id := range ids {
+			list = append(list, id)
+		}
+		args = append(args, pq.Array(list))
+
+		parts = append(parts, fmt.Sprintf(`SELECT %d, r.guid::TEXT, %s FROM %s r WHERE r.guid = ANY($%d::UUID[])`,
+			n, expansionObject(expansion), pq.QuoteIdentifier(expansion.Table), len(args)))
+	}
+
+	related := make([]map[string]any, len(expansions))
+	for n := range related {
+		related[n] = make(map[string]any)
+	}
+
+	if len(parts) > 0 {
+		rows, err := conn.Query(ctx, strings.Join(parts, " UNION ALL "), args...)
+		if err != nil {
+			return errors.Wrap(err, "error while expanding relations")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				n    int
+				guid string
+				row  map[string]any
+			)
+			if err := rows.Scan(&n, &guid, &row); err != nil {
+				return errors.Wrap(err, "error while scanning related row")
+			}
+			related[n][guid] = row
+		}
+		if err := rows.Err(); err != nil {
+			return errors.Wrap(err, "error while expanding relations")
+		}
+	}
+
+	if err := applyRelatedAccess(related, expansions, FieldAccessLoader(ctx, conn, params)); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		for n, expansion := range expansions {
+			item[expansion.Field+"_data"] = related[n][cast.ToString(item[expansion.Field])]
+		}
+	}
+
+	return nil
+}
+
+// applyRelatedAccess shows the related rows of each expansion, related[n]
+// keyed by guid, with the field access load returns on its table.
+func applyRelatedAccess(related []map[string]any, expansions []RelationExpansion, load func(table string) (*FieldAccess, error)) error {
+	for n, expansion := range expansions {
+		if len(related[n]) == 0 {
+			continue
+		}
+
+		access, err := load(expansion.Table)
+		if err != nil {
+			return err
+		}
+		for _, row := range related[n] {
+			if row, ok := row.(map[string]any); ok {
+				access.Apply(row)
+			}
+		}
+	}
+
+	return nil
+}
+
+func expansionObject(expansion RelationExpansion) string {
+	if len(expansion.Columns) == 0 {
+		return "to_jsonb(r)"
+	}
+
+	parts := []string{`'guid', r.guid`}
+	for _, column := range expansion.Columns {
+		if column == "guid" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf(`%s, r.%s`, pq.QuoteLiteral(column), pq.QuoteIdentifier(column)))
+	}
+
+	return "jsonb_build_object(" + strings.Join(parts, ", ") + ")"
+}
+
+// checkExpansionColumns rejects projections that name a column the
+// related table does not have.
+func checkExpansionColumns(ctx context.Context, conn *psqlpool.Pool, expansions []RelationExpansion) error {
+	var tables []string
+	for _, expansion := range expansions {
+		if len(expansion.Columns) > 0 {
+			tables = append(tables, expansion.Table)
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ANY($1)`, pq.Array(tables))
+	if err != nil {
+		return errors.Wrap(err, "error while getting related columns")
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return errors.Wrap(err, "error while scanning related column")
+		}
+		existing[table+"."+column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "error while getting related columns")
+	}
+
+	for _, expansion := range expansions {
+		for _, column := range expansion.Columns {
+			if !existing[expansion.Table+"."+column] {
+				return status.Errorf(codes.InvalidArgument, "%s has no column %s", expansion.Table, column)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package helper
+
+import (
+	"sort"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/security"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectRelationExpansions(t *testing.T) {
+	expansions := []RelationExpansion{
+		{Field: "author_id", Table: "user"},
+		{Field: "category_id", Table: "category"},
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]any
+		want   []RelationExpansion
+	}{
+		{name: "all by default", want: expansions},
+		{
+			name:   "chosen relations",
+			params: map[string]any{"relations": []any{"category_id"}},
+			want:   []RelationExpansion{{Field: "category_id", Table: "category"}},
+		},
+		{
+			name:   "no relations",
+			params: map[string]any{"relations": []any{}},
+			want:   []RelationExpansion{},
+		},
+		{
+			name:   "relation fields",
+			params: map[string]any{"relation_fields": map[string]any{"author_id": []any{"name"}}},
+			want: []RelationExpansion{
+				{Field: "author_id", Table: "user", Columns: []string{"name"}},
+				{Field: "category_id", Table: "category"},
+			},
+		},
+		{
+			name:   "outside the projection",
+			params: map[string]any{"fields": []any{"title", "author_id"}},
+			want:   []RelationExpansion{},
+		},
+		{
+			name:   "excluded related row",
+			params: map[string]any{"exclude_fields": []any{"author_id_data"}},
+			want:   []RelationExpansion{{Field: "category_id", Table: "category"}},
+		},
+		{
+			name:   "projected columns",
+			params: map[string]any{"fields": []any{"author_id_data.name", "author_id_data.email"}},
+			want:   []RelationExpansion{{Field: "author_id", Table: "user", Columns: []string{"email", "name"}}},
+		},
+		{
+			name: "relation fields win over the projection",
+			params: map[string]any{
+				"fields":          []any{"author_id_data.name"},
+				"relation_fields": map[string]any{"author_id": []any{"login"}},
+			},
+			want: []RelationExpansion{{Field: "author_id", Table: "user", Columns: []string{"login"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SelectRelationExpansions(tt.params, expansions)
+			for _, expansion := range got {
+				sort.Strings(expansion.Columns)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpansionObject(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		want    string
+	}{
+		{name: "whole row", want: "to_jsonb(r)"},
+		{name: "columns", columns: []string{"name"}, want: `jsonb_build_object('guid', r.guid, 'name', r."name")`},
+		{name: "guid once", columns: []string{"guid", "email"}, want: `jsonb_build_object('guid', r.guid, 'email', r."email")`},
+		{name: "quoted", columns: []string{`a"b`}, want: `jsonb_build_object('guid', r.guid, 'a"b', r."a""b")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, expansionObject(RelationExpansion{Field: "author_id", Table: "user", Columns: tt.columns}))
+		})
+	}
+}
+
+func TestApplyRelatedAccess(t *testing.T) {
+	var (
+		expansions = []RelationExpansion{{Field: "author_id", Table: "user"}, {Field: "category_id", Table: "category"}}
+		related    = []map[string]any{
+			{"u": map[string]any{"guid": "u", "email": "a@b.com", "salary": 100}},
+			{"c": map[string]any{"guid": "c", "title": "t"}},
+		}
+		accesses = map[string]*FieldAccess{
+			"user": {
+				Hidden: map[string]bool{"salary": true},
+				Masks:  map[string]authz.Mask{"email": authz.MaskEmailDomain},
+			},
+		}
+		loaded []string
+	)
+
+	err := applyRelatedAccess(related, expansions, func(table string) (*FieldAccess, error) {
+		loaded = append(loaded, table)
+		return accesses[table], nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"user", "category"}, loaded)
+	assert.Equal(t, map[string]any{"guid": "u", "email": authz.MaskEmailDomain.Apply("a@b.com", nil)}, related[0]["u"])
+	assert.Equal(t, map[string]any{"guid": "c", "title": "t"}, related[1]["c"])
+}
+
+func TestApplyRelatedAccessDecrypts(t *testing.T) {
+	var (
+		keyring    = security.NewKeyring(1, map[int][]byte{1: make([]byte, security.KeySize)}, make([]byte, security.KeySize))
+		cipher     = NewFieldCipher("patient", map[string]bool{"passport": true}, keyring)
+		sealed     = NewFieldAccess(nil, map[string]bool{"passport": true})
+		expansions = []RelationExpansion{{Field: "patient_id", Table: "patient"}}
+	)
+	sealed.Cipher = cipher
+
+	passport, err := cipher.Encrypt("passport", "AA1234567")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		access *FieldAccess
+		want   any
+	}{
+		{name: "decrypted", access: &FieldAccess{Cipher: cipher}, want: "AA1234567"},
+		{name: "without the decrypt permission", access: sealed, want: authz.MaskFull.Apply("AA1234567", nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			related := []map[string]any{{"p": map[string]any{"guid": "p", "passport": passport}}}
+			err := applyRelatedAccess(related, expansions, func(string) (*FieldAccess, error) { return tt.access, nil })
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"guid": "p", "passport": tt.want}, related[0]["p"])
+		})
+	}
+}
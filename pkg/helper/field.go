@@ -85,8 +85,8 @@ func AddPermissionToField1(ctx context.Context, req models.AddPermissionToFieldR
 				"field_id",
 				"edit_permission",
 				"view_permission"
-			FROM "field_permission" 
-			WHERE field_id = ANY($1) AND role_id = $2 AND table_slug = $3
+			FROM "effective_field_permission"
+			WHERE field_id = ANY($1) AND effective_role_id::TEXT = $2 AND table_slug = $3
 		`
 
 		rows, err := req.Conn.Query(ctx, query, pq.Array(fieldIds), req.RoleId, req.TableSlug)
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"reflect"
 	"slices"
 	"strings"
@@ -164,8 +163,7 @@ func GetItemWithTx(ctx context.Context, conn pgx.Tx, tableSlug, guid string, fro
 
 func GetItems(ctx context.Context, conn *psqlpool.Pool, req models.GetItemsBody) ([]map[string]any, int, error) {
 	var (
-		relations       []models.Relation
-		relationMap     = make(map[string]map[string]any)
+		relations       []RelationExpansion
 		tableSlug       = req.TableSlug
 		params          = req.Params
 		fields          = req.FieldsMap
@@ -325,6 +323,8 @@ func GetItems(ctx context.Context, conn *psqlpool.Pool, req models.GetItemsBody)
 		}
 	}
 
+	filter += req.RowFilter
+
 	countQuery += filter
 	query += filter + order + limit + offset
 
@@ -350,10 +350,7 @@ func GetItems(ctx context.Context, conn *psqlpool.Pool, req models.GetItemsBody)
 	if withRelations {
 		var relRows pgx.Rows
 		var relationQuery = `SELECT
-						id,
-						table_from,
 						table_to,
-						field_from,
 						type
 					FROM
 						relation`
@@ -371,26 +368,26 @@ func GetItems(ctx context.Context, conn *psqlpool.Pool, req models.GetItemsBody)
 		}
 		defer relRows.Close()
 
+		expanded := make(map[string]bool)
 		for relRows.Next() {
-			relation := models.Relation{}
-
-			err := relRows.Scan(
-				&relation.Id,
-				&relation.TableFrom,
-				&relation.TableTo,
-				&relation.FieldFrom,
-				&relation.Type,
-			)
-			if err != nil {
+			var tableTo, relationType string
+
+			if err := relRows.Scan(&tableTo, &relationType); err != nil {
 				return nil, 0, err
 			}
 
-			if config.SKIPPED_RELATION_TYPES[relation.Type] {
+			if config.SKIPPED_RELATION_TYPES[relationType] || expanded[tableTo] {
 				continue
 			}
+			expanded[tableTo] = true
 
-			relations = append(relations, relation)
+			relations = append(relations, RelationExpansion{Field: tableTo + "_id", Table: tableTo})
 		}
+		if err := relRows.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		relations = SelectRelationExpansions(params, relations)
 	}
 
 	for rows.Next() {
@@ -426,24 +423,15 @@ func GetItems(ctx context.Context, conn *psqlpool.Pool, req models.GetItemsBody)
 			data[fieldName] = value
 		}
 
-		if len(relations) > 0 {
-			for _, relation := range relations {
-				joinId := cast.ToString(data[relation.TableTo+"_id"])
-				if _, ok := relationMap[joinId]; ok {
-					data[relation.TableTo+"_id_data"] = relationMap[joinId]
-					continue
-				}
-				relationData, err := GetItem(ctx, conn, relation.TableTo, joinId, false)
-				if err != nil {
-					return nil, 0, err
-				}
+		result = append(result, data)
+	}
 
-				data[relation.TableTo+"_id_data"] = relationData
-				relationMap[joinId] = relationData
-			}
-		}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
 
-		result = append(result, data)
+	if err := ExpandRelations(ctx, conn, params, result, relations); err != nil {
+		return nil, 0, err
 	}
 
 	count := 0
@@ -457,8 +445,7 @@ func GetItems(ctx context.Context, conn *psqlpool.Pool, req models.GetItemsBody)
 
 func GetItemsGetList(ctx context.Context, conn *psqlpool.Pool, req models.GetItemsBody) ([]map[string]any, int, error) {
 	var (
-		relations       []models.Relation
-		relationMap     = make(map[string]map[string]any)
+		relations       []RelationExpansion
 		tableSlug       = req.TableSlug
 		params          = req.Params
 		fields          = req.FieldsMap
@@ -628,41 +615,29 @@ func GetItemsGetList(ctx context.Context, conn *psqlpool.Pool, req models.GetIte
 	if withRelations {
 		relationQuery := `
 			SELECT
-				id,
-				table_from,
 				table_to,
-				field_from,
-				type
+				field_from
 			FROM
 				relation
-			WHERE  table_from = $1 OR table_to = $1 `
+			WHERE table_from = $1 AND type NOT IN ($2, $3, $4)`
 
-		relRows, err := conn.Query(ctx, relationQuery, tableSlug)
+		relRows, err := conn.Query(ctx, relationQuery, tableSlug, config.MANY2MANY, config.MANY2DYNAMIC, config.RECURSIVE)
 		if err != nil {
 			return nil, 0, err
 		}
 		defer relRows.Close()
 
 		for relRows.Next() {
-			var relation models.Relation
-
-			err := relRows.Scan(
-				&relation.Id,
-				&relation.TableFrom,
-				&relation.TableTo,
-				&relation.FieldFrom,
-				&relation.Type,
-			)
-			if err != nil {
-				return nil, 0, err
-			}
+			var relation RelationExpansion
 
-			if relation.Type == config.MANY2MANY || relation.Type == config.MANY2DYNAMIC || relation.Type == config.RECURSIVE {
-				continue
+			if err := relRows.Scan(&relation.Table, &relation.Field); err != nil {
+				return nil, 0, err
 			}
 
 			relations = append(relations, relation)
 		}
+
+		relations = SelectRelationExpansions(params, relations)
 	}
 
 	for rows.Next() {
@@ -698,23 +673,6 @@ func GetItemsGetList(ctx context.Context, conn *psqlpool.Pool, req models.GetIte
 			data[fieldName] = value
 		}
 
-		if len(relations) > 0 {
-			for _, relation := range relations {
-				joinId := cast.ToString(data[relation.FieldFrom])
-				if _, ok := relationMap[joinId]; ok {
-					data[relation.FieldFrom+"_data"] = relationMap[joinId]
-					continue
-				}
-				relationData, err := GetItem(ctx, conn, relation.TableTo, joinId, false)
-				if err != nil {
-					return nil, 0, err
-				}
-
-				data[relation.FieldFrom+"_data"] = relationData
-				relationMap[joinId] = relationData
-			}
-		}
-
 		result = append(result, data)
 	}
 
@@ -722,6 +680,10 @@ func GetItemsGetList(ctx context.Context, conn *psqlpool.Pool, req models.GetIte
 		return nil, 0, err
 	}
 
+	if err := ExpandRelations(ctx, conn, params, result, relations); err != nil {
+		return nil, 0, err
+	}
+
 	count := 0
 	err = conn.QueryRow(ctx, countQuery, args...).Scan(&count)
 	if err != nil {
@@ -848,7 +810,7 @@ func AddPermissionToFieldv2(ctx context.Context, conn *psqlpool.Pool, fields []m
 			"field_id",
 			"edit_permission",
 			"view_permission"
-		FROM "field_permission" WHERE field_id IN ($1) AND role_id = $2 AND table_slug = $3`
+		FROM "effective_field_permission" WHERE field_id IN ($1) AND effective_role_id::TEXT = $2 AND table_slug = $3`
 
 		rows, err := conn.Query(ctx, query, pq.Array(fieldIds), roleId, tableSlug)
 		if err != nil {
@@ -923,23 +885,6 @@ func AddPermissionToFieldv2(ctx context.Context, conn *psqlpool.Pool, fields []m
 	return fieldsWithPermissions, nil
 }
 
-func CallJS(value string) (string, error) {
-	/*
-		local
-		 cmd := exec.Command("node", "./pkg/js_parser/frontend_formula.js", value)
-	*/
-	cmd := exec.Command("node", "/js/pkg/js_parser/frontend_formula.js", value)
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", err
-	}
-
-	result := strings.TrimSpace(string(output))
-
-	return result, nil
-}
-
 func IsEmpty(value any) bool {
 	if value == nil {
 		return true
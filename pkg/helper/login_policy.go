@@ -0,0 +1,116 @@
+package helper
+
+import (
+	"context"
+	"time"
+
+	"ucode/ucode_go_object_builder_service/pkg/security"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LoadLoginPolicy returns the login policy of a client type, the default
+// policy when it sets none or does not exist.
+func LoadLoginPolicy(ctx context.Context, q RowQuerier, clientTypeId string) (security.LoginPolicy, error) {
+	var raw string
+	err := q.QueryRow(ctx, `SELECT COALESCE(login_policy, '') FROM client_type WHERE guid::TEXT = $1`, clientTypeId).Scan(&raw)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return security.LoginPolicy{}, errors.Wrap(err, "error while getting login policy")
+	}
+
+	return security.ParseLoginPolicy(raw)
+}
+
+// ValidatePassword checks password against policy and the passwords the
+// row of tableSlug had last, current included. It returns InvalidArgument
+// for passwords the policy rejects.
+func ValidatePassword(ctx context.Context, q Querier, policy security.PasswordPolicy, tableSlug, guid, current, password string) error {
+	if err := policy.Validate(password); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if policy.History == 0 {
+		return nil
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT hash FROM password_history
+		WHERE table_slug = $1 AND row_guid::TEXT = $2
+		ORDER BY created_at DESC LIMIT $3`,
+		tableSlug, guid, policy.History,
+	)
+	if err != nil {
+		return errors.Wrap(err, "error while getting password history")
+	}
+	defer rows.Close()
+
+	hashes := []string{current}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return errors.Wrap(err, "error while scanning password history")
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "error while getting password history")
+	}
+
+	for _, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		if same, _ := security.ComparePasswordBcrypt(hash, password); same {
+			return status.Errorf(codes.InvalidArgument, "password must differ from the last %d passwords", policy.History)
+		}
+	}
+
+	return nil
+}
+
+// RecordPassword adds hash to the password history of the row and keeps
+// only what policy needs: the last History hashes, and always the latest
+// one, which dates the last change.
+func RecordPassword(ctx context.Context, q Querier, policy security.PasswordPolicy, tableSlug, guid, hash string) error {
+	_, err := q.Exec(ctx, `INSERT INTO password_history (table_slug, row_guid, hash) VALUES ($1, $2, $3)`,
+		tableSlug, guid, hash)
+	if err != nil {
+		return errors.Wrap(err, "error while recording password")
+	}
+
+	_, err = q.Exec(ctx, `
+		DELETE FROM password_history
+		WHERE table_slug = $1 AND row_guid::TEXT = $2 AND ctid NOT IN (
+			SELECT ctid FROM password_history
+			WHERE table_slug = $1 AND row_guid::TEXT = $2
+			ORDER BY created_at DESC LIMIT $3
+		)`,
+		tableSlug, guid, max(policy.History, 1),
+	)
+	if err != nil {
+		return errors.Wrap(err, "error while pruning password history")
+	}
+
+	return nil
+}
+
+// PasswordChangedAt returns when the password of the row last changed. ok
+// is false when no change was recorded.
+func PasswordChangedAt(ctx context.Context, q RowQuerier, tableSlug, guid string) (changedAt time.Time, ok bool, err error) {
+	err = q.QueryRow(ctx, `
+		SELECT created_at FROM password_history
+		WHERE table_slug = $1 AND row_guid::TEXT = $2
+		ORDER BY created_at DESC LIMIT 1`,
+		tableSlug, guid,
+	).Scan(&changedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, errors.Wrap(err, "error while getting password change")
+	}
+
+	return changedAt, true, nil
+}
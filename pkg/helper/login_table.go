@@ -65,7 +65,7 @@ func UpsertLoginTableField(ctx context.Context, req models.Field) (string, error
 			return fieldId, err
 		}
 
-		query = `SELECT guid FROM "role"`
+		query = `SELECT guid FROM "role" WHERE parent_id IS NULL`
 
 		rows, err := tx.Query(ctx, query)
 		if err != nil {
@@ -51,9 +51,9 @@ func AddPermissionToField(ctx context.Context, conn *psqlpool.Pool, fields []*ne
 			field_id,
 			view_permission,
 			field_permission
-		FROM field_permission
+		FROM effective_field_permission field_permission
 		WHERE field_id = ANY($1)
-			AND role_id = $2
+			AND effective_role_id::TEXT = $2
 			AND table_slug = $3
 	`
 	rows, err := conn.Query(ctx, query, fieldIds, roleId, tableSlug)
@@ -166,8 +166,8 @@ func AddPermissionToTab(ctx context.Context, relation map[string]any, conn *pgxp
 		"create_permission",
 		"edit_permission",
 		"delete_permission"
-		FROM view_relation_permission
-        WHERE role_id = $1 AND table_slug = $2 AND relation_id = $3
+		FROM effective_view_relation_permission
+        WHERE effective_role_id::TEXT = $1 AND table_slug = $2 AND relation_id = $3
     `
 
 	err := conn.QueryRow(ctx, query, roleId, tableSlug, relation["id"]).Scan(&guid, &role_id, &tableSlug, &relation_id, &view_permission, &create_permission, &edit_permission, &delete_permission)
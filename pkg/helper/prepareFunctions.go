@@ -160,7 +160,11 @@ func PrepareToCreateInObjectBuilder(ctx context.Context, conn pgx.Tx, req *nb.Co
 	{
 		if password, ok := fieldM["PASSWORD"]; ok {
 			if passwordData, ok := data[password.Slug]; ok {
-				err = util.ValidStrongPassword(cast.ToString(passwordData))
+				validate := util.ValidStrongPassword
+				if reqBody.ValidatePassword != nil {
+					validate = reqBody.ValidatePassword
+				}
+				err = validate(cast.ToString(passwordData))
 				if err != nil {
 					return map[string]any{}, []map[string]any{}, err
 				}
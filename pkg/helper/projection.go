@@ -0,0 +1,397 @@
+package helper
+
+import (
+	"context"
+	"strings"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+// Projection is the sparse fieldset a caller asks for with the "fields"
+// and "exclude_fields" params. "<lookup>_data.<column>" projects the
+// related row of a lookup. guid is always kept.
+type Projection struct {
+	include map[string]bool
+	exclude map[string]bool
+	nested  map[string]*Projection
+}
+
+// NewProjection reads the projection from the params of a request. It is
+// nil, keeping every field, when neither param is given.
+func NewProjection(params map[string]any) *Projection {
+	var (
+		include = cast.ToStringSlice(params["fields"])
+		exclude = cast.ToStringSlice(params["exclude_fields"])
+	)
+
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil
+	}
+
+	p := newProjection()
+	for _, field := range include {
+		parent, child, ok := strings.Cut(field, ".")
+		p.include[parent] = true
+		if ok {
+			p.child(parent).include[child] = true
+		}
+	}
+	for _, field := range exclude {
+		if parent, child, ok := strings.Cut(field, "."); ok {
+			p.child(parent).exclude[child] = true
+			continue
+		}
+		p.exclude[field] = true
+	}
+
+	return p
+}
+
+func newProjection() *Projection {
+	return &Projection{
+		include: make(map[string]bool),
+		exclude: make(map[string]bool),
+		nested:  make(map[string]*Projection),
+	}
+}
+
+func (p *Projection) child(field string) *Projection {
+	if p.nested[field] == nil {
+		p.nested[field] = newProjection()
+	}
+	return p.nested[field]
+}
+
+// Keeps reports whether field is part of the projection.
+func (p *Projection) Keeps(field string) bool {
+	if p == nil || field == "guid" {
+		return true
+	}
+	return (len(p.include) == 0 || p.include[field]) && !p.exclude[field]
+}
+
+// Selects reports whether a list query has to read field: it is projected
+// itself or its related row is.
+func (p *Projection) Selects(field string) bool {
+	return p.Keeps(field) || p.Keeps(field+"_data")
+}
+
+// Columns returns the projected columns of the related row of a lookup,
+// nil when all of them are.
+func (p *Projection) Columns(field string) []string {
+	if p == nil || p.nested[field+"_data"] == nil {
+		return nil
+	}
+
+	var columns []string
+	for column := range p.nested[field+"_data"].include {
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// Apply removes the fields outside the projection from item, including
+// those of projected related rows.
+func (p *Projection) Apply(item map[string]any) {
+	if p == nil {
+		return
+	}
+
+	for key, value := range item {
+		if !p.Keeps(key) {
+			delete(item, key)
+			continue
+		}
+		if nested, ok := value.(map[string]any); ok && p.nested[key] != nil {
+			p.nested[key].Apply(nested)
+		}
+	}
+}
+
+// FieldAccess is what the subject of a read may see of the fields of a
+// table: encrypted fields are decrypted, hidden fields dropped and masked
+// ones shown masked. Encrypted fields the subject may not decrypt are
+// fully masked.
+type FieldAccess struct {
+	Hidden map[string]bool
+	Masks  map[string]authz.Mask
+	Cipher *FieldCipher
+	// Hasher keys the hash masks with the keys of the database.
+	Hasher authz.Hasher
+}
+
+// FieldRule is the field_permission row a role reads a field with, the
+// zero value when neither the role nor its parents have one.
+type FieldRule struct {
+	View    *bool
+	Mask    string
+	Decrypt bool
+}
+
+// Read returns whether a reader with the rule sees the field and the mask
+// it sees it with. Fields are shown unless view_permission is off. A
+// sealed field, an encrypted one the client type of the reader may not
+// decrypt, is fully masked unless the rule allows decrypting it.
+func (r FieldRule) Read(sealed bool) (bool, authz.Mask) {
+	if r.View != nil && !*r.View {
+		return false, authz.NoMask
+	}
+	if sealed && !r.Decrypt {
+		return true, authz.MaskFull
+	}
+	return true, authz.Mask(r.Mask)
+}
+
+// NewFieldAccess returns the field access of a reader with rules on the
+// fields of a table, sealed being its encrypted fields the client type of
+// the reader may not decrypt.
+func NewFieldAccess(rules map[string]FieldRule, sealed map[string]bool) *FieldAccess {
+	access := &FieldAccess{Hidden: make(map[string]bool), Masks: make(map[string]authz.Mask)}
+
+	read := func(slug string, rule FieldRule) {
+		visible, mask := rule.Read(sealed[slug])
+		switch {
+		case !visible:
+			access.Hidden[slug] = true
+		case mask != authz.NoMask:
+			access.Masks[slug] = mask
+		}
+	}
+	for slug, rule := range rules {
+		read(slug, rule)
+	}
+	for slug := range sealed {
+		if _, ok := rules[slug]; !ok {
+			read(slug, FieldRule{})
+		}
+	}
+
+	return access
+}
+
+// LoadFieldAccess returns the field access on tableSlug of the subject of
+// ctx or params: the field permissions of its role and the masks of the
+// agent that reads, whether the request names it or it owns the role.
+// Encrypted fields are decrypted for the service itself, ADMIN client
+// types and roles with their decrypt_permission; requests of anyone else
+// see them masked. It is nil for subjects with no permissions on tables
+// without encrypted fields.
+func LoadFieldAccess(ctx context.Context, conn *psqlpool.Pool, tableSlug string, params map[string]any) (*FieldAccess, error) {
+	cipher, err := LoadFieldCipher(ctx, conn, tableSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := authz.Resolve(ctx, params)
+
+	var sealed map[string]bool
+	if cipher != nil && authz.FromRequest(ctx) {
+		admin, err := IsAdminClientType(ctx, conn, subject.ClientTypeId)
+		if err != nil {
+			return nil, err
+		}
+		if !admin {
+			sealed = make(map[string]bool, len(cipher.fields))
+			for slug := range cipher.fields {
+				sealed[slug] = true
+			}
+		}
+	}
+
+	if subject.RoleId == "" && subject.AgentId == "" && len(sealed) == 0 {
+		if cipher == nil {
+			return nil, nil
+		}
+		return &FieldAccess{Cipher: cipher}, nil
+	}
+
+	rules := make(map[string]FieldRule)
+
+	if subject.RoleId != "" {
+		rows, err := conn.Query(ctx, `
+			SELECT slug, view_permission, mask, decrypt_permission FROM (
+				SELECT f.slug, fp.view_permission,
+					COALESCE(fp.mask, '') AS mask, COALESCE(fp.decrypt_permission, false) AS decrypt_permission
+				FROM effective_field_permission fp
+				JOIN field f ON f.id = fp.field_id
+				WHERE fp.effective_role_id::TEXT = $1 AND fp.table_slug = $2
+			) fp
+			WHERE view_permission = false OR mask <> '' OR decrypt_permission`,
+			subject.RoleId, tableSlug,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "error while getting field permissions")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				slug string
+				rule FieldRule
+			)
+			if err := rows.Scan(&slug, &rule.View, &rule.Mask, &rule.Decrypt); err != nil {
+				return nil, errors.Wrap(err, "error while scanning field permission")
+			}
+			rules[slug] = rule
+		}
+		if err := rows.Err(); err != nil {
+			return nil, errors.Wrap(err, "error while getting field permissions")
+		}
+	}
+
+	access := NewFieldAccess(rules, sealed)
+	access.Cipher = cipher
+
+	// Agents read with roles of their own, so their masks apply even to
+	// the requests that leave the agent out.
+	rows, err := conn.Query(ctx, `
+		SELECT COALESCE(ap.masked_fields, '{}') FROM agent_permissions ap
+		JOIN agents a ON a.id = ap.agent_id
+		WHERE (a.id::TEXT = $1 OR a.role_id::TEXT = $2) AND ap.table_slug = $3`,
+		subject.AgentId, subject.RoleId, tableSlug,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting agent permissions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var masks map[string]string
+		if err := rows.Scan(&masks); err != nil {
+			return nil, errors.Wrap(err, "error while scanning agent permissions")
+		}
+		for slug, mask := range masks {
+			access.Masks[slug] = authz.Stricter(access.Masks[slug], authz.Mask(mask))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error while getting agent permissions")
+	}
+
+	for _, mask := range access.Masks {
+		if mask != authz.MaskHash {
+			continue
+		}
+		keyring, err := ensureKeyring(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if keyring != nil {
+			access.Hasher = keyring
+		}
+		break
+	}
+
+	return access, nil
+}
+
+// FieldAccessLoader returns a LoadFieldAccess of the subject of ctx or
+// params that loads the access on each table once.
+func FieldAccessLoader(ctx context.Context, conn *psqlpool.Pool, params map[string]any) func(tableSlug string) (*FieldAccess, error) {
+	accesses := make(map[string]*FieldAccess)
+
+	return func(tableSlug string) (*FieldAccess, error) {
+		if access, ok := accesses[tableSlug]; ok {
+			return access, nil
+		}
+		access, err := LoadFieldAccess(ctx, conn, tableSlug, params)
+		if err != nil {
+			return nil, err
+		}
+		accesses[tableSlug] = access
+		return access, nil
+	}
+}
+
+// Apply decrypts the encrypted fields of item, drops the hidden ones and
+// masks the masked ones. The related rows of hidden or masked lookups are
+// dropped as well.
+func (a *FieldAccess) Apply(item map[string]any) {
+	if a == nil {
+		return
+	}
+
+	a.Cipher.DecryptItem(item)
+	for slug := range a.Hidden {
+		delete(item, slug)
+		delete(item, slug+"_data")
+	}
+	for slug, mask := range a.Masks {
+		if value, ok := item[slug]; ok {
+			item[slug] = mask.Apply(value, a.Hasher)
+		}
+		delete(item, slug+"_data")
+	}
+}
+
+// keepHidden returns the access of reads that keep hidden fields. Hidden
+// encrypted fields are fully masked instead of shown decrypted.
+func (a *FieldAccess) keepHidden() *FieldAccess {
+	if a == nil || len(a.Hidden) == 0 {
+		return a
+	}
+
+	access := *a
+	access.Hidden = nil
+	access.Masks = make(map[string]authz.Mask, len(a.Masks))
+	for slug, mask := range a.Masks {
+		access.Masks[slug] = mask
+	}
+	for slug := range a.Hidden {
+		if a.Cipher.Encrypted(slug) {
+			access.Masks[slug] = authz.MaskFull
+		}
+	}
+
+	return &access
+}
+
+// Value returns value of the field slug as the subject sees it: nil when
+// the field is hidden, decrypted and then masked otherwise.
+func (a *FieldAccess) Value(slug string, value any) any {
+	if a == nil {
+		return value
+	}
+	if a.Hidden[slug] {
+		return nil
+	}
+	return a.Masks[slug].Apply(a.Cipher.Decrypt(slug, value), a.Hasher)
+}
+
+// ProjectItems applies the projection of params to items. Items are left
+// as they are when params ask for no projection.
+func ProjectItems(params map[string]any, items ...map[string]any) {
+	projection := NewProjection(params)
+	if projection == nil {
+		return
+	}
+
+	for _, item := range items {
+		projection.Apply(item)
+	}
+}
+
+// ApplyFieldAccess decrypts and masks the fields of items as the subject
+// of ctx or params reads them. Hidden fields are dropped only from
+// projected reads, after ProjectItems, so a projection can only narrow
+// what the role sees; other reads leave hiding them to the field list.
+func ApplyFieldAccess(ctx context.Context, conn *psqlpool.Pool, tableSlug string, params map[string]any, items ...map[string]any) error {
+	access, err := LoadFieldAccess(ctx, conn, tableSlug, params)
+	if err != nil {
+		return err
+	}
+
+	if NewProjection(params) == nil {
+		access = access.keepHidden()
+	}
+
+	for _, item := range items {
+		access.Apply(item)
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package helper
+
+import (
+	"sort"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjection(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]any
+		nil     bool
+		keeps   map[string]bool
+		selects map[string]bool
+		columns map[string][]string
+	}{
+		{
+			name:  "no projection",
+			nil:   true,
+			keeps: map[string]bool{"title": true, "author_id_data": true},
+		},
+		{
+			name:    "fields",
+			params:  map[string]any{"fields": []any{"title", "author_id"}},
+			keeps:   map[string]bool{"guid": true, "title": true, "author_id": true, "body": false, "author_id_data": false},
+			selects: map[string]bool{"author_id": true, "body": false},
+		},
+		{
+			name:    "exclude fields",
+			params:  map[string]any{"exclude_fields": []any{"body"}},
+			keeps:   map[string]bool{"guid": true, "title": true, "body": false},
+			selects: map[string]bool{"title": true},
+		},
+		{
+			name:    "nested fields",
+			params:  map[string]any{"fields": []any{"title", "author_id_data.name", "author_id_data.email"}},
+			keeps:   map[string]bool{"title": true, "author_id": false, "author_id_data": true},
+			selects: map[string]bool{"author_id": true, "editor_id": false},
+			columns: map[string][]string{"author_id": {"email", "name"}, "editor_id": nil},
+		},
+		{
+			name:    "nested exclude keeps the related row",
+			params:  map[string]any{"exclude_fields": []any{"author_id_data.email"}},
+			keeps:   map[string]bool{"author_id_data": true},
+			columns: map[string][]string{"author_id": nil},
+		},
+		{
+			name:   "guid can not be excluded",
+			params: map[string]any{"exclude_fields": []any{"guid"}},
+			keeps:  map[string]bool{"guid": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProjection(tt.params)
+			assert.Equal(t, tt.nil, p == nil)
+			for field, want := range tt.keeps {
+				assert.Equal(t, want, p.Keeps(field), field)
+			}
+			for field, want := range tt.selects {
+				assert.Equal(t, want, p.Selects(field), field)
+			}
+			for field, want := range tt.columns {
+				columns := p.Columns(field)
+				sort.Strings(columns)
+				assert.Equal(t, want, columns, field)
+			}
+		})
+	}
+}
+
+func TestProjectItems(t *testing.T) {
+	item := func() map[string]any {
+		return map[string]any{
+			"guid":           "g",
+			"title":          "a",
+			"body":           "b",
+			"author_id":      "u",
+			"author_id_data": map[string]any{"guid": "u", "name": "n", "email": "e"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]any
+		want   map[string]any
+	}{
+		{name: "no projection", want: item()},
+		{
+			name:   "fields",
+			params: map[string]any{"fields": []any{"title"}},
+			want:   map[string]any{"guid": "g", "title": "a"},
+		},
+		{
+			name:   "exclude fields",
+			params: map[string]any{"exclude_fields": []any{"body", "author_id_data"}},
+			want:   map[string]any{"guid": "g", "title": "a", "author_id": "u"},
+		},
+		{
+			name:   "nested fields",
+			params: map[string]any{"fields": []any{"author_id_data.name"}},
+			want:   map[string]any{"guid": "g", "author_id_data": map[string]any{"guid": "u", "name": "n"}},
+		},
+		{
+			name:   "nested exclude",
+			params: map[string]any{"fields": []any{"title", "author_id_data"}, "exclude_fields": []any{"author_id_data.email"}},
+			want:   map[string]any{"guid": "g", "title": "a", "author_id_data": map[string]any{"guid": "u", "name": "n"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := item()
+			ProjectItems(tt.params, got)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFieldRuleRead(t *testing.T) {
+	var (
+		on  = true
+		off = false
+	)
+
+	tests := []struct {
+		name    string
+		rule    FieldRule
+		sealed  bool
+		visible bool
+		mask    authz.Mask
+	}{
+		{name: "no rule", visible: true},
+		{name: "viewable", rule: FieldRule{View: &on}, visible: true},
+		{name: "hidden", rule: FieldRule{View: &off, Mask: "last4"}},
+		{name: "masked", rule: FieldRule{Mask: "last4"}, visible: true, mask: authz.MaskLast4},
+		{name: "sealed", sealed: true, visible: true, mask: authz.MaskFull},
+		{name: "sealed with decrypt", rule: FieldRule{Decrypt: true, Mask: "hash"}, sealed: true, visible: true, mask: authz.MaskHash},
+		{name: "sealed and hidden", rule: FieldRule{View: &off}, sealed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visible, mask := tt.rule.Read(tt.sealed)
+			assert.Equal(t, tt.visible, visible)
+			assert.Equal(t, tt.mask, mask)
+		})
+	}
+}
+
+func TestFieldAccess(t *testing.T) {
+	off := false
+
+	access := NewFieldAccess(map[string]FieldRule{
+		"salary": {View: &off},
+		"phone":  {Mask: "last4"},
+		"title":  {},
+	}, map[string]bool{"ssn": true})
+
+	assert.Equal(t, map[string]bool{"salary": true}, access.Hidden)
+	assert.Equal(t, map[string]authz.Mask{"phone": authz.MaskLast4, "ssn": authz.MaskFull}, access.Masks)
+
+	item := map[string]any{
+		"guid":           "g",
+		"title":          "a",
+		"salary":         100,
+		"salary_data":    map[string]any{"guid": "s"},
+		"phone":          "555123456",
+		"phone_data":     map[string]any{"guid": "p"},
+		"ssn":            "123",
+		"author_id_data": map[string]any{"guid": "u"},
+	}
+	access.Apply(item)
+	assert.Equal(t, map[string]any{
+		"guid":           "g",
+		"title":          "a",
+		"phone":          authz.MaskLast4.Apply("555123456", nil),
+		"ssn":            authz.MaskFull.Apply("123", nil),
+		"author_id_data": map[string]any{"guid": "u"},
+	}, item)
+
+	assert.Nil(t, access.Value("salary", 100))
+	assert.Equal(t, "a", access.Value("title", "a"))
+}
+
+func TestFieldAccessKeepHidden(t *testing.T) {
+	tests := []struct {
+		name   string
+		access *FieldAccess
+		masks  map[string]authz.Mask
+	}{
+		{name: "nil"},
+		{
+			name:   "nothing hidden",
+			access: &FieldAccess{Masks: map[string]authz.Mask{"phone": authz.MaskLast4}},
+			masks:  map[string]authz.Mask{"phone": authz.MaskLast4},
+		},
+		{
+			name: "hidden fields are kept",
+			access: &FieldAccess{
+				Hidden: map[string]bool{"salary": true},
+				Masks:  map[string]authz.Mask{"phone": authz.MaskLast4},
+			},
+			masks: map[string]authz.Mask{"phone": authz.MaskLast4},
+		},
+		{
+			name: "hidden encrypted fields are masked",
+			access: &FieldAccess{
+				Hidden: map[string]bool{"salary": true, "ssn": true},
+				Masks:  map[string]authz.Mask{},
+				Cipher: NewFieldCipher("employee", map[string]bool{"ssn": true}, nil),
+			},
+			masks: map[string]authz.Mask{"ssn": authz.MaskFull},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept := tt.access.keepHidden()
+			if tt.access == nil {
+				assert.Nil(t, kept)
+				return
+			}
+			assert.Empty(t, kept.Hidden)
+			assert.Equal(t, tt.masks, kept.Masks)
+		})
+	}
+}
@@ -24,8 +24,8 @@ func GetRecordPermission(ctx context.Context, req models.GetRecordPermissionRequ
 			"delete",
 			"is_public",
 			"is_have_condition"
-		FROM "record_permission"
-		WHERE table_slug = $1 AND role_id = $2
+		FROM "effective_record_permission"
+		WHERE table_slug = $1 AND effective_role_id::TEXT = $2
 	`
 
 	err := req.Conn.QueryRow(ctx, query, req.TableSlug, req.RoleId).Scan(
@@ -407,6 +407,58 @@ func CreateRelationWithTx(ctx context.Context, data *models.CreateRelationReques
 	return resp, nil
 }
 
+// CreateDynamicLookupField adds the DYNAMIC_LOOKUP field of a Many2Dynamic
+// relation to table_from. The field stores the table slug and guid of the
+// linked row.
+func CreateDynamicLookupField(ctx context.Context, req models.RelationHelper, data *nb.CreateRelationRequest) error {
+	if data.RelationFieldSlug == "" {
+		return errors.New("relation field slug is required")
+	}
+
+	table, err := TableFindOneTx(ctx, req.Tx, data.TableFrom)
+	if err != nil {
+		return errors.Wrap(err, "failed to find table_from")
+	}
+
+	var exists bool
+	err = req.Tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM field WHERE table_id = $1 AND slug = $2)`, table.Id, data.RelationFieldSlug).Scan(&exists)
+	if err != nil {
+		return errors.Wrap(err, "failed to check relation field exists")
+	}
+	if exists {
+		return fmt.Errorf("field %s already exists in %s", data.RelationFieldSlug, data.TableFrom)
+	}
+
+	field, err := UpsertField(ctx, models.RelationHelper{
+		Tx: req.Tx,
+		Field: &nb.CreateFieldRequest{
+			Id:         data.RelationFieldId,
+			TableId:    table.Id,
+			Slug:       data.RelationFieldSlug,
+			Label:      "FROM " + data.TableFrom + " TO DYNAMIC",
+			Type:       config.DYNAMIC_LOOKUP,
+			RelationId: data.Id,
+			Attributes: data.Attributes,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to upsert field")
+	}
+
+	err = RelationFieldPermission(ctx, models.RelationHelper{
+		Tx:        req.Tx,
+		FieldID:   field.Id,
+		TableSlug: data.TableFrom,
+		Label:     "FROM " + data.TableFrom + " TO DYNAMIC",
+		RoleIDs:   req.RoleIDs,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create relation field permission")
+	}
+
+	return nil
+}
+
 func CheckRelationFieldExists(ctx context.Context, req models.RelationHelper) (bool, string, error) {
 	rows, err := req.Tx.Query(ctx, "SELECT slug FROM field WHERE table_id = $1 AND slug LIKE $2 ORDER BY slug ASC", req.TableID, req.FieldName+"%")
 	if err != nil {
@@ -674,7 +726,7 @@ func ViewCreate(ctx context.Context, req models.RelationHelper) error {
 
 func RolesFind(ctx context.Context, req models.RelationHelper) (resp []string, err error) {
 	resp = []string{}
-	query := `SELECT guid FROM role`
+	query := `SELECT guid FROM role WHERE parent_id IS NULL`
 
 	rows, err := req.Tx.Query(ctx, query)
 	if err != nil {
@@ -852,15 +904,19 @@ func ExecRelation(ctx context.Context, req models.RelationHelper) error {
 	switch req.RelationType {
 	case config.MANY2ONE:
 		alterTableSQL = fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN  %s UUID;`, req.TableFrom, req.FieldFrom)
-		addConstraintSQL = fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT fk_%s_%s FOREIGN KEY (%s) REFERENCES "%s"(guid) ON DELETE SET NULL;
-    `, req.TableFrom, req.TableFrom, req.FieldFrom, req.FieldFrom, req.TableTo)
+		addConstraintSQL = fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT fk_%s_%s FOREIGN KEY (%s) REFERENCES "%s"(guid) ON DELETE %s;
+    `, req.TableFrom, req.TableFrom, req.FieldFrom, req.FieldFrom, req.TableTo, onDeleteAction(req.OnDelete))
 	case config.MANY2MANY:
 		alterTableSQL = fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN  %s VARCHAR[]`, req.TableFrom, req.FieldFrom)
 		addConstraintSQL = fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN  %s VARCHAR[]`, req.TableTo, req.FieldTo)
+	case config.MANY2DYNAMIC:
+		alterTableSQL = fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS %s JSONB`, req.TableFrom, req.FieldFrom)
+		addConstraintSQL = fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s_%s_link_idx" ON "%s" ((%s->>'table_slug'), (%s->>'guid'))`,
+			req.TableFrom, req.FieldFrom, req.TableFrom, req.FieldFrom, req.FieldFrom)
 	case config.RECURSIVE:
 		alterTableSQL = fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN  %s UUID`, req.TableFrom, req.FieldTo)
-		addConstraintSQL = fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT fk_%s_%s FOREIGN KEY (%s) REFERENCES "%s"(guid) ON DELETE SET NULL;
-		`, req.TableFrom, req.TableFrom, req.TableFrom, req.FieldTo, req.TableFrom)
+		addConstraintSQL = fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT fk_%s_%s FOREIGN KEY (%s) REFERENCES "%s"(guid) ON DELETE %s;
+		`, req.TableFrom, req.TableFrom, req.TableFrom, req.FieldTo, req.TableFrom, onDeleteAction(req.OnDelete))
 	}
 
 	if _, err := req.Tx.Exec(ctx, alterTableSQL); err != nil {
@@ -873,6 +929,100 @@ func ExecRelation(ctx context.Context, req models.RelationHelper) error {
 	return nil
 }
 
+// OnDeletePolicy reads the on_delete policy from relation attributes.
+// "set_null" and "SET NULL" are the same policy. SET NULL, what relations
+// did before they had a policy, is the default.
+func OnDeletePolicy(attributes map[string]any) (string, error) {
+	value := cast.ToString(attributes["on_delete"])
+	policy := strings.ToUpper(strings.TrimSpace(strings.ReplaceAll(value, "_", " ")))
+
+	switch policy {
+	case "":
+		return config.ON_DELETE_SET_NULL, nil
+	case config.ON_DELETE_CASCADE, config.ON_DELETE_RESTRICT, config.ON_DELETE_SET_NULL, config.ON_DELETE_NO_ACTION:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("invalid on_delete %q, expected one of CASCADE, RESTRICT, SET NULL, NO ACTION", value)
+}
+
+func onDeleteAction(policy string) string {
+	if policy == "" {
+		return config.ON_DELETE_SET_NULL
+	}
+	return policy
+}
+
+// ReplaceForeignKey recreates the foreign key of a Many2One or Recursive
+// relation with the ON DELETE action of policy.
+func ReplaceForeignKey(ctx context.Context, tx pgx.Tx, relationType, tableFrom, tableTo, fieldFrom, fieldTo, policy string) error {
+	var name, column, references string
+
+	switch relationType {
+	case config.MANY2ONE:
+		name, column, references = fmt.Sprintf("fk_%s_%s", tableFrom, fieldFrom), fieldFrom, tableTo
+	case config.RECURSIVE:
+		name, column, references = fmt.Sprintf("fk_%s_%s", tableFrom, tableFrom), fieldTo, tableFrom
+	default:
+		return nil
+	}
+
+	query := fmt.Sprintf(`ALTER TABLE "%s" DROP CONSTRAINT IF EXISTS %s, ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES "%s"(guid) ON DELETE %s`,
+		tableFrom, name, name, column, references, onDeleteAction(policy))
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return errors.Wrap(err, "failed to replace foreign key")
+	}
+
+	return nil
+}
+
+// EnableTreePath adds the materialized path of a recursive relation to
+// tableSlug: a tree_path column, filled for the existing rows and kept up
+// to date by triggers when column, the parent id, changes.
+func EnableTreePath(ctx context.Context, tx pgx.Tx, tableSlug, column string) error {
+	queries := []string{
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS %s TEXT`, tableSlug, config.TREE_PATH),
+		fmt.Sprintf(`WITH RECURSIVE tree AS (
+			SELECT guid, '/' || guid::TEXT || '/' AS path FROM "%[1]s" WHERE %[2]s IS NULL
+			UNION ALL
+			SELECT c.guid, tree.path || c.guid::TEXT || '/' FROM "%[1]s" c JOIN tree ON c.%[2]s = tree.guid
+		)
+		UPDATE "%[1]s" t SET %[3]s = tree.path FROM tree WHERE t.guid = tree.guid`, tableSlug, column, config.TREE_PATH),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s_tree_path_idx" ON "%s" (%s text_pattern_ops)`, tableSlug, tableSlug, config.TREE_PATH),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS set_tree_path ON "%s"`, tableSlug),
+		fmt.Sprintf(`CREATE TRIGGER set_tree_path BEFORE INSERT OR UPDATE OF %s ON "%s"
+			FOR EACH ROW EXECUTE PROCEDURE set_tree_path('%s')`, column, tableSlug, column),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS move_tree_subpaths ON "%s"`, tableSlug),
+		fmt.Sprintf(`CREATE TRIGGER move_tree_subpaths AFTER UPDATE OF %s ON "%s"
+			FOR EACH ROW WHEN (OLD.%s IS DISTINCT FROM NEW.%s) EXECUTE PROCEDURE move_tree_subpaths()`, column, tableSlug, config.TREE_PATH, config.TREE_PATH),
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return errors.Wrap(err, "failed to enable tree path")
+		}
+	}
+
+	return nil
+}
+
+// DisableTreePath drops the materialized path added by EnableTreePath.
+func DisableTreePath(ctx context.Context, tx pgx.Tx, tableSlug string) error {
+	queries := []string{
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS set_tree_path ON "%s"`, tableSlug),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS move_tree_subpaths ON "%s"`, tableSlug),
+		fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN IF EXISTS %s`, tableSlug, config.TREE_PATH),
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return errors.Wrap(err, "failed to disable tree path")
+		}
+	}
+
+	return nil
+}
+
 func ViewFindOne(ctx context.Context, req models.RelationHelper) (resp *nb.View, err error) {
 	resp = &nb.View{}
 	query := `
@@ -1098,6 +1248,10 @@ func RemoveRelation(ctx context.Context, req models.RelationHelper) error {
 			return err
 		}
 	case config.RECURSIVE:
+		if err := DisableTreePath(ctx, req.Tx, req.TableFrom); err != nil {
+			return err
+		}
+
 		query := fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN %s`, req.TableFrom, req.FieldName)
 		if _, err := req.Tx.Exec(ctx, query); err != nil {
 			return err
@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// MaxRoleDepth bounds the chain of parents of a role, as role_chain does.
+const MaxRoleDepth = 16
+
+// RowQuerier is the part of a pool or a transaction LoadRoleChain needs.
+type RowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// IsAdminClientType reports whether clientTypeId is the ADMIN client type.
+func IsAdminClientType(ctx context.Context, q RowQuerier, clientTypeId string) (bool, error) {
+	if clientTypeId == "" {
+		return false, nil
+	}
+
+	var admin bool
+	err := q.QueryRow(ctx, `SELECT name = 'ADMIN' FROM client_type WHERE guid::TEXT = $1`, clientTypeId).Scan(&admin)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, errors.Wrap(err, "error while getting client type")
+	}
+
+	return admin, nil
+}
+
+// LoadRoleChain returns roleId followed by its parents, nearest first, as
+// the role_chain function of the database resolves them. Permission reads
+// go through the effective_<table> views instead; the chain is for the
+// writes that need the current parents. An unknown role is a chain of
+// itself.
+func LoadRoleChain(ctx context.Context, conn RowQuerier, roleId string) ([]string, error) {
+	var chain []string
+	err := conn.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(c.role_id::TEXT ORDER BY c.depth), '{}')
+		FROM role r
+		CROSS JOIN LATERAL role_chain(r.guid) c
+		WHERE r.guid::TEXT = $1`, roleId,
+	).Scan(&chain)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting role parents")
+	}
+	if len(chain) == 0 {
+		chain = []string{roleId}
+	}
+
+	return chain, nil
+}
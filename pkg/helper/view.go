@@ -129,7 +129,7 @@ func GetViewWithPermission(ctx context.Context, req *models.GetViewWithPermissio
 		"view",
 		"edit",
 		"delete"
-	FROM "view_permission" WHERE "view_id" = $1 AND "role_id" = $2`
+	FROM "effective_view_permission" WHERE "view_id" = $1 AND "effective_role_id"::TEXT = $2`
 
 	for _, view := range views {
 
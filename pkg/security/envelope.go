@@ -0,0 +1,190 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KeySize is the size of master and data keys, for AES-256.
+const KeySize = 32
+
+// CiphertextPrefix starts every encrypted value, which is stored as
+// "enc:v1:<data key version>:<base64 nonce and ciphertext>".
+const CiphertextPrefix = "enc:v1:"
+
+// GenerateKey returns a random key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.Wrap(err, "error while generating key")
+	}
+	return key, nil
+}
+
+// ParseKey decodes a base64 key, as master keys are given in config.
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.Wrap(err, "error while decoding key")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key is %d bytes, want %d", len(key), KeySize)
+	}
+	return key, nil
+}
+
+// KeyId names a key without revealing it, so a wrapped data key records
+// the master key it was wrapped with.
+func KeyId(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Seal encrypts plaintext with AES-GCM under key. The nonce is prepended
+// to the result.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "error while generating nonce")
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts what Seal returned.
+func Open(key, sealed []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while decrypting")
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while creating cipher")
+	}
+	return cipher.NewGCM(block)
+}
+
+// Keyring holds the data keys of a tenant by version and its blind index
+// key. Values are encrypted with the active version and decrypted with
+// the version they name.
+type Keyring struct {
+	active int
+	keys   map[int][]byte
+	index  []byte
+}
+
+// NewKeyring returns a keyring encrypting with the data key of version
+// active.
+func NewKeyring(active int, keys map[int][]byte, index []byte) *Keyring {
+	return &Keyring{active: active, keys: keys, index: index}
+}
+
+// Active is the version of the data key new values are encrypted with.
+func (k *Keyring) Active() int {
+	return k.active
+}
+
+// Encrypt encrypts value with the active data key.
+func (k *Keyring) Encrypt(value string) (string, error) {
+	key, ok := k.keys[k.active]
+	if !ok {
+		return "", fmt.Errorf("data key %d is missing", k.active)
+	}
+
+	sealed, err := Seal(key, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return CiphertextPrefix + strconv.Itoa(k.active) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value Encrypt returned. Values that are not
+// encrypted are returned as they are.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	version, ok := KeyVersion(value)
+	if !ok {
+		return value, nil
+	}
+
+	key, ok := k.keys[version]
+	if !ok {
+		return "", fmt.Errorf("data key %d is missing", version)
+	}
+
+	_, encoded, _ := strings.Cut(strings.TrimPrefix(value, CiphertextPrefix), ":")
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.Wrap(err, "error while decoding ciphertext")
+	}
+
+	plaintext, err := Open(key, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex returns the HMAC of value under the index key. Equal values
+// have equal indexes, so encrypted fields can be searched for equality.
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// MaskHash returns the HMAC of value under a key derived from the index
+// key. Equal values have equal hashes, but unlike a plain hash they can
+// not be found by hashing guesses, nor matched with the blind indexes.
+func (k *Keyring) MaskHash(value string) string {
+	derive := hmac.New(sha256.New, k.index)
+	derive.Write([]byte("mask"))
+
+	mac := hmac.New(sha256.New, derive.Sum(nil))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// KeyVersion returns the version of the data key value is encrypted with,
+// and false when value is not encrypted.
+func KeyVersion(value string) (int, bool) {
+	rest, ok := strings.CutPrefix(value, CiphertextPrefix)
+	if !ok {
+		return 0, false
+	}
+
+	version, _, ok := strings.Cut(rest, ":")
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,105 @@
+package security_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/security"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpen(t *testing.T) {
+	master, err := security.GenerateKey()
+	require.NoError(t, err)
+	dataKey, err := security.GenerateKey()
+	require.NoError(t, err)
+
+	wrapped, err := security.Seal(master, dataKey)
+	require.NoError(t, err)
+
+	unwrapped, err := security.Open(master, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	other, err := security.GenerateKey()
+	require.NoError(t, err)
+	_, err = security.Open(other, wrapped)
+	assert.Error(t, err)
+}
+
+func TestParseKey(t *testing.T) {
+	key, err := security.GenerateKey()
+	require.NoError(t, err)
+
+	parsed, err := security.ParseKey(base64.StdEncoding.EncodeToString(key))
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	_, err = security.ParseKey(base64.StdEncoding.EncodeToString(key[:16]))
+	assert.Error(t, err)
+
+	_, err = security.ParseKey("not base64")
+	assert.Error(t, err)
+}
+
+func TestKeyring(t *testing.T) {
+	v1, err := security.GenerateKey()
+	require.NoError(t, err)
+	v2, err := security.GenerateKey()
+	require.NoError(t, err)
+	index, err := security.GenerateKey()
+	require.NoError(t, err)
+
+	old := security.NewKeyring(1, map[int][]byte{1: v1}, index)
+	current := security.NewKeyring(2, map[int][]byte{1: v1, 2: v2}, index)
+
+	encrypted, err := old.Encrypt("AA1234567")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, security.CiphertextPrefix+"1:"))
+	assert.NotContains(t, encrypted, "AA1234567")
+
+	again, err := old.Encrypt("AA1234567")
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again)
+
+	version, ok := security.KeyVersion(encrypted)
+	assert.True(t, ok)
+	assert.Equal(t, 1, version)
+
+	decrypted, err := current.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "AA1234567", decrypted)
+
+	rotated, err := current.Encrypt(decrypted)
+	require.NoError(t, err)
+	version, _ = security.KeyVersion(rotated)
+	assert.Equal(t, 2, version)
+
+	_, err = old.Decrypt(rotated)
+	assert.Error(t, err)
+
+	plain, err := current.Decrypt("plain text")
+	require.NoError(t, err)
+	assert.Equal(t, "plain text", plain)
+
+	assert.Equal(t, old.BlindIndex("AA1234567"), current.BlindIndex("AA1234567"))
+	assert.NotEqual(t, current.BlindIndex("AA1234567"), current.BlindIndex("AA1234568"))
+
+	assert.Equal(t, old.MaskHash("AA1234567"), current.MaskHash("AA1234567"))
+	assert.NotEqual(t, current.BlindIndex("AA1234567"), current.MaskHash("AA1234567"))
+}
+
+func TestKeyVersion(t *testing.T) {
+	_, ok := security.KeyVersion("enc:v1:x:abc")
+	assert.False(t, ok)
+
+	_, ok = security.KeyVersion("enc:v1:3")
+	assert.False(t, ok)
+
+	version, ok := security.KeyVersion("enc:v1:3:abc")
+	assert.True(t, ok)
+	assert.Equal(t, 3, version)
+}
@@ -0,0 +1,189 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// MaxPasswordHistory caps how many previous passwords a policy can forbid
+// reusing, as each one costs a bcrypt comparison.
+const MaxPasswordHistory = 24
+
+// LoginPolicy is the login policy of a client type, stored as JSON in
+// client_type.login_policy. Fields left out keep their default.
+type LoginPolicy struct {
+	// MaxAttempts failed logins of a user lock it out for LockoutSeconds.
+	// Zero turns the lockout off.
+	MaxAttempts int `json:"max_attempts"`
+	// IpMaxAttempts failed logins from an IP lock it out the same way.
+	IpMaxAttempts  int `json:"ip_max_attempts"`
+	LockoutSeconds int `json:"lockout_seconds"`
+	// After n failed logins the next one is allowed BackoffSeconds*2^(n-1)
+	// seconds later, at most MaxBackoffSeconds.
+	BackoffSeconds    int `json:"backoff_seconds"`
+	MaxBackoffSeconds int `json:"max_backoff_seconds"`
+
+	Password PasswordPolicy `json:"password"`
+}
+
+// PasswordPolicy is what passwords of a client type must satisfy.
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+	// History forbids reusing the last History passwords.
+	History int `json:"history"`
+	// MaxAgeDays expires passwords older than that. Zero never expires.
+	MaxAgeDays int `json:"max_age_days"`
+}
+
+// DefaultLoginPolicy is the policy of client types that set none. Its
+// password rules are the ones util.ValidStrongPassword checks.
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MaxAttempts:       5,
+		IpMaxAttempts:     50,
+		LockoutSeconds:    900,
+		BackoffSeconds:    1,
+		MaxBackoffSeconds: 60,
+		Password: PasswordPolicy{
+			MinLength:    6,
+			RequireUpper: true,
+			RequireLower: true,
+			RequireDigit: true,
+		},
+	}
+}
+
+// ParseLoginPolicy reads a policy over the defaults. An empty string is the
+// default policy.
+func ParseLoginPolicy(raw string) (LoginPolicy, error) {
+	policy := DefaultLoginPolicy()
+	if strings.TrimSpace(raw) == "" {
+		return policy, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil {
+		return policy, errors.Wrap(err, "error while parsing login policy")
+	}
+	policy.Password.History = min(max(policy.Password.History, 0), MaxPasswordHistory)
+
+	return policy, nil
+}
+
+// Validate returns why password does not satisfy the policy.
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.MinLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	switch {
+	case p.RequireUpper && !upper:
+		return errors.New("password must contain at least one uppercase letter")
+	case p.RequireLower && !lower:
+		return errors.New("password must contain at least one lowercase letter")
+	case p.RequireDigit && !digit:
+		return errors.New("password must contain at least one digit")
+	case p.RequireSymbol && !symbol:
+		return errors.New("password must contain at least one symbol")
+	}
+
+	return nil
+}
+
+// Expired reports whether a password changed at changedAt has expired.
+func (p PasswordPolicy) Expired(changedAt, now time.Time) bool {
+	return p.MaxAgeDays > 0 && now.After(changedAt.AddDate(0, 0, p.MaxAgeDays))
+}
+
+// LoginAttempts are the recent failed logins of a user or an IP.
+type LoginAttempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+func (p LoginPolicy) lockout() time.Duration {
+	return time.Duration(p.LockoutSeconds) * time.Second
+}
+
+// Backoff returns how long to wait after failures failed logins.
+func (p LoginPolicy) Backoff(failures int) time.Duration {
+	if failures <= 0 || p.BackoffSeconds <= 0 {
+		return 0
+	}
+
+	var (
+		backoff = time.Duration(p.BackoffSeconds) * time.Second
+		limit   = time.Duration(p.MaxBackoffSeconds) * time.Second
+	)
+	for n := 1; n < failures && (limit <= 0 || backoff < limit); n++ {
+		backoff *= 2
+	}
+	if limit > 0 && backoff > limit {
+		backoff = limit
+	}
+
+	return backoff
+}
+
+// RetryAt returns when the next login is allowed after a.
+func (p LoginPolicy) RetryAt(a LoginAttempts) time.Time {
+	retry := a.LockedUntil
+	if next := a.LastFailure.Add(p.Backoff(a.Failures)); a.Failures > 0 && next.After(retry) {
+		retry = next
+	}
+	return retry
+}
+
+// Fail returns a after a failed login at now. maxAttempts failures lock
+// the subject out; failures are forgotten once the lockout period passed
+// since the last one or a lockout ended.
+func (p LoginPolicy) Fail(a LoginAttempts, maxAttempts int, now time.Time) LoginAttempts {
+	expired := !a.LastFailure.IsZero() && now.Sub(a.LastFailure) > p.lockout()
+	unlocked := !a.LockedUntil.IsZero() && !now.Before(a.LockedUntil)
+	if expired || unlocked {
+		a = LoginAttempts{}
+	}
+
+	a.Failures++
+	a.LastFailure = now
+	if maxAttempts > 0 && a.Failures >= maxAttempts {
+		a.LockedUntil = now.Add(p.lockout())
+	}
+
+	return a
+}
+
+// Refund returns a without the failure counted for a login that then
+// succeeded, and without the lockout that failure caused.
+func (p LoginPolicy) Refund(a LoginAttempts, maxAttempts int) LoginAttempts {
+	if a.Failures > 0 {
+		a.Failures--
+	}
+	if maxAttempts <= 0 || a.Failures < maxAttempts {
+		a.LockedUntil = time.Time{}
+	}
+	return a
+}
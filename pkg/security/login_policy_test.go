@@ -0,0 +1,103 @@
+package security_test
+
+import (
+	"testing"
+	"time"
+
+	"ucode/ucode_go_object_builder_service/pkg/security"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLoginPolicy(t *testing.T) {
+	policy, err := security.ParseLoginPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, security.DefaultLoginPolicy(), policy)
+
+	policy, err = security.ParseLoginPolicy(`{"max_attempts": 3, "password": {"min_length": 12, "history": 100}}`)
+	require.NoError(t, err)
+	assert.Equal(t, 3, policy.MaxAttempts)
+	assert.Equal(t, 900, policy.LockoutSeconds)
+	assert.Equal(t, 12, policy.Password.MinLength)
+	assert.True(t, policy.Password.RequireUpper)
+	assert.Equal(t, security.MaxPasswordHistory, policy.Password.History)
+
+	_, err = security.ParseLoginPolicy(`{"max_attempts": "3"}`)
+	assert.Error(t, err)
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := security.DefaultLoginPolicy().Password
+
+	assert.NoError(t, policy.Validate("Secret1"))
+	assert.Error(t, policy.Validate("Sec1"))
+	assert.Error(t, policy.Validate("secret1"))
+	assert.Error(t, policy.Validate("SECRET1"))
+	assert.Error(t, policy.Validate("Secrets"))
+
+	policy.RequireSymbol = true
+	assert.Error(t, policy.Validate("Secret1"))
+	assert.NoError(t, policy.Validate("Secret1!"))
+
+	changed := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.False(t, policy.Expired(changed, changed.AddDate(1, 0, 0)))
+	policy.MaxAgeDays = 90
+	assert.False(t, policy.Expired(changed, changed.AddDate(0, 0, 90)))
+	assert.True(t, policy.Expired(changed, changed.AddDate(0, 0, 91)))
+}
+
+func TestLoginPolicyLockout(t *testing.T) {
+	var (
+		policy   = security.DefaultLoginPolicy()
+		now      = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		attempts security.LoginAttempts
+	)
+
+	assert.Equal(t, time.Duration(0), policy.Backoff(0))
+	assert.Equal(t, time.Second, policy.Backoff(1))
+	assert.Equal(t, 8*time.Second, policy.Backoff(4))
+	assert.Equal(t, time.Minute, policy.Backoff(30))
+
+	for n := 1; n < policy.MaxAttempts; n++ {
+		attempts = policy.Fail(attempts, policy.MaxAttempts, now)
+		assert.True(t, attempts.LockedUntil.IsZero())
+		assert.Equal(t, now.Add(policy.Backoff(n)), policy.RetryAt(attempts))
+		now = policy.RetryAt(attempts)
+	}
+
+	attempts = policy.Fail(attempts, policy.MaxAttempts, now)
+	assert.Equal(t, now.Add(15*time.Minute), policy.RetryAt(attempts))
+
+	now = attempts.LockedUntil
+	attempts = policy.Fail(attempts, policy.MaxAttempts, now)
+	assert.Equal(t, 1, attempts.Failures)
+	assert.True(t, attempts.LockedUntil.IsZero())
+
+	attempts = policy.Fail(attempts, policy.MaxAttempts, now.Add(time.Hour))
+	assert.Equal(t, 1, attempts.Failures)
+
+	attempts = policy.Fail(security.LoginAttempts{}, 0, now)
+	assert.True(t, attempts.LockedUntil.IsZero())
+}
+
+func TestLoginPolicyRefund(t *testing.T) {
+	var (
+		policy = security.DefaultLoginPolicy()
+		now    = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	attempts := policy.Fail(security.LoginAttempts{}, 2, now)
+	attempts = policy.Refund(attempts, 2)
+	assert.Equal(t, 0, attempts.Failures)
+
+	attempts = policy.Fail(policy.Fail(security.LoginAttempts{}, 2, now), 2, now)
+	assert.False(t, attempts.LockedUntil.IsZero())
+	attempts = policy.Refund(attempts, 2)
+	assert.Equal(t, 1, attempts.Failures)
+	assert.True(t, attempts.LockedUntil.IsZero())
+
+	attempts = policy.Fail(policy.Fail(policy.Fail(security.LoginAttempts{}, 0, now), 0, now), 0, now)
+	assert.Equal(t, 2, policy.Refund(attempts, 0).Failures)
+	assert.Equal(t, 0, policy.Refund(security.LoginAttempts{}, 2).Failures)
+}
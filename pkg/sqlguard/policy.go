@@ -0,0 +1,237 @@
+package sqlguard
+
+import (
+	"fmt"
+	"strings"
+)
+
+// systemSchemas can never be read or written by user SQL.
+var systemSchemas = map[string]bool{
+	"pg_catalog":         true,
+	"information_schema": true,
+	"pg_toast":           true,
+}
+
+// ProtectedTables are platform metadata tables of a tenant database. They are
+// managed through the builder APIs only.
+var ProtectedTables = map[string]bool{
+	"table":                    true,
+	"field":                    true,
+	"relation":                 true,
+	"view":                     true,
+	"section":                  true,
+	"layout":                   true,
+	"tab":                      true,
+	"menu":                     true,
+	"record_permission":        true,
+	"field_permission":         true,
+	"view_permission":          true,
+	"view_relation_permission": true,
+	"action_permission":        true,
+	"automatic_filter":         true,
+	"global_permission":        true,
+	"menu_permission":          true,
+	"custom_permission":        true,
+	"custom_permission_access": true,
+	"custom_endpoint":          true,
+	"custom_endpoint_revision": true,
+	"custom_event":             true,
+	"function":                 true,
+	"version_history":          true,
+	"query_timeout":            true,
+	"sql_audit_log":            true,
+	"audit_log":                true,
+	"audit_checkpoint":         true,
+	"login_attempt":            true,
+	"password_history":         true,
+	"schema_migrations":        true,
+	"agent_permissions":        true,
+}
+
+// AllowedFunctions are the built-in functions user SQL may call. Anything
+// else is rejected: functions outside this list can change settings, signal
+// the server, touch its files, take locks, run SQL the guard never sees or
+// read tables the query does not name. The list includes the functions
+// Postgres calls for SQL syntax such as EXTRACT, TRIM, SUBSTRING, POSITION,
+// OVERLAY, AT TIME ZONE, SIMILAR TO and OVERLAPS.
+var AllowedFunctions = map[string]bool{
+	// Aggregates.
+	"count": true, "sum": true, "avg": true, "min": true, "max": true,
+	"array_agg": true, "string_agg": true, "json_agg": true, "jsonb_agg": true,
+	"json_object_agg": true, "jsonb_object_agg": true, "bool_and": true,
+	"bool_or": true, "every": true, "bit_and": true, "bit_or": true,
+	"stddev": true, "stddev_pop": true, "stddev_samp": true, "variance": true,
+	"var_pop": true, "var_samp": true, "percentile_cont": true,
+	"percentile_disc": true, "mode": true, "corr": true, "covar_pop": true,
+	"covar_samp": true,
+	// Window functions.
+	"row_number": true, "rank": true, "dense_rank": true, "percent_rank": true,
+	"cume_dist": true, "ntile": true, "lag": true, "lead": true,
+	"first_value": true, "last_value": true, "nth_value": true,
+	// Math.
+	"abs": true, "ceil": true, "ceiling": true, "floor": true, "round": true,
+	"trunc": true, "sign": true, "sqrt": true, "cbrt": true, "power": true,
+	"pow": true, "exp": true, "ln": true, "log": true, "log10": true,
+	"mod": true, "div": true, "random": true, "pi": true, "degrees": true,
+	"radians": true, "sin": true, "cos": true, "tan": true, "width_bucket": true,
+	"gcd": true, "lcm": true, "scale": true,
+	// Strings.
+	"length": true, "char_length": true, "character_length": true,
+	"octet_length": true, "bit_length": true, "lower": true, "upper": true,
+	"initcap": true, "btrim": true, "ltrim": true, "rtrim": true,
+	"substr": true, "substring": true, "left": true, "right": true,
+	"lpad": true, "rpad": true, "replace": true, "reverse": true,
+	"repeat": true, "concat": true, "concat_ws": true, "split_part": true,
+	"strpos": true, "position": true, "overlay": true, "format": true,
+	"md5": true, "sha256": true, "translate": true, "chr": true, "ascii": true,
+	"encode": true, "decode": true, "starts_with": true, "normalize": true,
+	"regexp_replace": true, "regexp_match": true, "regexp_matches": true,
+	"regexp_split_to_array": true, "regexp_split_to_table": true,
+	"regexp_count": true, "regexp_like": true, "similar_to_escape": true,
+	"quote_ident": true, "quote_literal": true, "quote_nullable": true,
+	"string_to_array": true, "array_to_string": true, "to_char": true,
+	"to_number": true,
+	// Dates and times.
+	"now": true, "clock_timestamp": true, "statement_timestamp": true,
+	"transaction_timestamp": true, "date_trunc": true, "date_part": true,
+	"date_bin": true, "extract": true, "age": true, "make_date": true,
+	"make_time": true, "make_timestamp": true, "make_timestamptz": true,
+	"make_interval": true, "justify_days": true, "justify_hours": true,
+	"justify_interval": true, "timezone": true, "to_date": true,
+	"to_timestamp": true, "isfinite": true, "overlaps": true,
+	"generate_series": true,
+	// JSON.
+	"to_json": true, "to_jsonb": true, "row_to_json": true, "array_to_json": true,
+	"json_build_object": true, "jsonb_build_object": true,
+	"json_build_array": true, "jsonb_build_array": true,
+	"json_array_length": true, "jsonb_array_length": true,
+	"json_array_elements": true, "jsonb_array_elements": true,
+	"json_array_elements_text": true, "jsonb_array_elements_text": true,
+	"json_each": true, "jsonb_each": true, "json_each_text": true,
+	"jsonb_each_text": true, "json_extract_path": true,
+	"jsonb_extract_path": true, "json_extract_path_text": true,
+	"jsonb_extract_path_text": true, "json_object_keys": true,
+	"jsonb_object_keys": true, "json_typeof": true, "jsonb_typeof": true,
+	"json_strip_nulls": true, "jsonb_strip_nulls": true, "jsonb_set": true,
+	"jsonb_insert": true, "jsonb_pretty": true, "jsonb_path_query": true,
+	"jsonb_path_query_array": true, "jsonb_path_query_first": true,
+	"jsonb_path_exists": true, "jsonb_path_match": true,
+	"json_to_record": true, "jsonb_to_record": true,
+	"json_to_recordset": true, "jsonb_to_recordset": true,
+	// Arrays.
+	"array_length": true, "array_append": true, "array_prepend": true,
+	"array_cat": true, "array_position": true, "array_positions": true,
+	"array_remove": true, "array_replace": true, "array_upper": true,
+	"array_lower": true, "array_ndims": true, "array_dims": true,
+	"array_fill": true, "cardinality": true, "unnest": true,
+	// Other.
+	"gen_random_uuid": true, "num_nulls": true, "num_nonnulls": true,
+}
+
+// protectedSettingPrefix starts the settings the service keeps for itself,
+// as the subject row security reads.
+const protectedSettingPrefix = "ucode."
+
+// protectedSettings can never be changed by user SQL: RESET ALL would
+// reset the settings of the service, SET ROLE and SET SESSION
+// AUTHORIZATION would switch the database user.
+var protectedSettings = map[string]bool{
+	"all":                   true,
+	"role":                  true,
+	"session_authorization": true,
+}
+
+// Policy describes what a piece of user SQL is allowed to do.
+type Policy struct {
+	// ReadOnly allows SELECT statements only.
+	ReadOnly bool `json:"read_only"`
+	// AllowedOperations limits the statement kinds, e.g. SELECT, INSERT.
+	// Empty means SELECT, INSERT, UPDATE and DELETE.
+	AllowedOperations []string `json:"allowed_operations"`
+	// AllowedTables limits the tables the statements may reference. Empty
+	// means any table that is not protected.
+	AllowedTables []string `json:"allowed_tables"`
+}
+
+// DefaultOperations are allowed when a policy does not list any.
+var DefaultOperations = []string{OpSelect, OpInsert, OpUpdate, OpDelete}
+
+// Violation is returned when a query does not satisfy a policy.
+type Violation struct {
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return "sql policy violation: " + v.Reason
+}
+
+// Check validates the analysed query against the policy.
+func (p Policy) Check(analysis Analysis) error {
+	allowedOps := map[string]bool{OpExplain: true}
+	if p.ReadOnly {
+		allowedOps[OpSelect] = true
+	} else {
+		ops := p.AllowedOperations
+		if len(ops) == 0 {
+			ops = DefaultOperations
+		}
+		for _, op := range ops {
+			allowedOps[strings.ToUpper(strings.TrimSpace(op))] = true
+		}
+	}
+
+	allowedTables := make(map[string]bool, len(p.AllowedTables))
+	for _, table := range p.AllowedTables {
+		allowedTables[normalizeName(table)] = true
+	}
+
+	for _, stmt := range analysis.Statements {
+		for _, op := range stmt.Operations {
+			if !allowedOps[op] {
+				return &Violation{Reason: fmt.Sprintf("operation %s is not allowed", op)}
+			}
+		}
+
+		for _, fn := range stmt.Functions {
+			if !fn.Allowed() {
+				return &Violation{Reason: fmt.Sprintf("function %s is not allowed", fn)}
+			}
+		}
+
+		for _, name := range stmt.Settings {
+			if protectedSettings[name] || strings.HasPrefix(name, protectedSettingPrefix) {
+				return &Violation{Reason: fmt.Sprintf("changing setting %s is not allowed", name)}
+			}
+		}
+
+		if p.ReadOnly && stmt.Locking {
+			return &Violation{Reason: "row locking clauses are not allowed in read-only queries"}
+		}
+
+		for _, table := range stmt.Tables {
+			schema, name := normalizeName(table.Schema), normalizeName(table.Name)
+
+			if systemSchemas[schema] || strings.HasPrefix(name, "pg_") {
+				return &Violation{Reason: fmt.Sprintf("access to system catalog %s is not allowed", table)}
+			}
+			if (schema == "" || schema == "public") && ProtectedTables[name] {
+				return &Violation{Reason: fmt.Sprintf("access to platform table %s is not allowed", table)}
+			}
+			if len(allowedTables) > 0 && !allowedTables[name] && !allowedTables[table.String()] {
+				return &Violation{Reason: fmt.Sprintf("table %s is not in the allowed list", table)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Inspect analyses the query and checks it against the policy in one call.
+func Inspect(query string, policy Policy) (Analysis, error) {
+	analysis, err := Analyze(query)
+	if err != nil {
+		return Analysis{}, &Violation{Reason: "could not parse query: " + err.Error()}
+	}
+
+	return analysis, policy.Check(analysis)
+}
@@ -0,0 +1,544 @@
+// Package sqlguard classifies user-written SQL before it reaches a tenant
+// database. It is used by ExecuteSQL and custom endpoints to reject DDL,
+// access to platform metadata tables and operations outside the policy of the
+// caller, and to decide whether a query can run in a read-only transaction.
+//
+// Queries are parsed with the Postgres parser itself (libpg_query), so
+// quoting, Unicode escapes and the full FROM grammar are read the way the
+// server reads them.
+package sqlguard
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	pganalyze "github.com/pganalyze/pg_query_go/v6"
+	pg_query "github.com/wasilibs/go-pgquery"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Operations a statement can perform.
+const (
+	OpSelect  = "SELECT"
+	OpInsert  = "INSERT"
+	OpUpdate  = "UPDATE"
+	OpDelete  = "DELETE"
+	OpMerge   = "MERGE"
+	OpDDL     = "DDL"
+	OpLock    = "LOCK"
+	OpSession = "SESSION"
+	OpUtility = "UTILITY"
+	OpUnknown = "UNKNOWN"
+	OpExplain = "EXPLAIN"
+)
+
+var readOperations = map[string]bool{
+	OpSelect:  true,
+	OpExplain: true,
+}
+
+// Statement is the classification of a single SQL statement.
+type Statement struct {
+	Operations []string
+	Tables     []TableRef
+	Locking    bool
+	// Functions are the functions the statement calls.
+	Functions []Function
+	// Settings are the parameters SET and RESET change, lower-cased.
+	Settings []string
+}
+
+// TableRef is a table referenced by a statement.
+type TableRef struct {
+	Schema string
+	Name   string
+}
+
+func (t TableRef) String() string {
+	if t.Schema == "" {
+		return t.Name
+	}
+
+	return t.Schema + "." + t.Name
+}
+
+// Function is a function called by a statement.
+type Function struct {
+	Schema string
+	Name   string
+}
+
+func (f Function) String() string {
+	if f.Schema == "" {
+		return f.Name
+	}
+
+	return f.Schema + "." + f.Name
+}
+
+// Allowed reports whether the function is a built-in on AllowedFunctions.
+func (f Function) Allowed() bool {
+	return (f.Schema == "" || f.Schema == "pg_catalog") && AllowedFunctions[f.Name]
+}
+
+// ReadOnly reports whether the statement only reads data.
+func (s Statement) ReadOnly() bool {
+	if s.Locking {
+		return false
+	}
+
+	for _, op := range s.Operations {
+		if !readOperations[op] {
+			return false
+		}
+	}
+
+	return len(s.Operations) > 0
+}
+
+// Analysis is the classification of a whole query text.
+type Analysis struct {
+	Statements []Statement
+}
+
+// ReadOnly reports whether every statement only reads data.
+func (a Analysis) ReadOnly() bool {
+	for _, stmt := range a.Statements {
+		if !stmt.ReadOnly() {
+			return false
+		}
+	}
+
+	return len(a.Statements) > 0
+}
+
+// Complete reports whether the tables of the analysis are all the tables the
+// query can read. Functions outside AllowedFunctions may read tables the
+// query text does not name.
+func (a Analysis) Complete() bool {
+	for _, stmt := range a.Statements {
+		for _, fn := range stmt.Functions {
+			if !fn.Allowed() {
+				return false
+			}
+		}
+	}
+
+	return len(a.Statements) > 0
+}
+
+// Operations returns the distinct operations of all statements.
+func (a Analysis) Operations() []string {
+	set := map[string]bool{}
+	for _, stmt := range a.Statements {
+		for _, op := range stmt.Operations {
+			set[op] = true
+		}
+	}
+
+	return sortedKeys(set)
+}
+
+// Tables returns the distinct tables of all statements.
+func (a Analysis) Tables() []string {
+	set := map[string]bool{}
+	for _, stmt := range a.Statements {
+		for _, table := range stmt.Tables {
+			set[table.String()] = true
+		}
+	}
+
+	return sortedKeys(set)
+}
+
+// Analyze parses the query and classifies every statement in it. Named
+// :name placeholders are read as $N parameters.
+func Analyze(query string) (Analysis, error) {
+	params, err := Params(query)
+	if err != nil {
+		return Analysis{}, err
+	}
+
+	tree, err := pg_query.Parse(bindNames(query, params))
+	if err != nil {
+		return Analysis{}, err
+	}
+
+	var analysis Analysis
+	for _, raw := range tree.GetStmts() {
+		analysis.Statements = append(analysis.Statements, analyzeStatement(raw.GetStmt()))
+	}
+
+	if len(analysis.Statements) == 0 {
+		return Analysis{}, fmt.Errorf("empty query")
+	}
+
+	return analysis, nil
+}
+
+// analyzer collects the classification of one statement while walking its
+// parse tree.
+type analyzer struct {
+	ops      map[string]bool
+	tables   map[string]TableRef
+	funcs    map[string]Function
+	settings map[string]bool
+	locking  bool
+}
+
+func analyzeStatement(node *pganalyze.Node) Statement {
+	a := &analyzer{
+		ops:      map[string]bool{},
+		tables:   map[string]TableRef{},
+		funcs:    map[string]Function{},
+		settings: map[string]bool{},
+	}
+
+	msg := nodeMessage(node)
+	if msg == nil {
+		return Statement{Operations: []string{OpUnknown}}
+	}
+	if operation(msg.Interface()) == "" {
+		a.ops[OpDDL] = true
+	}
+	a.walk(msg, map[string]bool{})
+
+	// EXPLAIN ANALYZE executes the wrapped statement, plain EXPLAIN does not.
+	if explain, ok := msg.Interface().(*pganalyze.ExplainStmt); ok && !explainAnalyze(explain) {
+		a.ops = map[string]bool{OpExplain: true}
+	}
+
+	stmt := Statement{
+		Operations: sortedKeys(a.ops),
+		Settings:   sortedKeys(a.settings),
+		Locking:    a.locking,
+	}
+
+	for _, name := range sortedKeys(refNames(a.tables)) {
+		stmt.Tables = append(stmt.Tables, a.tables[name])
+	}
+	for _, name := range sortedKeys(refNames(a.funcs)) {
+		stmt.Functions = append(stmt.Functions, a.funcs[name])
+	}
+
+	return stmt
+}
+
+// walk visits m and everything below it. scope holds the names of the
+// common table expressions visible at m; unqualified references to them
+// are not tables.
+func (a *analyzer) walk(m protoreflect.Message, scope map[string]bool) {
+	if op := operation(m.Interface()); op != "" {
+		a.ops[op] = true
+	}
+
+	skip := map[protoreflect.Name]bool{}
+
+	switch n := m.Interface().(type) {
+	case *pganalyze.RangeVar:
+		if n.GetSchemaname() == "" && scope[n.GetRelname()] {
+			return
+		}
+		a.addTable(TableRef{Schema: n.GetSchemaname(), Name: n.GetRelname()})
+		return
+	case *pganalyze.FuncCall:
+		a.addFunction(n.GetFuncname())
+	case *pganalyze.LockingClause:
+		a.locking = true
+	case *pganalyze.VariableSetStmt:
+		if n.GetKind() == pganalyze.VariableSetKind_VAR_RESET_ALL {
+			a.settings["all"] = true
+		} else {
+			a.settings[strings.ToLower(n.GetName())] = true
+		}
+	case *pganalyze.DropStmt:
+		for _, object := range n.GetObjects() {
+			if names := stringList(object.GetList().GetItems()); len(names) > 0 {
+				ref := TableRef{Name: names[len(names)-1]}
+				if len(names) > 1 {
+					ref.Schema = names[len(names)-2]
+				}
+				a.addTable(ref)
+			}
+		}
+	case *pganalyze.InsertStmt:
+		// The VALUES list of an INSERT reads nothing by itself.
+		if values := n.GetSelectStmt().GetSelectStmt(); values != nil && len(values.GetValuesLists()) > 0 {
+			skip["select_stmt"] = true
+			for _, list := range values.GetValuesLists() {
+				a.walkNode(list, a.withScope(m, scope))
+			}
+		}
+	}
+
+	if with := m.Descriptor().Fields().ByName("with_clause"); with != nil && m.Has(with) {
+		scope = a.withScope(m, scope)
+		skip["with_clause"] = true
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if skip[fd.Name()] || fd.Message() == nil {
+			return true
+		}
+		if fd.IsList() {
+			for i := 0; i < v.List().Len(); i++ {
+				a.walk(v.List().Get(i).Message(), scope)
+			}
+			return true
+		}
+		if !fd.IsMap() {
+			a.walk(v.Message(), scope)
+		}
+		return true
+	})
+}
+
+func (a *analyzer) walkNode(node *pganalyze.Node, scope map[string]bool) {
+	if node != nil {
+		a.walk(node.ProtoReflect(), scope)
+	}
+}
+
+// withScope walks the common table expressions of the WITH clause of m and
+// returns the scope of the statement they belong to. A body sees the
+// expressions before it, or all of them in WITH RECURSIVE.
+func (a *analyzer) withScope(m protoreflect.Message, outer map[string]bool) map[string]bool {
+	fd := m.Descriptor().Fields().ByName("with_clause")
+	if fd == nil || !m.Has(fd) {
+		return outer
+	}
+	with, ok := m.Get(fd).Message().Interface().(*pganalyze.WithClause)
+	if !ok {
+		return outer
+	}
+
+	scope := make(map[string]bool, len(outer)+len(with.GetCtes()))
+	for name := range outer {
+		scope[name] = true
+	}
+	if with.GetRecursive() {
+		for _, cte := range with.GetCtes() {
+			scope[cte.GetCommonTableExpr().GetCtename()] = true
+		}
+	}
+
+	for _, cte := range with.GetCtes() {
+		expr := cte.GetCommonTableExpr()
+		body := make(map[string]bool, len(scope))
+		for name := range scope {
+			body[name] = true
+		}
+		a.walkNode(expr.GetCtequery(), body)
+		scope[expr.GetCtename()] = true
+	}
+
+	return scope
+}
+
+func (a *analyzer) addTable(ref TableRef) {
+	if ref.Name != "" {
+		a.tables[ref.String()] = ref
+	}
+}
+
+func (a *analyzer) addFunction(funcname []*pganalyze.Node) {
+	names := stringList(funcname)
+	if len(names) == 0 {
+		return
+	}
+
+	fn := Function{Name: names[len(names)-1]}
+	if len(names) > 1 {
+		fn.Schema = names[len(names)-2]
+	}
+	a.funcs[fn.String()] = fn
+}
+
+// operation returns the operation a statement node performs, or "" for
+// nodes the guard does not classify.
+func operation(msg any) string {
+	switch n := msg.(type) {
+	case *pganalyze.SelectStmt:
+		if n.GetIntoClause() != nil {
+			return OpDDL
+		}
+		return OpSelect
+	case *pganalyze.VariableShowStmt:
+		return OpSelect
+	case *pganalyze.InsertStmt:
+		return OpInsert
+	case *pganalyze.UpdateStmt:
+		return OpUpdate
+	case *pganalyze.DeleteStmt:
+		return OpDelete
+	case *pganalyze.MergeStmt:
+		return OpMerge
+	case *pganalyze.ExplainStmt:
+		return OpExplain
+	case *pganalyze.LockStmt:
+		return OpLock
+	case *pganalyze.VariableSetStmt, *pganalyze.TransactionStmt, *pganalyze.DiscardStmt,
+		*pganalyze.PrepareStmt, *pganalyze.ExecuteStmt, *pganalyze.DeallocateStmt,
+		*pganalyze.ListenStmt, *pganalyze.NotifyStmt, *pganalyze.UnlistenStmt, *pganalyze.LoadStmt:
+		return OpSession
+	case *pganalyze.CopyStmt, *pganalyze.VacuumStmt, *pganalyze.CheckPointStmt, *pganalyze.DoStmt,
+		*pganalyze.CallStmt, *pganalyze.DeclareCursorStmt, *pganalyze.FetchStmt, *pganalyze.ClosePortalStmt:
+		return OpUtility
+	}
+
+	return ""
+}
+
+func explainAnalyze(stmt *pganalyze.ExplainStmt) bool {
+	for _, option := range stmt.GetOptions() {
+		def := option.GetDefElem()
+		if def.GetDefname() != "analyze" {
+			continue
+		}
+
+		arg := def.GetArg()
+		switch {
+		case arg == nil:
+			return true
+		case arg.GetString_() != nil:
+			switch strings.ToLower(arg.GetString_().GetSval()) {
+			case "false", "off", "no", "0":
+				return false
+			}
+			return true
+		case arg.GetInteger() != nil:
+			return arg.GetInteger().GetIval() != 0
+		case arg.GetBoolean() != nil:
+			return arg.GetBoolean().GetBoolval()
+		}
+		return true
+	}
+
+	return false
+}
+
+// nodeMessage returns the message held by a Node.
+func nodeMessage(node *pganalyze.Node) protoreflect.Message {
+	m := node.ProtoReflect()
+	fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("node"))
+	if fd == nil {
+		return nil
+	}
+
+	return m.Get(fd).Message()
+}
+
+func stringList(nodes []*pganalyze.Node) []string {
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if s := node.GetString_(); s != nil {
+			names = append(names, s.GetSval())
+		}
+	}
+
+	return names
+}
+
+// Param is a placeholder of a query text.
+type Param struct {
+	// Name is set for :name placeholders, Position for $N ones.
+	Name     string
+	Position int
+	// Start and End are the byte offsets of the placeholder in the query.
+	Start, End int
+}
+
+// Params returns the placeholders of the query in order. Quoted text,
+// comments, ::type casts and array slices such as a[1:n] are not
+// placeholders.
+func Params(query string) ([]Param, error) {
+	scan, err := pg_query.Scan(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		params   []Param
+		brackets int
+		tokens   = scan.GetTokens()
+	)
+
+	for i, tok := range tokens {
+		switch tok.GetToken() {
+		case pganalyze.Token_ASCII_91:
+			brackets++
+		case pganalyze.Token_ASCII_93:
+			brackets--
+		case pganalyze.Token_PARAM:
+			position, err := strconv.Atoi(query[tok.GetStart()+1 : tok.GetEnd()])
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, Param{Position: position, Start: int(tok.GetStart()), End: int(tok.GetEnd())})
+		case pganalyze.Token_ASCII_58:
+			if brackets > 0 || i+1 == len(tokens) {
+				continue
+			}
+			name := tokens[i+1]
+			if name.GetStart() != tok.GetEnd() {
+				continue
+			}
+			if name.GetToken() != pganalyze.Token_IDENT && name.GetKeywordKind() == pganalyze.KeywordKind_NO_KEYWORD {
+				continue
+			}
+			params = append(params, Param{Name: query[name.GetStart():name.GetEnd()], Start: int(tok.GetStart()), End: int(name.GetEnd())})
+		}
+	}
+
+	return params, nil
+}
+
+// bindNames rewrites the :name placeholders of the query to $N, so the
+// query parses.
+func bindNames(query string, params []Param) string {
+	var (
+		b        strings.Builder
+		last     int
+		position = map[string]int{}
+	)
+
+	for _, param := range params {
+		if param.Name == "" {
+			continue
+		}
+		if _, ok := position[param.Name]; !ok {
+			position[param.Name] = len(position) + 1
+		}
+		b.WriteString(query[last:param.Start])
+		b.WriteString("$" + strconv.Itoa(position[param.Name]))
+		last = param.End
+	}
+	b.WriteString(query[last:])
+
+	return b.String()
+}
+
+func refNames[T any](refs map[string]T) map[string]bool {
+	set := make(map[string]bool, len(refs))
+	for name := range refs {
+		set[name] = true
+	}
+
+	return set
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
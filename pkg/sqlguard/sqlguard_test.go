@@ -0,0 +1,166 @@
+package sqlguard_test
+
+import (
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/sqlguard"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspect(t *testing.T) {
+	readOnly := sqlguard.Policy{ReadOnly: true}
+
+	tests := []struct {
+		name    string
+		query   string
+		policy  sqlguard.Policy
+		allowed bool
+		read    bool
+	}{
+		{"select", "SELECT id, name FROM orders WHERE id = :id", readOnly, true, true},
+		{"cte", "WITH o AS (SELECT * FROM orders) SELECT * FROM o", readOnly, true, true},
+		{"insert in read only", "INSERT INTO orders (id) VALUES ($1)", readOnly, false, false},
+		{"insert", "INSERT INTO orders (id) VALUES ($1)", sqlguard.Policy{}, true, false},
+		{"drop", "DROP TABLE orders", sqlguard.Policy{}, false, false},
+		{"stacked drop", "SELECT 1; DROP TABLE orders", sqlguard.Policy{}, false, false},
+		{"platform table", "SELECT * FROM field", sqlguard.Policy{}, false, true},
+		{"system catalog", "SELECT * FROM pg_catalog.pg_roles", sqlguard.Policy{}, false, true},
+		{"keyword in string", "SELECT 'drop table field' AS note FROM orders", readOnly, true, true},
+		{"outside allow-list", "SELECT * FROM customers", sqlguard.Policy{AllowedTables: []string{"orders"}}, false, true},
+		{"operation not allowed", "DELETE FROM orders", sqlguard.Policy{AllowedOperations: []string{"select", "update"}}, false, false},
+		{"set_config", "SELECT set_config('ucode.role_id', '', true) FROM orders", readOnly, false, true},
+		{"nested set_config", "SELECT coalesce(pg_catalog.set_config('ucode.bypass', 'on', true), '')", readOnly, false, true},
+		{"set row security setting", "SET LOCAL ucode.role_id = ''", sqlguard.Policy{AllowedOperations: []string{"session"}}, false, false},
+		{"reset all", "RESET ALL", sqlguard.Policy{AllowedOperations: []string{"session"}}, false, false},
+		{"set role", "SET ROLE postgres", sqlguard.Policy{AllowedOperations: []string{"session"}}, false, false},
+		{"set other setting", "SET LOCAL work_mem = '64MB'", sqlguard.Policy{AllowedOperations: []string{"session"}}, true, false},
+		{"parenthesised join", "SELECT * FROM (field JOIN orders ON true)", readOnly, false, true},
+		{"nested parenthesised join", "SELECT * FROM orders o JOIN ((customers c JOIN field f ON true) JOIN items i ON true) ON true", readOnly, false, true},
+		{"parenthesised join in allow-list", "SELECT * FROM (orders o JOIN customers c ON c.id = o.customer_id)", sqlguard.Policy{AllowedTables: []string{"orders", "customers"}}, true, true},
+		{"sub-query in join", "SELECT * FROM orders o JOIN (SELECT * FROM field) f ON true", readOnly, false, true},
+		{"terminate backend", "SELECT pg_terminate_backend(1)", readOnly, false, true},
+		{"dblink", "SELECT * FROM dblink('dbname=other', 'DELETE FROM orders') AS t(x int)", readOnly, false, true},
+		{"dblink_exec", "SELECT dblink_exec('DROP TABLE orders')", readOnly, false, true},
+		{"query_to_xml", "SELECT query_to_xml('SELECT * FROM field', true, false, '')", readOnly, false, true},
+		{"advisory lock", "SELECT pg_advisory_lock(1)", readOnly, false, true},
+		{"transaction advisory lock", "SELECT pg_advisory_xact_lock(hashtext('audit_log'))", readOnly, false, true},
+		{"hashtext", "SELECT hashtext('audit_log') FROM orders", readOnly, false, true},
+		{"comma after join on", "SELECT * FROM orders o JOIN customers c ON o.id=c.id, field", readOnly, false, true},
+		{"comma after join using", "SELECT * FROM orders o JOIN customers c USING (id), field", readOnly, false, true},
+		{"comma after lateral join", "SELECT * FROM orders o LEFT JOIN LATERAL (SELECT 1) x ON true, record_permission", readOnly, false, true},
+		{"comma after tablesample", "SELECT * FROM orders TABLESAMPLE SYSTEM(1), field", readOnly, false, true},
+		{"update from list", "UPDATE orders o SET total = 0 FROM customers c, LATERAL (SELECT 1) l, record_permission r WHERE o.id = c.id", sqlguard.Policy{}, false, false},
+		{"delete using list", "DELETE FROM orders o USING customers c, field WHERE o.id = c.id", sqlguard.Policy{}, false, false},
+		{"update after cte", "WITH a AS (SELECT 1) UPDATE field SET x=1", sqlguard.Policy{}, false, false},
+		{"update after cte in read only", "WITH a AS (SELECT 1) UPDATE orders SET x=1", readOnly, false, false},
+		{"unicode table name", `SELECT * FROM U&"fi\0065ld"`, readOnly, false, true},
+		{"unicode function name", `SELECT U&"\0073et_config"('ucode.bypass','on',true)`, readOnly, false, true},
+		{"cte shadows a platform table", "WITH field AS (SELECT * FROM orders) SELECT * FROM field", readOnly, true, true},
+		{"cte reads the table it is named after", "WITH field AS (SELECT * FROM field) SELECT * FROM field", readOnly, false, true},
+		{"schema function", "SELECT public.touch_orders()", readOnly, false, true},
+		{"syntax functions", "SELECT extract(year FROM created_at), trim(name), substring(name FROM 1 FOR 2), created_at AT TIME ZONE 'UTC' FROM orders", readOnly, true, true},
+		{"select into", "SELECT * INTO orders_copy FROM orders", sqlguard.Policy{}, false, false},
+		{"set session authorization", "SET SESSION AUTHORIZATION postgres", sqlguard.Policy{AllowedOperations: []string{"session"}}, false, false},
+		{"explain", "EXPLAIN DELETE FROM orders", readOnly, true, true},
+		{"explain analyze", "EXPLAIN ANALYZE DELETE FROM orders", readOnly, false, false},
+		{"row locking", "SELECT * FROM orders FOR UPDATE", readOnly, false, false},
+		{"insert values", "INSERT INTO orders (id) VALUES (:id)", sqlguard.Policy{AllowedOperations: []string{"insert"}}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis, err := sqlguard.Inspect(tt.query, tt.policy)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+			assert.Equal(t, tt.read, analysis.ReadOnly())
+		})
+	}
+}
+
+func TestAnalyzeJoinTables(t *testing.T) {
+	tests := []struct {
+		query  string
+		tables []string
+	}{
+		{"SELECT * FROM (field JOIN orders ON true)", []string{"field", "orders"}},
+		{"SELECT * FROM ((a JOIN b USING (id)) LEFT JOIN public.c ON true), d", []string{"a", "b", "d", "public.c"}},
+		{"SELECT * FROM a JOIN (b JOIN (SELECT * FROM c) s ON true) ON true", []string{"a", "b", "c"}},
+		{"SELECT * FROM a JOIN generate_series(1, 3) g ON true", []string{"a"}},
+		{"SELECT * FROM a JOIN b ON true, c", []string{"a", "b", "c"}},
+		{"SELECT * FROM a TABLESAMPLE SYSTEM(1), b", []string{"a", "b"}},
+		{`SELECT * FROM U&"fi\0065ld"`, []string{"field"}},
+		{"WITH a AS (SELECT 1) UPDATE field SET x=1", []string{"field"}},
+		{"WITH a AS (SELECT * FROM a), b AS (SELECT * FROM a) SELECT * FROM b", []string{"a"}},
+		{"WITH RECURSIVE a AS (SELECT 1 UNION ALL SELECT * FROM a) SELECT * FROM a", nil},
+		{"WITH a AS (SELECT 1) SELECT * FROM public.a", []string{"public.a"}},
+		{"SELECT * FROM (WITH c AS (SELECT 1) SELECT * FROM c) s, c", []string{"c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			analysis, err := sqlguard.Analyze(tt.query)
+			assert.NoError(t, err)
+			if tt.tables == nil {
+				assert.Empty(t, analysis.Tables())
+				return
+			}
+			assert.Equal(t, tt.tables, analysis.Tables())
+		})
+	}
+}
+
+func TestAnalyzeComplete(t *testing.T) {
+	tests := []struct {
+		query    string
+		complete bool
+	}{
+		{"SELECT count(*) FROM orders", true},
+		{"SELECT * FROM orders WHERE id = :id", true},
+		{"SELECT report_totals()", false},
+		{"SELECT * FROM orders o JOIN public.order_lines(o.id) l ON true", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			analysis, err := sqlguard.Analyze(tt.query)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.complete, analysis.Complete())
+		})
+	}
+}
+
+func TestParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		params []string
+	}{
+		{name: "named", query: "SELECT * FROM orders WHERE id = :id AND status = :status", params: []string{":id", ":status"}},
+		{name: "keyword names", query: "SELECT * FROM orders LIMIT :limit OFFSET :offset", params: []string{":limit", ":offset"}},
+		{name: "positional", query: "SELECT * FROM orders WHERE id = $1 AND total > $2", params: []string{"$1", "$2"}},
+		{name: "casts", query: "SELECT :id::uuid, created_at::date FROM orders", params: []string{":id"}},
+		{name: "quoted text", query: "SELECT ':id', $$ :body $$, \"a:b\" FROM orders WHERE id = :id", params: []string{":id"}},
+		{name: "comments", query: "SELECT 1 -- :note\n/* :other */ FROM orders", params: nil},
+		{name: "array slice", query: "SELECT tags[1:n] FROM orders", params: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := sqlguard.Params(tt.query)
+			assert.NoError(t, err)
+
+			var got []string
+			for _, param := range params {
+				got = append(got, tt.query[param.Start:param.End])
+				if param.Name != "" {
+					assert.Equal(t, ":"+param.Name, tt.query[param.Start:param.End])
+				}
+			}
+			assert.Equal(t, tt.params, got)
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"sync"
 	"ucode/ucode_go_object_builder_service/pkg/logger"
 
 	"github.com/jackc/pgx/v5"
@@ -14,11 +16,16 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-var PsqlPool = make(map[string]*Pool) // there we save psql connections by project_id
+var (
+	PsqlPool = make(map[string]*Pool) // there we save psql connections by project_id
+	poolMu   sync.RWMutex
+)
 
 type Pool struct {
 	Db     *pgxpool.Pool
 	Logger logger.LoggerI
+
+	budgets budgets
 }
 
 func (p *Pool) HandleDatabaseError(err error, message string) error {
@@ -31,6 +38,11 @@ func (p *Pool) HandleDatabaseError(err error, message string) error {
 		return status.Error(codes.NotFound, "not found")
 	}
 
+	if errors.Is(err, context.DeadlineExceeded) {
+		p.Logger.Error(message + ": " + err.Error())
+		return status.Error(codes.DeadlineExceeded, "query exceeded its time budget")
+	}
+
 	var pgErr *pgconn.PgError
 
 	if errors.As(err, &pgErr) {
@@ -85,6 +97,9 @@ func (p *Pool) HandleDatabaseError(err error, message string) error {
 		case "40P01":
 			// Deadlock detected
 			return status.Error(codes.Aborted, fmt.Sprintf("deadlock detected: %v", pgErr.Message))
+		case "57014":
+			// Query canceled (statement_timeout or cancel request)
+			return status.Error(codes.DeadlineExceeded, fmt.Sprintf("query canceled: %v", pgErr.Message))
 
 		// --- Transaction Errors ---
 		case "25P01":
@@ -188,6 +203,20 @@ func (b *Pool) Begin(ctx context.Context) (pgx.Tx, error) {
 	return &Tx{Tx: tx, ctx: ctx}, nil
 }
 
+func (b *Pool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "pgx.BeginTx")
+	defer dbSpan.Finish()
+
+	tx, err := b.Db.BeginTx(ctx, txOptions)
+	if err != nil {
+		dbSpan.SetTag("error", true)
+		dbSpan.LogKV("error.message", err.Error())
+		return nil, err
+	}
+
+	return &Tx{Tx: tx, ctx: ctx}, nil
+}
+
 type Tx struct {
 	pgx.Tx
 	ctx context.Context
@@ -237,11 +266,34 @@ func (tx *Tx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.Co
 	return tx.Tx.Exec(ctx, sql, arguments...)
 }
 
+// RowSecurityBypass is the setting that lets a connection past the row
+// security policies of the tables that turn it on.
+const RowSecurityBypass = "ucode.bypass"
+
+// BypassRowSecurity turns RowSecurityBypass on for the connections of
+// config, the ones the service itself reads and writes with. User SQL runs
+// in transactions that turn it off.
+func BypassRowSecurity(config *pgxpool.Config) {
+	afterConnect := config.AfterConnect
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if afterConnect != nil {
+			if err := afterConnect(ctx, conn); err != nil {
+				return err
+			}
+		}
+		_, err := conn.Exec(ctx, `SELECT set_config($1, 'on', false)`, RowSecurityBypass)
+		return err
+	}
+}
+
 func Add(projectId string, conn *Pool) {
 	if projectId == "" {
 		return
 	}
 
+	poolMu.Lock()
+	defer poolMu.Unlock()
+
 	_, ok := PsqlPool[projectId]
 	if ok {
 		return
@@ -255,6 +307,9 @@ func Get(projectId string) (conn *Pool, err error) {
 		return nil, errors.New("project id is empty")
 	}
 
+	poolMu.RLock()
+	defer poolMu.RUnlock()
+
 	_, ok := PsqlPool[projectId]
 	if !ok {
 		return nil, errors.New("connection not found")
@@ -268,6 +323,9 @@ func Remove(projectId string) {
 		return
 	}
 
+	poolMu.Lock()
+	defer poolMu.Unlock()
+
 	_, ok := PsqlPool[projectId]
 	if !ok {
 		return
@@ -281,6 +339,9 @@ func Override(projectId string, conn *Pool) {
 		return
 	}
 
+	poolMu.Lock()
+	defer poolMu.Unlock()
+
 	_, ok := PsqlPool[projectId]
 	if !ok {
 		return
@@ -288,3 +349,17 @@ func Override(projectId string, conn *Pool) {
 
 	PsqlPool[projectId] = conn
 }
+
+// Projects returns the ids of the projects with an open connection, sorted.
+func Projects() []string {
+	poolMu.RLock()
+	defer poolMu.RUnlock()
+
+	projectIds := make([]string, 0, len(PsqlPool))
+	for projectId := range PsqlPool {
+		projectIds = append(projectIds, projectId)
+	}
+	sort.Strings(projectIds)
+
+	return projectIds
+}
@@ -0,0 +1,137 @@
+package psqlpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Query budget kinds. Each RPC picks the kind that matches its workload and
+// gets the statement timeout configured for it.
+const (
+	BudgetList        = "list"
+	BudgetExport      = "export"
+	BudgetUserSQL     = "user_sql"
+	BudgetAggregation = "aggregation"
+)
+
+// budgetsCacheTTL controls how often tenant overrides from the query_timeout
+// table are re-read.
+const budgetsCacheTTL = time.Minute
+
+var (
+	defaultBudgetsMu sync.RWMutex
+	defaultBudgets   = map[string]time.Duration{
+		BudgetList:        10 * time.Second,
+		BudgetExport:      5 * time.Minute,
+		BudgetUserSQL:     30 * time.Second,
+		BudgetAggregation: 30 * time.Second,
+	}
+)
+
+type budgets struct {
+	mu       sync.RWMutex
+	values   map[string]time.Duration
+	loadedAt time.Time
+}
+
+// SetDefaultBudgets overrides the service wide budgets used when a tenant has
+// no row for the kind in its query_timeout table. Zero durations are ignored.
+func SetDefaultBudgets(values map[string]time.Duration) {
+	defaultBudgetsMu.Lock()
+	defer defaultBudgetsMu.Unlock()
+
+	for kind, value := range values {
+		if value > 0 {
+			defaultBudgets[kind] = value
+		}
+	}
+}
+
+func defaultBudget(kind string) time.Duration {
+	defaultBudgetsMu.RLock()
+	defer defaultBudgetsMu.RUnlock()
+
+	return defaultBudgets[kind]
+}
+
+// Budget returns the statement timeout for the given kind, preferring the
+// tenant override stored in query_timeout over the service default.
+func (b *Pool) Budget(ctx context.Context, kind string) time.Duration {
+	b.budgets.mu.RLock()
+	values, loadedAt := b.budgets.values, b.budgets.loadedAt
+	b.budgets.mu.RUnlock()
+
+	if values == nil || time.Since(loadedAt) > budgetsCacheTTL {
+		values = b.loadBudgets(ctx)
+	}
+
+	if value, ok := values[kind]; ok && value > 0 {
+		return value
+	}
+
+	return defaultBudget(kind)
+}
+
+func (b *Pool) loadBudgets(ctx context.Context) map[string]time.Duration {
+	values := make(map[string]time.Duration)
+
+	rows, err := b.Db.Query(ctx, `SELECT rpc_type, timeout_ms FROM query_timeout`)
+	if err == nil {
+		for rows.Next() {
+			var (
+				kind      string
+				timeoutMs int64
+			)
+
+			if err := rows.Scan(&kind, &timeoutMs); err != nil {
+				continue
+			}
+			values[kind] = time.Duration(timeoutMs) * time.Millisecond
+		}
+		rows.Close()
+	}
+
+	b.budgets.mu.Lock()
+	b.budgets.values, b.budgets.loadedAt = values, time.Now()
+	b.budgets.mu.Unlock()
+
+	return values
+}
+
+// WithBudget derives a context whose deadline is the budget of the given kind.
+// pgx cancels the running statement on the server once the deadline passes.
+// An earlier deadline already set on ctx is kept.
+func (b *Pool) WithBudget(ctx context.Context, kind string) (context.Context, context.CancelFunc) {
+	timeout := b.Budget(ctx, kind)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// SetStatementTimeout applies the timeout to the current transaction only, so
+// the setting never leaks to other users of the pooled connection.
+func SetStatementTimeout(ctx context.Context, tx pgx.Tx, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds()))
+	return err
+}
+
+// SetLocal sets the settings for the current transaction only, like
+// SetStatementTimeout.
+func SetLocal(ctx context.Context, tx pgx.Tx, settings map[string]string) error {
+	for name, value := range settings {
+		if _, err := tx.Exec(ctx, `SELECT set_config($1, $2, true)`, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
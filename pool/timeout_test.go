@@ -0,0 +1,96 @@
+package psqlpool
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"ucode/ucode_go_object_builder_service/pkg/logger"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tenantPool returns a pool whose tenant overrides are already loaded, so
+// Budget does not read query_timeout.
+func tenantPool(overrides map[string]time.Duration) *Pool {
+	p := &Pool{Logger: logger.NewLogger("test", "error")}
+	p.budgets.values, p.budgets.loadedAt = overrides, time.Now()
+	return p
+}
+
+func TestBudget(t *testing.T) {
+	defaults := map[string]time.Duration{}
+	for _, kind := range []string{BudgetList, BudgetExport, BudgetUserSQL, BudgetAggregation} {
+		defaults[kind] = defaultBudget(kind)
+	}
+	t.Cleanup(func() { SetDefaultBudgets(defaults) })
+
+	SetDefaultBudgets(map[string]time.Duration{BudgetList: 2 * time.Second, BudgetExport: 0})
+
+	tests := []struct {
+		name      string
+		overrides map[string]time.Duration
+		kind      string
+		want      time.Duration
+	}{
+		{name: "service default", overrides: map[string]time.Duration{}, kind: BudgetList, want: 2 * time.Second},
+		{name: "zero defaults are ignored", overrides: map[string]time.Duration{}, kind: BudgetExport, want: defaults[BudgetExport]},
+		{name: "tenant override", overrides: map[string]time.Duration{BudgetUserSQL: time.Second}, kind: BudgetUserSQL, want: time.Second},
+		{name: "zero override", overrides: map[string]time.Duration{BudgetList: 0}, kind: BudgetList, want: 2 * time.Second},
+		{name: "unknown kind", overrides: map[string]time.Duration{}, kind: "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tenantPool(tt.overrides).Budget(context.Background(), tt.kind))
+		})
+	}
+}
+
+func TestWithBudget(t *testing.T) {
+	p := tenantPool(map[string]time.Duration{BudgetList: time.Minute})
+
+	ctx, cancel := p.WithBudget(context.Background(), BudgetList)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	earlier, cancelEarlier := context.WithTimeout(context.Background(), time.Second)
+	defer cancelEarlier()
+	ctx, cancel = p.WithBudget(earlier, BudgetList)
+	defer cancel()
+	kept, _ := earlier.Deadline()
+	deadline, _ = ctx.Deadline()
+	assert.Equal(t, kept, deadline)
+
+	ctx, cancel = p.WithBudget(context.Background(), "other")
+	defer cancel()
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+}
+
+func TestHandleDatabaseErrorTimeouts(t *testing.T) {
+	p := tenantPool(nil)
+
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{name: "deadline", err: fmt.Errorf("query: %w", context.DeadlineExceeded), code: codes.DeadlineExceeded},
+		{name: "statement timeout", err: &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}, code: codes.DeadlineExceeded},
+		{name: "other", err: &pgconn.PgError{Code: "23505"}, code: codes.AlreadyExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, status.Code(p.HandleDatabaseError(tt.err, "test")))
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"ucode/ucode_go_object_builder_service/config"
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
 	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
 	"ucode/ucode_go_object_builder_service/pkg/helper"
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
 
@@ -26,6 +27,10 @@ type QueryContext struct {
 	Limit         int
 	Offset        int
 	Autofilter    map[string]any
+	// ParentFilter and ChildFilter are the row-level read rule on the
+	// parent_node and child_node aliases of the recursive query.
+	ParentFilter string
+	ChildFilter  string
 }
 
 func (o *objectBuilderRepo) AgGridTree(ctx context.Context, req *nb.CommonMessage) (*nb.CommonMessage, error) {
@@ -44,6 +49,16 @@ func (o *objectBuilderRepo) AgGridTree(ctx context.Context, req *nb.CommonMessag
 		return nil, helper.HandleDatabaseError(err, o.logger, "AgGridTree: Failed to get lookup fields")
 	}
 
+	subject := authz.Resolve(ctx, req.GetData().AsMap())
+	parentFilter, err := rowCondition(ctx, conn, req.TableSlug, "parent_node", subject, authz.Read)
+	if err != nil {
+		return nil, helper.HandleDatabaseError(err, o.logger, "AgGridTree: Failed to get row permissions")
+	}
+	childFilter, err := rowCondition(ctx, conn, req.TableSlug, "child_node", subject, authz.Read)
+	if err != nil {
+		return nil, helper.HandleDatabaseError(err, o.logger, "AgGridTree: Failed to get row permissions")
+	}
+
 	qc := QueryContext{
 		TableSlug:     req.TableSlug,
 		Fields:        fields,
@@ -53,6 +68,8 @@ func (o *objectBuilderRepo) AgGridTree(ctx context.Context, req *nb.CommonMessag
 		Limit:         limit,
 		Offset:        offset,
 		Autofilter:    autoFilter,
+		ParentFilter:  parentFilter,
+		ChildFilter:   childFilter,
 	}
 
 	results, err := buildAndExecuteQuery(ctx, conn, qc)
@@ -216,6 +233,14 @@ func buildRecursiveQuery(qc QueryContext) string {
 	} else {
 		baseFilterCondition = fmt.Sprintf("parent_node.%s = '%s'", childField, qc.FilterValue)
 	}
+	if qc.ParentFilter != "" {
+		baseFilterCondition += " AND " + qc.ParentFilter
+	}
+
+	recursiveJoin := fmt.Sprintf("child_node.%s = parent.guid", childField)
+	if qc.ChildFilter != "" {
+		recursiveJoin += " AND " + qc.ChildFilter
+	}
 
 	query := fmt.Sprintf(`
         WITH RECURSIVE hierarchy AS (
@@ -243,7 +268,7 @@ func buildRecursiveQuery(qc QueryContext) string {
                 parent.original_parent_id,
 				child_node.created_at
             FROM %s child_node
-            INNER JOIN hierarchy parent ON child_node.%s = parent.guid
+            INNER JOIN hierarchy parent ON %s
         )
         SELECT %s, h.path, h.has_child%s FROM hierarchy h
         WHERE h.%s %s
@@ -259,7 +284,7 @@ func buildRecursiveQuery(qc QueryContext) string {
 		qc.TableSlug,
 		childField,
 		qc.TableSlug,
-		childField,
+		recursiveJoin,
 		joinColumnsWithPrefix(qc.Fields, "h."),
 		lookupDataSelects.String(),
 		childField,
@@ -11,6 +11,7 @@ import (
 
 	"ucode/ucode_go_object_builder_service/config"
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
 	"ucode/ucode_go_object_builder_service/storage"
 
@@ -73,17 +74,17 @@ func (r *agentRepo) CreateAgent(ctx context.Context, req *nb.CreateAgentRequest)
 	}()
 
 	var query = `
-		INSERT INTO agents (id, project_id, name, description, instruction, model, max_steps, enabled, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
-		RETURNING id, project_id, name, description, instruction, model, max_steps, enabled, created_at, updated_at
+		INSERT INTO agents (id, project_id, name, description, instruction, model, max_steps, enabled, role_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($10, '')::UUID, $9, $9)
+		RETURNING id, project_id, name, description, instruction, model, max_steps, enabled, COALESCE(role_id::TEXT, ''), created_at, updated_at
 	`
 
 	err = tx.QueryRow(ctx, query, id,
 		req.GetProjectId(), req.GetName(), req.GetDescription(), req.GetInstruction(),
-		model, maxSteps, req.GetEnabled(), now,
+		model, maxSteps, req.GetEnabled(), now, req.GetRoleId(),
 	).Scan(
 		&agent.Id, &agent.ProjectId, &agent.Name, &agent.Description, &agent.Instruction,
-		&agent.Model, &agent.MaxSteps, &agent.Enabled, &createdAt, &updatedAt,
+		&agent.Model, &agent.MaxSteps, &agent.Enabled, &agent.RoleId, &createdAt, &updatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create agent: %w", err)
@@ -122,7 +123,7 @@ func (r *agentRepo) GetAgentById(ctx context.Context, req *nb.AgentPrimaryKey) (
 		createdAt, updatedAt time.Time
 
 		query = `
-			SELECT id, project_id, name, description, instruction, model, max_steps, enabled, created_at, updated_at
+			SELECT id, project_id, name, description, instruction, model, max_steps, enabled, COALESCE(role_id::TEXT, ''), created_at, updated_at
 			FROM agents
 			WHERE id = $1
 		`
@@ -130,7 +131,7 @@ func (r *agentRepo) GetAgentById(ctx context.Context, req *nb.AgentPrimaryKey) (
 
 	err = conn.QueryRow(ctx, query, req.GetId()).Scan(
 		&agent.Id, &agent.ProjectId, &agent.Name, &agent.Description, &agent.Instruction,
-		&agent.Model, &agent.MaxSteps, &agent.Enabled, &createdAt, &updatedAt,
+		&agent.Model, &agent.MaxSteps, &agent.Enabled, &agent.RoleId, &createdAt, &updatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -172,7 +173,7 @@ func (r *agentRepo) GetAllAgents(ctx context.Context, req *nb.GetAllAgentsReques
 	)
 
 	queryBuilder.WriteString(`
-		SELECT a.id, a.project_id, a.name, a.description, a.instruction, a.model, a.max_steps, a.enabled, a.created_at, a.updated_at
+		SELECT a.id, a.project_id, a.name, a.description, a.instruction, a.model, a.max_steps, a.enabled, COALESCE(a.role_id::TEXT, ''), a.created_at, a.updated_at
 		FROM agents a
 		WHERE 1=1
 	`)
@@ -235,7 +236,7 @@ func (r *agentRepo) GetAllAgents(ctx context.Context, req *nb.GetAllAgentsReques
 
 		err = rows.Scan(
 			&agent.Id, &agent.ProjectId, &agent.Name, &agent.Description, &agent.Instruction,
-			&agent.Model, &agent.MaxSteps, &agent.Enabled, &createdAt, &updatedAt,
+			&agent.Model, &agent.MaxSteps, &agent.Enabled, &agent.RoleId, &createdAt, &updatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan agent: %w", err)
@@ -305,17 +306,18 @@ func (r *agentRepo) UpdateAgent(ctx context.Context, req *nb.UpdateAgentRequest)
 
 	var query = `
 		UPDATE agents
-		SET name = $2, description = $3, instruction = $4, model = $5, max_steps = $6, enabled = $7, updated_at = NOW()
+		SET name = $2, description = $3, instruction = $4, model = $5, max_steps = $6, enabled = $7,
+			role_id = NULLIF($8, '')::UUID, updated_at = NOW()
 		WHERE id = $1
-		RETURNING id, project_id, name, description, instruction, model, max_steps, enabled, created_at, updated_at
+		RETURNING id, project_id, name, description, instruction, model, max_steps, enabled, COALESCE(role_id::TEXT, ''), created_at, updated_at
 	`
 
 	err = tx.QueryRow(ctx, query, req.GetId(),
 		req.GetName(), req.GetDescription(), req.GetInstruction(),
-		model, maxSteps, req.GetEnabled(),
+		model, maxSteps, req.GetEnabled(), req.GetRoleId(),
 	).Scan(
 		&agent.Id, &agent.ProjectId, &agent.Name, &agent.Description, &agent.Instruction,
-		&agent.Model, &agent.MaxSteps, &agent.Enabled, &createdAt, &updatedAt,
+		&agent.Model, &agent.MaxSteps, &agent.Enabled, &agent.RoleId, &createdAt, &updatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -379,25 +381,36 @@ func insertAgentPermissions(ctx context.Context, tx pgx.Tx, agentID string, perm
 
 	var (
 		builder strings.Builder
-		args    = make([]any, 0, len(perms)*7)
+		args    = make([]any, 0, len(perms)*8)
 		argIdx  int
 	)
 
 	builder.WriteString(`
 		INSERT INTO agent_permissions
-			(agent_id, table_slug, can_create, can_read, can_update, can_delete, can_list)
+			(agent_id, table_slug, can_create, can_read, can_update, can_delete, can_list, masked_fields)
 		VALUES
 	`)
 
 	for i, p := range perms {
+		for _, mask := range p.GetMaskedFields() {
+			if _, err := authz.ParseMask(mask); err != nil {
+				return err
+			}
+		}
+
+		maskedFields := p.GetMaskedFields()
+		if maskedFields == nil {
+			maskedFields = map[string]string{}
+		}
+
 		if i > 0 {
 			builder.WriteString(", ")
 		}
-		builder.WriteString(fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			argIdx+1, argIdx+2, argIdx+3, argIdx+4, argIdx+5, argIdx+6, argIdx+7))
-		argIdx += 7
+		builder.WriteString(fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			argIdx+1, argIdx+2, argIdx+3, argIdx+4, argIdx+5, argIdx+6, argIdx+7, argIdx+8))
+		argIdx += 8
 		args = append(args, agentID, p.GetTableSlug(),
-			p.GetCanCreate(), p.GetCanRead(), p.GetCanUpdate(), p.GetCanDelete(), p.GetCanList())
+			p.GetCanCreate(), p.GetCanRead(), p.GetCanUpdate(), p.GetCanDelete(), p.GetCanList(), maskedFields)
 	}
 
 	_, err := tx.Exec(ctx, builder.String(), args...)
@@ -408,7 +421,7 @@ func getAgentPermissions(ctx context.Context, conn *psqlpool.Pool, agentID strin
 	permissions := make([]*nb.AgentPermission, 0)
 
 	rows, err := conn.Query(ctx, `
-		SELECT id, agent_id, table_slug, can_create, can_read, can_update, can_delete, can_list
+		SELECT id, agent_id, table_slug, can_create, can_read, can_update, can_delete, can_list, masked_fields
 		FROM agent_permissions
 		WHERE agent_id = $1
 		ORDER BY table_slug ASC
@@ -422,7 +435,7 @@ func getAgentPermissions(ctx context.Context, conn *psqlpool.Pool, agentID strin
 		var p nb.AgentPermission
 		err = rows.Scan(
 			&p.Id, &p.AgentId, &p.TableSlug,
-			&p.CanCreate, &p.CanRead, &p.CanUpdate, &p.CanDelete, &p.CanList,
+			&p.CanCreate, &p.CanRead, &p.CanUpdate, &p.CanDelete, &p.CanList, &p.MaskedFields,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan agent permission: %w", err)
@@ -444,7 +457,7 @@ func getAgentPermissionsByAgentIds(ctx context.Context, conn *psqlpool.Pool, age
 	}
 
 	rows, err := conn.Query(ctx, `
-		SELECT id, agent_id, table_slug, can_create, can_read, can_update, can_delete, can_list
+		SELECT id, agent_id, table_slug, can_create, can_read, can_update, can_delete, can_list, masked_fields
 		FROM agent_permissions
 		WHERE agent_id = ANY($1)
 		ORDER BY table_slug ASC
@@ -458,7 +471,7 @@ func getAgentPermissionsByAgentIds(ctx context.Context, conn *psqlpool.Pool, age
 		var p nb.AgentPermission
 		err = rows.Scan(
 			&p.Id, &p.AgentId, &p.TableSlug,
-			&p.CanCreate, &p.CanRead, &p.CanUpdate, &p.CanDelete, &p.CanList,
+			&p.CanCreate, &p.CanRead, &p.CanUpdate, &p.CanDelete, &p.CanList, &p.MaskedFields,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan agent permission: %w", err)
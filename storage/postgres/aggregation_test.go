@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestAuthorizeAggregationUpdate(t *testing.T) {
+	var (
+		role    = map[string]any{"role_id_from_token": "r"}
+		request = authz.WithSubject(context.Background(), authz.Subject{})
+		record  = func(update string) fakeRow {
+			return fakeRow{match: `COALESCE("write"`, values: []any{"Yes", update, "Yes"}}
+		}
+		readOnly = fakeResult{match: "effective_field_permission", rows: [][]any{{"salary"}}}
+		rule     = fakeRow{match: "SELECT conditions", values: []any{[]byte(`{"update": {"field": "status", "op": "neq", "value": "closed"}}`)}}
+		columns  = fakeResult{match: "information_schema.columns", rows: [][]any{{"status"}}}
+	)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		data    map[string]any
+		rows    []fakeRow
+		results []fakeResult
+		code    codes.Code
+		fields  []string
+		where   string
+	}{
+		{name: "service", ctx: context.Background(), fields: []string{"salary", "total"}, where: "total > 0"},
+		{name: "request without role", ctx: request, code: codes.PermissionDenied},
+		{name: "update denied", ctx: request, data: role, rows: []fakeRow{record("No")}, code: codes.PermissionDenied},
+		{
+			name:    "fields the role may not edit",
+			ctx:     request,
+			data:    role,
+			rows:    []fakeRow{record("Yes")},
+			results: []fakeResult{readOnly},
+			fields:  []string{"total"},
+			where:   "total > 0",
+		},
+		{
+			name:    "update rule",
+			ctx:     request,
+			data:    role,
+			rows:    []fakeRow{record("Yes"), rule},
+			results: []fakeResult{columns},
+			fields:  []string{"salary", "total"},
+			where:   `(total > 0) AND COALESCE("orders"."status" IS DISTINCT FROM 'closed', FALSE)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := &models.QueryParams{
+				Operation: "UPDATE",
+				Table:     "orders",
+				Data:      map[string]any{"salary": 1, "total": 2},
+				Where:     "total > 0",
+			}
+			q := &fakeQuerier{rows: tt.rows, results: tt.results}
+
+			err := authorizeAggregationUpdate(tt.ctx, q, params, tt.data)
+			if tt.code != codes.OK {
+				assert.Equal(t, tt.code, status.Code(err))
+				return
+			}
+			assert.NoError(t, err)
+
+			var fields []string
+			for field := range params.Data {
+				fields = append(fields, field)
+			}
+			assert.ElementsMatch(t, tt.fields, fields)
+			assert.Equal(t, tt.where, params.Where)
+		})
+	}
+}
+
+func TestApplyAggregationAccess(t *testing.T) {
+	result, err := structpb.NewStruct(map[string]any{
+		"data": []any{map[string]any{"phone": "555123456", "salary": 100, "total": 3}},
+	})
+	require.NoError(t, err)
+
+	kept, err := applyAggregationAccess(result, nil)
+	require.NoError(t, err)
+	assert.Equal(t, result, kept)
+
+	shown, err := applyAggregationAccess(result, &helper.FieldAccess{
+		Hidden: map[string]bool{"salary": true},
+		Masks:  map[string]authz.Mask{"phone": authz.MaskLast4},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"data": []any{map[string]any{"phone": authz.MaskLast4.Apply("555123456", nil), "total": float64(3)}},
+	}, shown.AsMap())
+}
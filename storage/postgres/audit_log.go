@@ -0,0 +1,437 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/audit"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// versionHistoryContentHash hashes the columns of a version_history row
+// aliased vh that never change once written. used_environments, which
+// Update sets, is left out. Hashing in SQL keeps the JSONB columns in the
+// form Postgres stores them.
+const versionHistoryContentHash = `encode(sha256(convert_to(concat_ws(E'\x1f',
+	vh.id::TEXT, vh.action_source, vh.action_type,
+	COALESCE(vh.previous::TEXT, ''), COALESCE(vh.current::TEXT, ''), COALESCE(vh.date, ''),
+	vh.user_info, COALESCE(vh.request::TEXT, ''), COALESCE(vh.response::TEXT, ''),
+	COALESCE(vh.api_key, ''), COALESCE(vh.type, ''), vh.table_slug, COALESCE(vh.method_api, ''),
+	COALESCE(vh.time_started, ''), COALESCE(vh.time_completed, ''),
+	COALESCE(vh.duration::TEXT, ''), COALESCE(vh.status_code::TEXT, ''), COALESCE(vh.table_label, ''),
+	to_char(vh.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US')
+), 'UTF8')), 'hex')`
+
+const auditEntryColumns = `seq, version_history_id::TEXT, created_at, action_source, action_type,
+	table_slug, user_info, content_hash, prev_hash, hash`
+
+// maxAuditProblems caps the problems a verification returns.
+const maxAuditProblems = 1000
+
+func scanAuditEntry(row pgx.Row, e *audit.Entry, extra ...any) error {
+	return row.Scan(append([]any{
+		&e.Seq, &e.VersionHistoryId, &e.CreatedAt, &e.ActionSource, &e.ActionType,
+		&e.TableSlug, &e.UserInfo, &e.ContentHash, &e.PrevHash, &e.Hash,
+	}, extra...)...)
+}
+
+// auditEntry returns the entry at seq, nil when there is none.
+func auditEntry(ctx context.Context, q helper.RowQuerier, seq int64) (*audit.Entry, error) {
+	var e audit.Entry
+	err := scanAuditEntry(q.QueryRow(ctx, `SELECT `+auditEntryColumns+` FROM audit_log WHERE seq = $1`, seq), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting audit entry")
+	}
+	return &e, nil
+}
+
+// appendAudit appends e to the audit log in the transaction that wrote its
+// version history row. Appends are serialized, so that sequence numbers
+// have no gaps and every entry links to the one before it.
+func appendAudit(ctx context.Context, tx pgx.Tx, e audit.Entry) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('audit_log'))`); err != nil {
+		return errors.Wrap(err, "error while locking audit log")
+	}
+
+	var (
+		last *audit.Entry
+		head audit.Entry
+	)
+	err := scanAuditEntry(tx.QueryRow(ctx, `SELECT `+auditEntryColumns+` FROM audit_log ORDER BY seq DESC LIMIT 1`), &head)
+	switch {
+	case err == nil:
+		last = &head
+	case !errors.Is(err, pgx.ErrNoRows):
+		return errors.Wrap(err, "error while getting audit head")
+	}
+
+	e = audit.Next(last, e)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO audit_log (`+auditEntryColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		e.Seq, e.VersionHistoryId, e.CreatedAt, e.ActionSource, e.ActionType,
+		e.TableSlug, e.UserInfo, e.ContentHash, e.PrevHash, e.Hash,
+	)
+	if err != nil {
+		return errors.Wrap(err, "error while appending audit entry")
+	}
+
+	return nil
+}
+
+type auditCheckpointRow struct {
+	seq, fromSeq int64
+	hash, keyId  string
+	signature    string
+	objectKey    string
+	createdAt    time.Time
+}
+
+func (r auditCheckpointRow) checkpoint(projectId string) audit.Checkpoint {
+	return audit.Checkpoint{
+		ProjectId: projectId,
+		FromSeq:   r.fromSeq,
+		Seq:       r.seq,
+		Hash:      r.hash,
+		CreatedAt: r.createdAt,
+		KeyId:     r.keyId,
+		Signature: r.signature,
+	}
+}
+
+func (r auditCheckpointRow) proto() *nb.AuditCheckpoint {
+	return &nb.AuditCheckpoint{
+		Seq:       r.seq,
+		FromSeq:   r.fromSeq,
+		Hash:      r.hash,
+		KeyId:     r.keyId,
+		Signature: r.signature,
+		ObjectKey: r.objectKey,
+		CreatedAt: r.createdAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// auditCheckpoints returns the checkpoints whose seq is between from and
+// to, by seq. A zero to has no upper limit.
+func auditCheckpoints(ctx context.Context, conn *psqlpool.Pool, from, to int64) ([]auditCheckpointRow, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT seq, from_seq, hash, key_id, signature, object_key, created_at
+		FROM audit_checkpoint
+		WHERE seq >= $1 AND ($2 = 0 OR seq <= $2)
+		ORDER BY seq`, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting audit checkpoints")
+	}
+	defer rows.Close()
+
+	var checkpoints []auditCheckpointRow
+	for rows.Next() {
+		var r auditCheckpointRow
+		if err := rows.Scan(&r.seq, &r.fromSeq, &r.hash, &r.keyId, &r.signature, &r.objectKey, &r.createdAt); err != nil {
+			return nil, errors.Wrap(err, "error while scanning audit checkpoint")
+		}
+		checkpoints = append(checkpoints, r)
+	}
+
+	return checkpoints, rows.Err()
+}
+
+// lastAuditCheckpoint returns the latest checkpoint, nil when there is none.
+func lastAuditCheckpoint(ctx context.Context, conn *psqlpool.Pool) (*auditCheckpointRow, error) {
+	var r auditCheckpointRow
+	err := conn.QueryRow(ctx, `
+		SELECT seq, from_seq, hash, key_id, signature, object_key, created_at
+		FROM audit_checkpoint ORDER BY seq DESC LIMIT 1`,
+	).Scan(&r.seq, &r.fromSeq, &r.hash, &r.keyId, &r.signature, &r.objectKey, &r.createdAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting last audit checkpoint")
+	}
+	return &r, nil
+}
+
+// auditSigningKey returns the key signing checkpoints, nil when none is
+// configured.
+func (v *versionHistoryRepo) auditSigningKey() (ed25519.PrivateKey, error) {
+	seed := v.cfg.AuditSigningKey
+	if seed == "" {
+		return nil, nil
+	}
+	return audit.ParseSigningKey(seed)
+}
+
+// VerifyAuditChain checks the audit log entries from req.FromSeq to
+// req.ToSeq: that none is missing, each links to the one before it and
+// still matches its hash, their version history rows were not edited or
+// removed before a checkpoint covered them, and the checkpoints in the
+// range match the chain.
+func (v *versionHistoryRepo) VerifyAuditChain(ctx context.Context, req *nb.VerifyAuditChainRequest) (*nb.VerifyAuditChainResponse, error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "version_history.VerifyAuditChain")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := max(req.GetFromSeq(), 1), req.GetToSeq()
+	if to != 0 && to < from {
+		return nil, status.Error(codes.InvalidArgument, "to_seq is before from_seq")
+	}
+
+	key, err := v.auditSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := lastAuditCheckpoint(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	var covered int64
+	if last != nil {
+		covered = last.seq
+	}
+
+	checkpoints, err := auditCheckpoints(ctx, conn, from, to)
+	if err != nil {
+		return nil, err
+	}
+	bySeq := make(map[int64]auditCheckpointRow, len(checkpoints))
+	for _, r := range checkpoints {
+		bySeq[r.seq] = r
+	}
+
+	var prev *audit.Entry
+	if from > 1 {
+		if prev, err = auditEntry(ctx, conn, from-1); err != nil {
+			return nil, err
+		}
+	}
+	chain := audit.NewChain(prev)
+
+	rows, err := conn.Query(ctx, `
+		SELECT `+auditEntryColumns+`, vh.current_hash
+		FROM audit_log a
+		LEFT JOIN LATERAL (
+			SELECT `+versionHistoryContentHash+` AS current_hash
+			FROM version_history vh
+			WHERE vh.id = a.version_history_id
+			LIMIT 1
+		) vh ON true
+		WHERE a.seq >= $1 AND ($2 = 0 OR a.seq <= $2)
+		ORDER BY a.seq`, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting audit entries")
+	}
+	defer rows.Close()
+
+	resp := &nb.VerifyAuditChainResponse{}
+	for rows.Next() {
+		var (
+			e       audit.Entry
+			content *string
+		)
+		if err := scanAuditEntry(rows, &e, &content); err != nil {
+			return nil, errors.Wrap(err, "error while scanning audit entry")
+		}
+
+		if resp.FirstSeq == 0 {
+			resp.FirstSeq = e.Seq
+		}
+		resp.LastSeq = e.Seq
+
+		chain.Add(e)
+
+		switch {
+		case content == nil && e.Seq > covered:
+			chain.Report(e.Seq, audit.ProblemMissing, "version history row was removed before a checkpoint covered it")
+		case content != nil && *content != e.ContentHash:
+			chain.Report(e.Seq, audit.ProblemContent, "version history row was edited")
+		}
+
+		if r, ok := bySeq[e.Seq]; ok {
+			if r.hash != e.Hash {
+				chain.Report(e.Seq, audit.ProblemCheckpoint, "checkpoint hash does not match the entry")
+			}
+			delete(bySeq, e.Seq)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error while getting audit entries")
+	}
+
+	for _, r := range checkpoints {
+		if _, ok := bySeq[r.seq]; ok {
+			chain.Report(r.seq, audit.ProblemGap, "entry of a checkpoint is missing")
+		}
+		c := r.checkpoint(req.GetProjectId())
+		if key != nil && !c.VerifySignature(key.Public().(ed25519.PublicKey)) {
+			chain.Report(r.seq, audit.ProblemCheckpoint, "checkpoint signature is invalid")
+		}
+	}
+
+	resp.Checked = chain.Checked
+	resp.Valid = len(chain.Problems) == 0
+	for _, p := range chain.Problems {
+		if len(resp.Problems) == maxAuditProblems {
+			break
+		}
+		resp.Problems = append(resp.Problems, &nb.AuditChainProblem{Seq: p.Seq, Kind: p.Kind, Detail: p.Detail})
+	}
+
+	return resp, nil
+}
+
+// CreateAuditCheckpoint signs the head of the audit log and exports it,
+// with the entries since the previous checkpoint, to the audit bucket. It
+// returns the previous checkpoint when no entry was appended since.
+func (v *versionHistoryRepo) CreateAuditCheckpoint(ctx context.Context, req *nb.CreateAuditCheckpointRequest) (*nb.AuditCheckpoint, error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "version_history.CreateAuditCheckpoint")
+	defer dbSpan.Finish()
+
+	key, err := v.auditSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, status.Error(codes.FailedPrecondition, "audit signing key is not configured")
+	}
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := lastAuditCheckpoint(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		from int64 = 1
+		prev *audit.Entry
+	)
+	if last != nil {
+		from = last.seq + 1
+		if prev, err = auditEntry(ctx, conn, last.seq); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := conn.Query(ctx, `SELECT `+auditEntryColumns+` FROM audit_log WHERE seq >= $1 ORDER BY seq`, from)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting audit entries")
+	}
+	defer rows.Close()
+
+	chain := audit.NewChain(prev)
+	var entries []audit.Entry
+	for rows.Next() {
+		var e audit.Entry
+		if err := scanAuditEntry(rows, &e); err != nil {
+			return nil, errors.Wrap(err, "error while scanning audit entry")
+		}
+		chain.Add(e)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error while getting audit entries")
+	}
+
+	if len(entries) == 0 {
+		if last == nil {
+			return &nb.AuditCheckpoint{}, nil
+		}
+		return last.proto(), nil
+	}
+
+	// A broken chain is not signed, so checkpoints only vouch for what
+	// verifies.
+	if len(chain.Problems) > 0 {
+		p := chain.Problems[0]
+		return nil, status.Errorf(codes.FailedPrecondition, "audit chain is broken at %d: %s", p.Seq, p.Detail)
+	}
+
+	head := entries[len(entries)-1]
+	checkpoint := audit.Checkpoint{
+		ProjectId: req.GetProjectId(),
+		FromSeq:   from,
+		Seq:       head.Seq,
+		Hash:      head.Hash,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		Entries:   entries,
+	}
+	checkpoint.Sign(key)
+
+	r := auditCheckpointRow{
+		seq:       checkpoint.Seq,
+		fromSeq:   checkpoint.FromSeq,
+		hash:      checkpoint.Hash,
+		keyId:     checkpoint.KeyId,
+		signature: checkpoint.Signature,
+		objectKey: fmt.Sprintf("%s/checkpoint-%020d.json", req.GetProjectId(), checkpoint.Seq),
+		createdAt: checkpoint.CreatedAt,
+	}
+
+	if err := v.exportAuditCheckpoint(ctx, r.objectKey, checkpoint); err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Exec(ctx, `
+		INSERT INTO audit_checkpoint (seq, from_seq, hash, key_id, signature, object_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		r.seq, r.fromSeq, r.hash, r.keyId, r.signature, r.objectKey, r.createdAt,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while saving audit checkpoint")
+	}
+
+	return r.proto(), nil
+}
+
+// exportAuditCheckpoint writes checkpoint as JSON to objectKey of the audit
+// bucket.
+func (v *versionHistoryRepo) exportAuditCheckpoint(ctx context.Context, objectKey string, checkpoint audit.Checkpoint) error {
+	cfg := v.cfg
+
+	body, err := json.Marshal(checkpoint)
+	if err != nil {
+		return errors.Wrap(err, "error while marshaling audit checkpoint")
+	}
+
+	minioClient, err := minio.New(cfg.MinioHost, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.MinioAccessKeyID, cfg.MinioSecretKey, ""),
+		Secure: cfg.MinioSSL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error while creating minio client")
+	}
+
+	_, err = minioClient.PutObject(ctx, cfg.AuditBucket, objectKey, bytes.NewReader(body), int64(len(body)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return errors.Wrap(err, "error while exporting audit checkpoint")
+	}
+
+	return nil
+}
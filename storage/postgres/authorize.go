@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+// authorizeWrite checks that the subject of a write may do action with the
+// items of tableSlug and, when data names one, run its custom_event_id. It
+// returns the permissions, nil for unrestricted callers, so the caller can
+// strip the fields the role may not edit.
+func authorizeWrite(ctx context.Context, q querier, tableSlug string, action authz.Action, data map[string]any) (*authz.Permissions, error) {
+	subject := authz.Resolve(ctx, data)
+	if !subject.Restricted() {
+		return nil, authorizeWithoutRole(ctx, q, subject)
+	}
+
+	permissions, err := loadPermissions(ctx, q, tableSlug, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := permissions.Check(action); err != nil {
+		return nil, err
+	}
+	if err := permissions.CheckEvent(cast.ToString(data["custom_event_id"])); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// authorizeWithoutRole lets a subject without a role write only when it is
+// the service itself, outside of a request, or of the ADMIN client type.
+func authorizeWithoutRole(ctx context.Context, q querier, subject authz.Subject) error {
+	if !authz.FromRequest(ctx) {
+		return nil
+	}
+
+	admin, err := isAdminClientType(ctx, q, subject.ClientTypeId)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return authz.ErrNoRole
+	}
+	return nil
+}
+
+func loadPermissions(ctx context.Context, q querier, tableSlug string, subject authz.Subject) (*authz.Permissions, error) {
+	p := &authz.Permissions{
+		TableSlug: tableSlug,
+		Record:    make(map[authz.Action]string),
+		ReadOnly:  make(map[string]bool),
+		Events:    make(map[string]bool),
+	}
+
+	admin, err := isAdminClientType(ctx, q, subject.ClientTypeId)
+	if err != nil {
+		return nil, err
+	}
+	if p.Admin = admin; admin {
+		return p, nil
+	}
+
+	var canWrite, canUpdate, canDelete string
+	err = q.QueryRow(ctx, `
+		SELECT COALESCE("write", ''), COALESCE("update", ''), COALESCE("delete", '')
+		FROM effective_record_permission
+		WHERE table_slug = $1 AND effective_role_id::TEXT = $2`, tableSlug, subject.RoleId,
+	).Scan(&canWrite, &canUpdate, &canDelete)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.Wrap(err, "error while getting record permission")
+	}
+	p.Record[authz.Create], p.Record[authz.Update], p.Record[authz.Delete] = canWrite, canUpdate, canDelete
+
+	rows, err := q.Query(ctx, `
+		SELECT slug FROM (
+			SELECT f.slug, fp.edit_permission, fp.mask
+			FROM effective_field_permission fp
+			JOIN field f ON f.id = fp.field_id
+			WHERE fp.table_slug = $1 AND fp.effective_role_id::TEXT = $2
+		) fp
+		WHERE edit_permission = false OR COALESCE(mask, '') <> ''`, tableSlug, subject.RoleId)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting field permissions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, errors.Wrap(err, "error while scanning field permission")
+		}
+		p.ReadOnly[slug] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error while getting field permissions")
+	}
+	rows.Close()
+
+	rows, err = q.Query(ctx, `
+		SELECT custom_event_id::TEXT, COALESCE(permission, true)
+		FROM effective_action_permission
+		WHERE table_slug = $1 AND effective_role_id::TEXT = $2 AND custom_event_id IS NOT NULL`, tableSlug, subject.RoleId)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting action permissions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			eventId string
+			allowed bool
+		)
+		if err := rows.Scan(&eventId, &allowed); err != nil {
+			return nil, errors.Wrap(err, "error while scanning action permission")
+		}
+		p.Events[eventId] = allowed
+	}
+
+	return p, rows.Err()
+}
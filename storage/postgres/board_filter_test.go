@@ -5,7 +5,10 @@ import (
 	"testing"
 
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
 
+	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -159,3 +162,105 @@ func TestBuildBoardDataQueryCastsPaginationParams(t *testing.T) {
 		t.Fatalf("board data query must cast limit param, got: %s", query)
 	}
 }
+
+func TestGroupBoardItems(t *testing.T) {
+	var (
+		access = &helper.FieldAccess{
+			Hidden: map[string]bool{"salary": true},
+			Masks:  map[string]authz.Mask{"phone": authz.MaskLast4},
+		}
+		masked = authz.MaskLast4.Apply("555123456", nil)
+		items  = func() []map[string]any {
+			return []map[string]any{
+				{"guid": "a", "status": "open", "phone": "555123456", "salary": 100},
+				{"guid": "b", "status": "closed", "phone": "555123456", "salary": 200},
+			}
+		}
+		shown = func(guid, status string) map[string]any {
+			return map[string]any{"guid": guid, "status": status, "phone": masked}
+		}
+	)
+
+	tests := []struct {
+		name      string
+		groupBy   string
+		subgroup  string
+		groups    map[string][]any
+		subgroups map[string]map[string][]any
+	}{
+		{
+			name:    "plain field",
+			groupBy: "status",
+			groups:  map[string][]any{"open": {shown("a", "open")}, "closed": {shown("b", "closed")}},
+		},
+		{
+			name:    "masked field",
+			groupBy: "phone",
+			groups:  map[string][]any{masked.(string): {shown("a", "open"), shown("b", "closed")}},
+		},
+		{
+			name:    "hidden field",
+			groupBy: "salary",
+			groups:  map[string][]any{"Unassigned": {shown("a", "open"), shown("b", "closed")}},
+		},
+		{
+			name:     "hidden subgroup field",
+			groupBy:  "status",
+			subgroup: "salary",
+			subgroups: map[string]map[string][]any{
+				"Unassigned": {"open": {shown("a", "open")}, "closed": {shown("b", "closed")}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, subgroups := groupBoardItems(items(), access, tt.groupBy, tt.subgroup)
+			if tt.groups == nil {
+				tt.groups = map[string][]any{}
+			}
+			if tt.subgroups == nil {
+				tt.subgroups = map[string]map[string][]any{}
+			}
+			assert.Equal(t, tt.groups, groups)
+			assert.Equal(t, tt.subgroups, subgroups)
+		})
+	}
+}
+
+func TestGroupBoardItemsDecrypts(t *testing.T) {
+	cipher := helper.NewFieldCipher("patient", map[string]bool{"passport": true}, testKeyring(t, 1))
+	sealed := helper.NewFieldAccess(nil, map[string]bool{"passport": true})
+	sealed.Cipher = cipher
+
+	items := func() []map[string]any {
+		var items []map[string]any
+		for _, guid := range []string{"a", "b"} {
+			passport, err := cipher.Encrypt("passport", "AA1234567")
+			if err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, map[string]any{"guid": guid, "passport": passport})
+		}
+		return items
+	}
+
+	tests := []struct {
+		name   string
+		access *helper.FieldAccess
+		value  string
+	}{
+		{name: "decrypted", access: &helper.FieldAccess{Cipher: cipher}, value: "AA1234567"},
+		{name: "without the decrypt permission", access: sealed, value: authz.MaskFull.Apply("AA1234567", nil).(string)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, _ := groupBoardItems(items(), tt.access, "passport", "")
+			assert.Equal(t, map[string][]any{tt.value: {
+				map[string]any{"guid": "a", "passport": tt.value},
+				map[string]any{"guid": "b", "passport": tt.value},
+			}}, groups)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"ucode/ucode_go_object_builder_service/config"
 	"ucode/ucode_go_object_builder_service/pkg/helper"
 
 	"github.com/jackc/pgx/v5"
@@ -29,13 +30,24 @@ type QueryBuilder struct {
 	isCached         bool
 	additionalField  string
 	additionalValues []any
+	projection       *helper.Projection
+	source           string
+}
+
+// from is the row source of the query, aliased a: the table, or the table
+// with the computed columns of a view.
+func (qb *QueryBuilder) from(tableSlug string) string {
+	if qb.source != "" {
+		return qb.source + " a"
+	}
+	return fmt.Sprintf(`"%s" a`, tableSlug)
 }
 
 func (qb *QueryBuilder) finalizeQuery(tableSlug string) string {
 	qb.query = strings.TrimRight(qb.query, ",")
 
 	if len(qb.additionalField) == 0 || len(qb.additionalValues) == 0 {
-		qb.query += fmt.Sprintf(`) AS DATA FROM "%s" a`, tableSlug)
+		qb.query += `) AS DATA FROM ` + qb.from(tableSlug)
 		return qb.query + qb.filter + qb.autoFilters + qb.order + qb.limit + qb.offset
 	}
 
@@ -43,7 +55,7 @@ func (qb *QueryBuilder) finalizeQuery(tableSlug string) string {
 }
 
 func (qb *QueryBuilder) buildAdditionalValues(tableSlug string) string {
-	baseSelect := qb.query + `) AS DATA FROM "` + tableSlug + `" a`
+	baseSelect := qb.query + `) AS DATA FROM ` + qb.from(tableSlug)
 
 	requiredPart := fmt.Sprintf(`
         %s
@@ -161,6 +173,16 @@ func (qb *QueryBuilder) buildFieldQuery(fieldRows pgx.Rows) error {
 
 		qb.isCached = isCached
 
+		// Fields outside the projection are not selected but can still
+		// be filtered and searched by
+		if !qb.projection.Selects(slug) {
+			qb.fields[slug] = ftype
+			if helper.FIELD_TYPES[ftype] == "VARCHAR" && isSearch {
+				qb.searchFields = append(qb.searchFields, slug)
+			}
+			continue
+		}
+
 		// Handle special datetime fields
 		if ftype == "DATE_TIME_WITHOUT_TIME_ZONE" {
 			qb.query += fmt.Sprintf(`'%s', TO_CHAR(a.%s, 'DD.MM.YYYY HH24:MI'),`, slug, slug)
@@ -209,6 +231,9 @@ func (qb *QueryBuilder) buildFieldQuery(fieldRows pgx.Rows) error {
 		if slug == "updated_at" && hasUpdatedAt {
 			continue
 		}
+		if !qb.projection.Keeps(slug) {
+			continue
+		}
 
 		if counter >= 30 {
 			qb.query = strings.TrimRight(qb.query, ",")
@@ -238,18 +263,68 @@ func (qb *QueryBuilder) handleLookupField(slug string) {
 	qb.tableSlugsTable = append(qb.tableSlugsTable, strings.ReplaceAll(slug, "_id", ""))
 }
 
-// buildRelationsQuery adds relation data to the query
-func (qb *QueryBuilder) buildRelationsQuery() {
+// relationExpansions returns the lookup fields with the tables they point
+// at. Their rows are read for the whole page after the list query.
+func (qb *QueryBuilder) relationExpansions() []helper.RelationExpansion {
+	expansions := make([]helper.RelationExpansion, 0, len(qb.tableSlugs))
+	for i, slug := range qb.tableSlugs {
+		expansions = append(expansions, helper.RelationExpansion{Field: slug, Table: qb.tableSlugsTable[i]})
+	}
+	return expansions
+}
+
+// buildDynamicRelationsQuery adds <field>_data with the display fields of
+// the row each DYNAMIC_LOOKUP field links to
+func (qb *QueryBuilder) buildDynamicRelationsQuery(relations []dynamicRelation) {
+	var parts []string
+
+	for _, relation := range relations {
+		if qb.fields[relation.field] != config.DYNAMIC_LOOKUP || len(relation.tables) == 0 || !qb.projection.Keeps(relation.field+"_data") {
+			continue
+		}
+
+		field := pq.QuoteIdentifier(relation.field)
+		expr := fmt.Sprintf(`CASE a.%s->>'table_slug'`, field)
+		for _, t := range relation.tables {
+			expr += fmt.Sprintf(` WHEN %s THEN (SELECT %s FROM %s d WHERE d.guid = (a.%s->>'guid')::UUID)`,
+				pq.QuoteLiteral(t.TableSlug), dynamicDisplayObject(t, "d"), pq.QuoteIdentifier(t.TableSlug), field)
+		}
+		expr += ` END`
+
+		parts = append(parts, fmt.Sprintf(`%s, %s`, pq.QuoteLiteral(relation.field+"_data"), expr))
+	}
+
+	if len(parts) == 0 {
+		return
+	}
+
 	qb.query = strings.TrimRight(qb.query, ",")
-	qb.query += `) || jsonb_build_object( `
+	qb.query += `) || jsonb_build_object( ` + strings.Join(parts, ", ") + ","
+}
 
-	for i, slug := range qb.tableSlugs {
-		as := fmt.Sprintf("r%d", i+1)
-		qb.query += fmt.Sprintf(`'%s_data', (
-			SELECT row_to_json(%s)
-			FROM "%s" %s WHERE %s.guid = a.%s
-		),`, slug, as, qb.tableSlugsTable[i], as, as, slug)
+// buildComputedColumnsQuery reads the rows from the table with the
+// computed columns of a view and adds them to the output. They are
+// registered as fields, so they can be sorted and filtered by.
+func (qb *QueryBuilder) buildComputedColumnsQuery(columns *viewColumns) {
+	qb.source = columns.source()
+	if qb.source == "" {
+		return
 	}
+
+	var parts []string
+	for slug, ftype := range columns.types {
+		qb.fields[slug] = ftype
+		if qb.projection.Keeps(slug) {
+			parts = append(parts, fmt.Sprintf(`%s, a.%s`, pq.QuoteLiteral(slug), pq.QuoteIdentifier(slug)))
+		}
+	}
+
+	if len(parts) == 0 {
+		return
+	}
+
+	qb.query = strings.TrimRight(qb.query, ",")
+	qb.query += `) || jsonb_build_object( ` + strings.Join(parts, ", ") + ","
 }
 
 // applyFilters processes and applies filters from parameters
@@ -326,6 +401,11 @@ func (qb *QueryBuilder) buildFieldFilter(key string, val any) {
 		return
 	}
 
+	if fieldType == config.DYNAMIC_LOOKUP {
+		qb.buildDynamicLinkFilter(key, val)
+		return
+	}
+
 	switch valTyped := val.(type) {
 	case []string:
 		qb.filter += fmt.Sprintf(" AND a.%s IN($%d) ", key, qb.argCount)
@@ -356,6 +436,33 @@ func (qb *QueryBuilder) buildFieldFilter(key string, val any) {
 	}
 }
 
+// buildDynamicLinkFilter filters a DYNAMIC_LOOKUP field by the table it
+// links to. A map value may also give the guid of the linked row:
+// {"table_slug": "order", "guid": "..."}. Either accepts a list.
+func (qb *QueryBuilder) buildDynamicLinkFilter(key string, val any) {
+	conditions, ok := val.(map[string]any)
+	if !ok {
+		conditions = map[string]any{"table_slug": val}
+	}
+
+	for _, part := range []string{"table_slug", "guid"} {
+		value, ok := conditions[part]
+		if !ok {
+			continue
+		}
+
+		switch value.(type) {
+		case []any, []string:
+			qb.filter += fmt.Sprintf(" AND a.%s->>'%s' = ANY($%d) ", key, part, qb.argCount)
+			qb.args = append(qb.args, pq.Array(cast.ToStringSlice(value)))
+		default:
+			qb.filter += fmt.Sprintf(" AND a.%s->>'%s' = $%d ", key, part, qb.argCount)
+			qb.args = append(qb.args, cast.ToString(value))
+		}
+		qb.argCount++
+	}
+}
+
 // buildComparisonFilters handles comparison operators in filters
 func (qb *QueryBuilder) buildComparisonFilters(key string, comparisons map[string]any) {
 	for op, v := range comparisons {
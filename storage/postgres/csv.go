@@ -52,6 +52,15 @@ func (o *csvRepo) GetListInCSV(ctx context.Context, req *nb.CommonMessage) (resp
 		return nil, err
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetExport)
+	defer cancel()
+
+	defer func() {
+		if helper.IsQueryTimeout(err) {
+			err = o.db.HandleDatabaseError(err, "GetListInCSV")
+		}
+	}()
+
 	paramBody, err := json.Marshal(req.Data)
 	if err != nil {
 		return &nb.CommonMessage{}, err
@@ -94,15 +103,26 @@ func (o *csvRepo) GetListInCSV(ctx context.Context, req *nb.CommonMessage) (resp
 		fieldsArr = append(fieldsArr, fBody)
 	}
 
+	rowFilter, err := readCondition(ctx, conn, req.TableSlug, pq.QuoteIdentifier(req.TableSlug), params)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	items, _, err := helper.GetItems(ctx, conn, models.GetItemsBody{
 		TableSlug: req.TableSlug,
 		Params:    params,
 		FieldsMap: fields,
+		RowFilter: rowFilter,
 	})
 	if err != nil {
 		return &nb.CommonMessage{}, err
 	}
 
+	access, err := helper.LoadFieldAccess(ctx, conn, req.TableSlug, params)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	curDir, err := os.Getwd()
 	if err != nil {
 		return &nb.CommonMessage{}, err
@@ -170,7 +190,7 @@ func (o *csvRepo) GetListInCSV(ctx context.Context, req *nb.CommonMessage) (resp
 				}
 				item[field.Slug] = strings.TrimRight(multiselectValue, ",")
 			}
-			record = append(record, cast.ToString(item[field.Slug]))
+			record = append(record, cast.ToString(access.Value(field.Slug, item[field.Slug])))
 		}
 		if err := writer.Write(record); err != nil {
 			return &nb.CommonMessage{}, err
@@ -6,21 +6,35 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
 	"ucode/ucode_go_object_builder_service/config"
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/cache"
+	"ucode/ucode_go_object_builder_service/pkg/endpoint"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	"ucode/ucode_go_object_builder_service/pkg/sqlguard"
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
 	"ucode/ucode_go_object_builder_service/storage"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
-var paramRegexp = regexp.MustCompile(`:(\w+)`)
+// customEndpointColumns are scanned by scanEndpoint. The live content of an
+// endpoint is kept on custom_endpoint, drafts only in custom_endpoint_revision.
+const customEndpointColumns = `id, name, description, sql_query, method, in_transaction, parameters, policy, response,
+	revision, (
+		SELECT MAX(r.revision) FROM custom_endpoint_revision r
+		WHERE r.endpoint_id = custom_endpoint.id AND r.status = 'draft' AND r.revision > custom_endpoint.revision
+	), cache_ttl_seconds, created_at, updated_at`
 
 type customEndpointRepo struct {
 	db *psqlpool.Pool
@@ -47,12 +61,33 @@ func (r *customEndpointRepo) Create(ctx context.Context, req *nb.CreateCustomEnd
 		return nil, fmt.Errorf("marshal parameters: %w", err)
 	}
 
+	policy := policyFromProto(req.GetPolicy())
+	if err = validateEndpointContract(req.GetSql(), policy, req.GetParameters(), req.GetResponse()); err != nil {
+		return nil, err
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return nil, fmt.Errorf("marshal policy: %w", err)
+	}
+
+	responseJSON, err := json.Marshal(req.GetResponse())
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
-		INSERT INTO custom_endpoint (id, name, description, sql_query, method, in_transaction, parameters, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		INSERT INTO custom_endpoint (id, name, description, sql_query, method, in_transaction, parameters, policy, response, revision, cache_ttl_seconds, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1, $10, $11, $11)
 	`
 
-	_, err = conn.Exec(ctx, query,
+	_, err = tx.Exec(ctx, query,
 		id,
 		req.GetName(),
 		req.GetDescription(),
@@ -60,18 +95,46 @@ func (r *customEndpointRepo) Create(ctx context.Context, req *nb.CreateCustomEnd
 		req.GetMethod(),
 		req.GetInTransaction(),
 		paramsJSON,
+		policyJSON,
+		responseJSON,
+		req.GetCacheTtlSeconds(),
 		now,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("custom_endpoint.Create: %w", err)
 	}
 
+	err = insertEndpointRevision(ctx, tx, &nb.CustomEndpointRevision{
+		EndpointId:    id,
+		Revision:      1,
+		Status:        endpoint.RevisionPublished,
+		IsCurrent:     true,
+		Sql:           req.GetSql(),
+		Method:        req.GetMethod(),
+		InTransaction: req.GetInTransaction(),
+		Parameters:    req.GetParameters(),
+		Policy:        req.GetPolicy(),
+		Response:      req.GetResponse(),
+		CommitMessage: req.GetCommitMessage(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("custom_endpoint.Create: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return r.GetById(ctx, &nb.CustomEndpointId{
 		ResourceEnvId: req.GetResourceEnvId(),
 		Id:            id,
 	})
 }
 
+// Update changes the name and description of an endpoint in place. Changes
+// to the executable content are saved as a new draft revision and go live
+// only when the revision is published. Fields left empty keep their value
+// unless the update mask lists them.
 func (r *customEndpointRepo) Update(ctx context.Context, req *nb.CustomEndpoint) (*nb.CustomEndpoint, error) {
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "custom_endpoint.Update")
 	defer dbSpan.Finish()
@@ -81,6 +144,33 @@ func (r *customEndpointRepo) Update(ctx context.Context, req *nb.CustomEndpoint)
 		return nil, err
 	}
 
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	mask, err := endpointUpdateMask(req)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := lockHeadRevision(ctx, tx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	draft := mergeEndpointDraft(head, req, mask)
+
+	if len(endpoint.Diff(head, draft).GetChanges()) > 0 {
+		if err = validateEndpointContract(draft.GetSql(), policyFromProto(draft.GetPolicy()), draft.GetParameters(), draft.GetResponse()); err != nil {
+			return nil, err
+		}
+		if err = insertEndpointRevision(ctx, tx, draft); err != nil {
+			return nil, fmt.Errorf("custom_endpoint.Update: %w", err)
+		}
+	}
+
 	setClauses := []string{"updated_at = $1"}
 	args := []any{time.Now()}
 	idx := 2
@@ -90,30 +180,14 @@ func (r *customEndpointRepo) Update(ctx context.Context, req *nb.CustomEndpoint)
 		args = append(args, req.GetName())
 		idx++
 	}
-	if req.GetDescription() != "" {
+	if mask["description"] || req.GetDescription() != "" {
 		setClauses = append(setClauses, fmt.Sprintf("description = $%d", idx))
 		args = append(args, req.GetDescription())
 		idx++
 	}
-	if req.GetSql() != "" {
-		setClauses = append(setClauses, fmt.Sprintf("sql_query = $%d", idx))
-		args = append(args, req.GetSql())
-		idx++
-	}
-	if req.GetMethod() != "" {
-		setClauses = append(setClauses, fmt.Sprintf("method = $%d", idx))
-		args = append(args, req.GetMethod())
-		idx++
-	}
-
-	setClauses = append(setClauses, fmt.Sprintf("in_transaction = $%d", idx))
-	args = append(args, req.GetInTransaction())
-	idx++
-
-	if len(req.GetParameters()) > 0 {
-		paramsJSON, _ := json.Marshal(req.GetParameters())
-		setClauses = append(setClauses, fmt.Sprintf("parameters = $%d", idx))
-		args = append(args, paramsJSON)
+	if mask["cache_ttl_seconds"] || req.GetCacheTtlSeconds() != nil {
+		setClauses = append(setClauses, fmt.Sprintf("cache_ttl_seconds = $%d", idx))
+		args = append(args, req.GetCacheTtlSeconds().GetValue())
 		idx++
 	}
 
@@ -123,17 +197,94 @@ func (r *customEndpointRepo) Update(ctx context.Context, req *nb.CustomEndpoint)
 		strings.Join(setClauses, ", "), idx,
 	)
 
-	_, err = conn.Exec(ctx, query, args...)
+	_, err = tx.Exec(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("custom_endpoint.Update: %w", err)
 	}
 
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return r.GetById(ctx, &nb.CustomEndpointId{
 		ResourceEnvId: req.GetResourceEnvId(),
 		Id:            req.GetId(),
 	})
 }
 
+// endpointUpdateFields are the fields an update mask can list.
+var endpointUpdateFields = map[string]bool{
+	"name":              true,
+	"description":       true,
+	"sql":               true,
+	"method":            true,
+	"in_transaction":    true,
+	"parameters":        true,
+	"policy":            true,
+	"response":          true,
+	"cache_ttl_seconds": true,
+}
+
+// endpointUpdateMask returns the fields the update mask of req lists.
+// Name, sql and method can not be cleared.
+func endpointUpdateMask(req *nb.CustomEndpoint) (map[string]bool, error) {
+	mask := make(map[string]bool)
+	for _, path := range req.GetUpdateMask().GetPaths() {
+		if !endpointUpdateFields[path] {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown update mask field %q", path)
+		}
+		mask[path] = true
+	}
+
+	switch {
+	case mask["name"] && req.GetName() == "":
+		return nil, status.Error(codes.InvalidArgument, "name can not be empty")
+	case mask["sql"] && req.GetSql() == "":
+		return nil, status.Error(codes.InvalidArgument, "sql can not be empty")
+	case mask["method"] && req.GetMethod() == "":
+		return nil, status.Error(codes.InvalidArgument, "method can not be empty")
+	}
+
+	return mask, nil
+}
+
+// mergeEndpointDraft returns the draft revision req makes of head: the
+// content of head with the fields of req that are in mask or set.
+func mergeEndpointDraft(head *nb.CustomEndpointRevision, req *nb.CustomEndpoint, mask map[string]bool) *nb.CustomEndpointRevision {
+	draft := &nb.CustomEndpointRevision{
+		EndpointId:    req.GetId(),
+		Revision:      head.GetRevision() + 1,
+		Status:        endpoint.RevisionDraft,
+		Sql:           head.GetSql(),
+		Method:        head.GetMethod(),
+		InTransaction: head.GetInTransaction(),
+		Parameters:    head.GetParameters(),
+		Policy:        head.GetPolicy(),
+		Response:      head.GetResponse(),
+		CommitMessage: req.GetCommitMessage(),
+	}
+	if req.GetSql() != "" {
+		draft.Sql = req.GetSql()
+	}
+	if req.GetMethod() != "" {
+		draft.Method = req.GetMethod()
+	}
+	if mask["in_transaction"] || req.GetInTransaction() {
+		draft.InTransaction = req.GetInTransaction()
+	}
+	if mask["parameters"] || len(req.GetParameters()) > 0 {
+		draft.Parameters = req.GetParameters()
+	}
+	if mask["policy"] || req.GetPolicy() != nil {
+		draft.Policy = req.GetPolicy()
+	}
+	if mask["response"] || req.GetResponse() != nil {
+		draft.Response = req.GetResponse()
+	}
+
+	return draft
+}
+
 func (r *customEndpointRepo) GetAll(ctx context.Context, req *nb.GetCustomEndpointListRequest) (*nb.CustomEndpointList, error) {
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "custom_endpoint.GetAll")
 	defer dbSpan.Finish()
@@ -156,8 +307,7 @@ func (r *customEndpointRepo) GetAll(ctx context.Context, req *nb.GetCustomEndpoi
 		endpoints []*nb.CustomEndpoint
 	)
 
-	qb.WriteString(`SELECT id, name, description, sql_query, method, in_transaction, parameters, created_at, updated_at
-		FROM custom_endpoint WHERE deleted_at IS NULL`)
+	qb.WriteString(`SELECT ` + customEndpointColumns + ` FROM custom_endpoint WHERE deleted_at IS NULL`)
 
 	if req.GetSearch() != "" {
 		args = append(args, "%"+req.GetSearch()+"%")
@@ -216,11 +366,10 @@ func (r *customEndpointRepo) GetById(ctx context.Context, req *nb.CustomEndpoint
 		return nil, err
 	}
 
-	query := `SELECT id, name, description, sql_query, method, in_transaction, parameters, created_at, updated_at
-		FROM custom_endpoint WHERE id = $1 AND deleted_at IS NULL`
+	query := `SELECT ` + customEndpointColumns + ` FROM custom_endpoint WHERE id = $1 AND deleted_at IS NULL`
 
 	row := conn.QueryRow(ctx, query, req.GetId())
-	e, err := scanEndpointRow(row)
+	e, err := scanEndpoint(row)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, fmt.Errorf("custom endpoint not found: %s", req.GetId())
@@ -257,7 +406,7 @@ func (r *customEndpointRepo) Delete(ctx context.Context, req *nb.CustomEndpointI
 	return e, nil
 }
 
-func (r *customEndpointRepo) Run(ctx context.Context, req *nb.RunCustomEndpointRequest) (*nb.RunCustomEndpointResponse, error) {
+func (r *customEndpointRepo) Run(ctx context.Context, req *nb.RunCustomEndpointRequest) (resp *nb.RunCustomEndpointResponse, err error) {
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "custom_endpoint.Run")
 	defer dbSpan.Finish()
 
@@ -274,45 +423,113 @@ func (r *customEndpointRepo) Run(ctx context.Context, req *nb.RunCustomEndpointR
 		return nil, err
 	}
 
-	sqlQuery := e.GetSql()
-	inputParams := req.GetParams()
+	if req.GetRevision() > 0 && req.GetRevision() != e.GetRevision() {
+		rev, err := getEndpointRevision(ctx, conn, e.GetId(), req.GetRevision())
+		if err != nil {
+			return nil, err
+		}
+		if rev.GetStatus() != endpoint.RevisionPublished {
+			editor, err := isEndpointEditor(ctx, conn)
+			if err != nil {
+				return nil, err
+			}
+			if !editor {
+				return nil, status.Error(codes.PermissionDenied, "only published revisions can be run")
+			}
+		}
+		e.Revision, e.Sql, e.InTransaction = rev.GetRevision(), rev.GetSql(), rev.GetInTransaction()
+		e.Parameters, e.Policy, e.Response = rev.GetParameters(), rev.GetPolicy(), rev.GetResponse()
+	}
+
+	var (
+		timeout   = conn.Budget(ctx, psqlpool.BudgetUserSQL)
+		startedAt = time.Now()
+		rowCount  int64
+	)
+
+	analysis, guardErr := sqlguard.Inspect(e.GetSql(), policyFromProto(e.GetPolicy()))
+
+	defer func() {
+		entry := models.SQLAuditEntry{
+			Source:       sqlAuditSourceCustomEndpoint,
+			EndpointId:   e.GetId(),
+			Query:        e.GetSql(),
+			Operations:   analysis.Operations(),
+			Tables:       analysis.Tables(),
+			ReadOnly:     analysis.ReadOnly(),
+			Allowed:      guardErr == nil,
+			RowsAffected: rowCount,
+			Duration:     time.Since(startedAt),
+		}
+		if guardErr != nil {
+			entry.Reason = guardErr.Error()
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		} else if resp != nil {
+			entry.Error = resp.GetError()
+		}
+		writeSQLAudit(ctx, conn, r.db.Logger, entry)
+	}()
+
+	if guardErr != nil {
+		return nil, status.Error(codes.PermissionDenied, guardErr.Error())
+	}
+
+	finalSQL, args, err := endpoint.Bind(e.GetSql(), e.GetParameters(), req.GetParams())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	limit := req.GetLimit()
+	if mode, _ := endpoint.NormalizeMode(e.GetResponse().GetMode()); mode == endpoint.ModePaginated {
+		finalSQL, args, limit = endpoint.Paginate(finalSQL, args, req.GetLimit(), req.GetOffset())
+	}
 
-	var args []any
-	argNameMap := make(map[string]int)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	matches := paramRegexp.FindAllStringSubmatch(sqlQuery, -1)
-	finalSQL := sqlQuery
+	if req.GetTestRun() {
+		return r.explain(ctx, conn, finalSQL, args)
+	}
 
-	if len(matches) > 0 {
-		// ─── Формат :paramName → заменяем на $N ───────────────────────
-		for _, match := range matches {
-			paramName := match[1]
-			if _, ok := argNameMap[paramName]; !ok {
-				val, exists := inputParams[paramName]
-				if !exists {
-					args = append(args, nil)
-				} else {
-					args = append(args, val)
-				}
-				argNameMap[paramName] = len(args)
-			}
-			placeholder := fmt.Sprintf("$%d", argNameMap[paramName])
-			finalSQL = strings.ReplaceAll(finalSQL, ":"+paramName, placeholder)
+	// Row security shows each subject its own rows, so cached results are
+	// kept per role chain and user.
+	subject, _ := authz.SubjectFromContext(ctx)
+	chain, err := helper.LoadRoleChain(ctx, conn, subject.RoleId)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cacheTTL   = time.Duration(e.GetCacheTtlSeconds().GetValue()) * time.Second
+		cacheEntry = cache.Entry{
+			Scope:  cacheScope(conn),
+			Tables: analysis.Tables(),
+			Parts: []any{"custom_endpoint", e.GetId(), e.GetRevision(), cache.NormalizeQuery(finalSQL), args, limit, req.GetOffset(),
+				chain, subject.UserId, subject.ClientTypeId},
 		}
-	} else {
-		// ─── Формат $1, $2... → строим args по порядку из e.Parameters ─
-		for _, param := range e.GetParameters() {
-			val, exists := inputParams[param.GetName()]
-			if !exists {
-				args = append(args, nil)
-			} else {
-				args = append(args, val)
-			}
+	)
+
+	if cacheTTL > 0 && analysis.ReadOnly() {
+		if data, ok := cache.Default().Get(ctx, cacheEntry); ok {
+			return &nb.RunCustomEndpointResponse{Data: data, Cached: true}, nil
 		}
 	}
 
-	rows, err := conn.Query(ctx, finalSQL, args...)
+	// The SQL always runs in a guarded transaction, so the row security
+	// policies see the subject of the request.
+	tx, err := beginGuardedTx(ctx, conn, analysis.ReadOnly(), timeout)
+	if err != nil {
+		return &nb.RunCustomEndpointResponse{Error: err.Error()}, nil
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	rows, err := tx.Query(ctx, finalSQL, args...)
 	if err != nil {
+		if helper.IsQueryTimeout(err) {
+			return nil, r.db.HandleDatabaseError(err, "custom_endpoint.Run")
+		}
 		return &nb.RunCustomEndpointResponse{Error: err.Error()}, nil
 	}
 	defer rows.Close()
@@ -325,30 +542,91 @@ func (r *customEndpointRepo) Run(ctx context.Context, req *nb.RunCustomEndpointR
 		if err != nil {
 			return nil, err
 		}
-		rowMap := make(map[string]any)
+		rowMap := make(map[string]any, len(fields))
 		for i, field := range fields {
-			val := values[i]
-			// pgx возвращает UUID как [16]byte — конвертируем в строку
-			if b, ok := val.([16]byte); ok {
-				val = fmt.Sprintf("%08x-%04x-%04x-%04x-%012x",
-					b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
-			}
-			rowMap[field.Name] = val
+			rowMap[field.Name] = values[i]
 		}
 		result = append(result, rowMap)
 	}
 	if err = rows.Err(); err != nil {
+		if helper.IsQueryTimeout(err) {
+			return nil, r.db.HandleDatabaseError(err, "custom_endpoint.Run")
+		}
 		return nil, fmt.Errorf("custom_endpoint.Run rows: %w", err)
 	}
 
-	data, err := json.Marshal(result)
+	rowCount = int64(len(result))
+	if tag := rows.CommandTag(); tag.RowsAffected() > rowCount {
+		rowCount = tag.RowsAffected()
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return &nb.RunCustomEndpointResponse{Error: err.Error()}, nil
+	}
+
+	if !analysis.ReadOnly() {
+		invalidateCache(ctx, req.GetResourceEnvId(), analysis.Tables()...)
+	}
+
+	data, err := endpoint.Shape(result, e.GetResponse(), limit, req.GetOffset())
 	if err != nil {
-		return nil, err
+		return &nb.RunCustomEndpointResponse{Error: err.Error()}, nil
+	}
+
+	if cacheTTL > 0 && analysis.ReadOnly() {
+		cache.Default().Set(ctx, cacheEntry, data, cacheTTL)
 	}
 
 	return &nb.RunCustomEndpointResponse{Data: data}, nil
 }
 
+// isEndpointEditor reports whether the subject of ctx may run draft
+// revisions: the service itself and ADMIN client types.
+func isEndpointEditor(ctx context.Context, q querier) (bool, error) {
+	if !authz.FromRequest(ctx) {
+		return true, nil
+	}
+	subject, _ := authz.SubjectFromContext(ctx)
+	return isAdminClientType(ctx, q, subject.ClientTypeId)
+}
+
+// explain returns the SQL a run would execute, its bound arguments and the
+// planner output, without executing the statement.
+func (r *customEndpointRepo) explain(ctx context.Context, conn *psqlpool.Pool, finalSQL string, args []any) (*nb.RunCustomEndpointResponse, error) {
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("marshal args: %w", err)
+	}
+
+	resp := &nb.RunCustomEndpointResponse{Sql: finalSQL, Args: argsJSON}
+
+	rows, err := conn.Query(ctx, "EXPLAIN "+finalSQL, args...)
+	if err != nil {
+		if helper.IsQueryTimeout(err) {
+			return nil, r.db.HandleDatabaseError(err, "custom_endpoint.Run")
+		}
+		resp.Error = err.Error()
+		return resp, nil
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err = rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		plan = append(plan, line)
+	}
+	if err = rows.Err(); err != nil {
+		resp.Error = err.Error()
+		return resp, nil
+	}
+
+	resp.Plan = strings.Join(plan, "\n")
+	return resp, nil
+}
+
 // ─── helpers ──────────────────────────────────────────────────────────────────
 
 type rowScanner interface {
@@ -361,10 +639,16 @@ func scanEndpoint(row rowScanner) (*nb.CustomEndpoint, error) {
 		createdAt, updatedAt time.Time
 		desc                 sql.NullString
 		paramsJSON           []byte
+		policyJSON           []byte
+		responseJSON         []byte
+		draftRevision        sql.NullInt32
+		cacheTTL             int32
 	)
-	if err := row.Scan(&e.Id, &e.Name, &desc, &e.Sql, &e.Method, &e.InTransaction, &paramsJSON, &createdAt, &updatedAt); err != nil {
+	if err := row.Scan(&e.Id, &e.Name, &desc, &e.Sql, &e.Method, &e.InTransaction, &paramsJSON, &policyJSON, &responseJSON, &e.Revision, &draftRevision, &cacheTTL, &createdAt, &updatedAt); err != nil {
 		return nil, fmt.Errorf("scanEndpoint: %w", err)
 	}
+	e.DraftRevision = draftRevision.Int32
+	e.CacheTtlSeconds = wrapperspb.Int32(cacheTTL)
 	if desc.Valid {
 		e.Description = desc.String
 	}
@@ -374,11 +658,57 @@ func scanEndpoint(row rowScanner) (*nb.CustomEndpoint, error) {
 			e.Parameters = params
 		}
 	}
+	if len(policyJSON) > 0 {
+		var policy sqlguard.Policy
+		if err := json.Unmarshal(policyJSON, &policy); err == nil {
+			e.Policy = policyToProto(policy)
+		}
+	}
+	if len(responseJSON) > 0 {
+		var response nb.ResponseShape
+		if err := json.Unmarshal(responseJSON, &response); err == nil {
+			e.Response = &response
+		}
+	}
 	e.CreatedAt = createdAt.Format(time.RFC3339)
 	e.UpdatedAt = updatedAt.Format(time.RFC3339)
 	return &e, nil
 }
 
-func scanEndpointRow(row interface{ Scan(...any) error }) (*nb.CustomEndpoint, error) {
-	return scanEndpoint(row)
+func policyFromProto(p *nb.SqlPolicy) sqlguard.Policy {
+	return sqlguard.Policy{
+		ReadOnly:          p.GetReadOnly(),
+		AllowedOperations: p.GetAllowedOperations(),
+		AllowedTables:     p.GetAllowedTables(),
+	}
+}
+
+func policyToProto(p sqlguard.Policy) *nb.SqlPolicy {
+	return &nb.SqlPolicy{
+		ReadOnly:          p.ReadOnly,
+		AllowedOperations: p.AllowedOperations,
+		AllowedTables:     p.AllowedTables,
+	}
+}
+
+// validateEndpointContract checks the SQL, policy, parameters and response
+// shape of an endpoint before it is stored.
+func validateEndpointContract(sqlQuery string, policy sqlguard.Policy, params []*nb.Parameter, response *nb.ResponseShape) error {
+	analysis, err := sqlguard.Inspect(sqlQuery, policy)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err = endpoint.ValidateDeclarations(params); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err = endpoint.ValidatePlaceholders(sqlQuery, params); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err = endpoint.ValidateShape(response); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if mode, _ := endpoint.NormalizeMode(response.GetMode()); mode == endpoint.ModePaginated && !analysis.ReadOnly() {
+		return status.Error(codes.InvalidArgument, "paginated responses require a read-only query")
+	}
+	return nil
 }
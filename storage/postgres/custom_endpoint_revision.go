@@ -0,0 +1,344 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/endpoint"
+	"ucode/ucode_go_object_builder_service/pkg/sqlguard"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/opentracing/opentracing-go"
+)
+
+const customEndpointRevisionColumns = `id, endpoint_id, revision, status, is_current, sql_query, method, in_transaction,
+	parameters, policy, response, commit_message, created_at, published_at`
+
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func (r *customEndpointRepo) GetRevisionList(ctx context.Context, req *nb.GetCustomEndpointRevisionListRequest) (*nb.CustomEndpointRevisionList, error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "custom_endpoint.GetRevisionList")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetResourceEnvId())
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.GetLimit()
+	if limit == 0 {
+		limit = 20
+	}
+
+	var count uint32
+	err = conn.QueryRow(ctx,
+		`SELECT COUNT(*) FROM custom_endpoint_revision WHERE endpoint_id = $1`,
+		req.GetEndpointId(),
+	).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("custom_endpoint.GetRevisionList: %w", err)
+	}
+
+	rows, err := conn.Query(ctx,
+		`SELECT `+customEndpointRevisionColumns+`
+		 FROM custom_endpoint_revision
+		 WHERE endpoint_id = $1
+		 ORDER BY revision DESC
+		 LIMIT $2 OFFSET $3`,
+		req.GetEndpointId(), limit, req.GetOffset(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("custom_endpoint.GetRevisionList: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []*nb.CustomEndpointRevision
+	for rows.Next() {
+		rev, err := scanEndpointRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("custom_endpoint.GetRevisionList rows: %w", err)
+	}
+
+	return &nb.CustomEndpointRevisionList{
+		Revisions: revisions,
+		Count:     count,
+	}, nil
+}
+
+// GetRevision returns one revision of an endpoint. Revision 0 means the
+// published revision that currently serves calls.
+func (r *customEndpointRepo) GetRevision(ctx context.Context, req *nb.CustomEndpointRevisionId) (*nb.CustomEndpointRevision, error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "custom_endpoint.GetRevision")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetResourceEnvId())
+	if err != nil {
+		return nil, err
+	}
+
+	return getEndpointRevision(ctx, conn, req.GetEndpointId(), req.GetRevision())
+}
+
+// PublishRevision makes a revision the live content of its endpoint. It is
+// used both to publish a draft and to roll back to an earlier revision.
+func (r *customEndpointRepo) PublishRevision(ctx context.Context, req *nb.CustomEndpointRevisionId) (*nb.CustomEndpoint, error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "custom_endpoint.PublishRevision")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetResourceEnvId())
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = lockHeadRevision(ctx, tx, req.GetEndpointId()); err != nil {
+		return nil, err
+	}
+
+	rev, err := getEndpointRevision(ctx, tx, req.GetEndpointId(), req.GetRevision())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateEndpointContract(rev.GetSql(), policyFromProto(rev.GetPolicy()), rev.GetParameters(), rev.GetResponse()); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE custom_endpoint_revision SET is_current = false WHERE endpoint_id = $1 AND is_current`,
+		req.GetEndpointId(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("custom_endpoint.PublishRevision: %w", err)
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE custom_endpoint_revision
+		 SET is_current = true, status = $3, published_at = COALESCE(published_at, now())
+		 WHERE endpoint_id = $1 AND revision = $2`,
+		req.GetEndpointId(), rev.GetRevision(), endpoint.RevisionPublished,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("custom_endpoint.PublishRevision: %w", err)
+	}
+
+	paramsJSON, policyJSON, responseJSON, err := marshalRevisionContent(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE custom_endpoint
+		 SET sql_query = $2, method = $3, in_transaction = $4, parameters = $5, policy = $6, response = $7,
+		     revision = $8, updated_at = now()
+		 WHERE id = $1`,
+		req.GetEndpointId(), rev.GetSql(), rev.GetMethod(), rev.GetInTransaction(),
+		paramsJSON, policyJSON, responseJSON, rev.GetRevision(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("custom_endpoint.PublishRevision: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return r.GetById(ctx, &nb.CustomEndpointId{
+		ResourceEnvId: req.GetResourceEnvId(),
+		Id:            req.GetEndpointId(),
+	})
+}
+
+// DiffRevisions compares two revisions of an endpoint. A zero from_revision
+// means the published revision, a zero to_revision the latest one.
+func (r *customEndpointRepo) DiffRevisions(ctx context.Context, req *nb.DiffCustomEndpointRevisionsRequest) (*nb.CustomEndpointRevisionDiff, error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "custom_endpoint.DiffRevisions")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetResourceEnvId())
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := getEndpointRevision(ctx, conn, req.GetEndpointId(), req.GetFromRevision())
+	if err != nil {
+		return nil, err
+	}
+
+	var to *nb.CustomEndpointRevision
+	if req.GetToRevision() == 0 {
+		to, err = scanEndpointRevision(conn.QueryRow(ctx,
+			`SELECT `+customEndpointRevisionColumns+` FROM custom_endpoint_revision
+			 WHERE endpoint_id = $1 ORDER BY revision DESC LIMIT 1`,
+			req.GetEndpointId(),
+		))
+	} else {
+		to, err = getEndpointRevision(ctx, conn, req.GetEndpointId(), req.GetToRevision())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoint.Diff(from, to), nil
+}
+
+// lockHeadRevision locks the endpoint row, serialising revision numbering and
+// publishing, and returns its latest revision.
+func lockHeadRevision(ctx context.Context, tx pgx.Tx, endpointId string) (*nb.CustomEndpointRevision, error) {
+	var id string
+	err := tx.QueryRow(ctx,
+		`SELECT id FROM custom_endpoint WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`,
+		endpointId,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("custom endpoint not found: %s", endpointId)
+		}
+		return nil, fmt.Errorf("lockHeadRevision: %w", err)
+	}
+
+	rev, err := scanEndpointRevision(tx.QueryRow(ctx,
+		`SELECT `+customEndpointRevisionColumns+` FROM custom_endpoint_revision
+		 WHERE endpoint_id = $1 ORDER BY revision DESC LIMIT 1`,
+		endpointId,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("lockHeadRevision: %w", err)
+	}
+	return rev, nil
+}
+
+func getEndpointRevision(ctx context.Context, db rowQuerier, endpointId string, revision int32) (*nb.CustomEndpointRevision, error) {
+	var (
+		query = `SELECT ` + customEndpointRevisionColumns + ` FROM custom_endpoint_revision WHERE endpoint_id = $1`
+		args  = []any{endpointId}
+	)
+	if revision == 0 {
+		query += ` AND is_current`
+	} else {
+		query += ` AND revision = $2`
+		args = append(args, revision)
+	}
+
+	rev, err := scanEndpointRevision(db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("custom endpoint revision not found: %s@%d", endpointId, revision)
+		}
+		return nil, err
+	}
+	return rev, nil
+}
+
+func insertEndpointRevision(ctx context.Context, tx pgx.Tx, rev *nb.CustomEndpointRevision) error {
+	paramsJSON, policyJSON, responseJSON, err := marshalRevisionContent(rev)
+	if err != nil {
+		return err
+	}
+
+	var publishedAt any
+	if rev.GetStatus() == endpoint.RevisionPublished {
+		publishedAt = time.Now()
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO custom_endpoint_revision (
+			endpoint_id, revision, status, is_current, sql_query, method, in_transaction,
+			parameters, policy, response, commit_message, published_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		rev.GetEndpointId(),
+		rev.GetRevision(),
+		rev.GetStatus(),
+		rev.GetIsCurrent(),
+		rev.GetSql(),
+		rev.GetMethod(),
+		rev.GetInTransaction(),
+		paramsJSON,
+		policyJSON,
+		responseJSON,
+		nullString(rev.GetCommitMessage()),
+		publishedAt,
+	)
+	return err
+}
+
+func marshalRevisionContent(rev *nb.CustomEndpointRevision) (params, policy, response []byte, err error) {
+	if params, err = json.Marshal(rev.GetParameters()); err != nil {
+		return nil, nil, nil, fmt.Errorf("marshal parameters: %w", err)
+	}
+	if params == nil || string(params) == "null" {
+		params = []byte("[]")
+	}
+	if policy, err = json.Marshal(policyFromProto(rev.GetPolicy())); err != nil {
+		return nil, nil, nil, fmt.Errorf("marshal policy: %w", err)
+	}
+	if response, err = json.Marshal(rev.GetResponse()); err != nil {
+		return nil, nil, nil, fmt.Errorf("marshal response: %w", err)
+	}
+	if string(response) == "null" {
+		response = []byte("{}")
+	}
+	return params, policy, response, nil
+}
+
+func scanEndpointRevision(row rowScanner) (*nb.CustomEndpointRevision, error) {
+	var (
+		rev                                  nb.CustomEndpointRevision
+		paramsJSON, policyJSON, responseJSON []byte
+		commitMessage                        sql.NullString
+		createdAt                            time.Time
+		publishedAt                          sql.NullTime
+	)
+
+	err := row.Scan(
+		&rev.Id, &rev.EndpointId, &rev.Revision, &rev.Status, &rev.IsCurrent, &rev.Sql, &rev.Method, &rev.InTransaction,
+		&paramsJSON, &policyJSON, &responseJSON, &commitMessage, &createdAt, &publishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paramsJSON) > 0 {
+		var params []*nb.Parameter
+		if err := json.Unmarshal(paramsJSON, &params); err == nil {
+			rev.Parameters = params
+		}
+	}
+	if len(policyJSON) > 0 {
+		var policy sqlguard.Policy
+		if err := json.Unmarshal(policyJSON, &policy); err == nil {
+			rev.Policy = policyToProto(policy)
+		}
+	}
+	if len(responseJSON) > 0 {
+		var response nb.ResponseShape
+		if err := json.Unmarshal(responseJSON, &response); err == nil {
+			rev.Response = &response
+		}
+	}
+
+	rev.CommitMessage = commitMessage.String
+	rev.CreatedAt = createdAt.Format(time.RFC3339)
+	if publishedAt.Valid {
+		rev.PublishedAt = publishedAt.Time.Format(time.RFC3339)
+	}
+	return &rev, nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"testing"
+
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/endpoint"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestMergeEndpointDraft(t *testing.T) {
+	head := &nb.CustomEndpointRevision{
+		Revision:      3,
+		Sql:           "SELECT 1",
+		Method:        "GET",
+		InTransaction: true,
+		Parameters:    []*nb.Parameter{{Name: "id"}},
+		Response:      &nb.ResponseShape{Mode: "single"},
+	}
+
+	tests := []struct {
+		name  string
+		req   *nb.CustomEndpoint
+		check func(t *testing.T, draft *nb.CustomEndpointRevision)
+	}{
+		{
+			name: "empty fields keep head",
+			req:  &nb.CustomEndpoint{Id: "e", Sql: "SELECT 2"},
+			check: func(t *testing.T, draft *nb.CustomEndpointRevision) {
+				assert.Equal(t, "SELECT 2", draft.GetSql())
+				assert.Equal(t, "GET", draft.GetMethod())
+				assert.True(t, draft.GetInTransaction())
+				assert.Len(t, draft.GetParameters(), 1)
+				assert.Equal(t, "single", draft.GetResponse().GetMode())
+			},
+		},
+		{
+			name: "mask clears",
+			req: &nb.CustomEndpoint{Id: "e", UpdateMask: &fieldmaskpb.FieldMask{
+				Paths: []string{"in_transaction", "parameters", "response"},
+			}},
+			check: func(t *testing.T, draft *nb.CustomEndpointRevision) {
+				assert.Equal(t, "SELECT 1", draft.GetSql())
+				assert.False(t, draft.GetInTransaction())
+				assert.Empty(t, draft.GetParameters())
+				assert.Nil(t, draft.GetResponse())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mask, err := endpointUpdateMask(tt.req)
+			require.NoError(t, err)
+
+			draft := mergeEndpointDraft(head, tt.req, mask)
+			assert.Equal(t, "e", draft.GetEndpointId())
+			assert.Equal(t, int32(4), draft.GetRevision())
+			assert.Equal(t, endpoint.RevisionDraft, draft.GetStatus())
+			tt.check(t, draft)
+		})
+	}
+}
+
+func TestEndpointUpdateMask(t *testing.T) {
+	_, err := endpointUpdateMask(&nb.CustomEndpoint{UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"revision"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = endpointUpdateMask(&nb.CustomEndpoint{UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"sql"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	mask, err := endpointUpdateMask(&nb.CustomEndpoint{Description: "", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"description"}}})
+	require.NoError(t, err)
+	assert.True(t, mask["description"])
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
@@ -14,7 +15,12 @@ import (
 	"ucode/ucode_go_object_builder_service/storage"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
 	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -79,24 +85,28 @@ func (r *customPermissionsRepo) Create(ctx context.Context, req *nb.CreateCustom
 	}
 
 	// Auto-create access rows only for roles that belong to this custom permission's client type.
-	// Defaults in DB are 'No' (deny). Exception: a nav-gating permission with
-	// attributes.nav_path seeds read='Yes' so a new sidebar item stays visible
-	// until an admin explicitly hides it.
-	readDefault := "No"
+	// Roots default to 'No' (deny), children inherit the access of their parent.
+	// Exception: a nav-gating permission with attributes.nav_path seeds
+	// read='Yes' so a new sidebar item stays visible until an admin explicitly hides it.
+	var accessDefault any = "No"
+	if req.ParentId != "" {
+		accessDefault = nil
+	}
+	readDefault := accessDefault
 	if attrs := req.GetAttributes(); attrs != nil {
 		if v, ok := attrs.GetFields()["nav_path"]; ok && strings.TrimSpace(v.GetStringValue()) != "" {
 			readDefault = "Yes"
 		}
 	}
 	accessQuery := `
-       INSERT INTO custom_permission_access (id, custom_permission_id, role_id, client_type_id, "read")
-       SELECT uuid_generate_v4(), $1, r.guid, r.client_type_id, $2
+       INSERT INTO custom_permission_access (id, custom_permission_id, role_id, client_type_id, "read", "write", "update", "delete")
+       SELECT uuid_generate_v4(), $1, r.guid, r.client_type_id, $2, $4, $4, $4
        FROM role r
        WHERE r.client_type_id = $3
        ON CONFLICT (custom_permission_id, role_id, client_type_id) DO NOTHING
     `
 	// Используем Exec, так как нам не нужны данные обратно
-	_, err = conn.Exec(ctx, accessQuery, id, readDefault, req.ClientTypeId)
+	_, err = conn.Exec(ctx, accessQuery, id, readDefault, req.ClientTypeId, accessDefault)
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +125,16 @@ func (r *customPermissionsRepo) Update(ctx context.Context, req *nb.UpdateCustom
 		return nil, err
 	}
 
+	if req.ParentId != "" {
+		tree, err := loadCustomPermissionTree(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if tree.isAncestor(req.Id, req.ParentId) {
+			return nil, status.Error(codes.InvalidArgument, "parent_id is the permission or one of its children")
+		}
+	}
+
 	attributesBytes, err := json.Marshal(req.Attributes)
 	if err != nil {
 		attributesBytes = []byte("{}")
@@ -238,6 +258,13 @@ func (r *customPermissionsRepo) GetAll(ctx context.Context, req *nb.GetAllCustom
 
 // ==================== Access ====================
 
+// customPermissionVisible limits the permissions of an access listing to
+// the ones of the client type $2 and those role $1 has access rows for.
+const customPermissionVisible = `(cp.client_type_id IS NULL OR cp.client_type_id = $2 OR EXISTS (
+	SELECT 1 FROM custom_permission_access cpa
+	WHERE cpa.custom_permission_id = cp.id AND cpa.role_id = $1 AND cpa.client_type_id = $2
+))`
+
 func (r *customPermissionsRepo) GetAccesses(ctx context.Context, req *nb.GetCustomPermissionAccessesRequest) (*nb.GetCustomPermissionAccessesResponse, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "customPermissionsRepo.GetAccesses")
 	defer span.Finish()
@@ -260,16 +287,14 @@ func (r *customPermissionsRepo) GetAccesses(ctx context.Context, req *nb.GetCust
 	}
 
 	query := fmt.Sprintf(`
-       SELECT cp.id, cp.title, cp.parent_id, cp.attributes,
-              cpa."read", cpa."write", cpa."update", cpa."delete"
+       SELECT cp.id, cp.title, cp.parent_id, cp.attributes
        FROM custom_permission cp
-       JOIN custom_permission_access cpa ON cpa.custom_permission_id = cp.id
-       WHERE cpa.role_id = $1 AND cpa.client_type_id = $2
+       WHERE %s
          AND %s
        ORDER BY cp.created_at
-    `, parentFilter)
+    `, customPermissionVisible, parentFilter)
 
-	return r.scanPermissionWithAccessRows(ctx, conn, query, args...)
+	return r.scanPermissionWithAccessRows(ctx, conn, req.RoleId, req.ClientTypeId, query, args...)
 }
 
 func (r *customPermissionsRepo) GetAllAccesses(ctx context.Context, req *nb.GetAllCustomPermissionAccessesRequest) (*nb.GetCustomPermissionAccessesResponse, error) {
@@ -282,19 +307,18 @@ func (r *customPermissionsRepo) GetAllAccesses(ctx context.Context, req *nb.GetA
 	}
 
 	query := `
-       SELECT cp.id, cp.title, cp.parent_id, cp.attributes,
-              cpa."read", cpa."write", cpa."update", cpa."delete"
+       SELECT cp.id, cp.title, cp.parent_id, cp.attributes
        FROM custom_permission cp
-       JOIN custom_permission_access cpa ON cpa.custom_permission_id = cp.id
-       WHERE cpa.role_id = $1 AND cpa.client_type_id = $2
+       WHERE ` + customPermissionVisible + `
        ORDER BY cp.created_at
     `
 
-	return r.scanPermissionWithAccessRows(ctx, conn, query, req.RoleId, req.ClientTypeId)
+	return r.scanPermissionWithAccessRows(ctx, conn, req.RoleId, req.ClientTypeId, query, req.RoleId, req.ClientTypeId)
 }
 
 // UpdateAccess — unified update handler.
-// Теперь работает со строками "Yes"/"No"
+// Теперь работает со строками "Yes"/"No", "Inherit" clears the value so the
+// parent's one applies.
 func (r *customPermissionsRepo) UpdateAccess(ctx context.Context, req *nb.UpdateCustomPermissionAccessRequest) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "customPermissionsRepo.UpdateAccess")
 	defer span.Finish()
@@ -311,27 +335,19 @@ func (r *customPermissionsRepo) UpdateAccess(ctx context.Context, req *nb.Update
 	)
 
 	// Проверяем не на nil, а на пустую строку
-	if req.Read != "" {
-		setClauses = append(setClauses, fmt.Sprintf("\"read\" = $%d", argIdx))
-		args = append(args, req.Read)
-		argIdx++
-	}
-
-	if req.Write != "" {
-		setClauses = append(setClauses, fmt.Sprintf("\"write\" = $%d", argIdx))
-		args = append(args, req.Write)
-		argIdx++
-	}
+	values := customAccess{"read": req.Read, "write": req.Write, "update": req.Update, "delete": req.Delete}
+	for _, action := range customAccessActions {
+		if values[action] == "" {
+			continue
+		}
 
-	if req.Update != "" {
-		setClauses = append(setClauses, fmt.Sprintf("\"update\" = $%d", argIdx))
-		args = append(args, req.Update)
-		argIdx++
-	}
+		value, err := customAccessValue(values[action])
+		if err != nil {
+			return err
+		}
 
-	if req.Delete != "" {
-		setClauses = append(setClauses, fmt.Sprintf("\"delete\" = $%d", argIdx))
-		args = append(args, req.Delete)
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", pq.QuoteIdentifier(action), argIdx))
+		args = append(args, value)
 		argIdx++
 	}
 
@@ -366,9 +382,363 @@ func (r *customPermissionsRepo) UpdateAccess(ctx context.Context, req *nb.Update
 	return err
 }
 
+// ==================== Matrix ====================
+
+// GetMatrix returns the effective access of every role, or of the roles of
+// req, to every custom permission of their client type.
+func (r *customPermissionsRepo) GetMatrix(ctx context.Context, req *nb.GetCustomPermissionMatrixRequest) (*nb.CustomPermissionMatrix, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "customPermissionsRepo.GetMatrix")
+	defer span.Finish()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := r.GetAll(ctx, &nb.GetAllCustomPermissionsRequest{ProjectId: req.ProjectId})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, `
+       SELECT guid::TEXT, client_type_id::TEXT
+       FROM role
+       WHERE client_type_id IS NOT NULL AND COALESCE(is_template, false) = false
+         AND ($1 = '' OR client_type_id::TEXT = $1)
+         AND (COALESCE(cardinality($2::TEXT[]), 0) = 0 OR guid::TEXT = ANY($2))
+       ORDER BY name
+    `, req.ClientTypeId, pq.Array(req.RoleIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		roles   []customAccessKey
+		roleIds []string
+	)
+	for rows.Next() {
+		var role customAccessKey
+		if err := rows.Scan(&role.roleId, &role.clientTypeId); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+		roleIds = append(roleIds, role.roleId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tree, err := loadCustomPermissionTree(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	accesses, err := loadCustomAccess(ctx, conn, roleIds, req.ClientTypeId)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &nb.CustomPermissionMatrix{
+		Permissions: permissions.CustomPermissions,
+		Cells:       []*nb.CustomPermissionMatrixCell{},
+	}
+
+	for _, role := range roles {
+		access := accesses[role]
+		for _, perm := range permissions.CustomPermissions {
+			if _, ok := access[perm.Id]; !ok && perm.ClientTypeId != "" && perm.ClientTypeId != role.clientTypeId {
+				continue
+			}
+
+			values, inherited := tree.effective(access, perm.Id)
+			res.Cells = append(res.Cells, &nb.CustomPermissionMatrixCell{
+				RoleId:             role.roleId,
+				ClientTypeId:       role.clientTypeId,
+				CustomPermissionId: perm.Id,
+				Read:               values["read"],
+				Write:              values["write"],
+				Update:             values["update"],
+				Delete:             values["delete"],
+				Inherited:          inherited,
+			})
+		}
+	}
+
+	return res, nil
+}
+
+// UpdateMatrix writes the cells of req in one transaction. Cells without a
+// client type take the one of their role; values left empty are kept, or
+// inherited on a new access row.
+func (r *customPermissionsRepo) UpdateMatrix(ctx context.Context, req *nb.UpdateCustomPermissionMatrixRequest) (err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "customPermissionsRepo.UpdateMatrix")
+	defer span.Finish()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	for _, cell := range req.Cells {
+		if cell.RoleId == "" || cell.CustomPermissionId == "" {
+			return status.Error(codes.InvalidArgument, "role_id and custom_permission_id are required")
+		}
+
+		var (
+			values     = customAccess{"read": cell.Read, "write": cell.Write, "update": cell.Update, "delete": cell.Delete}
+			args       = []any{cell.CustomPermissionId, cell.RoleId, cell.ClientTypeId}
+			setClauses []string
+		)
+		for _, action := range customAccessActions {
+			var value any
+			if values[action] != "" {
+				if value, err = customAccessValue(values[action]); err != nil {
+					return err
+				}
+				setClauses = append(setClauses, fmt.Sprintf("%s = EXCLUDED.%s", pq.QuoteIdentifier(action), pq.QuoteIdentifier(action)))
+			}
+			args = append(args, value)
+		}
+		if len(setClauses) == 0 {
+			continue
+		}
+
+		query := fmt.Sprintf(`
+          INSERT INTO custom_permission_access (id, custom_permission_id, role_id, client_type_id, "read", "write", "update", "delete")
+          SELECT uuid_generate_v4(), $1, r.guid, COALESCE(NULLIF($3, '')::UUID, r.client_type_id), $4, $5, $6, $7
+          FROM role r
+          WHERE r.guid = $2
+          ON CONFLICT (custom_permission_id, role_id, client_type_id) DO UPDATE SET %s, updated_at = NOW()
+       `, strings.Join(setClauses, ", "))
+
+		tag, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return status.Errorf(codes.NotFound, "role %s not found", cell.RoleId)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ==================== Check ====================
+
+// HasCustomPermission tells whether a role has an action on the custom
+// permission with the id req.Key, its own or inherited from a parent
+// permission.
+func (r *customPermissionsRepo) HasCustomPermission(ctx context.Context, req *nb.HasCustomPermissionRequest) (*nb.HasCustomPermissionResponse, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "customPermissionsRepo.HasCustomPermission")
+	defer span.Finish()
+
+	if req.RoleId == "" || req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "role_id and key are required")
+	}
+
+	action := req.Action
+	if action == "" {
+		action = "read"
+	}
+	if !slices.Contains(customAccessActions, action) {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown action %q", action)
+	}
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	clientTypeId := req.ClientTypeId
+	if clientTypeId == "" {
+		err = conn.QueryRow(ctx, `SELECT COALESCE(client_type_id::TEXT, '') FROM role WHERE guid::TEXT = $1`, req.RoleId).Scan(&clientTypeId)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "role %s not found", req.RoleId)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tree, err := loadCustomPermissionTree(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tree[req.Key]; !ok {
+		return nil, status.Errorf(codes.NotFound, "custom permission %s not found", req.Key)
+	}
+
+	res := &nb.HasCustomPermissionResponse{CustomPermissionId: req.Key}
+
+	accesses, err := loadCustomAccess(ctx, conn, []string{req.RoleId}, clientTypeId)
+	if err != nil {
+		return nil, err
+	}
+
+	value, from := tree.resolve(accesses[customAccessKey{req.RoleId, clientTypeId}], res.CustomPermissionId, action)
+	res.Allowed = value == "Yes"
+	if from != res.CustomPermissionId {
+		res.InheritedFrom = from
+	}
+
+	return res, nil
+}
+
 // ==================== Helpers ====================
 
-func (r *customPermissionsRepo) scanPermissionWithAccessRows(ctx context.Context, conn *psqlpool.Pool, query string, args ...interface{}) (*nb.GetCustomPermissionAccessesResponse, error) {
+// customAccessActions are the actions of custom_permission_access.
+var customAccessActions = []string{"read", "write", "update", "delete"}
+
+// customAccessInherit clears the access of a role to a permission, stored
+// as NULL, so it takes the one of the parent permission.
+const customAccessInherit = "Inherit"
+
+// customAccess holds the stored values of a role on a permission by
+// action. A missing value is inherited.
+type customAccess map[string]string
+
+// customAccessKey is a role with the client type of its access rows.
+type customAccessKey struct {
+	roleId       string
+	clientTypeId string
+}
+
+// customPermissionTree maps a custom permission to its parent.
+type customPermissionTree map[string]string
+
+// resolve returns the value of action on id, the one stored on it or on its
+// nearest parent that has one, and the permission it comes from. Without
+// any it is No.
+func (t customPermissionTree) resolve(access map[string]customAccess, id, action string) (value, from string) {
+	seen := make(map[string]bool)
+	for node := id; node != "" && !seen[node]; node = t[node] {
+		if value := access[node][action]; value != "" {
+			return value, node
+		}
+		seen[node] = true
+	}
+	return "No", ""
+}
+
+// effective resolves every action on id, listing the ones that come from a
+// parent.
+func (t customPermissionTree) effective(access map[string]customAccess, id string) (customAccess, []string) {
+	var (
+		values    = make(customAccess, len(customAccessActions))
+		inherited []string
+	)
+	for _, action := range customAccessActions {
+		value, from := t.resolve(access, id, action)
+		values[action] = value
+		if from != "" && from != id {
+			inherited = append(inherited, action)
+		}
+	}
+	return values, inherited
+}
+
+// isAncestor tells whether ancestor is id or one of its parents.
+func (t customPermissionTree) isAncestor(ancestor, id string) bool {
+	seen := make(map[string]bool)
+	for node := id; node != "" && !seen[node]; node = t[node] {
+		if node == ancestor {
+			return true
+		}
+		seen[node] = true
+	}
+	return false
+}
+
+// customAccessValue returns the column value of an access value.
+func customAccessValue(value string) (any, error) {
+	switch value {
+	case "Yes", "No":
+		return value, nil
+	case customAccessInherit:
+		return nil, nil
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "access must be Yes, No or %s, got %q", customAccessInherit, value)
+}
+
+func loadCustomPermissionTree(ctx context.Context, q querier) (customPermissionTree, error) {
+	rows, err := q.Query(ctx, `SELECT id::TEXT, COALESCE(parent_id::TEXT, '') FROM custom_permission`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tree := make(customPermissionTree)
+	for rows.Next() {
+		var id, parentId string
+		if err := rows.Scan(&id, &parentId); err != nil {
+			return nil, err
+		}
+		tree[id] = parentId
+	}
+
+	return tree, rows.Err()
+}
+
+// loadCustomAccess returns the access rows of roleIds by role and
+// permission, of any client type when clientTypeId is empty. A role has
+// the rows of its parents it has none of its own for.
+func loadCustomAccess(ctx context.Context, q querier, roleIds []string, clientTypeId string) (map[customAccessKey]map[string]customAccess, error) {
+	rows, err := q.Query(ctx, `
+       SELECT effective_role_id::TEXT, client_type_id::TEXT, custom_permission_id::TEXT,
+              COALESCE("read", ''), COALESCE("write", ''), COALESCE("update", ''), COALESCE("delete", '')
+       FROM effective_custom_permission_access
+       WHERE effective_role_id::TEXT = ANY($1) AND ($2 = '' OR client_type_id::TEXT = $2)
+    `, pq.Array(roleIds), clientTypeId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accesses := make(map[customAccessKey]map[string]customAccess)
+	for rows.Next() {
+		var (
+			key                      customAccessKey
+			permId                   string
+			read, write, update, del string
+		)
+		if err := rows.Scan(&key.roleId, &key.clientTypeId, &permId, &read, &write, &update, &del); err != nil {
+			return nil, err
+		}
+
+		if accesses[key] == nil {
+			accesses[key] = make(map[string]customAccess)
+		}
+		accesses[key][permId] = customAccess{"read": read, "write": write, "update": update, "delete": del}
+	}
+
+	return accesses, rows.Err()
+}
+
+// scanPermissionWithAccessRows returns the permissions of query with the
+// effective access of the role to each.
+func (r *customPermissionsRepo) scanPermissionWithAccessRows(ctx context.Context, conn *psqlpool.Pool, roleId, clientTypeId, query string, args ...interface{}) (*nb.GetCustomPermissionAccessesResponse, error) {
+	tree, err := loadCustomPermissionTree(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	accesses, err := loadCustomAccess(ctx, conn, []string{roleId}, clientTypeId)
+	if err != nil {
+		return nil, err
+	}
+	access := accesses[customAccessKey{roleId, clientTypeId}]
+
 	rows, err := conn.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
@@ -386,13 +756,9 @@ func (r *customPermissionsRepo) scanPermissionWithAccessRows(ctx context.Context
 			title           string
 			parentId        sql.NullString
 			attributesBytes []byte
-			read            string
-			write           string
-			update          string
-			del             string
 		)
 
-		err = rows.Scan(&permId, &title, &parentId, &attributesBytes, &read, &write, &update, &del)
+		err = rows.Scan(&permId, &title, &parentId, &attributesBytes)
 		if err != nil {
 			return nil, err
 		}
@@ -407,14 +773,17 @@ func (r *customPermissionsRepo) scanPermissionWithAccessRows(ctx context.Context
 			}
 		}
 
+		values, inherited := tree.effective(access, permId)
+
 		item := &nb.CustomPermissionWithAccess{
 			CustomPermissionId: permId,
 			Title:              title,
 			Attributes:         attrs,
-			Read:               read,   // теперь это string ("Yes"/"No")
-			Write:              write,  // string
-			Update:             update, // string
-			Delete:             del,    // string
+			Read:               values["read"],   // теперь это string ("Yes"/"No")
+			Write:              values["write"],  // string
+			Update:             values["update"], // string
+			Delete:             values["delete"], // string
+			Inherited:          inherited,
 		}
 
 		if parentId.Valid {
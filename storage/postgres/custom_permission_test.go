@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCustomPermissionTreeResolve(t *testing.T) {
+	tree := customPermissionTree{
+		"reports":         "",
+		"reports.sales":   "reports",
+		"reports.sales.q": "reports.sales",
+		"settings":        "",
+		"loop.a":          "loop.b",
+		"loop.b":          "loop.a",
+	}
+	access := map[string]customAccess{
+		"reports":       {"read": "Yes", "write": "No"},
+		"reports.sales": {"write": "Yes"},
+		"settings":      {"read": "No"},
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		action string
+		value  string
+		from   string
+	}{
+		{name: "own", id: "reports", action: "read", value: "Yes", from: "reports"},
+		{name: "from parent", id: "reports.sales", action: "read", value: "Yes", from: "reports"},
+		{name: "override", id: "reports.sales", action: "write", value: "Yes", from: "reports.sales"},
+		{name: "from grandparent", id: "reports.sales.q", action: "read", value: "Yes", from: "reports"},
+		{name: "nearest override", id: "reports.sales.q", action: "write", value: "Yes", from: "reports.sales"},
+		{name: "none", id: "reports.sales.q", action: "delete", value: "No"},
+		{name: "root without access", id: "settings", action: "update", value: "No"},
+		{name: "cycle", id: "loop.a", action: "read", value: "No"},
+		{name: "unknown", id: "missing", action: "read", value: "No"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, from := tree.resolve(access, tt.id, tt.action)
+			assert.Equal(t, tt.value, value)
+			assert.Equal(t, tt.from, from)
+		})
+	}
+
+	values, inherited := tree.effective(access, "reports.sales")
+	assert.Equal(t, customAccess{"read": "Yes", "write": "Yes", "update": "No", "delete": "No"}, values)
+	assert.Equal(t, []string{"read"}, inherited)
+}
+
+func TestCustomPermissionTreeIsAncestor(t *testing.T) {
+	tree := customPermissionTree{"a": "", "b": "a", "c": "b"}
+
+	assert.True(t, tree.isAncestor("a", "c"))
+	assert.True(t, tree.isAncestor("c", "c"))
+	assert.False(t, tree.isAncestor("c", "a"))
+	assert.False(t, tree.isAncestor("b", "x"))
+}
+
+func TestCustomAccessValue(t *testing.T) {
+	value, err := customAccessValue("Yes")
+	assert.NoError(t, err)
+	assert.Equal(t, "Yes", value)
+
+	value, err = customAccessValue(customAccessInherit)
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	_, err = customAccessValue("yes")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
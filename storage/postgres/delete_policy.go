@@ -0,0 +1,406 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"ucode/ucode_go_object_builder_service/config"
+	pa "ucode/ucode_go_object_builder_service/genproto/auth_service"
+	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Cascades deeper than this are most likely a misconfigured relation.
+const maxDeleteCascadeDepth = 32
+
+// referencingColumn is a column that points at rows of a deleted table:
+// the UUID column of a Many2One or Recursive relation, or one side of a
+// Many2Many relation, whose ids are kept in an array.
+type referencingColumn struct {
+	table    string
+	column   string
+	onDelete string
+	array    bool
+}
+
+func loadReferencingColumns(ctx context.Context, q querier, tableSlug string) ([]referencingColumn, error) {
+	rows, err := q.Query(ctx, `
+		WITH refs AS (
+			SELECT table_from AS table_slug, field_from AS column_name, attributes, false AS is_array
+			FROM relation WHERE deleted_at IS NULL AND type = $2 AND table_to = $1
+			UNION ALL
+			SELECT table_from, field_to, attributes, false
+			FROM relation WHERE deleted_at IS NULL AND type = $3 AND table_from = $1
+			UNION ALL
+			SELECT table_from, field_from, attributes, true
+			FROM relation WHERE deleted_at IS NULL AND type = $4 AND table_to = $1
+			UNION ALL
+			SELECT table_to, field_to, attributes, true
+			FROM relation WHERE deleted_at IS NULL AND type = $4 AND table_from = $1
+		)
+		SELECT DISTINCT r.table_slug, r.column_name, COALESCE(r.attributes->>'on_delete', ''), r.is_array
+		FROM refs r
+		JOIN information_schema.columns c
+			ON c.table_schema = current_schema() AND c.table_name = r.table_slug AND c.column_name = r.column_name`,
+		tableSlug, config.MANY2ONE, config.RECURSIVE, config.MANY2MANY,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting referencing relations")
+	}
+	defer rows.Close()
+
+	var columns []referencingColumn
+	for rows.Next() {
+		var column referencingColumn
+		if err := rows.Scan(&column.table, &column.column, &column.onDelete, &column.array); err != nil {
+			return nil, errors.Wrap(err, "error while scanning referencing relation")
+		}
+
+		column.onDelete, err = helper.OnDeletePolicy(map[string]any{"on_delete": column.onDelete})
+		if err != nil {
+			return nil, errors.Wrapf(err, "relation %s.%s", column.table, column.column)
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// deletedRows are the rows CASCADE policies deleted, by table, as they
+// were before the delete.
+type deletedRows map[string][]map[string]any
+
+type deletePolicy struct {
+	q        querier
+	soft     bool
+	deleted  map[string]rowSet
+	touched  map[string]bool
+	cascaded deletedRows
+}
+
+// applyDeletePolicies enforces the on_delete policy of every relation that
+// points at the ids of tableSlug before they are deleted, in the
+// transaction of the delete: RESTRICT fails, SET NULL clears the
+// references, CASCADE deletes the referencing rows the same way, soft or
+// hard, and NO ACTION leaves them to the foreign keys. Many2Many arrays
+// drop the deleted ids unless the policy is RESTRICT or NO ACTION.
+// It returns the other tables it changed and the rows it cascaded to,
+// which the caller records and syncs as it does its own.
+func applyDeletePolicies(ctx context.Context, q querier, tableSlug string, ids []string, soft bool) ([]string, deletedRows, error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+
+	p := &deletePolicy{
+		q:        q,
+		soft:     soft,
+		deleted:  map[string]rowSet{tableSlug: {}},
+		touched:  make(map[string]bool),
+		cascaded: make(deletedRows),
+	}
+	p.deleted[tableSlug].add(ids...)
+
+	if err := p.apply(ctx, tableSlug, ids, 0); err != nil {
+		return nil, nil, err
+	}
+
+	delete(p.touched, tableSlug)
+
+	tables := make([]string, 0, len(p.touched))
+	for table := range p.touched {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	return tables, p.cascaded, nil
+}
+
+func (p *deletePolicy) apply(ctx context.Context, tableSlug string, ids []string, depth int) error {
+	if depth > maxDeleteCascadeDepth {
+		return status.Errorf(codes.FailedPrecondition, "cannot delete: cascade from %s is deeper than %d relations", tableSlug, maxDeleteCascadeDepth)
+	}
+
+	columns, err := loadReferencingColumns(ctx, p.q, tableSlug)
+	if err != nil {
+		return err
+	}
+
+	for _, column := range columns {
+		if column.array {
+			err = p.applyArray(ctx, column, ids)
+		} else {
+			err = p.applyColumn(ctx, column, ids, depth)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.deleteDynamicLinkTargets(ctx, tableSlug, ids, depth)
+}
+
+// restrict fails when a row of table that is not being deleted itself
+// matches condition. Soft deletes only look at rows that are not deleted.
+func (p *deletePolicy) restrict(ctx context.Context, table, field, condition string, args ...any) error {
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s AND NOT (guid::TEXT = ANY($%d))`,
+		pq.QuoteIdentifier(table), condition, len(args)+1)
+	if p.soft {
+		query += ` AND deleted_at IS NULL`
+	}
+
+	var count int
+	if err := p.q.QueryRow(ctx, query, append(args, pq.Array(p.deleted[table].list()))...).Scan(&count); err != nil {
+		return errors.Wrap(err, "error while checking references")
+	}
+	if count > 0 {
+		return status.Errorf(codes.FailedPrecondition, "cannot delete: %d %s records reference it through %s", count, table, field)
+	}
+
+	return nil
+}
+
+func (p *deletePolicy) applyColumn(ctx context.Context, column referencingColumn, ids []string, depth int) error {
+	var (
+		table     = pq.QuoteIdentifier(column.table)
+		field     = pq.QuoteIdentifier(column.column)
+		condition = fmt.Sprintf(`%s::TEXT = ANY($1)`, field)
+	)
+
+	switch column.onDelete {
+	case config.ON_DELETE_RESTRICT:
+		return p.restrict(ctx, column.table, column.column, condition, pq.Array(ids))
+	case config.ON_DELETE_NO_ACTION:
+		if p.soft {
+			return nil
+		}
+		return p.restrict(ctx, column.table, column.column, condition, pq.Array(ids))
+	case config.ON_DELETE_CASCADE:
+		return p.cascade(ctx, column.table, condition, depth, pq.Array(ids))
+	}
+
+	return p.clear(ctx, column.table, fmt.Sprintf(`UPDATE %s SET %s = NULL WHERE %s`, table, field, condition), pq.Array(ids))
+}
+
+func (p *deletePolicy) applyArray(ctx context.Context, column referencingColumn, ids []string) error {
+	var (
+		table = pq.QuoteIdentifier(column.table)
+		field = pq.QuoteIdentifier(column.column)
+	)
+
+	switch column.onDelete {
+	case config.ON_DELETE_NO_ACTION:
+		return nil
+	case config.ON_DELETE_RESTRICT:
+		return p.restrict(ctx, column.table, column.column, fmt.Sprintf(`%s::TEXT[] && $1`, field), pq.Array(ids))
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s SET %s = ARRAY(SELECT x FROM unnest(%s) x WHERE x::TEXT <> ALL($1))
+		WHERE %s::TEXT[] && $1`, table, field, field, field)
+
+	return p.clear(ctx, column.table, query, pq.Array(ids))
+}
+
+// deleteDynamicLinkTargets enforces the Many2Dynamic relations that can
+// link to the deleted rows of tableSlug: with on_delete RESTRICT a linked
+// row blocks the delete, with CASCADE it is deleted too, with NO ACTION it
+// is left alone and otherwise the links are cleared.
+func (p *deletePolicy) deleteDynamicLinkTargets(ctx context.Context, tableSlug string, ids []string, depth int) error {
+	relations, err := loadDynamicRelations(ctx, p.q, tableSlug, true)
+	if err != nil {
+		return err
+	}
+
+	for _, relation := range relations {
+		var (
+			field     = pq.QuoteIdentifier(relation.field)
+			condition = fmt.Sprintf(`%s->>'table_slug' = $1 AND %s->>'guid' = ANY($2)`, field, field)
+		)
+
+		switch relation.onDelete {
+		case config.ON_DELETE_NO_ACTION:
+			continue
+		case onDeleteRestrict:
+			err = p.restrict(ctx, relation.tableFrom, relation.field, condition, tableSlug, pq.Array(ids))
+		case config.ON_DELETE_CASCADE:
+			err = p.cascade(ctx, relation.tableFrom, condition, depth, tableSlug, pq.Array(ids))
+		default:
+			err = p.clear(ctx, relation.tableFrom, fmt.Sprintf(`UPDATE %s SET %s = NULL WHERE %s`, pq.QuoteIdentifier(relation.tableFrom), field, condition), tableSlug, pq.Array(ids))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clear runs the statement that drops the references to deleted rows from
+// table.
+func (p *deletePolicy) clear(ctx context.Context, table, query string, args ...any) error {
+	tag, err := p.q.Exec(ctx, query, args...)
+	if err != nil {
+		return errors.Wrapf(err, "error while clearing references from %s", table)
+	}
+	if tag.RowsAffected() > 0 {
+		p.touched[table] = true
+	}
+
+	return nil
+}
+
+// cascade deletes the rows of table that match condition, after applying
+// the policies of the relations that point at them.
+func (p *deletePolicy) cascade(ctx context.Context, table, condition string, depth int, args ...any) error {
+	query := fmt.Sprintf(`SELECT guid::TEXT FROM %s WHERE %s`, pq.QuoteIdentifier(table), condition)
+	if p.soft {
+		query += ` AND deleted_at IS NULL`
+	}
+
+	rows, err := p.q.Query(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "error while getting cascaded rows")
+	}
+
+	if p.deleted[table] == nil {
+		p.deleted[table] = rowSet{}
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "error while scanning cascaded row")
+		}
+		if _, ok := p.deleted[table][id]; !ok {
+			p.deleted[table].add(id)
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "error while getting cascaded rows")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := p.apply(ctx, table, ids, depth+1); err != nil {
+		return err
+	}
+
+	// The deleted rows are returned as they were, before deleted_at is set.
+	if p.soft {
+		query = fmt.Sprintf(`
+			UPDATE %s t SET deleted_at = CURRENT_TIMESTAMP FROM %s o
+			WHERE t.guid = o.guid AND t.guid::TEXT = ANY($1)
+			RETURNING to_jsonb(o)`, pq.QuoteIdentifier(table), pq.QuoteIdentifier(table))
+	} else {
+		query = fmt.Sprintf(`DELETE FROM %s t WHERE t.guid::TEXT = ANY($1) RETURNING to_jsonb(t)`, pq.QuoteIdentifier(table))
+	}
+
+	rows, err = p.q.Query(ctx, query, pq.Array(ids))
+	if err != nil {
+		return errors.Wrapf(err, "error while deleting cascaded %s rows", table)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row map[string]any
+		if err := rows.Scan(&row); err != nil {
+			return errors.Wrapf(err, "error while scanning cascaded %s row", table)
+		}
+		p.cascaded[table] = append(p.cascaded[table], row)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrapf(err, "error while deleting cascaded %s rows", table)
+	}
+
+	p.touched[table] = true
+
+	return nil
+}
+
+// syncCascadedDeletes does for the rows CASCADE policies deleted what a
+// delete of them does: it records them in the version history and, for
+// login tables, removes their people and their users from the auth service.
+func (i *itemsRepo) syncCascadedDeletes(ctx context.Context, tx pgx.Tx, data map[string]any, cascaded deletedRows) error {
+	tables := make([]string, 0, len(cascaded))
+	for table := range cascaded {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		rows := cascaded[table]
+		for _, row := range rows {
+			if err := recordItemHistory(ctx, tx, table, "DELETE", row, nil); err != nil {
+				return err
+			}
+		}
+
+		var (
+			isLoginTable bool
+			attr         []byte
+			attributes   models.TableAttributes
+		)
+		err := tx.QueryRow(ctx, `SELECT is_login_table, attributes FROM "table" WHERE slug = $1`, table).Scan(&isLoginTable, &attr)
+		if err != nil {
+			return errors.Wrap(err, "error while getting cascaded table")
+		}
+		if !isLoginTable {
+			continue
+		}
+
+		if err := json.Unmarshal(attr, &attributes); err != nil {
+			return errors.Wrap(err, "error while unmarshalling attributes")
+		}
+
+		var (
+			authInfo = attributes.AuthInfo
+			ids      = make([]string, 0, len(rows))
+			users    = make([]*pa.DeleteManyUserRequest_User, 0, len(rows))
+		)
+		for _, row := range rows {
+			ids = append(ids, cast.ToString(row["guid"]))
+			if userId := cast.ToString(row["user_id_auth"]); userId != "" {
+				users = append(users, &pa.DeleteManyUserRequest_User{
+					UserId:       userId,
+					RoleId:       cast.ToString(row[authInfo.RoleID]),
+					ClientTypeId: cast.ToString(row[authInfo.ClientTypeID]),
+				})
+			}
+		}
+
+		if err := i.DeleteManyPersonTable(ctx, &models.PersonRequest{Tx: tx, Ids: ids}); err != nil {
+			return errors.Wrap(err, "error while deleting many person")
+		}
+
+		if len(users) == 0 {
+			continue
+		}
+
+		_, err = i.grpcClient.SyncUserService().DeleteManyUser(ctx, &pa.DeleteManyUserRequest{
+			Users:         users,
+			ProjectId:     cast.ToString(data["company_service_project_id"]),
+			EnvironmentId: cast.ToString(data["company_service_environment_id"]),
+		})
+		if err != nil {
+			return errors.Wrap(err, "error while deleting users from auth service")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestApplyDeletePolicies(t *testing.T) {
+	var (
+		column = func(onDelete string, array bool) fakeResult {
+			return fakeResult{match: "WITH refs AS", rows: [][]any{{"child", "parent_id", onDelete, array}}}
+		}
+		dynamic = func(onDelete string) fakeResult {
+			return fakeResult{match: "r.dynamic_tables @>", rows: [][]any{{"comment", "target", []byte(`[{"table_slug": "post"}]`), onDelete}}}
+		}
+		children = fakeResult{match: `SELECT guid::TEXT FROM "child"`, rows: [][]any{{"c1"}}}
+		deleted  = fakeResult{match: "RETURNING to_jsonb", rows: [][]any{{map[string]any{"guid": "c1"}}}}
+	)
+
+	tests := []struct {
+		name     string
+		soft     bool
+		results  []fakeResult
+		rows     []fakeRow
+		code     codes.Code
+		tables   []string
+		cascaded deletedRows
+		ran      string
+	}{
+		{
+			name:    "restrict with references",
+			results: []fakeResult{column("RESTRICT", false)},
+			rows:    []fakeRow{{match: `SELECT COUNT(*) FROM "child"`, values: []any{2}}},
+			code:    codes.FailedPrecondition,
+		},
+		{
+			name:    "restrict without references",
+			results: []fakeResult{column("restrict", false)},
+			rows:    []fakeRow{{match: `SELECT COUNT(*) FROM "child"`, values: []any{0}}},
+			tables:  []string{},
+		},
+		{
+			name:    "set null by default",
+			results: []fakeResult{column("", false)},
+			tables:  []string{"child"},
+			ran:     `UPDATE "child" SET "parent_id" = NULL`,
+		},
+		{
+			name:    "no action on soft delete",
+			soft:    true,
+			results: []fakeResult{column("NO ACTION", false)},
+			tables:  []string{},
+		},
+		{
+			name:     "cascade hard delete",
+			results:  []fakeResult{column("CASCADE", false), children, deleted},
+			tables:   []string{"child"},
+			cascaded: deletedRows{"child": {{"guid": "c1"}}},
+			ran:      `DELETE FROM "child" t`,
+		},
+		{
+			name:     "cascade soft delete",
+			soft:     true,
+			results:  []fakeResult{column("cascade", false), children, deleted},
+			tables:   []string{"child"},
+			cascaded: deletedRows{"child": {{"guid": "c1"}}},
+			ran:      `UPDATE "child" t SET deleted_at`,
+		},
+		{
+			name:    "many2many drops ids",
+			results: []fakeResult{column("SET_NULL", true)},
+			tables:  []string{"child"},
+			ran:     "unnest",
+		},
+		{
+			name:    "dynamic restrict",
+			results: []fakeResult{dynamic("restrict")},
+			rows:    []fakeRow{{match: `SELECT COUNT(*) FROM "comment"`, values: []any{1}}},
+			code:    codes.FailedPrecondition,
+		},
+		{
+			name:    "dynamic links cleared",
+			results: []fakeResult{dynamic("")},
+			tables:  []string{"comment"},
+			ran:     `UPDATE "comment" SET "target" = NULL`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{rows: tt.rows, results: tt.results}
+
+			tables, cascaded, err := applyDeletePolicies(context.Background(), q, "post", []string{"p1"}, tt.soft)
+			assert.Equal(t, tt.code, status.Code(err))
+			assert.Equal(t, tt.tables, tables)
+			if tt.cascaded == nil && tt.code == codes.OK {
+				tt.cascaded = deletedRows{}
+			}
+			assert.Equal(t, tt.cascaded, cascaded)
+			if tt.ran != "" {
+				assert.True(t, q.ran(tt.ran), tt.ran)
+			}
+		})
+	}
+}
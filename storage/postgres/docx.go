@@ -26,6 +26,15 @@ func (o *objectBuilderRepo) GetListForDocxMultiTables(ctx context.Context, req *
 		return nil, err
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetExport)
+	defer cancel()
+
+	defer func() {
+		if helper.IsQueryTimeout(err) {
+			err = helper.HandleDatabaseError(err, o.logger, "GetListForDocxMultiTables")
+		}
+	}()
+
 	params, _ := helper.ConvertStructToMap(req.Data)
 
 	query := "WITH combined_data AS ("
@@ -174,7 +183,10 @@ func (o *objectBuilderRepo) GetListForDocxMultiTables(ctx context.Context, req *
 	}
 	defer rows.Close()
 
-	result := make(map[string]any)
+	var (
+		result   = make(map[string]any)
+		accesses = make(map[string]*helper.FieldAccess)
+	)
 	for rows.Next() {
 		values, err := rows.Values()
 		if err != nil {
@@ -182,7 +194,18 @@ func (o *objectBuilderRepo) GetListForDocxMultiTables(ctx context.Context, req *
 		}
 
 		for _, value := range values {
-			res, _ := helper.ConvertMapToStruct(value.(map[string]any))
+			item := value.(map[string]any)
+			tableSlug := cast.ToString(item["table_slug"])
+			access, ok := accesses[tableSlug]
+			if !ok {
+				if access, err = helper.LoadFieldAccess(ctx, conn, tableSlug, params); err != nil {
+					return &nb.CommonMessage{}, err
+				}
+				accesses[tableSlug] = access
+			}
+			access.Apply(item)
+
+			res, _ := helper.ConvertMapToStruct(item)
 			for j, val := range value.(map[string]any) {
 				if j == "table_slug" {
 					if arr, ok := result[val.(string)]; ok {
@@ -213,6 +236,15 @@ func (o *objectBuilderRepo) GetListForDocx(ctx context.Context, req *nb.CommonMe
 		return nil, err
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetExport)
+	defer cancel()
+
+	defer func() {
+		if helper.IsQueryTimeout(err) {
+			err = helper.HandleDatabaseError(err, o.logger, "GetListForDocx")
+		}
+	}()
+
 	params, _ := helper.ConvertStructToMap(req.Data)
 
 	fquery := `SELECT f.slug, f.type, t.order_by, f.is_search FROM field f JOIN "table" t ON t.id = f.table_id WHERE t.slug = $1`
@@ -411,6 +443,11 @@ func (o *objectBuilderRepo) GetListForDocx(ctx context.Context, req *nb.CommonMe
 	}
 	defer rows.Close()
 
+	access, err := helper.LoadFieldAccess(ctx, conn, req.TableSlug, params)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	result := []any{}
 
 	for rows.Next() {
@@ -429,6 +466,10 @@ func (o *objectBuilderRepo) GetListForDocx(ctx context.Context, req *nb.CommonMe
 			data = temp["data"]
 		}
 
+		if item, ok := data.(map[string]any); ok {
+			access.Apply(item)
+		}
+
 		result = append(result, data)
 	}
 
@@ -456,6 +497,15 @@ func (o *objectBuilderRepo) GetAllForDocx(ctx context.Context, req *nb.CommonMes
 		return nil, err
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetExport)
+	defer cancel()
+
+	defer func() {
+		if helper.IsQueryTimeout(err) {
+			err = helper.HandleDatabaseError(err, o.logger, "GetAllForDocx")
+		}
+	}()
+
 	paramBody, err := json.Marshal(req.Data)
 	if err != nil {
 		return nil, err
@@ -471,10 +521,18 @@ func (o *objectBuilderRepo) GetAllForDocx(ctx context.Context, req *nb.CommonMes
 	response := map[string]any{}
 
 	for key, value := range additionalFields {
-		additionalItem, err := helper.GetItem(ctx, conn, strings.TrimSuffix(key, "_id"), cast.ToString(value), false)
+		tableSlug := strings.TrimSuffix(key, "_id")
+		additionalItem, err := helper.GetItem(ctx, conn, tableSlug, cast.ToString(value), false)
 		if err != nil {
 			return nil, errors.Wrap(err, "error while getting additional item")
 		}
+
+		access, err := helper.LoadFieldAccess(ctx, conn, tableSlug, params)
+		if err != nil {
+			return nil, err
+		}
+		access.Apply(additionalItem)
+
 		response[key+"_data"] = additionalItem
 	}
 
@@ -0,0 +1,286 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"ucode/ucode_go_object_builder_service/config"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A Many2Dynamic relation links a DYNAMIC_LOOKUP field of table_from to a
+// row of any of its dynamic tables. The field stores
+// {"table_slug": ..., "guid": ...}.
+
+const onDeleteRestrict = config.ON_DELETE_RESTRICT
+
+type dynamicTable struct {
+	TableSlug string `json:"table_slug"`
+	// ViewFields are the ids or slugs of the fields shown for a linked row.
+	ViewFields []string `json:"view_fields"`
+
+	// displayFields are the resolved slugs of ViewFields.
+	displayFields []string
+}
+
+type dynamicRelation struct {
+	tableFrom string
+	field     string
+	tables    []*dynamicTable
+	onDelete  string
+}
+
+func (r dynamicRelation) table(slug string) (*dynamicTable, bool) {
+	for _, t := range r.tables {
+		if t.TableSlug == slug {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+type dynamicLink struct {
+	TableSlug string `json:"table_slug"`
+	Guid      string `json:"guid"`
+}
+
+// parseDynamicLink reads a DYNAMIC_LOOKUP value as it comes from a request
+// or from the database. ok is false for an empty value.
+func parseDynamicLink(value any) (link dynamicLink, ok bool, err error) {
+	switch v := value.(type) {
+	case nil:
+		return link, false, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return link, false, nil
+		}
+		err = json.Unmarshal([]byte(v), &link)
+	case []byte:
+		err = json.Unmarshal(v, &link)
+	case map[string]any:
+		link.TableSlug = cast.ToString(v["table_slug"])
+		link.Guid = cast.ToString(v["guid"])
+	default:
+		return link, false, fmt.Errorf("unexpected value %v", value)
+	}
+	if err != nil {
+		return link, false, err
+	}
+
+	return link, link.TableSlug != "" || link.Guid != "", nil
+}
+
+// loadDynamicRelations returns the Many2Dynamic relations that have a
+// DYNAMIC_LOOKUP field, either of table_from tableSlug or, with targets,
+// those that can link to rows of tableSlug.
+func loadDynamicRelations(ctx context.Context, q querier, tableSlug string, targets bool) ([]dynamicRelation, error) {
+	condition := `r.table_from = $1`
+	if targets {
+		condition = `r.dynamic_tables @> jsonb_build_array(jsonb_build_object('table_slug', $1::TEXT))`
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			r.table_from,
+			r.field_from,
+			CASE WHEN jsonb_typeof(r.dynamic_tables) = 'array' THEN r.dynamic_tables ELSE '[]' END,
+			COALESCE(r.attributes->>'on_delete', '')
+		FROM relation r
+		JOIN "table" t ON t.slug = r.table_from
+		JOIN field f ON f.table_id = t.id AND f.slug = r.field_from
+		WHERE r.deleted_at IS NULL AND r.type = $2 AND f.type = $3 AND %s`, condition)
+
+	rows, err := q.Query(ctx, query, tableSlug, config.MANY2DYNAMIC, config.DYNAMIC_LOOKUP)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting dynamic relations")
+	}
+	defer rows.Close()
+
+	var relations []dynamicRelation
+	for rows.Next() {
+		var (
+			relation dynamicRelation
+			tables   []byte
+		)
+
+		if err := rows.Scan(&relation.tableFrom, &relation.field, &tables, &relation.onDelete); err != nil {
+			return nil, errors.Wrap(err, "error while scanning dynamic relation")
+		}
+
+		if err := json.Unmarshal(tables, &relation.tables); err != nil {
+			return nil, errors.Wrapf(err, "invalid dynamic_tables of relation %s.%s", relation.tableFrom, relation.field)
+		}
+
+		relation.onDelete, err = helper.OnDeletePolicy(map[string]any{"on_delete": relation.onDelete})
+		if err != nil {
+			return nil, errors.Wrapf(err, "relation %s.%s", relation.tableFrom, relation.field)
+		}
+		relations = append(relations, relation)
+	}
+
+	return relations, rows.Err()
+}
+
+// resolveDisplayFields maps the view fields of the dynamic tables to the
+// slugs of existing fields, so only real columns reach the queries.
+func resolveDisplayFields(ctx context.Context, q querier, relations []dynamicRelation) error {
+	var slugs []string
+	for _, relation := range relations {
+		for _, t := range relation.tables {
+			slugs = append(slugs, t.TableSlug)
+		}
+	}
+	if len(slugs) == 0 {
+		return nil
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT t.slug, f.id::TEXT, f.slug
+		FROM field f
+		JOIN "table" t ON t.id = f.table_id
+		WHERE t.slug = ANY($1)`, pq.Array(slugs))
+	if err != nil {
+		return errors.Wrap(err, "error while getting display fields")
+	}
+	defer rows.Close()
+
+	fields := make(map[string]map[string]string)
+	for rows.Next() {
+		var table, id, slug string
+		if err := rows.Scan(&table, &id, &slug); err != nil {
+			return errors.Wrap(err, "error while scanning display field")
+		}
+		if fields[table] == nil {
+			fields[table] = make(map[string]string)
+		}
+		fields[table][id] = slug
+		fields[table][slug] = slug
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, relation := range relations {
+		for _, t := range relation.tables {
+			t.displayFields = t.displayFields[:0]
+			for _, viewField := range t.ViewFields {
+				if slug, ok := fields[t.TableSlug][viewField]; ok && slug != "guid" {
+					t.displayFields = append(t.displayFields, slug)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// dynamicDisplayObject selects the guid and display fields of a row of t
+// aliased as alias.
+func dynamicDisplayObject(t *dynamicTable, alias string) string {
+	parts := []string{fmt.Sprintf(`'guid', %s.guid`, alias)}
+	for _, slug := range t.displayFields {
+		parts = append(parts, fmt.Sprintf(`%s, %s.%s`, pq.QuoteLiteral(slug), alias, pq.QuoteIdentifier(slug)))
+	}
+	return "jsonb_build_object(" + strings.Join(parts, ", ") + ")"
+}
+
+// validateDynamicLinks checks that every DYNAMIC_LOOKUP value in rows
+// points at an existing row of one of the relation's dynamic tables, and
+// rewrites the values to their stored form.
+func validateDynamicLinks(ctx context.Context, q querier, tableSlug string, rows ...map[string]any) error {
+	relations, err := loadDynamicRelations(ctx, q, tableSlug, false)
+	if err != nil || len(relations) == 0 {
+		return err
+	}
+
+	for _, data := range rows {
+		if err := validateDynamicRow(ctx, q, relations, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateDynamicRow(ctx context.Context, q querier, relations []dynamicRelation, data map[string]any) error {
+	for _, relation := range relations {
+		value, ok := data[relation.field]
+		if !ok {
+			continue
+		}
+
+		link, ok, err := parseDynamicLink(value)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "%s: invalid dynamic link: %v", relation.field, err)
+		}
+		if !ok {
+			data[relation.field] = nil
+			continue
+		}
+
+		if _, ok := relation.table(link.TableSlug); !ok {
+			return status.Errorf(codes.InvalidArgument, "%s: cannot link to table %q", relation.field, link.TableSlug)
+		}
+		if _, err := uuid.Parse(link.Guid); err != nil {
+			return status.Errorf(codes.InvalidArgument, "%s: invalid guid %q", relation.field, link.Guid)
+		}
+
+		var exists bool
+		query := fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s WHERE guid = $1 AND deleted_at IS NULL)`, pq.QuoteIdentifier(link.TableSlug))
+		if err := q.QueryRow(ctx, query, link.Guid).Scan(&exists); err != nil {
+			return errors.Wrap(err, "error while checking dynamic link")
+		}
+		if !exists {
+			return status.Errorf(codes.InvalidArgument, "%s: %s row %s does not exist", relation.field, link.TableSlug, link.Guid)
+		}
+
+		data[relation.field] = map[string]any{"table_slug": link.TableSlug, "guid": link.Guid}
+	}
+
+	return nil
+}
+
+// expandDynamicLinks adds <field>_data with the display fields of the
+// linked row for every DYNAMIC_LOOKUP field of a single record.
+func expandDynamicLinks(ctx context.Context, q querier, tableSlug string, record map[string]any) error {
+	relations, err := loadDynamicRelations(ctx, q, tableSlug, false)
+	if err != nil || len(relations) == 0 {
+		return err
+	}
+
+	if err := resolveDisplayFields(ctx, q, relations); err != nil {
+		return err
+	}
+
+	for _, relation := range relations {
+		link, ok, err := parseDynamicLink(record[relation.field])
+		if err != nil || !ok {
+			continue
+		}
+
+		t, ok := relation.table(link.TableSlug)
+		if !ok {
+			continue
+		}
+
+		var linked map[string]any
+		query := fmt.Sprintf(`SELECT %s FROM %s d WHERE d.guid = $1`, dynamicDisplayObject(t, "d"), pq.QuoteIdentifier(t.TableSlug))
+		if err := q.QueryRow(ctx, query, link.Guid).Scan(&linked); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return errors.Wrap(err, "error while getting linked row")
+		}
+
+		record[relation.field+"_data"] = linked
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const linkedGuid = "0b9f0c4e-7a52-4c3f-9d0e-3f0f3b8d6a11"
+
+func TestParseDynamicLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		link  dynamicLink
+		ok    bool
+		err   bool
+	}{
+		{name: "nil", value: nil},
+		{name: "blank", value: "  "},
+		{name: "json", value: `{"table_slug": "article", "guid": "g"}`, link: dynamicLink{TableSlug: "article", Guid: "g"}, ok: true},
+		{name: "bytes", value: []byte(`{"table_slug": "article", "guid": "g"}`), link: dynamicLink{TableSlug: "article", Guid: "g"}, ok: true},
+		{name: "map", value: map[string]any{"table_slug": "article", "guid": "g"}, link: dynamicLink{TableSlug: "article", Guid: "g"}, ok: true},
+		{name: "empty object", value: map[string]any{}},
+		{name: "invalid json", value: `{"table_slug":`, err: true},
+		{name: "number", value: 42, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, ok, err := parseDynamicLink(tt.value)
+			assert.Equal(t, tt.err, err != nil)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.link, link)
+		})
+	}
+}
+
+func TestValidateDynamicLinks(t *testing.T) {
+	relation := fakeResult{
+		match: "FROM relation r",
+		rows:  [][]any{{"post", "target", []byte(`[{"table_slug": "article"}]`), ""}},
+	}
+
+	tests := []struct {
+		name   string
+		data   map[string]any
+		exists bool
+		code   codes.Code
+		want   map[string]any
+	}{
+		{name: "no link", data: map[string]any{"title": "a"}, want: map[string]any{"title": "a"}},
+		{name: "cleared", data: map[string]any{"target": ""}, want: map[string]any{"target": nil}},
+		{
+			name:   "linked",
+			data:   map[string]any{"target": `{"table_slug": "article", "guid": "` + linkedGuid + `"}`},
+			exists: true,
+			want:   map[string]any{"target": map[string]any{"table_slug": "article", "guid": linkedGuid}},
+		},
+		{name: "other table", data: map[string]any{"target": map[string]any{"table_slug": "user", "guid": linkedGuid}}, code: codes.InvalidArgument},
+		{name: "invalid guid", data: map[string]any{"target": map[string]any{"table_slug": "article", "guid": "x"}}, code: codes.InvalidArgument},
+		{name: "missing row", data: map[string]any{"target": map[string]any{"table_slug": "article", "guid": linkedGuid}}, code: codes.InvalidArgument},
+		{name: "invalid value", data: map[string]any{"target": 1}, code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{
+				results: []fakeResult{relation},
+				rows:    []fakeRow{{match: "SELECT EXISTS", values: []any{tt.exists}}},
+			}
+
+			err := validateDynamicLinks(context.Background(), q, "post", tt.data)
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.code == codes.OK {
+				assert.Equal(t, tt.want, tt.data)
+			}
+		})
+	}
+}
+
+func TestLoadDynamicRelations(t *testing.T) {
+	q := &fakeQuerier{results: []fakeResult{{
+		match: "FROM relation r",
+		rows:  [][]any{{"post", "target", []byte(`[{"table_slug": "article", "view_fields": ["title"]}]`), "set_null"}},
+	}}}
+
+	relations, err := loadDynamicRelations(context.Background(), q, "post", false)
+	require.NoError(t, err)
+	require.Len(t, relations, 1)
+	assert.Equal(t, "SET NULL", relations[0].onDelete)
+	assert.Equal(t, []string{"title"}, relations[0].tables[0].ViewFields)
+	assert.True(t, q.ran("r.deleted_at IS NULL"))
+
+	q.results[0].rows[0][2] = []byte(`[{"table_slug": 1}]`)
+	_, err = loadDynamicRelations(context.Background(), q, "post", false)
+	assert.Error(t, err)
+
+	q.results[0].rows[0][2] = []byte(`[]`)
+	q.results[0].rows[0][3] = "sometimes"
+	_, err = loadDynamicRelations(context.Background(), q, "post", false)
+	assert.Error(t, err)
+}
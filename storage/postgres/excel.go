@@ -12,6 +12,7 @@ import (
 	"ucode/ucode_go_object_builder_service/config"
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
 	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
 	"ucode/ucode_go_object_builder_service/pkg/helper"
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
 	"ucode/ucode_go_object_builder_service/storage"
@@ -135,6 +136,16 @@ func (e *excelRepo) ExcelToDb(ctx context.Context, req *nb.ExcelToDbRequest) (re
 		return &nb.ExcelToDbResponse{}, errors.Wrap(err, "excelize.OpenFile")
 	}
 
+	data, err := helper.ConvertStructToMap(req.Data)
+	if err != nil {
+		return &nb.ExcelToDbResponse{}, errors.Wrap(err, "ConvertStructToMap")
+	}
+
+	permissions, err := authorizeWrite(ctx, tx, req.TableSlug, authz.Create, data)
+	if err != nil {
+		return &nb.ExcelToDbResponse{}, err
+	}
+
 	query := `SELECT f.id, f.slug, f.type FROM "field" f JOIN "table" t ON f.table_id = t.id WHERE t.slug = $1`
 
 	fieldRows, err := tx.Query(ctx, query, req.TableSlug)
@@ -143,11 +154,6 @@ func (e *excelRepo) ExcelToDb(ctx context.Context, req *nb.ExcelToDbRequest) (re
 	}
 	defer fieldRows.Close()
 
-	data, err := helper.ConvertStructToMap(req.Data)
-	if err != nil {
-		return &nb.ExcelToDbResponse{}, errors.Wrap(err, "ConvertStructToMap")
-	}
-
 	fields := []models.Field{}
 
 	for fieldRows.Next() {
@@ -239,6 +245,7 @@ func (e *excelRepo) ExcelToDb(ctx context.Context, req *nb.ExcelToDbRequest) (re
 				body[slugsMap[convertToTitle(i)]] = value
 			}
 		}
+		permissions.Strip(body)
 		fullData = append(fullData, body)
 	}
 
@@ -275,6 +282,37 @@ func (e *excelRepo) ExcelToDb(ctx context.Context, req *nb.ExcelToDbRequest) (re
 		insertedRows = append(insertedRows, rowMap)
 	}
 
+	cipher, err := helper.LoadFieldCipher(ctx, tx, req.TableSlug)
+	if err != nil {
+		return &nb.ExcelToDbResponse{}, err
+	}
+
+	guids := make([]string, 0, len(insertedRows))
+	for _, row := range insertedRows {
+		guid := cast.ToString(row["guid"])
+		if err := cipher.IndexRow(ctx, tx, guid, row); err != nil {
+			return &nb.ExcelToDbResponse{}, err
+		}
+		guids = append(guids, guid)
+	}
+
+	if err := checkRowAccess(ctx, tx, req.TableSlug, authz.Resolve(ctx, data), authz.Create, guids...); err != nil {
+		return &nb.ExcelToDbResponse{}, err
+	}
+
+	err = enqueueFormulaChange(ctx, tx, recordChange{table: req.TableSlug, ids: guids})
+	if err != nil {
+		return &nb.ExcelToDbResponse{}, errors.Wrap(err, "enqueueFormulaChange")
+	}
+
+	access, err := helper.LoadFieldAccess(ctx, conn, req.TableSlug, data)
+	if err != nil {
+		return &nb.ExcelToDbResponse{}, err
+	}
+	for _, row := range insertedRows {
+		access.Apply(row)
+	}
+
 	newResp, err := helper.Convert[[]map[string]any, []*structpb.Struct](insertedRows)
 	if err != nil {
 		return &nb.ExcelToDbResponse{}, errors.Wrap(err, "error while converting map to struct")
@@ -285,6 +323,8 @@ func (e *excelRepo) ExcelToDb(ctx context.Context, req *nb.ExcelToDbRequest) (re
 		return &nb.ExcelToDbResponse{}, errors.Wrap(err, "tx.Commit")
 	}
 
+	invalidateCache(ctx, req.GetProjectId(), req.GetTableSlug())
+
 	return &nb.ExcelToDbResponse{Rows: newResp}, nil
 }
 
@@ -298,6 +338,11 @@ func MakeQueryForMultiInsert(ctx context.Context, tx pgx.Tx, tableSlug string, d
 		query      = fmt.Sprintf(`INSERT INTO %s (`, tableSlug)
 	)
 
+	cipher, err := helper.LoadFieldCipher(ctx, tx, tableSlug)
+	if err != nil {
+		return "", nil, err
+	}
+
 	for index, field := range fields {
 		if field.Slug == "guid" || field.Type == "INCREMENT_NUMBER" {
 			continue
@@ -398,8 +443,13 @@ func MakeQueryForMultiInsert(ctx context.Context, tx pgx.Tx, tableSlug string, d
 				continue
 			}
 
+			value, err := cipher.Encrypt(field.Slug, body[field.Slug])
+			if err != nil {
+				return "", nil, err
+			}
+
 			query += fmt.Sprintf(" $%d,", argCount)
-			args = append(args, body[field.Slug])
+			args = append(args, value)
 			argCount++
 		}
 
@@ -0,0 +1,614 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The access explanation follows the checks the reads and writes of this
+// service make, in the order they make them, so each decision names the
+// permission that actually decides it rather than the raw rows.
+
+// recordFlags are the columns of record_permission the UI reads to show
+// the buttons of a table. They default to Yes.
+var recordFlags = []string{"automation", "language_btn", "settings", "share_modal", "view_create", "add_field", "pdf_action"}
+
+var recordColumns = map[authz.Action]string{
+	authz.Read:   "read",
+	authz.Create: "write",
+	authz.Update: "update",
+	authz.Delete: "delete",
+}
+
+type accessExplainer struct {
+	q         querier
+	subject   authz.Subject
+	tableSlug string
+	recordId  string
+	admin     bool
+	// readRule and legacyFilter decide whether the automatic filters of
+	// the role still apply to reads.
+	readRule     bool
+	legacyFilter bool
+	// owner is the role the permission being explained comes from, the
+	// subject's or one of its parents.
+	owner       string
+	recordOwner string
+	decisions   []*nb.AccessDecision
+}
+
+func (p *permissionRepo) ExplainAccess(ctx context.Context, req *nb.ExplainAccessRequest) (*nb.ExplainAccessResponse, error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "permission.ExplainAccess")
+	defer dbSpan.Finish()
+
+	if req.GetRoleId() == "" || req.GetTableSlug() == "" {
+		return nil, status.Error(codes.InvalidArgument, "role_id and table_slug are required")
+	}
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &nb.ExplainAccessResponse{
+		RoleId:       req.RoleId,
+		ClientTypeId: req.ClientTypeId,
+		UserId:       req.UserId,
+		TableSlug:    req.TableSlug,
+		RecordId:     req.RecordId,
+	}
+
+	var roleClientTypeId string
+	err = conn.QueryRow(ctx, `SELECT COALESCE(client_type_id::TEXT, '') FROM role WHERE guid::TEXT = $1`, req.RoleId).Scan(&roleClientTypeId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Errorf(codes.NotFound, "role %s not found", req.RoleId)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ExplainAccess: get role")
+	}
+	if resp.ClientTypeId == "" {
+		resp.ClientTypeId = roleClientTypeId
+	}
+
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM "table" WHERE slug = $1)`, req.TableSlug).Scan(&exists); err != nil {
+		return nil, errors.Wrap(err, "ExplainAccess: get table")
+	}
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "table %s not found", req.TableSlug)
+	}
+
+	if req.RecordId != "" {
+		query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE guid::TEXT = $1)`, pq.QuoteIdentifier(req.TableSlug))
+		if err := conn.QueryRow(ctx, query, req.RecordId).Scan(&exists); err != nil {
+			return nil, errors.Wrap(err, "ExplainAccess: get record")
+		}
+		if !exists {
+			return nil, status.Errorf(codes.NotFound, "record %s of %s not found", req.RecordId, req.TableSlug)
+		}
+	}
+
+	e := &accessExplainer{
+		q: conn,
+		subject: authz.Subject{
+			RoleId:       resp.RoleId,
+			ClientTypeId: resp.ClientTypeId,
+			UserId:       resp.UserId,
+		},
+		tableSlug: req.TableSlug,
+		recordId:  req.RecordId,
+	}
+
+	if e.admin, err = isAdminClientType(ctx, conn, resp.ClientTypeId); err != nil {
+		return nil, err
+	}
+	resp.Admin = e.admin
+
+	for _, explain := range []func(context.Context) error{
+		e.record,
+		e.rows,
+		e.automaticFilters,
+		e.fields,
+		e.actions,
+		e.views,
+		e.viewRelations,
+		e.menus,
+		e.custom,
+	} {
+		e.owner = ""
+		if err := explain(ctx); err != nil {
+			return nil, errors.Wrap(err, "ExplainAccess")
+		}
+	}
+
+	resp.Decisions = e.decisions
+
+	return resp, nil
+}
+
+func (e *accessExplainer) add(scope, target, capability string, allowed bool, source, reason string, args ...any) {
+	decision := &nb.AccessDecision{
+		Scope:      scope,
+		Target:     target,
+		Capability: capability,
+		Allowed:    allowed,
+		Source:     source,
+		Reason:     fmt.Sprintf(reason, args...),
+	}
+	if e.owner != "" && e.owner != e.subject.RoleId && source != "client_type" && source != "default" {
+		decision.InheritedFrom = e.owner
+	}
+	e.decisions = append(e.decisions, decision)
+}
+
+// record explains the actions of record_permission. Reads need a Yes of
+// the role or of a public permission of the table, as GetTablePermission
+// checks them; writes are allowed unless the role has a No.
+func (e *accessExplainer) record(ctx context.Context) error {
+	var (
+		values = make(map[string]string)
+		dest   = make([]any, 0, len(recordColumns)+len(recordFlags)+2)
+		found  = true
+	)
+
+	columns := append([]string{"read", "write", "update", "delete"}, recordFlags...)
+	query := "SELECT role_id::TEXT, COALESCE(is_have_condition, false)"
+	for _, column := range columns {
+		query += fmt.Sprintf(", COALESCE(%s, '')", pq.QuoteIdentifier(column))
+	}
+	query += ` FROM effective_record_permission WHERE table_slug = $1 AND effective_role_id::TEXT = $2`
+
+	scanned := make([]string, len(columns))
+	dest = append(dest, &e.recordOwner, &e.legacyFilter)
+	for i := range scanned {
+		dest = append(dest, &scanned[i])
+	}
+
+	err := e.q.QueryRow(ctx, query, e.tableSlug, e.subject.RoleId).Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		found = false
+	} else if err != nil {
+		return errors.Wrap(err, "get record permission")
+	}
+	for i, column := range columns {
+		values[column] = scanned[i]
+	}
+	e.owner = e.recordOwner
+
+	switch {
+	case values["read"] == "Yes":
+		e.add("record", e.tableSlug, string(authz.Read), true, "record_permission.read", "the role has read Yes")
+	default:
+		var public bool
+		err := e.q.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM record_permission
+				WHERE table_slug = $1 AND read = 'Yes' AND (role_id IS NULL OR is_public = true)
+			)`, e.tableSlug).Scan(&public)
+		if err != nil {
+			return errors.Wrap(err, "get public record permission")
+		}
+
+		switch {
+		case public:
+			e.owner = ""
+			e.add("record", e.tableSlug, string(authz.Read), true, "record_permission.is_public", "a public record permission of the table has read Yes")
+			e.owner = e.recordOwner
+		case found:
+			e.add("record", e.tableSlug, string(authz.Read), false, "record_permission.read", "the role has read %q", values["read"])
+		default:
+			e.add("record", e.tableSlug, string(authz.Read), false, "record_permission", "the role has no record permission on the table and none is public")
+		}
+	}
+
+	for _, action := range []authz.Action{authz.Create, authz.Update, authz.Delete} {
+		column := recordColumns[action]
+		switch {
+		case e.admin:
+			e.add("record", e.tableSlug, string(action), true, "client_type", "the ADMIN client type is not restricted")
+		case values[column] == "No":
+			e.add("record", e.tableSlug, string(action), false, "record_permission."+column, "the role has %s No", column)
+		case !found:
+			e.add("record", e.tableSlug, string(action), true, "default", "the role has no record permission on the table, writes default to Yes")
+		default:
+			e.add("record", e.tableSlug, string(action), true, "record_permission."+column, "the role has %s %q", column, values[column])
+		}
+	}
+
+	for _, flag := range recordFlags {
+		if !found || values[flag] == "" {
+			e.add("record", e.tableSlug, flag, true, "default", "%s defaults to Yes", flag)
+			continue
+		}
+		e.add("record", e.tableSlug, flag, values[flag] != "No", "record_permission."+flag, "the role has %s %q", flag, values[flag])
+	}
+
+	return nil
+}
+
+// rows explains the row-level rules of record_permission.conditions. With
+// a record the rule is evaluated on it, otherwise the compiled condition
+// is shown.
+func (e *accessExplainer) rows(ctx context.Context) error {
+	e.owner = e.recordOwner
+	for _, action := range []authz.Action{authz.Read, authz.Create, authz.Update, authz.Delete} {
+		if e.admin {
+			e.add("row", e.tableSlug, string(action), true, "client_type", "the ADMIN client type is not restricted")
+			continue
+		}
+
+		condition, err := rowCondition(ctx, e.q, e.tableSlug, "a", e.subject, action)
+		if err != nil {
+			return err
+		}
+		if condition == "" {
+			e.add("row", e.tableSlug, string(action), true, "record_permission.conditions", "the role has no %s rule, all rows are allowed", action)
+			continue
+		}
+		if action == authz.Read {
+			e.readRule = true
+		}
+
+		if e.recordId == "" {
+			e.add("row", e.tableSlug, string(action), true, "record_permission.conditions", "only rows matching %s", condition)
+			continue
+		}
+
+		var matches bool
+		query := fmt.Sprintf(`SELECT %s FROM %s a WHERE a.guid::TEXT = $1`, condition, pq.QuoteIdentifier(e.tableSlug))
+		if err := e.q.QueryRow(ctx, query, e.recordId).Scan(&matches); err != nil {
+			return errors.Wrap(err, "evaluate row rule")
+		}
+		if matches {
+			e.add("row", e.recordId, string(action), true, "record_permission.conditions", "the record matches %s", condition)
+		} else {
+			e.add("row", e.recordId, string(action), false, "record_permission.conditions", "the record does not match %s", condition)
+		}
+	}
+
+	return nil
+}
+
+// automaticFilters explains the legacy automatic filters of the role. Only
+// read filters are applied, and only to roles with is_have_condition set
+// and no read rule.
+func (e *accessExplainer) automaticFilters(ctx context.Context) error {
+	rows, err := e.q.Query(ctx, `
+		SELECT guid::TEXT, role_id::TEXT, COALESCE(method, ''), COALESCE(custom_field, ''), COALESCE(object_field, ''), COALESCE(not_use_in_tab, false)
+		FROM effective_automatic_filter
+		WHERE table_slug = $1 AND effective_role_id::TEXT = $2
+		ORDER BY method, created_at`, e.tableSlug, e.subject.RoleId)
+	if err != nil {
+		return errors.Wrap(err, "get automatic filters")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id, method, customField, objectField string
+			notUseInTab                          bool
+		)
+		if err := rows.Scan(&id, &e.owner, &method, &customField, &objectField, &notUseInTab); err != nil {
+			return errors.Wrap(err, "scan automatic filter")
+		}
+
+		switch {
+		case method != "read":
+			e.add("automatic_filter", id, method, true, "automatic_filter", "not applied, only read filters are")
+		case e.readRule:
+			e.add("automatic_filter", id, method, true, "record_permission.conditions", "not applied, the read rule of the role supersedes it")
+		case !e.legacyFilter:
+			e.add("automatic_filter", id, method, true, "record_permission.is_have_condition", "not applied, is_have_condition is off")
+		case notUseInTab:
+			e.add("automatic_filter", id, method, true, "automatic_filter.not_use_in_tab", "not applied, not_use_in_tab is set")
+		default:
+			e.add("automatic_filter", id, method, true, "automatic_filter", "reads only return items whose %s matches the %s of the user", customField, objectField)
+		}
+	}
+
+	return rows.Err()
+}
+
+// fields explains field_permission. Reads show the fields the role has no
+// permission row for, as helper.LoadFieldAccess does, and writes only drop
+// the fields it may not edit.
+func (e *accessExplainer) fields(ctx context.Context) error {
+	encrypted, err := helper.LoadEncryptedFields(ctx, e.q, e.tableSlug)
+	if err != nil {
+		return err
+	}
+
+	rows, err := e.q.Query(ctx, `
+		SELECT f.slug, fp.view_permission, fp.edit_permission, COALESCE(fp.mask, ''),
+			COALESCE(fp.decrypt_permission, false), COALESCE(fp.role_id::TEXT, '')
+		FROM field f
+		JOIN "table" t ON t.id = f.table_id
+		LEFT JOIN effective_field_permission fp
+			ON fp.field_id = f.id AND fp.effective_role_id::TEXT = $2 AND fp.table_slug = $1
+		WHERE t.slug = $1
+		ORDER BY f.slug`, e.tableSlug, e.subject.RoleId)
+	if err != nil {
+		return errors.Wrap(err, "get field permissions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			slug string
+			rule helper.FieldRule
+			edit *bool
+		)
+		if err := rows.Scan(&slug, &rule.View, &edit, &rule.Mask, &rule.Decrypt, &e.owner); err != nil {
+			return errors.Wrap(err, "scan field permission")
+		}
+		found := e.owner != ""
+		_, isEncrypted := encrypted[slug]
+
+		e.fieldRead(slug, found, rule, isEncrypted && !e.admin)
+
+		switch {
+		case e.admin:
+			e.add("field", slug, "edit", true, "client_type", "the ADMIN client type is not restricted")
+		case !found:
+			e.add("field", slug, "edit", true, "default", "the role has no permission on the field, writes keep it")
+		case edit != nil && !*edit:
+			e.add("field", slug, "edit", false, "field_permission.edit_permission", "edit_permission is off, writes drop it")
+		case rule.Mask != "":
+			e.add("field", slug, "edit", false, "field_permission.mask", "the field is masked, writes drop it")
+		default:
+			e.add("field", slug, "edit", true, "field_permission.edit_permission", "edit_permission is on")
+		}
+	}
+
+	return rows.Err()
+}
+
+// fieldRead explains how reads show the field slug, deciding it with
+// helper.FieldRule.Read as the reads do.
+func (e *accessExplainer) fieldRead(slug string, found bool, rule helper.FieldRule, sealed bool) {
+	visible, mask := rule.Read(sealed)
+
+	switch {
+	case !visible:
+		e.add("field", slug, "view", false, "field_permission.view_permission", "view_permission is off")
+	case sealed && !rule.Decrypt:
+		e.add("field", slug, "view", true, "field_permission.decrypt_permission", "the field is encrypted and decrypt_permission is off, reads and exports show it masked as %s", mask)
+	case mask != authz.NoMask:
+		e.add("field", slug, "view", true, "field_permission.mask", "view_permission is on, reads and exports show it masked as %s", mask)
+	case !found:
+		e.add("field", slug, "view", true, "default", "the role has no permission on the field, reads show it")
+	default:
+		e.add("field", slug, "view", true, "field_permission.view_permission", "view_permission is on")
+	}
+}
+
+// actions explains action_permission for the custom events of the table.
+// Events without one may run.
+func (e *accessExplainer) actions(ctx context.Context) error {
+	rows, err := e.q.Query(ctx, `
+		SELECT ce.id::TEXT, ce.label, ap.permission, COALESCE(ap.role_id::TEXT, '')
+		FROM custom_event ce
+		LEFT JOIN effective_action_permission ap
+			ON ap.custom_event_id = ce.id AND ap.effective_role_id::TEXT = $2 AND ap.table_slug = $1
+		WHERE ce.table_slug = $1
+		ORDER BY ce.label`, e.tableSlug, e.subject.RoleId)
+	if err != nil {
+		return errors.Wrap(err, "get action permissions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id, label  string
+			permission *bool
+		)
+		if err := rows.Scan(&id, &label, &permission, &e.owner); err != nil {
+			return errors.Wrap(err, "scan action permission")
+		}
+		found := e.owner != ""
+
+		switch {
+		case e.admin:
+			e.add("action", id, "run", true, "client_type", "%s: the ADMIN client type is not restricted", label)
+		case !found:
+			e.add("action", id, "run", true, "default", "%s: the role has no action permission, events default to allowed", label)
+		case permission != nil && !*permission:
+			e.add("action", id, "run", false, "action_permission.permission", "%s: permission is off", label)
+		default:
+			e.add("action", id, "run", true, "action_permission.permission", "%s: permission is on", label)
+		}
+	}
+
+	return rows.Err()
+}
+
+// views explains view_permission. A view is only shown to the roles with
+// a permission row for it.
+func (e *accessExplainer) views(ctx context.Context) error {
+	rows, err := e.q.Query(ctx, `
+		SELECT v.id::TEXT, COALESCE(v.name, ''), vp.view, vp.edit, vp.delete, COALESCE(vp.role_id::TEXT, '')
+		FROM view v
+		LEFT JOIN effective_view_permission vp
+			ON vp.view_id = v.id AND vp.effective_role_id::TEXT = $2
+		WHERE v.table_slug = $1
+		ORDER BY v."order"`, e.tableSlug, e.subject.RoleId)
+	if err != nil {
+		return errors.Wrap(err, "get view permissions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id, name        string
+			view, edit, del *bool
+		)
+		if err := rows.Scan(&id, &name, &view, &edit, &del, &e.owner); err != nil {
+			return errors.Wrap(err, "scan view permission")
+		}
+
+		if e.owner == "" {
+			e.add("view", id, "view", false, "view_permission", "%s: the role has no permission on the view", name)
+			continue
+		}
+		for _, c := range []struct {
+			capability string
+			value      *bool
+		}{{"view", view}, {"edit", edit}, {"delete", del}} {
+			allowed := c.value == nil || *c.value
+			e.add("view", id, c.capability, allowed, "view_permission."+c.capability, "%s: %s is %s", name, c.capability, onOff(allowed))
+		}
+	}
+
+	return rows.Err()
+}
+
+// viewRelations explains the view_relation_permission rows of the role on
+// the table.
+func (e *accessExplainer) viewRelations(ctx context.Context) error {
+	rows, err := e.q.Query(ctx, `
+		SELECT
+			COALESCE(relation_id::TEXT, ''),
+			role_id::TEXT,
+			COALESCE(label, '') AS label,
+			COALESCE(view_permission, false),
+			COALESCE(create_permission, false),
+			COALESCE(edit_permission, false),
+			COALESCE(delete_permission, false)
+		FROM effective_view_relation_permission
+		WHERE table_slug = $1 AND effective_role_id::TEXT = $2
+		ORDER BY label`, e.tableSlug, e.subject.RoleId)
+	if err != nil {
+		return errors.Wrap(err, "get view relation permissions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			relationId, label              string
+			view, create, edit, deleteable bool
+		)
+		if err := rows.Scan(&relationId, &e.owner, &label, &view, &create, &edit, &deleteable); err != nil {
+			return errors.Wrap(err, "scan view relation permission")
+		}
+
+		for _, c := range []struct {
+			capability string
+			allowed    bool
+		}{{"view", view}, {"create", create}, {"edit", edit}, {"delete", deleteable}} {
+			e.add("view_relation", relationId, c.capability, c.allowed, "view_relation_permission."+c.capability+"_permission",
+				"%s: %s_permission is %s", label, c.capability, onOff(c.allowed))
+		}
+	}
+
+	return rows.Err()
+}
+
+// menus explains menu_permission for the menus that open the table. A
+// menu the role has no permission row for is hidden.
+func (e *accessExplainer) menus(ctx context.Context) error {
+	rows, err := e.q.Query(ctx, `
+		SELECT
+			m.id::TEXT,
+			COALESCE(m.label, ''),
+			COALESCE(mp.read, false),
+			COALESCE(mp.write, false),
+			COALESCE(mp.update, false),
+			COALESCE(mp.delete, false),
+			COALESCE(mp.menu_settings, false),
+			COALESCE(mp.role_id::TEXT, '')
+		FROM menu m
+		JOIN "table" t ON t.id = m.table_id
+		LEFT JOIN effective_menu_permission mp
+			ON mp.menu_id = m.id AND mp.effective_role_id::TEXT = $2
+		WHERE t.slug = $1
+		ORDER BY m.label`, e.tableSlug, e.subject.RoleId)
+	if err != nil {
+		return errors.Wrap(err, "get menu permissions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id, label                                 string
+			read, write, update, deleteable, settings bool
+		)
+		if err := rows.Scan(&id, &label, &read, &write, &update, &deleteable, &settings, &e.owner); err != nil {
+			return errors.Wrap(err, "scan menu permission")
+		}
+
+		if e.owner == "" {
+			e.add("menu", id, "read", false, "menu_permission", "%s: the role has no permission on the menu", label)
+			continue
+		}
+		for _, c := range []struct {
+			capability string
+			allowed    bool
+		}{{"read", read}, {"write", write}, {"update", update}, {"delete", deleteable}, {"menu_settings", settings}} {
+			e.add("menu", id, c.capability, c.allowed, "menu_permission."+c.capability, "%s: %s is %s", label, c.capability, onOff(c.allowed))
+		}
+	}
+
+	return rows.Err()
+}
+
+// custom explains the custom permissions of the role and client type. They
+// are not tied to a table, take the access of their parent permission when
+// the role has none of its own, and default to No.
+func (e *accessExplainer) custom(ctx context.Context) error {
+	tree, err := loadCustomPermissionTree(ctx, e.q)
+	if err != nil {
+		return errors.Wrap(err, "get custom permission tree")
+	}
+
+	accesses, err := loadCustomAccess(ctx, e.q, []string{e.subject.RoleId}, e.subject.ClientTypeId)
+	if err != nil {
+		return errors.Wrap(err, "get custom permission access")
+	}
+	access := accesses[customAccessKey{e.subject.RoleId, e.subject.ClientTypeId}]
+
+	rows, err := e.q.Query(ctx, `SELECT id::TEXT, title FROM custom_permission ORDER BY created_at`)
+	if err != nil {
+		return errors.Wrap(err, "get custom permissions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, title string
+		if err := rows.Scan(&id, &title); err != nil {
+			return errors.Wrap(err, "scan custom permission")
+		}
+
+		for _, action := range customAccessActions {
+			value, from := tree.resolve(access, id, action)
+			switch {
+			case from == "":
+				e.add("custom", id, action, false, "default", "%s: the role has no %s access, custom permissions default to No", title, action)
+			case from != id:
+				e.add("custom", id, action, value == "Yes", "custom_permission_access."+action, "%s: %s is %q, inherited from permission %s", title, action, value, from)
+			default:
+				e.add("custom", id, action, value == "Yes", "custom_permission_access."+action, "%s: %s is %q", title, action, value)
+			}
+		}
+	}
+
+	return rows.Err()
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,310 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainFieldReadMatchesFieldAccess(t *testing.T) {
+	var (
+		on, off = true, false
+
+		rules = map[string]helper.FieldRule{
+			"hidden":    {View: &off},
+			"unset":     {},
+			"visible":   {View: &on},
+			"masked":    {View: &on, Mask: string(authz.MaskLast4)},
+			"secret":    {View: &on},
+			"decrypted": {View: &on, Decrypt: true, Mask: string(authz.MaskLast4)},
+		}
+		encrypted = map[string]bool{"secret": true, "decrypted": true, "unlisted": true}
+	)
+
+	for _, admin := range []bool{false, true} {
+		sealed := make(map[string]bool)
+		if !admin {
+			sealed = encrypted
+		}
+		access := helper.NewFieldAccess(rules, sealed)
+
+		for _, slug := range []string{"hidden", "unset", "visible", "masked", "secret", "decrypted", "unlisted", "missing"} {
+			rule, found := rules[slug]
+
+			e := &accessExplainer{admin: admin}
+			e.fieldRead(slug, found, rule, encrypted[slug] && !admin)
+			require.Len(t, e.decisions, 1)
+
+			decision := e.decisions[0]
+			assert.Equal(t, !access.Hidden[slug], decision.Allowed, "%s admin=%v", slug, admin)
+			if mask := access.Masks[slug]; mask != authz.NoMask {
+				assert.Contains(t, decision.Reason, "masked as "+string(mask), "%s admin=%v", slug, admin)
+			} else {
+				assert.NotContains(t, decision.Reason, "masked", "%s admin=%v", slug, admin)
+			}
+		}
+	}
+
+	e := &accessExplainer{}
+	e.fieldRead("missing", false, helper.FieldRule{}, false)
+	assert.Equal(t, "default", e.decisions[0].Source)
+	assert.True(t, e.decisions[0].Allowed)
+}
+
+// decision returns the decision on capability of target, nil when there is
+// none.
+func decision(decisions []*nb.AccessDecision, scope, target, capability string) *nb.AccessDecision {
+	for _, d := range decisions {
+		if d.Scope == scope && d.Target == target && d.Capability == capability {
+			return d
+		}
+	}
+	return nil
+}
+
+func TestExplainRecord(t *testing.T) {
+	permission := func(owner, read, update string) fakeRow {
+		values := []any{owner, false, read, "", update, ""}
+		for range recordFlags {
+			values = append(values, "")
+		}
+		return fakeRow{match: "FROM effective_record_permission", values: values}
+	}
+
+	type want struct {
+		capability string
+		allowed    bool
+		source     string
+		inherited  string
+	}
+
+	tests := []struct {
+		name  string
+		admin bool
+		rows  []fakeRow
+		want  []want
+	}{
+		{
+			name: "role permission",
+			rows: []fakeRow{permission("role", "Yes", "No")},
+			want: []want{
+				{capability: "read", allowed: true, source: "record_permission.read"},
+				{capability: "update", source: "record_permission.update"},
+				{capability: "delete", allowed: true, source: "record_permission.delete"},
+				{capability: "settings", allowed: true, source: "default"},
+			},
+		},
+		{
+			name: "inherited permission",
+			rows: []fakeRow{permission("parent", "Yes", "")},
+			want: []want{
+				{capability: "read", allowed: true, source: "record_permission.read", inherited: "parent"},
+				{capability: "update", allowed: true, source: "record_permission.update", inherited: "parent"},
+				{capability: "settings", allowed: true, source: "default"},
+			},
+		},
+		{
+			name: "read denied",
+			rows: []fakeRow{permission("role", "No", ""), {match: "is_public = true", values: []any{false}}},
+			want: []want{{capability: "read", source: "record_permission.read"}},
+		},
+		{
+			name: "public read",
+			rows: []fakeRow{{match: "is_public = true", values: []any{true}}},
+			want: []want{
+				{capability: "read", allowed: true, source: "record_permission.is_public"},
+				{capability: "create", allowed: true, source: "default"},
+			},
+		},
+		{
+			name: "no permission",
+			rows: []fakeRow{{match: "is_public = true", values: []any{false}}},
+			want: []want{
+				{capability: "read", source: "record_permission"},
+				{capability: "delete", allowed: true, source: "default"},
+			},
+		},
+		{
+			name:  "admin writes",
+			admin: true,
+			rows:  []fakeRow{permission("role", "Yes", "No")},
+			want:  []want{{capability: "update", allowed: true, source: "client_type"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &accessExplainer{
+				q:         &fakeQuerier{rows: tt.rows},
+				subject:   authz.Subject{RoleId: "role"},
+				tableSlug: "post",
+				admin:     tt.admin,
+			}
+			require.NoError(t, e.record(context.Background()))
+			assert.Len(t, e.decisions, 4+len(recordFlags))
+
+			for _, w := range tt.want {
+				d := decision(e.decisions, "record", "post", w.capability)
+				require.NotNil(t, d, w.capability)
+				assert.Equal(t, w.allowed, d.Allowed, w.capability)
+				assert.Equal(t, w.source, d.Source, w.capability)
+				assert.Equal(t, w.inherited, d.InheritedFrom, w.capability)
+			}
+		})
+	}
+}
+
+func TestExplainAutomaticFilters(t *testing.T) {
+	filter := func(id, method string, notUseInTab bool) []any {
+		return []any{id, "role", method, "owner_id", "user_id", notUseInTab}
+	}
+
+	tests := []struct {
+		name         string
+		readRule     bool
+		legacyFilter bool
+		row          []any
+		source       string
+		applied      bool
+	}{
+		{name: "applied", legacyFilter: true, row: filter("f", "read", false), source: "automatic_filter", applied: true},
+		{name: "write filter", legacyFilter: true, row: filter("f", "write", false), source: "automatic_filter"},
+		{name: "read rule", readRule: true, legacyFilter: true, row: filter("f", "read", false), source: "record_permission.conditions"},
+		{name: "condition off", row: filter("f", "read", false), source: "record_permission.is_have_condition"},
+		{name: "not used in tab", legacyFilter: true, row: filter("f", "read", true), source: "automatic_filter.not_use_in_tab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &accessExplainer{
+				q:            &fakeQuerier{results: []fakeResult{{match: "FROM effective_automatic_filter", rows: [][]any{tt.row}}}},
+				subject:      authz.Subject{RoleId: "role"},
+				tableSlug:    "post",
+				readRule:     tt.readRule,
+				legacyFilter: tt.legacyFilter,
+			}
+			require.NoError(t, e.automaticFilters(context.Background()))
+			require.Len(t, e.decisions, 1)
+			assert.Equal(t, tt.source, e.decisions[0].Source)
+			assert.Equal(t, tt.applied, !strings.HasPrefix(e.decisions[0].Reason, "not applied"))
+		})
+	}
+}
+
+func TestExplainFieldEdits(t *testing.T) {
+	var (
+		on, off = true, false
+		none    *bool
+	)
+
+	fields := fakeResult{match: "LEFT JOIN effective_field_permission", rows: [][]any{
+		{"title", none, none, "", false, ""},
+		{"body", &on, &on, "", false, "role"},
+		{"salary", &on, &off, "", false, "role"},
+		{"phone", &on, &on, "last4", false, "parent"},
+		{"ssn", &on, &on, "", false, "role"},
+	}}
+	encrypted := fakeResult{match: "f.attributes ? 'encrypted'", rows: [][]any{{"ssn", true}}}
+
+	tests := []struct {
+		name  string
+		admin bool
+		want  map[string]bool
+		views map[string]string
+	}{
+		{
+			name: "role",
+			want: map[string]bool{"title": true, "body": true, "salary": false, "phone": false, "ssn": true},
+			views: map[string]string{
+				"title": "default",
+				"body":  "field_permission.view_permission",
+				"phone": "field_permission.mask",
+				"ssn":   "field_permission.decrypt_permission",
+			},
+		},
+		{
+			name:  "admin",
+			admin: true,
+			want:  map[string]bool{"title": true, "body": true, "salary": true, "phone": true, "ssn": true},
+			views: map[string]string{"phone": "field_permission.mask", "ssn": "field_permission.view_permission"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &accessExplainer{
+				q:         &fakeQuerier{results: []fakeResult{encrypted, fields}},
+				subject:   authz.Subject{RoleId: "role"},
+				tableSlug: "employee",
+				admin:     tt.admin,
+			}
+			require.NoError(t, e.fields(context.Background()))
+
+			for slug, allowed := range tt.want {
+				d := decision(e.decisions, "field", slug, "edit")
+				require.NotNil(t, d, slug)
+				assert.Equal(t, allowed, d.Allowed, slug)
+			}
+			for slug, source := range tt.views {
+				d := decision(e.decisions, "field", slug, "view")
+				require.NotNil(t, d, slug)
+				assert.Equal(t, source, d.Source, slug)
+			}
+			if !tt.admin {
+				assert.Equal(t, "parent", decision(e.decisions, "field", "phone", "view").InheritedFrom)
+			}
+		})
+	}
+}
+
+func TestExplainViewsAndActions(t *testing.T) {
+	var (
+		on, off = true, false
+		none    *bool
+	)
+
+	e := &accessExplainer{
+		q: &fakeQuerier{results: []fakeResult{
+			{match: "FROM view v", rows: [][]any{
+				{"v1", "Table", &on, &off, none, "role"},
+				{"v2", "Board", none, none, none, ""},
+			}},
+			{match: "FROM custom_event ce", rows: [][]any{
+				{"e1", "Send", &off, "role"},
+				{"e2", "Print", none, ""},
+			}},
+		}},
+		subject:   authz.Subject{RoleId: "role"},
+		tableSlug: "post",
+	}
+	require.NoError(t, e.views(context.Background()))
+	require.NoError(t, e.actions(context.Background()))
+
+	tests := []struct {
+		scope, target, capability string
+		allowed                   bool
+		source                    string
+	}{
+		{scope: "view", target: "v1", capability: "view", allowed: true, source: "view_permission.view"},
+		{scope: "view", target: "v1", capability: "edit", source: "view_permission.edit"},
+		{scope: "view", target: "v1", capability: "delete", allowed: true, source: "view_permission.delete"},
+		{scope: "view", target: "v2", capability: "view", source: "view_permission"},
+		{scope: "action", target: "e1", capability: "run", source: "action_permission.permission"},
+		{scope: "action", target: "e2", capability: "run", allowed: true, source: "default"},
+	}
+
+	for _, tt := range tests {
+		d := decision(e.decisions, tt.scope, tt.target, tt.capability)
+		require.NotNil(t, d, "%s %s %s", tt.scope, tt.target, tt.capability)
+		assert.Equal(t, tt.allowed, d.Allowed, "%s %s %s", tt.scope, tt.target, tt.capability)
+		assert.Equal(t, tt.source, d.Source, "%s %s %s", tt.scope, tt.target, tt.capability)
+	}
+	assert.Nil(t, decision(e.decisions, "view", "v2", "edit"))
+}
@@ -11,6 +11,7 @@ import (
 	"ucode/ucode_go_object_builder_service/config"
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
 	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/formula"
 	"ucode/ucode_go_object_builder_service/pkg/helper"
 	"ucode/ucode_go_object_builder_service/pkg/logger"
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
@@ -22,6 +23,9 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type fieldRepo struct {
@@ -42,6 +46,14 @@ func (f *fieldRepo) Create(ctx context.Context, req *nb.CreateFieldRequest) (res
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "field.Create")
 	defer dbSpan.Finish()
 
+	if err := validateFormula(req.GetType(), req.GetAttributes()); err != nil {
+		return nil, err
+	}
+
+	if err := validateEncryption(req.GetType(), req.GetUnique(), req.GetAttributes()); err != nil {
+		return nil, err
+	}
+
 	var (
 		body                                  []byte
 		fields                                = []models.SectionFields{}
@@ -102,6 +114,10 @@ func (f *fieldRepo) Create(ctx context.Context, req *nb.CreateFieldRequest) (res
 		return f.GetByID(ctx, &nb.FieldPrimaryKey{Id: fieldId, ProjectId: req.ProjectId})
 	}
 
+	if err := checkFormulaCycles(ctx, conn, tableSlug, "", strings.ToLower(req.GetSlug()), req.GetType(), req.GetAttributes().AsMap()); err != nil {
+		return nil, err
+	}
+
 	tx, err := conn.Begin(ctx)
 	if err != nil {
 		return nil, f.db.HandleDatabaseError(err, "Create field: failed to begin transaction")
@@ -162,13 +178,19 @@ func (f *fieldRepo) Create(ctx context.Context, req *nb.CreateFieldRequest) (res
 		return nil, f.db.HandleDatabaseError(err, "Create field: failed to execute insert query")
 	}
 
-	query = `ALTER TABLE "` + tableSlug + `" ADD COLUMN ` + req.Slug + " " + helper.GetDataType(req.Type)
+	query = `ALTER TABLE "` + tableSlug + `" ADD COLUMN ` + req.Slug + " " + fieldDataType(req.GetType(), req.GetAttributes())
 
 	_, err = tx.Exec(ctx, query)
 	if err != nil {
 		return nil, f.db.HandleDatabaseError(err, "Create field: failed to alter table")
 	}
 
+	if encryptedField(req.GetAttributes()) {
+		if err := helper.EnsureDataKeys(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
 	if req.Unique {
 		query = fmt.Sprintf(`ALTER TABLE IF EXISTS %s ADD CONSTRAINT %s_%s_unq UNIQUE(%s)`, tableSlug, tableSlug, req.Slug, req.Slug)
 		_, err = tx.Exec(ctx, query)
@@ -177,7 +199,7 @@ func (f *fieldRepo) Create(ctx context.Context, req *nb.CreateFieldRequest) (res
 		}
 	}
 
-	query = `SELECT guid FROM "role"`
+	query = `SELECT guid FROM "role" WHERE parent_id IS NULL`
 
 	rows, err := tx.Query(ctx, query)
 	if err != nil {
@@ -515,6 +537,14 @@ func (f *fieldRepo) Update(ctx context.Context, req *nb.Field) (resp *nb.Field,
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "field.Update")
 	defer dbSpan.Finish()
 
+	if err := validateFormula(req.GetType(), req.GetAttributes()); err != nil {
+		return &nb.Field{}, err
+	}
+
+	if err := validateEncryption(req.GetType(), req.GetUnique(), req.GetAttributes()); err != nil {
+		return &nb.Field{}, err
+	}
+
 	conn, err := psqlpool.Get(req.GetProjectId())
 	if err != nil {
 		return nil, err
@@ -557,6 +587,10 @@ func (f *fieldRepo) Update(ctx context.Context, req *nb.Field) (resp *nb.Field,
 		return &nb.Field{}, errors.Wrap(err, "error getting table slug")
 	}
 
+	if err := checkFormulaCycles(ctx, tx, tableSlug, resp.Slug, req.GetSlug(), req.GetType(), req.GetAttributes().AsMap()); err != nil {
+		return &nb.Field{}, err
+	}
+
 	if _, err := uuid.Parse(req.Id); err == nil {
 		fieldFilter = ` id = $1`
 	} else {
@@ -570,6 +604,17 @@ func (f *fieldRepo) Update(ctx context.Context, req *nb.Field) (resp *nb.Field,
 		return &nb.Field{}, errors.Wrap(err, "error getting table slug")
 	}
 
+	// A field that was encrypted keeps the flag, turned off, until the
+	// re-encrypt job has decrypted its values
+	if _, ok := resp.GetAttributes().GetFields()["encrypted"]; ok && req.GetAttributes() != nil {
+		if req.Attributes.Fields == nil {
+			req.Attributes.Fields = make(map[string]*structpb.Value)
+		}
+		if _, ok := req.Attributes.Fields["encrypted"]; !ok {
+			req.Attributes.Fields["encrypted"] = structpb.NewBoolValue(false)
+		}
+	}
+
 	attributes, err := json.Marshal(req.Attributes)
 	if err != nil {
 		return &nb.Field{}, errors.Wrap(err, "error marshaling attributes")
@@ -623,7 +668,7 @@ func (f *fieldRepo) Update(ctx context.Context, req *nb.Field) (resp *nb.Field,
 			return &nb.Field{}, errors.Wrap(err, "error dropping column")
 		}
 
-		fieldType := helper.GetDataType(req.Type)
+		fieldType := fieldDataType(req.GetType(), req.GetAttributes())
 
 		query = fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN %s %s`, tableSlug, req.Slug, fieldType)
 
@@ -631,6 +676,14 @@ func (f *fieldRepo) Update(ctx context.Context, req *nb.Field) (resp *nb.Field,
 		if err != nil {
 			return &nb.Field{}, errors.Wrap(err, "error adding column")
 		}
+	} else if oldType, newType := fieldDataType(resp.Type, resp.Attributes), fieldDataType(req.Type, req.Attributes); oldType != newType {
+		query = fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN %s TYPE %s USING %s`,
+			tableSlug, resp.Slug, newType, convertColumn(pq.QuoteIdentifier(resp.Slug), newType))
+
+		_, err = tx.Exec(ctx, query)
+		if err != nil {
+			return &nb.Field{}, errors.Wrap(err, "error altering column type")
+		}
 	}
 
 	if resp.Slug != req.Slug {
@@ -643,6 +696,20 @@ func (f *fieldRepo) Update(ctx context.Context, req *nb.Field) (resp *nb.Field,
 		}
 	}
 
+	if encryptedField(req.GetAttributes()) {
+		if err := helper.EnsureDataKeys(ctx, tx); err != nil {
+			return &nb.Field{}, err
+		}
+	}
+
+	if resp.Slug != req.Slug {
+		_, err = tx.Exec(ctx, `UPDATE encrypted_field_index SET field_slug = $3 WHERE table_slug = $1 AND field_slug = $2`,
+			tableSlug, resp.Slug, req.Slug)
+		if err != nil {
+			return &nb.Field{}, errors.Wrap(err, "error renaming blind index")
+		}
+	}
+
 	if !resp.Unique && req.Unique {
 		query = fmt.Sprintf(`ALTER TABLE IF EXISTS %s ADD CONSTRAINT %s_%s_unq UNIQUE(%s)`, tableSlug, tableSlug, req.Slug, req.Slug)
 		_, err = tx.Exec(ctx, query)
@@ -889,6 +956,11 @@ func (f *fieldRepo) Delete(ctx context.Context, req *nb.FieldPrimaryKey) error {
 		return errors.Wrap(err, "error dropping column")
 	}
 
+	_, err = tx.Exec(ctx, `DELETE FROM encrypted_field_index WHERE table_slug = $1 AND field_slug = $2`, tableSlug, fieldSlug)
+	if err != nil {
+		return errors.Wrap(err, "error deleting blind index")
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return errors.Wrap(err, "error committing transaction")
 	}
@@ -1074,6 +1146,14 @@ func (f *fieldRepo) CreateWithTx(ctx context.Context, req *nb.CreateFieldRequest
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "field.Create")
 	defer dbSpan.Finish()
 
+	if err := validateFormula(req.GetType(), req.GetAttributes()); err != nil {
+		return nil, err
+	}
+
+	if err := validateEncryption(req.GetType(), req.GetUnique(), req.GetAttributes()); err != nil {
+		return nil, err
+	}
+
 	var (
 		body                       []byte
 		fields                     = []models.SectionFields{}
@@ -1131,6 +1211,10 @@ func (f *fieldRepo) CreateWithTx(ctx context.Context, req *nb.CreateFieldRequest
 
 	req.Slug = strings.ToLower(req.GetSlug())
 
+	if err := checkFormulaCycles(ctx, tx, tableSlug, "", req.Slug, req.GetType(), req.GetAttributes().AsMap()); err != nil {
+		return nil, err
+	}
+
 	query := `INSERT INTO "field" (
 		id,
 		"table_id",
@@ -1175,14 +1259,20 @@ func (f *fieldRepo) CreateWithTx(ctx context.Context, req *nb.CreateFieldRequest
 		return nil, f.db.HandleDatabaseError(err, "Create field: failed to execute insert query")
 	}
 
-	query = `ALTER TABLE "` + tableSlug + `" ADD COLUMN ` + req.Slug + " " + helper.GetDataType(req.Type)
+	query = `ALTER TABLE "` + tableSlug + `" ADD COLUMN ` + req.Slug + " " + fieldDataType(req.GetType(), req.GetAttributes())
 
 	_, err = tx.Exec(ctx, query)
 	if err != nil {
 		return nil, f.db.HandleDatabaseError(err, "Create field: failed to alter table")
 	}
 
-	query = `SELECT guid FROM "role"`
+	if encryptedField(req.GetAttributes()) {
+		if err := helper.EnsureDataKeys(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	query = `SELECT guid FROM "role" WHERE parent_id IS NULL`
 
 	rows, err := tx.Query(ctx, query)
 	if err != nil {
@@ -1340,3 +1430,66 @@ func (f *fieldRepo) ObtainRandomOne(ctx context.Context, req *nb.ObtainRandomReq
 
 	return resp, nil
 }
+
+// validateFormula compiles the formula of a FORMULA_FRONTEND field, so a
+// broken formula is rejected when the field is saved rather than evaluating
+// to an error on every record.
+func validateFormula(fieldType string, attributes *structpb.Struct) error {
+	if fieldType != config.FORMULA_FRONT {
+		return nil
+	}
+
+	source := attributes.GetFields()["formula"].GetStringValue()
+	if source == "" {
+		return nil
+	}
+
+	if _, err := formula.Compile(source); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid formula: %v", err)
+	}
+
+	return nil
+}
+
+// encryptedField reports whether a field is flagged encrypted.
+func encryptedField(attributes *structpb.Struct) bool {
+	return attributes.GetFields()["encrypted"].GetBoolValue()
+}
+
+// validateEncryption rejects the encrypted flag on fields whose values
+// cannot be stored encrypted. Encrypted values differ on every write, so
+// such a field cannot be unique either.
+func validateEncryption(fieldType string, unique bool, attributes *structpb.Struct) error {
+	if !encryptedField(attributes) {
+		return nil
+	}
+
+	if !helper.EncryptableFieldTypes[fieldType] {
+		return status.Errorf(codes.InvalidArgument, "fields of type %s cannot be encrypted", fieldType)
+	}
+	if unique {
+		return status.Error(codes.InvalidArgument, "encrypted fields cannot be unique")
+	}
+
+	return nil
+}
+
+// convertColumn returns the USING expression that converts column to
+// newType. Text that is not a number becomes NULL in a numeric column.
+func convertColumn(column, newType string) string {
+	if newType == "NUMERIC" || newType == "FLOAT" {
+		return fmt.Sprintf(`CASE WHEN btrim(%[1]s::TEXT) ~ '^[-+]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?$' THEN btrim(%[1]s::TEXT)::%[2]s END`, column, newType)
+	}
+
+	return column + "::" + newType
+}
+
+// fieldDataType is the column type of a field. Text rollups store text, so
+// a STRING_AGG formula gets a VARCHAR column instead of the FORMULA default.
+func fieldDataType(fieldType string, attributes *structpb.Struct) string {
+	if fieldType == "FORMULA" && strings.EqualFold(attributes.GetFields()["type"].GetStringValue(), rollupStringAgg) {
+		return "VARCHAR"
+	}
+
+	return helper.GetDataType(fieldType)
+}
@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	"ucode/ucode_go_object_builder_service/pkg/security"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+type encryptedColumn struct {
+	tableSlug string
+	slug      string
+	on        bool
+}
+
+// ReencryptFields brings up to batchSize values of the encrypted fields of
+// the project in line with their flag: plaintext values of encrypted
+// fields and values of older data keys are encrypted with the active key,
+// and values of fields whose flag was turned off are decrypted. Before
+// that, data keys wrapped by a previous master key are rewrapped and the
+// data key is rotated once it is older than the rotation period. It
+// returns the number of values it rewrote.
+func (i *itemsRepo) ReencryptFields(ctx context.Context, projectId string, batchSize int) (processed int, err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.ReencryptFields")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(projectId)
+	if err != nil {
+		return 0, err
+	}
+
+	fields, err := loadEncryptedFields(ctx, conn)
+	if err != nil || len(fields) == 0 {
+		return 0, err
+	}
+
+	if err := helper.EnsureDataKeys(ctx, conn); err != nil {
+		return 0, err
+	}
+	if _, err := helper.RewrapDataKeys(ctx, conn); err != nil {
+		return 0, err
+	}
+	if err := rotateDataKey(ctx, conn, i.cfg.EncryptionKeyRotation); err != nil {
+		return 0, err
+	}
+
+	keyring, err := helper.LoadKeyring(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, field := range fields {
+		if processed >= batchSize {
+			break
+		}
+
+		n, err := reencryptField(ctx, conn, keyring, field, batchSize-processed)
+		if n > 0 {
+			invalidateCache(ctx, projectId, field.tableSlug)
+		}
+		processed += n
+		if err != nil {
+			return processed, err
+		}
+	}
+
+	return processed, nil
+}
+
+// loadEncryptedFields returns the fields of every table with an
+// "encrypted" attribute.
+func loadEncryptedFields(ctx context.Context, conn *psqlpool.Pool) ([]encryptedColumn, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT t.slug, f.slug, f.attributes->'encrypted' = 'true'::JSONB
+		FROM field f
+		JOIN "table" t ON t.id = f.table_id
+		WHERE f.attributes ? 'encrypted'
+		ORDER BY t.slug, f.slug`)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting encrypted fields")
+	}
+	defer rows.Close()
+
+	var fields []encryptedColumn
+	for rows.Next() {
+		var field encryptedColumn
+		if err := rows.Scan(&field.tableSlug, &field.slug, &field.on); err != nil {
+			return nil, errors.Wrap(err, "error while scanning encrypted field")
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, rows.Err()
+}
+
+// rotateDataKey adds a data key when the active one is older than period.
+// A zero period turns rotation off.
+func rotateDataKey(ctx context.Context, conn *psqlpool.Pool, period time.Duration) error {
+	if period <= 0 {
+		return nil
+	}
+
+	var due bool
+	err := conn.QueryRow(ctx, `
+		SELECT COALESCE(MAX(created_at) < NOW() - make_interval(secs => $1), true)
+		FROM data_key WHERE purpose = $2`,
+		period.Seconds(), helper.DataKeyPurpose,
+	).Scan(&due)
+	if err != nil {
+		return errors.Wrap(err, "error while checking data key age")
+	}
+	if !due {
+		return nil
+	}
+
+	return helper.CreateDataKey(ctx, conn, helper.DataKeyPurpose)
+}
+
+// reencryptField rewrites up to limit values of field. Once a field whose
+// flag was turned off has no encrypted value left, the flag and the blind
+// index of the field are removed.
+func reencryptField(ctx context.Context, conn *psqlpool.Pool, keyring *security.Keyring, field encryptedColumn, limit int) (int, error) {
+	var (
+		column  = pq.QuoteIdentifier(field.slug)
+		table   = pq.QuoteIdentifier(field.tableSlug)
+		pattern = security.CiphertextPrefix + "%"
+		cond    = fmt.Sprintf(`%s LIKE $1`, column)
+	)
+	if field.on {
+		pattern = security.CiphertextPrefix + strconv.Itoa(keyring.Active()) + ":%"
+		cond = fmt.Sprintf(`%s <> '' AND %s NOT LIKE $1`, column, column)
+	}
+
+	rows, err := conn.Query(ctx, fmt.Sprintf(`SELECT guid::TEXT, %s FROM %s WHERE %s LIMIT $2`, column, table, cond), pattern, limit)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error while getting values of %s.%s", field.tableSlug, field.slug)
+	}
+
+	var guids, values []string
+	for rows.Next() {
+		var guid, value string
+		if err := rows.Scan(&guid, &value); err != nil {
+			rows.Close()
+			return 0, errors.Wrap(err, "error while scanning value")
+		}
+		guids = append(guids, guid)
+		values = append(values, value)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, errors.Wrapf(err, "error while getting values of %s.%s", field.tableSlug, field.slug)
+	}
+
+	cipher := helper.NewFieldCipher(field.tableSlug, map[string]bool{field.slug: field.on}, keyring)
+
+	for k, guid := range guids {
+		plain, rewritten, err := reencryptValue(keyring, values[k], field.on)
+		if err != nil {
+			return k, errors.Wrapf(err, "error while re-encrypting %s.%s of %s", field.tableSlug, field.slug, guid)
+		}
+
+		// A value written meanwhile is left to the next run
+		_, err = conn.Exec(ctx, fmt.Sprintf(`UPDATE %s SET %s = $3 WHERE guid::TEXT = $1 AND %s = $2`, table, column, column),
+			guid, values[k], rewritten)
+		if err != nil {
+			return k, errors.Wrapf(err, "error while updating %s.%s", field.tableSlug, field.slug)
+		}
+
+		if !field.on {
+			plain = ""
+		}
+		if err := cipher.IndexValue(ctx, conn, field.slug, guid, plain); err != nil {
+			return k, err
+		}
+	}
+
+	if !field.on && len(guids) < limit {
+		_, err = conn.Exec(ctx, `
+			UPDATE field SET attributes = attributes - 'encrypted'
+			WHERE slug = $2 AND table_id = (SELECT id FROM "table" WHERE slug = $1)`,
+			field.tableSlug, field.slug,
+		)
+		if err != nil {
+			return len(guids), errors.Wrap(err, "error while removing encrypted flag")
+		}
+
+		_, err = conn.Exec(ctx, `DELETE FROM encrypted_field_index WHERE table_slug = $1 AND field_slug = $2`,
+			field.tableSlug, field.slug)
+		if err != nil {
+			return len(guids), errors.Wrap(err, "error while deleting blind index")
+		}
+	}
+
+	return len(guids), nil
+}
+
+// reencryptValue returns the plaintext of a stored value and the value to
+// store instead: encrypted with the active data key when encrypt is set,
+// the plaintext otherwise.
+func reencryptValue(keyring *security.Keyring, value string, encrypt bool) (plain, rewritten string, err error) {
+	if plain, err = keyring.Decrypt(value); err != nil {
+		return "", "", err
+	}
+	if !encrypt {
+		return plain, plain, nil
+	}
+
+	rewritten, err = keyring.Encrypt(plain)
+	return plain, rewritten, err
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	"ucode/ucode_go_object_builder_service/pkg/security"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func testKeyring(t *testing.T, active int) *security.Keyring {
+	keys := make(map[int][]byte)
+	for v := 1; v <= active; v++ {
+		key := make([]byte, security.KeySize)
+		key[0] = byte(v)
+		keys[v] = key
+	}
+	return security.NewKeyring(active, keys, make([]byte, security.KeySize))
+}
+
+func TestFieldCipher(t *testing.T) {
+	cipher := helper.NewFieldCipher("patient", map[string]bool{"passport": true, "notes": false}, testKeyring(t, 1))
+
+	encrypted, err := cipher.Encrypt("passport", "AA1234567")
+	require.NoError(t, err)
+	assert.NotEqual(t, "AA1234567", encrypted)
+
+	again, err := cipher.Encrypt("passport", encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, encrypted, again)
+
+	for _, value := range []any{nil, ""} {
+		kept, err := cipher.Encrypt("passport", value)
+		require.NoError(t, err)
+		assert.Equal(t, value, kept)
+	}
+
+	plain, err := cipher.Encrypt("notes", "left in plaintext")
+	require.NoError(t, err)
+	assert.Equal(t, "left in plaintext", plain)
+
+	notes, err := testKeyring(t, 1).Encrypt("being decrypted")
+	require.NoError(t, err)
+
+	item := map[string]any{"passport": encrypted, "notes": notes, "name": "John"}
+	cipher.DecryptItem(item)
+	assert.Equal(t, map[string]any{"passport": "AA1234567", "notes": "being decrypted", "name": "John"}, item)
+
+	var none *helper.FieldCipher
+	assert.False(t, none.Encrypted("passport"))
+	assert.Equal(t, encrypted, none.Decrypt("passport", encrypted))
+}
+
+func TestReencryptValue(t *testing.T) {
+	var (
+		old     = testKeyring(t, 1)
+		current = testKeyring(t, 2)
+	)
+
+	stored, err := old.Encrypt("AA1234567")
+	require.NoError(t, err)
+
+	plain, rewritten, err := reencryptValue(current, stored, true)
+	require.NoError(t, err)
+	assert.Equal(t, "AA1234567", plain)
+	version, _ := security.KeyVersion(rewritten)
+	assert.Equal(t, 2, version)
+
+	plain, rewritten, err = reencryptValue(current, "AA7654321", true)
+	require.NoError(t, err)
+	assert.Equal(t, "AA7654321", plain)
+	decrypted, err := current.Decrypt(rewritten)
+	require.NoError(t, err)
+	assert.Equal(t, "AA7654321", decrypted)
+
+	plain, rewritten, err = reencryptValue(current, stored, false)
+	require.NoError(t, err)
+	assert.Equal(t, "AA1234567", plain)
+	assert.Equal(t, "AA1234567", rewritten)
+}
+
+func TestValidateEncryption(t *testing.T) {
+	encrypted, err := structpb.NewStruct(map[string]any{"encrypted": true})
+	require.NoError(t, err)
+
+	assert.NoError(t, validateEncryption("SINGLE_LINE", false, encrypted))
+	assert.NoError(t, validateEncryption("NUMBER", false, nil))
+	assert.Equal(t, codes.InvalidArgument, status.Code(validateEncryption("NUMBER", false, encrypted)))
+	assert.Equal(t, codes.InvalidArgument, status.Code(validateEncryption("SINGLE_LINE", true, encrypted)))
+}
@@ -0,0 +1,304 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"ucode/ucode_go_object_builder_service/config"
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/logger"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+// A queued record is retried until it has failed maxRecalcAttempts times;
+// after that it stays in the queue, reported as failed, until it is written
+// again.
+const maxRecalcAttempts = 5
+
+type deferFormulasKey struct{}
+
+// deferFormulas marks ctx so that writes made with it queue their formula
+// recalculation for the background worker instead of running it in the
+// request. Bulk operations use it.
+func deferFormulas(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deferFormulasKey{}, true)
+}
+
+func formulasDeferred(ctx context.Context) bool {
+	deferred, _ := ctx.Value(deferFormulasKey{}).(bool)
+	return deferred
+}
+
+// enqueueFormulaChange queues the written rows, and the parents their old
+// values pointed at, in formula_recalc_queue. Tables no formula reads from
+// are not queued.
+func enqueueFormulaChange(ctx context.Context, q querier, change recordChange) error {
+	if len(change.ids) == 0 {
+		return nil
+	}
+
+	defs, err := loadFormulaDefinitions(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	var (
+		read    bool
+		parents = make(map[string]rowSet)
+	)
+
+	for _, def := range defs {
+		if def.node.Table == change.table {
+			read = true
+		}
+		if def.fieldType != config.FORMULA_FRONT && def.childTable == change.table {
+			read = true
+			for _, old := range change.old {
+				if parents[def.node.Table] == nil {
+					parents[def.node.Table] = rowSet{}
+				}
+				parents[def.node.Table].add(cast.ToString(old[def.relationField]))
+			}
+		}
+	}
+
+	if !read {
+		return nil
+	}
+
+	if err := enqueueRecords(ctx, q, change.table, change.ids); err != nil {
+		return err
+	}
+
+	for table, ids := range parents {
+		if err := enqueueRecords(ctx, q, table, ids.list()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func enqueueRecords(ctx context.Context, q querier, tableSlug string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := q.Exec(ctx, `
+		INSERT INTO formula_recalc_queue (table_slug, record_id)
+		SELECT $1, unnest($2::UUID[])
+		ON CONFLICT (table_slug, record_id) DO UPDATE SET attempts = 0, last_error = NULL`,
+		tableSlug, pq.Array(ids),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to enqueue formula recalculation")
+	}
+
+	return nil
+}
+
+// ProcessRecalculationQueue recalculates up to batchSize queued records of
+// the project, table by table, and removes them from the queue. A record
+// whose recalculation fails is rolled back on its own and retried later.
+// It returns the number of records taken from the queue.
+func (i *itemsRepo) ProcessRecalculationQueue(ctx context.Context, projectId string, batchSize int) (processed int, err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.ProcessRecalculationQueue")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(projectId)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "error while beginning transaction")
+	}
+
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	rows, err := tx.Query(ctx, `
+		SELECT table_slug, record_id::TEXT
+		FROM formula_recalc_queue
+		WHERE attempts < $1
+		ORDER BY created_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`,
+		maxRecalcAttempts, batchSize,
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "error while reading formula queue")
+	}
+
+	var (
+		tables []string
+		queued = make(map[string][]string)
+	)
+
+	for rows.Next() {
+		var tableSlug, recordId string
+		if err := rows.Scan(&tableSlug, &recordId); err != nil {
+			rows.Close()
+			return 0, errors.Wrap(err, "error while scanning formula queue")
+		}
+		if queued[tableSlug] == nil {
+			tables = append(tables, tableSlug)
+		}
+		queued[tableSlug] = append(queued[tableSlug], recordId)
+		processed++
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, errors.Wrap(err, "error while reading formula queue")
+	}
+
+	sort.Strings(tables)
+
+	var recalculated []string
+	for _, tableSlug := range tables {
+		ids := queued[tableSlug]
+
+		updated, failed := recalculateQueuedRows(ctx, tx, tableSlug, ids)
+
+		var done []string
+		for _, id := range ids {
+			recalcErr, ok := failed[id]
+			if !ok {
+				done = append(done, id)
+				continue
+			}
+
+			i.log.Error("error while recalculating queued formulas",
+				logger.String("table_slug", tableSlug),
+				logger.String("record_id", id),
+				logger.Error(recalcErr),
+			)
+
+			_, err = tx.Exec(ctx, `
+				UPDATE formula_recalc_queue SET attempts = attempts + 1, last_error = $3
+				WHERE table_slug = $1 AND record_id = $2::UUID`,
+				tableSlug, id, recalcErr.Error(),
+			)
+			if err != nil {
+				return 0, errors.Wrap(err, "error while updating formula queue")
+			}
+		}
+
+		if len(done) == 0 {
+			continue
+		}
+		recalculated = append(append(recalculated, tableSlug), updated...)
+
+		_, err = tx.Exec(ctx, `
+			DELETE FROM formula_recalc_queue
+			WHERE table_slug = $1 AND record_id = ANY($2::UUID[])`,
+			tableSlug, pq.Array(done),
+		)
+		if err != nil {
+			return 0, errors.Wrap(err, "error while updating formula queue")
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, errors.Wrap(err, "error while committing formula queue")
+	}
+
+	invalidateCache(ctx, projectId, recalculated...)
+
+	return processed, nil
+}
+
+// recalculateQueuedRows recalculates the queued records of a table together
+// and, when that fails, one by one, so a record that cannot be
+// recalculated does not hold back the others. It returns the tables it
+// updated and the errors of the records that failed.
+func recalculateQueuedRows(ctx context.Context, tx pgx.Tx, tableSlug string, ids []string) ([]string, map[string]error) {
+	updated, err := recalculateQueued(ctx, tx, tableSlug, ids)
+	if err == nil {
+		return updated, nil
+	}
+	if len(ids) == 1 {
+		return nil, map[string]error{ids[0]: err}
+	}
+
+	failed := make(map[string]error)
+	for _, id := range ids {
+		tables, err := recalculateQueued(ctx, tx, tableSlug, []string{id})
+		if err != nil {
+			failed[id] = err
+			continue
+		}
+		updated = append(updated, tables...)
+	}
+
+	return updated, failed
+}
+
+// recalculateQueued recalculates the formulas of the records and everything
+// computed from them under a savepoint of tx, and returns the tables it
+// updated.
+func recalculateQueued(ctx context.Context, tx pgx.Tx, tableSlug string, ids []string) ([]string, error) {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create savepoint")
+	}
+
+	tables, err := recalculateFormulas(ctx, savepoint, recordChange{table: tableSlug, ids: ids, refresh: true})
+	if err != nil {
+		_ = savepoint.Rollback(ctx)
+		return nil, err
+	}
+
+	if err := savepoint.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+func (i *itemsRepo) GetRecalculationStatus(ctx context.Context, req *nb.GetRecalculationStatusRequest) (resp *nb.GetRecalculationStatusResponse, err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.GetRecalculationStatus")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return &nb.GetRecalculationStatusResponse{}, err
+	}
+
+	var (
+		oldest    sql.NullString
+		lastError sql.NullString
+	)
+
+	resp = &nb.GetRecalculationStatusResponse{}
+
+	err = conn.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE attempts < $1),
+			COUNT(*) FILTER (WHERE attempts >= $1),
+			TO_CHAR(MIN(created_at) FILTER (WHERE attempts < $1), 'YYYY-MM-DD"T"HH24:MI:SS'),
+			(SELECT last_error FROM formula_recalc_queue
+			 WHERE last_error IS NOT NULL AND ($2 = '' OR table_slug = $2)
+			 ORDER BY created_at DESC LIMIT 1)
+		FROM formula_recalc_queue
+		WHERE $2 = '' OR table_slug = $2`,
+		maxRecalcAttempts, req.GetTableSlug(),
+	).Scan(&resp.Pending, &resp.Failed, &oldest, &lastError)
+	if err != nil {
+		return &nb.GetRecalculationStatusResponse{}, errors.Wrap(err, "error while getting formula queue status")
+	}
+
+	resp.OldestPendingAt = oldest.String
+	resp.LastError = lastError.String
+
+	return resp, nil
+}
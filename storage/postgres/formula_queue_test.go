@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnqueueFormulaChange(t *testing.T) {
+	rollup := fakeResult{
+		match: "f.attributes",
+		rows:  [][]any{{"order", "total", "FORMULA", []byte(`{"table_from": "line#rel", "sum_field": "amount"}`), "order_id"}},
+	}
+
+	tests := []struct {
+		name    string
+		change  recordChange
+		results []fakeResult
+		queued  map[string][]string
+	}{
+		{
+			name:   "no formulas",
+			change: recordChange{table: "line", ids: []string{"l1"}},
+			queued: map[string][]string{},
+		},
+		{
+			name:    "table no formula reads",
+			change:  recordChange{table: "customer", ids: []string{"c1"}},
+			results: []fakeResult{rollup},
+			queued:  map[string][]string{},
+		},
+		{
+			name:    "new rows",
+			change:  recordChange{table: "line", ids: []string{"l1", "l2"}},
+			results: []fakeResult{rollup},
+			queued:  map[string][]string{"line": {"l1", "l2"}},
+		},
+		{
+			name: "old parents",
+			change: recordChange{
+				table: "line",
+				ids:   []string{"l1", "l2"},
+				old:   []map[string]any{{"order_id": "o1"}, {"order_id": nil}},
+			},
+			results: []fakeResult{rollup},
+			queued:  map[string][]string{"line": {"l1", "l2"}, "order": {"o1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{results: tt.results}
+
+			require.NoError(t, enqueueFormulaChange(context.Background(), q, tt.change))
+
+			queued := map[string][]string{}
+			for _, args := range q.execArgs {
+				queued[args[0].(string)] = *args[1].(*pq.StringArray)
+			}
+			assert.Equal(t, tt.queued, queued)
+		})
+	}
+}
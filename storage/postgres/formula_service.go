@@ -5,371 +5,652 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"reflect"
 	"sort"
 	"strings"
 
-	"ucode/ucode_go_object_builder_service/models"
-	"ucode/ucode_go_object_builder_service/pkg/helper"
-	psqlpool "ucode/ucode_go_object_builder_service/pool"
+	"ucode/ucode_go_object_builder_service/config"
+	"ucode/ucode_go_object_builder_service/pkg/formula"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// querier is satisfied by the pool and by a transaction, so the checks and
+// recalculations that follow a write can run inside its transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// FormulaCalculationService recalculates the formulas affected by a write to
+// records of tableSlug. body is the written data and oldData the record
+// before the write; a create has no oldData and a delete has no body.
 type FormulaCalculationService struct {
-	conn      *psqlpool.Pool
 	tableSlug string
 	body      map[string]any
 	oldData   map[string]any
-
-	fields                []models.Field
-	formulaFields         []models.Field
-	formulaFrontendFields []models.Field
 }
 
 type FormulaFilter struct {
-	Key   string `json:"key"`
-	Value any    `json:"value"`
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    any    `json:"value"`
 }
 
 // NewFormulaCalculationService creates a new formula calculation service
-func NewFormulaCalculationService(conn *psqlpool.Pool, tableSlug string, body, oldData map[string]any, fields, formulaFronts []models.Field) *FormulaCalculationService {
-	service := &FormulaCalculationService{
-		conn:                  conn,
-		body:                  body,
-		oldData:               oldData,
-		tableSlug:             tableSlug,
-		fields:                fields,
-		formulaFields:         make([]models.Field, 0),
-		formulaFrontendFields: formulaFronts,
+func NewFormulaCalculationService(tableSlug string, body, oldData map[string]any) *FormulaCalculationService {
+	return &FormulaCalculationService{
+		tableSlug: tableSlug,
+		body:      body,
+		oldData:   oldData,
 	}
+}
+
+// Recalculate updates every formula that depends, directly or through other
+// formulas, on the written records, in dependency order. It returns the
+// tables it updated, whose cached results the caller invalidates once the
+// write is committed.
+func (f *FormulaCalculationService) Recalculate(ctx context.Context, q querier, recordIds ...string) ([]string, error) {
+	return recalculateFormulas(ctx, q, f.change(recordIds))
+}
 
-	// Initialize all fields from the database
-	service.initializeFields()
+func (f *FormulaCalculationService) change(recordIds []string) recordChange {
+	change := recordChange{table: f.tableSlug, ids: recordIds}
 
-	return service
+	if f.body != nil && f.oldData != nil {
+		change.fields = []string{}
+		for slug, value := range f.body {
+			if !reflect.DeepEqual(value, f.oldData[slug]) {
+				change.fields = append(change.fields, slug)
+			}
+		}
+	}
+	if f.oldData != nil {
+		change.old = []map[string]any{f.oldData}
+	}
+
+	return change
 }
 
-// initializeFields loads all fields from the database and caches them
-func (f *FormulaCalculationService) initializeFields() {
-	ctx := context.Background()
-
-	query := `
-		SELECT 
-    		f.id,
-    		f.attributes,
-			f.slug,
-			(SELECT slug FROM "table" WHERE id = f.table_id) AS table_slug,
-    		f2.slug AS relation_field_slug
-		FROM field AS f
-		LEFT JOIN field AS f2 
-    	ON f2.relation_id = split_part(f.attributes->>'table_from', '#', 2)::UUID
-		WHERE split_part(f.attributes->>'table_from', '#', 1) = $1 AND f.type = 'FORMULA';
-
-	`
-
-	rows, err := f.conn.Query(ctx, query, f.tableSlug)
+// RecalculateTx recalculates under a savepoint of tx. If it fails, only the
+// savepoint is rolled back, so the caller can log the error and still commit
+// the write that triggered it. When ctx comes from deferFormulas the
+// records are queued for the background worker instead. It returns the
+// tables it updated, as Recalculate.
+func (f *FormulaCalculationService) RecalculateTx(ctx context.Context, tx pgx.Tx, recordIds ...string) ([]string, error) {
+	savepoint, err := tx.Begin(ctx)
 	if err != nil {
-		return
+		return nil, errors.Wrap(err, "failed to create savepoint")
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var (
-			field      models.Field
-			attributes []byte
-			f2Slug     sql.NullString
-		)
+	var tables []string
+	if formulasDeferred(ctx) {
+		err = enqueueFormulaChange(ctx, savepoint, f.change(recordIds))
+	} else {
+		tables, err = f.Recalculate(ctx, savepoint, recordIds...)
+	}
+	if err != nil {
+		_ = savepoint.Rollback(ctx)
+		return nil, err
+	}
 
-		err := rows.Scan(
-			&field.Id,
-			&attributes,
-			&field.Slug,
-			&field.TableSlug,
-			&f2Slug,
-		)
-		if err != nil {
-			continue
-		}
+	if err := savepoint.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
 
-		field.RelationFieldSlug = f2Slug.String
+// Rollup types of backend formulas.
+const (
+	rollupSum           = "SUMM"
+	rollupAvg           = "AVG"
+	rollupMin           = "MIN"
+	rollupMax           = "MAX"
+	rollupCount         = "COUNT"
+	rollupCountDistinct = "COUNT_DISTINCT"
+	rollupFirst         = "FIRST"
+	rollupLast          = "LAST"
+	rollupStringAgg     = "STRING_AGG"
+)
 
-		if err := json.Unmarshal(attributes, &field.Attributes); err != nil {
-			continue
-		}
+// formulaDefinition is a FORMULA or FORMULA_FRONTEND field and the fields
+// it is computed from.
+type formulaDefinition struct {
+	node       formula.Node
+	fieldType  string
+	attributes map[string]any
+
+	// A FORMULA field rolls sumField up over the rows of childTable whose
+	// relationField points at the row.
+	childTable    string
+	sumField      string
+	relationField string
+	filters       []FormulaFilter
+
+	// A FORMULA_FRONTEND field evaluates source over fields of its own row.
+	source string
+}
 
-		f.formulaFields = append(f.formulaFields, field)
+func newFormulaDefinition(tableSlug, slug, fieldType string, attributes map[string]any, relationField string) formulaDefinition {
+	def := formulaDefinition{
+		node:       formula.Node{Table: tableSlug, Field: slug},
+		fieldType:  fieldType,
+		attributes: attributes,
+	}
 
+	switch fieldType {
+	case config.FORMULA_FRONT:
+		def.source = cast.ToString(attributes["formula"])
+	default:
+		def.childTable = strings.Split(cast.ToString(attributes["table_from"]), "#")[0]
+		def.sumField = cast.ToString(attributes["sum_field"])
+		def.relationField = relationField
+
+		if attributes["formula_filters"] != nil {
+			data, err := json.Marshal(attributes["formula_filters"])
+			if err == nil {
+				_ = json.Unmarshal(data, &def.filters)
+			}
+		}
 	}
+
+	return def
 }
 
-func (f *FormulaCalculationService) CalculateFormulaFields(ctx context.Context, recordId string) error {
-	calculatedValues := make(map[string]any)
+// inputs returns the fields the formula reads.
+func (d formulaDefinition) inputs() []formula.Node {
+	var inputs []formula.Node
 
-	for _, field := range f.formulaFrontendFields {
-		attributes, err := helper.ConvertStructToMap(field.Attributes)
+	if d.fieldType == config.FORMULA_FRONT {
+		program, err := formula.CompileCached(d.source)
 		if err != nil {
-			return errors.Wrap(err, "failed to convert field attributes")
+			return nil
 		}
-
-		value, err := f.calculateFrontendFormula(attributes)
-		if err != nil {
-			return errors.Wrap(err, "failed to calculate frontend formula")
+		for _, ref := range program.References() {
+			inputs = append(inputs, formula.Node{Table: d.node.Table, Field: ref})
 		}
-		calculatedValues[field.Slug] = value
+		return inputs
 	}
 
-	if len(calculatedValues) > 0 {
-		err := f.UpdateRecordWithFormulas(ctx, f.tableSlug, recordId, calculatedValues)
-		if err != nil {
-			log.Println("UpdateRecordWithFormulas", err.Error())
+	if d.childTable == "" {
+		return nil
+	}
+	for _, slug := range []string{d.sumField, d.relationField, d.attributeString("order_by")} {
+		if slug != "" {
+			inputs = append(inputs, formula.Node{Table: d.childTable, Field: slug})
+		}
+	}
+	for _, filter := range d.filters {
+		if key := strings.Split(filter.Key, "#")[0]; key != "" {
+			inputs = append(inputs, formula.Node{Table: d.childTable, Field: key})
 		}
 	}
+	return inputs
+}
 
-	return nil
+func (d formulaDefinition) attributeString(key string) string {
+	return cast.ToString(d.attributes[key])
 }
 
-func (f *FormulaCalculationService) UpdateRecordWithFormulas(ctx context.Context, tableSlug, recordId string, calculatedValues map[string]any) error {
-	if len(calculatedValues) == 0 {
-		return nil
+// isCalculable reports whether a backend formula has what it needs to be
+// calculated. Counting rollups do not need a sum field.
+func (d formulaDefinition) isCalculable() bool {
+	if d.fieldType == config.FORMULA_FRONT {
+		return d.source != ""
+	}
+	if d.childTable == "" || d.relationField == "" {
+		return false
 	}
 
-	var (
-		setClauses []string
-		args       []any
-		argCount   = 1
-	)
+	switch strings.ToUpper(d.attributeString("type")) {
+	case rollupCount, rollupCountDistinct:
+		return true
+	}
+	return d.sumField != ""
+}
 
-	for fieldSlug, value := range calculatedValues {
-		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", fieldSlug, argCount))
-		args = append(args, value)
-		argCount++
+const formulaDefinitionsQuery = `
+	SELECT
+		t.slug,
+		f.slug,
+		f.type,
+		COALESCE(f.attributes, '{}'),
+		COALESCE((
+			SELECT rf.slug FROM field rf
+			JOIN "table" ct ON ct.id = rf.table_id
+			WHERE ct.slug = split_part(f.attributes->>'table_from', '#', 1)
+				AND rf.relation_id::TEXT = split_part(f.attributes->>'table_from', '#', 2)
+			LIMIT 1
+		), '')
+	FROM field f
+	JOIN "table" t ON t.id = f.table_id
+	WHERE f.type IN ('FORMULA', 'FORMULA_FRONTEND')`
+
+func loadFormulaDefinitions(ctx context.Context, q querier) ([]formulaDefinition, error) {
+	rows, err := q.Query(ctx, formulaDefinitionsQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load formula fields")
 	}
+	defer rows.Close()
 
-	args = append(args, recordId)
-	query := fmt.Sprintf(`
-		UPDATE "%s" 
-		SET %s 
-		WHERE guid = $%d`,
-		tableSlug,
-		strings.Join(setClauses, ", "),
-		argCount,
-	)
+	var defs []formulaDefinition
+	for rows.Next() {
+		var (
+			tableSlug, slug, fieldType, relationField string
+			attributes                                []byte
+			attributeMap                              = make(map[string]any)
+		)
 
-	_, err := f.conn.Exec(ctx, query, args...)
-	if err != nil {
-		return errors.Wrap(err, "failed to update record with formula values")
+		if err := rows.Scan(&tableSlug, &slug, &fieldType, &attributes, &relationField); err != nil {
+			return nil, errors.Wrap(err, "failed to scan formula field")
+		}
+		if err := json.Unmarshal(attributes, &attributeMap); err != nil {
+			continue
+		}
+
+		defs = append(defs, newFormulaDefinition(tableSlug, slug, fieldType, attributeMap, relationField))
 	}
 
-	return nil
+	return defs, rows.Err()
 }
 
-func (f *FormulaCalculationService) RecalculateAffectedFormulas(ctx context.Context, changedRecordId string) error {
+func buildFormulaGraph(defs []formulaDefinition) (*formula.Graph, map[formula.Node]formulaDefinition) {
 	var (
-		relationRowId string
+		graph  = formula.NewGraph()
+		byNode = make(map[formula.Node]formulaDefinition, len(defs))
 	)
 
-	for _, formulaField := range f.formulaFields {
-		var (
-			args []any
-		)
-
-		attributes, err := helper.ConvertStructToMap(formulaField.Attributes)
-		if err != nil {
-			return errors.Wrap(err, "error while converting struct to map")
+	for _, def := range defs {
+		byNode[def.node] = def
+		graph.AddNode(def.node)
+		for _, input := range def.inputs() {
+			graph.AddEdge(input, def.node)
 		}
+	}
 
-		tableFrom := cast.ToString(attributes["table_from"])
-		sumField := cast.ToString(attributes["sum_field"])
+	return graph, byNode
+}
 
-		if tableFrom == "" || sumField == "" {
-			return nil
-		}
+// checkFormulaCycles reports whether saving a formula field would make a
+// formula depend on itself. oldSlug is the slug the field had before an
+// update, if it changed.
+func checkFormulaCycles(ctx context.Context, q querier, tableSlug, oldSlug, slug, fieldType string, attributes map[string]any) error {
+	if fieldType != config.FORMULA_FRONT && fieldType != "FORMULA" {
+		return nil
+	}
 
-		if f.body[sumField] == f.oldData[sumField] {
-			continue
+	var relationField string
+	if fieldType == "FORMULA" {
+		tableFrom := strings.Split(cast.ToString(attributes["table_from"]), "#")
+		if len(tableFrom) == 2 {
+			err := q.QueryRow(ctx, `
+				SELECT rf.slug FROM field rf
+				JOIN "table" ct ON ct.id = rf.table_id
+				WHERE ct.slug = $1 AND rf.relation_id::TEXT = $2
+				LIMIT 1`, tableFrom[0], tableFrom[1]).Scan(&relationField)
+			if err != nil && err != pgx.ErrNoRows {
+				return errors.Wrap(err, "failed to find relation field")
+			}
 		}
+	}
 
-		if _, exist := f.body[sumField]; !exist {
-			continue
+	defs, err := loadFormulaDefinitions(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	saved := newFormulaDefinition(tableSlug, slug, fieldType, attributes, relationField)
+	replaced := formula.Node{Table: tableSlug, Field: oldSlug}
+
+	kept := defs[:0]
+	for _, def := range defs {
+		if def.node != saved.node && def.node != replaced {
+			kept = append(kept, def)
 		}
+	}
 
-		if rowId, exist := f.body[formulaField.RelationFieldSlug]; exist {
-			relationRowId = cast.ToString(rowId)
-			num, err := f.calculateBackendFormula(attributes, sumField, relationRowId, formulaField)
-			if err != nil {
-				continue
-			}
+	graph, _ := buildFormulaGraph(append(kept, saved))
+	if _, err := graph.Sort(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return nil
+}
 
-			args = append(args, relationRowId, num)
+// recordChange describes a write to rows of a table: the fields it touched,
+// nil meaning all of them, and the rows as they were before, so rollups of
+// the parents the rows pointed at are recalculated too. refresh also
+// recalculates the rollups defined on the rows themselves.
+type recordChange struct {
+	table   string
+	ids     []string
+	fields  []string
+	old     []map[string]any
+	refresh bool
+}
 
-			query := fmt.Sprintf(`
-				UPDATE "%s" 
-					SET "%s" = $2
-				WHERE guid = $1`,
-				formulaField.TableSlug,
-				formulaField.Slug,
-			)
+type rowSet map[string]struct{}
 
-			_, err = f.conn.Exec(ctx, query, args...)
-			if err != nil {
-				return errors.Wrap(err, "failed to update record with formula values")
-			}
+func (s rowSet) add(ids ...string) {
+	for _, id := range ids {
+		if id != "" {
+			s[id] = struct{}{}
 		}
 	}
+}
 
-	return nil
+func (s rowSet) list() []string {
+	ids := make([]string, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	return ids
 }
 
-func (f *FormulaCalculationService) RecalculateAffectedFormulasDelete(ctx context.Context, tableSlug, changedRecordId string, changedField string) error {
+// recalculateFormulas walks the formulas in dependency order. A formula is
+// recalculated for the rows whose inputs changed, and then counts as changed
+// for those rows itself, so the change cascades through formulas of formulas
+// and rollups of rollups. It returns the tables whose rows it updated.
+func recalculateFormulas(ctx context.Context, q querier, change recordChange) ([]string, error) {
+	defs, err := loadFormulaDefinitions(ctx, q)
+	if err != nil || len(defs) == 0 {
+		return nil, err
+	}
+
+	graph, byNode := buildFormulaGraph(defs)
+
+	order, err := graph.Sort()
+	if err != nil {
+		return nil, err
+	}
+
 	var (
-		args          []any
-		relationRowId string
+		dirty    = make(map[formula.Node]rowSet)
+		wildcard = formula.Node{Table: change.table, Field: "*"}
+		updated  = rowSet{}
 	)
 
-	for _, formulaField := range f.formulaFields {
-		attributes, err := helper.ConvertStructToMap(formulaField.Attributes)
-		if err != nil {
-			return errors.Wrap(err, "error while converting struct to map")
+	mark := func(n formula.Node, ids ...string) {
+		if dirty[n] == nil {
+			dirty[n] = rowSet{}
 		}
+		dirty[n].add(ids...)
+	}
 
-		tableFrom := cast.ToString(attributes["table_from"])
-		sumField := cast.ToString(attributes["sum_field"])
+	if change.fields == nil {
+		mark(wildcard, change.ids...)
+	}
+	for _, field := range change.fields {
+		mark(formula.Node{Table: change.table, Field: field}, change.ids...)
+	}
 
-		if tableFrom == "" || sumField == "" {
-			return nil
+	for _, n := range order {
+		def, ok := byNode[n]
+		if !ok || !def.isCalculable() {
+			continue
 		}
 
-		if rowId, exist := f.oldData[formulaField.RelationFieldSlug]; exist {
-			relationRowId = cast.ToString(rowId)
-			num, err := f.calculateBackendFormula(attributes, sumField, relationRowId, formulaField)
-			if err != nil {
-				continue
+		rows := rowSet{}
+		for _, input := range def.inputs() {
+			for id := range dirty[input] {
+				rows.add(id)
 			}
+		}
 
-			args = append(args, relationRowId, num)
+		switch def.fieldType {
+		case config.FORMULA_FRONT:
+			if def.node.Table == change.table {
+				rows.add(dirty[wildcard].list()...)
+			}
+			if len(rows) == 0 {
+				continue
+			}
+			if err := recalculateFrontendFormula(ctx, q, def, rows.list()); err != nil {
+				return nil, err
+			}
+		default:
+			if def.childTable == change.table {
+				rows.add(dirty[wildcard].list()...)
+			}
 
-			query := fmt.Sprintf(`
-				UPDATE "%s" 
-					SET "%s" = $2
-				WHERE guid = $1`,
-				formulaField.TableSlug,
-				formulaField.Slug,
-			)
+			parents := rowSet{}
+			if len(rows) > 0 {
+				ids, err := rollupParents(ctx, q, def, rows.list())
+				if err != nil {
+					return nil, err
+				}
+				parents.add(ids...)
+			}
+			if def.childTable == change.table {
+				for _, old := range change.old {
+					parents.add(cast.ToString(old[def.relationField]))
+				}
+			}
+			if change.refresh && def.node.Table == change.table {
+				parents.add(change.ids...)
+			}
 
-			_, err = f.conn.Exec(ctx, query, args...)
-			if err != nil {
-				return errors.Wrap(err, "failed to update record with formula values")
+			if rows = parents; len(rows) == 0 {
+				continue
+			}
+			if err := recalculateRollup(ctx, q, def, rows.list()); err != nil {
+				return nil, err
 			}
 		}
+
+		mark(n, rows.list()...)
+		updated.add(n.Table)
 	}
 
-	return nil
+	tables := updated.list()
+	sort.Strings(tables)
+
+	return tables, nil
 }
 
-// calculateBackendFormula calculates a backend formula value
-func (f *FormulaCalculationService) calculateBackendFormula(attributes map[string]any, sumField, recordId string, field models.Field) (float32, error) {
+func recalculateFrontendFormula(ctx context.Context, q querier, def formulaDefinition, ids []string) error {
+	query := fmt.Sprintf(`SELECT guid::TEXT, row_to_json(t) FROM %s t WHERE guid = ANY($1::UUID[])`, pq.QuoteIdentifier(def.node.Table))
 
-	resp, err := f.CalculateFormulaBackend(attributes, sumField, recordId, field)
+	rows, err := q.Query(ctx, query, pq.Array(ids))
 	if err != nil {
-		return 0, errors.Wrap(err, "failed to calculate formula backend")
-	}
-
-	return resp, nil
-}
-
-// calculateFrontendFormula calculates a frontend formula value
-func (f *FormulaCalculationService) calculateFrontendFormula(attributes map[string]any) (any, error) {
-	_, ok := attributes["formula"]
-	if !ok {
-		return nil, nil
+		return errors.Wrap(err, "failed to load records for formula")
 	}
+	defer rows.Close()
 
-	return f.CalculateFormulaFrontend(attributes)
-}
-
-func (f *FormulaCalculationService) CalculateFormulaBackend(attributes map[string]any, sumField, rowId string, field models.Field) (float32, error) {
 	var (
-		query string
-
-		formulaFilter []FormulaFilter
-		num           float32
+		guids  []string
+		values []sql.NullString
 	)
+	for rows.Next() {
+		var (
+			guid string
+			data []byte
+			vars = make(map[string]any)
+		)
 
-	round := cast.ToInt(attributes["number_of_rounds"])
-
-	if attributes["formula_filters"] != nil {
-		formulaFilterByte, err := json.Marshal(attributes["formula_filters"])
-		if err != nil {
-			return 0, errors.Wrap(err, "CalculateFormulaBackend - json.Marshal")
+		if err := rows.Scan(&guid, &data); err != nil {
+			return errors.Wrap(err, "failed to scan record for formula")
+		}
+		if err := json.Unmarshal(data, &vars); err != nil {
+			return errors.Wrap(err, "failed to decode record for formula")
 		}
 
-		err = json.Unmarshal(formulaFilterByte, &formulaFilter)
+		value, err := formula.Evaluate(ctx, def.source, vars)
 		if err != nil {
-			return 0, errors.Wrap(err, "CalculateFormulaBackend - json.Unmarshal")
+			return errors.Wrapf(err, "failed to evaluate %s", def.node)
 		}
-	}
 
-	if sumField == "" {
-		sumField = "1"
+		guids = append(guids, guid)
+		values = append(values, sql.NullString{String: value, Valid: true})
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "failed to load records for formula")
 	}
 
-	formulaFilter = append(formulaFilter, FormulaFilter{Key: field.RelationFieldSlug, Value: rowId})
+	return updateFormulaValues(ctx, q, def, guids, values)
+}
 
-	whereClause, params, _ := buildWhereClause(formulaFilter)
+func rollupParents(ctx context.Context, q querier, def formulaDefinition, childIds []string) ([]string, error) {
+	query := fmt.Sprintf(`SELECT DISTINCT %[2]s::TEXT FROM %[1]s WHERE guid = ANY($1::UUID[]) AND %[2]s IS NOT NULL`,
+		pq.QuoteIdentifier(def.childTable), pq.QuoteIdentifier(def.relationField))
 
-	switch cast.ToString(attributes["type"]) {
-	case "SUMM":
-		query = fmt.Sprintf(`SELECT SUM(%s) FROM "%s" WHERE deleted_at IS NULL %s GROUP BY %s`, sumField, f.tableSlug, whereClause, field.RelationFieldSlug)
-	case "MAX":
-		query = fmt.Sprintf(`SELECT MAX(%s) FROM "%s" WHERE deleted_at IS NULL %s GROUP BY %s`, sumField, f.tableSlug, whereClause, field.RelationFieldSlug)
-	case "AVG":
-		query = fmt.Sprintf(`SELECT AVG(%s) FROM "%s" WHERE deleted_at IS NULL %s GROUP BY %s`, sumField, f.tableSlug, whereClause, field.RelationFieldSlug)
+	rows, err := q.Query(ctx, query, pq.Array(childIds))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find rollup parents")
 	}
+	defer rows.Close()
 
-	err := f.conn.QueryRow(context.Background(), query, params...).Scan(&num)
+	var parents []string
+	for rows.Next() {
+		var parent string
+		if err := rows.Scan(&parent); err != nil {
+			return nil, errors.Wrap(err, "failed to scan rollup parent")
+		}
+		parents = append(parents, parent)
+	}
+
+	return parents, rows.Err()
+}
+
+// recalculateRollup aggregates sumField over the child rows of each parent.
+// Numeric rollups are computed as numeric and kept as exact decimal text,
+// rounded to a positive number_of_rounds digits with rounding_mode. FIRST
+// and LAST pick by order_by (created_at by default) and STRING_AGG joins
+// with separator. A rollup over no rows is 0 for SUMM and the counts, and
+// NULL otherwise.
+func recalculateRollup(ctx context.Context, q querier, def formulaDefinition, parents []string) error {
+	var (
+		rollup   = strings.ToUpper(def.attributeString("type"))
+		relation = pq.QuoteIdentifier(def.relationField)
+		orderBy  = def.attributeString("order_by")
+		empty    sql.NullString
+	)
+
+	whereClause, params, err := buildWhereClause(def.filters)
 	if err != nil {
-		return 0, errors.Wrap(err, "CalculateFormulaBackend - conn.Query")
+		return errors.Wrapf(err, "invalid filters of %s", def.node)
 	}
 
-	if round > 0 {
-		format := "%." + fmt.Sprint(round) + "f"
-		num = cast.ToFloat32(fmt.Sprintf(format, num))
+	column := "1"
+	if def.sumField != "" {
+		column = pq.QuoteIdentifier(def.sumField)
+	}
+	if orderBy == "" {
+		orderBy = "created_at"
+	}
+	order := pq.QuoteIdentifier(orderBy) + ", guid"
+
+	var aggregate string
+	switch rollup {
+	case rollupSum, "SUM":
+		aggregate = fmt.Sprintf(`SUM(%s::NUMERIC)`, column)
+		empty = sql.NullString{String: "0", Valid: true}
+	case rollupAvg:
+		aggregate = fmt.Sprintf(`AVG(%s::NUMERIC)`, column)
+	case rollupMin:
+		aggregate = fmt.Sprintf(`MIN(%s::NUMERIC)`, column)
+	case rollupMax:
+		aggregate = fmt.Sprintf(`MAX(%s::NUMERIC)`, column)
+	case rollupCount:
+		aggregate = fmt.Sprintf(`COUNT(%s)`, column)
+		empty = sql.NullString{String: "0", Valid: true}
+	case rollupCountDistinct:
+		aggregate = fmt.Sprintf(`COUNT(DISTINCT %s)`, column)
+		empty = sql.NullString{String: "0", Valid: true}
+	case rollupFirst:
+		aggregate = fmt.Sprintf(`(ARRAY_AGG(%s ORDER BY %s))[1]`, column, order)
+	case rollupLast:
+		aggregate = fmt.Sprintf(`(ARRAY_AGG(%s ORDER BY %s DESC))[1]`, column, strings.ReplaceAll(order, ",", " DESC,"))
+	case rollupStringAgg:
+		separator := ", "
+		if value, ok := def.attributes["separator"]; ok {
+			separator = cast.ToString(value)
+		}
+		params = append(params, separator)
+		aggregate = fmt.Sprintf(`STRING_AGG(%s::TEXT, $%d ORDER BY %s)`, column, len(params), order)
+	default:
+		return fmt.Errorf("unsupported formula type %q of %s", rollup, def.node)
 	}
 
-	return num, nil
-}
+	params = append(params, pq.Array(parents))
+	query := fmt.Sprintf(`SELECT %[1]s::TEXT, (%[2]s)::TEXT FROM %[3]s WHERE deleted_at IS NULL AND %[1]s = ANY($%[4]d::UUID[]) %[5]s GROUP BY %[1]s`,
+		relation, aggregate, pq.QuoteIdentifier(def.childTable), len(params), whereClause)
 
-func (f *FormulaCalculationService) CalculateFormulaFrontend(attributes map[string]any) (any, error) {
-	computedFormula := attributes["formula"].(string)
+	rows, err := q.Query(ctx, query, params...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to calculate %s", def.node)
+	}
+	defer rows.Close()
 
-	sort.Slice(f.fields, func(i, j int) bool {
-		return len(f.fields[i].Slug) > len(f.fields[j].Slug)
-	})
+	results := make(map[string]sql.NullString, len(parents))
+	for rows.Next() {
+		var (
+			parent string
+			value  sql.NullString
+		)
+		if err := rows.Scan(&parent, &value); err != nil {
+			return errors.Wrapf(err, "failed to scan %s", def.node)
+		}
+		results[parent] = value
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrapf(err, "failed to calculate %s", def.node)
+	}
 
-	for _, el := range f.fields {
-		value, ok := f.body[el.Slug]
+	values := make([]sql.NullString, len(parents))
+	for i, parent := range parents {
+		value, ok := results[parent]
 		if !ok {
-			value = 0
+			value = empty
 		}
 
-		valBytes, err := json.Marshal(value)
-		if err != nil {
-			valBytes = []byte(fmt.Sprintf(`"%v"`, value))
+		if round := cast.ToInt(def.attributes["number_of_rounds"]); value.Valid && round > 0 && rollup != rollupStringAgg {
+			if rounded, err := formula.RoundDecimal(value.String, round, def.attributeString("rounding_mode")); err == nil {
+				value.String = rounded
+			}
 		}
+		values[i] = value
+	}
 
-		computedFormula = strings.ReplaceAll(computedFormula, el.Slug, string(valBytes))
+	return updateFormulaValues(ctx, q, def, parents, values)
+}
+
+// updateFormulaValues stores the values of a formula field in one statement.
+// Rollups other than STRING_AGG have NUMERIC columns and are cast to
+// numeric, so the exact decimal text is stored as is.
+func updateFormulaValues(ctx context.Context, q querier, def formulaDefinition, guids []string, values []sql.NullString) error {
+	if len(guids) == 0 {
+		return nil
 	}
 
-	result, err := helper.CallJS(computedFormula)
-	if err != nil {
-		return nil, err
+	value := "v.value"
+	if def.fieldType != config.FORMULA_FRONT && !strings.EqualFold(def.attributeString("type"), rollupStringAgg) {
+		value = "v.value::NUMERIC"
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %[1]s AS t SET %[2]s = %[3]s
+		FROM (SELECT unnest($1::UUID[]) AS guid, unnest($2::TEXT[]) AS value) AS v
+		WHERE t.guid = v.guid`,
+		pq.QuoteIdentifier(def.node.Table), pq.QuoteIdentifier(def.node.Field), value)
+
+	if _, err := q.Exec(ctx, query, pq.Array(guids), pq.Array(values)); err != nil {
+		return errors.Wrapf(err, "failed to update %s", def.node)
 	}
 
-	return result, nil
+	return nil
 }
 
+// buildWhereClause turns formula filters into AND-ed conditions. operator is
+// one of =, !=, <>, >, >=, <, <=, in, not_in, like, ilike, between, is_null
+// and is_not_null; without one, a list value means in and anything else =.
 func buildWhereClause(filters []FormulaFilter) (string, []any, error) {
 	var (
 		whereClauses []string
@@ -380,30 +661,72 @@ func buildWhereClause(filters []FormulaFilter) (string, []any, error) {
 		return "", params, nil
 	}
 
+	placeholder := func(value any) string {
+		params = append(params, value)
+		return fmt.Sprintf("$%d", len(params))
+	}
+
 	for i, filter := range filters {
 		keyParts := strings.Split(filter.Key, "#")
-		if len(keyParts) == 0 {
+		if keyParts[0] == "" {
 			return "", nil, fmt.Errorf("invalid filter key at index %d", i)
 		}
-		field := keyParts[0]
+		field := pq.QuoteIdentifier(keyParts[0])
 
-		switch v := filter.Value.(type) {
-		case []any:
-			if len(v) == 0 {
+		list, isList := filter.Value.([]any)
+
+		operator := strings.ToLower(strings.TrimSpace(filter.Operator))
+		if operator == "" {
+			operator = "="
+			if isList {
+				operator = "in"
+			}
+		}
+
+		switch operator {
+		case "=", "!=", "<>", ">", ">=", "<", "<=":
+			if operator == "!=" {
+				operator = "<>"
+			}
+			whereClauses = append(whereClauses, fmt.Sprintf("%s %s %s", field, operator, placeholder(filter.Value)))
+		case "like", "ilike":
+			whereClauses = append(whereClauses, fmt.Sprintf("%s::TEXT %s %s", field, strings.ToUpper(operator), placeholder(cast.ToString(filter.Value))))
+		case "in", "not_in":
+			if !isList {
+				list = []any{filter.Value}
+			}
+			if len(list) == 0 {
+				if operator == "in" {
+					whereClauses = append(whereClauses, "FALSE")
+				}
 				continue
 			}
-			placeholders := make([]string, len(v))
-			for i := range v {
-				placeholders[i] = fmt.Sprintf("$%d", len(params)+1+i)
+			placeholders := make([]string, len(list))
+			for i := range list {
+				placeholders[i] = placeholder(list[i])
 			}
-			params = append(params, v...)
-			whereClauses = append(whereClauses, fmt.Sprintf("%s IN (%s)", field, strings.Join(placeholders, ",")))
+			keyword := "IN"
+			if operator == "not_in" {
+				keyword = "NOT IN"
+			}
+			whereClauses = append(whereClauses, fmt.Sprintf("%s %s (%s)", field, keyword, strings.Join(placeholders, ",")))
+		case "between":
+			if len(list) != 2 {
+				return "", nil, fmt.Errorf("filter %q: between needs two values", filter.Key)
+			}
+			whereClauses = append(whereClauses, fmt.Sprintf("%s BETWEEN %s AND %s", field, placeholder(list[0]), placeholder(list[1])))
+		case "is_null":
+			whereClauses = append(whereClauses, field+" IS NULL")
+		case "is_not_null":
+			whereClauses = append(whereClauses, field+" IS NOT NULL")
 		default:
-			// Handle single value
-			params = append(params, v)
-			whereClauses = append(whereClauses, fmt.Sprintf("%s = $%d", field, len(params)))
+			return "", nil, fmt.Errorf("filter %q: unsupported operator %q", filter.Key, filter.Operator)
 		}
 	}
 
+	if len(whereClauses) == 0 {
+		return "", params, nil
+	}
+
 	return fmt.Sprintf(" AND %s", strings.Join(whereClauses, " AND ")), params, nil
 }
@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestBuildInnerGroupQueryWithRelatedData(t *testing.T) {
+	var (
+		o        = &objectBuilderRepo{}
+		types    = map[string]string{"status": "SINGLE_LINE", "author_id": "LOOKUP", "orphan_id": "LOOKUP"}
+		relation = map[string]string{"author_id": "user"}
+	)
+
+	tests := []struct {
+		name      string
+		fields    []string
+		rowFilter string
+		want      string
+	}{
+		{
+			name:   "plain fields",
+			fields: []string{"status"},
+			want:   `SELECT "post".status, jsonb_agg(jsonb_build_object( 'status', "post".status)) as data FROM "post" GROUP BY "post".status`,
+		},
+		{
+			name:   "lookups are joined",
+			fields: []string{"status", "author_id"},
+			want: `SELECT "post".status, jsonb_agg(jsonb_build_object( 'status', "post".status, 'author_id', "post".author_id, ` +
+				`'author_id_data', CASE WHEN rel_1.guid IS NULL THEN NULL ELSE row_to_json(rel_1) END)) as data ` +
+				`FROM "post" LEFT JOIN "user" rel_1 ON rel_1.guid = "post".author_id GROUP BY "post".status`,
+		},
+		{
+			name:   "lookups without a relation",
+			fields: []string{"status", "orphan_id"},
+			want:   `SELECT "post".status, jsonb_agg(jsonb_build_object( 'status', "post".status, 'orphan_id', "post".orphan_id)) as data FROM "post" GROUP BY "post".status`,
+		},
+		{
+			name:      "row filter",
+			fields:    []string{"status"},
+			rowFilter: ` AND "post".owner_id = 'u' `,
+			want:      `SELECT "post".status, jsonb_agg(jsonb_build_object( 'status', "post".status)) as data FROM "post" WHERE TRUE AND "post".owner_id = 'u' GROUP BY "post".status`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := o.buildInnerGroupQueryWithRelatedData(nil, "post", []string{"status"}, tt.fields, types, relation, tt.rowFilter)
+			assert.Equal(t, tt.want, query)
+			assert.NotContains(t, query, "(SELECT")
+		})
+	}
+}
+
+func TestApplyGroupAccess(t *testing.T) {
+	var (
+		last4    = authz.MaskLast4.Apply("555123456", nil)
+		accesses = map[string]*helper.FieldAccess{
+			"post": {
+				Hidden: map[string]bool{"salary": true},
+				Masks:  map[string]authz.Mask{"phone": authz.MaskLast4},
+			},
+			"user": {Masks: map[string]authz.Mask{"email": authz.MaskEmailDomain}},
+		}
+		row = func() map[string]any {
+			return map[string]any{
+				"guid":           "g",
+				"phone":          "555123456",
+				"salary":         100,
+				"author_id":      "u",
+				"author_id_data": map[string]any{"guid": "u", "email": "a@b.com"},
+			}
+		}
+		shown = map[string]any{
+			"guid":           "g",
+			"phone":          last4,
+			"author_id":      "u",
+			"author_id_data": map[string]any{"guid": "u", "email": authz.MaskEmailDomain.Apply("a@b.com", nil)},
+		}
+	)
+
+	tests := []struct {
+		name     string
+		response []any
+		levels   int
+		want     []any
+	}{
+		{
+			name:     "one level",
+			response: []any{map[string]any{"salary": 100, "data": []any{row()}}},
+			levels:   1,
+			want:     []any{map[string]any{"salary": nil, "data": []any{shown}}},
+		},
+		{
+			name: "nested levels",
+			response: []any{map[string]any{"status": "open", "data": []any{
+				map[string]any{"phone": "555123456", "data": []any{row()}},
+			}}},
+			levels: 2,
+			want: []any{map[string]any{"status": "open", "data": []any{
+				map[string]any{"phone": last4, "data": []any{shown}},
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := structpb.NewStruct(map[string]any{"response": tt.response})
+			require.NoError(t, err)
+
+			want, err := structpb.NewStruct(map[string]any{"response": tt.want})
+			require.NoError(t, err)
+
+			got, err := applyGroupAccess(result, "post", tt.levels, map[string]string{"author_id": "user", "status": ""}, func(table string) (*helper.FieldAccess, error) {
+				return accesses[table], nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, want.AsMap(), got.AsMap())
+		})
+	}
+}
+
+func TestApplyGroupAccessDecrypts(t *testing.T) {
+	var (
+		cipher = helper.NewFieldCipher("patient", map[string]bool{"passport": true}, testKeyring(t, 1))
+		sealed = helper.NewFieldAccess(nil, map[string]bool{"passport": true})
+		hidden = authz.MaskFull.Apply("AA1234567", nil)
+	)
+	sealed.Cipher = cipher
+
+	encrypt := func() string {
+		value, err := cipher.Encrypt("passport", "AA1234567")
+		require.NoError(t, err)
+		return value.(string)
+	}
+	groups := func() []any {
+		first, second := encrypt(), encrypt()
+		require.NotEqual(t, first, second)
+		return []any{
+			map[string]any{"passport": first, "data": []any{map[string]any{"guid": "a", "passport": first}}},
+			map[string]any{"passport": second, "data": []any{map[string]any{"guid": "b", "passport": second}}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		access *helper.FieldAccess
+		value  any
+	}{
+		{name: "decrypted", access: &helper.FieldAccess{Cipher: cipher}, value: "AA1234567"},
+		{name: "without the decrypt permission", access: sealed, value: hidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := structpb.NewStruct(map[string]any{"response": groups()})
+			require.NoError(t, err)
+
+			got, err := applyGroupAccess(result, "patient", 1, nil, func(string) (*helper.FieldAccess, error) { return tt.access, nil })
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"response": []any{map[string]any{"passport": tt.value, "data": []any{
+				map[string]any{"guid": "a", "passport": tt.value},
+				map[string]any{"guid": "b", "passport": tt.value},
+			}}}}, got.AsMap())
+		})
+	}
+}
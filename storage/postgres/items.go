@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"ucode/ucode_go_object_builder_service/genproto/transcoder_service"
 	"ucode/ucode_go_object_builder_service/grpc/client"
 	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
 	"ucode/ucode_go_object_builder_service/pkg/helper"
 	"ucode/ucode_go_object_builder_service/pkg/logger"
 	"ucode/ucode_go_object_builder_service/pkg/person"
@@ -23,23 +25,27 @@ import (
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
 	"ucode/ucode_go_object_builder_service/storage"
 
-	structpb "github.com/golang/protobuf/ptypes/struct"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/lib/pq"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type itemsRepo struct {
 	db         *psqlpool.Pool
 	grpcClient client.ServiceManagerI
 	log        logger.LoggerI
+	cfg        config.Config
 }
 
-func NewItemsRepo(db *psqlpool.Pool, grpcClient client.ServiceManagerI, log logger.LoggerI) storage.ItemsRepoI {
+func NewItemsRepo(db *psqlpool.Pool, cfg config.Config, grpcClient client.ServiceManagerI, log logger.LoggerI) storage.ItemsRepoI {
 	return &itemsRepo{
 		db:         db,
+		cfg:        cfg,
 		grpcClient: grpcClient,
 		log:        log,
 	}
@@ -49,6 +55,14 @@ func (i *itemsRepo) Create(ctx context.Context, req *nb.CommonMessage) (resp *nb
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.Create")
 	defer dbSpan.Finish()
 
+	var formulaTables []string
+
+	defer func() {
+		if err == nil {
+			invalidateCache(ctx, req.GetProjectId(), append(formulaTables, req.GetTableSlug())...)
+		}
+	}()
+
 	var (
 		fieldM          = make(map[string]models.FieldBody)
 		tableData       = models.Table{}
@@ -61,7 +75,6 @@ func (i *itemsRepo) Create(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		isSystemTable   sql.NullBool
 		authInfo        models.AuthInfo
 		tableAttributes models.TableAttributes
-		formulaFronts   []models.Field
 	)
 
 	conn, err := psqlpool.Get(req.GetProjectId())
@@ -83,6 +96,12 @@ func (i *itemsRepo) Create(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		return &nb.CommonMessage{}, errors.Wrap(err, "error marshalling request data")
 	}
 
+	permissions, err := authorizeWrite(ctx, tx, req.TableSlug, authz.Create, body)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	permissions.Strip(body)
+
 	query = `SELECT id, slug, is_login_table, attributes FROM "table" WHERE slug = $1 `
 
 	err = tx.QueryRow(ctx, query, req.TableSlug).Scan(&tableData.Id, &tableData.Slug, &tableData.IsLoginTable, &attr)
@@ -247,10 +266,6 @@ func (i *itemsRepo) Create(ctx context.Context, req *nb.CommonMessage) (resp *nb
 			}
 		}
 
-		if field.Type == config.FORMULA_FRONT {
-			formulaFronts = append(formulaFronts, field)
-		}
-
 		field.AutofillField = autoFillField.String
 		field.AutofillTable = autoFillTable.String
 		field.RelationId = relationId.String
@@ -258,15 +273,30 @@ func (i *itemsRepo) Create(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		fields = append(fields, field)
 	}
 
-	data, appendMany2Many, err := helper.PrepareToCreateInObjectBuilder(ctx, tx, req, models.CreateBody{
+	createBody := models.CreateBody{
 		FieldMap:   fieldM,
 		Fields:     fields,
 		TableSlugs: tableSlugs,
-	})
+	}
+
+	// Passwords of login table rows follow the policy of their client type
+	var loginPolicy security.LoginPolicy
+	if tableData.IsLoginTable && !cast.ToBool(body["from_auth_service"]) {
+		if loginPolicy, err = helper.LoadLoginPolicy(ctx, tx, cast.ToString(body[config.CLIENT_TYPE_ID])); err != nil {
+			return &nb.CommonMessage{}, err
+		}
+		createBody.ValidatePassword = loginPolicy.Password.Validate
+	}
+
+	data, appendMany2Many, err := helper.PrepareToCreateInObjectBuilder(ctx, tx, req, createBody)
 	if err != nil {
 		return &nb.CommonMessage{}, i.db.HandleDatabaseError(err, "Items Create: error while preparing")
 	}
 
+	if err := validateDynamicLinks(ctx, tx, req.TableSlug, data); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	query = fmt.Sprintf(`INSERT INTO "%s" (guid`, req.TableSlug)
 	valQuery = ") VALUES ($1,"
 
@@ -276,6 +306,15 @@ func (i *itemsRepo) Create(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		guid = uuid.NewString()
 	}
 
+	if err := validateTreeParent(ctx, tx, req.TableSlug, guid, data); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	cipher, err := helper.LoadFieldCipher(ctx, tx, req.TableSlug)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	args = append(args, guid)
 
 	for _, field := range fields {
@@ -335,6 +374,10 @@ func (i *itemsRepo) Create(ctx context.Context, req *nb.CommonMessage) (resp *nb
 					}
 				}
 
+				if val, err = cipher.Encrypt(fieldSlug, val); err != nil {
+					return &nb.CommonMessage{}, err
+				}
+
 				query += fmt.Sprintf(", %s", fieldSlug)
 				args = append(args, val)
 				if argCount != 2 {
@@ -358,6 +401,10 @@ func (i *itemsRepo) Create(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		return &nb.CommonMessage{}, i.db.HandleDatabaseError(err, "Items Create: error while inserting")
 	}
 
+	if err := cipher.IndexRow(ctx, tx, guid, data); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	if tableData.IsLoginTable && !cast.ToBool(data["from_auth_service"]) {
 		if err := json.Unmarshal(attr, &tableAttributes); err != nil {
 			return &nb.CommonMessage{}, errors.Wrap(err, "error while unmarshalling attributes")
@@ -365,6 +412,12 @@ func (i *itemsRepo) Create(ctx context.Context, req *nb.CommonMessage) (resp *nb
 
 		authInfo = tableAttributes.AuthInfo
 
+		if hash := cast.ToString(data[authInfo.Password]); authInfo.Password != "" && len(hash) == config.BcryptHashPasswordLength {
+			if err := helper.RecordPassword(ctx, tx, loginPolicy.Password, req.TableSlug, guid, hash); err != nil {
+				return &nb.CommonMessage{}, err
+			}
+		}
+
 		var (
 			count         = 0
 			loginStrategy = cast.ToStringSlice(authInfo.LoginStrategy)
@@ -537,23 +590,19 @@ func (i *itemsRepo) Create(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while converting map to struct")
 	}
 
-	if err = tx.Commit(ctx); err != nil {
-		return &nb.CommonMessage{}, errors.Wrap(err, "error while committing")
+	if tables, err := NewFormulaCalculationService(req.TableSlug, body, nil).RecalculateTx(ctx, tx, guid); err != nil {
+		i.log.Error("error while recalculating formulas in CREATE", logger.Error(err))
+	} else {
+		formulaTables = tables
 	}
 
-	go func() {
-		formulaService := NewFormulaCalculationService(conn, req.TableSlug, body, nil, fields, formulaFronts)
-
-		err = formulaService.CalculateFormulaFields(context.Background(), guid)
-		if err != nil {
-			i.log.Error("error CalculateFormulaFrontendFields in CREATE", logger.Error(err))
-		}
+	if err := checkRowAccess(ctx, tx, req.TableSlug, authz.Resolve(ctx, body), authz.Create, guid); err != nil {
+		return &nb.CommonMessage{}, err
+	}
 
-		err = formulaService.RecalculateAffectedFormulas(context.Background(), guid)
-		if err != nil {
-			i.log.Error("error CalculateFormulaBackendFields in CREATE", logger.Error(err))
-		}
-	}()
+	if err = tx.Commit(ctx); err != nil {
+		return &nb.CommonMessage{}, errors.Wrap(err, "error while committing")
+	}
 
 	return &nb.CommonMessage{
 		TableSlug: req.TableSlug,
@@ -565,14 +614,21 @@ func (i *itemsRepo) Update(ctx context.Context, req *nb.CommonMessage) (resp *nb
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.Update")
 	defer dbSpan.Finish()
 
+	var formulaTables []string
+
+	defer func() {
+		if err == nil {
+			invalidateCache(ctx, req.GetProjectId(), append(formulaTables, req.GetTableSlug())...)
+		}
+	}()
+
 	var (
-		argCount              = 2
-		args                  = []any{}
-		attr                  = []byte{}
-		guid                  string
-		isLoginTable          bool
-		tableAttributes       models.TableAttributes
-		formulaFronts, fields []models.Field
+		argCount        = 2
+		args            = []any{}
+		attr            = []byte{}
+		guid            string
+		isLoginTable    bool
+		tableAttributes models.TableAttributes
 	)
 
 	conn, err := psqlpool.Get(req.GetProjectId())
@@ -594,12 +650,30 @@ func (i *itemsRepo) Update(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		return &nb.CommonMessage{}, i.db.HandleDatabaseError(err, "Items Update: error while preparing")
 	}
 
+	permissions, err := authorizeWrite(ctx, tx, req.TableSlug, authz.Update, data)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	permissions.Strip(data)
+
+	if err := validateDynamicLinks(ctx, tx, req.TableSlug, data); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	if _, ok := data["guid"]; !ok {
 		data["guid"] = data["id"]
 	}
 
 	guid = cast.ToString(data["guid"])
 
+	if err := checkRowAccess(ctx, tx, req.TableSlug, authz.Resolve(ctx, data), authz.Update, guid); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	if err := validateTreeParent(ctx, tx, req.TableSlug, guid, data); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	if authGuid, ok := data["auth_guid"]; ok {
 		data["guid"] = authGuid
 	}
@@ -620,6 +694,11 @@ func (i *itemsRepo) Update(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		ON f.table_id = t.id 
 		WHERE t.slug = $1 AND f.slug != 'user_id_auth'`
 
+	cipher, err := helper.LoadFieldCipher(ctx, tx, req.TableSlug)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	fieldRows, err := tx.Query(ctx, fieldQuery, req.TableSlug)
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while getting fields")
@@ -636,8 +715,6 @@ func (i *itemsRepo) Update(ctx context.Context, req *nb.CommonMessage) (resp *nb
 			return &nb.CommonMessage{}, errors.Wrap(err, "error while scanning fields")
 		}
 
-		fields = append(fields, models.Field{Slug: fieldSlug})
-
 		val, ok := data[fieldSlug]
 		switch fieldType {
 		case "MULTISELECT":
@@ -652,19 +729,6 @@ func (i *itemsRepo) Update(ctx context.Context, req *nb.CommonMessage) (resp *nb
 			}
 		case "FORMULA_FRONTEND":
 			val = cast.ToString(val)
-
-			var attributes = &structpb.Struct{}
-
-			err := json.Unmarshal(fieldAttr, attributes)
-			if err != nil {
-				return &nb.CommonMessage{}, errors.Wrap(err, "error while converting struct to map")
-			}
-
-			formulaFronts = append(formulaFronts, models.Field{
-				Slug:       fieldSlug,
-				Attributes: attributes,
-			})
-
 		case "PASSWORD":
 			if ok && val != nil {
 				password := cast.ToString(val)
@@ -684,6 +748,10 @@ func (i *itemsRepo) Update(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		}
 
 		if ok {
+			if val, err = cipher.Encrypt(fieldSlug, val); err != nil {
+				return &nb.CommonMessage{}, err
+			}
+
 			query += fmt.Sprintf(`%s=$%d, `, fieldSlug, argCount)
 			argCount++
 			args = append(args, val)
@@ -699,6 +767,7 @@ func (i *itemsRepo) Update(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		if err != nil {
 			return &nb.CommonMessage{}, errors.Wrap(err, "error while getting item")
 		}
+		cipher.DecryptItem(response)
 
 		var (
 			count    = 0
@@ -826,11 +895,27 @@ func (i *itemsRepo) Update(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		}
 	}
 
+	if tables, err := NewFormulaCalculationService(req.TableSlug, data, oldData).RecalculateTx(ctx, tx, guid); err != nil {
+		i.log.Error("error while recalculating formulas in UPDATE", logger.Error(err))
+	} else {
+		formulaTables = tables
+	}
+
+	if err := cipher.IndexRow(ctx, tx, guid, data); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	output, err := helper.GetItemWithTx(ctx, tx, req.TableSlug, guid, false)
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while getting item")
 	}
 
+	access, err := helper.LoadFieldAccess(ctx, conn, req.TableSlug, data)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	access.Apply(output)
+
 	response, err := helper.ConvertMapToStruct(output)
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while converting map to struct")
@@ -840,19 +925,6 @@ func (i *itemsRepo) Update(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while committing")
 	}
 
-	go func() {
-		formulaService := NewFormulaCalculationService(conn, req.TableSlug, data, oldData, fields, formulaFronts)
-		err = formulaService.CalculateFormulaFields(context.Background(), guid)
-		if err != nil {
-			i.log.Error("error CalculateFormulaFrontendFields in UPDATE", logger.Error(err))
-		}
-
-		err = formulaService.RecalculateAffectedFormulas(context.Background(), guid)
-		if err != nil {
-			i.log.Error("error CalculateFormulaBackendFields in UPDATE", logger.Error(err))
-		}
-	}()
-
 	return &nb.CommonMessage{
 		TableSlug: req.TableSlug,
 		ProjectId: req.ProjectId,
@@ -893,6 +965,12 @@ func (i *itemsRepo) GetSingle(ctx context.Context, req *nb.CommonMessage) (resp
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while getting item")
 	}
 
+	if guid := cast.ToString(output["guid"]); guid != "" {
+		if err := checkRowAccess(ctx, conn, req.TableSlug, authz.Resolve(ctx, data), authz.Read, guid); err != nil {
+			return &nb.CommonMessage{}, err
+		}
+	}
+
 	withRelations := cast.ToBool(data["with_relations"])
 	if withRelations {
 		relationQuery := `
@@ -952,6 +1030,12 @@ func (i *itemsRepo) GetSingle(ctx context.Context, req *nb.CommonMessage) (resp
 		}
 	}
 
+	if withRelations {
+		if err := expandDynamicLinks(ctx, conn, req.TableSlug, output); err != nil {
+			return &nb.CommonMessage{}, err
+		}
+	}
+
 	clear(relationMap)
 
 	query := `SELECT 
@@ -1120,6 +1204,12 @@ func (i *itemsRepo) GetSingle(ctx context.Context, req *nb.CommonMessage) (resp
 		dynamicRelationsMap[relation.Id] = relation
 	}
 
+	helper.ProjectItems(data, output)
+
+	if err := helper.ApplyFieldAccess(ctx, conn, req.TableSlug, data, output); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	response := make(map[string]any)
 	response["response"] = output
 	response["fields"] = fields
@@ -1141,6 +1231,14 @@ func (i *itemsRepo) Delete(ctx context.Context, req *nb.CommonMessage) (resp *nb
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.Delete")
 	defer dbSpan.Finish()
 
+	var linkedTables, formulaTables []string
+
+	defer func() {
+		if err == nil {
+			invalidateCache(ctx, req.GetProjectId(), append(append(linkedTables, formulaTables...), req.GetTableSlug())...)
+		}
+	}()
+
 	var (
 		table      = models.Table{}
 		atr        = []byte{}
@@ -1167,6 +1265,10 @@ func (i *itemsRepo) Delete(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while converting struct to map")
 	}
 
+	if _, err := authorizeWrite(ctx, tx, req.TableSlug, authz.Delete, data); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	var (
 		id              = cast.ToString(data["id"])
 		fromAuthService = cast.ToBool(data["from_auth_service"])
@@ -1189,6 +1291,19 @@ func (i *itemsRepo) Delete(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while scanning")
 	}
 
+	if err := checkRowAccess(ctx, tx, req.TableSlug, authz.Resolve(ctx, data), authz.Delete, cast.ToString(response["guid"])); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	linkedTables, cascaded, err := applyDeletePolicies(ctx, tx, req.TableSlug, []string{cast.ToString(response["guid"])}, table.SoftDelete)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	if err := i.syncCascadedDeletes(ctx, tx, data, cascaded); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	deleteColumn := "guid"
 	if fromAuthService {
 		deleteColumn = "user_id_auth"
@@ -1261,14 +1376,14 @@ func (i *itemsRepo) Delete(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		}
 	}
 
-	if err = tx.Commit(ctx); err != nil {
-		return &nb.CommonMessage{}, errors.Wrap(err, "error while committing")
+	if tables, err := NewFormulaCalculationService(req.TableSlug, nil, response).RecalculateTx(ctx, tx, id); err != nil {
+		i.log.Error("error while recalculating formulas in DELETE", logger.Error(err))
+	} else {
+		formulaTables = tables
 	}
 
-	formulaService := NewFormulaCalculationService(conn, req.TableSlug, nil, response, nil, nil)
-	err = formulaService.RecalculateAffectedFormulasDelete(ctx, req.TableSlug, id, "")
-	if err != nil {
-		log.Println("eror while RecalculateAffectedFormulas in delete", err.Error())
+	if err = tx.Commit(ctx); err != nil {
+		return &nb.CommonMessage{}, errors.Wrap(err, "error while committing")
 	}
 
 	newRes, err := helper.ConvertMapToStruct(response)
@@ -1276,14 +1391,6 @@ func (i *itemsRepo) Delete(ctx context.Context, req *nb.CommonMessage) (resp *nb
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while converting map to struct")
 	}
 
-	go func() {
-		formulaService := NewFormulaCalculationService(conn, req.TableSlug, nil, response, nil, nil)
-		err = formulaService.RecalculateAffectedFormulasDelete(context.Background(), req.TableSlug, id, "")
-		if err != nil {
-			i.log.Error("error CalculateFormulaBackendFields in DELETE", logger.Error(err))
-		}
-	}()
-
 	return &nb.CommonMessage{
 		TableSlug: req.TableSlug,
 		ProjectId: req.ProjectId,
@@ -1295,6 +1402,14 @@ func (i *itemsRepo) DeleteMany(ctx context.Context, req *nb.CommonMessage) (resp
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.DeleteMany")
 	defer dbSpan.Finish()
 
+	var linkedTables []string
+
+	defer func() {
+		if err == nil {
+			invalidateCache(ctx, req.GetProjectId(), append(linkedTables, req.GetTableSlug())...)
+		}
+	}()
+
 	data, err := helper.ConvertStructToMap(req.Data)
 	if err != nil {
 		return nil, errors.Wrap(err, "error while converting struct to map")
@@ -1323,6 +1438,10 @@ func (i *itemsRepo) DeleteMany(ctx context.Context, req *nb.CommonMessage) (resp
 		_ = tx.Rollback(ctx)
 	}()
 
+	if _, err := authorizeWrite(ctx, tx, req.TableSlug, authz.Delete, data); err != nil {
+		return nil, err
+	}
+
 	query = `SELECT slug, attributes, is_login_table, soft_delete FROM "table" WHERE slug = $1`
 
 	err = tx.QueryRow(ctx, query, req.TableSlug).Scan(
@@ -1335,6 +1454,19 @@ func (i *itemsRepo) DeleteMany(ctx context.Context, req *nb.CommonMessage) (resp
 		return nil, errors.Wrap(err, "error while scanning")
 	}
 
+	if err := checkRowAccess(ctx, tx, req.TableSlug, authz.Resolve(ctx, data), authz.Delete, ids...); err != nil {
+		return nil, err
+	}
+
+	linkedTables, cascaded, err := applyDeletePolicies(ctx, tx, req.TableSlug, ids, table.SoftDelete)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.syncCascadedDeletes(ctx, tx, data, cascaded); err != nil {
+		return nil, err
+	}
+
 	if !table.IsLoginTable && !config.PersonTable[table.Slug] {
 		if table.SoftDelete {
 			query = fmt.Sprintf(`UPDATE "%s" SET deleted_at = CURRENT_TIMESTAMP WHERE guid = ANY($1)`, req.TableSlug)
@@ -1440,13 +1572,35 @@ func (i *itemsRepo) MultipleUpdate(ctx context.Context, req *nb.CommonMessage) (
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.MultipleUpdate")
 	defer dbSpan.Finish()
 
+	// Formulas of the objects are recalculated by the background worker.
+	ctx = deferFormulas(ctx)
+
 	data, err := helper.ConvertStructToMap(req.Data)
 	if err != nil {
 		return &nb.CommonMessage{}, err
 	}
 
+	// The objects carry no subject of their own
+	if subject := authz.Resolve(ctx, data); subject.Restricted() {
+		ctx = authz.WithSubject(ctx, subject)
+	}
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	// Every object is written on its own, so the links of all of them are
+	// checked before the first is
+	objects := make([]map[string]any, 0, len(cast.ToSlice(data["objects"])))
 	for _, obj := range cast.ToSlice(data["objects"]) {
-		object := cast.ToStringMap(obj)
+		objects = append(objects, cast.ToStringMap(obj))
+	}
+	if err := validateDynamicLinks(ctx, conn, req.TableSlug, objects...); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	for _, object := range objects {
 
 		newObj, err := helper.ConvertMapToStruct(object)
 		if err != nil {
@@ -1482,10 +1636,16 @@ func (i *itemsRepo) MultipleUpdate(ctx context.Context, req *nb.CommonMessage) (
 	}, nil
 }
 
-func (i *itemsRepo) UpsertMany(ctx context.Context, req *nb.CommonMessage) error {
+func (i *itemsRepo) UpsertMany(ctx context.Context, req *nb.CommonMessage) (err error) {
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.UpsertMany")
 	defer dbSpan.Finish()
 
+	defer func() {
+		if err == nil {
+			invalidateCache(ctx, req.GetProjectId(), req.GetTableSlug())
+		}
+	}()
+
 	data, err := helper.ConvertStructToMap(req.Data)
 	if err != nil {
 		return errors.Wrap(err, "upsertMany convert req")
@@ -1517,6 +1677,21 @@ func (i *itemsRepo) UpsertMany(ctx context.Context, req *nb.CommonMessage) error
 		return err
 	}
 
+	// An upsert both creates and updates. The conflict field identifies
+	// the rows, so it is kept even when the role may not edit it.
+	permissions, err := authorizeWrite(ctx, conn, req.TableSlug, authz.Create, data)
+	if err != nil {
+		return err
+	}
+	if _, err := authorizeWrite(ctx, conn, req.TableSlug, authz.Update, data); err != nil {
+		return err
+	}
+	editable := permissions.Editable(fieldsReq)
+	if slices.Contains(fieldsReq, fieldSlug) && !slices.Contains(editable, fieldSlug) {
+		editable = append(editable, fieldSlug)
+	}
+	fieldsReq = editable
+
 	fieldRows, err := conn.Query(ctx, `
 		SELECT f.slug, f.type 
 		FROM "field" as f 
@@ -1539,6 +1714,23 @@ func (i *itemsRepo) UpsertMany(ctx context.Context, req *nb.CommonMessage) error
 		fieldSlugs = append(fieldSlugs, field)
 	}
 
+	rowsData := make([]map[string]any, 0, len(objects))
+	for _, obj := range objects {
+		rowsData = append(rowsData, cast.ToStringMap(obj))
+	}
+	if err := validateDynamicLinks(ctx, conn, req.TableSlug, rowsData...); err != nil {
+		return err
+	}
+
+	// Encrypted values differ on every write, so they cannot identify rows
+	cipher, err := helper.LoadFieldCipher(ctx, conn, req.TableSlug)
+	if err != nil {
+		return err
+	}
+	if cipher.Encrypted(fieldSlug) {
+		return status.Errorf(codes.InvalidArgument, "encrypted field %s cannot be the upsert field", fieldSlug)
+	}
+
 	for _, field := range fieldSlugs {
 		insertQuery += fmt.Sprintf(`%s, `, field.Slug)
 		updateQuery += fmt.Sprintf(`%s = EXCLUDED.%s, `, field.Slug, field.Slug)
@@ -1566,6 +1758,10 @@ func (i *itemsRepo) UpsertMany(ctx context.Context, req *nb.CommonMessage) error
 					}
 				}
 
+				if val, err = cipher.Encrypt(field.Slug, val); err != nil {
+					return err
+				}
+
 				valuesQuery += fmt.Sprintf(`$%d, `, argCount)
 				args = append(args, val)
 				argCount++
@@ -1577,13 +1773,121 @@ func (i *itemsRepo) UpsertMany(ctx context.Context, req *nb.CommonMessage) error
 
 	valuesQuery = valuesQuery[:len(valuesQuery)-2]
 
-	var query = insertQuery + valuesQuery + updateQuery
+	var query = insertQuery + valuesQuery + updateQuery + fmt.Sprintf(" RETURNING guid::TEXT, %s::TEXT", pq.QuoteIdentifier(fieldSlug))
 
-	_, err = conn.Exec(ctx, query, args...)
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "upsertMany begin transaction")
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// Rows the upsert updates must pass the update rule of the role
+	// before, and the rows it inserts the create rule after
+	var (
+		subject = authz.Resolve(ctx, data)
+		keys    = make([]string, 0, len(objects))
+	)
+	for _, obj := range objects {
+		keys = append(keys, cast.ToString(cast.ToStringMap(obj)[fieldSlug]))
+	}
+
+	// The old values tell the formula queue which parents the updated
+	// rows pointed at before
+	rows, err := tx.Query(ctx, fmt.Sprintf(`SELECT guid::TEXT, to_jsonb(t) FROM %s t WHERE %s::TEXT = ANY($1)`,
+		pq.QuoteIdentifier(req.TableSlug), pq.QuoteIdentifier(fieldSlug)), pq.Array(keys))
+	if err != nil {
+		return errors.Wrap(err, "upsertMany get existing rows")
+	}
+
+	var (
+		existing []string
+		old      []map[string]any
+	)
+	for rows.Next() {
+		var (
+			guid string
+			row  map[string]any
+		)
+		if err := rows.Scan(&guid, &row); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "upsertMany scan existing row")
+		}
+		existing = append(existing, guid)
+		old = append(old, row)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "upsertMany get existing rows")
+	}
+
+	if err := checkRowAccess(ctx, tx, req.TableSlug, subject, authz.Update, existing...); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(ctx, query, args...)
 	if err != nil {
 		return errors.Wrap(err, "upsertMany execute query")
 	}
 
+	// RETURNING does not keep the order of the objects, so the rows are
+	// matched to them by the upsert field
+	var (
+		guids   []string
+		rowKeys = make(map[string]string)
+	)
+	for rows.Next() {
+		var guid, key string
+		if err := rows.Scan(&guid, &key); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "upsertMany scan row")
+		}
+		guids = append(guids, guid)
+		rowKeys[guid] = key
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "upsertMany execute query")
+	}
+
+	var inserted []string
+	for _, guid := range guids {
+		if !slices.Contains(existing, guid) {
+			inserted = append(inserted, guid)
+		}
+	}
+	if err := checkRowAccess(ctx, tx, req.TableSlug, subject, authz.Create, inserted...); err != nil {
+		return err
+	}
+
+	objectsByKey := make(map[string]map[string]any, len(objects))
+	for k, obj := range objects {
+		objectsByKey[keys[k]] = cast.ToStringMap(obj)
+	}
+
+	for _, guid := range guids {
+		object, ok := objectsByKey[rowKeys[guid]]
+		if !ok {
+			continue
+		}
+		written := make(map[string]any, len(fieldSlugs))
+		for _, field := range fieldSlugs {
+			if value, ok := object[field.Slug]; ok {
+				written[field.Slug] = value
+			}
+		}
+		if err := cipher.IndexRow(ctx, tx, guid, written); err != nil {
+			return err
+		}
+	}
+
+	if err := enqueueFormulaChange(ctx, tx, recordChange{table: req.TableSlug, ids: guids, old: old}); err != nil {
+		return errors.Wrap(err, "upsertMany queue formulas")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errors.Wrap(err, "upsertMany commit")
+	}
+
 	return nil
 }
 
@@ -1591,6 +1895,12 @@ func (i *itemsRepo) UpdateByUserIdAuth(ctx context.Context, req *nb.CommonMessag
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.Update")
 	defer dbSpan.Finish()
 
+	defer func() {
+		if err == nil {
+			invalidateCache(ctx, req.GetProjectId(), req.GetTableSlug())
+		}
+	}()
+
 	var (
 		argCount     = 2
 		guid         string
@@ -1618,6 +1928,12 @@ func (i *itemsRepo) UpdateByUserIdAuth(ctx context.Context, req *nb.CommonMessag
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while preparing to update in object builder")
 	}
 
+	permissions, err := authorizeWrite(ctx, tx, req.TableSlug, authz.Update, data)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	permissions.Strip(data)
+
 	if _, ok := data["guid"]; !ok {
 		data["user_id_auth"] = data["id"]
 	}
@@ -1639,6 +1955,11 @@ func (i *itemsRepo) UpdateByUserIdAuth(ctx context.Context, req *nb.CommonMessag
 		ON f.table_id = t.id 
 		WHERE t.slug = $1 AND f.slug != 'user_id_auth'`
 
+	cipher, err := helper.LoadFieldCipher(ctx, tx, req.TableSlug)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	fieldRows, err := tx.Query(ctx, fieldQuery, req.TableSlug)
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while getting fields")
@@ -1685,6 +2006,10 @@ func (i *itemsRepo) UpdateByUserIdAuth(ctx context.Context, req *nb.CommonMessag
 		}
 
 		if ok {
+			if val, err = cipher.Encrypt(fieldSlug, val); err != nil {
+				return &nb.CommonMessage{}, err
+			}
+
 			query += fmt.Sprintf(`%s=$%d, `, fieldSlug, argCount)
 			argCount++
 			args = append(args, val)
@@ -1704,6 +2029,20 @@ func (i *itemsRepo) UpdateByUserIdAuth(ctx context.Context, req *nb.CommonMessag
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while getting item")
 	}
 
+	if err := checkRowAccess(ctx, tx, req.TableSlug, authz.Resolve(ctx, data), authz.Update, cast.ToString(output["guid"])); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	if err := cipher.IndexRow(ctx, tx, cast.ToString(output["guid"]), data); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	access, err := helper.LoadFieldAccess(ctx, conn, req.TableSlug, data)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	access.Apply(output)
+
 	response, err := helper.ConvertMapToStruct(output)
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while converting map to struct")
@@ -1826,3 +2165,99 @@ func (i *itemsRepo) DeleteManyPersonTable(ctx context.Context, req *models.Perso
 
 	return nil
 }
+
+// RecalculateAll recalculates every formula of the table for all its rows,
+// one batch per transaction, so formulas added or changed after the data was
+// written can be backfilled.
+func (i *itemsRepo) RecalculateAll(ctx context.Context, req *nb.RecalculateAllRequest) (resp *nb.RecalculateAllResponse, err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.RecalculateAll")
+	defer dbSpan.Finish()
+
+	resp = &nb.RecalculateAllResponse{}
+
+	updated := map[string]bool{}
+	defer func() {
+		if resp.Rows > 0 {
+			tables := []string{req.GetTableSlug()}
+			for table := range updated {
+				tables = append(tables, table)
+			}
+			invalidateCache(ctx, req.GetProjectId(), tables...)
+		}
+	}()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return resp, err
+	}
+
+	batchSize := int(req.GetBatchSize())
+	switch {
+	case batchSize <= 0:
+		batchSize = 500
+	case batchSize > 5000:
+		batchSize = 5000
+	}
+
+	var (
+		last  = uuid.Nil.String()
+		query = fmt.Sprintf(`SELECT guid::TEXT FROM %s WHERE deleted_at IS NULL AND guid > $1 ORDER BY guid LIMIT $2`, pq.QuoteIdentifier(req.GetTableSlug()))
+	)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return resp, err
+		}
+
+		rows, err := conn.Query(ctx, query, last, batchSize)
+		if err != nil {
+			return resp, errors.Wrap(err, "error while getting rows")
+		}
+
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return resp, errors.Wrap(err, "error while scanning rows")
+		}
+
+		if len(ids) == 0 {
+			return resp, nil
+		}
+
+		tables, err := i.recalculateBatch(ctx, conn, req.GetTableSlug(), ids)
+		if err != nil {
+			return resp, err
+		}
+		for _, table := range tables {
+			updated[table] = true
+		}
+
+		resp.Rows += int32(len(ids))
+		resp.Batches++
+		last = ids[len(ids)-1]
+
+		if len(ids) < batchSize {
+			return resp, nil
+		}
+	}
+}
+
+func (i *itemsRepo) recalculateBatch(ctx context.Context, conn *psqlpool.Pool, tableSlug string, ids []string) ([]string, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while beginning transaction")
+	}
+
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	tables, err := recalculateFormulas(ctx, tx, recordChange{table: tableSlug, ids: ids, refresh: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "error while recalculating formulas")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
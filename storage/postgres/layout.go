@@ -74,7 +74,7 @@ func (l *layoutRepo) Update(ctx context.Context, req *nb.LayoutRequest) (resp *n
 	defer tx.Rollback(ctx)
 
 	resp = &nb.LayoutResponse{}
-	rows, err := tx.Query(ctx, "SELECT guid FROM role")
+	rows, err := tx.Query(ctx, "SELECT guid FROM role WHERE parent_id IS NULL")
 	if err != nil {
 		return nil, errors.Wrap(err, "error fetching roles")
 	}
@@ -991,8 +991,8 @@ func (l *layoutRepo) getFieldsWithPermissions(ctx context.Context, conn *psqlpoo
 		fp.edit_permission
 	FROM "field" f 
 	JOIN "table" t ON t.id = f.table_id 
-	LEFT JOIN "field_permission" fp ON fp.field_id = f.id
-	WHERE t.slug = $1 AND fp.role_id = $2`
+	JOIN "effective_field_permission" fp ON fp.field_id = f.id AND fp.effective_role_id::TEXT = $2
+	WHERE t.slug = $1`
 
 	rows, err := conn.Query(ctx, fieldQuery, tableSlug, roleId)
 	if err != nil {
@@ -1560,7 +1560,7 @@ func GetSections(ctx context.Context, conn *psqlpool.Pool, tabId, roleId, tableS
 						label,
 						view_permission,
 						edit_permission
-					FROM field_permission WHERE field_id = $1 AND role_id = $2`
+					FROM effective_field_permission WHERE field_id = $1 AND effective_role_id::TEXT = $2`
 
 					err = conn.QueryRow(ctx, query, fieldId, roleId).Scan(
 						&permission.Guid,
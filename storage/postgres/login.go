@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
 	"ucode/ucode_go_object_builder_service/models"
@@ -14,6 +16,7 @@ import (
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
 	"ucode/ucode_go_object_builder_service/storage"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
@@ -44,6 +47,7 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 		tableSlug                      = `user`
 		userId, roleId, guid           string
 		userFound, comparePassword     bool
+		passwordExpired                bool
 		role                           models.Role
 		clientPlatform                 models.ClientPlatform
 		connections                    = []*nb.TableClientType{}
@@ -65,7 +69,8 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 			"is_system",
 			"table_slug",
 			"default_page",
-			"session_limit"
+			"session_limit",
+			COALESCE("login_policy", '')
 		FROM client_type WHERE "guid" = $1 OR "name" = $1::varchar
 	`
 
@@ -81,6 +86,7 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 		&tableSlugNull,
 		&defaultPageNull,
 		&clientType.SessionLimit,
+		&clientType.LoginPolicy,
 	)
 	if err != nil {
 		return errResp, errors.Wrap(err, "error getting client type")
@@ -97,6 +103,25 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 		return errResp, nil
 	}
 
+	policy, err := security.ParseLoginPolicy(clientType.LoginPolicy)
+	if err != nil {
+		return errResp, err
+	}
+
+	// Logins with a password are throttled and counted as failed before
+	// the password is checked, so a locked out login learns nothing from
+	// its guesses and concurrent guesses are all counted.
+	guard := newLoginGuard(conn, clientType.Guid, policy, req.UserId, req.GetIp())
+	if len(req.GetPassword()) != 0 {
+		retryAt, err := guard.reserve(ctx)
+		if err != nil {
+			return errResp, err
+		}
+		if !retryAt.IsZero() {
+			return &nb.LoginDataRes{Locked: true, RetryAfter: int64(math.Ceil(time.Until(retryAt).Seconds()))}, nil
+		}
+	}
+
 	userInfo, err := helper.GetItemLogin(ctx, conn, tableSlug, req.UserId, req.ClientType)
 	if err != nil {
 		return errResp, nil
@@ -135,15 +160,20 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 				return errResp, nil
 			}
 
-			checkPassword, err := security.ComparePasswordBcrypt(cast.ToString(userInfo[cast.ToString(authInfo["password"])]), req.Password)
-			if err != nil {
+			hash := cast.ToString(userInfo[cast.ToString(authInfo["password"])])
+			checkPassword, err := security.ComparePasswordBcrypt(hash, req.Password)
+			if err != nil || !checkPassword {
 				return &nb.LoginDataRes{UserFound: false, ComparePassword: false}, nil
 			}
+			comparePassword = true
 
-			if !checkPassword {
-				return &nb.LoginDataRes{UserFound: false, ComparePassword: false}, nil
+			if err := guard.succeed(ctx); err != nil {
+				return errResp, err
+			}
+
+			if passwordExpired, err = checkPasswordAge(ctx, conn, policy.Password, tableSlug, guid, hash); err != nil {
+				return errResp, err
 			}
-			comparePassword = true
 		} else {
 			comparePassword = true
 		}
@@ -155,7 +185,7 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 		"project_id",
 		COALESCE("client_platform_id"::varchar, ''),
 		"client_type_id"
-	FROM "role" WHERE "guid" = $1`
+	FROM "role" WHERE "guid" = $1 AND COALESCE("is_template", false) = false`
 
 	err = conn.QueryRow(ctx, query, roleId).Scan(
 		&role.Guid,
@@ -164,6 +194,10 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 		&role.ClientPlatformId,
 		&role.ClientTypeId,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Permission templates are only parents, no user logs in with one
+		return errResp, nil
+	}
 	if err != nil {
 		return errResp, errors.Wrap(err, "error getting role")
 	}
@@ -225,7 +259,7 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 		})
 	}
 
-	query = `SELECT 
+	query = `SELECT
 		"guid",
 		"role_id",
 		"read",
@@ -248,7 +282,7 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 		"group",
 		excel_menu,
 		search_button
-	FROM "record_permission" WHERE role_id = $1`
+	FROM "effective_record_permission" WHERE effective_role_id::TEXT = $1`
 
 	recPermissions, err := conn.Query(ctx, query, roleId)
 	if err != nil {
@@ -311,9 +345,8 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 				"gpt_button",
 				"billing",
 				COALESCE("menu_drag", true)
-			FROM global_permission
-			WHERE role_id = $1
-		LIMIT 1
+			FROM effective_global_permission
+			WHERE effective_role_id::TEXT = $1
 	`
 
 	err = conn.QueryRow(ctx, query, roleId).Scan(
@@ -379,9 +412,29 @@ func (l *loginRepo) LoginData(ctx context.Context, req *nb.LoginDataReq) (resp *
 		GlobalPermission: globalPermission,
 		UserIdAuth:       userId,
 		UserData:         userdata,
+		PasswordExpired:  passwordExpired,
 	}, nil
 }
 
+// checkPasswordAge reports whether the password of a login row expired
+// under policy. A row with no recorded change has hash recorded now, which
+// starts its clock.
+func checkPasswordAge(ctx context.Context, conn *psqlpool.Pool, policy security.PasswordPolicy, tableSlug, guid, hash string) (bool, error) {
+	if policy.MaxAgeDays == 0 {
+		return false, nil
+	}
+
+	changedAt, ok, err := helper.PasswordChangedAt(ctx, conn, tableSlug, guid)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, helper.RecordPassword(ctx, conn, policy, tableSlug, guid, hash)
+	}
+
+	return policy.Expired(changedAt, time.Now()), nil
+}
+
 func (l *loginRepo) GetConnectionOptions(ctx context.Context, req *nb.GetConnetionOptionsRequest) (resp *nb.GetConnectionOptionsResponse, err error) {
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "login.GetConnectionOptions")
 	defer dbSpan.Finish()
@@ -522,7 +575,8 @@ func (l *loginRepo) UpdateUserPassword(ctx context.Context, req *nb.UpdateUserPa
 			"guid",
 			COALESCE("project_id"::varchar, ''),
 			"name",
-			"table_slug"
+			"table_slug",
+			COALESCE("login_policy", '')
 		FROM client_type WHERE "guid" = $1
 	`
 
@@ -531,6 +585,7 @@ func (l *loginRepo) UpdateUserPassword(ctx context.Context, req *nb.UpdateUserPa
 		&clientType.ProjectId,
 		&clientType.Name,
 		&tableSlugNull,
+		&clientType.LoginPolicy,
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "error getting client type")
@@ -577,6 +632,33 @@ func (l *loginRepo) UpdateUserPassword(ctx context.Context, req *nb.UpdateUserPa
 		}
 	}
 
+	policy, err := security.ParseLoginPolicy(clientType.LoginPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	// The password and its history change together, and the row stays
+	// locked from the history check on, so concurrent changes cannot both
+	// pass it.
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating transaction")
+	}
+
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var current sql.NullString
+	query = fmt.Sprintf(`SELECT %s::TEXT FROM "%s" WHERE guid = $1 FOR UPDATE`, field, tableSlug)
+	if err := tx.QueryRow(ctx, query, req.Guid).Scan(&current); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.Wrap(err, "error getting current password")
+	}
+
+	if err := helper.ValidatePassword(ctx, tx, policy.Password, tableSlug, req.Guid, current.String, req.Password); err != nil {
+		return nil, err
+	}
+
 	// Hash the password
 	hashedPassword, err := security.HashPasswordBcrypt(req.Password)
 	if err != nil {
@@ -585,11 +667,19 @@ func (l *loginRepo) UpdateUserPassword(ctx context.Context, req *nb.UpdateUserPa
 
 	// Update the user record by guid
 	query = fmt.Sprintf(`UPDATE "%s" SET %s = $1, updated_at = now() WHERE guid = $2`, tableSlug, field)
-	_, err = conn.Exec(ctx, query, hashedPassword, req.Guid)
+	_, err = tx.Exec(ctx, query, hashedPassword, req.Guid)
 	if err != nil {
 		return nil, errors.Wrap(err, "error updating user password")
 	}
 
+	if err := helper.RecordPassword(ctx, tx, policy.Password, tableSlug, req.Guid, hashedPassword); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errors.Wrap(err, "error committing transaction")
+	}
+
 	// Get the updated user to return user_id_auth and user_id (guid)
 	query = fmt.Sprintf(`SELECT * FROM "%s" WHERE guid = $1`, tableSlug)
 	rows, err := conn.Query(ctx, query, req.Guid)
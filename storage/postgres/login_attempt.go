@@ -0,0 +1,174 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	"ucode/ucode_go_object_builder_service/pkg/security"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// loginSubject is what failed logins are counted for: a login of a client
+// type or an IP.
+type loginSubject struct {
+	kind        string
+	subject     string
+	maxAttempts int
+}
+
+// loginGuard throttles the logins of a client type by login and by IP as
+// its policy says.
+type loginGuard struct {
+	conn         *psqlpool.Pool
+	clientTypeId string
+	policy       security.LoginPolicy
+	subjects     []loginSubject
+}
+
+func newLoginGuard(conn *psqlpool.Pool, clientTypeId string, policy security.LoginPolicy, login, ip string) *loginGuard {
+	g := &loginGuard{conn: conn, clientTypeId: clientTypeId, policy: policy}
+	if login != "" {
+		g.subjects = append(g.subjects, loginSubject{kind: "user", subject: login, maxAttempts: policy.MaxAttempts})
+	}
+	if ip != "" {
+		g.subjects = append(g.subjects, loginSubject{kind: "ip", subject: ip, maxAttempts: policy.IpMaxAttempts})
+	}
+	return g
+}
+
+func (g *loginGuard) attempts(ctx context.Context, q helper.RowQuerier, s loginSubject, lock string) (security.LoginAttempts, error) {
+	var (
+		a                        security.LoginAttempts
+		lastFailure, lockedUntil *time.Time
+	)
+
+	err := q.QueryRow(ctx, `
+		SELECT failures, last_failure_at, locked_until FROM login_attempt
+		WHERE client_type_id::TEXT = $1 AND kind = $2 AND subject = $3 `+lock,
+		g.clientTypeId, s.kind, s.subject,
+	).Scan(&a.Failures, &lastFailure, &lockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return a, nil
+	}
+	if err != nil {
+		return a, errors.Wrap(err, "error while getting login attempts")
+	}
+
+	if lastFailure != nil {
+		a.LastFailure = *lastFailure
+	}
+	if lockedUntil != nil {
+		a.LockedUntil = *lockedUntil
+	}
+	return a, nil
+}
+
+// reserve counts a login as failed before its password is checked, so
+// concurrent guesses cannot all pass the throttle before any of them is
+// counted. It returns when the next login is allowed when this one is
+// refused, a zero time when it may go on; a refused login is not counted.
+// succeed takes the count back for the logins that pass.
+func (g *loginGuard) reserve(ctx context.Context) (time.Time, error) {
+	tx, err := g.conn.Begin(ctx)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "error creating transaction")
+	}
+
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var (
+		now      = time.Now()
+		retry    time.Time
+		attempts = make([]security.LoginAttempts, len(g.subjects))
+	)
+	for i, s := range g.subjects {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO login_attempt (client_type_id, kind, subject) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING`,
+			g.clientTypeId, s.kind, s.subject,
+		)
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "error while creating login attempts")
+		}
+
+		if attempts[i], err = g.attempts(ctx, tx, s, "FOR UPDATE"); err != nil {
+			return time.Time{}, err
+		}
+		if at := g.policy.RetryAt(attempts[i]); at.After(retry) {
+			retry = at
+		}
+	}
+
+	if retry.After(now) {
+		return retry, nil
+	}
+
+	for i, s := range g.subjects {
+		if err := g.save(ctx, tx, s, g.policy.Fail(attempts[i], s.maxAttempts, now)); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return time.Time{}, tx.Commit(ctx)
+}
+
+func (g *loginGuard) save(ctx context.Context, q helper.Querier, s loginSubject, a security.LoginAttempts) error {
+	var lockedUntil *time.Time
+	if !a.LockedUntil.IsZero() {
+		lockedUntil = &a.LockedUntil
+	}
+
+	_, err := q.Exec(ctx, `
+		UPDATE login_attempt SET failures = $4, last_failure_at = $5, locked_until = $6
+		WHERE client_type_id::TEXT = $1 AND kind = $2 AND subject = $3`,
+		g.clientTypeId, s.kind, s.subject, a.Failures, a.LastFailure, lockedUntil,
+	)
+	if err != nil {
+		return errors.Wrap(err, "error while counting failed login")
+	}
+
+	return nil
+}
+
+// succeed forgets the failed logins of the login. Those of the IP are
+// kept, so that logging into an own account does not reset a guessing
+// run from the same IP, but for the one reserve counted for this login.
+func (g *loginGuard) succeed(ctx context.Context) error {
+	tx, err := g.conn.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error creating transaction")
+	}
+
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	for _, s := range g.subjects {
+		if s.kind == "user" {
+			_, err := tx.Exec(ctx, `
+				DELETE FROM login_attempt WHERE client_type_id::TEXT = $1 AND kind = $2 AND subject = $3`,
+				g.clientTypeId, s.kind, s.subject,
+			)
+			if err != nil {
+				return errors.Wrap(err, "error while resetting login attempts")
+			}
+			continue
+		}
+
+		a, err := g.attempts(ctx, tx, s, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := g.save(ctx, tx, s, g.policy.Refund(a, s.maxAttempts)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -89,6 +89,7 @@ func TestMain(m *testing.M) {
 	}
 
 	config.MaxConns = cfg.PostgresMaxConnections
+	psqlpool.BypassRowSecurity(config)
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
@@ -164,7 +164,7 @@ func (m *menuRepo) Create(ctx context.Context, req *nb.CreateMenuRequest) (resp
 		)
 	}
 
-	query = `SELECT guid FROM "role"`
+	query = `SELECT guid FROM "role" WHERE parent_id IS NULL`
 
 	rows, err := tx.Query(ctx, query)
 	if err != nil {
@@ -306,7 +306,7 @@ func (m *menuRepo) CreateWithTx(ctx context.Context, req *nb.CreateMenuRequest,
 		return &nb.Menu{}, errors.Wrap(err, "failed to insert menu")
 	}
 
-	query = `SELECT guid FROM "role"`
+	query = `SELECT guid FROM "role" WHERE parent_id IS NULL`
 
 	rows, err := tx.Query(ctx, query)
 	if err != nil {
@@ -703,7 +703,11 @@ func (m *menuRepo) GetAll(ctx context.Context, req *nb.GetAllMenusRequest) (resp
 	}
 
 	if req.RoleId != "" {
-		whereStr += fmt.Sprintf(` AND mp.role_id = '%s'`, req.RoleId)
+		whereStr += ` AND mp.guid = (
+			SELECT p.guid FROM "effective_menu_permission" p
+			WHERE p.menu_id = m.id AND p.effective_role_id::TEXT = :role_id
+		)`
+		params["role_id"] = req.RoleId
 	}
 
 	query += whereStr
@@ -18,8 +18,11 @@ import (
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
 	"ucode/ucode_go_object_builder_service/grpc/client"
 	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/cache"
 	"ucode/ucode_go_object_builder_service/pkg/helper"
 	"ucode/ucode_go_object_builder_service/pkg/logger"
+	"ucode/ucode_go_object_builder_service/pkg/sqlguard"
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
 	"ucode/ucode_go_object_builder_service/storage"
 
@@ -33,6 +36,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
 	excel "github.com/xuri/excelize/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/structpb"
 )
@@ -142,6 +147,7 @@ func (o *objectBuilderRepo) GetList(ctx context.Context, req *nb.CommonMessage)
 				"client_type_id",
 				"is_system"
 			FROM "role"
+			WHERE COALESCE("is_template", false) = false
 		`
 
 		rows, err := conn.Query(ctx, query)
@@ -707,7 +713,7 @@ func (o *objectBuilderRepo) GetTableDetails(ctx context.Context, req *nb.CommonM
 		"view",
 		"edit",
 		"delete"
-	FROM "view_permission" WHERE "view_id" = $1 AND "role_id" = $2`
+	FROM "effective_view_permission" WHERE "view_id" = $1 AND "effective_role_id"::TEXT = $2`
 
 		for _, view := range views {
 			vp := models.ViewPermission{}
@@ -838,7 +844,6 @@ func (o *objectBuilderRepo) GetAll(ctx context.Context, req *nb.CommonMessage) (
 	}
 
 	roleIdFromToken := cast.ToString(params["role_id_from_token"])
-	userIdFromToken := cast.ToString(params["user_id_from_token"])
 
 	query := `
 		SELECT 
@@ -1160,30 +1165,14 @@ func (o *objectBuilderRepo) GetAll(ctx context.Context, req *nb.CommonMessage) (
 		views = append(views, view)
 	}
 
-	recordPermission, err := helper.GetRecordPermission(ctx, models.GetRecordPermissionRequest{
-		Conn:      conn,
-		TableSlug: req.TableSlug,
-		RoleId:    roleIdFromToken,
-	})
-	if err != nil {
-		return &nb.CommonMessage{}, errors.Wrap(err, "when get recordPermission")
-	}
-
 	getQuery = strings.TrimRight(getQuery, ",")
 	getQuery += fmt.Sprintf(`) AS DATA FROM "%s" a`, req.TableSlug)
 
-	if recordPermission.IsHaveCondition {
-		params, err = helper.GetAutomaticFilter(ctx, models.GetAutomaticFilterRequest{
-			Conn:            conn,
-			Params:          params,
-			RoleIdFromToken: roleIdFromToken,
-			UserIdFromToken: userIdFromToken,
-			TableSlug:       req.TableSlug,
-		})
-		if err != nil {
-			return &nb.CommonMessage{}, errors.Wrap(err, "when get GetAutomaticFilter")
-		}
+	rowFilter, params, err := readFilter(ctx, conn, req.TableSlug, "a", params)
+	if err != nil {
+		return &nb.CommonMessage{}, err
 	}
+	filter += rowFilter
 
 	for key, val := range params {
 		if key == "limit" {
@@ -1364,7 +1353,7 @@ func (o *objectBuilderRepo) GetAll(ctx context.Context, req *nb.CommonMessage) (
 	}, nil
 }
 
-func (o *objectBuilderRepo) GetList2(ctx context.Context, req *nb.CommonMessage) (*nb.CommonMessage, error) {
+func (o *objectBuilderRepo) GetList2(ctx context.Context, req *nb.CommonMessage) (resp *nb.CommonMessage, err error) {
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "object_builder.GetList2")
 	defer dbSpan.Finish()
 
@@ -1373,6 +1362,15 @@ func (o *objectBuilderRepo) GetList2(ctx context.Context, req *nb.CommonMessage)
 		return nil, err
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetList)
+	defer cancel()
+
+	defer func() {
+		if helper.IsQueryTimeout(err) {
+			err = helper.HandleDatabaseError(err, o.logger, "GetList2")
+		}
+	}()
+
 	if req.TableSlug == "template" {
 		response := map[string]any{
 			"count":    0,
@@ -1447,16 +1445,36 @@ func (o *objectBuilderRepo) GetList2(ctx context.Context, req *nb.CommonMessage)
 	withTypes, _ := params["with_types"].(bool)
 	delete(params, "with_types")
 
+	cipher, err := helper.LoadFieldCipher(ctx, conn, req.TableSlug)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	if err := cipher.FilterParams(ctx, conn, params); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	rowFilter, err := readCondition(ctx, conn, req.TableSlug, pq.QuoteIdentifier(req.TableSlug), params)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	items, count, err := helper.GetItemsGetList(ctx, conn, models.GetItemsBody{
 		TableSlug:    req.TableSlug,
 		Params:       params,
 		FieldsMap:    fields,
 		SearchFields: searchFields,
+		RowFilter:    rowFilter,
 	})
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while getting items")
 	}
 
+	helper.ProjectItems(params, items...)
+
+	if err := helper.ApplyFieldAccess(ctx, conn, req.TableSlug, params, items...); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	if req.TableSlug == "user" && len(items) > 0 && o.grpcClient != nil {
 		userIdAuths := make([]string, 0, len(items))
 		for _, item := range items {
@@ -1548,6 +1566,15 @@ func (o *objectBuilderRepo) GetListSlim(ctx context.Context, req *nb.CommonMessa
 		return nil, err
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetList)
+	defer cancel()
+
+	defer func() {
+		if helper.IsQueryTimeout(err) {
+			err = helper.HandleDatabaseError(err, o.logger, "GetListSlim")
+		}
+	}()
+
 	paramBody, err := json.Marshal(req.Data)
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while marshalling request data")
@@ -1586,15 +1613,35 @@ func (o *objectBuilderRepo) GetListSlim(ctx context.Context, req *nb.CommonMessa
 		fields[fBody.Slug] = fBody
 	}
 
+	cipher, err := helper.LoadFieldCipher(ctx, conn, req.TableSlug)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	if err := cipher.FilterParams(ctx, conn, params); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	rowFilter, err := readCondition(ctx, conn, req.TableSlug, pq.QuoteIdentifier(req.TableSlug), params)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	items, count, err := helper.GetItems(ctx, conn, models.GetItemsBody{
 		TableSlug: req.TableSlug,
 		Params:    params,
 		FieldsMap: fields,
+		RowFilter: rowFilter,
 	})
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while getting items")
 	}
 
+	helper.ProjectItems(params, items...)
+
+	if err := helper.ApplyFieldAccess(ctx, conn, req.TableSlug, params, items...); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	// Formula calculations are now handled in CREATE/UPDATE operations
 	// Formula values should already be stored in the database
 
@@ -1634,6 +1681,15 @@ func (o *objectBuilderRepo) GetListInExcel(ctx context.Context, req *nb.CommonMe
 		return nil, err
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetExport)
+	defer cancel()
+
+	defer func() {
+		if helper.IsQueryTimeout(err) {
+			err = helper.HandleDatabaseError(err, o.logger, "GetListInExcel")
+		}
+	}()
+
 	paramBody, err := json.Marshal(req.Data)
 	if err != nil {
 		return &nb.CommonMessage{}, err
@@ -1726,6 +1782,19 @@ func (o *objectBuilderRepo) GetListInExcel(ctx context.Context, req *nb.CommonMe
 	params["limit"] = config.MAX_EXCEL_LIMIT
 	delete(params, "language")
 
+	cipher, err := helper.LoadFieldCipher(ctx, conn, req.TableSlug)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	if err := cipher.FilterParams(ctx, conn, params); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	rowFilter, err := readCondition(ctx, conn, req.TableSlug, pq.QuoteIdentifier(req.TableSlug), params)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	items, _, err := helper.GetItemsGetList(
 		ctx, conn,
 		models.GetItemsBody{
@@ -1733,6 +1802,7 @@ func (o *objectBuilderRepo) GetListInExcel(ctx context.Context, req *nb.CommonMe
 			Params:       params,
 			FieldsMap:    fields,
 			SearchFields: searchFields,
+			RowFilter:    rowFilter,
 		},
 	)
 
@@ -1740,6 +1810,11 @@ func (o *objectBuilderRepo) GetListInExcel(ctx context.Context, req *nb.CommonMe
 		return &nb.CommonMessage{}, err
 	}
 
+	access, err := helper.LoadFieldAccess(ctx, conn, req.TableSlug, params)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	var file = excel.NewFile()
 	sheetName := file.GetSheetName(0)
 
@@ -1894,7 +1969,7 @@ func (o *objectBuilderRepo) GetListInExcel(ctx context.Context, req *nb.CommonMe
 					item[f.Slug] = overall
 				}
 
-				err = file.SetCellValue(sheetName, convertToTitle(letterCount)+column, item[f.Slug])
+				err = file.SetCellValue(sheetName, convertToTitle(letterCount)+column, access.Value(f.Slug, item[f.Slug]))
 				if err != nil {
 					return &nb.CommonMessage{}, err
 				}
@@ -1960,7 +2035,7 @@ func (o *objectBuilderRepo) GetListInExcel(ctx context.Context, req *nb.CommonMe
 	return &nb.CommonMessage{TableSlug: req.TableSlug, Data: outputStruct}, nil
 }
 
-func (o *objectBuilderRepo) GroupByColumns(ctx context.Context, req *nb.CommonMessage) (*nb.CommonMessage, error) {
+func (o *objectBuilderRepo) GroupByColumns(ctx context.Context, req *nb.CommonMessage) (resp *nb.CommonMessage, err error) {
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "object_builder.GroupByColumns")
 	defer dbSpan.Finish()
 
@@ -1969,6 +2044,15 @@ func (o *objectBuilderRepo) GroupByColumns(ctx context.Context, req *nb.CommonMe
 		return nil, errors.Wrap(err, "failed to get database connection")
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetAggregation)
+	defer cancel()
+
+	defer func() {
+		if helper.IsQueryTimeout(err) {
+			err = helper.HandleDatabaseError(err, o.logger, "GroupByColumns")
+		}
+	}()
+
 	// Parse request data
 	reqData, err := helper.ConvertStructToMap(req.Data)
 	if err != nil {
@@ -2003,12 +2087,29 @@ func (o *objectBuilderRepo) GroupByColumns(ctx context.Context, req *nb.CommonMe
 	// Map group field IDs to slugs
 	groupFieldSlugs := o.mapGroupFieldIdsToSlugs(groupFields, fieldSlugMap)
 
+	rowFilter, err := readCondition(ctx, conn, req.TableSlug, pq.QuoteIdentifier(req.TableSlug), reqData)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	// Build hierarchical query with related data included
-	query, err := o.buildHierarchicalGroupQueryWithRelatedData(conn, req.TableSlug, groupFieldSlugs, fieldMap, slugRelation)
+	query, err := o.buildHierarchicalGroupQueryWithRelatedData(conn, req.TableSlug, groupFieldSlugs, fieldMap, slugRelation, rowFilter)
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "failed to build hierarchical query")
 	}
 
+	tables, cacheable := cacheableTables(ctx, conn, req.TableSlug, query)
+	cacheEntry := cache.Entry{Scope: cacheScope(conn), Tables: tables, Parts: []any{"group_by_columns", cache.NormalizeQuery(query)}}
+	if cacheable {
+		if cached, ok := getCachedStruct(ctx, cacheEntry); ok {
+			data, err := applyGroupAccess(cached, req.TableSlug, len(groupFieldSlugs), slugRelation, helper.FieldAccessLoader(ctx, conn, reqData))
+			if err != nil {
+				return &nb.CommonMessage{}, err
+			}
+			return &nb.CommonMessage{Data: data, TableSlug: req.TableSlug}, nil
+		}
+	}
+
 	// Execute query
 	rows, err := conn.Query(ctx, query)
 	if err != nil {
@@ -2032,12 +2133,113 @@ func (o *objectBuilderRepo) GroupByColumns(ctx context.Context, req *nb.CommonMe
 		return &nb.CommonMessage{}, errors.Wrap(err, "failed to convert response to struct")
 	}
 
+	if cacheable {
+		setCachedStruct(ctx, cacheEntry, responseStruct)
+	}
+
+	responseStruct, err = applyGroupAccess(responseStruct, req.TableSlug, len(groupFieldSlugs), slugRelation, helper.FieldAccessLoader(ctx, conn, reqData))
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	return &nb.CommonMessage{
 		Data:      responseStruct,
 		TableSlug: req.TableSlug,
 	}, nil
 }
 
+// applyGroupAccess shows the groups of GroupByColumns on tableSlug with the
+// field access of the reader, load returning its access on a table. The
+// values of the levels groups are nested in are shown as the fields are,
+// the grouped rows with the access on the table and the related rows of
+// their lookups, relations mapping them to their tables, with the access
+// on the related table. Groups whose values are shown the same are merged:
+// encrypted fields are grouped by ciphertext, which differs for every row.
+// The cache keeps the groups as read, so the access applies to cached
+// results too.
+func applyGroupAccess(result *structpb.Struct, tableSlug string, levels int, relations map[string]string, load func(table string) (*helper.FieldAccess, error)) (*structpb.Struct, error) {
+	access, err := load(tableSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	var apply func(groups []any, level int) ([]any, error)
+	apply = func(groups []any, level int) ([]any, error) {
+		if level == levels {
+			for _, row := range groups {
+				row, ok := row.(map[string]any)
+				if !ok {
+					continue
+				}
+
+				access.Apply(row)
+				for field, table := range relations {
+					related, ok := row[field+"_data"].(map[string]any)
+					if !ok || table == "" {
+						continue
+					}
+					a, err := load(table)
+					if err != nil {
+						return nil, err
+					}
+					a.Apply(related)
+				}
+			}
+			return groups, nil
+		}
+
+		var (
+			merged = make([]any, 0, len(groups))
+			shown  = make(map[string]map[string]any)
+		)
+		for _, group := range groups {
+			group, ok := group.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			values := make(map[string]any, len(group))
+			for key, value := range group {
+				if key != "data" {
+					group[key] = access.Value(key, value)
+					values[key] = group[key]
+				}
+			}
+
+			key, err := json.Marshal(values)
+			if err != nil {
+				return nil, errors.Wrap(err, "error while merging groups")
+			}
+			if first, ok := shown[string(key)]; ok {
+				first["data"] = append(cast.ToSlice(first["data"]), cast.ToSlice(group["data"])...)
+				continue
+			}
+			shown[string(key)] = group
+			merged = append(merged, group)
+		}
+
+		for _, group := range merged {
+			group := group.(map[string]any)
+			data, err := apply(cast.ToSlice(group["data"]), level+1)
+			if err != nil {
+				return nil, err
+			}
+			group["data"] = data
+		}
+
+		return merged, nil
+	}
+
+	response := result.AsMap()
+	if groups, ok := response["response"].([]any); ok {
+		if response["response"], err = apply(groups, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return helper.ConvertMapToStruct(response)
+}
+
 // getViewGroupFields retrieves group fields from view configuration
 func (o *objectBuilderRepo) getViewGroupFields(ctx context.Context, conn *psqlpool.Pool, viewID string) ([]string, error) {
 	query := `SELECT attributes, group_fields FROM view WHERE id = $1`
@@ -2126,7 +2328,7 @@ func (o *objectBuilderRepo) mapGroupFieldIdsToSlugs(groupFields []string, fieldS
 }
 
 // buildHierarchicalGroupQueryWithRelatedData builds a hierarchical SQL query for grouping with related data included
-func (o *objectBuilderRepo) buildHierarchicalGroupQueryWithRelatedData(conn *psqlpool.Pool, tableSlug string, groupFields []string, fieldMap, fieldRelation map[string]string) (string, error) {
+func (o *objectBuilderRepo) buildHierarchicalGroupQueryWithRelatedData(conn *psqlpool.Pool, tableSlug string, groupFields []string, fieldMap, fieldRelation map[string]string, rowFilter string) (string, error) {
 	if len(groupFields) == 0 {
 		return "", errors.New("no group fields provided")
 	}
@@ -2138,7 +2340,7 @@ func (o *objectBuilderRepo) buildHierarchicalGroupQueryWithRelatedData(conn *psq
 	}
 
 	// Build the innermost query with related data included
-	innerQuery := o.buildInnerGroupQueryWithRelatedData(conn, tableSlug, groupFields, allFields, fieldMap, fieldRelation)
+	innerQuery := o.buildInnerGroupQueryWithRelatedData(conn, tableSlug, groupFields, allFields, fieldMap, fieldRelation, rowFilter)
 
 	// Build hierarchical structure if multiple group fields
 	if len(groupFields) == 1 {
@@ -2148,13 +2350,23 @@ func (o *objectBuilderRepo) buildHierarchicalGroupQueryWithRelatedData(conn *psq
 	return o.buildHierarchicalStructure(innerQuery, groupFields), nil
 }
 
-// buildInnerGroupQueryWithRelatedData builds the innermost query that groups by all specified fields and includes related data
-func (o *objectBuilderRepo) buildInnerGroupQueryWithRelatedData(conn *psqlpool.Pool, tableSlug string, groupFields, allFields []string, fieldTypes, fieldRelation map[string]string) string {
-	var query strings.Builder
+// buildInnerGroupQueryWithRelatedData builds the innermost query that groups by all specified fields and includes related data.
+// The related rows of lookups are left joined once instead of read by a subquery per row.
+func (o *objectBuilderRepo) buildInnerGroupQueryWithRelatedData(conn *psqlpool.Pool, tableSlug string, groupFields, allFields []string, fieldTypes, fieldRelation map[string]string, rowFilter string) string {
+	var (
+		query  strings.Builder
+		joins  strings.Builder
+		source = pq.QuoteIdentifier(tableSlug)
+	)
 
 	// SELECT clause with group fields
 	query.WriteString("SELECT ")
-	query.WriteString(strings.Join(groupFields, ", "))
+	for i, field := range groupFields {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(source + "." + field)
+	}
 
 	// Add JSON aggregation for all fields including related data
 	query.WriteString(", jsonb_agg(jsonb_build_object( ")
@@ -2163,20 +2375,30 @@ func (o *objectBuilderRepo) buildInnerGroupQueryWithRelatedData(conn *psqlpool.P
 		if i > 0 {
 			query.WriteString(", ")
 		}
-		query.WriteString(fmt.Sprintf("'%s', %s", field, field))
+		query.WriteString(fmt.Sprintf("'%s', %s.%s", field, source, field))
 
-		// Add related data as subquery for _id fields (skip folder_id)
-		if fieldTypes[field] == "LOOKUP" {
-			relatedTable := fieldRelation[field]
+		// Add related data of lookups from their join
+		if relatedTable := fieldRelation[field]; fieldTypes[field] == "LOOKUP" && relatedTable != "" {
+			alias := fmt.Sprintf("rel_%d", i)
 
-			query.WriteString(fmt.Sprintf(", '%s_data', (SELECT row_to_json(rel) FROM \"%s\" rel WHERE rel.guid = %s LIMIT 1)",
-				field, relatedTable, field))
+			query.WriteString(fmt.Sprintf(", '%s_data', CASE WHEN %s.guid IS NULL THEN NULL ELSE row_to_json(%s) END", field, alias, alias))
+			joins.WriteString(fmt.Sprintf("LEFT JOIN %s %s ON %s.guid = %s.%s ", pq.QuoteIdentifier(relatedTable), alias, alias, source, field))
 		}
 	}
 
 	query.WriteString(")) as data ")
-	query.WriteString(fmt.Sprintf("FROM \"%s\" ", tableSlug))
-	query.WriteString(fmt.Sprintf("GROUP BY %s", strings.Join(groupFields, ", ")))
+	query.WriteString(fmt.Sprintf("FROM %s ", source))
+	query.WriteString(joins.String())
+	if rowFilter != "" {
+		query.WriteString("WHERE TRUE" + rowFilter)
+	}
+	query.WriteString("GROUP BY ")
+	for i, field := range groupFields {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(source + "." + field)
+	}
 
 	return query.String()
 }
@@ -2280,6 +2502,18 @@ func (o *objectBuilderRepo) UpdateWithParams(ctx context.Context, req *nb.Common
 	params := cast.ToStringMap(data["params"])
 	delete(data, "params")
 
+	permissions, err := authorizeWrite(ctx, conn, req.TableSlug, authz.Update, data)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	permissions.Strip(data)
+
+	// Only the rows the update rule of the subject allows are updated.
+	rowFilter, err := rowCondition(ctx, conn, req.TableSlug, pq.QuoteIdentifier(req.TableSlug), authz.Resolve(ctx, data), authz.Update)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	queryField := `SELECT f.slug FROM field f JOIN "table" t ON t.id = f.table_id WHERE t.slug = $1`
 
 	fieldRows, err := conn.Query(ctx, queryField, req.TableSlug)
@@ -2346,6 +2580,10 @@ func (o *objectBuilderRepo) UpdateWithParams(ctx context.Context, req *nb.Common
 		}
 	}
 
+	if rowFilter != "" {
+		filter += " AND " + rowFilter
+	}
+
 	query = strings.TrimRight(query, ",")
 	query = query + filter
 
@@ -2372,6 +2610,15 @@ func (o *objectBuilderRepo) GetListV2(ctx context.Context, req *nb.CommonMessage
 		return nil, err
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetList)
+	defer cancel()
+
+	defer func() {
+		if helper.IsQueryTimeout(err) {
+			err = helper.HandleDatabaseError(err, o.logger, "GetListV2")
+		}
+	}()
+
 	// Initialize query builder
 	qb := NewQueryBuilder()
 
@@ -2381,10 +2628,6 @@ func (o *objectBuilderRepo) GetListV2(ctx context.Context, req *nb.CommonMessage
 		return &nb.CommonMessage{}, errors.Wrap(err, "when convert struct to map")
 	}
 
-	// Get role ID from token
-	roleIdFromToken := cast.ToString(params["role_id_from_token"])
-	userIdFromToken := cast.ToString(params["user_id_from_token"])
-
 	// Get fields for the table
 	fquery := `SELECT 
 		f.slug, 
@@ -2402,44 +2645,62 @@ func (o *objectBuilderRepo) GetListV2(ctx context.Context, req *nb.CommonMessage
 	defer fieldRows.Close()
 
 	// Build field query
+	qb.projection = helper.NewProjection(params)
 	if err := qb.buildFieldQuery(fieldRows); err != nil {
 		return &nb.CommonMessage{}, err
 	}
 
+	// Add the computed columns of the view
+	var computedColumns *viewColumns
+	if viewId := cast.ToString(params["builder_service_view_id"]); viewId != "" {
+		computed, err := loadComputedColumns(ctx, conn, viewId, req.TableSlug)
+		if err != nil {
+			return &nb.CommonMessage{}, err
+		}
+
+		computedColumns, err = compileComputedColumns(ctx, conn, req.TableSlug, qb.fields, computed, authz.Resolve(ctx, params), helper.FieldAccessLoader(ctx, conn, params))
+		if err != nil {
+			return &nb.CommonMessage{}, err
+		}
+		qb.buildComputedColumnsQuery(computedColumns)
+	}
+
 	// Add relations if requested
+	var expansions []helper.RelationExpansion
 	withRelations, ok := params["with_relations"]
 	if cast.ToBool(withRelations) || !ok {
-		qb.buildRelationsQuery()
-	}
-
-	// Get record permissions
-	recordPermission, err := helper.GetRecordPermission(ctx, models.GetRecordPermissionRequest{
-		Conn:      conn,
-		TableSlug: req.TableSlug,
-		RoleId:    roleIdFromToken,
-	})
-	if err != nil {
-		return &nb.CommonMessage{}, errors.Wrap(err, "when get recordPermission")
-	}
+		expansions = helper.SelectRelationExpansions(params, qb.relationExpansions())
 
-	// Apply automatic filters if needed
-	if recordPermission.IsHaveCondition {
-		params, err = helper.GetAutomaticFilter(ctx, models.GetAutomaticFilterRequest{
-			Conn:            conn,
-			Params:          params,
-			RoleIdFromToken: roleIdFromToken,
-			UserIdFromToken: userIdFromToken,
-			TableSlug:       req.TableSlug,
-		})
+		dynamicRelations, err := loadDynamicRelations(ctx, conn, req.TableSlug, false)
 		if err != nil {
-			return &nb.CommonMessage{}, errors.Wrap(err, "when GetAutomaticFilter")
+			return &nb.CommonMessage{}, err
 		}
+		if err := resolveDisplayFields(ctx, conn, dynamicRelations); err != nil {
+			return &nb.CommonMessage{}, err
+		}
+		qb.buildDynamicRelationsQuery(dynamicRelations)
+	}
+
+	// Apply the row-level read rule of the role
+	rowFilter, params, err := readFilter(ctx, conn, req.TableSlug, "a", params)
+	if err != nil {
+		return &nb.CommonMessage{}, err
 	}
+	qb.filter += rowFilter
 
 	// Extract with_types before filters so it's not treated as a column filter
 	withTypes, _ := params["with_types"].(bool)
 	delete(params, "with_types")
 
+	// Filters on encrypted fields go through their blind index
+	cipher, err := helper.LoadFieldCipher(ctx, conn, req.TableSlug)
+	if err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	if err := cipher.FilterParams(ctx, conn, params); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	// Apply filters and search
 	qb.applyFilters(params)
 	qb.buildSearchFilter(cast.ToString(params["search"]))
@@ -2465,7 +2726,10 @@ func (o *objectBuilderRepo) GetListV2(ctx context.Context, req *nb.CommonMessage
 	}
 
 	// Process results
-	var result []any
+	var (
+		result []any
+		items  []map[string]any
+	)
 	for rows.Next() {
 		var (
 			data any
@@ -2482,11 +2746,27 @@ func (o *objectBuilderRepo) GetListV2(ctx context.Context, req *nb.CommonMessage
 			data = temp["data"]
 		}
 
+		if item, ok := data.(map[string]any); ok {
+			items = append(items, item)
+		}
+
 		result = append(result, data)
 	}
+	rows.Close()
+
+	if err := helper.ExpandRelations(ctx, conn, params, items, expansions); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
+	helper.ProjectItems(params, items...)
+
+	if err := helper.ApplyFieldAccess(ctx, conn, req.TableSlug, params, items...); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+	computedColumns.apply(items...)
 
 	var count int
-	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM "%s" AS a %s`, req.TableSlug, qb.filter+qb.autoFilters)
+	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM %s %s`, qb.from(req.TableSlug), qb.filter+qb.autoFilters)
 	err = conn.QueryRow(ctx, countQuery, qb.args...).Scan(&count)
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while getting count")
@@ -2532,11 +2812,24 @@ func (o *objectBuilderRepo) GetSingleSlim(ctx context.Context, req *nb.CommonMes
 		return nil, err
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetList)
+	defer cancel()
+
+	defer func() {
+		if helper.IsQueryTimeout(err) {
+			err = helper.HandleDatabaseError(err, o.logger, "GetSingleSlim")
+		}
+	}()
+
 	data, err := helper.ConvertStructToMap(req.Data)
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "convert req data")
 	}
 
+	if err := checkRowAccess(ctx, conn, req.TableSlug, authz.Resolve(ctx, data), authz.Read, cast.ToString(data["id"])); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	output, err := helper.GetItem(ctx, conn, req.TableSlug, cast.ToString(data["id"]), false)
 	if err != nil {
 		return &nb.CommonMessage{}, errors.Wrap(err, "get item by id")
@@ -2679,6 +2972,12 @@ func (o *objectBuilderRepo) GetSingleSlim(ctx context.Context, req *nb.CommonMes
 	// Formula calculations are now handled in CREATE/UPDATE operations
 	// Formula values should already be stored in the database
 
+	helper.ProjectItems(data, output)
+
+	if err := helper.ApplyFieldAccess(ctx, conn, req.TableSlug, data, output); err != nil {
+		return &nb.CommonMessage{}, err
+	}
+
 	response := make(map[string]any)
 	response["response"] = output
 
@@ -2721,10 +3020,24 @@ func (o *objectBuilderRepo) GetListAggregation(ctx context.Context, req *nb.Comm
 		return &nb.CommonMessage{}, errors.Wrap(err, "unmarshal query params")
 	}
 
+	ctx, cancel := conn.WithBudget(ctx, psqlpool.BudgetAggregation)
+	defer cancel()
+
+	var (
+		subject = authz.Resolve(ctx, dataMap)
+		table   = sourceTable(queryParams.Table)
+	)
+
 	switch queryParams.Operation {
 	case "SELECT":
+		if err := restrictSources(ctx, conn, &queryParams, subject); err != nil {
+			return &nb.CommonMessage{}, err
+		}
 		query, args, err = executeSelect(queryParams, sb)
 	case "UPDATE":
+		if err := authorizeAggregationUpdate(ctx, conn, &queryParams, dataMap); err != nil {
+			return &nb.CommonMessage{}, err
+		}
 		query, args, err = executeUpdate(queryParams, sb)
 	default:
 		return &nb.CommonMessage{}, errors.New("operation not found")
@@ -2734,8 +3047,36 @@ func (o *objectBuilderRepo) GetListAggregation(ctx context.Context, req *nb.Comm
 		return &nb.CommonMessage{}, errors.Wrap(err, "execute select")
 	}
 
+	var (
+		cacheEntry = cache.Entry{Scope: cacheScope(conn), Parts: []any{"aggregation", cache.NormalizeQuery(query), args}}
+		cacheable  bool
+		access     *helper.FieldAccess
+	)
+
+	if queryParams.Operation == "SELECT" {
+		cacheEntry.Tables, cacheable = cacheableTables(ctx, conn, queryParams.Table, query)
+
+		if table != "" {
+			if access, err = helper.LoadFieldAccess(ctx, conn, table, dataMap); err != nil {
+				return &nb.CommonMessage{}, err
+			}
+		}
+	}
+	if cacheable {
+		if cached, ok := getCachedStruct(ctx, cacheEntry); ok {
+			data, err := applyAggregationAccess(cached, access)
+			if err != nil {
+				return &nb.CommonMessage{}, err
+			}
+			return &nb.CommonMessage{Data: data, ProjectId: req.ProjectId}, nil
+		}
+	}
+
 	rows, err := conn.Query(ctx, query, args...)
 	if err != nil {
+		if helper.IsQueryTimeout(err) {
+			return &nb.CommonMessage{}, helper.HandleDatabaseError(err, o.logger, "GetListAggregation")
+		}
 		return &nb.CommonMessage{}, errors.Wrap(err, "query execution")
 	}
 	defer rows.Close()
@@ -2764,6 +3105,9 @@ func (o *objectBuilderRepo) GetListAggregation(ctx context.Context, req *nb.Comm
 	}
 
 	if err := rows.Err(); err != nil {
+		if helper.IsQueryTimeout(err) {
+			return &nb.CommonMessage{}, helper.HandleDatabaseError(err, o.logger, "GetListAggregation")
+		}
 		return &nb.CommonMessage{}, errors.Wrap(err, "rows error")
 	}
 
@@ -2776,6 +3120,17 @@ func (o *objectBuilderRepo) GetListAggregation(ctx context.Context, req *nb.Comm
 		return &nb.CommonMessage{}, errors.Wrap(err, "error while converting map to struct")
 	}
 
+	if queryParams.Operation == "UPDATE" {
+		invalidateCache(ctx, req.GetProjectId(), queryParams.Table)
+	} else {
+		if cacheable {
+			setCachedStruct(ctx, cacheEntry, newResp)
+		}
+		if newResp, err = applyAggregationAccess(newResp, access); err != nil {
+			return &nb.CommonMessage{}, err
+		}
+	}
+
 	return &nb.CommonMessage{
 		Data:      newResp,
 		ProjectId: req.ProjectId,
@@ -2925,11 +3280,7 @@ func (o *objectBuilderRepo) GetBoardData(ctx context.Context, req *nb.CommonMess
 		offset          = params.Offset
 		search          = params.Search
 		orderBy         = "created_at"
-		noGroupValue    = "Unassigned"
-
-		groups    = make(map[string][]any)
-		subgroups = make(map[string]map[string][]any)
-		response  = map[string]any{}
+		response        = map[string]any{}
 	)
 
 	if len(fields) == 0 {
@@ -2947,25 +3298,10 @@ func (o *objectBuilderRepo) GetBoardData(ctx context.Context, req *nb.CommonMess
 		return nil, helper.HandleDatabaseError(err, o.logger, "GetBoardData: Failed to get lookup fields")
 	}
 
-	var sb strings.Builder
-	baseFields := joinColumnsWithPrefix(fields, "a.")
-
-	sb.WriteString(baseFields)
-
+	// The related rows of lookups are read in one batch after the page.
+	expansions := make([]helper.RelationExpansion, 0, len(lookupFields))
 	for i, field := range lookupFields {
-		relationAlias := fmt.Sprintf("related_table%d", i+1)
-		sb.WriteString(fmt.Sprintf(`, 
-        (SELECT row_to_json(%s) 
-         FROM "%s" %s 
-         WHERE %s.guid = a.%s
-        ) AS %s_data`,
-			relationAlias,
-			relatedTables[i],
-			relationAlias,
-			relationAlias,
-			field,
-			field,
-		))
+		expansions = append(expansions, helper.RelationExpansion{Field: field, Table: relatedTables[i]})
 	}
 
 	whereClause, whereArgs, err := o.buildBoardWhereClause(ctx, conn, req.TableSlug, paramsMap, search, viewFields, 3)
@@ -2974,7 +3310,7 @@ func (o *objectBuilderRepo) GetBoardData(ctx context.Context, req *nb.CommonMess
 	}
 	queryParams := append([]any{offset, limit}, whereArgs...)
 
-	query := buildBoardDataQuery(req.TableSlug, sb.String(), whereClause, orderBy)
+	query := buildBoardDataQuery(req.TableSlug, joinColumnsWithPrefix(fields, "a."), whereClause, orderBy)
 
 	rows, err := conn.Query(ctx, query, queryParams...)
 	if err != nil {
@@ -2988,32 +3324,27 @@ func (o *objectBuilderRepo) GetBoardData(ctx context.Context, req *nb.CommonMess
 		columns[i] = string(fd.Name)
 	}
 
+	var items []map[string]any
 	for rows.Next() {
 		row, err := processRow(rows, columns)
 		if err != nil {
 			return nil, helper.HandleDatabaseError(err, o.logger, "GetBoardData: Failed to process row")
 		}
+		items = append(items, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, helper.HandleDatabaseError(err, o.logger, "GetBoardData: Failed to query db")
+	}
 
-		groupValues := toStringSlice(row[groupByField], noGroupValue)
-
-		var subgroupValues []string
-		if hasSubgroup {
-			subgroupValues = toStringSlice(row[subgroupByField], noGroupValue)
-		}
+	if err := helper.ExpandRelations(ctx, conn, paramsMap, items, expansions); err != nil {
+		return nil, helper.HandleDatabaseError(err, o.logger, "GetBoardData: Failed to expand relations")
+	}
 
-		for _, groupValue := range groupValues {
-			if hasSubgroup {
-				for _, subgroupValue := range subgroupValues {
-					if _, exists := subgroups[subgroupValue]; !exists {
-						subgroups[subgroupValue] = make(map[string][]any)
-					}
-					subgroups[subgroupValue][groupValue] = append(subgroups[subgroupValue][groupValue], row)
-				}
-			} else {
-				groups[groupValue] = append(groups[groupValue], row)
-			}
-		}
+	access, err := helper.LoadFieldAccess(ctx, conn, req.TableSlug, paramsMap)
+	if err != nil {
+		return nil, helper.HandleDatabaseError(err, o.logger, "GetBoardData: Failed to get field access")
 	}
+	groups, subgroups := groupBoardItems(items, access, groupByField, subgroupByField)
 
 	var count int
 	countWhereClause, countWhereArgs, err := o.buildBoardWhereClause(ctx, conn, req.TableSlug, paramsMap, search, viewFields, 1)
@@ -3044,6 +3375,45 @@ func (o *objectBuilderRepo) GetBoardData(ctx context.Context, req *nb.CommonMess
 	}, nil
 }
 
+// groupBoardItems shows items with the field access of the reader and
+// groups them by the values of groupByField, and by the ones of
+// subgroupByField within them when it is set. Items are grouped by the
+// values the reader sees, so masked or hidden fields do not show through
+// the group names.
+func groupBoardItems(items []map[string]any, access *helper.FieldAccess, groupByField, subgroupByField string) (map[string][]any, map[string]map[string][]any) {
+	var (
+		noGroupValue = "Unassigned"
+		groups       = make(map[string][]any)
+		subgroups    = make(map[string]map[string][]any)
+	)
+
+	for _, row := range items {
+		access.Apply(row)
+
+		groupValues := toStringSlice(row[groupByField], noGroupValue)
+
+		var subgroupValues []string
+		if subgroupByField != "" {
+			subgroupValues = toStringSlice(row[subgroupByField], noGroupValue)
+		}
+
+		for _, groupValue := range groupValues {
+			if subgroupByField != "" {
+				for _, subgroupValue := range subgroupValues {
+					if _, exists := subgroups[subgroupValue]; !exists {
+						subgroups[subgroupValue] = make(map[string][]any)
+					}
+					subgroups[subgroupValue][groupValue] = append(subgroups[subgroupValue][groupValue], row)
+				}
+			} else {
+				groups[groupValue] = append(groups[groupValue], row)
+			}
+		}
+	}
+
+	return groups, subgroups
+}
+
 func parseBoardParams(req *nb.CommonMessage) (*models.BoardDataParams, map[string]any, error) {
 	params := &models.BoardDataParams{}
 	paramBody, err := json.Marshal(req.Data)
@@ -3066,29 +3436,9 @@ func parseBoardParams(req *nb.CommonMessage) (*models.BoardDataParams, map[strin
 }
 
 func (o *objectBuilderRepo) buildBoardWhereClause(ctx context.Context, conn *psqlpool.Pool, tableSlug string, params map[string]any, search string, viewFields []string, startParamIdx int) (string, []any, error) {
-	roleIdFromToken := cast.ToString(params["role_id_from_token"])
-	userIdFromToken := cast.ToString(params["user_id_from_token"])
-
-	recordPermission, err := helper.GetRecordPermission(ctx, models.GetRecordPermissionRequest{
-		Conn:      conn,
-		TableSlug: tableSlug,
-		RoleId:    roleIdFromToken,
-	})
+	rowFilter, params, err := readFilter(ctx, conn, tableSlug, "a", params)
 	if err != nil {
-		return "", nil, errors.Wrap(err, "when get recordPermission")
-	}
-
-	if recordPermission.IsHaveCondition {
-		params, err = helper.GetAutomaticFilter(ctx, models.GetAutomaticFilterRequest{
-			Conn:            conn,
-			Params:          params,
-			RoleIdFromToken: roleIdFromToken,
-			UserIdFromToken: userIdFromToken,
-			TableSlug:       tableSlug,
-		})
-		if err != nil {
-			return "", nil, errors.Wrap(err, "when get automatic filter")
-		}
+		return "", nil, err
 	}
 
 	tableColumns, err := getBoardTableColumnSet(ctx, conn, tableSlug)
@@ -3097,7 +3447,7 @@ func (o *objectBuilderRepo) buildBoardWhereClause(ctx context.Context, conn *psq
 	}
 
 	whereClause, args := buildBoardWhereClauseFromParams(params, tableColumns, search, viewFields, startParamIdx)
-	return whereClause, args, nil
+	return whereClause + rowFilter, args, nil
 }
 
 func getBoardTableColumnSet(ctx context.Context, conn *psqlpool.Pool, tableSlug string) (map[string]bool, error) {
@@ -3342,41 +3692,71 @@ func (o *objectBuilderRepo) GetResourceUsage(ctx context.Context, req *nb.GetRes
 	return resp, nil
 }
 
-func (o *objectBuilderRepo) ExecuteSQL(ctx context.Context, req *nb.ExecuteSQLRequest) (*nb.ExecuteSQLResponse, error) {
+func (o *objectBuilderRepo) ExecuteSQL(ctx context.Context, req *nb.ExecuteSQLRequest) (resp *nb.ExecuteSQLResponse, err error) {
 	conn, err := psqlpool.Get(req.GetResourceEnvId())
 	if err != nil {
 		return &nb.ExecuteSQLResponse{Error: fmt.Sprintf("[ExecuteSQL -> psqlpool.Get] Ошибка подключения к БД: %v", err)}, nil
 	}
 
 	var (
-		args = make([]any, len(req.GetParams()))
-		tx   pgx.Tx
-		rows pgx.Rows
+		args      = make([]any, len(req.GetParams()))
+		tx        pgx.Tx
+		rows      pgx.Rows
+		timeout   = conn.Budget(ctx, psqlpool.BudgetUserSQL)
+		startedAt = time.Now()
 	)
 	for i, p := range req.GetParams() {
 		args[i] = p
 	}
 
-	if req.GetInTransaction() {
-		tx, err = conn.Begin(ctx)
+	analysis, guardErr := sqlguard.Inspect(req.GetSql(), sqlguard.Policy{})
+
+	defer func() {
+		entry := models.SQLAuditEntry{
+			Source:     sqlAuditSourceExecuteSQL,
+			Query:      req.GetSql(),
+			Operations: analysis.Operations(),
+			Tables:     analysis.Tables(),
+			ReadOnly:   analysis.ReadOnly(),
+			Allowed:    guardErr == nil,
+			Duration:   time.Since(startedAt),
+		}
+		if guardErr != nil {
+			entry.Reason = guardErr.Error()
+		}
 		if err != nil {
-			return &nb.ExecuteSQLResponse{Error: fmt.Sprintf("[ExecuteSQL -> Begin] Не удалось начать транзакцию: %v", err)}, nil
+			entry.Error = err.Error()
+		} else if resp != nil {
+			entry.Error, entry.RowsAffected = resp.GetError(), resp.GetRowsAffected()
 		}
+		writeSQLAudit(ctx, conn, o.logger, entry)
+	}()
 
-		defer func() {
-			if txErr := tx.Rollback(ctx); txErr != nil && txErr != pgx.ErrTxClosed {
-				o.logger.Error("ExecuteSQL: Failed to rollback transaction", logger.Error(txErr))
-			}
-		}()
+	if guardErr != nil {
+		return nil, status.Error(codes.PermissionDenied, guardErr.Error())
 	}
 
-	if tx != nil {
-		rows, err = tx.Query(ctx, req.GetSql(), args...)
-	} else {
-		rows, err = conn.Query(ctx, req.GetSql(), args...)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// User SQL always runs in a guarded transaction, so the row security
+	// policies see its subject.
+	tx, err = beginGuardedTx(ctx, conn, analysis.ReadOnly(), timeout)
+	if err != nil {
+		return &nb.ExecuteSQLResponse{Error: fmt.Sprintf("[ExecuteSQL -> Begin] Не удалось начать транзакцию: %v", err)}, nil
 	}
 
+	defer func() {
+		if txErr := tx.Rollback(ctx); txErr != nil && txErr != pgx.ErrTxClosed {
+			o.logger.Error("ExecuteSQL: Failed to rollback transaction", logger.Error(txErr))
+		}
+	}()
+
+	rows, err = tx.Query(ctx, req.GetSql(), args...)
 	if err != nil {
+		if helper.IsQueryTimeout(err) {
+			return nil, helper.HandleDatabaseError(err, o.logger, "ExecuteSQL")
+		}
 		return &nb.ExecuteSQLResponse{Error: fmt.Sprintf("[ExecuteSQL -> Query] Ошибка выполнения SQL: %v | Query: %s", err, req.GetSql())}, nil
 	}
 	defer rows.Close()
@@ -3445,6 +3825,9 @@ func (o *objectBuilderRepo) ExecuteSQL(ctx context.Context, req *nb.ExecuteSQLRe
 	}
 
 	if err = rows.Err(); err != nil {
+		if helper.IsQueryTimeout(err) {
+			return nil, helper.HandleDatabaseError(err, o.logger, "ExecuteSQL")
+		}
 		return &nb.ExecuteSQLResponse{Error: fmt.Sprintf("[ExecuteSQL -> rows.Err] Ошибка при итерации результата: %v", err)}, nil
 	}
 
@@ -3453,10 +3836,12 @@ func (o *objectBuilderRepo) ExecuteSQL(ctx context.Context, req *nb.ExecuteSQLRe
 		rowsAffected = commandTag.RowsAffected()
 	}
 
-	if tx != nil {
-		if err := tx.Commit(ctx); err != nil {
-			return &nb.ExecuteSQLResponse{Error: fmt.Sprintf("[ExecuteSQL -> Commit] Не удалось закоммитить транзакцию: %v", err)}, nil
-		}
+	if err := tx.Commit(ctx); err != nil {
+		return &nb.ExecuteSQLResponse{Error: fmt.Sprintf("[ExecuteSQL -> Commit] Не удалось закоммитить транзакцию: %v", err)}, nil
+	}
+
+	if !analysis.ReadOnly() {
+		invalidateCache(ctx, req.GetResourceEnvId(), analysis.Tables()...)
 	}
 
 	return &nb.ExecuteSQLResponse{
@@ -3466,6 +3851,25 @@ func (o *objectBuilderRepo) ExecuteSQL(ctx context.Context, req *nb.ExecuteSQLRe
 	}, nil
 }
 
+// restrictSources limits the table, the joined tables and the WITH queries
+// of a select to the rows the subject may read.
+func restrictSources(ctx context.Context, q querier, params *models.QueryParams, subject authz.Subject) (err error) {
+	if params.Table, err = restrictSource(ctx, q, params.Table, subject); err != nil {
+		return err
+	}
+	for i := range params.Joins {
+		if params.Joins[i].Table, err = restrictSource(ctx, q, params.Joins[i].Table, subject); err != nil {
+			return err
+		}
+	}
+	for i := range params.WithQueries {
+		if err = restrictSources(ctx, q, &params.WithQueries[i].Query, subject); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func executeSelect(params models.QueryParams, sb squirrel.StatementBuilderType) (string, []any, error) {
 	query := sb.Select(params.Columns...).From(params.Table)
 
@@ -3513,6 +3917,51 @@ func executeSelect(params models.QueryParams, sb squirrel.StatementBuilderType)
 	return sql, args, nil
 }
 
+// applyAggregationAccess shows the rows of an aggregation SELECT with the
+// field access of the reader on the table it reads: columns named after
+// fields of the table are decrypted, masked or dropped as in a list of its
+// items. The cache keeps the rows as read, so the access applies to cached
+// results too.
+func applyAggregationAccess(result *structpb.Struct, access *helper.FieldAccess) (*structpb.Struct, error) {
+	if access == nil {
+		return result, nil
+	}
+
+	response := result.AsMap()
+	for _, row := range cast.ToSlice(response["data"]) {
+		if row, ok := row.(map[string]any); ok {
+			access.Apply(row)
+		}
+	}
+
+	return helper.ConvertMapToStruct(response)
+}
+
+// authorizeAggregationUpdate applies the write permissions of the subject of
+// data to an aggregation UPDATE, as itemsRepo.Update does: the role needs
+// the update permission, fields it may not edit are dropped and the rows
+// are limited to the ones its update rule allows.
+func authorizeAggregationUpdate(ctx context.Context, q querier, params *models.QueryParams, data map[string]any) error {
+	permissions, err := authorizeWrite(ctx, q, params.Table, authz.Update, data)
+	if err != nil {
+		return err
+	}
+	permissions.Strip(params.Data)
+
+	condition, err := rowCondition(ctx, q, params.Table, pq.QuoteIdentifier(params.Table), authz.Resolve(ctx, data), authz.Update)
+	if err != nil {
+		return err
+	}
+	switch {
+	case condition == "":
+	case params.Where == "":
+		params.Where = condition
+	default:
+		params.Where = "(" + params.Where + ") AND " + condition
+	}
+	return nil
+}
+
 func executeUpdate(params models.QueryParams, sb squirrel.StatementBuilderType) (string, []any, error) {
 	if len(params.Data) == 0 {
 		return "", nil, errors.New("no data provided for update")
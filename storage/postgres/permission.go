@@ -10,14 +10,18 @@ import (
 	"ucode/ucode_go_object_builder_service/config"
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
 	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
 	"ucode/ucode_go_object_builder_service/pkg/helper"
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
 	"ucode/ucode_go_object_builder_service/storage"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/lib/pq"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
@@ -125,6 +129,16 @@ func (p *permissionRepo) CreateDefaultPermission(ctx context.Context, req *nb.Cr
 		return err
 	}
 
+	// Roles with a parent inherit the permissions they have no rows for
+	var inherits bool
+	err = conn.QueryRow(ctx, `SELECT parent_id IS NOT NULL FROM role WHERE guid::TEXT = $1`, req.RoleId).Scan(&inherits)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return errors.Wrap(err, "when get role parent")
+	}
+	if inherits {
+		return nil
+	}
+
 	query := `
 		SELECT
 			t.id,
@@ -601,11 +615,13 @@ func (p *permissionRepo) CreateDefaultPermission(ctx context.Context, req *nb.Cr
 		}
 	}
 
+	// Child custom permissions are left empty so they inherit their parent's access.
 	query = `
-		INSERT INTO custom_permission_access (id, custom_permission_id, role_id, client_type_id)
-		SELECT uuid_generate_v4(), cp.id, r.guid, r.client_type_id
+		INSERT INTO custom_permission_access (id, custom_permission_id, role_id, client_type_id, "read", "write", "update", "delete")
+		SELECT uuid_generate_v4(), cp.id, r.guid, r.client_type_id, a.value, a.value, a.value, a.value
 		FROM custom_permission cp
 		JOIN role r ON r.guid = $1
+		CROSS JOIN LATERAL (SELECT CASE WHEN cp.parent_id IS NULL THEN 'No' END AS value) a
 		WHERE r.client_type_id IS NOT NULL
 		  AND (cp.client_type_id IS NULL OR cp.client_type_id = r.client_type_id)
 		ON CONFLICT (custom_permission_id, role_id, client_type_id) DO NOTHING
@@ -683,7 +699,8 @@ func (p *permissionRepo) GetListWithRoleAppTablePermissions(ctx context.Context,
     		COALESCE(rp."group", 'Yes') AS group,
     		COALESCE(rp."excel_menu", 'Yes') AS excel_menu,
     		COALESCE(rp."tab_group", 'Yes') AS tab_group,
-			COALESCE(rp."search_button", 'Yes') AS search_button
+			COALESCE(rp."search_button", 'Yes') AS search_button,
+			COALESCE(rp.conditions, '{}') AS conditions
 		FROM "table" t
 		LEFT JOIN record_permission rp ON t.slug = rp.table_slug AND rp.role_id = $1
 		WHERE t.id NOT IN (SELECT unnest($2::uuid[]))
@@ -701,6 +718,7 @@ func (p *permissionRepo) GetListWithRoleAppTablePermissions(ctx context.Context,
 				CustomPermission:  &nb.RoleWithAppTablePermissions_Table_CustomPermission{},
 				Attributes:        structpb.NewNullValue().GetStructValue()}
 			attributes []byte
+			conditions []byte
 			attrStruct *structpb.Struct
 			guid       sql.NullString
 		)
@@ -735,6 +753,7 @@ func (p *permissionRepo) GetListWithRoleAppTablePermissions(ctx context.Context,
 			&table.CustomPermission.ExcelMenu,
 			&table.CustomPermission.TabGroup,
 			&table.CustomPermission.SearchButton,
+			&conditions,
 		)
 		if err != nil {
 			return nil, errors.Wrap(err, "GetListWithRoleAppTablePermissions => when scan table resp")
@@ -742,6 +761,9 @@ func (p *permissionRepo) GetListWithRoleAppTablePermissions(ctx context.Context,
 		if err := json.Unmarshal(attributes, &attrStruct); err != nil {
 			return nil, errors.Wrap(err, "GetListWithRoleAppTablePermissions => when unmarshl table resp")
 		}
+		if err := json.Unmarshal(conditions, &table.RecordPermissions.Conditions); err != nil {
+			return nil, errors.Wrap(err, "GetListWithRoleAppTablePermissions => when unmarshal conditions")
+		}
 
 		table.Attributes = attrStruct
 
@@ -755,7 +777,9 @@ func (p *permissionRepo) GetListWithRoleAppTablePermissions(ctx context.Context,
 			"table_slug",
 			"field_id",
 			"edit_permission",
-			"view_permission"
+			"view_permission",
+			COALESCE("mask", ''),
+			"decrypt_permission"
 		FROM "field_permission" WHERE role_id = $1`
 
 	rowsFieldPermission, err := conn.Query(ctx, queryFieldPermission, req.GetRoleId())
@@ -773,6 +797,8 @@ func (p *permissionRepo) GetListWithRoleAppTablePermissions(ctx context.Context,
 			&fp.FieldId,
 			&fp.EditPermission,
 			&fp.ViewPermission,
+			&fp.Mask,
+			&fp.DecryptPermission,
 		)
 		if err != nil {
 			return nil, errors.Wrap(err, "GetListWithRoleAppTablePermissions => when scan field_permission resp")
@@ -1036,12 +1062,14 @@ func (p *permissionRepo) GetListWithRoleAppTablePermissions(ctx context.Context,
 			if field.GetGuid() != "" {
 				temp := field
 				fieldPermission = nb.RoleWithAppTablePermissions_Table_FieldPermission{
-					FieldId:        temp.FieldId,
-					TableSlug:      table.Slug,
-					ViewPermission: temp.ViewPermission,
-					EditPermission: temp.EditPermission,
-					Label:          field.Label,
-					Attributes:     field.Attributes,
+					FieldId:           temp.FieldId,
+					TableSlug:         table.Slug,
+					ViewPermission:    temp.ViewPermission,
+					EditPermission:    temp.EditPermission,
+					Label:             field.Label,
+					Attributes:        field.Attributes,
+					Mask:              temp.Mask,
+					DecryptPermission: temp.DecryptPermission,
 				}
 			} else {
 				fieldPermission = nb.RoleWithAppTablePermissions_Table_FieldPermission{
@@ -1293,7 +1321,8 @@ func (p *permissionRepo) UpdateRoleAppTablePermissions(ctx context.Context, req
 		columns = $19,
 		"group" = $20,
 		excel_menu = $21,
-		is_have_condition = $23
+		conditions = COALESCE($24::JSONB, conditions),
+		is_have_condition = $23 OR COALESCE($24::JSONB, conditions) <> '{}'
 	WHERE table_slug = $1 AND role_id = $22
 	`
 
@@ -1320,16 +1349,20 @@ func (p *permissionRepo) UpdateRoleAppTablePermissions(ctx context.Context, req
 		columns,
 		"group",
 		excel_menu,
+		conditions,
 		is_have_condition
-	) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23)`
+	) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,
+		COALESCE($24::JSONB, '{}'), $23 OR COALESCE($24::JSONB, '{}') <> '{}')`
 
 	fieldPermission := `UPDATE "field_permission" SET
 		edit_permission = $2,
-		view_permission = $3
+		view_permission = $3,
+		mask = NULLIF($5, ''),
+		decrypt_permission = $6
 	WHERE field_id = $1 AND role_id = $4
 	`
 
-	fieldPermissionInsert := `INSERT INTO "field_permission" (field_id, role_id, edit_permission, view_permission) VALUES ($1,$2,$3,$4)`
+	fieldPermissionInsert := `INSERT INTO "field_permission" (field_id, role_id, edit_permission, view_permission, mask, decrypt_permission) VALUES ($1,$2,$3,$4,NULLIF($5, ''),$6)`
 
 	viewPermission := `UPDATE "view_permission" SET
 		view = $2,
@@ -1358,6 +1391,19 @@ func (p *permissionRepo) UpdateRoleAppTablePermissions(ctx context.Context, req
 			continue
 		}
 
+		// Row-level rules, kept as they are when the request has none
+		var conditions any
+		if rp.GetConditions() != nil {
+			raw, err := json.Marshal(rp.GetConditions().AsMap())
+			if err != nil {
+				return errors.Wrap(err, "UpdateRoleAppTablePermissions: marshal conditions")
+			}
+			if _, err := authz.ParseConditions(raw); err != nil {
+				return err
+			}
+			conditions = raw
+		}
+
 		// AutomaticFilter method: Read
 		for _, read := range table.GetAutomaticFilters().GetRead() {
 			id := read.GetGuid()
@@ -1468,6 +1514,7 @@ func (p *permissionRepo) UpdateRoleAppTablePermissions(ctx context.Context, req
 			cp.ExcelMenu,
 			req.Data.Guid,
 			isHaveCondition,
+			conditions,
 		)
 		if err != nil {
 			return errors.Wrap(err, "UpdateRoleAppTablePermissions: update record permission")
@@ -1495,6 +1542,7 @@ func (p *permissionRepo) UpdateRoleAppTablePermissions(ctx context.Context, req
 				cp.Group,
 				cp.ExcelMenu,
 				isHaveCondition,
+				conditions,
 			)
 			if err != nil {
 				return errors.Wrap(err, "UpdateRoleAppTablePermissions: insert record permission")
@@ -1502,14 +1550,17 @@ func (p *permissionRepo) UpdateRoleAppTablePermissions(ctx context.Context, req
 		}
 
 		for _, fp := range table.FieldPermissions {
+			if _, err = authz.ParseMask(fp.Mask); err != nil {
+				return err
+			}
 
-			fip, err := tx.Exec(ctx, fieldPermission, fp.FieldId, fp.EditPermission, fp.ViewPermission, req.Data.Guid)
+			fip, err := tx.Exec(ctx, fieldPermission, fp.FieldId, fp.EditPermission, fp.ViewPermission, req.Data.Guid, fp.Mask, fp.DecryptPermission)
 			if err != nil {
 				return errors.Wrap(err, "UpdateRoleAppTablePermissions: update field permission")
 			}
 
 			if fip.RowsAffected() == 0 {
-				_, err := tx.Exec(ctx, fieldPermissionInsert, fp.FieldId, req.Data.Guid, fp.EditPermission, fp.ViewPermission)
+				_, err := tx.Exec(ctx, fieldPermissionInsert, fp.FieldId, req.Data.Guid, fp.EditPermission, fp.ViewPermission, fp.Mask, fp.DecryptPermission)
 				if err != nil {
 					return errors.Wrap(err, "UpdateRoleAppTablePermissions: insert field permission")
 				}
@@ -1522,6 +1573,10 @@ func (p *permissionRepo) UpdateRoleAppTablePermissions(ctx context.Context, req
 				return errors.Wrap(err, "UpdateRoleAppTablePermissions: update view permission")
 			}
 		}
+
+		if err = syncRowSecurity(ctx, tx, table.Slug); err != nil {
+			return err
+		}
 	}
 
 	if err = tx.Commit(ctx); err != nil {
@@ -1653,11 +1708,25 @@ func (p *permissionRepo) UpdatePermissionsByTableSlug(ctx context.Context, req *
 		return errors.Wrap(err, "role not found")
 	}
 
+	var conditions any
+	if c := req.Table.RecordPermissions.GetConditions(); c != nil {
+		raw, err := json.Marshal(c.AsMap())
+		if err != nil {
+			return errors.Wrap(err, "UpdatePermissionsByTableSlug: marshal conditions")
+		}
+		if _, err = authz.ParseConditions(raw); err != nil {
+			return err
+		}
+		conditions = raw
+	}
+
 	query = `UPDATE record_permission SET
 		read = $3,
 		write = $4,
 		update = $5,
-		delete = $6
+		delete = $6,
+		conditions = COALESCE($7::JSONB, conditions),
+		is_have_condition = is_have_condition OR COALESCE($7::JSONB, conditions) <> '{}'
 	WHERE role_id = $1 AND table_slug = $2`
 
 	_, err = tx.Exec(ctx, query,
@@ -1667,6 +1736,7 @@ func (p *permissionRepo) UpdatePermissionsByTableSlug(ctx context.Context, req *
 		req.Table.RecordPermissions.Write,
 		req.Table.RecordPermissions.Update,
 		req.Table.RecordPermissions.Delete,
+		conditions,
 	)
 	if err != nil {
 		return errors.Wrap(err, "UpdatePermissionsByTableSlug: update record permission")
@@ -1674,13 +1744,21 @@ func (p *permissionRepo) UpdatePermissionsByTableSlug(ctx context.Context, req *
 
 	query = `UPDATE field_permission SET
 		edit_permission = $3,
-		view_permission = $4
+		view_permission = $4,
+		mask = NULLIF($5, ''),
+		decrypt_permission = $6
 	WHERE role_id = $1 AND field_id = $2`
 
 	for _, fp := range req.Table.FieldPermissions {
+		if _, err = authz.ParseMask(fp.Mask); err != nil {
+			return err
+		}
+
 		_, err := tx.Exec(ctx, query, roleId, fp.FieldId,
 			fp.EditPermission,
 			fp.ViewPermission,
+			fp.Mask,
+			fp.DecryptPermission,
 		)
 		if err != nil {
 			return errors.Wrap(err, "UpdatePermissionsByTableSlug: update field permission")
@@ -1700,6 +1778,10 @@ func (p *permissionRepo) UpdatePermissionsByTableSlug(ctx context.Context, req *
 		}
 	}
 
+	if err = syncRowSecurity(ctx, tx, req.Table.Slug); err != nil {
+		return err
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		return errors.Wrap(err, "UpdatePermissionsByTableSlug: commit transaction")
 	}
@@ -1718,7 +1800,8 @@ func getTablePermission(conn *psqlpool.Pool, roleId, tableSlug string, ctx conte
 				'read', rp.read,
 				'write', rp.write,
 				'update', rp.update,
-				'delete', rp.delete
+				'delete', rp.delete,
+				'conditions', COALESCE(rp.conditions, '{}')
 			) as record_permissions,
 			COALESCE(
 				jsonb_agg(
@@ -1726,6 +1809,8 @@ func getTablePermission(conn *psqlpool.Pool, roleId, tableSlug string, ctx conte
 						'field_id', fp.field_id,
 						'view_permission', fp.view_permission,
 						'edit_permission', fp.edit_permission,
+						'mask', COALESCE(fp.mask, ''),
+						'decrypt_permission', fp.decrypt_permission,
 						'label', fp.label,
 						'table_slug', fp.table_slug
 				)) FILTER (WHERE fp.field_id IS NOT NULL), '[]'::jsonb
@@ -1789,12 +1874,6 @@ func (b *permissionRepo) GetTablePermission(ctx context.Context, req *nb.GetTabl
 		return nil, err
 	}
 
-	var roleId any = req.RoleId
-
-	if req.RoleId == "" {
-		roleId = nil
-	}
-
 	if req.TableSlug == "template" {
 		return &nb.GetTablePermissionResponse{IsHavePermission: true}, nil
 	}
@@ -1805,14 +1884,17 @@ func (b *permissionRepo) GetTablePermission(ctx context.Context, req *nb.GetTabl
         WHERE table_slug = $1
         AND %s = 'Yes'
         AND (
-            (role_id = $2)
+            (role_id::TEXT = (
+                SELECT role_id::TEXT FROM effective_record_permission
+                WHERE table_slug = $1 AND effective_role_id::TEXT = $2
+            ))
             OR (role_id IS NULL)
             OR (is_public = true)
         )
     )`, req.Method)
 
 	var hasPermission bool
-	err = conn.QueryRow(ctx, query, req.TableSlug, roleId).Scan(&hasPermission)
+	err = conn.QueryRow(ctx, query, req.TableSlug, req.RoleId).Scan(&hasPermission)
 	if err != nil {
 		return &nb.GetTablePermissionResponse{IsHavePermission: false}, err
 	}
@@ -1821,3 +1903,54 @@ func (b *permissionRepo) GetTablePermission(ctx context.Context, req *nb.GetTabl
 		IsHavePermission: hasPermission,
 	}, nil
 }
+
+func (p *permissionRepo) SetRowLevelSecurity(ctx context.Context, req *nb.SetRowLevelSecurityRequest) (err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "permission.SetRowLevelSecurity")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return err
+	}
+
+	if req.Enabled {
+		if err := checkRowSecuritySupported(ctx, conn); err != nil {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "SetRowLevelSecurity: begin transaction")
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	tag, err := tx.Exec(ctx, `UPDATE "table" SET row_level_security = $2 WHERE slug = $1`, req.TableSlug, req.Enabled)
+	if err != nil {
+		return errors.Wrap(err, "SetRowLevelSecurity: update table")
+	}
+	if tag.RowsAffected() == 0 {
+		return status.Errorf(codes.NotFound, "table %s not found", req.TableSlug)
+	}
+
+	if req.Enabled {
+		err = syncRowSecurity(ctx, tx, req.TableSlug)
+	} else if err = dropRowSecurity(ctx, tx, req.TableSlug); err == nil {
+		_, err = tx.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY`, pq.QuoteIdentifier(req.TableSlug)))
+		err = errors.Wrap(err, "SetRowLevelSecurity: disable row level security")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errors.Wrap(err, "SetRowLevelSecurity: commit transaction")
+	}
+
+	return nil
+}
@@ -15,6 +15,7 @@ import (
 
 type Store struct {
 	db                    *psqlpool.Pool
+	cfg                   config.Config
 	logger                logger.LoggerI
 	grpcClient            client.ServiceManagerI
 	builderProject        storage.BuilderProjectRepoI
@@ -61,6 +62,7 @@ func NewPostgres(ctx context.Context, cfg config.Config, grpcClient client.Servi
 	}
 
 	config.MaxConns = cfg.PostgresMaxConnections
+	psqlpool.BypassRowSecurity(config)
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
@@ -74,6 +76,7 @@ func NewPostgres(ctx context.Context, cfg config.Config, grpcClient client.Servi
 
 	return &Store{
 		db:         dbPool,
+		cfg:        cfg,
 		grpcClient: grpcClient,
 		logger:     logger,
 	}, err
@@ -185,7 +188,7 @@ func (s *Store) Permission() storage.PermissionRepoI {
 
 func (s *Store) Items() storage.ItemsRepoI {
 	if s.items == nil {
-		s.items = NewItemsRepo(s.db, s.grpcClient, s.logger)
+		s.items = NewItemsRepo(s.db, s.cfg, s.grpcClient, s.logger)
 	}
 	return s.items
 }
@@ -216,7 +219,7 @@ func (s *Store) CustomEvent() storage.CustomEventRepoI {
 
 func (s *Store) VersionHistory() storage.VersionHistoryRepoI {
 	if s.versionHistory == nil {
-		s.versionHistory = NewVersionHistoryRepo(s.db)
+		s.versionHistory = NewVersionHistoryRepo(s.db, s.cfg)
 	}
 
 	return s.versionHistory
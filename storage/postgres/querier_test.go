@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeQuerier answers QueryRow with the first row whose match the query
+// contains, pgx.ErrNoRows otherwise, Query with the first result whose
+// match it contains, no rows otherwise, and records the queries it is
+// given, and the arguments of the statements.
+type fakeQuerier struct {
+	rows     []fakeRow
+	results  []fakeResult
+	queries  []string
+	execs    []string
+	execArgs [][]any
+}
+
+type fakeResult struct {
+	match string
+	rows  [][]any
+}
+
+type fakeRow struct {
+	match  string
+	values []any
+	err    error
+}
+
+func (f *fakeQuerier) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	f.queries = append(f.queries, sql)
+	for _, row := range f.rows {
+		if strings.Contains(sql, row.match) {
+			return row
+		}
+	}
+	return fakeRow{err: pgx.ErrNoRows}
+}
+
+func (f *fakeQuerier) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	f.queries = append(f.queries, sql)
+	for _, result := range f.results {
+		if strings.Contains(sql, result.match) {
+			return &fakeRows{rows: result.rows}, nil
+		}
+	}
+	return &fakeRows{}, nil
+}
+
+func (f *fakeQuerier) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	f.execs = append(f.execs, sql)
+	f.execArgs = append(f.execArgs, args)
+	return pgconn.NewCommandTag("SELECT 1"), nil
+}
+
+// ran reports whether a query or statement containing fragment was run.
+func (f *fakeQuerier) ran(fragment string) bool {
+	for _, sql := range append(append([]string{}, f.queries...), f.execs...) {
+		if strings.Contains(sql, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(dest) != len(r.values) {
+		return errors.New("fakeRow: wrong number of values")
+	}
+	for i, value := range r.values {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+type fakeRows struct {
+	rows [][]any
+	next int
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+	return r.next <= len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	return fakeRow{values: r.rows[r.next-1]}.Scan(dest...)
+}
+
+func (r *fakeRows) Values() ([]any, error) { return r.rows[r.next-1], nil }
+
+func (r *fakeRows) Close()                                       {}
+func (r *fakeRows) Err() error                                   { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag                { return pgconn.NewCommandTag("SELECT") }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *fakeRows) RawValues() [][]byte                          { return nil }
+func (r *fakeRows) Conn() *pgx.Conn                              { return nil }
@@ -19,6 +19,7 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type relationRepo struct {
@@ -52,6 +53,11 @@ func (r *relationRepo) Create(ctx context.Context, data *nb.CreateRelationReques
 		data.Id = uuid.New().String()
 	}
 
+	onDelete, err := helper.OnDeletePolicy(data.GetAttributes().AsMap())
+	if err != nil {
+		return nil, err
+	}
+
 	tx, err := conn.Begin(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to start transaction")
@@ -71,6 +77,11 @@ func (r *relationRepo) Create(ctx context.Context, data *nb.CreateRelationReques
 	switch data.Type {
 	case config.MANY2DYNAMIC:
 		fieldFrom = data.RelationFieldSlug
+
+		err = helper.CreateDynamicLookupField(ctx, models.RelationHelper{Tx: tx, RoleIDs: roles}, data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create dynamic lookup field")
+		}
 	case config.MANY2MANY:
 		fieldFrom = data.TableTo + "_ids"
 		fieldTo = data.TableFrom + "_ids"
@@ -647,8 +658,9 @@ func (r *relationRepo) Create(ctx context.Context, data *nb.CreateRelationReques
 			"object_id_from_jwt",
 			"cascading_tree_table_slug", 
 			"cascading_tree_field_slug",
-			"auto_filters" 
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+			"auto_filters",
+			"attributes"
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 		RETURNING 
 			"id", 
 			"type",
@@ -686,6 +698,7 @@ func (r *relationRepo) Create(ctx context.Context, data *nb.CreateRelationReques
 		data.CascadingTreeTableSlug,
 		data.CascadingTreeFieldSlug,
 		autoFilters,
+		data.GetAttributes().AsMap(),
 	).Scan(
 		&resp.Id,
 		&resp.Type,
@@ -817,11 +830,18 @@ func (r *relationRepo) Create(ctx context.Context, data *nb.CreateRelationReques
 		FieldFrom:    fieldFrom,
 		FieldTo:      fieldTo,
 		RelationType: data.Type,
+		OnDelete:     onDelete,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to exec relation")
 	}
 
+	if data.Type == config.RECURSIVE && cast.ToBool(data.GetAttributes().AsMap()["materialized_path"]) {
+		if err = helper.EnableTreePath(ctx, tx, data.TableFrom, fieldTo); err != nil {
+			return nil, err
+		}
+	}
+
 	resp.Attributes = data.Attributes
 
 	query = `
@@ -858,12 +878,24 @@ func (r *relationRepo) CreateWithTx(ctx context.Context, data *nb.CreateRelation
 		data.Id = uuid.New().String()
 	}
 
+	onDelete, err := helper.OnDeletePolicy(data.GetAttributes().AsMap())
+	if err != nil {
+		return nil, err
+	}
+
 	roles, err := helper.RolesFind(ctx, models.RelationHelper{Tx: tx})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to find roles")
 	}
 
 	switch data.Type {
+	case config.MANY2DYNAMIC:
+		fieldFrom = data.RelationFieldSlug
+
+		err = helper.CreateDynamicLookupField(ctx, models.RelationHelper{Tx: tx, RoleIDs: roles}, data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create dynamic lookup field")
+		}
 	case config.MANY2MANY:
 		fieldFrom = data.TableTo + "_ids"
 		fieldTo = data.TableFrom + "_ids"
@@ -1440,8 +1472,9 @@ func (r *relationRepo) CreateWithTx(ctx context.Context, data *nb.CreateRelation
 			"object_id_from_jwt",
 			"cascading_tree_table_slug", 
 			"cascading_tree_field_slug",
-			"auto_filters" 
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+			"auto_filters",
+			"attributes"
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 		RETURNING 
 			"id", 
 			"type",
@@ -1479,6 +1512,7 @@ func (r *relationRepo) CreateWithTx(ctx context.Context, data *nb.CreateRelation
 		data.CascadingTreeTableSlug,
 		data.CascadingTreeFieldSlug,
 		autoFilters,
+		data.GetAttributes().AsMap(),
 	).Scan(
 		&resp.Id,
 		&resp.Type,
@@ -1610,11 +1644,18 @@ func (r *relationRepo) CreateWithTx(ctx context.Context, data *nb.CreateRelation
 		FieldFrom:    fieldFrom,
 		FieldTo:      fieldTo,
 		RelationType: data.Type,
+		OnDelete:     onDelete,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to exec relation")
 	}
 
+	if data.Type == config.RECURSIVE && cast.ToBool(data.GetAttributes().AsMap()["materialized_path"]) {
+		if err = helper.EnableTreePath(ctx, tx, data.TableFrom, fieldTo); err != nil {
+			return nil, err
+		}
+	}
+
 	resp.Attributes = data.Attributes
 
 	query = `
@@ -2020,6 +2061,14 @@ func (r *relationRepo) Update(ctx context.Context, data *nb.UpdateRelationReques
 		return resp, errors.New("relation table slug is required")
 	}
 
+	// The foreign key and the tree path live on the tables the relation
+	// was created between, whatever the request says.
+	var storedFrom, storedTo, fieldFrom, fieldTo sql.NullString
+	err = tx.QueryRow(ctx, `SELECT table_from, table_to, field_from, field_to FROM "relation" WHERE id = $1`, data.Id).Scan(&storedFrom, &storedTo, &fieldFrom, &fieldTo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get relation")
+	}
+
 	query := `
 	UPDATE "relation"
 	SET 
@@ -2035,7 +2084,8 @@ func (r *relationRepo) Update(ctx context.Context, data *nb.UpdateRelationReques
 		"object_id_from_jwt" = $11,
 		"cascading_tree_table_slug" = $12, 
 		"cascading_tree_field_slug" = $13,
-		"auto_filters" = $14
+		"auto_filters" = $14,
+		"attributes" = COALESCE($15, "attributes")
 	WHERE "id" = $1 AND "type" = $4
 	RETURNING 
 		"id", 
@@ -2063,6 +2113,7 @@ func (r *relationRepo) Update(ctx context.Context, data *nb.UpdateRelationReques
 		data.CascadingTreeTableSlug,
 		data.CascadingTreeFieldSlug,
 		data.AutoFilters,
+		relationAttributes(data.GetAttributes()),
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to update relation")
@@ -2072,6 +2123,37 @@ func (r *relationRepo) Update(ctx context.Context, data *nb.UpdateRelationReques
 		return nil, errors.New("relation type cannot be changed")
 	}
 
+	var (
+		attributes            = data.GetAttributes().GetFields()
+		_, onDeleteSet        = attributes["on_delete"]
+		treePath, treePathSet = attributes["materialized_path"]
+	)
+
+	treePathSet = treePathSet && data.Type == config.RECURSIVE
+
+	if onDeleteSet {
+		onDelete, err := helper.OnDeletePolicy(data.GetAttributes().AsMap())
+		if err != nil {
+			return nil, err
+		}
+
+		err = helper.ReplaceForeignKey(ctx, tx, data.Type, storedFrom.String, storedTo.String, fieldFrom.String, fieldTo.String, onDelete)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if treePathSet {
+		if treePath.GetBoolValue() {
+			err = helper.EnableTreePath(ctx, tx, storedFrom.String, fieldTo.String)
+		} else {
+			err = helper.DisableTreePath(ctx, tx, storedFrom.String)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	resp = &nb.RelationForGetAll{
 		Id:                     data.Id,
 		Type:                   data.Type,
@@ -3035,3 +3117,12 @@ func (r *relationRepo) GetRelationsByTableFrom(ctx context.Context, projectID st
 
 	return relations, nil
 }
+
+// relationAttributes returns the attributes to store on the relation row,
+// or nil to keep the stored ones.
+func relationAttributes(attributes *structpb.Struct) map[string]any {
+	if attributes == nil {
+		return nil
+	}
+	return attributes.AsMap()
+}
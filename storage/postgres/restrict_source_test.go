@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRestrictSource(t *testing.T) {
+	const restricted = `(SELECT * FROM "orders" r WHERE COALESCE(r."status" IS DISTINCT FROM 'closed', FALSE))`
+
+	var (
+		role = authz.Subject{RoleId: "r"}
+		rule = fakeRow{match: "SELECT conditions", values: []any{[]byte(`{"read": {"field": "status", "op": "neq", "value": "closed"}}`)}}
+	)
+
+	tests := []struct {
+		name    string
+		subject authz.Subject
+		ruled   bool
+		source  string
+		want    string
+		code    codes.Code
+	}{
+		{name: "table", subject: role, ruled: true, source: "orders", want: restricted + ` "orders"`},
+		{name: "table with alias", subject: role, ruled: true, source: `"orders" AS o`, want: restricted + " o"},
+		{name: "qualified table", subject: role, ruled: true, source: "public.orders o", want: restricted + " o"},
+		{name: "table without rule", subject: role, source: "orders o", want: "orders o"},
+		{name: "subquery of a ruled table", subject: role, ruled: true, source: "(SELECT * FROM orders) o", code: codes.PermissionDenied},
+		{name: "table list", subject: role, ruled: true, source: "orders o, customers c", code: codes.PermissionDenied},
+		{name: "subquery without rules", subject: role, source: "(SELECT * FROM orders) o", want: "(SELECT * FROM orders) o"},
+		{name: "system catalog", subject: role, source: "(SELECT * FROM pg_catalog.pg_roles) r", code: codes.PermissionDenied},
+		{name: "write", subject: role, source: "(DELETE FROM orders RETURNING *) d", code: codes.PermissionDenied},
+		{name: "unknown function", subject: role, source: "report_orders() o", code: codes.PermissionDenied},
+		{name: "unrestricted subject", ruled: true, source: "(SELECT * FROM orders) o", want: "(SELECT * FROM orders) o"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{results: []fakeResult{{match: "information_schema.columns", rows: [][]any{{"status"}}}}}
+			if tt.ruled {
+				q.rows = append(q.rows, rule)
+			}
+
+			got, err := restrictSource(context.Background(), q, tt.source, tt.subject)
+			if tt.code != codes.OK {
+				assert.Equal(t, tt.code, status.Code(err), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRestrictSourcesWithQueries(t *testing.T) {
+	q := &fakeQuerier{
+		rows:    []fakeRow{{match: "SELECT conditions", values: []any{[]byte(`{"read": {"field": "status", "op": "neq", "value": "closed"}}`)}}},
+		results: []fakeResult{{match: "information_schema.columns", rows: [][]any{{"status"}}}},
+	}
+	params := &models.QueryParams{
+		Table:       "totals",
+		WithQueries: []models.WithQuery{{Name: "totals", Query: models.QueryParams{Table: "(SELECT * FROM orders) o"}}},
+	}
+
+	err := restrictSources(context.Background(), q, params, authz.Subject{RoleId: "r"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
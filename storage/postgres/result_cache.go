@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"ucode/ucode_go_object_builder_service/pkg/cache"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	"ucode/ucode_go_object_builder_service/pkg/sqlguard"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// cacheableTables returns the tables a generated query reads, and whether its
+// result may be cached: the query has to be read-only, its analysis has to
+// name every table it reads and its main table has to be marked is_cached.
+func cacheableTables(ctx context.Context, conn helper.RowQuerier, tableSlug, query string) ([]string, bool) {
+	if fields := strings.Fields(tableSlug); len(fields) > 0 {
+		tableSlug = strings.Trim(fields[0], `"`)
+	}
+
+	var isCached bool
+	err := conn.QueryRow(ctx, `SELECT COALESCE(is_cached, false) FROM "table" WHERE slug = $1`, tableSlug).Scan(&isCached)
+	if err != nil || !isCached {
+		return nil, false
+	}
+
+	analysis, err := sqlguard.Analyze(query)
+	if err != nil || !analysis.ReadOnly() || !analysis.Complete() {
+		return nil, false
+	}
+
+	return append(analysis.Tables(), tableSlug), true
+}
+
+func getCachedStruct(ctx context.Context, entry cache.Entry) (*structpb.Struct, bool) {
+	data, ok := cache.Default().Get(ctx, entry)
+	if !ok {
+		return nil, false
+	}
+
+	var result structpb.Struct
+	if err := proto.Unmarshal(data, &result); err != nil {
+		return nil, false
+	}
+	return &result, true
+}
+
+func setCachedStruct(ctx context.Context, entry cache.Entry, result *structpb.Struct) {
+	data, err := proto.Marshal(result)
+	if err != nil {
+		return
+	}
+	cache.Default().Set(ctx, entry, data, 0)
+}
+
+// invalidateCache drops the cached results that read one of tables in the
+// database of the pool of projectId.
+func invalidateCache(ctx context.Context, projectId string, tables ...string) {
+	if conn, err := psqlpool.Get(projectId); err == nil {
+		helper.InvalidateCache(ctx, conn, tables...)
+	}
+}
+
+// cacheScope is the scope of the cache entries read through conn.
+func cacheScope(conn *psqlpool.Pool) string {
+	scope, _ := helper.CacheScope(conn)
+	return scope
+}
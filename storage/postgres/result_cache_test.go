@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheScope(t *testing.T) {
+	pool := func(dsn string) *psqlpool.Pool {
+		db, err := pgxpool.New(context.Background(), dsn)
+		require.NoError(t, err)
+		t.Cleanup(db.Close)
+		return &psqlpool.Pool{Db: db}
+	}
+
+	var (
+		project  = pool("postgres://project@db.local:5432/tenant?sslmode=disable")
+		resource = pool("postgres://resource@db.local:5432/tenant?sslmode=disable")
+		other    = pool("postgres://project@db.local:5432/other?sslmode=disable")
+	)
+
+	assert.Equal(t, "db.local:5432/tenant", cacheScope(project))
+	assert.Equal(t, cacheScope(project), cacheScope(resource))
+	assert.NotEqual(t, cacheScope(project), cacheScope(other))
+}
+
+func TestCacheableTables(t *testing.T) {
+	tests := []struct {
+		name      string
+		cached    bool
+		query     string
+		tables    []string
+		cacheable bool
+	}{
+		{name: "not cached", query: `SELECT * FROM "order"`},
+		{name: "read only", cached: true, query: `SELECT count(*) FROM "order" JOIN customer ON true`, tables: []string{"customer", "order", "order"}, cacheable: true},
+		{name: "write", cached: true, query: `UPDATE "order" SET total = 0`},
+		{name: "function reads other tables", cached: true, query: `SELECT order_totals() FROM "order"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &fakeQuerier{rows: []fakeRow{{match: "is_cached", values: []any{tt.cached}}}}
+			tables, cacheable := cacheableTables(context.Background(), conn, `"order" o`, tt.query)
+			assert.Equal(t, tt.cacheable, cacheable)
+			assert.Equal(t, tt.tables, tables)
+		})
+	}
+}
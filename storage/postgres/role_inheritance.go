@@ -0,0 +1,362 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A role with a parent inherits every permission it has no row of its own
+// for. The effective_<table> views of the permission tables resolve what a
+// role has, see migration 000092. Permission templates are roles that are
+// only used as parents.
+
+// permissionTables are the tables of the permissions of a role and the
+// columns that identify a permission in them. global_permission has one
+// row per role. Each has an effective_<table> view.
+var permissionTables = []struct {
+	table string
+	key   []string
+}{
+	{"record_permission", []string{"table_slug"}},
+	{"field_permission", []string{"field_id"}},
+	{"view_permission", []string{"view_id"}},
+	{"view_relation_permission", []string{"table_slug", "relation_id"}},
+	{"menu_permission", []string{"menu_id"}},
+	{"action_permission", []string{"table_slug", "custom_event_id"}},
+	{"global_permission", nil},
+	{"custom_permission_access", []string{"custom_permission_id", "client_type_id"}},
+}
+
+func (p *permissionRepo) SetRoleParent(ctx context.Context, req *nb.SetRoleParentRequest) (err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "permission.SetRoleParent")
+	defer dbSpan.Finish()
+
+	if req.RoleId == "" {
+		return status.Error(codes.InvalidArgument, "role_id is required")
+	}
+	if req.ClearOverrides && req.ParentId == "" {
+		return status.Error(codes.InvalidArgument, "clear_overrides needs a parent_id")
+	}
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "SetRoleParent: begin transaction")
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	chain, err := helper.LoadRoleChain(ctx, tx, req.RoleId)
+	if err != nil {
+		return err
+	}
+
+	if req.ParentId == "" {
+		// The role keeps what it inherited as its own rows
+		if len(chain) > 1 {
+			if err = copyPermissions(ctx, tx, req.RoleId, chain[1]); err != nil {
+				return err
+			}
+		}
+	} else {
+		var parentChain []string
+		if parentChain, err = parentRoleChain(ctx, tx, req.ParentId); err != nil {
+			return err
+		}
+		for _, id := range parentChain {
+			if id == req.RoleId {
+				return status.Errorf(codes.FailedPrecondition, "role %s is a parent of %s", req.RoleId, req.ParentId)
+			}
+		}
+		if len(parentChain) >= helper.MaxRoleDepth {
+			return status.Errorf(codes.FailedPrecondition, "roles can have at most %d parents", helper.MaxRoleDepth)
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `UPDATE role SET parent_id = NULLIF($2, '')::UUID WHERE guid::TEXT = $1`, req.RoleId, req.ParentId)
+	if err != nil {
+		return errors.Wrap(err, "SetRoleParent: update role")
+	}
+	if tag.RowsAffected() == 0 {
+		return status.Errorf(codes.NotFound, "role %s not found", req.RoleId)
+	}
+
+	if req.ClearOverrides {
+		tables := []string{"automatic_filter"}
+		for _, t := range permissionTables {
+			tables = append(tables, t.table)
+		}
+		for _, table := range tables {
+			query := fmt.Sprintf(`DELETE FROM %s WHERE role_id::TEXT = $1`, pq.QuoteIdentifier(table))
+			if _, err = tx.Exec(ctx, query, req.RoleId); err != nil {
+				return errors.Wrapf(err, "SetRoleParent: clear %s", table)
+			}
+		}
+	}
+
+	if err = syncAllRowSecurity(ctx, tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errors.Wrap(err, "SetRoleParent: commit transaction")
+	}
+
+	return nil
+}
+
+func (p *permissionRepo) CreatePermissionTemplate(ctx context.Context, req *nb.CreatePermissionTemplateRequest) (resp *nb.PermissionTemplate, err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "permission.CreatePermissionTemplate")
+	defer dbSpan.Finish()
+
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "CreatePermissionTemplate: begin transaction")
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	resp = &nb.PermissionTemplate{Name: req.Name}
+	err = tx.QueryRow(ctx, `INSERT INTO role (name, is_template) VALUES ($1, true) RETURNING guid::TEXT`, req.Name).Scan(&resp.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "CreatePermissionTemplate: insert role")
+	}
+
+	if req.FromRoleId != "" {
+		if _, err = parentRoleChain(ctx, tx, req.FromRoleId); err != nil {
+			return nil, err
+		}
+		if err = copyPermissions(ctx, tx, resp.Id, req.FromRoleId); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, errors.Wrap(err, "CreatePermissionTemplate: commit transaction")
+	}
+
+	if req.FromRoleId == "" {
+		err = p.CreateDefaultPermission(ctx, &nb.CreateDefaultPermissionRequest{ProjectId: req.ProjectId, RoleId: resp.Id})
+		if err != nil {
+			return nil, errors.Wrap(err, "CreatePermissionTemplate: create default permissions")
+		}
+	}
+
+	return resp, nil
+}
+
+func (p *permissionRepo) GetPermissionTemplates(ctx context.Context, req *nb.GetPermissionTemplatesRequest) (resp *nb.GetPermissionTemplatesResponse, err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "permission.GetPermissionTemplates")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT
+			r.guid::TEXT,
+			r.name,
+			(SELECT COUNT(*) FROM role c WHERE c.parent_id = r.guid)::INT
+		FROM role r
+		WHERE r.is_template = true
+		ORDER BY r.name`)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetPermissionTemplates: get templates")
+	}
+	defer rows.Close()
+
+	resp = &nb.GetPermissionTemplatesResponse{}
+	for rows.Next() {
+		template := &nb.PermissionTemplate{}
+		if err := rows.Scan(&template.Id, &template.Name, &template.Roles); err != nil {
+			return nil, errors.Wrap(err, "GetPermissionTemplates: scan template")
+		}
+		resp.Templates = append(resp.Templates, template)
+	}
+
+	return resp, rows.Err()
+}
+
+func (p *permissionRepo) DeletePermissionTemplate(ctx context.Context, req *nb.DeletePermissionTemplateRequest) error {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "permission.DeletePermissionTemplate")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return err
+	}
+
+	var roles int
+	err = conn.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM role c WHERE c.parent_id = r.guid)
+		FROM role r
+		WHERE r.guid::TEXT = $1 AND r.is_template = true`, req.Id).Scan(&roles)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status.Errorf(codes.NotFound, "permission template %s not found", req.Id)
+	}
+	if err != nil {
+		return errors.Wrap(err, "DeletePermissionTemplate: get template")
+	}
+	if roles > 0 {
+		return status.Errorf(codes.FailedPrecondition, "permission template %s is the parent of %d roles", req.Id, roles)
+	}
+
+	if _, err := conn.Exec(ctx, `DELETE FROM role WHERE guid::TEXT = $1 AND is_template = true`, req.Id); err != nil {
+		return errors.Wrap(err, "DeletePermissionTemplate: delete template")
+	}
+
+	return nil
+}
+
+// parentRoleChain is helper.LoadRoleChain for a role that has to exist.
+func parentRoleChain(ctx context.Context, q querier, roleId string) ([]string, error) {
+	var exists bool
+	if err := q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM role WHERE guid::TEXT = $1)`, roleId).Scan(&exists); err != nil {
+		return nil, errors.Wrap(err, "error while getting role")
+	}
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "role %s not found", roleId)
+	}
+	return helper.LoadRoleChain(ctx, q, roleId)
+}
+
+// copyPermissions gives roleId, as rows of its own, the permissions
+// fromRoleId has, inherited ones included, that roleId has no row for.
+func copyPermissions(ctx context.Context, q querier, roleId, fromRoleId string) error {
+	for _, t := range permissionTables {
+		columns, err := copiedColumns(ctx, q, t.table)
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.Exec(ctx, copyPermissionsQuery(t.table, t.key, columns), roleId, fromRoleId); err != nil {
+			return errors.Wrapf(err, "error while copying %s", t.table)
+		}
+	}
+
+	columns, err := copiedColumns(ctx, q, "automatic_filter")
+	if err != nil {
+		return err
+	}
+
+	// Automatic filters are inherited together, by table and method
+	query := fmt.Sprintf(`
+		INSERT INTO automatic_filter (role_id, %s)
+		SELECT $1::UUID, %s
+		FROM effective_automatic_filter p
+		WHERE p.effective_role_id::TEXT = $2
+			AND NOT EXISTS (
+				SELECT 1 FROM automatic_filter o
+				WHERE o.role_id = $1::UUID AND o.table_slug = p.table_slug AND o.method IS NOT DISTINCT FROM p.method AND o.deleted_at IS NULL
+			)`,
+		strings.Join(columns, ", "), prefixColumns("p.", columns))
+	if _, err := q.Exec(ctx, query, roleId, fromRoleId); err != nil {
+		return errors.Wrap(err, "error while copying automatic_filter")
+	}
+
+	return nil
+}
+
+// copyPermissionsQuery copies the rows of the effective view of table for
+// the role $2 to the role $1, but for the keys $1 has a row for.
+func copyPermissionsQuery(table string, key, columns []string) string {
+	same := []string{"o.role_id = $1::UUID"}
+	for _, k := range key {
+		same = append(same, fmt.Sprintf("o.%s IS NOT DISTINCT FROM p.%s", pq.QuoteIdentifier(k), pq.QuoteIdentifier(k)))
+	}
+
+	return fmt.Sprintf(`
+		INSERT INTO %s (role_id, %s)
+		SELECT $1::UUID, %s
+		FROM %s p
+		WHERE p.effective_role_id::TEXT = $2 AND NOT EXISTS (SELECT 1 FROM %s o WHERE %s)`,
+		pq.QuoteIdentifier(table), strings.Join(columns, ", "),
+		prefixColumns("p.", columns),
+		pq.QuoteIdentifier("effective_"+table),
+		pq.QuoteIdentifier(table), strings.Join(same, " AND "))
+}
+
+// copiedColumns returns the quoted columns of a permission table a copy
+// takes, all but its id, role and timestamps.
+func copiedColumns(ctx context.Context, q querier, table string) ([]string, error) {
+	existing, err := tableColumns(ctx, q, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []string
+	for column := range existing {
+		switch column {
+		case "guid", "id", "role_id", "created_at", "updated_at":
+			continue
+		}
+		columns = append(columns, pq.QuoteIdentifier(column))
+	}
+	sort.Strings(columns)
+
+	return columns, nil
+}
+
+func prefixColumns(prefix string, columns []string) string {
+	prefixed := make([]string, len(columns))
+	for i, column := range columns {
+		prefixed[i] = prefix + column
+	}
+	return strings.Join(prefixed, ", ")
+}
+
+// syncAllRowSecurity regenerates the row security policies of every table
+// that has them, as they depend on the parents of the roles.
+func syncAllRowSecurity(ctx context.Context, q querier) error {
+	rows, err := q.Query(ctx, `SELECT slug FROM "table" WHERE row_level_security = true`)
+	if err != nil {
+		return errors.Wrap(err, "error while getting row level security tables")
+	}
+	slugs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return errors.Wrap(err, "error while getting row level security tables")
+	}
+
+	for _, slug := range slugs {
+		if err := syncRowSecurity(ctx, q, slug); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveViewsCoverPermissionTables(t *testing.T) {
+	up, err := os.ReadFile("../../migrations/postgres/000092_create_effective_permission_views.up.sql")
+	require.NoError(t, err)
+	down, err := os.ReadFile("../../migrations/postgres/000092_create_effective_permission_views.down.sql")
+	require.NoError(t, err)
+
+	for _, table := range permissionTables {
+		keys := make([]string, len(table.key))
+		for i, key := range table.key {
+			keys[i] = "'" + key + "'"
+		}
+		entry := fmt.Sprintf("('%s', ARRAY[%s]", table.table, strings.Join(keys, ", "))
+		if len(keys) == 0 {
+			entry = fmt.Sprintf("('%s', ARRAY[]::TEXT[]", table.table)
+		}
+
+		assert.Contains(t, string(up), entry, table.table)
+		assert.Contains(t, string(down), "DROP VIEW IF EXISTS effective_"+table.table+";", table.table)
+	}
+}
+
+func TestCopyPermissionsQuery(t *testing.T) {
+	query := copyPermissionsQuery("action_permission", []string{"table_slug", "custom_event_id"}, []string{`"label"`, `"permission"`})
+
+	assert.Contains(t, query, `INSERT INTO "action_permission" (role_id, "label", "permission")`)
+	assert.Contains(t, query, `SELECT $1::UUID, p."label", p."permission"`)
+	assert.Contains(t, query, `FROM "effective_action_permission" p`)
+	assert.Contains(t, query, `p.effective_role_id::TEXT = $2`)
+	assert.Contains(t, query, `o.role_id = $1::UUID AND o."table_slug" IS NOT DISTINCT FROM p."table_slug" AND o."custom_event_id" IS NOT DISTINCT FROM p."custom_event_id"`)
+
+	query = copyPermissionsQuery("global_permission", nil, []string{`"chat"`})
+	assert.Contains(t, query, `FROM "effective_global_permission" p`)
+	assert.Contains(t, query, `(SELECT 1 FROM "global_permission" o WHERE o.role_id = $1::UUID)`)
+}
@@ -0,0 +1,303 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	"ucode/ucode_go_object_builder_service/pkg/sqlguard"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Row-level permissions are rules in record_permission.conditions, by
+// action. Read rules are added to the WHERE clause of every read query,
+// update and delete rules are checked on the rows before they change and
+// create rules on the rows a create inserts.
+
+func isAdminClientType(ctx context.Context, q querier, clientTypeId string) (bool, error) {
+	return helper.IsAdminClientType(ctx, q, clientTypeId)
+}
+
+// loadRowRule returns the rule of the role of subject for action on the
+// rows of tableSlug, nil when they are not restricted.
+func loadRowRule(ctx context.Context, q querier, tableSlug string, subject authz.Subject, action authz.Action) (*authz.Rule, error) {
+	if !subject.Restricted() {
+		return nil, nil
+	}
+
+	admin, err := isAdminClientType(ctx, q, subject.ClientTypeId)
+	if err != nil || admin {
+		return nil, err
+	}
+
+	var raw []byte
+	err = q.QueryRow(ctx, `
+		SELECT conditions FROM effective_record_permission
+		WHERE table_slug = $1 AND effective_role_id::TEXT = $2`, tableSlug, subject.RoleId,
+	).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting record permission conditions")
+	}
+
+	conditions, err := authz.ParseConditions(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "record permission of %s", tableSlug)
+	}
+
+	return conditions[action], nil
+}
+
+// userVariables returns the values of the $user variables of rule.
+func userVariables(ctx context.Context, q querier, subject authz.Subject, rule *authz.Rule) (map[string]any, error) {
+	vars := map[string]any{
+		"id":             subject.UserId,
+		"role_id":        subject.RoleId,
+		"client_type_id": subject.ClientTypeId,
+	}
+
+	var fromRow bool
+	for _, name := range rule.Variables() {
+		if _, ok := vars[name]; !ok {
+			fromRow = true
+		}
+	}
+	if !fromRow || subject.UserId == "" || subject.ClientTypeId == "" {
+		return vars, nil
+	}
+
+	var loginTable string
+	err := q.QueryRow(ctx, `SELECT COALESCE(table_slug, '') FROM client_type WHERE guid::TEXT = $1`, subject.ClientTypeId).Scan(&loginTable)
+	if errors.Is(err, pgx.ErrNoRows) || loginTable == "" {
+		return vars, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting login table")
+	}
+
+	var user map[string]any
+	query := fmt.Sprintf(`SELECT to_jsonb(u) FROM %s u WHERE u.guid::TEXT = $1`, pq.QuoteIdentifier(loginTable))
+	err = q.QueryRow(ctx, query, subject.UserId).Scan(&user)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.Wrap(err, "error while getting user")
+	}
+
+	for name, value := range user {
+		if _, ok := vars[name]; !ok {
+			vars[name] = value
+		}
+	}
+
+	return vars, nil
+}
+
+func tableColumns(ctx context.Context, q querier, tableSlug string) (map[string]bool, error) {
+	rows, err := q.Query(ctx, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1`, tableSlug)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting columns")
+	}
+	columns, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting columns")
+	}
+
+	existing := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		existing[column] = true
+	}
+	return existing, nil
+}
+
+// validateRuleFields fails with InvalidArgument when rule compares a field
+// tableSlug has no column for.
+func validateRuleFields(ctx context.Context, q querier, tableSlug string, action authz.Action, rule *authz.Rule) error {
+	columns, err := tableColumns(ctx, q, tableSlug)
+	if err != nil {
+		return err
+	}
+	for _, field := range rule.Fields() {
+		if !columns[field] {
+			return status.Errorf(codes.InvalidArgument, "%s condition of %s: unknown field %s", action, tableSlug, field)
+		}
+	}
+	return nil
+}
+
+// rowCondition returns the SQL condition on the rows of tableSlug, aliased
+// alias, that the subject may access with action, or "" when it may access
+// all of them.
+func rowCondition(ctx context.Context, q querier, tableSlug, alias string, subject authz.Subject, action authz.Action) (string, error) {
+	rule, err := loadRowRule(ctx, q, tableSlug, subject, action)
+	if err != nil || rule == nil {
+		return "", err
+	}
+
+	if err := validateRuleFields(ctx, q, tableSlug, action, rule); err != nil {
+		return "", err
+	}
+
+	vars, err := userVariables(ctx, q, subject, rule)
+	if err != nil {
+		return "", err
+	}
+
+	condition, err := rule.SQL(alias, vars)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "%s condition of %s: %v", action, tableSlug, err)
+	}
+
+	return condition, nil
+}
+
+// checkRowAccess fails with PermissionDenied when a row of ids does not
+// pass the rule of the subject for action.
+func checkRowAccess(ctx context.Context, q querier, tableSlug string, subject authz.Subject, action authz.Action, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	condition, err := rowCondition(ctx, q, tableSlug, "a", subject, action)
+	if err != nil || condition == "" {
+		return err
+	}
+
+	var denied int
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s a WHERE a.guid::TEXT = ANY($1) AND NOT %s`, pq.QuoteIdentifier(tableSlug), condition)
+	if err := q.QueryRow(ctx, query, pq.Array(ids)).Scan(&denied); err != nil {
+		return errors.Wrap(err, "error while checking row permissions")
+	}
+	if denied > 0 {
+		return status.Errorf(codes.PermissionDenied, "no %s permission on %d %s rows", action, denied, tableSlug)
+	}
+
+	return nil
+}
+
+// readCondition is rowCondition for the reads of a request with params,
+// prefixed with AND to extend a WHERE clause.
+func readCondition(ctx context.Context, q querier, tableSlug, alias string, params map[string]any) (string, error) {
+	condition, err := rowCondition(ctx, q, tableSlug, alias, authz.Resolve(ctx, params), authz.Read)
+	if err != nil || condition == "" {
+		return "", err
+	}
+	return " AND " + condition + " ", nil
+}
+
+// readFilter returns the read condition of the request, as readCondition.
+// Roles without a read rule that still have is_have_condition set get the
+// legacy automatic filters of helper.GetAutomaticFilter merged into params
+// instead.
+func readFilter(ctx context.Context, conn *psqlpool.Pool, tableSlug, alias string, params map[string]any) (string, map[string]any, error) {
+	condition, err := readCondition(ctx, conn, tableSlug, alias, params)
+	if err != nil || condition != "" {
+		return condition, params, err
+	}
+
+	roleId := cast.ToString(params["role_id_from_token"])
+	recordPermission, err := helper.GetRecordPermission(ctx, models.GetRecordPermissionRequest{
+		Conn:      conn,
+		TableSlug: tableSlug,
+		RoleId:    roleId,
+	})
+	if err != nil {
+		return "", params, errors.Wrap(err, "when get recordPermission")
+	}
+
+	if recordPermission.IsHaveCondition {
+		params, err = helper.GetAutomaticFilter(ctx, models.GetAutomaticFilterRequest{
+			Conn:            conn,
+			Params:          params,
+			RoleIdFromToken: roleId,
+			UserIdFromToken: cast.ToString(params["user_id_from_token"]),
+			TableSlug:       tableSlug,
+		})
+		if err != nil {
+			return "", params, errors.Wrap(err, "when get GetAutomaticFilter")
+		}
+	}
+
+	return "", params, nil
+}
+
+// tableSource matches a source of the aggregation API that is a table with
+// an optional alias: orders, "orders" o, public.orders AS o.
+var tableSource = regexp.MustCompile(`(?i)^\s*(?:(?:public|"public")\.)?("(?:[^"]|"")+"|[a-z_][a-z0-9_]*)(?:\s+(?:as\s+)?("(?:[^"]|"")+"|[a-z_][a-z0-9_]*))?\s*$`)
+
+// sourceTable returns the table of source, a source of the aggregation API,
+// or "" when it is not a table with an optional alias.
+func sourceTable(source string) string {
+	match := tableSource.FindStringSubmatch(source)
+	if match == nil {
+		return ""
+	}
+	return strings.ReplaceAll(strings.Trim(match[1], `"`), `""`, `"`)
+}
+
+// restrictSource wraps source, a table with an optional alias as the
+// aggregation API takes it, in a subquery of the rows of the table the
+// subject may read. Any other source, such as a subquery, may only read
+// tables the subject has no read rule on.
+func restrictSource(ctx context.Context, q querier, source string, subject authz.Subject) (string, error) {
+	match := tableSource.FindStringSubmatch(source)
+	if match == nil {
+		return source, checkSourceTables(ctx, q, source, subject)
+	}
+
+	table := strings.ReplaceAll(strings.Trim(match[1], `"`), `""`, `"`)
+	alias := pq.QuoteIdentifier(table)
+	if match[2] != "" {
+		alias = match[2]
+	}
+
+	condition, err := rowCondition(ctx, q, table, "r", subject, authz.Read)
+	if err != nil || condition == "" {
+		return source, err
+	}
+
+	return fmt.Sprintf("(SELECT * FROM %s r WHERE %s) %s", pq.QuoteIdentifier(table), condition, alias), nil
+}
+
+// checkSourceTables fails with PermissionDenied when source is not a read
+// of tables, or reads a table the subject has a read rule on: a rule can
+// not be applied inside a source the service did not write.
+func checkSourceTables(ctx context.Context, q querier, source string, subject authz.Subject) error {
+	if !subject.Restricted() {
+		return nil
+	}
+
+	analysis, err := sqlguard.Analyze("SELECT * FROM " + source)
+	if err != nil || !analysis.ReadOnly() || !analysis.Complete() {
+		return status.Errorf(codes.PermissionDenied, "source %s is not a table or a read of tables", source)
+	}
+
+	for _, stmt := range analysis.Statements {
+		for _, table := range stmt.Tables {
+			if table.Schema != "" && table.Schema != "public" {
+				return status.Errorf(codes.PermissionDenied, "source %s reads %s outside the tenant schema", source, table)
+			}
+			rule, err := loadRowRule(ctx, q, table.Name, subject, authz.Read)
+			if err != nil {
+				return err
+			}
+			if rule != nil {
+				return status.Errorf(codes.PermissionDenied, "%s has a read condition and can only be a source as a table", table.Name)
+			}
+		}
+	}
+	return nil
+}
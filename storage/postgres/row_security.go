@@ -0,0 +1,275 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// Row security compiles the record permissions and row-level rules of the
+// roles into Postgres policies on the tables that turn it on, so they also
+// hold for the raw SQL of ExecuteSQL and custom endpoints. That SQL runs
+// with the subject of the request in the ucode.role_id, ucode.user_id and
+// ucode.client_type_id settings of its transaction and sees no rows
+// without a role. Only the connections of the service itself, which turn
+// psqlpool.RowSecurityBypass on, see every row.
+
+const rowSecurityPolicyPrefix = "ucode_rls_"
+
+var rowSecurityCommands = []struct {
+	action  authz.Action
+	command string
+}{
+	{authz.Read, "SELECT"},
+	{authz.Create, "INSERT"},
+	{authz.Update, "UPDATE"},
+	{authz.Delete, "DELETE"},
+}
+
+// rowSecuritySettings maps the variables of the subject to the settings
+// that carry them.
+var rowSecuritySettings = map[string]string{
+	"id":             "ucode.user_id",
+	"role_id":        "ucode.role_id",
+	"client_type_id": "ucode.client_type_id",
+}
+
+type rowSecurityRole struct {
+	Id         string
+	Record     map[authz.Action]string
+	Conditions authz.Conditions
+	Admin      bool
+	LoginTable string
+}
+
+func currentSetting(name string) string {
+	return fmt.Sprintf("NULLIF(current_setting(%s, true), '')", pq.QuoteLiteral(name))
+}
+
+// setRowSecurityContext turns the bypass of the service off in tx and puts
+// the subject of ctx in the settings the policies read. Without a subject
+// the settings are empty, and tables with row security show no rows.
+func setRowSecurityContext(ctx context.Context, tx pgx.Tx) error {
+	subject, _ := authz.SubjectFromContext(ctx)
+
+	return psqlpool.SetLocal(ctx, tx, map[string]string{
+		psqlpool.RowSecurityBypass:            "off",
+		rowSecuritySettings["role_id"]:        subject.RoleId,
+		rowSecuritySettings["id"]:             subject.UserId,
+		rowSecuritySettings["client_type_id"]: subject.ClientTypeId,
+	})
+}
+
+// checkRowSecuritySupported fails when the database user of conn bypasses
+// row security, as superusers do, which would make the policies no-ops.
+func checkRowSecuritySupported(ctx context.Context, q querier) error {
+	var bypass bool
+	err := q.QueryRow(ctx, `SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user`).Scan(&bypass)
+	if err != nil {
+		return errors.Wrap(err, "error while getting database user")
+	}
+	if bypass {
+		return errors.New("the database user bypasses row level security")
+	}
+	return nil
+}
+
+// syncRowSecurity regenerates the policies of tableSlug from the current
+// permissions of its roles. Tables without row security are left alone.
+func syncRowSecurity(ctx context.Context, q querier, tableSlug string) error {
+	var enabled bool
+	err := q.QueryRow(ctx, `SELECT COALESCE(row_level_security, false) FROM "table" WHERE slug = $1`, tableSlug).Scan(&enabled)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !enabled) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error while getting row level security")
+	}
+
+	roles, err := loadRowSecurityRoles(ctx, q, tableSlug)
+	if err != nil {
+		return err
+	}
+
+	if err := dropRowSecurity(ctx, q, tableSlug); err != nil {
+		return err
+	}
+
+	table := pq.QuoteIdentifier(tableSlug)
+	for _, c := range rowSecurityCommands {
+		expression, err := rowSecurityExpression(ctx, q, tableSlug, roles, c.action)
+		if err != nil {
+			return err
+		}
+
+		clause := "USING"
+		if c.action == authz.Create {
+			clause = "WITH CHECK"
+		}
+
+		query := fmt.Sprintf(`CREATE POLICY %s ON %s FOR %s %s (%s)`,
+			pq.QuoteIdentifier(rowSecurityPolicyPrefix+string(c.action)), table, c.command, clause, expression)
+		if _, err := q.Exec(ctx, query); err != nil {
+			return errors.Wrapf(err, "error while creating %s policy", c.action)
+		}
+	}
+
+	_, err = q.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s ENABLE ROW LEVEL SECURITY, FORCE ROW LEVEL SECURITY`, table))
+	return errors.Wrap(err, "error while enabling row level security")
+}
+
+// dropRowSecurity removes the policies of tableSlug.
+func dropRowSecurity(ctx context.Context, q querier, tableSlug string) error {
+	for _, c := range rowSecurityCommands {
+		query := fmt.Sprintf(`DROP POLICY IF EXISTS %s ON %s`,
+			pq.QuoteIdentifier(rowSecurityPolicyPrefix+string(c.action)), pq.QuoteIdentifier(tableSlug))
+		if _, err := q.Exec(ctx, query); err != nil {
+			return errors.Wrapf(err, "error while dropping %s policy", c.action)
+		}
+	}
+	return nil
+}
+
+// loadRowSecurityRoles returns the roles with a record permission on
+// tableSlug, their own or the one of their nearest parent.
+func loadRowSecurityRoles(ctx context.Context, q querier, tableSlug string) ([]rowSecurityRole, error) {
+	rows, err := q.Query(ctx, `
+		WITH RECURSIVE chain AS (
+			SELECT guid AS role_id, guid AS ancestor_id, parent_id, 0 AS depth FROM role
+			UNION ALL
+			SELECT c.role_id, r.guid, r.parent_id, c.depth + 1
+			FROM chain c
+			JOIN role r ON r.guid = c.parent_id
+			WHERE c.depth < $2
+		)
+		SELECT DISTINCT ON (c.role_id)
+			c.role_id::TEXT,
+			COALESCE(rp.read, 'Yes'),
+			COALESCE(rp.write, 'Yes'),
+			COALESCE(rp.update, 'Yes'),
+			COALESCE(rp.delete, 'Yes'),
+			COALESCE(rp.conditions, '{}'),
+			COALESCE(ct.name = 'ADMIN', false),
+			COALESCE(ct.table_slug, '')
+		FROM chain c
+		JOIN record_permission rp ON rp.role_id = c.ancestor_id AND rp.table_slug = $1
+		JOIN role r ON r.guid = c.role_id
+		LEFT JOIN client_type ct ON ct.guid = r.client_type_id
+		ORDER BY c.role_id, c.depth`, tableSlug, helper.MaxRoleDepth)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting record permissions")
+	}
+	defer rows.Close()
+
+	var roles []rowSecurityRole
+	for rows.Next() {
+		var (
+			role                     = rowSecurityRole{Record: make(map[authz.Action]string)}
+			read, write, update, del string
+			conditions               []byte
+		)
+
+		if err := rows.Scan(&role.Id, &read, &write, &update, &del, &conditions, &role.Admin, &role.LoginTable); err != nil {
+			return nil, errors.Wrap(err, "error while scanning record permissions")
+		}
+
+		role.Record[authz.Read], role.Record[authz.Create] = read, write
+		role.Record[authz.Update], role.Record[authz.Delete] = update, del
+
+		if role.Conditions, err = authz.ParseConditions(conditions); err != nil {
+			return nil, errors.Wrapf(err, "record permission of %s", tableSlug)
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// rowSecurityExpression compiles the rules of roles for action into the
+// expression of a policy, a CASE on the role of the setting. It fails
+// closed: without the bypass or a role no row passes.
+func rowSecurityExpression(ctx context.Context, q querier, tableSlug string, roles []rowSecurityRole, action authz.Action) (string, error) {
+	var cases []string
+	for _, role := range roles {
+		if role.Admin {
+			continue
+		}
+
+		condition := "TRUE"
+		if role.Record[action] == "No" {
+			condition = "FALSE"
+		} else if rule := role.Conditions[action]; rule != nil {
+			if err := validateRuleFields(ctx, q, tableSlug, action, rule); err != nil {
+				return "", err
+			}
+
+			vars, err := rowSecurityVariables(ctx, q, role, rule)
+			if err != nil {
+				return "", err
+			}
+
+			if condition, err = rule.SQL(pq.QuoteIdentifier(tableSlug), vars); err != nil {
+				return "", errors.Wrapf(err, "%s condition of %s", action, tableSlug)
+			}
+		}
+
+		if condition != "TRUE" {
+			cases = append(cases, fmt.Sprintf("WHEN %s THEN %s", pq.QuoteLiteral(role.Id), condition))
+		}
+	}
+
+	var (
+		bypass  = fmt.Sprintf("COALESCE(current_setting(%s, true), '') = 'on'", pq.QuoteLiteral(psqlpool.RowSecurityBypass))
+		setting = currentSetting(rowSecuritySettings["role_id"])
+	)
+	if len(cases) == 0 {
+		return fmt.Sprintf("%s OR %s IS NOT NULL", bypass, setting), nil
+	}
+
+	return fmt.Sprintf("%s OR (%s IS NOT NULL AND CASE %s %s ELSE TRUE END)",
+		bypass, setting, setting, strings.Join(cases, " ")), nil
+}
+
+// rowSecurityVariables returns the $user variables of rule as expressions
+// on the settings. Fields of the user are read from the login table of the
+// client type of the role; variables it has no column for stay unset.
+func rowSecurityVariables(ctx context.Context, q querier, role rowSecurityRole, rule *authz.Rule) (map[string]any, error) {
+	vars := make(map[string]any, len(rowSecuritySettings))
+	for name, setting := range rowSecuritySettings {
+		vars[name] = authz.Expr(currentSetting(setting))
+	}
+
+	var fields []string
+	for _, name := range rule.Variables() {
+		if _, ok := vars[name]; !ok {
+			fields = append(fields, name)
+		}
+	}
+	if len(fields) == 0 || role.LoginTable == "" {
+		return vars, nil
+	}
+
+	columns, err := tableColumns(ctx, q, role.LoginTable)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range fields {
+		if !columns[name] {
+			continue
+		}
+		vars[name] = authz.Expr(fmt.Sprintf(`SELECT u.%s FROM %s u WHERE u.guid::TEXT = %s`,
+			pq.QuoteIdentifier(name), pq.QuoteIdentifier(role.LoginTable), currentSetting(rowSecuritySettings["id"])))
+	}
+
+	return vars, nil
+}
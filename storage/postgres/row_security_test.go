@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowSecurityExpressionFailsClosed(t *testing.T) {
+	const (
+		bypass = `COALESCE(current_setting('ucode.bypass', true), '') = 'on'`
+		role   = `NULLIF(current_setting('ucode.role_id', true), '')`
+	)
+
+	expression, err := rowSecurityExpression(context.Background(), nil, "orders", nil, authz.Read)
+	require.NoError(t, err)
+	assert.Equal(t, bypass+" OR "+role+" IS NOT NULL", expression)
+
+	roles := []rowSecurityRole{
+		{Id: "admin", Admin: true, Record: map[authz.Action]string{authz.Read: "No"}},
+		{Id: "guest", Record: map[authz.Action]string{authz.Read: "No"}},
+		{Id: "user", Record: map[authz.Action]string{authz.Read: "Yes"}},
+	}
+	expression, err = rowSecurityExpression(context.Background(), nil, "orders", roles, authz.Read)
+	require.NoError(t, err)
+	assert.Equal(t, bypass+" OR ("+role+" IS NOT NULL AND CASE "+role+" WHEN 'guest' THEN FALSE ELSE TRUE END)", expression)
+}
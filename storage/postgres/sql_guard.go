@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"ucode/ucode_go_object_builder_service/models"
+	"ucode/ucode_go_object_builder_service/pkg/logger"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+)
+
+const (
+	sqlAuditSourceExecuteSQL     = "execute_sql"
+	sqlAuditSourceCustomEndpoint = "custom_endpoint"
+
+	sqlAuditWriteTimeout = 5 * time.Second
+)
+
+// beginGuardedTx opens the transaction user SQL runs in. Read-only queries get
+// a READ ONLY transaction so the server rejects any write that slipped past
+// the classifier, and the row security policies see the subject of ctx.
+func beginGuardedTx(ctx context.Context, conn *psqlpool.Pool, readOnly bool, timeout time.Duration) (pgx.Tx, error) {
+	txOptions := pgx.TxOptions{}
+	if readOnly {
+		txOptions.AccessMode = pgx.ReadOnly
+	}
+
+	tx, err := conn.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = psqlpool.SetStatementTimeout(ctx, tx, timeout); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	if err = setRowSecurityContext(ctx, tx); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// writeSQLAudit records an execution of user SQL. It runs detached from the
+// request context so timed out or cancelled queries are still audited.
+func writeSQLAudit(ctx context.Context, conn *psqlpool.Pool, log logger.LoggerI, entry models.SQLAuditEntry) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sqlAuditWriteTimeout)
+	defer cancel()
+
+	var endpointId any
+	if _, err := uuid.Parse(entry.EndpointId); err == nil {
+		endpointId = entry.EndpointId
+	}
+
+	_, err := conn.Exec(ctx, `
+		INSERT INTO sql_audit_log (
+			source, endpoint_id, sql_query, operations, tables,
+			read_only, allowed, reason, error, rows_affected, duration_ms
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		entry.Source,
+		endpointId,
+		entry.Query,
+		pq.Array(entry.Operations),
+		pq.Array(entry.Tables),
+		entry.ReadOnly,
+		entry.Allowed,
+		nullString(entry.Reason),
+		nullString(entry.Error),
+		entry.RowsAffected,
+		entry.Duration.Milliseconds(),
+	)
+	if err != nil && log != nil {
+		log.Error("failed to write sql audit log", logger.String("source", entry.Source), logger.Error(err))
+	}
+}
@@ -203,7 +203,7 @@ func (t *tableRepo) Create(ctx context.Context, req *nb.CreateTableRequest) (res
 				role_id
 			) VALUES ($1, $2)`
 
-	query = `SELECT guid FROM role`
+	query = `SELECT guid FROM role WHERE parent_id IS NULL`
 
 	rows, err := tx.Query(ctx, query)
 	if err != nil {
@@ -917,7 +917,7 @@ func (t *tableRepo) Update(ctx context.Context, req *nb.UpdateTableRequest) (res
 		return &nb.Table{}, errors.Wrap(err, "failed to update table")
 	}
 
-	query = `SELECT guid FROM "role" `
+	query = `SELECT guid FROM "role" WHERE parent_id IS NULL`
 
 	rows, err := tx.Query(ctx, query)
 	if err != nil {
@@ -2345,7 +2345,7 @@ func (t *tableRepo) CreateWithTx(ctx context.Context, req *nb.CreateTableRequest
 	}
 
 	var roleIds = []string{}
-	query = `SELECT guid FROM role`
+	query = `SELECT guid FROM role WHERE parent_id IS NULL`
 
 	rows, err := tx.Query(ctx, query)
 	if err != nil {
@@ -0,0 +1,449 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"ucode/ucode_go_object_builder_service/config"
+	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+	psqlpool "ucode/ucode_go_object_builder_service/pool"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// treeRelation is the recursive relation of a table: parentField holds the
+// guid of the parent row, and with treePath the table keeps a materialized
+// path of every row in tree_path.
+type treeRelation struct {
+	table       string
+	parentField string
+	treePath    bool
+}
+
+func loadTreeRelation(ctx context.Context, q querier, tableSlug string) (relation treeRelation, ok bool, err error) {
+	relation.table = tableSlug
+
+	err = q.QueryRow(ctx, `
+		SELECT r.field_to, COALESCE((r.attributes->>'materialized_path')::BOOLEAN, false)
+		FROM relation r
+		JOIN information_schema.columns c
+			ON c.table_schema = current_schema() AND c.table_name = r.table_from AND c.column_name = r.field_to
+		WHERE r.type = $1 AND r.table_from = $2 AND r.deleted_at IS NULL
+		LIMIT 1`,
+		config.RECURSIVE, tableSlug,
+	).Scan(&relation.parentField, &relation.treePath)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return relation, false, nil
+	}
+	if err != nil {
+		return relation, false, errors.Wrap(err, "error while getting recursive relation")
+	}
+
+	return relation, true, nil
+}
+
+func requireTreeRelation(ctx context.Context, q querier, tableSlug string) (treeRelation, error) {
+	relation, ok, err := loadTreeRelation(ctx, q, tableSlug)
+	if err != nil {
+		return relation, err
+	}
+	if !ok {
+		return relation, status.Errorf(codes.FailedPrecondition, "table %s has no recursive relation", tableSlug)
+	}
+	return relation, nil
+}
+
+// lockTree serializes the moves within a table, so two concurrent moves
+// cannot each pass the cycle check and close a cycle together.
+func lockTree(ctx context.Context, q querier, tableSlug string) error {
+	if _, err := q.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "tree:"+tableSlug); err != nil {
+		return errors.Wrap(err, "error while locking tree")
+	}
+	return nil
+}
+
+// checkTreeParent fails when parentId is id itself or one of its
+// descendants, which would turn the subtree of id into a cycle.
+func checkTreeParent(ctx context.Context, q querier, relation treeRelation, id, parentId string) error {
+	if parentId == "" {
+		return nil
+	}
+	if parentId == id {
+		return status.Error(codes.InvalidArgument, config.ErrTheSameId)
+	}
+
+	var (
+		table  = pq.QuoteIdentifier(relation.table)
+		parent = pq.QuoteIdentifier(relation.parentField)
+		cycle  bool
+	)
+
+	query := fmt.Sprintf(`
+		WITH RECURSIVE ancestors AS (
+			SELECT guid, %[2]s AS parent, ARRAY[guid] AS visited FROM %[1]s WHERE guid = $1
+			UNION ALL
+			SELECT t.guid, t.%[2]s, a.visited || t.guid
+			FROM %[1]s t
+			JOIN ancestors a ON t.guid = a.parent
+			WHERE NOT t.guid = ANY(a.visited)
+		)
+		SELECT EXISTS(SELECT 1 FROM ancestors WHERE guid::TEXT = $2)`, table, parent)
+
+	if err := q.QueryRow(ctx, query, parentId, id).Scan(&cycle); err != nil {
+		return errors.Wrap(err, "error while checking tree cycle")
+	}
+	if cycle {
+		return status.Errorf(codes.InvalidArgument, "cannot move %s under its descendant %s", id, parentId)
+	}
+
+	return nil
+}
+
+// validateTreeParent checks the parent of a created or updated row of a
+// table with a recursive relation. Only a row that has descendants can
+// close a cycle, so the tree is locked and walked only when an existing
+// row changes its parent.
+func validateTreeParent(ctx context.Context, q querier, tableSlug, id string, data map[string]any) error {
+	relation, ok, err := loadTreeRelation(ctx, q, tableSlug)
+	if err != nil || !ok {
+		return err
+	}
+
+	value, ok := data[relation.parentField]
+	if !ok || helper.IsEmpty(value) {
+		return nil
+	}
+
+	parentId := cast.ToString(value)
+	if parentId == id {
+		return status.Error(codes.InvalidArgument, config.ErrTheSameId)
+	}
+
+	var current string
+	query := fmt.Sprintf(`SELECT COALESCE(%s::TEXT, '') FROM %s WHERE guid::TEXT = $1`,
+		pq.QuoteIdentifier(relation.parentField), pq.QuoteIdentifier(relation.table))
+	err = q.QueryRow(ctx, query, id).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) || current == parentId {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error while getting tree parent")
+	}
+
+	if err := lockTree(ctx, q, tableSlug); err != nil {
+		return err
+	}
+
+	return checkTreeParent(ctx, q, relation, id, parentId)
+}
+
+// treeReadFilter checks that the subject of the request may read the row
+// id of tableSlug and returns the condition on the other nodes it may
+// read, aliased t, as readCondition.
+func treeReadFilter(ctx context.Context, q querier, tableSlug, id string) (string, error) {
+	if err := checkRowAccess(ctx, q, tableSlug, authz.Resolve(ctx, nil), authz.Read, id); err != nil {
+		return "", err
+	}
+	return readCondition(ctx, q, tableSlug, "t", nil)
+}
+
+// scanTreeRows returns the nodes of rows with the field permissions of
+// access applied.
+func scanTreeRows(rows pgx.Rows, access *helper.FieldAccess) ([]any, error) {
+	defer rows.Close()
+
+	var nodes []any
+	for rows.Next() {
+		var node map[string]any
+		if err := rows.Scan(&node); err != nil {
+			return nil, errors.Wrap(err, "error while scanning tree node")
+		}
+		access.Apply(node)
+		nodes = append(nodes, node)
+	}
+
+	return nodes, rows.Err()
+}
+
+func treeResponse(req *nb.TreeRequest, nodes []any) (*nb.CommonMessage, error) {
+	data, err := helper.ConvertMapToStruct(map[string]any{
+		"response": nodes,
+		"count":    len(nodes),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error while converting tree")
+	}
+
+	return &nb.CommonMessage{
+		ProjectId: req.GetProjectId(),
+		TableSlug: req.GetTableSlug(),
+		Data:      data,
+	}, nil
+}
+
+// GetAncestors returns the parents of a row up to the root, nearest first,
+// each with its depth above the row.
+func (i *itemsRepo) GetAncestors(ctx context.Context, req *nb.TreeRequest) (resp *nb.CommonMessage, err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.GetAncestors")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	relation, err := requireTreeRelation(ctx, conn, req.GetTableSlug())
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := treeReadFilter(ctx, conn, req.GetTableSlug(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := helper.LoadFieldAccess(ctx, conn, req.GetTableSlug(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		table  = pq.QuoteIdentifier(relation.table)
+		parent = pq.QuoteIdentifier(relation.parentField)
+	)
+
+	query := fmt.Sprintf(`
+		WITH RECURSIVE ancestors AS (
+			SELECT p.guid, 1 AS depth, ARRAY[n.guid, p.guid] AS visited
+			FROM %[1]s n
+			JOIN %[1]s p ON p.guid = n.%[2]s
+			WHERE n.guid = $1 AND p.deleted_at IS NULL
+			UNION ALL
+			SELECT p.guid, a.depth + 1, a.visited || p.guid
+			FROM ancestors a
+			JOIN %[1]s c ON c.guid = a.guid
+			JOIN %[1]s p ON p.guid = c.%[2]s
+			WHERE p.deleted_at IS NULL AND NOT p.guid = ANY(a.visited) AND ($2 = 0 OR a.depth < $2)
+		)
+		SELECT to_jsonb(t) || jsonb_build_object('depth', a.depth)
+		FROM ancestors a
+		JOIN %[1]s t ON t.guid = a.guid
+		WHERE true %[3]s
+		ORDER BY a.depth`, table, parent, filter)
+
+	rows, err := conn.Query(ctx, query, req.GetId(), req.GetDepth())
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting ancestors")
+	}
+
+	nodes, err := scanTreeRows(rows, access)
+	if err != nil {
+		return nil, err
+	}
+
+	return treeResponse(req, nodes)
+}
+
+// GetDescendants returns the subtree under a row, level by level, each
+// node with its depth below the row. With depth above 0 only that many
+// levels are returned. Tables with a materialized path read the subtree
+// from tree_path instead of walking it. Nodes under a deleted node are
+// left out, as are the nodes the read rule of the role hides.
+func (i *itemsRepo) GetDescendants(ctx context.Context, req *nb.TreeRequest) (resp *nb.CommonMessage, err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.GetDescendants")
+	defer dbSpan.Finish()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	relation, err := requireTreeRelation(ctx, conn, req.GetTableSlug())
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := treeReadFilter(ctx, conn, req.GetTableSlug(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := helper.LoadFieldAccess(ctx, conn, req.GetTableSlug(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		table  = pq.QuoteIdentifier(relation.table)
+		parent = pq.QuoteIdentifier(relation.parentField)
+		query  string
+	)
+
+	if relation.treePath {
+		query = fmt.Sprintf(`
+			WITH descendants AS (
+				SELECT d.guid,
+					(length(d.%[2]s) - length(replace(d.%[2]s, '/', ''))) -
+					(length(r.%[2]s) - length(replace(r.%[2]s, '/', ''))) AS depth
+				FROM %[1]s r
+				JOIN %[1]s d ON d.%[2]s LIKE r.%[2]s || '%%' AND d.guid <> r.guid
+				WHERE r.guid = $1 AND d.deleted_at IS NULL AND NOT EXISTS (
+					SELECT 1 FROM %[1]s a
+					WHERE a.guid = ANY(string_to_array(trim(BOTH '/' FROM substr(d.%[2]s, length(r.%[2]s) + 1)), '/')::UUID[])
+						AND a.deleted_at IS NOT NULL
+				)
+			)
+			SELECT to_jsonb(t) || jsonb_build_object('depth', d.depth)
+			FROM descendants d
+			JOIN %[1]s t ON t.guid = d.guid
+			WHERE ($2 = 0 OR d.depth <= $2) %[3]s
+			ORDER BY d.depth, t.created_at`, table, config.TREE_PATH, filter)
+	} else {
+		query = fmt.Sprintf(`
+			WITH RECURSIVE descendants AS (
+				SELECT guid, 1 AS depth, ARRAY[$1::UUID, guid] AS visited
+				FROM %[1]s
+				WHERE %[2]s = $1 AND deleted_at IS NULL
+				UNION ALL
+				SELECT c.guid, d.depth + 1, d.visited || c.guid
+				FROM descendants d
+				JOIN %[1]s c ON c.%[2]s = d.guid
+				WHERE c.deleted_at IS NULL AND NOT c.guid = ANY(d.visited) AND ($2 = 0 OR d.depth < $2)
+			)
+			SELECT to_jsonb(t) || jsonb_build_object('depth', d.depth)
+			FROM descendants d
+			JOIN %[1]s t ON t.guid = d.guid
+			WHERE true %[3]s
+			ORDER BY d.depth, t.created_at`, table, parent, filter)
+	}
+
+	rows, err := conn.Query(ctx, query, req.GetId(), req.GetDepth())
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting descendants")
+	}
+
+	nodes, err := scanTreeRows(rows, access)
+	if err != nil {
+		return nil, err
+	}
+
+	return treeResponse(req, nodes)
+}
+
+// MoveSubtree moves a row, and with it its subtree, under a new parent or,
+// without one, to the root. A move under the row's own subtree is refused.
+func (i *itemsRepo) MoveSubtree(ctx context.Context, req *nb.MoveSubtreeRequest) (resp *nb.CommonMessage, err error) {
+	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "items.MoveSubtree")
+	defer dbSpan.Finish()
+
+	defer func() {
+		if err == nil {
+			invalidateCache(ctx, req.GetProjectId(), req.GetTableSlug())
+		}
+	}()
+
+	conn, err := psqlpool.Get(req.GetProjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while beginning transaction")
+	}
+
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	relation, err := requireTreeRelation(ctx, tx, req.GetTableSlug())
+	if err != nil {
+		return nil, err
+	}
+
+	// A move is an update of the parent field of the row.
+	permissions, err := authorizeWrite(ctx, tx, req.GetTableSlug(), authz.Update, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(permissions.Editable([]string{relation.parentField})) == 0 {
+		return nil, status.Errorf(codes.PermissionDenied, "no permission to edit %s of %s", relation.parentField, req.GetTableSlug())
+	}
+	if err := checkRowAccess(ctx, tx, req.GetTableSlug(), authz.Resolve(ctx, nil), authz.Update, req.GetId()); err != nil {
+		return nil, err
+	}
+
+	if err := lockTree(ctx, tx, req.GetTableSlug()); err != nil {
+		return nil, err
+	}
+
+	if err := checkTreeParent(ctx, tx, relation, req.GetId(), req.GetNewParentId()); err != nil {
+		return nil, err
+	}
+
+	var newParent any
+	if req.GetNewParentId() != "" {
+		var exists bool
+		query := fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s WHERE guid = $1 AND deleted_at IS NULL)`, pq.QuoteIdentifier(relation.table))
+		if err := tx.QueryRow(ctx, query, req.GetNewParentId()).Scan(&exists); err != nil {
+			return nil, errors.Wrap(err, "error while getting new parent")
+		}
+		if !exists {
+			return nil, status.Errorf(codes.NotFound, "parent %s not found", req.GetNewParentId())
+		}
+		newParent = req.GetNewParentId()
+	}
+
+	var (
+		table          = pq.QuoteIdentifier(relation.table)
+		previous, node map[string]any
+	)
+
+	query := fmt.Sprintf(`SELECT to_jsonb(%[1]s.*) FROM %[1]s WHERE guid = $1 AND deleted_at IS NULL FOR UPDATE`, table)
+	err = tx.QueryRow(ctx, query, req.GetId()).Scan(&previous)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.GetId())
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting moved row")
+	}
+
+	query = fmt.Sprintf(`UPDATE %[1]s SET %[2]s = $2, updated_at = now() WHERE guid = $1 RETURNING to_jsonb(%[1]s.*)`,
+		table, pq.QuoteIdentifier(relation.parentField))
+	if err = tx.QueryRow(ctx, query, req.GetId(), newParent).Scan(&node); err != nil {
+		return nil, errors.Wrap(err, "error while moving subtree")
+	}
+
+	if err = recordItemHistory(ctx, tx, req.GetTableSlug(), "UPDATE", previous, node); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errors.Wrap(err, "error while committing transaction")
+	}
+
+	access, err := helper.LoadFieldAccess(ctx, conn, req.GetTableSlug(), nil)
+	if err != nil {
+		return nil, err
+	}
+	access.Apply(node)
+
+	data, err := helper.ConvertMapToStruct(node)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while converting moved row")
+	}
+
+	return &nb.CommonMessage{
+		ProjectId: req.GetProjectId(),
+		TableSlug: req.GetTableSlug(),
+		Data:      data,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateTreeParent(t *testing.T) {
+	relation := fakeRow{match: "FROM relation r", values: []any{"parent_id", false}}
+
+	tests := []struct {
+		name   string
+		rows   []fakeRow
+		data   map[string]any
+		locked bool
+		code   codes.Code
+	}{
+		{name: "no recursive relation", data: map[string]any{"parent_id": "p"}},
+		{name: "no parent", rows: []fakeRow{relation}, data: map[string]any{"name": "a"}},
+		{name: "own parent", rows: []fakeRow{relation}, data: map[string]any{"parent_id": "a"}, code: codes.InvalidArgument},
+		{name: "new row", rows: []fakeRow{relation}, data: map[string]any{"parent_id": "p"}},
+		{
+			name: "same parent",
+			rows: []fakeRow{relation, {match: `SELECT COALESCE("parent_id"::TEXT`, values: []any{"p"}}},
+			data: map[string]any{"parent_id": "p"},
+		},
+		{
+			name:   "new parent",
+			rows:   []fakeRow{relation, {match: `SELECT COALESCE("parent_id"::TEXT`, values: []any{"old"}}, {match: "WITH RECURSIVE ancestors", values: []any{false}}},
+			data:   map[string]any{"parent_id": "p"},
+			locked: true,
+		},
+		{
+			name:   "under own descendant",
+			rows:   []fakeRow{relation, {match: `SELECT COALESCE("parent_id"::TEXT`, values: []any{"old"}}, {match: "WITH RECURSIVE ancestors", values: []any{true}}},
+			data:   map[string]any{"parent_id": "p"},
+			locked: true,
+			code:   codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{rows: tt.rows}
+
+			err := validateTreeParent(context.Background(), q, "category", "a", tt.data)
+			assert.Equal(t, tt.code, status.Code(err))
+			assert.Equal(t, tt.locked, q.ran("pg_advisory_xact_lock"))
+		})
+	}
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -10,23 +11,43 @@ import (
 	"ucode/ucode_go_object_builder_service/config"
 
 	nb "ucode/ucode_go_object_builder_service/genproto/new_object_builder_service"
+	"ucode/ucode_go_object_builder_service/pkg/audit"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
 	psqlpool "ucode/ucode_go_object_builder_service/pool"
 	"ucode/ucode_go_object_builder_service/storage"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
 	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var versionHistoryPartitionNameRe = regexp.MustCompile(`^version_history_p_\d{4}_\d{2}_\d{2}$`)
 
+// schemaHistorySources are the action sources of schema changes, whose
+// payloads hold no items.
+var schemaHistorySources = map[string]bool{
+	"RELATION": true,
+	"FIELD":    true,
+	"MENU":     true,
+	"TABLE":    true,
+	"LAYOUT":   true,
+	"VIEW":     true,
+}
+
 type versionHistoryRepo struct {
-	db *psqlpool.Pool
+	db  *psqlpool.Pool
+	cfg config.Config
 }
 
-func NewVersionHistoryRepo(db *psqlpool.Pool) storage.VersionHistoryRepoI {
+func NewVersionHistoryRepo(db *psqlpool.Pool, cfg config.Config) storage.VersionHistoryRepoI {
 	return &versionHistoryRepo{
-		db: db,
+		db:  db,
+		cfg: cfg,
 	}
 }
 
@@ -103,6 +124,10 @@ func (v *versionHistoryRepo) GetById(ctx context.Context, req *nb.VersionHistory
 	history.StatusCode = statusCode.Int64
 	history.TableLabel = tableLabel.String
 
+	if err := maskHistory(ctx, conn, history, make(map[string]*helper.FieldAccess)); err != nil {
+		return &nb.VersionHistory{}, err
+	}
+
 	return history, nil
 }
 
@@ -176,7 +201,10 @@ func (v *versionHistoryRepo) GetAll(ctx context.Context, req *nb.GetAllRquest) (
 	}
 	defer rows.Close()
 
-	var histories []*nb.VersionHistory
+	var (
+		histories []*nb.VersionHistory
+		accesses  = make(map[string]*helper.FieldAccess)
+	)
 	for rows.Next() {
 		var (
 			history       nb.VersionHistory
@@ -217,6 +245,10 @@ func (v *versionHistoryRepo) GetAll(ctx context.Context, req *nb.GetAllRquest) (
 		history.StatusCode = statusCode.Int64
 		history.TableLabel = tableLabel.String
 
+		if err := maskHistory(ctx, conn, &history, accesses); err != nil {
+			return nil, err
+		}
+
 		histories = append(histories, &history)
 	}
 
@@ -270,7 +302,48 @@ func (v *versionHistoryRepo) Create(ctx context.Context, req *nb.CreateVersionHi
 		return err
 	}
 
-	versionH := `INSERT INTO version_history (
+	labelQuery := `SELECT label, slug FROM "table" `
+	tableLabel, tableSlug := "", ""
+
+	if err := uuid.Validate(req.TableSlug); err != nil {
+		labelQuery += fmt.Sprintf(`WHERE slug = '%s'`, req.TableSlug)
+	} else {
+		labelQuery += fmt.Sprintf(`WHERE id = '%s'`, req.TableSlug)
+	}
+
+	err = conn.QueryRow(ctx, labelQuery).Scan(&tableLabel, &tableSlug)
+	if err != nil && !strings.Contains(err.Error(), "no rows") {
+		return err
+	}
+
+	if err := encryptHistory(ctx, conn, tableSlug, req); err != nil {
+		return err
+	}
+
+	if req.Type == "" {
+		req.Type = "GLOBAL"
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error creating transaction")
+	}
+
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := insertVersionHistory(ctx, tx, req, tableLabel); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// insertVersionHistory adds req to the version history and to the audit
+// chain within tx.
+func insertVersionHistory(ctx context.Context, tx pgx.Tx, req *nb.CreateVersionHistoryRequest, tableLabel string) error {
+	versionH := `INSERT INTO version_history AS vh (
 		action_source,
 		action_type,
 		previous,
@@ -288,27 +361,17 @@ func (v *versionHistoryRepo) Create(ctx context.Context, req *nb.CreateVersionHi
 		duration,
 		status_code,
 		table_label
-	) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17)`
-
-	labelQuery := `SELECT label FROM "table" `
-	tableLabel := ""
-
-	if err := uuid.Validate(req.TableSlug); err != nil {
-		labelQuery += fmt.Sprintf(`WHERE slug = '%s'`, req.TableSlug)
-	} else {
-		labelQuery += fmt.Sprintf(`WHERE id = '%s'`, req.TableSlug)
-	}
+	) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17)
+	RETURNING id::TEXT, created_at, ` + versionHistoryContentHash
 
-	err = conn.QueryRow(ctx, labelQuery).Scan(&tableLabel)
-	if err != nil && !strings.Contains(err.Error(), "no rows") {
-		return err
-	}
-
-	if req.Type == "" {
-		req.Type = "GLOBAL"
+	entry := audit.Entry{
+		ActionSource: req.ActionSource,
+		ActionType:   req.ActionType,
+		TableSlug:    req.TableSlug,
+		UserInfo:     req.UserInfo,
 	}
 
-	_, err = conn.Exec(ctx, versionH,
+	err := tx.QueryRow(ctx, versionH,
 		req.ActionSource,
 		req.ActionType,
 		[]byte(req.Previus),
@@ -326,12 +389,52 @@ func (v *versionHistoryRepo) Create(ctx context.Context, req *nb.CreateVersionHi
 		req.Duration,
 		req.StatusCode,
 		tableLabel,
-	)
+	).Scan(&entry.VersionHistoryId, &entry.CreatedAt, &entry.ContentHash)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return appendAudit(ctx, tx, entry)
+}
+
+// recordItemHistory adds a change the service makes to the rows of
+// tableSlug on its own, which no API call records, to the version history
+// within tx. The rows are as stored, so encrypted fields stay encrypted.
+func recordItemHistory(ctx context.Context, tx pgx.Tx, tableSlug, actionType string, previous, current map[string]any) error {
+	var tableLabel string
+	err := tx.QueryRow(ctx, `SELECT COALESCE(label, '') FROM "table" WHERE slug = $1`, tableSlug).Scan(&tableLabel)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return errors.Wrap(err, "error while getting table label")
+	}
+
+	payload := func(row map[string]any) (string, error) {
+		if row == nil {
+			return "{}", nil
+		}
+		data, err := json.Marshal(row)
+		return string(data), err
+	}
+
+	req := &nb.CreateVersionHistoryRequest{
+		ActionSource: "ITEM",
+		ActionType:   actionType,
+		Date:         time.Now().UTC().Format(time.RFC3339),
+		Request:      "{}",
+		Response:     "{}",
+		Type:         "GLOBAL",
+		TableSlug:    tableSlug,
+	}
+	if subject, ok := authz.SubjectFromContext(ctx); ok {
+		req.UserInfo = subject.UserId
+	}
+	if req.Previus, err = payload(previous); err != nil {
+		return errors.Wrap(err, "error while marshaling previous row")
+	}
+	if req.Current, err = payload(current); err != nil {
+		return errors.Wrap(err, "error while marshaling current row")
+	}
+
+	return insertVersionHistory(ctx, tx, req, tableLabel)
 }
 
 func (v *versionHistoryRepo) CreateFunctionLog(ctx context.Context, req *nb.FunctionLogReq) error {
@@ -521,6 +624,9 @@ func (v *versionHistoryRepo) DeleteFunctionLogs(ctx context.Context, projectId s
 //  1. ensures partitions exist for the current and next week,
 //  2. drops partitions whose date range ends before the retention cutoff
 //     (today - VERSION_HISTORY_EXPIRE_DAY days). DROP returns disk space to the OS immediately.
+//     A checkpoint of the audit log is exported first and partitions newer
+//     than it are kept, so no row leaves before its audit entry does. Without
+//     an audit signing key, or when the checkpoint fails, nothing is dropped.
 func (v *versionHistoryRepo) RotateVersionHistoryPartitions(ctx context.Context, projectId string) error {
 	dbSpan, ctx := opentracing.StartSpanFromContext(ctx, "version_history.RotateVersionHistoryPartitions")
 	defer dbSpan.Finish()
@@ -549,6 +655,30 @@ func (v *versionHistoryRepo) RotateVersionHistoryPartitions(ctx context.Context,
 		}
 	}
 
+	// Rows only leave with a partition once a checkpoint exported their
+	// audit entries, so without a signing key nothing is dropped.
+	key, err := v.auditSigningKey()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return status.Error(codes.FailedPrecondition, "audit signing key is not configured, version_history partitions are kept")
+	}
+	if _, err := v.CreateAuditCheckpoint(ctx, &nb.CreateAuditCheckpointRequest{ProjectId: projectId}); err != nil {
+		return fmt.Errorf("create audit checkpoint, version_history partitions are kept: %w", err)
+	}
+
+	checkpoint, err := lastAuditCheckpoint(ctx, conn)
+	if err != nil {
+		return err
+	}
+	switch {
+	case checkpoint == nil:
+		return nil
+	case checkpoint.createdAt.Before(cutoff):
+		cutoff = checkpoint.createdAt
+	}
+
 	const listPartitionsQuery = `
 		SELECT
 			c.relname,
@@ -655,3 +785,122 @@ func (v *versionHistoryRepo) GetPerformanceMetrics(ctx context.Context, req *nb.
 		ErrorRate:       errorRate,
 	}, nil
 }
+
+// maskHistory applies the field access of the subject of ctx on the table
+// of an item history to its payloads. accesses caches the access by table.
+func maskHistory(ctx context.Context, conn *psqlpool.Pool, history *nb.VersionHistory, accesses map[string]*helper.FieldAccess) error {
+	if history.TableSlug == "" || schemaHistorySources[history.ActionSource] {
+		return nil
+	}
+
+	access, ok := accesses[history.TableSlug]
+	if !ok {
+		var err error
+		if access, err = helper.LoadFieldAccess(ctx, conn, history.TableSlug, nil); err != nil {
+			return err
+		}
+		accesses[history.TableSlug] = access
+	}
+	if access == nil {
+		return nil
+	}
+
+	history.Previus = maskPayload(access, history.Previus)
+	history.Current = maskPayload(access, history.Current)
+	history.Request = maskPayload(access, history.Request)
+	history.Response = maskPayload(access, history.Response)
+
+	return nil
+}
+
+// maskPayload applies access to every object of a JSON payload. Payloads
+// that are not JSON are returned as they are.
+func maskPayload(access *helper.FieldAccess, payload string) string {
+	masked, err := walkPayload(payload, func(object map[string]any) error {
+		access.Apply(object)
+		return nil
+	})
+	if err != nil {
+		return payload
+	}
+	return masked
+}
+
+// encryptHistory encrypts the values of the encrypted fields of tableSlug
+// in the payloads of an item history, so they are not kept in plaintext.
+func encryptHistory(ctx context.Context, conn *psqlpool.Pool, tableSlug string, req *nb.CreateVersionHistoryRequest) error {
+	if tableSlug == "" || schemaHistorySources[req.ActionSource] {
+		return nil
+	}
+
+	cipher, err := helper.LoadFieldCipher(ctx, conn, tableSlug)
+	if err != nil || cipher == nil {
+		return err
+	}
+
+	encrypt := func(object map[string]any) error {
+		for slug, value := range object {
+			encrypted, err := cipher.Encrypt(slug, value)
+			if err != nil {
+				return err
+			}
+			object[slug] = encrypted
+		}
+		return nil
+	}
+
+	for _, payload := range []*string{&req.Previus, &req.Current, &req.Request, &req.Response} {
+		encrypted, err := walkPayload(*payload, encrypt)
+		if errors.Is(err, errNotJSON) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		*payload = encrypted
+	}
+
+	return nil
+}
+
+var errNotJSON = errors.New("payload is not JSON")
+
+// walkPayload calls fn on every object of a JSON payload and returns the
+// payload it changed.
+func walkPayload(payload string, fn func(object map[string]any) error) (string, error) {
+	var value any
+	if err := json.Unmarshal([]byte(payload), &value); err != nil {
+		return "", errNotJSON
+	}
+
+	var walk func(value any) error
+	walk = func(value any) error {
+		switch v := value.(type) {
+		case map[string]any:
+			if err := fn(v); err != nil {
+				return err
+			}
+			for _, nested := range v {
+				if err := walk(nested); err != nil {
+					return err
+				}
+			}
+		case []any:
+			for _, nested := range v {
+				if err := walk(nested); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(value); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", errors.Wrap(err, "error while marshalling payload")
+	}
+	return string(data), nil
+}
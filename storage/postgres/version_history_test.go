@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskPayload(t *testing.T) {
+	access := &helper.FieldAccess{
+		Hidden: map[string]bool{"salary": true},
+		Masks:  map[string]authz.Mask{"phone": authz.MaskLast4, "email": authz.MaskEmailDomain},
+	}
+
+	payload := `{"data": {"guid": "1", "phone": "+998901234567", "email": "a@b.uz", "salary": 100}, "items": [{"phone": ""}]}`
+	want := `{"data":{"email":"****@b.uz","guid":"1","phone":"*********4567"},"items":[{"phone":""}]}`
+	assert.Equal(t, want, maskPayload(access, payload))
+
+	assert.Equal(t, "not json", maskPayload(access, "not json"))
+}
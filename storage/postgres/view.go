@@ -153,7 +153,7 @@ func (v viewRepo) Create(ctx context.Context, req *nb.CreateViewRequest) (resp *
 		return nil, errors.Wrap(err, "failed to insert view")
 	}
 
-	roles, err := tx.Query(ctx, `SELECT guid FROM role`)
+	roles, err := tx.Query(ctx, `SELECT guid FROM role WHERE parent_id IS NULL`)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get roles")
 	}
@@ -337,8 +337,8 @@ func (v viewRepo) GetList(ctx context.Context, req *nb.GetAllViewsRequest) (resp
 
 		permissionsQuery := `
 			SELECT role_id
-			FROM view_permission
-			WHERE view_id = $1 AND role_id = $2
+			FROM effective_view_permission
+			WHERE view_id = $1 AND effective_role_id::TEXT = $2
 `
 		rows, err := conn.Query(ctx, permissionsQuery, row.Id, req.RoleId)
 		if err != nil {
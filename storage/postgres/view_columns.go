@@ -0,0 +1,442 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"ucode/ucode_go_object_builder_service/config"
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/formula"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// computedColumn is a column a view declares in attributes.computed_columns
+// on top of the fields of its table. One of Expression, Path and Rollup
+// defines it:
+//
+//	{"slug": "total", "expression": "price * quantity", "type": "NUMBER"}
+//	{"slug": "region", "path": "customer.region.name"}
+//	{"slug": "spent", "rollup": {"table": "order", "field": "customer_id", "function": "sum", "column": "amount"}}
+//
+// Expressions use the formula syntax over the fields of the table. A path
+// follows Many2One lookups, named by their field with or without _id, to a
+// column of the last table. A rollup aggregates the rows of a child table
+// whose field points at the row. Type is the field type the column is
+// filtered as; it defaults to the type of the column a path ends in, NUMBER
+// for counts, sums and averages, and FORMULA for expressions.
+//
+// Paths and rollups read other tables as the reader would read them: rows
+// of tables it has no read permission on and rows outside its read rule
+// are not joined, hidden columns are NULL, and values are decrypted and
+// masked as the field access of their table says.
+type computedColumn struct {
+	Slug       string  `json:"slug"`
+	Type       string  `json:"type"`
+	Expression string  `json:"expression"`
+	Path       string  `json:"path"`
+	Rollup     *rollup `json:"rollup"`
+}
+
+type rollup struct {
+	Table    string `json:"table"`
+	Field    string `json:"field"`
+	Function string `json:"function"`
+	Column   string `json:"column"`
+}
+
+// viewColumns is the SQL of the computed columns of a view: the lookup
+// joins their paths need and an expression for each column.
+type viewColumns struct {
+	q       querier
+	table   string
+	fields  map[string]string
+	subject authz.Subject
+	access  func(table string) (*helper.FieldAccess, error)
+	joins   []string
+	aliases map[string]string
+	columns []string
+	types   map[string]string
+	// reads are the columns of other tables the computed columns show, to
+	// decrypt and mask after the query.
+	reads map[string]columnRead
+}
+
+// columnRead is a column of another table a computed column shows.
+type columnRead struct {
+	access *helper.FieldAccess
+	column string
+}
+
+// loadComputedColumns returns the computed columns of the view, which has
+// to be a view of tableSlug.
+func loadComputedColumns(ctx context.Context, q querier, viewId, tableSlug string) ([]computedColumn, error) {
+	var raw []byte
+	err := q.QueryRow(ctx, `SELECT COALESCE(attributes->'computed_columns', '[]') FROM view WHERE id::TEXT = $1 AND table_slug = $2`, viewId, tableSlug).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Errorf(codes.NotFound, "view %s of %s not found", viewId, tableSlug)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error while getting view computed columns")
+	}
+
+	var columns []computedColumn
+	if err := json.Unmarshal(raw, &columns); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid computed_columns of view %s: %v", viewId, err)
+	}
+
+	return columns, nil
+}
+
+// compileComputedColumns turns the computed columns of a view over
+// tableSlug, whose fields map slugs to types, into SQL that reads other
+// tables as subject, with the field access access returns for them.
+func compileComputedColumns(ctx context.Context, q querier, tableSlug string, fields map[string]string, columns []computedColumn, subject authz.Subject, access func(table string) (*helper.FieldAccess, error)) (*viewColumns, error) {
+	v := &viewColumns{
+		q:       q,
+		table:   tableSlug,
+		fields:  fields,
+		subject: subject,
+		access:  access,
+		aliases: map[string]string{"": "a"},
+		types:   make(map[string]string),
+		reads:   make(map[string]columnRead),
+	}
+
+	for _, column := range columns {
+		if column.Slug == "" {
+			return nil, status.Error(codes.InvalidArgument, "computed column without slug")
+		}
+		if _, ok := fields[column.Slug]; ok {
+			return nil, status.Errorf(codes.InvalidArgument, "computed column %s conflicts with a field", column.Slug)
+		}
+		if _, ok := v.types[column.Slug]; ok {
+			return nil, status.Errorf(codes.InvalidArgument, "computed column %s is declared twice", column.Slug)
+		}
+
+		var (
+			expr, ftype string
+			err         error
+		)
+		switch {
+		case column.Expression != "":
+			expr, ftype, err = v.expression(column.Expression)
+		case column.Path != "":
+			expr, ftype, err = v.path(ctx, column.Slug, column.Path)
+		case column.Rollup != nil:
+			expr, ftype, err = v.rollup(ctx, column.Slug, column.Rollup)
+		default:
+			err = status.Error(codes.InvalidArgument, "needs an expression, path or rollup")
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "computed column %s", column.Slug)
+		}
+
+		if column.Type != "" {
+			ftype = column.Type
+		}
+		v.types[column.Slug] = ftype
+		v.columns = append(v.columns, fmt.Sprintf(`%s AS %s`, expr, pq.QuoteIdentifier(column.Slug)))
+	}
+
+	return v, nil
+}
+
+// source is the row source of a list query with the computed columns: the
+// table joined to the lookups of the paths, aliased so that the computed
+// columns read like fields of the table.
+func (v *viewColumns) source() string {
+	if v == nil || len(v.columns) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(`(SELECT a.*, %s FROM %s a %s)`,
+		strings.Join(v.columns, ", "), pq.QuoteIdentifier(v.table), strings.Join(v.joins, " "))
+}
+
+func (v *viewColumns) expression(src string) (string, string, error) {
+	program, err := formula.CompileCached(src)
+	if err != nil {
+		return "", "", status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	expr, err := program.SQL(func(name string) (string, error) {
+		if _, ok := v.fields[name]; !ok {
+			return "", status.Errorf(codes.InvalidArgument, "unknown field %s", name)
+		}
+		return "a." + pq.QuoteIdentifier(name), nil
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Error(codes.InvalidArgument, err.Error())
+		}
+		return "", "", err
+	}
+
+	return expr, "FORMULA", nil
+}
+
+// path joins the lookups of every segment but the last, which is a column
+// of the last table. Paths that share a prefix share their joins. A path
+// through a table the reader may not read, or to a column it may not see,
+// is NULL.
+func (v *viewColumns) path(ctx context.Context, slug, path string) (string, string, error) {
+	segments := strings.Split(path, ".")
+	if len(segments) < 2 {
+		return "", "", status.Errorf(codes.InvalidArgument, "path %s has no lookup", path)
+	}
+
+	var (
+		table    = v.table
+		prefix   = ""
+		readable = true
+	)
+	for _, segment := range segments[:len(segments)-1] {
+		field, tableTo, err := v.lookup(ctx, table, segment)
+		if err != nil {
+			return "", "", err
+		}
+
+		key := prefix + "." + field
+		if _, ok := v.aliases[key]; !ok && readable {
+			source, ok, err := v.readSource(ctx, tableTo)
+			if err != nil {
+				return "", "", err
+			}
+			alias := fmt.Sprintf("p%d", len(v.aliases))
+			if !ok {
+				alias = ""
+			} else {
+				v.joins = append(v.joins, fmt.Sprintf(`LEFT JOIN %s %s ON %s.guid = %s.%s AND %s.deleted_at IS NULL`,
+					source, alias, alias, v.aliases[prefix], pq.QuoteIdentifier(field), alias))
+			}
+			v.aliases[key] = alias
+		}
+		if v.aliases[key] == "" {
+			readable = false
+		}
+
+		table, prefix = tableTo, key
+	}
+
+	column := segments[len(segments)-1]
+	ftype, err := fieldType(ctx, v.q, table, column)
+	if err != nil {
+		return "", "", err
+	}
+	if !readable {
+		return "NULL", ftype, nil
+	}
+
+	access, err := v.access(table)
+	if err != nil {
+		return "", "", err
+	}
+	if access != nil && access.Hidden[column] {
+		return "NULL", ftype, nil
+	}
+	v.reads[slug] = columnRead{access: access, column: column}
+
+	return v.aliases[prefix] + "." + pq.QuoteIdentifier(column), ftype, nil
+}
+
+// readSource returns the rows of table the reader may read, as a join
+// source: the table, or a subquery of the rows its read rule allows. ok is
+// false when the reader may not read the table at all.
+func (v *viewColumns) readSource(ctx context.Context, table string) (string, bool, error) {
+	ok, err := readsTable(ctx, v.q, table, v.subject)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	condition, err := rowCondition(ctx, v.q, table, "r", v.subject, authz.Read)
+	if err != nil {
+		return "", false, err
+	}
+	if condition == "" {
+		return pq.QuoteIdentifier(table), true, nil
+	}
+
+	return fmt.Sprintf("(SELECT * FROM %s r WHERE %s)", pq.QuoteIdentifier(table), condition), true, nil
+}
+
+// readsTable reports whether subject may read the rows of table: the
+// service, subjects without a role and ADMIN client types always may,
+// roles when their record permission, or a public one of the table, has
+// read Yes.
+func readsTable(ctx context.Context, q querier, table string, subject authz.Subject) (bool, error) {
+	if !subject.Restricted() {
+		return true, nil
+	}
+
+	admin, err := isAdminClientType(ctx, q, subject.ClientTypeId)
+	if err != nil || admin {
+		return admin, err
+	}
+
+	var read bool
+	err = q.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM effective_record_permission
+			WHERE table_slug = $1 AND effective_role_id::TEXT = $2 AND read = 'Yes'
+		) OR EXISTS (
+			SELECT 1 FROM record_permission
+			WHERE table_slug = $1 AND read = 'Yes' AND (role_id IS NULL OR is_public = true)
+		)`, table, subject.RoleId,
+	).Scan(&read)
+	if err != nil {
+		return false, errors.Wrap(err, "error while getting read permission")
+	}
+
+	return read, nil
+}
+
+// apply decrypts and masks the columns of other tables in items as their
+// field access says.
+func (v *viewColumns) apply(items ...map[string]any) {
+	if v == nil {
+		return
+	}
+
+	for _, item := range items {
+		for slug, read := range v.reads {
+			if value, ok := item[slug]; ok {
+				item[slug] = read.access.Value(read.column, value)
+			}
+		}
+	}
+}
+
+// lookup resolves a segment of a path to the Many2One field of table it
+// names and the table the field points at.
+func (v *viewColumns) lookup(ctx context.Context, table, segment string) (string, string, error) {
+	var field, tableTo string
+	err := v.q.QueryRow(ctx, `
+		SELECT field_from, table_to FROM relation
+		WHERE deleted_at IS NULL AND type = $1 AND table_from = $2 AND field_from IN ($3, $3 || '_id')
+		ORDER BY field_from = $3 DESC
+		LIMIT 1`,
+		config.MANY2ONE, table, segment,
+	).Scan(&field, &tableTo)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", status.Errorf(codes.InvalidArgument, "%s has no lookup %s", table, segment)
+	}
+	if err != nil {
+		return "", "", errors.Wrap(err, "error while getting lookup relation")
+	}
+
+	return field, tableTo, nil
+}
+
+var rollupFunctions = map[string]string{
+	"count": "COUNT",
+	"sum":   "SUM",
+	"avg":   "AVG",
+	"min":   "MIN",
+	"max":   "MAX",
+}
+
+// rollup aggregates the rows of a child table that are not deleted, point
+// at the row through a Many2One field and are within the read rule of the
+// reader. It is NULL when the reader may not read the child table or its
+// column, and for sums and averages of masked or encrypted columns.
+func (v *viewColumns) rollup(ctx context.Context, slug string, r *rollup) (string, string, error) {
+	fn, ok := rollupFunctions[strings.ToLower(r.Function)]
+	if !ok {
+		return "", "", status.Errorf(codes.InvalidArgument, "unknown rollup function %q", r.Function)
+	}
+
+	var exists bool
+	err := v.q.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM relation
+		WHERE deleted_at IS NULL AND type = $1 AND table_from = $2 AND field_from = $3 AND table_to = $4)`,
+		config.MANY2ONE, r.Table, r.Field, v.table,
+	).Scan(&exists)
+	if err != nil {
+		return "", "", errors.Wrap(err, "error while getting rollup relation")
+	}
+	if !exists {
+		return "", "", status.Errorf(codes.InvalidArgument, "%s.%s does not point at %s", r.Table, r.Field, v.table)
+	}
+
+	var (
+		value = "*"
+		ftype = "NUMBER"
+	)
+	if fn != "COUNT" || r.Column != "" {
+		if r.Column == "" {
+			return "", "", status.Errorf(codes.InvalidArgument, "rollup %s needs a column", r.Function)
+		}
+		columnType, err := fieldType(ctx, v.q, r.Table, r.Column)
+		if err != nil {
+			return "", "", err
+		}
+		if fn == "MIN" || fn == "MAX" {
+			ftype = columnType
+		}
+		value = "r." + pq.QuoteIdentifier(r.Column)
+	}
+
+	readable, err := readsTable(ctx, v.q, r.Table, v.subject)
+	if err != nil || !readable {
+		return "NULL", ftype, err
+	}
+
+	if value != "*" {
+		access, err := v.access(r.Table)
+		if err != nil {
+			return "", "", err
+		}
+		if access != nil {
+			_, masked := access.Masks[r.Column]
+			switch {
+			case access.Hidden[r.Column], access.Cipher.Encrypted(r.Column) && fn != "COUNT":
+				return "NULL", ftype, nil
+			case masked && (fn == "SUM" || fn == "AVG"):
+				return "NULL", ftype, nil
+			case masked && fn != "COUNT":
+				v.reads[slug] = columnRead{access: access, column: r.Column}
+			}
+		}
+	}
+
+	condition, err := rowCondition(ctx, v.q, r.Table, "r", v.subject, authz.Read)
+	if err != nil {
+		return "", "", err
+	}
+	if condition != "" {
+		condition = " AND " + condition
+	}
+
+	expr := fmt.Sprintf(`(SELECT %s(%s) FROM %s r WHERE r.%s = a.guid AND r.deleted_at IS NULL%s)`,
+		fn, value, pq.QuoteIdentifier(r.Table), pq.QuoteIdentifier(r.Field), condition)
+	if fn == "SUM" {
+		expr = "COALESCE(" + expr + ", 0)"
+	}
+
+	return expr, ftype, nil
+}
+
+func fieldType(ctx context.Context, q querier, table, slug string) (string, error) {
+	var ftype string
+	err := q.QueryRow(ctx, `
+		SELECT f.type FROM field f
+		JOIN "table" t ON t.id = f.table_id
+		WHERE t.slug = $1 AND f.slug = $2`, table, slug,
+	).Scan(&ftype)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", status.Errorf(codes.InvalidArgument, "%s has no field %s", table, slug)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "error while getting field type")
+	}
+
+	return ftype, nil
+}
@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"ucode/ucode_go_object_builder_service/pkg/authz"
+	"ucode/ucode_go_object_builder_service/pkg/helper"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoadComputedColumns(t *testing.T) {
+	q := &fakeQuerier{rows: []fakeRow{{match: "table_slug = $2", values: []any{[]byte(`[{"slug": "total", "expression": "price"}]`)}}}}
+	columns, err := loadComputedColumns(context.Background(), q, "v", "order")
+	require.NoError(t, err)
+	assert.Equal(t, []computedColumn{{Slug: "total", Expression: "price"}}, columns)
+
+	_, err = loadComputedColumns(context.Background(), &fakeQuerier{}, "v", "customer")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestCompileComputedColumns(t *testing.T) {
+	const condition = `COALESCE(r."status" IS DISTINCT FROM 'closed', FALSE)`
+
+	var (
+		role     = authz.Subject{RoleId: "r"}
+		lookup   = fakeRow{match: "SELECT field_from, table_to", values: []any{"customer_id", "customer"}}
+		child    = fakeRow{match: "AND table_to = $4", values: []any{true}}
+		ftype    = fakeRow{match: "SELECT f.type FROM field", values: []any{"SINGLE_LINE"}}
+		readable = func(read bool) fakeRow { return fakeRow{match: "AND read = 'Yes'", values: []any{read}} }
+		rule     = fakeRow{match: "SELECT conditions", values: []any{[]byte(`{"read": {"field": "status", "op": "neq", "value": "closed"}}`)}}
+		access   = &helper.FieldAccess{
+			Hidden: map[string]bool{"salary": true},
+			Masks:  map[string]authz.Mask{"phone": authz.MaskLast4},
+		}
+		path   = func(p string) computedColumn { return computedColumn{Slug: "c", Path: p} }
+		rollup = func(fn, column string) computedColumn {
+			return computedColumn{Slug: "c", Rollup: &rollup{Table: "payment", Field: "order_id", Function: fn, Column: column}}
+		}
+	)
+
+	tests := []struct {
+		name    string
+		subject authz.Subject
+		rows    []fakeRow
+		column  computedColumn
+		want    string
+		reads   bool
+	}{
+		{
+			name:   "path",
+			rows:   []fakeRow{lookup, ftype},
+			column: path("customer.name"),
+			want:   `(SELECT a.*, p1."name" AS "c" FROM "order" a LEFT JOIN "customer" p1 ON p1.guid = a."customer_id" AND p1.deleted_at IS NULL)`,
+			reads:  true,
+		},
+		{
+			name:   "two hops",
+			rows:   []fakeRow{lookup, ftype},
+			column: path("customer.customer.name"),
+			want: `(SELECT a.*, p2."name" AS "c" FROM "order" a ` +
+				`LEFT JOIN "customer" p1 ON p1.guid = a."customer_id" AND p1.deleted_at IS NULL ` +
+				`LEFT JOIN "customer" p2 ON p2.guid = p1."customer_id" AND p2.deleted_at IS NULL)`,
+			reads: true,
+		},
+		{
+			name:    "path with a read rule",
+			subject: role,
+			rows:    []fakeRow{lookup, ftype, readable(true), rule},
+			column:  path("customer.name"),
+			want: `(SELECT a.*, p1."name" AS "c" FROM "order" a LEFT JOIN (SELECT * FROM "customer" r WHERE ` + condition + `) p1 ` +
+				`ON p1.guid = a."customer_id" AND p1.deleted_at IS NULL)`,
+			reads: true,
+		},
+		{
+			name:    "path through a table the role may not read",
+			subject: role,
+			rows:    []fakeRow{lookup, ftype, readable(false)},
+			column:  path("customer.name"),
+			want:    `(SELECT a.*, NULL AS "c" FROM "order" a )`,
+		},
+		{
+			name:   "path to a hidden column",
+			rows:   []fakeRow{lookup, ftype},
+			column: path("customer.salary"),
+			want:   `(SELECT a.*, NULL AS "c" FROM "order" a LEFT JOIN "customer" p1 ON p1.guid = a."customer_id" AND p1.deleted_at IS NULL)`,
+		},
+		{
+			name:   "count",
+			rows:   []fakeRow{child},
+			column: rollup("count", ""),
+			want:   `(SELECT a.*, (SELECT COUNT(*) FROM "payment" r WHERE r."order_id" = a.guid AND r.deleted_at IS NULL) AS "c" FROM "order" a )`,
+		},
+		{
+			name:    "rollup with a read rule",
+			subject: role,
+			rows:    []fakeRow{child, ftype, readable(true), rule},
+			column:  rollup("sum", "amount"),
+			want: `(SELECT a.*, COALESCE((SELECT SUM(r."amount") FROM "payment" r WHERE r."order_id" = a.guid AND r.deleted_at IS NULL AND ` +
+				condition + `), 0) AS "c" FROM "order" a )`,
+		},
+		{
+			name:    "rollup of a table the role may not read",
+			subject: role,
+			rows:    []fakeRow{child, readable(false)},
+			column:  rollup("count", ""),
+			want:    `(SELECT a.*, NULL AS "c" FROM "order" a )`,
+		},
+		{
+			name:   "rollup of a hidden column",
+			rows:   []fakeRow{child, ftype},
+			column: rollup("max", "salary"),
+			want:   `(SELECT a.*, NULL AS "c" FROM "order" a )`,
+		},
+		{
+			name:   "sum of a masked column",
+			rows:   []fakeRow{child, ftype},
+			column: rollup("sum", "phone"),
+			want:   `(SELECT a.*, NULL AS "c" FROM "order" a )`,
+		},
+		{
+			name:   "max of a masked column",
+			rows:   []fakeRow{child, ftype},
+			column: rollup("max", "phone"),
+			want:   `(SELECT a.*, (SELECT MAX(r."phone") FROM "payment" r WHERE r."order_id" = a.guid AND r.deleted_at IS NULL) AS "c" FROM "order" a )`,
+			reads:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{
+				rows:    tt.rows,
+				results: []fakeResult{{match: "information_schema.columns", rows: [][]any{{"status"}}}},
+			}
+			loadAccess := func(string) (*helper.FieldAccess, error) { return access, nil }
+
+			columns, err := compileComputedColumns(context.Background(), q, "order", map[string]string{"price": "NUMBER"}, []computedColumn{tt.column}, tt.subject, loadAccess)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, columns.source())
+			_, reads := columns.reads["c"]
+			assert.Equal(t, tt.reads, reads)
+		})
+	}
+}
+
+func TestViewColumnsApply(t *testing.T) {
+	columns := &viewColumns{reads: map[string]columnRead{
+		"phone": {access: &helper.FieldAccess{Masks: map[string]authz.Mask{"number": authz.MaskLast4}}, column: "number"},
+		"name":  {column: "name"},
+	}}
+
+	item := map[string]any{"phone": "555123456", "name": "n"}
+	columns.apply(item)
+	assert.Equal(t, map[string]any{"phone": authz.MaskLast4.Apply("555123456", nil), "name": "n"}, item)
+}
+
+func TestViewColumnsApplyDecrypts(t *testing.T) {
+	cipher := helper.NewFieldCipher("patient", map[string]bool{"passport": true}, testKeyring(t, 1))
+	passport, err := cipher.Encrypt("passport", "AA1234567")
+	require.NoError(t, err)
+
+	sealed := helper.NewFieldAccess(nil, map[string]bool{"passport": true})
+	sealed.Cipher = cipher
+
+	tests := []struct {
+		name   string
+		access *helper.FieldAccess
+		want   any
+	}{
+		{name: "decrypted", access: &helper.FieldAccess{Cipher: cipher}, want: "AA1234567"},
+		{name: "without the decrypt permission", access: sealed, want: authz.MaskFull.Apply("AA1234567", nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns := &viewColumns{reads: map[string]columnRead{"patient_passport": {access: tt.access, column: "passport"}}}
+			item := map[string]any{"patient_passport": passport}
+			columns.apply(item)
+			assert.Equal(t, tt.want, item["patient_passport"])
+		})
+	}
+}
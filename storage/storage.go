@@ -189,6 +189,12 @@ type PermissionRepoI interface {
 	GetPermissionsByTableSlug(ctx context.Context, req *nb.GetPermissionsByTableSlugRequest) (resp *nb.GetPermissionsByTableSlugResponse, err error)
 	UpdatePermissionsByTableSlug(ctx context.Context, req *nb.UpdatePermissionsRequest) (err error)
 	GetTablePermission(ctx context.Context, req *nb.GetTablePermissionRequest) (resp *nb.GetTablePermissionResponse, err error)
+	SetRowLevelSecurity(ctx context.Context, req *nb.SetRowLevelSecurityRequest) (err error)
+	ExplainAccess(ctx context.Context, req *nb.ExplainAccessRequest) (resp *nb.ExplainAccessResponse, err error)
+	SetRoleParent(ctx context.Context, req *nb.SetRoleParentRequest) (err error)
+	CreatePermissionTemplate(ctx context.Context, req *nb.CreatePermissionTemplateRequest) (resp *nb.PermissionTemplate, err error)
+	GetPermissionTemplates(ctx context.Context, req *nb.GetPermissionTemplatesRequest) (resp *nb.GetPermissionTemplatesResponse, err error)
+	DeletePermissionTemplate(ctx context.Context, req *nb.DeletePermissionTemplateRequest) error
 }
 
 type ItemsRepoI interface {
@@ -201,6 +207,13 @@ type ItemsRepoI interface {
 	MultipleUpdate(ctx context.Context, req *nb.CommonMessage) (resp *nb.CommonMessage, err error)
 	UpsertMany(ctx context.Context, req *nb.CommonMessage) error
 	UpdateByUserIdAuth(ctx context.Context, req *nb.CommonMessage) (resp *nb.CommonMessage, err error)
+	RecalculateAll(ctx context.Context, req *nb.RecalculateAllRequest) (resp *nb.RecalculateAllResponse, err error)
+	GetRecalculationStatus(ctx context.Context, req *nb.GetRecalculationStatusRequest) (resp *nb.GetRecalculationStatusResponse, err error)
+	GetAncestors(ctx context.Context, req *nb.TreeRequest) (resp *nb.CommonMessage, err error)
+	GetDescendants(ctx context.Context, req *nb.TreeRequest) (resp *nb.CommonMessage, err error)
+	MoveSubtree(ctx context.Context, req *nb.MoveSubtreeRequest) (resp *nb.CommonMessage, err error)
+	ProcessRecalculationQueue(ctx context.Context, projectId string, batchSize int) (processed int, err error)
+	ReencryptFields(ctx context.Context, projectId string, batchSize int) (processed int, err error)
 }
 
 type ExcelRepoI interface {
@@ -241,6 +254,8 @@ type VersionHistoryRepoI interface {
 	DeleteFunctionLogs(ctx context.Context, projectId string) error
 	RotateVersionHistoryPartitions(ctx context.Context, projectId string) error
 	GetPerformanceMetrics(ctx context.Context, req *nb.GetPerformanceMetricsRequest) (*nb.GetPerformanceMetricsResponse, error)
+	VerifyAuditChain(ctx context.Context, req *nb.VerifyAuditChainRequest) (*nb.VerifyAuditChainResponse, error)
+	CreateAuditCheckpoint(ctx context.Context, req *nb.CreateAuditCheckpointRequest) (*nb.AuditCheckpoint, error)
 }
 type DocxTemplateRepoI interface {
 	Create(ctx context.Context, req *nb.CreateDocxTemplateRequest) (*nb.DocxTemplate, error)
@@ -277,6 +292,11 @@ type CustomPermissionsRepoI interface {
 	GetAllAccesses(ctx context.Context, req *nb.GetAllCustomPermissionAccessesRequest) (*nb.GetCustomPermissionAccessesResponse, error)
 
 	UpdateAccess(ctx context.Context, req *nb.UpdateCustomPermissionAccessRequest) error
+
+	GetMatrix(ctx context.Context, req *nb.GetCustomPermissionMatrixRequest) (*nb.CustomPermissionMatrix, error)
+	UpdateMatrix(ctx context.Context, req *nb.UpdateCustomPermissionMatrixRequest) error
+
+	HasCustomPermission(ctx context.Context, req *nb.HasCustomPermissionRequest) (*nb.HasCustomPermissionResponse, error)
 }
 
 type AiChatRepoI interface {
@@ -332,6 +352,10 @@ type CustomEndpointRepoI interface {
 	GetById(ctx context.Context, req *nb.CustomEndpointId) (*nb.CustomEndpoint, error)
 	Delete(ctx context.Context, req *nb.CustomEndpointId) (*nb.CustomEndpoint, error)
 	Run(ctx context.Context, req *nb.RunCustomEndpointRequest) (*nb.RunCustomEndpointResponse, error)
+	GetRevisionList(ctx context.Context, req *nb.GetCustomEndpointRevisionListRequest) (*nb.CustomEndpointRevisionList, error)
+	GetRevision(ctx context.Context, req *nb.CustomEndpointRevisionId) (*nb.CustomEndpointRevision, error)
+	PublishRevision(ctx context.Context, req *nb.CustomEndpointRevisionId) (*nb.CustomEndpoint, error)
+	DiffRevisions(ctx context.Context, req *nb.DiffCustomEndpointRevisionsRequest) (*nb.CustomEndpointRevisionDiff, error)
 }
 
 type MicrofrontendVersionsRepoI interface {